@@ -0,0 +1,146 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clfTimeFormat is the time format of Common Log Format access logs.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogEntry is a tile request to be recorded in an access log, in Common
+// or Combined Log Format, or as JSON lines.  It is intended to be created by
+// tile handlers for each request using NewAccessLogEntry, and completed with
+// the tileset ID and tile coordinates of the request, for example from
+// PathTemplate.Match.
+type AccessLogEntry struct {
+	RemoteHost string
+	User       string // authenticated user; empty if none
+	Time       time.Time
+	Method     string
+	URI        string // request URI, including the query string
+	Protocol   string
+	Status     int
+	Bytes      int64 // size of the response body
+	Referer    string
+	UserAgent  string
+	Tileset    string     // tileset ID; empty if not a tileset request
+	Coord      *TileCoord // tile coordinates; nil if not a tile request
+	Result     TileResult // outcome of a tile request, such as from ClassifyTileResult
+}
+
+// NewAccessLogEntry creates an AccessLogEntry for r, with the status and size
+// of the response, and the current time.
+func NewAccessLogEntry(r *http.Request, status int, bytes int64) AccessLogEntry {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user, _, _ := r.BasicAuth()
+	return AccessLogEntry{
+		RemoteHost: host,
+		User:       user,
+		Time:       time.Now(),
+		Method:     r.Method,
+		URI:        r.URL.RequestURI(),
+		Protocol:   r.Proto,
+		Status:     status,
+		Bytes:      bytes,
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+	}
+}
+
+// CommonLog formats the entry in Common Log Format, without a trailing
+// newline.
+func (e AccessLogEntry) CommonLog() string {
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	return strings.Join([]string{
+		clfField(e.RemoteHost),
+		"-",
+		clfField(e.User),
+		"[" + e.Time.Format(clfTimeFormat) + "]",
+		strconv.Quote(e.Method + " " + e.URI + " " + e.Protocol),
+		strconv.Itoa(e.Status),
+		bytes,
+	}, " ")
+}
+
+// CombinedLog formats the entry in Combined Log Format, without a trailing
+// newline.  The tileset ID and tile coordinates (z/x/y) are appended as two
+// additional quoted fields, or "-" if not set, which log parsers for extended
+// combined formats can capture.
+func (e AccessLogEntry) CombinedLog() string {
+	tile := ""
+	if e.Coord != nil {
+		tile = e.Coord.String()
+	}
+	return strings.Join([]string{
+		e.CommonLog(),
+		clfQuoted(e.Referer),
+		clfQuoted(e.UserAgent),
+		clfQuoted(e.Tileset),
+		clfQuoted(tile),
+	}, " ")
+}
+
+// JSON formats the entry as a single line of JSON, without a trailing newline.
+// Tile coordinates are formatted as z/x/y, and the result as its name.
+func (e AccessLogEntry) JSON() ([]byte, error) {
+	entry := struct {
+		RemoteHost string `json:"remote_host"`
+		User       string `json:"user,omitempty"`
+		Time       string `json:"time"`
+		Method     string `json:"method"`
+		URI        string `json:"uri"`
+		Protocol   string `json:"protocol"`
+		Status     int    `json:"status"`
+		Bytes      int64  `json:"bytes"`
+		Referer    string `json:"referer,omitempty"`
+		UserAgent  string `json:"user_agent,omitempty"`
+		Tileset    string `json:"tileset,omitempty"`
+		Tile       string `json:"tile,omitempty"`
+		Result     string `json:"result,omitempty"`
+	}{
+		RemoteHost: e.RemoteHost,
+		User:       e.User,
+		Time:       e.Time.Format(time.RFC3339),
+		Method:     e.Method,
+		URI:        e.URI,
+		Protocol:   e.Protocol,
+		Status:     e.Status,
+		Bytes:      e.Bytes,
+		Referer:    e.Referer,
+		UserAgent:  e.UserAgent,
+		Tileset:    e.Tileset,
+		Result:     e.Result.String(),
+	}
+	if e.Coord != nil {
+		entry.Tile = e.Coord.String()
+	}
+	return json.Marshal(entry)
+}
+
+// clfField returns value for an unquoted field of an access log, or "-" if
+// it is empty.  Spaces are escaped so that fields remain separated.
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(value, " ", `\x20`)
+}
+
+// clfQuoted returns value quoted for an access log, or "-" if it is empty.
+func clfQuoted(value string) string {
+	if value == "" {
+		return `"-"`
+	}
+	return strconv.Quote(value)
+}
@@ -0,0 +1,51 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_AccessLogEntry(t *testing.T) {
+	r := httptest.NewRequest("GET", "/tiles/world/1/0/1.png?key=abc", nil)
+	r.RemoteAddr = "192.0.2.1:54321"
+	r.Header.Set("Referer", "https://example.com/map")
+	r.Header.Set("User-Agent", `Mozilla/5.0 "test"`)
+	r.SetBasicAuth("alice", "secret")
+
+	entry := NewAccessLogEntry(r, 200, 2326)
+	entry.Time = time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+
+	expected := `192.0.2.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /tiles/world/1/0/1.png?key=abc HTTP/1.1" 200 2326`
+	if line := entry.CommonLog(); line != expected {
+		t.Error("Common log line", line, "does not match expected value", expected)
+	}
+
+	expected += ` "https://example.com/map" "Mozilla/5.0 \"test\"" "-" "-"`
+	if line := entry.CombinedLog(); line != expected {
+		t.Error("Combined log line", line, "does not match expected value", expected)
+	}
+
+	entry.Tileset = "world"
+	entry.Coord = &TileCoord{Z: 1, X: 0, Y: 1}
+	entry.Status = 304
+	entry.Bytes = 0
+	expected = `192.0.2.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /tiles/world/1/0/1.png?key=abc HTTP/1.1" 304 - "https://example.com/map" "Mozilla/5.0 \"test\"" "world" "1/0/1"`
+	if line := entry.CombinedLog(); line != expected {
+		t.Error("Combined log line", line, "does not match expected value", expected)
+	}
+
+	entry.Result = ResultHit
+	data, err := entry.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["tileset"] != "world" || decoded["tile"] != "1/0/1" || decoded["status"] != 304.0 || decoded["time"] != "2000-10-10T13:55:36-07:00" || decoded["result"] != "hit" {
+		t.Error("Unexpected JSON log line:", string(data))
+	}
+}
@@ -0,0 +1,130 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// earthRadius is the radius in meters used by the Web Mercator projection.
+	earthRadius = 6378137.0
+	// mercatorOrigin is the distance in meters from the center to the edge of
+	// the Web Mercator projection.
+	mercatorOrigin = math.Pi * earthRadius
+	// arcgisDPI is the screen resolution assumed by ArcGIS to compute scales.
+	arcgisDPI = 96
+	// arcgisInchesPerMeter is the conversion used by ArcGIS to compute scales.
+	arcgisInchesPerMeter = 39.37
+)
+
+// ArcGISTileCoord returns the TileCoord (XYZ scheme) for the level, row, and
+// column of an ArcGIS REST MapServer tile request of the form
+// /MapServer/tile/{level}/{row}/{col}.
+func ArcGISTileCoord(level int64, row int64, col int64) TileCoord {
+	return TileCoord{Z: level, X: col, Y: row}
+}
+
+// ArcGISServiceInfo creates a minimal ArcGIS REST MapServer service
+// description for a tiled map service, as served at /MapServer?f=json.  This
+// allows Esri clients to consume the tileset as a tiled layer, with tile
+// requests mapped using ArcGISTileCoord.
+//
+// Only raster tilesets are supported.
+func (db *MBtiles) ArcGISServiceInfo() (map[string]interface{}, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot create service description for closed mbtiles database")
+	}
+	if err := db.init(context.TODO()); err != nil {
+		return nil, err
+	}
+	state := db.loadState()
+	if state.format != PNG && state.format != JPG {
+		return nil, errors.New("ArcGIS service description is only supported for PNG and JPG tilesets")
+	}
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := metadata["name"].(string)
+	description, _ := metadata["description"].(string)
+	attribution, _ := metadata["attribution"].(string)
+	minZoom, _ := metadata["minzoom"].(int)
+	maxZoom, _ := metadata["maxzoom"].(int)
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return nil, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+
+	tilesize := state.tilesize
+	if tilesize == 0 {
+		tilesize = 256
+	}
+
+	spatialReference := map[string]interface{}{"wkid": 102100, "latestWkid": 3857}
+
+	bounds, ok := metadata["bounds"].([]float64)
+	if !ok || len(bounds) != 4 {
+		bounds = []float64{-180, -maxLatitude, 180, maxLatitude}
+	}
+	xmin, ymin := lonLatToMercator(bounds[0], bounds[1])
+	xmax, ymax := lonLatToMercator(bounds[2], bounds[3])
+	extent := map[string]interface{}{
+		"xmin":             xmin,
+		"ymin":             ymin,
+		"xmax":             xmax,
+		"ymax":             ymax,
+		"spatialReference": spatialReference,
+	}
+
+	var lods []map[string]interface{}
+	for z := minZoom; z <= maxZoom; z++ {
+		resolution := 2 * mercatorOrigin / float64(tilesize) / float64(int64(1)<<z)
+		lods = append(lods, map[string]interface{}{
+			"level":      z,
+			"resolution": resolution,
+			"scale":      resolution * arcgisDPI * arcgisInchesPerMeter,
+		})
+	}
+
+	format := "PNG"
+	if state.format == JPG {
+		format = "JPEG"
+	}
+
+	return map[string]interface{}{
+		"currentVersion":      10.4,
+		"mapName":             name,
+		"serviceDescription":  description,
+		"copyrightText":       attribution,
+		"capabilities":        "Map",
+		"singleFusedMapCache": true,
+		"spatialReference":    spatialReference,
+		"initialExtent":       extent,
+		"fullExtent":          extent,
+		"units":               "esriMeters",
+		"minScale":            lods[0]["scale"],
+		"maxScale":            lods[len(lods)-1]["scale"],
+		"tileInfo": map[string]interface{}{
+			"rows":               tilesize,
+			"cols":               tilesize,
+			"dpi":                arcgisDPI,
+			"format":             format,
+			"compressionQuality": 0,
+			"origin":             map[string]interface{}{"x": -mercatorOrigin, "y": mercatorOrigin},
+			"spatialReference":   spatialReference,
+			"lods":               lods,
+		},
+	}, nil
+}
+
+// lonLatToMercator projects longitude and latitude in degrees to Web Mercator
+// coordinates in meters.  Latitude is clamped to the range of the projection.
+func lonLatToMercator(lon float64, lat float64) (float64, float64) {
+	lat = math.Max(-maxLatitude, math.Min(maxLatitude, lat))
+	x := lon * math.Pi / 180 * earthRadius
+	y := math.Log(math.Tan((90+lat)*math.Pi/360)) * earthRadius
+	return x, y
+}
@@ -0,0 +1,79 @@
+package mbtiles
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_ArcGISTileCoord(t *testing.T) {
+	coord := ArcGISTileCoord(3, 2, 5)
+	if coord != (TileCoord{Z: 3, X: 5, Y: 2}) {
+		t.Error("ArcGIS tile coordinate does not match expected value, got:", coord)
+	}
+}
+
+func Test_ArcGISServiceInfo(t *testing.T) {
+	db, err := Open("./testdata/geography-class-jpg.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	info, err := db.ArcGISServiceInfo()
+	if err != nil {
+		t.Fatal("Unexpected error creating service description:", err)
+	}
+
+	if info["mapName"] != "Geography Class" {
+		t.Error("mapName does not match expected value, got:", info["mapName"])
+	}
+
+	tileInfo := info["tileInfo"].(map[string]interface{})
+	if tileInfo["format"] != "JPEG" || tileInfo["rows"] != uint32(256) {
+		t.Error("tileInfo does not match expected values, got:", tileInfo)
+	}
+	lods := tileInfo["lods"].([]map[string]interface{})
+	if len(lods) != 2 {
+		t.Fatal("Expected a level of detail per zoom level, got:", lods)
+	}
+	// standard Web Mercator resolution and scale at zoom level 0
+	if math.Abs(lods[0]["resolution"].(float64)-156543.03392804097) > 1e-6 {
+		t.Error("Zoom level 0 resolution does not match expected value, got:", lods[0]["resolution"])
+	}
+	if math.Abs(lods[0]["scale"].(float64)-591657527.591555) > 1e-3 {
+		t.Error("Zoom level 0 scale does not match expected value, got:", lods[0]["scale"])
+	}
+
+	extent := info["fullExtent"].(map[string]interface{})
+	if math.Abs(extent["xmin"].(float64)+mercatorOrigin) > 1e-6 || math.Abs(extent["xmax"].(float64)-mercatorOrigin) > 1e-6 {
+		t.Error("fullExtent does not match tileset bounds, got:", extent)
+	}
+}
+
+func Test_ArcGISServiceInfo_vector(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ArcGISServiceInfo(); err == nil {
+		t.Error("Creating ArcGIS service description for vector tiles did not raise error")
+	}
+}
+
+func Test_ArcGISServiceInfo_empty_zoom_range(t *testing.T) {
+	db, err := Open(copyTestdata(t, "geography-class-jpg.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.pool.Exec("update metadata set value = '3' where name = 'minzoom'"); err != nil {
+		t.Fatal(err)
+	}
+	db.InvalidateMetadata()
+
+	if _, err := db.ArcGISServiceInfo(); err == nil {
+		t.Error("Creating ArcGIS service description with empty zoom range did not raise error")
+	}
+}
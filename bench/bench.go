@@ -0,0 +1,207 @@
+// Package bench provides a reproducible load generator for reading tiles from
+// an mbtiles file, to compare drivers, pragmas, and cache settings.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	mbtiles "github.com/brendan-ward/mbtiles-go"
+)
+
+// Distribution determines how tiles are chosen for each request.
+type Distribution uint8
+
+// Distribution values
+const (
+	Uniform Distribution = iota // all tiles within bounds are equally likely
+	Zipfian                     // tiles near the center of bounds are requested much more often, as for real map traffic
+)
+
+// Config configures a benchmark run.
+type Config struct {
+	MinZoom      int
+	MaxZoom      int
+	Bounds       []float64 // [west, south, east, north] in degrees; defaults to the bounds in metadata, or the whole world
+	Requests     int       // total number of tile reads
+	Concurrency  int       // number of concurrent readers; defaults to 1
+	Distribution Distribution
+	Seed         int64 // seed for the sequence of tiles; runs with the same seed read the same tiles
+}
+
+// Result summarizes a benchmark run.
+type Result struct {
+	Requests   int
+	Errors     int // number of reads that returned an error
+	Missing    int // number of reads of tiles that do not exist
+	Duration   time.Duration
+	Throughput float64 // reads per second
+	P50        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d requests (%d errors, %d missing) in %v: %.1f/s, p50 %v, p99 %v, max %v",
+		r.Requests, r.Errors, r.Missing, r.Duration, r.Throughput, r.P50, r.P99, r.Max)
+}
+
+// Run reads tiles from db as configured by cfg, and reports the latency and
+// throughput of the reads.  If ctx is canceled, the context's error is
+// returned.
+func Run(ctx context.Context, db *mbtiles.MBtiles, cfg Config) (Result, error) {
+	if cfg.MinZoom < 0 || cfg.MaxZoom > mbtiles.MaxZoomLevel || cfg.MinZoom > cfg.MaxZoom {
+		return Result{}, fmt.Errorf("invalid zoom range %d-%d", cfg.MinZoom, cfg.MaxZoom)
+	}
+	if cfg.Requests < 1 {
+		return Result{}, errors.New("number of requests must be positive")
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	bounds := cfg.Bounds
+	if bounds == nil {
+		metadata, err := db.ReadMetadata()
+		if err != nil {
+			return Result{}, err
+		}
+		bounds, _ = metadata["bounds"].([]float64)
+		if len(bounds) != 4 {
+			bounds = []float64{-180, -85.0511287798066, 180, 85.0511287798066}
+		}
+	}
+
+	coords := sequence(cfg, bounds)
+	latencies := make([]time.Duration, len(coords))
+	errs := make([]error, len(coords))
+	missing := make([]bool, len(coords))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(cfg.Concurrency)
+	start := time.Now()
+	for w := 0; w < cfg.Concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				coord := coords[i]
+				begin := time.Now()
+				_, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y)
+				latencies[i] = time.Since(begin)
+				missing[i] = err == mbtiles.ErrTileNotFound
+				if !missing[i] {
+					errs[i] = err
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range coords {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Requests: len(coords), Duration: elapsed}
+	for i := range coords {
+		if errs[i] != nil {
+			result.Errors++
+		}
+		if missing[i] {
+			result.Missing++
+		}
+	}
+	if elapsed > 0 {
+		result.Throughput = float64(len(coords)) / elapsed.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50 = percentile(latencies, 0.50)
+	result.P99 = percentile(latencies, 0.99)
+	result.Max = latencies[len(latencies)-1]
+	return result, nil
+}
+
+// sequence returns the tiles (TMS scheme) to read, determined by cfg.Seed.
+func sequence(cfg Config, bounds []float64) []mbtiles.TileCoord {
+	random := rand.New(rand.NewSource(cfg.Seed))
+
+	// tiles within bounds at each zoom level, nearest to the center first so
+	// that the Zipfian distribution favors them
+	levels := make([][]mbtiles.TileCoord, 0, cfg.MaxZoom-cfg.MinZoom+1)
+	for z := int64(cfg.MinZoom); z <= int64(cfg.MaxZoom); z++ {
+		levels = append(levels, tilesByDistance(bounds, z))
+	}
+
+	var zipfs []*rand.Zipf
+	if cfg.Distribution == Zipfian {
+		for _, tiles := range levels {
+			zipfs = append(zipfs, rand.NewZipf(random, 1.2, 1, uint64(len(tiles)-1)))
+		}
+	}
+
+	coords := make([]mbtiles.TileCoord, cfg.Requests)
+	for i := range coords {
+		level := random.Intn(len(levels))
+		tiles := levels[level]
+		var index int
+		if zipfs != nil {
+			index = int(zipfs[level].Uint64())
+		} else {
+			index = random.Intn(len(tiles))
+		}
+		coords[i] = tiles[index].FlipY()
+	}
+	return coords
+}
+
+// tilesByDistance returns the XYZ tiles at zoom level z within bounds, in order
+// of distance from the center of bounds.
+func tilesByDistance(bounds []float64, z int64) []mbtiles.TileCoord {
+	topLeft := mbtiles.TileCoordFromLonLat(bounds[0], bounds[3], z)
+	bottomRight := mbtiles.TileCoordFromLonLat(bounds[2], bounds[1], z)
+	center := mbtiles.TileCoordFromLonLat((bounds[0]+bounds[2])/2, (bounds[1]+bounds[3])/2, z)
+
+	var tiles []mbtiles.TileCoord
+	for x := topLeft.X; x <= bottomRight.X; x++ {
+		for y := topLeft.Y; y <= bottomRight.Y; y++ {
+			tiles = append(tiles, mbtiles.TileCoord{Z: z, X: x, Y: y})
+		}
+	}
+	distance := func(c mbtiles.TileCoord) int64 {
+		dx, dy := abs(c.X-center.X), abs(c.Y-center.Y)
+		if dx > dy {
+			return dx
+		}
+		return dy
+	}
+	sort.SliceStable(tiles, func(i, j int) bool { return distance(tiles[i]) < distance(tiles[j]) })
+	return tiles
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
@@ -0,0 +1,53 @@
+package bench
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	mbtiles "github.com/brendan-ward/mbtiles-go"
+)
+
+func Test_Run(t *testing.T) {
+	db, err := mbtiles.Open("../testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, distribution := range []Distribution{Uniform, Zipfian} {
+		result, err := Run(context.Background(), db, Config{MinZoom: 0, MaxZoom: 6, Requests: 200, Concurrency: 4, Distribution: distribution})
+		if err != nil {
+			t.Error("Unexpected error running benchmark:", err)
+			continue
+		}
+		if result.Requests != 200 || result.Errors != 0 {
+			t.Error("Benchmark result does not match expected values, got:", result)
+		}
+		if result.Missing == result.Requests {
+			t.Error("Expected some tiles to exist, got:", result)
+		}
+		if result.P50 > result.P99 || result.P99 > result.Max || result.Throughput <= 0 {
+			t.Error("Invalid latency statistics, got:", result)
+		}
+	}
+
+	if _, err := Run(context.Background(), db, Config{MinZoom: 3, MaxZoom: 2, Requests: 1}); err == nil {
+		t.Error("Expected error for invalid zoom range")
+	}
+}
+
+func Test_sequence(t *testing.T) {
+	cfg := Config{MinZoom: 2, MaxZoom: 4, Requests: 50, Distribution: Zipfian, Seed: 42}
+	bounds := []float64{-180, -85, 180, 85}
+
+	a, b := sequence(cfg, bounds), sequence(cfg, bounds)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("Expected the same sequence for the same seed")
+	}
+	for _, coord := range a {
+		if coord.Z < 2 || coord.Z > 4 || coord.Validate() != nil {
+			t.Error("Invalid tile in sequence:", coord)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// defaultTileChunkSize is the number of bytes read by each query of a
+// TileReader when reading sequentially.
+const defaultTileChunkSize = 1 << 20
+
+// TileReader reads the data of a tile in chunks, so that very large tiles,
+// such as high resolution satellite images, are not held in memory.  It
+// implements io.ReadSeeker and io.ReaderAt, so it can be served with
+// http.ServeContent, which supports HTTP range requests.
+//
+// Each chunk is read with a separate query, so the data may be inconsistent
+// if the tile is replaced while it is read; ErrTileNotFound is returned if
+// the tile is deleted.
+type TileReader struct {
+	db      *MBtiles
+	ctx     context.Context
+	z, x, y int64
+	size    int64
+	offset  int64
+	data    []byte // data of tiles read in full, such as external blobs
+}
+
+// OpenTile opens the tile for z, x, y (TMS scheme) for reading in chunks.
+// ErrTileNotFound is returned if the tile does not exist.  Tiles are read
+// from the mbtiles file, bypassing the tile cache and fallback tiles.  ctx is
+// used for all reads by the TileReader.
+func (db *MBtiles) OpenTile(ctx context.Context, z int64, x int64, y int64) (*TileReader, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read tile from closed mbtiles database")
+	}
+	tile, err := db.openTile(ctx, z, x, y)
+	var size int64
+	if tile != nil {
+		size = tile.size
+	}
+	db.counters.read(size, err)
+	return tile, err
+}
+
+// openTile opens the tile for z, x, y (TMS scheme) as described for OpenTile.
+func (db *MBtiles) openTile(ctx context.Context, z int64, x int64, y int64) (*TileReader, error) {
+	if err := db.init(ctx); err != nil {
+		return nil, err
+	}
+
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if !db.covers(coord) {
+		return nil, ErrTileNotFound
+	}
+	missing, generation := db.misses.contains(coord)
+	if missing || db.indexedMissing(coord) {
+		return nil, ErrTileNotFound
+	}
+
+	var size int64
+	err := db.limiter.do(ctx, func() error {
+		return db.traced(db.pool).QueryRowContext(ctx, "select length(tile_data) from tiles where zoom_level = ? and tile_column = ? and tile_row = ?", z, x, y).Scan(&size)
+	})
+	if err == sql.ErrNoRows {
+		db.misses.add(coord, generation)
+		return nil, ErrTileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size == int64(blobRefSize) {
+		// the tile may be a reference to an external blob, which is read in
+		// full
+		var data []byte
+		err := db.queryTile(ctx, z, x, y, &data)
+		if err == sql.ErrNoRows {
+			return nil, ErrTileNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &TileReader{db: db, ctx: ctx, z: z, x: x, y: y, size: int64(len(data)), data: data}, nil
+	}
+	return &TileReader{db: db, ctx: ctx, z: z, x: x, y: y, size: size}, nil
+}
+
+// Size returns the size of the tile data in bytes.
+func (r *TileReader) Size() int64 {
+	return r.size
+}
+
+// Read reads up to len(p) bytes of the tile, and at most 1 MiB per call, or
+// 64 KiB for handles opened with LowMemory.
+func (r *TileReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if size := r.db.chunkSize(); len(p) > size {
+		p = p[:size]
+	}
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt reads len(p) bytes of the tile starting at offset off with a single
+// query.
+func (r *TileReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if r.data != nil {
+		n := copy(p, r.data[off:])
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+	length := int64(len(p))
+	if remaining := r.size - off; length > remaining {
+		length = remaining
+	}
+
+	var chunk []byte
+	// substr offsets of blobs start at 1
+	err := r.db.traced(r.db.pool).QueryRowContext(r.ctx, "select substr(tile_data, ?, ?) from tiles where zoom_level = ? and tile_column = ? and tile_row = ?", off+1, length, r.z, r.x, r.y).Scan(&chunk)
+	if err == sql.ErrNoRows {
+		return 0, ErrTileNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, chunk)
+	if int64(n) < length {
+		// the tile was replaced by a smaller tile
+		return n, io.ErrUnexpectedEOF
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek sets the offset of the next Read, as described for io.Seeker.
+func (r *TileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// ServeTile writes the tile for z, x, y (TMS scheme) as the response to r,
+// reading it in chunks with a TileReader, and supports HTTP range requests so
+// that clients can read parts of large tiles such as terrain meshes.
+// Conditional requests are handled using the Last-Modified time of the
+// mbtiles file, which is read again for each request (see RefreshTimestamp) so
+// that files replaced in place are not reported unmodified, and its ETag if
+// GetTileETag supports its schema.
+//
+// The Content-Type is that of the tile format; gzip-compressed vector tiles
+// are served with Content-Encoding gzip, and ranges refer to the compressed
+// data.  ErrTileNotFound is returned without writing a response if the tile
+// does not exist, so that handlers can respond according to their
+// MissingTilePolicy.  ErrZoomNotServed is returned, also without writing a
+// response, for zoom levels outside the limit set with ServedZooms.
+func (db *MBtiles) ServeTile(w http.ResponseWriter, r *http.Request, z int64, x int64, y int64) error {
+	if limit, ok := db.ServedZooms(); ok && !limit.Allows(z) {
+		return ErrZoomNotServed
+	}
+	modified, err := db.RefreshTimestamp()
+	if err != nil {
+		modified = db.GetTimestamp()
+	}
+	tile, err := db.OpenTile(r.Context(), z, x, y)
+	if err != nil {
+		return err
+	}
+
+	header := w.Header()
+	format := db.GetTileFormat()
+	header.Set("Content-Type", format.MimeType())
+	if format == PBF {
+		magic := make([]byte, 2)
+		if n, _ := tile.ReadAt(magic, 0); n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	if etag, err := db.GetTileETag(r.Context(), z, x, y); err == nil && etag != "" {
+		header.Set("ETag", etag)
+	}
+	http.ServeContent(w, r, "", modified, tile)
+	return nil
+}
@@ -0,0 +1,164 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_OpenTile(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	expected, err := db.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := db.OpenTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Could not open tile:", err)
+	}
+	if r.Size() != int64(len(expected)) {
+		t.Error("Size does not match expected value, got:", r.Size())
+	}
+
+	// read in small chunks
+	var data []byte
+	buf := make([]byte, 1000)
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Could not read tile:", err)
+		}
+	}
+	if !bytes.Equal(data, expected) {
+		t.Error("Tile data does not match expected value")
+	}
+
+	chunk := make([]byte, 16)
+	if n, err := r.ReadAt(chunk, 100); err != nil || n != 16 || !bytes.Equal(chunk, expected[100:116]) {
+		t.Error("ReadAt does not match expected value, got:", n, err)
+	}
+	if n, err := r.ReadAt(chunk, r.Size()-10); err != io.EOF || n != 10 || !bytes.Equal(chunk[:n], expected[len(expected)-10:]) {
+		t.Error("Expected partial read with io.EOF at end of tile, got:", n, err)
+	}
+
+	if _, err := r.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(rest, expected[len(expected)-4:]) {
+		t.Error("Read after Seek does not match expected value, got:", rest, err)
+	}
+
+	if _, err := db.OpenTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+}
+
+func Test_ServeTile(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expected, err := db.GetTile(context.Background(), 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/0/0/0.png", nil)
+	r.Header.Set("Range", "bytes=100-199")
+	w := httptest.NewRecorder()
+	if err := db.ServeTile(w, r, 0, 0, 0); err != nil {
+		t.Fatal("Could not serve tile:", err)
+	}
+	if w.Code != http.StatusPartialContent {
+		t.Error("Expected 206 Partial Content, got:", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), expected[100:200]) {
+		t.Error("Range does not match expected value")
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "image/png" {
+		t.Error("Content-Type does not match expected value, got:", contentType)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/0/0/0.png", nil)
+	w = httptest.NewRecorder()
+	if err := db.ServeTile(w, r, 0, 0, 0); err != nil || w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), expected) {
+		t.Error("Expected whole tile, got:", w.Code, err)
+	}
+
+	w = httptest.NewRecorder()
+	if err := db.ServeTile(w, r, 10, 0, 0); err != ErrTileNotFound || w.Body.Len() != 0 {
+		t.Error("Expected ErrTileNotFound without response for missing tile, got:", err)
+	}
+
+	vector, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vector.Close()
+	w = httptest.NewRecorder()
+	if err := vector.ServeTile(w, httptest.NewRequest(http.MethodGet, "/0/0/0.pbf", nil), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Error("Expected gzip Content-Encoding for vector tile, got:", encoding)
+	}
+}
+
+func Test_ServeTile_replaced(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "/0/0/0.png", nil)
+	r.Header.Set("If-Modified-Since", db.GetTimestamp().UTC().Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	if err := db.ServeTile(w, r, 0, 0, 0); err != nil || w.Code != http.StatusNotModified {
+		t.Fatal("Expected 304 Not Modified for unmodified file, got:", w.Code, err)
+	}
+
+	// replace the file with a newer copy
+	replacement := filepath.Join(filepath.Dir(filename), "replacement.mbtiles")
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(replacement, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	modified := db.GetTimestamp().Add(time.Hour)
+	if err := os.Chtimes(replacement, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	if err := db.ServeTile(w, r, 0, 0, 0); err != nil || w.Code != http.StatusOK {
+		t.Error("Expected 200 OK for replaced file, got:", w.Code, err)
+	}
+	if lastModified := w.Header().Get("Last-Modified"); lastModified != modified.UTC().Format(http.TimeFormat) {
+		t.Error("Last-Modified does not match time of replaced file, got:", lastModified)
+	}
+}
@@ -0,0 +1,277 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Get if no blob has the key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores tile data outside of the mbtiles file, by content
+// address: the key of each blob is the hex-encoded SHA-256 hash of its data.
+// Implementations for object stores, such as S3 buckets, use the key as the
+// object key; DirBlobStore stores blobs as files.  Implementations must be
+// safe for concurrent use.
+type BlobStore interface {
+	// Get returns the data of the blob with key, or ErrBlobNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores data as the blob with key.  As blobs are content-addressed,
+	// Put may skip blobs that already exist.
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// ExternalBlobs stores the data of tiles larger than threshold bytes in
+// store, and a reference to the blob in the mbtiles file, so that tilesets
+// with large tiles, such as high resolution imagery, keep a small SQLite
+// file.  Tiles written by the handle, such as by AncestorFallback with write
+// back and by ExternalizeTiles, are stored in store if they are larger than
+// threshold.  Reads of tiles by GetTile, ReadTile, OpenTile, and ServeTile
+// read referenced blobs from store, so external tiles are read as any other.
+//
+// Tools other than this package read the references instead of the tiles,
+// so files with external tiles must only be served with this option.  Blobs
+// are not deleted from store when their tiles are deleted or replaced, as
+// other tiles or files may reference them.
+func ExternalBlobs(store BlobStore, threshold int64) OpenOption {
+	return func(o *openOptions) {
+		o.blobStore = store
+		o.blobThreshold = threshold
+	}
+}
+
+// blobRefPrefix starts the tile data of tiles stored in a BlobStore, followed
+// by the key of the blob.
+const blobRefPrefix = "mbtiles-blob:sha256:"
+
+// blobRefSize is the size of the references to blobs.
+const blobRefSize = len(blobRefPrefix) + 2*sha256.Size
+
+// blobKey returns the key of the blob referenced by data, or false if data is
+// not a reference to a blob.
+func blobKey(data []byte) (string, bool) {
+	if len(data) != blobRefSize || !bytes.HasPrefix(data, []byte(blobRefPrefix)) {
+		return "", false
+	}
+	key := string(data[len(blobRefPrefix):])
+	if !isBlobKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// isBlobKey returns true if key is a lowercase hex-encoded SHA-256 hash.
+func isBlobKey(key string) bool {
+	if len(key) != 2*sha256.Size {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// externalize stores data in the BlobStore of the handle and returns the
+// reference to the blob, if the handle was opened with ExternalBlobs and data
+// is larger than its threshold.  Otherwise, data is returned.
+func (db *MBtiles) externalize(ctx context.Context, data []byte) ([]byte, error) {
+	store := db.options.blobStore
+	if store == nil || int64(len(data)) <= db.options.blobThreshold {
+		return data, nil
+	}
+	if _, ok := blobKey(data); ok {
+		return data, nil
+	}
+	hash := sha256.Sum256(data)
+	key := hex.EncodeToString(hash[:])
+	if err := store.Put(ctx, key, data); err != nil {
+		return nil, fmt.Errorf("could not store blob %s: %w", key, err)
+	}
+	return []byte(blobRefPrefix + key), nil
+}
+
+// resolveBlob replaces *data with the blob it references in the BlobStore of
+// the handle, if it is a reference to a blob.
+func (db *MBtiles) resolveBlob(ctx context.Context, data *[]byte) error {
+	return resolveBlob(ctx, db.options.blobStore, data)
+}
+
+// resolveBlob replaces *data with the blob it references in store, if it is a
+// reference to a blob.  store may be nil if the handle was not opened with
+// ExternalBlobs.
+func resolveBlob(ctx context.Context, store BlobStore, data *[]byte) error {
+	key, ok := blobKey(*data)
+	if !ok {
+		return nil
+	}
+	if store == nil {
+		return fmt.Errorf("tile is stored in external blob %s; open with ExternalBlobs to read it", key)
+	}
+	blob, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("could not read blob %s: %w", key, err)
+	}
+	*data = blob
+	return nil
+}
+
+// ExternalizeTiles moves the data of existing tiles larger than the
+// threshold of ExternalBlobs to its BlobStore, and returns the number of
+// tiles moved.  The file itself does not shrink unless it is vacuumed, such
+// as by a Snapshot.
+func (db *MBtiles) ExternalizeTiles(ctx context.Context) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot externalize tiles of closed mbtiles database")
+	}
+	if db.options.blobStore == nil {
+		return 0, errors.New("externalizing tiles requires the ExternalBlobs option")
+	}
+
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	q := db.traced(tx)
+	schema, err := db.writeSchema(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	threshold := db.options.blobThreshold
+	if threshold < int64(blobRefSize) {
+		// references to blobs are never moved again
+		threshold = int64(blobRefSize)
+	}
+	rows, err := q.QueryContext(ctx, "select zoom_level, tile_column, tile_row from tiles where length(tile_data) > ?", threshold)
+	if err != nil {
+		return 0, err
+	}
+	var coords []TileCoord
+	for rows.Next() {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		coords = append(coords, coord)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	for _, coord := range coords {
+		var data []byte
+		err := q.QueryRowContext(ctx, tileQuery, coord.Z, coord.X, coord.Y).Scan(&data)
+		if err != nil {
+			return 0, err
+		}
+		stored, err := db.externalize(ctx, data)
+		if err != nil {
+			return 0, err
+		}
+		if err := writeTileTx(ctx, q, schema, coord.Z, coord.X, coord.Y, data, stored); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(coords) > 0 {
+		if schema.deduplicated {
+			if err := deleteUnreferencedImages(ctx, q); err != nil {
+				return 0, err
+			}
+		}
+		if err := db.bumpVersion(ctx, q); err != nil {
+			return 0, err
+		}
+		if err := db.syncMetadata(ctx, q, schema); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	db.tilesChanged()
+	return int64(len(coords)), nil
+}
+
+// DirBlobStore is a BlobStore of files in a directory.  Each blob is stored
+// in a subdirectory named by the first 2 characters of its key, so that
+// directories do not hold too many files.
+type DirBlobStore struct {
+	dir string
+}
+
+// NewDirBlobStore creates a BlobStore of files in dir, which is created if it
+// does not exist.
+func NewDirBlobStore(dir string) (*DirBlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirBlobStore{dir: dir}, nil
+}
+
+// path returns the path of the file of the blob with key.
+func (s *DirBlobStore) path(key string) (string, error) {
+	if !isBlobKey(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+// Get returns the data of the blob with key, or ErrBlobNotFound.
+func (s *DirBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+// Put stores data as the blob with key, unless the blob already exists.
+// Blobs are written to a temporary file that is renamed once complete, so
+// that readers never read partial blobs.
+func (s *DirBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
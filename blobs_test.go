@@ -0,0 +1,205 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ExternalBlobs(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+
+	original, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := original.GetTile(ctx, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original.Close()
+
+	store, err := NewDirBlobStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(filename, ExternalBlobs(store, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	moved, err := db.ExternalizeTiles(ctx)
+	if err != nil || moved == 0 {
+		t.Fatal("Expected tiles to be moved, got:", moved, err)
+	}
+	if moved, err := db.ExternalizeTiles(ctx); err != nil || moved != 0 {
+		t.Error("Expected moved tiles to not be moved again, got:", moved, err)
+	}
+
+	var stored []byte
+	if err := db.pool.QueryRowContext(ctx, tileQuery, 1, 1, 0).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blobKey(stored); !ok {
+		t.Fatal("Expected tile to be a reference to a blob, got:", len(stored))
+	}
+
+	data, err := db.GetTile(ctx, 1, 1, 0)
+	if err != nil || !bytes.Equal(data, expected) {
+		t.Error("Tile does not match original tile:", len(data), err)
+	}
+	tile, err := db.OpenTile(ctx, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(tile); err != nil || !bytes.Equal(data, expected) || tile.Size() != int64(len(expected)) {
+		t.Error("Tile read in chunks does not match original tile:", len(data), err)
+	}
+
+	// hashes are of the tile data, not the reference
+	if hash, err := db.ReadTileHash(ctx, 1, 1, 0); err != nil || hash != hashTile(expected) {
+		t.Error("Tile hash does not match hash of original tile, got:", hash, err)
+	}
+	if hashes, err := db.ReadTileHashes(ctx, 1); err != nil || hashes[TileCoord{Z: 1, X: 1, Y: 0}] != hashTile(expected) {
+		t.Error("Tile hashes do not match hash of original tile, got:", hashes, err)
+	}
+
+	// external ancestor tiles are read to synthesize tiles, and synthesized
+	// tiles larger than the threshold are stored externally
+	filled, err := db.FillFromAncestors(ctx, 2)
+	if err != nil || filled != 16 {
+		t.Fatal("Expected tiles to be filled from external ancestors, got:", filled, err)
+	}
+	if err := db.pool.QueryRowContext(ctx, tileQuery, 2, 2, 0).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blobKey(stored); !ok {
+		t.Error("Expected synthesized tile to be a reference to a blob, got:", len(stored))
+	}
+	if data, err := db.GetTile(ctx, 2, 2, 0); err != nil || data[0] != 0x89 {
+		t.Error("Expected synthesized PNG tile, got:", len(data), err)
+	}
+
+	// handles without a store cannot read external tiles
+	if plain, err := Open(filename); err == nil {
+		_, err = plain.GetTile(ctx, 1, 1, 0)
+		plain.Close()
+		if err == nil {
+			t.Error("Expected error reading external tile without a store")
+		}
+	}
+}
+
+func Test_DirBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewDirBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	if _, err := store.Get(ctx, key); err != ErrBlobNotFound {
+		t.Error("Expected ErrBlobNotFound, got:", err)
+	}
+	if err := store.Put(ctx, key, []byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Get(ctx, key); err != nil || string(data) != "foo" {
+		t.Error("Blob does not match expected value, got:", string(data), err)
+	}
+	if _, err := store.Get(ctx, "../../etc/passwd"); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+}
+
+func Test_ExternalBlobs_tile_hash(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+
+	store, err := NewDirBlobStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(filename, ExternalBlobs(store, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.pool.Exec("alter table map add column tile_hash text"); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored hashes are of the tile data, not the reference
+	storedHash := func(coord TileCoord) string {
+		var hash string
+		err := db.pool.QueryRowContext(ctx, "select tile_hash from map where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y).Scan(&hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	if _, err := db.ExternalizeTiles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FillFromAncestors(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, coord := range []TileCoord{{Z: 1, X: 1, Y: 0}, {Z: 2, X: 2, Y: 0}} {
+		var stored []byte
+		if err := db.pool.QueryRowContext(ctx, tileQuery, coord.Z, coord.X, coord.Y).Scan(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := blobKey(stored); !ok {
+			t.Error("Expected tile to be a reference to a blob:", coord)
+		}
+		data, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash := storedHash(coord); hash != hashTile(data) {
+			t.Error("Stored hash does not match hash of tile:", coord, hash)
+		}
+	}
+}
+
+func Test_ExternalBlobs_Prefetch(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+
+	store, err := NewDirBlobStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(filename, ExternalBlobs(store, 100), CacheTiles(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	expected, err := db.GetTile(ctx, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := db.ExternalizeTiles(ctx); err != nil || moved == 0 {
+		t.Fatal("Expected tiles to be moved, got:", moved, err)
+	}
+
+	// prefetched tiles are read from the store, not cached as references
+	if _, err := db.Prefetch(ctx, TileCoord{Z: 1, X: 0, Y: 0}, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := db.GetTile(ctx, 1, 0, 0); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Prefetched tile does not match original tile:", len(data), err)
+	}
+	tiles, err := db.ReadTileRow(ctx, 1, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tiles[0], expected) {
+		t.Error("Tile read in row does not match original tile:", len(tiles[0]))
+	}
+}
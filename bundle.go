@@ -0,0 +1,243 @@
+package mbtiles
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Names of the files in style bundles.
+const (
+	bundleTilesName = "tiles.mbtiles"
+	bundleStyleName = "style.json"
+	bundleGlyphsDir = "glyphs/"
+	bundleSpriteDir = "sprites/"
+)
+
+// StyleBundle holds the files other than tiles that MapLibre needs to display
+// a vector tileset offline, for bundling with the tileset by WriteBundle.
+type StyleBundle struct {
+	Style []byte // style.json; omitted if nil
+	// Glyphs are the glyph ranges of font stacks by their paths in the
+	// MapLibre glyphs URL, as {fontstack}/{range}.pbf, for example
+	// Noto Sans Regular/0-255.pbf.
+	Glyphs map[string][]byte
+	// Sprites are the sprite files by name, for example sprite.json,
+	// sprite.png, sprite@2x.json, and sprite@2x.png.
+	Sprites map[string][]byte
+}
+
+// WriteBundle writes a zip archive to a new file at dst, which must not
+// already exist, that bundles a snapshot of the mbtiles file with the style,
+// glyphs, and sprites of bundle, for fully offline MapLibre deployments.  The
+// bundle can be opened with OpenBundle.
+//
+// The mbtiles file is stored without compression, so that it can be
+// extracted quickly; other files are compressed.  dst is removed if writing
+// the bundle fails.
+func (db *MBtiles) WriteBundle(ctx context.Context, dst string, bundle StyleBundle) error {
+	if db.isClosed() {
+		return errors.New("cannot bundle closed mbtiles database")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %q", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, files := range []map[string][]byte{bundle.Glyphs, bundle.Sprites} {
+		for name := range files {
+			if !fs.ValidPath(name) || name == "." {
+				return fmt.Errorf("invalid file name in style bundle: %q", name)
+			}
+		}
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(dst), ".bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, bundleTilesName)
+	if err := db.Snapshot(ctx, snapshot); err != nil {
+		return err
+	}
+
+	return publishFile(dst, func(tmp string) error {
+		return writeBundle(tmp, snapshot, bundle)
+	})
+}
+
+// writeBundle writes the zip archive of the mbtiles file at tilesPath and
+// bundle to path.
+func writeBundle(path string, tilesPath string, bundle StyleBundle) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	tiles, err := os.Open(tilesPath)
+	if err != nil {
+		return err
+	}
+	defer tiles.Close()
+	entry, err := w.CreateHeader(&zip.FileHeader{Name: bundleTilesName, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, tiles); err != nil {
+		return err
+	}
+
+	if bundle.Style != nil {
+		if err := writeBundleFile(w, bundleStyleName, bundle.Style); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []struct {
+		prefix string
+		files  map[string][]byte
+	}{{bundleGlyphsDir, bundle.Glyphs}, {bundleSpriteDir, bundle.Sprites}} {
+		names := make([]string, 0, len(dir.files))
+		for name := range dir.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := writeBundleFile(w, dir.prefix+name, dir.files[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeBundleFile writes a compressed file to the zip archive.
+func writeBundleFile(w *zip.Writer, name string, data []byte) error {
+	entry, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = entry.Write(data)
+	return err
+}
+
+// Bundle is a style bundle written by WriteBundle, opened for serving its
+// tiles, style, glyphs, and sprites.
+type Bundle struct {
+	Tiles *MBtiles // the bundled mbtiles file, extracted from the archive
+
+	archive   *zip.ReadCloser
+	tilesPath string
+	removeDir string
+}
+
+// OpenBundle opens the style bundle at path.  The bundled mbtiles file is
+// extracted to dir, replacing any previous extraction, and opened with
+// options; if dir is empty, it is extracted to a temporary directory that is
+// removed by Close.  The style, glyphs, and sprites are read from the
+// archive as requested.
+func OpenBundle(path string, dir string, options ...OpenOption) (*Bundle, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{archive: archive}
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "mbtiles-bundle-"); err != nil {
+			archive.Close()
+			return nil, err
+		}
+		b.removeDir = dir
+	}
+	b.tilesPath = filepath.Join(dir, bundleTilesName)
+
+	if err := b.extractTiles(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if b.Tiles, err = Open(b.tilesPath, options...); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// extractTiles extracts the bundled mbtiles file to its path.
+func (b *Bundle) extractTiles() error {
+	src, err := b.archive.Open(bundleTilesName)
+	if err != nil {
+		return fmt.Errorf("style bundle does not contain tiles: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(b.tilesPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+	return dst.Close()
+}
+
+// Style returns the bundled style.json.  An error wrapping fs.ErrNotExist is
+// returned if the bundle has no style.
+func (b *Bundle) Style() ([]byte, error) {
+	return b.readFile("", bundleStyleName)
+}
+
+// Glyphs returns the bundled glyph range of fontstack, such as 0-255.  An
+// error wrapping fs.ErrNotExist is returned if the range is not bundled.
+func (b *Bundle) Glyphs(fontstack string, glyphRange string) ([]byte, error) {
+	return b.readFile(bundleGlyphsDir, path.Join(fontstack, glyphRange+".pbf"))
+}
+
+// Sprite returns the bundled sprite file name, such as sprite.json or
+// sprite@2x.png.  An error wrapping fs.ErrNotExist is returned if the file is
+// not bundled.
+func (b *Bundle) Sprite(name string) ([]byte, error) {
+	return b.readFile(bundleSpriteDir, name)
+}
+
+// readFile reads the named file in the dir prefix of the archive; names
+// outside of dir are not found.
+func (b *Bundle) readFile(dir string, name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := b.archive.Open(dir + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Close closes the bundled mbtiles file and the archive, and removes the
+// temporary directory of the extracted mbtiles file, if any.
+func (b *Bundle) Close() error {
+	if b.Tiles != nil {
+		b.Tiles.Close()
+	}
+	err := b.archive.Close()
+	if b.removeDir != "" {
+		if removeErr := os.RemoveAll(b.removeDir); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
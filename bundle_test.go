@@ -0,0 +1,82 @@
+package mbtiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WriteBundle(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	style := []byte(`{"version": 8}`)
+	glyphs := []byte("glyphs")
+	sprite := []byte("sprite")
+	dst := filepath.Join(t.TempDir(), "bundle.zip")
+	bundle := StyleBundle{
+		Style:   style,
+		Glyphs:  map[string][]byte{"Noto Sans Regular/0-255.pbf": glyphs},
+		Sprites: map[string][]byte{"sprite.png": sprite},
+	}
+	if err := db.WriteBundle(ctx, dst, bundle); err != nil {
+		t.Fatal("Could not write bundle:", err)
+	}
+	if err := db.WriteBundle(ctx, dst, bundle); err == nil {
+		t.Error("Expected error for existing destination")
+	}
+	invalid := StyleBundle{Sprites: map[string][]byte{"../sprite.png": sprite}}
+	if err := db.WriteBundle(ctx, filepath.Join(t.TempDir(), "invalid.zip"), invalid); err == nil {
+		t.Error("Expected error for invalid file name")
+	}
+
+	archive, err := zip.OpenReader(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := archive.File[0]; f.Name != bundleTilesName || f.Method != zip.Store {
+		t.Error("Expected uncompressed tiles first in the bundle, got:", f.Name, f.Method)
+	}
+	archive.Close()
+
+	b, err := OpenBundle(dst, "")
+	if err != nil {
+		t.Fatal("Could not open bundle:", err)
+	}
+	var data []byte
+	if err := b.Tiles.ReadTile(0, 0, 0, &data); err != nil || len(data) == 0 {
+		t.Error("Could not read bundled tile:", err)
+	}
+	if got, err := b.Style(); err != nil || !bytes.Equal(got, style) {
+		t.Error("Bundled style does not match expected value, got:", string(got), err)
+	}
+	if got, err := b.Glyphs("Noto Sans Regular", "0-255"); err != nil || !bytes.Equal(got, glyphs) {
+		t.Error("Bundled glyphs do not match expected value, got:", string(got), err)
+	}
+	if got, err := b.Sprite("sprite.png"); err != nil || !bytes.Equal(got, sprite) {
+		t.Error("Bundled sprite does not match expected value, got:", string(got), err)
+	}
+	if _, err := b.Glyphs("Noto Sans Regular", "256-511"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("Expected fs.ErrNotExist for missing glyphs, got:", err)
+	}
+	if _, err := b.Sprite("../" + bundleTilesName); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("Expected fs.ErrNotExist for file outside sprites, got:", err)
+	}
+
+	dir := filepath.Dir(b.tilesPath)
+	if err := b.Close(); err != nil {
+		t.Error("Could not close bundle:", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected temporary directory to be removed, got:", err)
+	}
+}
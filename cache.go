@@ -0,0 +1,364 @@
+package mbtiles
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CacheTiles caches up to size of the most recently read tiles in memory, so
+// that they are not read from the mbtiles file again.  Cached tiles are shared
+// between calls to GetTile, and must not be modified.  The cache is cleared
+// when tiles are written or deleted using this handle, or when
+// RefreshTimestamp detects that the file was modified.
+func CacheTiles(size int) OpenOption {
+	return func(o *openOptions) {
+		o.cacheSize = size
+	}
+}
+
+// tileCache is a least recently used cache of tiles.  A nil *tileCache caches
+// nothing.
+//
+// Tiles are only added if the cache has not been purged since the read of the
+// tile began, as indicated by the generation, so that tiles read before a
+// concurrent write are not cached after it.
+type tileCache struct {
+	mu         sync.Mutex
+	size       int
+	generation uint64
+	order      *list.List // of *cacheEntry, most recently used first
+	items      map[TileCoord]*list.Element
+}
+
+type cacheEntry struct {
+	coord TileCoord
+	data  []byte
+}
+
+// newTileCache creates a cache of up to size tiles, or returns nil if size is
+// not positive.
+func newTileCache(size int) *tileCache {
+	if size <= 0 {
+		return nil
+	}
+	return &tileCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[TileCoord]*list.Element, size),
+	}
+}
+
+// get returns the cached tile at coord, if any.
+func (c *tileCache) get(coord TileCoord) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[coord]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).data, true
+}
+
+// current returns the current generation of the cache, to be passed to add
+// for tiles read after it is called.
+func (c *tileCache) current() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// add caches the tile at coord, as read by a read that began at generation,
+// evicting the least recently used tile if the cache is full.
+func (c *tileCache) add(coord TileCoord, data []byte, generation uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if elem, ok := c.items[coord]; ok {
+		elem.Value.(*cacheEntry).data = data
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[coord] = c.order.PushFront(&cacheEntry{coord: coord, data: data})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).coord)
+	}
+}
+
+// purge removes all tiles from the cache.
+func (c *tileCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	c.items = make(map[TileCoord]*list.Element, c.size)
+}
+
+// Prefetch reads tiles around center (TMS scheme) into the tile cache enabled
+// by CacheTiles, to reduce latency when a viewer pans or zooms.  At the zoom
+// level of center and up to zoomSpread levels above and below it, the tile
+// that contains the center of center is read, along with all neighboring tiles
+// within radius tiles of it.  Tiles that GetTile would not return, because they
+// are outside the coverage of RejectOutsideCoverage or larger than
+// MaxTileSize, are not cached.  Returns the number of tiles cached.
+func (db *MBtiles) Prefetch(ctx context.Context, center TileCoord, radius int, zoomSpread int) (int, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot prefetch tiles from closed mbtiles database")
+	}
+	if db.cache == nil {
+		return 0, errors.New("cannot prefetch tiles without a tile cache")
+	}
+	if err := center.Validate(); err != nil {
+		return 0, err
+	}
+	if radius < 0 || zoomSpread < 0 {
+		return 0, errors.New("radius and zoom spread must not be negative")
+	}
+
+	var cached int
+	for z := center.Z - int64(zoomSpread); z <= center.Z+int64(zoomSpread); z++ {
+		if z < 0 || z > MaxZoomLevel {
+			continue
+		}
+		x, y := center.X, center.Y
+		if z < center.Z {
+			x, y = x>>(center.Z-z), y>>(center.Z-z)
+		} else if z > center.Z {
+			// the child tile that contains the center of center
+			shift := z - center.Z
+			half := int64(1) << shift >> 1
+			x, y = x<<shift+half, y<<shift+half
+		}
+
+		last := int64(1)<<z - 1
+		xMin, xMax := clampTile(x-int64(radius), last), clampTile(x+int64(radius), last)
+		for row := clampTile(y-int64(radius), last); row <= clampTile(y+int64(radius), last); row++ {
+			if err := ctx.Err(); err != nil {
+				return cached, err
+			}
+			generation := db.cache.current()
+			tiles, err := db.ReadTileRow(ctx, z, row, xMin, xMax)
+			if err != nil {
+				return cached, err
+			}
+			for i, tile := range tiles {
+				coord := TileCoord{Z: z, X: xMin + int64(i), Y: row}
+				if tile == nil || !db.covers(coord) {
+					continue
+				}
+				if limit := db.options.maxTileSize; limit > 0 && int64(len(tile)) > limit {
+					continue
+				}
+				db.cache.add(coord, tile, generation)
+				cached++
+			}
+		}
+	}
+	return cached, nil
+}
+
+// Prewarm reads all tiles at zoom levels within zooms, in the order in which
+// they are stored, so that the pages of the mbtiles file that hold them are in
+// the page cache of the operating system, and the first requests after a
+// deploy or restart are not slowed by reads from disk.  Tiles are not added to
+// the tile cache of CacheTiles.  Returns the number and total size of tiles
+// read per zoom level.  This is usually run in the background after Open, for
+// the low zoom levels that are requested most.
+func (db *MBtiles) Prewarm(ctx context.Context, zooms ZoomLimit) ([]ZoomSize, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot prewarm closed mbtiles database")
+	}
+	if zooms.MinZoom < 0 || zooms.MaxZoom > MaxZoomLevel || zooms.MinZoom > zooms.MaxZoom {
+		return nil, fmt.Errorf("invalid zoom range %d-%d", zooms.MinZoom, zooms.MaxZoom)
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx,
+		"select zoom_level, tile_data from tiles where zoom_level between ? and ? order by zoom_level, tile_column, tile_row",
+		zooms.MinZoom, zooms.MaxZoom)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sizes []ZoomSize
+	for rows.Next() {
+		var (
+			z    int
+			data sql.RawBytes
+		)
+		if err := rows.Scan(&z, &data); err != nil {
+			return sizes, err
+		}
+		if len(sizes) == 0 || sizes[len(sizes)-1].Zoom != z {
+			sizes = append(sizes, ZoomSize{Zoom: z})
+		}
+		sizes[len(sizes)-1].Tiles++
+		sizes[len(sizes)-1].Bytes += int64(len(data))
+	}
+	return sizes, rows.Err()
+}
+
+// clampTile clamps a tile column or row to 0 through last.
+func clampTile(v int64, last int64) int64 {
+	if v < 0 {
+		return 0
+	}
+	if v > last {
+		return last
+	}
+	return v
+}
+
+// CacheMissingTiles remembers up to size of the most recently requested
+// coordinates of tiles that do not exist in the mbtiles file, for up to ttl,
+// so that repeated requests for them, such as from misbehaving clients, return
+// ErrTileNotFound without querying the file.  A ttl of 0 remembers them until
+// they are evicted.  The cache is cleared when tiles are written or deleted
+// using this handle, by Reload, or when RefreshTimestamp detects that the file
+// was modified; tiles written by other processes are found once ttl passes.
+func CacheMissingTiles(size int, ttl time.Duration) OpenOption {
+	return func(o *openOptions) {
+		o.missCacheSize = size
+		o.missCacheTTL = ttl
+	}
+}
+
+// missCache is a least recently used set of tile coordinates known to be
+// missing from a source, so that the source is not queried again for them.  A
+// nil *missCache records nothing.
+//
+// Coordinates are only added if the source has not changed since the query
+// that found them missing began, as indicated by the generation, so that
+// concurrent writes are not hidden.
+type missCache struct {
+	mu         sync.Mutex
+	size       int
+	ttl        time.Duration // 0 if coordinates do not expire
+	generation uint64
+	order      *list.List // of *missEntry, most recently used first
+	items      map[TileCoord]*list.Element
+}
+
+type missEntry struct {
+	coord   TileCoord
+	expires time.Time // zero if the coordinate does not expire
+}
+
+// newMissCache creates a cache of up to size coordinates that expire after
+// ttl, or never if ttl is 0, or returns nil if size is not positive.
+func newMissCache(size int, ttl time.Duration) *missCache {
+	if size <= 0 {
+		return nil
+	}
+	return &missCache{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[TileCoord]*list.Element, size),
+	}
+}
+
+// contains returns true if coord is known to be missing, and the current
+// generation, to be passed to add if it is not.
+func (c *missCache) contains(coord TileCoord) (bool, uint64) {
+	if c == nil {
+		return false, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[coord]
+	if !ok {
+		return false, c.generation
+	}
+	if expires := elem.Value.(*missEntry).expires; !expires.IsZero() && time.Now().After(expires) {
+		c.order.Remove(elem)
+		delete(c.items, coord)
+		return false, c.generation
+	}
+	c.order.MoveToFront(elem)
+	return true, c.generation
+}
+
+// add records that coord is missing, as found by a query that began at
+// generation, evicting the least recently used coordinate if the cache is
+// full.
+func (c *missCache) add(coord TileCoord, generation uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	if elem, ok := c.items[coord]; ok {
+		elem.Value.(*missEntry).expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[coord] = c.order.PushFront(&missEntry{coord: coord, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*missEntry).coord)
+	}
+}
+
+// remove forgets that coord is missing, when it is written to the source.
+func (c *missCache) remove(coord TileCoord) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.items[coord]; ok {
+		c.order.Remove(elem)
+		delete(c.items, coord)
+	}
+}
+
+// purge forgets all missing coordinates, when any tiles of the source may
+// have changed.
+func (c *missCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	c.items = make(map[TileCoord]*list.Element, c.size)
+}
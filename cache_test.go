@@ -0,0 +1,232 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_tileCache(t *testing.T) {
+	cache := newTileCache(2)
+	a, b, c := TileCoord{Z: 1}, TileCoord{Z: 1, X: 1}, TileCoord{Z: 1, Y: 1}
+
+	generation := cache.current()
+	cache.add(a, []byte("a"), generation)
+	cache.add(b, []byte("b"), generation)
+	cache.get(a) // b is now least recently used
+	cache.add(c, []byte("c"), generation)
+
+	if _, ok := cache.get(b); ok {
+		t.Error("Expected least recently used tile to be evicted")
+	}
+	for _, coord := range []TileCoord{a, c} {
+		if _, ok := cache.get(coord); !ok {
+			t.Error("Expected tile to be cached:", coord)
+		}
+	}
+
+	cache.purge()
+	if _, ok := cache.get(a); ok {
+		t.Error("Expected cache to be empty after purge")
+	}
+
+	// tiles read before a purge are not cached after it
+	cache.add(a, []byte("a"), generation)
+	if _, ok := cache.get(a); ok {
+		t.Error("Expected tile read before purge not to be cached")
+	}
+	cache.add(a, []byte("a"), cache.current())
+	if _, ok := cache.get(a); !ok {
+		t.Error("Expected tile read after purge to be cached")
+	}
+
+	// a nil cache caches nothing
+	var empty *tileCache
+	empty.add(a, []byte("a"), empty.current())
+	if _, ok := empty.get(a); ok {
+		t.Error("Expected nil cache to cache nothing")
+	}
+}
+
+func Test_CacheTiles(t *testing.T) {
+	var queries int32
+	db, err := Open("./testdata/world_cities.mbtiles", CacheTiles(100), TraceQueries(func(QueryTrace) { atomic.AddInt32(&queries, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || data == nil {
+		t.Fatal("Could not read tile:", err)
+	}
+	atomic.StoreInt32(&queries, 0)
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || data == nil {
+		t.Fatal("Could not read cached tile:", err)
+	}
+	if queries != 0 {
+		t.Error("Expected cached tile to be read without a query, got queries:", queries)
+	}
+}
+
+func Test_Prefetch(t *testing.T) {
+	var queries int32
+	db, err := Open("./testdata/world_cities.mbtiles", CacheTiles(100), TraceQueries(func(QueryTrace) { atomic.AddInt32(&queries, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cached, err := db.Prefetch(context.Background(), TileCoord{Z: 3, X: 2, Y: 4}, 1, 1)
+	if err != nil {
+		t.Fatal("Unexpected error prefetching tiles:", err)
+	}
+	if cached == 0 {
+		t.Fatal("Expected tiles to be prefetched")
+	}
+
+	// neighboring, parent, and child tiles are read from the cache
+	atomic.StoreInt32(&queries, 0)
+	for _, coord := range []TileCoord{{Z: 3, X: 3, Y: 4}, {Z: 2, X: 1, Y: 2}, {Z: 4, X: 4, Y: 10}} {
+		var data []byte
+		if err := db.ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || data == nil {
+			t.Error("Could not read tile:", coord, err)
+		}
+	}
+	if queries != 0 {
+		t.Error("Expected prefetched tiles to be read without a query, got queries:", queries)
+	}
+
+	uncached, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uncached.Close()
+	if _, err := uncached.Prefetch(context.Background(), TileCoord{Z: 3, X: 2, Y: 4}, 1, 1); err == nil {
+		t.Error("Expected error prefetching without a tile cache")
+	}
+}
+
+func Test_missCache(t *testing.T) {
+	cache := newMissCache(2, 0)
+	a, b, c := TileCoord{Z: 1}, TileCoord{Z: 2}, TileCoord{Z: 3}
+
+	_, generation := cache.contains(a)
+	cache.add(a, generation)
+	cache.add(b, generation)
+	if missing, _ := cache.contains(a); !missing {
+		t.Error("Missing coordinate was not cached")
+	}
+	// b is least recently used
+	cache.add(c, generation)
+	if missing, _ := cache.contains(b); missing {
+		t.Error("Least recently used coordinate was not evicted")
+	}
+
+	// coordinates found missing before a write are not cached
+	_, generation = cache.contains(b)
+	cache.remove(a)
+	cache.add(b, generation)
+	if missing, _ := cache.contains(b); missing {
+		t.Error("Coordinate found missing before a write was cached")
+	}
+	if missing, _ := cache.contains(a); missing {
+		t.Error("Removed coordinate is still cached")
+	}
+
+	cache.purge()
+	if missing, _ := cache.contains(c); missing {
+		t.Error("Expected cache to be empty after purge")
+	}
+
+	expiring := newMissCache(2, time.Millisecond)
+	_, generation = expiring.contains(a)
+	expiring.add(a, generation)
+	time.Sleep(5 * time.Millisecond)
+	if missing, _ := expiring.contains(a); missing {
+		t.Error("Expired coordinate is still cached")
+	}
+}
+
+func Test_CacheMissingTiles(t *testing.T) {
+	ctx := context.Background()
+	tile := uniformImage(color.NRGBA{G: 255, A: 255})
+	filename := createRasterTileset(t, nil, map[TileCoord]image.Image{{Z: 0}: tile})
+
+	var queries int32
+	db, err := Open(filename, CacheMissingTiles(10, 50*time.Millisecond), TraceQueries(func(QueryTrace) { atomic.AddInt32(&queries, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.GetTile(ctx, 1, 0, 0); err != ErrTileNotFound {
+		t.Fatal("Expected ErrTileNotFound, got:", err)
+	}
+	atomic.StoreInt32(&queries, 0)
+	if _, err := db.GetTile(ctx, 1, 0, 0); err != ErrTileNotFound {
+		t.Fatal("Expected ErrTileNotFound for cached missing tile, got:", err)
+	}
+	if _, err := db.OpenTile(ctx, 1, 0, 0); err != ErrTileNotFound {
+		t.Fatal("Expected ErrTileNotFound opening cached missing tile, got:", err)
+	}
+	if queries != 0 {
+		t.Error("Expected cached missing tile to be found without a query, got queries:", queries)
+	}
+
+	// tiles written by another process are found after Reload
+	pool, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	if _, err := pool.Exec("insert into tiles (zoom_level, tile_column, tile_row, tile_data) select 1, 0, 0, tile_data from tiles where zoom_level = 0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetTile(ctx, 1, 0, 0); err != nil {
+		t.Error("Expected tile written after Reload, got:", err)
+	}
+
+	// or once the missing tile expires
+	if _, err := db.GetTile(ctx, 1, 1, 0); err != ErrTileNotFound {
+		t.Fatal("Expected ErrTileNotFound, got:", err)
+	}
+	if _, err := pool.Exec("insert into tiles (zoom_level, tile_column, tile_row, tile_data) select 1, 1, 0, tile_data from tiles where zoom_level = 0"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := db.GetTile(ctx, 1, 1, 0); err != nil {
+		t.Error("Expected tile written after missing tile expired, got:", err)
+	}
+}
+
+func Test_Prewarm(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sizes, err := db.Prewarm(context.Background(), ZoomLimit{MinZoom: 0, MaxZoom: 5})
+	if err != nil {
+		t.Fatal("Could not prewarm tiles:", err)
+	}
+	expected, err := db.EstimateExtract(context.Background(), []float64{-180, -85, 180, 85}, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != expected[0] || sizes[1] != expected[1] {
+		t.Error("Prewarmed tiles do not match expected value, got:", sizes, expected)
+	}
+
+	if _, err := db.Prewarm(context.Background(), ZoomLimit{MinZoom: 2, MaxZoom: 1}); err == nil {
+		t.Error("Expected error for invalid zoom range")
+	}
+}
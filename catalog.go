@@ -0,0 +1,74 @@
+package mbtiles
+
+import (
+	"sort"
+	"strings"
+)
+
+// Catalog creates a catalog of tilesets found by DiscoverTilesets, as served at
+// /services or /catalog, for frontends that let users choose among tilesets.
+// The returned entries can be encoded to JSON, and are ordered by ID.
+//
+// Each entry has the tileset ID derived from its path relative to base using
+// IDFromPath, its name, tile format, bounds, and zoom range, and links named by
+// the keys of linkTemplates, such as "tilejson" or "preview", with "{id}" in
+// each template replaced by the tileset ID.  Bounds are omitted if missing or
+// invalid, and tilesets that could not be read are omitted.  An error is returned if more than one tileset produces the same
+// ID.
+func Catalog(base string, tilesets []TilesetInfo, linkTemplates map[string]string) ([]map[string]interface{}, error) {
+	ids, byID, err := catalogIDs(base, tilesets)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		tileset := byID[id]
+
+		links := make(map[string]string, len(linkTemplates))
+		for rel, template := range linkTemplates {
+			links[rel] = strings.ReplaceAll(template, "{id}", id)
+		}
+
+		entry := map[string]interface{}{
+			"id":      id,
+			"name":    tileset.Name,
+			"format":  tileset.Format.String(),
+			"minzoom": tileset.MinZoom,
+			"maxzoom": tileset.MaxZoom,
+			"links":   links,
+		}
+		if validateBounds(tileset.Bounds) == nil {
+			entry["bounds"] = tileset.Bounds
+		}
+		catalog = append(catalog, entry)
+	}
+	return catalog, nil
+}
+
+// catalogIDs derives tileset IDs for tilesets that could be read, relative to
+// base, and returns the sorted IDs and a map of ID to tileset.
+func catalogIDs(base string, tilesets []TilesetInfo) ([]string, map[string]TilesetInfo, error) {
+	paths := make([]string, 0, len(tilesets))
+	byPath := make(map[string]TilesetInfo, len(tilesets))
+	for _, tileset := range tilesets {
+		if tileset.Err != nil {
+			continue
+		}
+		paths = append(paths, tileset.Path)
+		byPath[tileset.Path] = tileset
+	}
+
+	pathsByID, err := IDsFromPaths(base, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]string, 0, len(pathsByID))
+	byID := make(map[string]TilesetInfo, len(pathsByID))
+	for id, path := range pathsByID {
+		ids = append(ids, id)
+		byID[id] = byPath[path]
+	}
+	sort.Strings(ids)
+	return ids, byID, nil
+}
@@ -0,0 +1,59 @@
+package mbtiles
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Catalog(t *testing.T) {
+	tilesets, err := DiscoverTilesets("./testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tilesets = append(tilesets, TilesetInfo{Path: "testdata/unreadable.mbtiles", Err: errors.New("unreadable")})
+
+	catalog, err := Catalog("testdata", tilesets, map[string]string{
+		"tilejson": "https://example.com/services/{id}",
+		"preview":  "https://example.com/services/{id}/map",
+	})
+	if err != nil {
+		t.Fatal("Could not create catalog:", err)
+	}
+
+	var ids []string
+	byID := make(map[string]map[string]interface{})
+	for _, entry := range catalog {
+		id := entry["id"].(string)
+		ids = append(ids, id)
+		byID[id] = entry
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Error("Catalog is not ordered by ID:", ids)
+		}
+	}
+	if _, ok := byID["unreadable"]; ok {
+		t.Error("Catalog includes tileset that could not be read")
+	}
+
+	entry, ok := byID["world_cities"]
+	if !ok {
+		t.Fatal("Catalog does not include world_cities:", ids)
+	}
+	if entry["format"] != "pbf" || entry["minzoom"] != 0 || entry["maxzoom"] != 6 || entry["name"] != "Major cities from Natural Earth data" {
+		t.Error("Unexpected catalog entry:", entry)
+	}
+	if _, ok := entry["bounds"].([]float64); !ok {
+		t.Error("Catalog entry does not include bounds:", entry)
+	}
+	links := entry["links"].(map[string]string)
+	if links["tilejson"] != "https://example.com/services/world_cities" || links["preview"] != "https://example.com/services/world_cities/map" {
+		t.Error("Unexpected catalog links:", links)
+	}
+
+	// IDs that collide are an error
+	duplicate := TilesetInfo{Path: "other/world_cities.mbtiles"}
+	if _, err := Catalog("testdata", append(tilesets, duplicate), nil); err == nil {
+		t.Error("Expected error for colliding tileset IDs")
+	}
+}
@@ -0,0 +1,150 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoReplicationLog is returned by ChangesSince if the mbtiles file does not
+// have a changelog table.
+var ErrNoReplicationLog = errors.New("mbtiles file does not have a replication log")
+
+// changelogTable records tile writes and deletes made with ReplicationLog; seq
+// increases with each change and is never reused.
+const changelogTable = "create table if not exists changelog (seq integer primary key autoincrement, zoom_level integer, tile_column integer, tile_row integer, op text, timestamp integer, tile_hash text)"
+
+// ReplicationLog records every tile written or deleted using the MBtiles
+// handle in a changelog table of the mbtiles file, within the same
+// transaction as the change.  Replicas and CDNs can then apply changes
+// incrementally using ChangesSince.  The table is created on the first write.
+func ReplicationLog() OpenOption {
+	return func(o *openOptions) {
+		o.replicationLog = true
+	}
+}
+
+// ChangeOp is the type of a change recorded in the replication log.
+type ChangeOp uint8
+
+// ChangeOp values
+const (
+	ChangeWrite  ChangeOp = iota // tile was written
+	ChangeDelete                 // tile was deleted
+)
+
+// String returns a string representing the ChangeOp, as stored in the
+// changelog table.
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeWrite:
+		return "write"
+	case ChangeDelete:
+		return "delete"
+	default:
+		return ""
+	}
+}
+
+// Change is a tile write or delete recorded in the replication log.
+type Change struct {
+	Seq       int64
+	Coord     TileCoord // TMS scheme
+	Op        ChangeOp
+	Timestamp time.Time
+	Hash      string // MD5 hash of the tile data written; empty for deletes
+}
+
+// ChangesSince returns the changes recorded in the replication log after seq,
+// in the order they were made, along with the sequence number to pass to the
+// next call.  Pass 0 to return all changes.  ErrNoReplicationLog is returned if
+// nothing was ever recorded in the mbtiles file.
+func (db *MBtiles) ChangesSince(ctx context.Context, seq int64) ([]Change, int64, error) {
+	if db.isClosed() {
+		return nil, 0, errors.New("cannot read changes from closed mbtiles database")
+	}
+
+	q := db.traced(db.pool)
+	var count int
+	if err := q.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'changelog'").Scan(&count); err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, ErrNoReplicationLog
+	}
+
+	rows, err := q.QueryContext(ctx, "select seq, zoom_level, tile_column, tile_row, op, timestamp, coalesce(tile_hash, '') from changelog where seq > ? order by seq", seq)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var (
+			change    Change
+			op        string
+			timestamp int64
+		)
+		if err := rows.Scan(&change.Seq, &change.Coord.Z, &change.Coord.X, &change.Coord.Y, &op, &timestamp, &change.Hash); err != nil {
+			return nil, 0, err
+		}
+		switch op {
+		case ChangeWrite.String():
+			change.Op = ChangeWrite
+		case ChangeDelete.String():
+			change.Op = ChangeDelete
+		default:
+			return nil, 0, fmt.Errorf("unknown change %q in replication log", op)
+		}
+		change.Timestamp = time.Unix(timestamp, 0)
+		changes = append(changes, change)
+		seq = change.Seq
+	}
+	return changes, seq, rows.Err()
+}
+
+// writeSchema determines how tiles are stored in the mbtiles file, for writes
+// within a transaction.  With ReplicationLog, the changelog table is created
+// if needed, and changes are logged.
+func (db *MBtiles) writeSchema(ctx context.Context, tx querier) (tileSchema, error) {
+	schema, err := readTileSchema(ctx, tx)
+	if err != nil {
+		return tileSchema{}, err
+	}
+	if db.options.replicationLog {
+		if _, err := tx.ExecContext(ctx, changelogTable); err != nil {
+			return tileSchema{}, err
+		}
+		schema.logged = true
+	}
+	return schema, nil
+}
+
+// logChange records a change to a single tile in the replication log, if
+// changes are logged for schema.
+func logChange(ctx context.Context, tx querier, schema tileSchema, op ChangeOp, coord TileCoord, tileHash string) error {
+	if !schema.logged {
+		return nil
+	}
+	var hash interface{}
+	if tileHash != "" {
+		hash = tileHash
+	}
+	_, err := tx.ExecContext(ctx, "insert into changelog (zoom_level, tile_column, tile_row, op, timestamp, tile_hash) values (?, ?, ?, ?, ?, ?)",
+		coord.Z, coord.X, coord.Y, op.String(), time.Now().Unix(), hash)
+	return err
+}
+
+// logDeletes records the deletion of all tiles in schema.table that match the
+// where clause in the replication log, if changes are logged for schema.  This
+// must be called before the tiles are deleted.
+func logDeletes(ctx context.Context, tx querier, schema tileSchema, where string, args ...interface{}) error {
+	if !schema.logged {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "insert into changelog (zoom_level, tile_column, tile_row, op, timestamp) select zoom_level, tile_column, tile_row, ?, ? from "+schema.table+" where "+where,
+		append([]interface{}{ChangeDelete.String(), time.Now().Unix()}, args...)...)
+	return err
+}
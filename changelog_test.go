@@ -0,0 +1,102 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ChangesSince(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+
+	db, err := Open(filename, ReplicationLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, _, err := db.ChangesSince(ctx, 0); err != ErrNoReplicationLog {
+		t.Error("Expected ErrNoReplicationLog before any changes:", err)
+	}
+
+	// z1 tiles are deleted, then one is written again
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	changes, seq, err := db.ChangesSince(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 || seq != changes[len(changes)-1].Seq {
+		t.Fatal("Unexpected changes after delete:", changes, seq)
+	}
+	for _, change := range changes {
+		if change.Op != ChangeDelete || change.Coord.Z != 1 || change.Hash != "" || change.Timestamp.IsZero() {
+			t.Error("Unexpected delete change:", change)
+		}
+	}
+
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.insertTile(ctx, 1, 0, 0, data); err != nil {
+		t.Fatal(err)
+	}
+	// inserting an existing tile is not a change
+	if err := db.insertTile(ctx, 1, 0, 0, data); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, next, err := db.ChangesSince(ctx, seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || next <= seq {
+		t.Fatal("Unexpected changes after write:", changes, next)
+	}
+	if change := changes[0]; change.Op != ChangeWrite || change.Coord != (TileCoord{Z: 1, X: 0, Y: 0}) || change.Hash != hashTile(data) {
+		t.Error("Unexpected write change:", change)
+	}
+
+	if changes, _, err := db.ChangesSince(ctx, next); err != nil || len(changes) != 0 {
+		t.Error("Expected no changes after last sequence number:", changes, err)
+	}
+}
+
+func Test_ChangesSince_overlay(t *testing.T) {
+	ctx := context.Background()
+	basePath := copyTestdata(t, "geography-class-png.mbtiles")
+
+	overlay, err := OpenOverlay(basePath, filepath.Join(t.TempDir(), "patch.mbtiles"), ReplicationLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer overlay.Close()
+
+	var data []byte
+	if err := overlay.ReadTile(0, 0, 0, &data); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.WriteTile(ctx, 2, 0, 0, data); err != nil {
+		t.Fatal(err)
+	}
+	// the base has no tiles at zoom level 3, so deleting one is not a change
+	for _, coord := range []TileCoord{{Z: 1, X: 1, Y: 1}, {Z: 3, X: 0, Y: 0}} {
+		if err := overlay.DeleteTile(ctx, coord.Z, coord.X, coord.Y); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := overlay.Flatten(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, _, err := overlay.Base().ChangesSince(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Op != ChangeWrite || changes[1].Op != ChangeDelete || changes[1].Coord != (TileCoord{Z: 1, X: 1, Y: 1}) {
+		t.Error("Unexpected changes after Flatten:", changes)
+	}
+}
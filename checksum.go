@@ -0,0 +1,113 @@
+package mbtiles
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumExtension is the extension added to the path of an mbtiles file to
+// get the path of its checksum file.
+const ChecksumExtension = ".sha256"
+
+// ErrChecksumMismatch is returned by VerifyChecksumFile if the mbtiles file
+// does not match its checksum file.
+var ErrChecksumMismatch = errors.New("mbtiles file does not match checksum")
+
+// WriteChecksumFile computes the SHA-256 checksum of the mbtiles file at path,
+// and writes it to a checksum file at path with ChecksumExtension added, in
+// the format of the sha256sum command, so that the file can be verified after
+// distribution using VerifyChecksumFile or sha256sum -c.  Returns the
+// checksum in hexadecimal.
+//
+// The checksum reflects the main database file only; tilesets in WAL mode
+// must be checkpointed first so that all changes are included.
+func WriteChecksumFile(path string) (string, error) {
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(path))
+	if err := os.WriteFile(path+ChecksumExtension, []byte(line), 0644); err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
+
+// VerifyChecksumFile verifies that the mbtiles file at path matches the
+// checksum in its checksum file, as written by WriteChecksumFile, without
+// opening it with SQLite.  Returns ErrChecksumMismatch if it does not match.
+func VerifyChecksumFile(path string) error {
+	f, err := os.Open(path + ChecksumExtension)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	expected, err := readChecksumLine(f, filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("invalid checksum file %q: %w", path+ChecksumExtension, err)
+	}
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if checksum != expected {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// readChecksumLine reads the checksum of name from r, in the format of the
+// sha256sum command.  A line without a name is used if there is no line for
+// name.
+func readChecksumLine(r io.Reader, name string) (string, error) {
+	var checksum string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		value := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(value); err != nil || len(value) != sha256.Size*2 {
+			return "", fmt.Errorf("invalid SHA-256 checksum %q", fields[0])
+		}
+		// sha256sum marks files read in binary mode with *
+		if len(fields) == 1 || strings.TrimPrefix(fields[1], "*") == name {
+			checksum = value
+			if len(fields) > 1 {
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if checksum == "" {
+		return "", fmt.Errorf("no checksum for %q", name)
+	}
+	return checksum, nil
+}
+
+// fileChecksum returns the SHA-256 checksum of the file at path in
+// hexadecimal.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,60 @@
+package mbtiles
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_WriteChecksumFile(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	checksum, err := WriteChecksumFile(filename)
+	if err != nil {
+		t.Fatal("Could not write checksum file:", err)
+	}
+	data, err := os.ReadFile(filename + ChecksumExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != checksum+"  geography-class-png.mbtiles\n" {
+		t.Error("Checksum file does not match expected format, got:", string(data))
+	}
+
+	if err := VerifyChecksumFile(filename); err != nil {
+		t.Error("Checksum does not verify:", err)
+	}
+
+	// modify the file
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0})
+	f.Close()
+	if err := VerifyChecksumFile(filename); !errors.Is(err, ErrChecksumMismatch) {
+		t.Error("Modified file verified, got:", err)
+	}
+}
+
+func Test_readChecksumLine(t *testing.T) {
+	checksum := strings.Repeat("ab", 32)
+	tests := []struct {
+		content  string
+		expected string
+		valid    bool
+	}{
+		{checksum + "  world.mbtiles\n", checksum, true},
+		{checksum + " *world.mbtiles\n", checksum, true},
+		{checksum + "\n", checksum, true},
+		{strings.Repeat("cd", 32) + "  other.mbtiles\n" + checksum + "  world.mbtiles\n", checksum, true},
+		{strings.Repeat("cd", 32) + "  other.mbtiles\n", "", false},
+		{"nothex  world.mbtiles\n", "", false},
+	}
+	for _, tc := range tests {
+		got, err := readChecksumLine(strings.NewReader(tc.content), "world.mbtiles")
+		if (err == nil) != tc.valid || got != tc.expected {
+			t.Error("readChecksumLine", tc.content, "got:", got, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"sync"
+)
+
+// CompositeFunc combines a pixel of a tile of a base tileset with the pixel at
+// the same position of the tile of an overlay tileset.  Pixels of missing tiles
+// are transparent.
+type CompositeFunc func(base color.NRGBA, overlay color.NRGBA) color.NRGBA
+
+// AlphaBlend is a CompositeFunc that draws overlay over base, using the alpha
+// of overlay.
+func AlphaBlend(base color.NRGBA, overlay color.NRGBA) color.NRGBA {
+	a := uint32(overlay.A)
+	ba := uint32(base.A) * (255 - a) / 255
+	outA := a + ba
+	if outA == 0 {
+		return color.NRGBA{}
+	}
+	blend := func(o uint8, b uint8) uint8 {
+		return uint8((uint32(o)*a + uint32(b)*ba + outA/2) / outA)
+	}
+	return color.NRGBA{R: blend(overlay.R, base.R), G: blend(overlay.G, base.G), B: blend(overlay.B, base.B), A: uint8(outA)}
+}
+
+// compositeResult is a tile composited by a worker of Composite.
+type compositeResult struct {
+	coord TileCoord
+	data  []byte
+	err   error
+}
+
+// Composite creates a new mbtiles file at dst of PNG tiles that combine the
+// tiles of base and overlay pixel by pixel using op, such as AlphaBlend or a
+// difference of bands, and returns the number of tiles written.  dst must not
+// already exist.  Tiles are composited using up to concurrency goroutines;
+// concurrency less than 1 is treated as 1.
+//
+// A tile is written for each tile in either tileset; pixels of the missing
+// tile are transparent.  The tilesets must be aligned, with tiles of the same
+// size.  Metadata is combined as described for Mosaic.  Only PNG and JPG
+// tilesets are supported.  dst is removed if the operation fails.
+func Composite(ctx context.Context, dst string, base *MBtiles, overlay *MBtiles, op CompositeFunc, concurrency int) (int64, error) {
+	for _, db := range []*MBtiles{base, overlay} {
+		if db.isClosed() {
+			return 0, errors.New("cannot composite closed mbtiles database")
+		}
+		if format := db.GetTileFormat(); format != PNG && format != JPG {
+			return 0, fmt.Errorf("composite is only supported for PNG and JPG tilesets, not %v", format)
+		}
+	}
+	sources := []*MBtiles{base, overlay}
+	metadata, err := unionMetadata(sources)
+	if err != nil {
+		return 0, err
+	}
+	parameters := map[string]interface{}{"sources": []string{base.GetFilename(), overlay.GetFilename()}}
+	return compositeTiles(ctx, dst, sources, metadata, "composite", parameters, concurrency, func(coord TileCoord) ([]byte, error) {
+		return compositeTile(ctx, base, overlay, coord, op)
+	})
+}
+
+// compositeTiles creates a new mbtiles file at dst, with metadata copied from
+// the first of sources except for the items in metadata, and a tile rendered
+// by render for each tile in any of sources, using up to concurrency
+// goroutines.  Returns the number of tiles written.
+func compositeTiles(ctx context.Context, dst string, sources []*MBtiles, metadata map[string]string, operation string, parameters map[string]interface{}, concurrency int, render func(coord TileCoord) ([]byte, error)) (int64, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var coords []TileCoord
+	for _, db := range sources {
+		sourceCoords, err := readAllTileCoords(ctx, db.traced(db.pool))
+		if err != nil {
+			return 0, err
+		}
+		coords = unionTileCoords(coords, sourceCoords)
+	}
+
+	var count int64
+	err := sources[0].extract(ctx, dst, metadata, func(q querier) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		pending := make(chan TileCoord)
+		results := make(chan compositeResult)
+		var wg sync.WaitGroup
+		wg.Add(concurrency)
+		for w := 0; w < concurrency; w++ {
+			go func() {
+				defer wg.Done()
+				for coord := range pending {
+					data, err := render(coord)
+					select {
+					case results <- compositeResult{coord: coord, data: data, err: err}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			defer close(pending)
+			for _, coord := range coords {
+				select {
+				case pending <- coord:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		for result := range results {
+			if result.err != nil {
+				return result.err
+			}
+			_, err := q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", result.coord.Z, result.coord.X, result.coord.Y, result.data)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return sources[0].recordExtractHistory(ctx, q, operation, parameters)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// compositeTile composites the tiles of base and overlay at coord (TMS
+// scheme) using op, and returns the encoded PNG.
+func compositeTile(ctx context.Context, base *MBtiles, overlay *MBtiles, coord TileCoord, op CompositeFunc) ([]byte, error) {
+	a, err := readTileNRGBA(ctx, base, coord)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readTileNRGBA(ctx, overlay, coord)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		a = image.NewNRGBA(b.Rect)
+	}
+	if b == nil {
+		b = image.NewNRGBA(a.Rect)
+	}
+	if a.Rect != b.Rect {
+		return nil, &TileError{Coord: coord, Err: fmt.Errorf("tile sizes %v and %v do not match", a.Rect.Size(), b.Rect.Size())}
+	}
+
+	for i := 0; i < len(a.Pix); i += 4 {
+		pa := color.NRGBA{R: a.Pix[i], G: a.Pix[i+1], B: a.Pix[i+2], A: a.Pix[i+3]}
+		pb := color.NRGBA{R: b.Pix[i], G: b.Pix[i+1], B: b.Pix[i+2], A: b.Pix[i+3]}
+		c := op(pa, pb)
+		a.Pix[i], a.Pix[i+1], a.Pix[i+2], a.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	return encodePNG(a)
+}
+
+// encodePNG encodes img as a PNG.
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readTileNRGBA reads and decodes the tile of db at coord (TMS scheme), with
+// its origin at 0, 0, or returns nil if the tile does not exist.
+func readTileNRGBA(ctx context.Context, db *MBtiles, coord TileCoord) (*image.NRGBA, error) {
+	var data []byte
+	err := db.queryTile(ctx, coord.Z, coord.X, coord.Y, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &TileError{Coord: coord, Err: err}
+	}
+	b := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Rect, src, b.Min, draw.Src)
+	return img, nil
+}
+
+// readAllTileCoords reads the coordinates (TMS scheme) of all tiles, in order
+// of zoom level, column, and row.
+func readAllTileCoords(ctx context.Context, q querier) ([]TileCoord, error) {
+	rows, err := q.QueryContext(ctx, "select zoom_level, tile_column, tile_row from tiles order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coords []TileCoord
+	for rows.Next() {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			return nil, err
+		}
+		coords = append(coords, coord)
+	}
+	return coords, rows.Err()
+}
+
+// unionTileCoords returns the coordinates in either a or b, in order of zoom
+// level, column, and row.
+func unionTileCoords(a []TileCoord, b []TileCoord) []TileCoord {
+	seen := make(map[TileCoord]bool, len(a)+len(b))
+	var coords []TileCoord
+	for _, coord := range append(append([]TileCoord(nil), a...), b...) {
+		if !seen[coord] {
+			seen[coord] = true
+			coords = append(coords, coord)
+		}
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		a, b := coords[i], coords[j]
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+	return coords
+}
@@ -0,0 +1,130 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+)
+
+func Test_AlphaBlend(t *testing.T) {
+	base := color.NRGBA{R: 0, G: 0, B: 255, A: 255}
+	tests := []struct {
+		overlay  color.NRGBA
+		expected color.NRGBA
+	}{
+		{overlay: color.NRGBA{R: 255, A: 255}, expected: color.NRGBA{R: 255, A: 255}},
+		{overlay: color.NRGBA{R: 255, A: 0}, expected: base},
+		{overlay: color.NRGBA{R: 255, A: 128}, expected: color.NRGBA{R: 128, B: 127, A: 255}},
+	}
+	for _, tc := range tests {
+		if c := AlphaBlend(base, tc.overlay); c != tc.expected {
+			t.Error("Blended color", c, "does not match expected value", tc.expected, "for:", tc.overlay)
+		}
+	}
+	if c := AlphaBlend(color.NRGBA{}, color.NRGBA{}); c != (color.NRGBA{}) {
+		t.Error("Blending transparent colors is not transparent:", c)
+	}
+}
+
+func Test_Composite(t *testing.T) {
+	base, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	// the overlay has an opaque red tile at 1/0/0, and a tile at 2/0/0 that
+	// is not in base
+	red := uniformImage(color.NRGBA{R: 255, A: 255})
+	overlayPath := createRasterTileset(t, nil, map[TileCoord]image.Image{
+		{Z: 1, X: 0, Y: 0}: red,
+		{Z: 2, X: 0, Y: 0}: red,
+	})
+
+	overlay, err := Open(overlayPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer overlay.Close()
+
+	dst := filepath.Join(t.TempDir(), "composite.mbtiles")
+	count, err := Composite(context.Background(), dst, base, overlay, AlphaBlend, 4)
+	if err != nil {
+		t.Fatal("Could not composite tilesets:", err)
+	}
+	if count != 6 {
+		t.Error("Composite did not write expected number of tiles:", count)
+	}
+
+	out, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	for _, coord := range []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 2, X: 0, Y: 0}} {
+		img, err := readTileNRGBA(context.Background(), out, coord)
+		if err != nil || img == nil {
+			t.Fatal("Could not read composite tile:", coord, err)
+		}
+		if c := img.NRGBAAt(100, 100); c != (color.NRGBA{R: 255, A: 255}) {
+			t.Error("Composite pixel", c, "is not red for:", coord)
+		}
+	}
+
+	// tiles without an overlay tile are unchanged
+	expected, err := readTileNRGBA(context.Background(), base, TileCoord{Z: 0, X: 0, Y: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := readTileNRGBA(context.Background(), out, TileCoord{Z: 0, X: 0, Y: 0})
+	if err != nil || img == nil || !bytes.Equal(img.Pix, expected.Pix) {
+		t.Error("Composite tile without overlay does not match base tile:", err)
+	}
+}
+
+// createRasterTileset creates an mbtiles file with metadata and PNG tiles, by
+// TMS scheme tile coordinates.
+func createRasterTileset(t *testing.T, metadata map[string]string, tiles map[TileCoord]image.Image) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "raster.mbtiles")
+	pool, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for _, query := range schemaStatements("") {
+		if _, err := pool.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, value := range metadata {
+		if _, err := pool.Exec("insert into metadata (name, value) values (?, ?)", name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for coord, img := range tiles {
+		data, err := encodePNG(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec("insert into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filename
+}
+
+// uniformImage returns a 256px image of a single color.
+func uniformImage(c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
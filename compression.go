@@ -0,0 +1,130 @@
+package mbtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ZoomCompression describes the compression of tiles at a zoom level.
+type ZoomCompression struct {
+	Zoom              int
+	Tiles             int64
+	GzippedTiles      int64 // number of tiles that are gzip compressed
+	OptimalTiles      int64 // number of gzipped tiles that are no larger when recompressed
+	Bytes             int64 // total length of tile data
+	UncompressedBytes int64 // total length of tile data after decompression
+	RecompressedBytes int64 // total length of tile data if gzipped at best compression
+}
+
+// Ratio returns the ratio of uncompressed to compressed size of tiles.
+func (c ZoomCompression) Ratio() float64 {
+	if c.Bytes == 0 {
+		return 0
+	}
+	return float64(c.UncompressedBytes) / float64(c.Bytes)
+}
+
+// Savings returns the estimated number of bytes saved by recompressing tiles
+// at best compression, including compressing tiles that are not gzipped.
+func (c ZoomCompression) Savings() int64 {
+	return c.Bytes - c.RecompressedBytes
+}
+
+// ReadCompressionStats analyzes the compression of vector tiles at each zoom
+// level, to estimate whether recompressing them would be worthwhile.  Every
+// tile is decompressed and recompressed at gzip.BestCompression, so this can be
+// slow for large tilesets.  Only PBF tilesets are supported.
+func (db *MBtiles) ReadCompressionStats(ctx context.Context) ([]ZoomCompression, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot analyze tiles in closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return nil, fmt.Errorf("cannot analyze compression of tiles in %v format", format)
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx, "select zoom_level, tile_data from tiles order by zoom_level")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		stats []ZoomCompression
+		buf   bytes.Buffer
+	)
+	writer, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	for rows.Next() {
+		var (
+			z    int
+			data []byte
+		)
+		if err := rows.Scan(&z, &data); err != nil {
+			return nil, err
+		}
+		if len(stats) == 0 || stats[len(stats)-1].Zoom != z {
+			stats = append(stats, ZoomCompression{Zoom: z})
+		}
+		s := &stats[len(stats)-1]
+
+		raw := data
+		gzipped := len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+		if gzipped {
+			raw, err = gunzip(data)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decompress tile at zoom level %d: %w", z, err)
+			}
+		}
+
+		buf.Reset()
+		writer.Reset(&buf)
+		if _, err := writer.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		recompressed := int64(buf.Len())
+		if recompressed > int64(len(data)) {
+			recompressed = int64(len(data))
+		}
+
+		s.Tiles++
+		s.Bytes += int64(len(data))
+		s.UncompressedBytes += int64(len(raw))
+		s.RecompressedBytes += recompressed
+		if gzipped {
+			s.GzippedTiles++
+			if recompressed == int64(len(data)) {
+				s.OptimalTiles++
+			}
+		}
+	}
+	return stats, rows.Err()
+}
+
+// gunzip decompresses gzip compressed data.
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// gzipBytes compresses data using gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_ReadCompressionStats(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stats, err := db.ReadCompressionStats(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error reading compression stats:", err)
+	}
+	expectedTiles := []int64{1, 4, 7, 17, 38, 57, 72}
+	if len(stats) != len(expectedTiles) {
+		t.Fatal("Expected stats for 7 zoom levels, got:", stats)
+	}
+	var total int64
+	for i, s := range stats {
+		if s.Zoom != i || s.Tiles != expectedTiles[i] || s.GzippedTiles != s.Tiles {
+			t.Error("Stats for zoom level", i, "do not match expected values, got:", s)
+		}
+		// small tiles may be larger when compressed
+		if s.UncompressedBytes == 0 || s.Ratio() != float64(s.UncompressedBytes)/float64(s.Bytes) {
+			t.Error("Compression ratio for zoom level", i, "does not match expected value, got:", s.Ratio())
+		}
+		if s.Savings() < 0 || s.OptimalTiles > s.GzippedTiles {
+			t.Error("Invalid recompression estimate for zoom level", i, "got:", s)
+		}
+		total += s.Bytes
+	}
+	if total != 18861 {
+		t.Error("Total size does not match expected value, got:", total)
+	}
+
+	png, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer png.Close()
+	if _, err := png.ReadCompressionStats(context.Background()); err == nil {
+		t.Error("Expected error reading compression stats for PNG tileset")
+	}
+}
@@ -0,0 +1,245 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	// contourLayer is the name of the vector tile layer written by
+	// GenerateContours.
+	contourLayer = "contour"
+	// contourExtent is the extent of vector tiles written by GenerateContours.
+	contourExtent = 4096
+	// contourMetadata is the JSON metadata of tilesets written by
+	// GenerateContours.
+	contourMetadata = `{"vector_layers":[{"id":"contour","fields":{"ele":"Number"}}]}`
+)
+
+// contourPoint is a point of a contour line in vector tile coordinates.
+type contourPoint [2]int32
+
+// GenerateContours creates a new mbtiles file at dst of vector tiles of contour
+// lines every interval meters, computed from the Terrain-RGB tiles of this
+// mbtiles file, and returns the number of tiles written.  dst must not already
+// exist.  Tiles without contour lines are not written.
+//
+// Each tile has a contour layer with a LineString feature for each elevation,
+// with the elevation in meters as its ele attribute.  Lines are traced between
+// pixel centers using marching squares, so lines within half a pixel of the
+// edges of a tile are not included.  Tiles are gzip compressed.  Metadata is
+// copied from this mbtiles file, with format set to pbf and json set to
+// describe the contour layer.  Only PNG tilesets are supported, as described
+// for ElevationProfile.  dst is removed if the operation fails.
+func (db *MBtiles) GenerateContours(ctx context.Context, dst string, interval float64) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot generate contours from closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PNG {
+		return 0, fmt.Errorf("contours are only supported for PNG tilesets, not %v", format)
+	}
+	if !(interval > 0) {
+		return 0, fmt.Errorf("invalid contour interval %f: must be greater than 0", interval)
+	}
+
+	var count int64
+	metadata := map[string]string{"format": "pbf", "json": contourMetadata}
+	err := db.extract(ctx, dst, metadata, func(q querier) error {
+		err := forEachTile(ctx, q, func(coord TileCoord, data []byte) error {
+			src, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return &TileError{Coord: coord, Err: err}
+			}
+
+			tile, err := contourTile(src, interval)
+			if err != nil || tile == nil {
+				return err
+			}
+			_, err = q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, tile)
+			if err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return db.recordExtractHistory(ctx, q, "contours", map[string]interface{}{"interval": interval})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// contourTile returns a gzip compressed vector tile of contour lines every
+// interval meters of src, a decoded Terrain-RGB tile, or nil if there are no
+// contour lines.
+func contourTile(src image.Image, interval float64) ([]byte, error) {
+	elevations, width, height := terrainElevations(src)
+	if width < 2 || height < 2 {
+		return nil, nil
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, elevation := range elevations {
+		low, high = math.Min(low, elevation), math.Max(high, elevation)
+	}
+
+	layer := mvtLayer{version: 2, name: contourLayer, extent: contourExtent, keys: []string{"ele"}}
+	for level := math.Ceil(low/interval) * interval; level <= high; level += interval {
+		lines := joinSegments(contourSegments(elevations, width, height, level))
+		if len(lines) == 0 {
+			continue
+		}
+		layer.features = append(layer.features, mvtFeature{
+			tags:     []uint32{0, uint32(len(layer.values))},
+			geomType: mvtLineString,
+			geometry: encodeLineGeometry(lines),
+		})
+		layer.values = append(layer.values, level)
+	}
+	if len(layer.features) == 0 {
+		return nil, nil
+	}
+
+	return gzipBytes(encodeMVT([]mvtLayer{layer}))
+}
+
+// contourSegments returns the segments of the contour line at level of a
+// grid of elevations, using marching squares over the cells between pixel
+// centers.  Points are in vector tile coordinates.
+func contourSegments(elevations []float64, width int, height int, level float64) [][2]contourPoint {
+	sx := float64(contourExtent) / float64(width)
+	sy := float64(contourExtent) / float64(height)
+	// point returns the point at fraction t of the way from pixel a to b
+	point := func(ax, ay, bx, by int, va, vb float64) contourPoint {
+		t := (level - va) / (vb - va)
+		x := (float64(ax) + 0.5 + t*float64(bx-ax)) * sx
+		y := (float64(ay) + 0.5 + t*float64(by-ay)) * sy
+		return contourPoint{int32(math.Round(x)), int32(math.Round(y))}
+	}
+
+	var segments [][2]contourPoint
+	for y := 0; y < height-1; y++ {
+		for x := 0; x < width-1; x++ {
+			tl, tr := elevations[y*width+x], elevations[y*width+x+1]
+			bl, br := elevations[(y+1)*width+x], elevations[(y+1)*width+x+1]
+
+			var index int
+			for i, v := range []float64{tl, tr, br, bl} {
+				if v >= level {
+					index |= 8 >> i
+				}
+			}
+			if index == 0 || index == 15 {
+				continue
+			}
+
+			top := func() contourPoint { return point(x, y, x+1, y, tl, tr) }
+			right := func() contourPoint { return point(x+1, y, x+1, y+1, tr, br) }
+			bottom := func() contourPoint { return point(x, y+1, x+1, y+1, bl, br) }
+			left := func() contourPoint { return point(x, y, x, y+1, tl, bl) }
+			// whether the center of a saddle cell is above the level
+			center := (tl+tr+br+bl)/4 >= level
+
+			switch index {
+			case 1, 14:
+				segments = append(segments, [2]contourPoint{left(), bottom()})
+			case 2, 13:
+				segments = append(segments, [2]contourPoint{bottom(), right()})
+			case 3, 12:
+				segments = append(segments, [2]contourPoint{left(), right()})
+			case 4, 11:
+				segments = append(segments, [2]contourPoint{top(), right()})
+			case 6, 9:
+				segments = append(segments, [2]contourPoint{top(), bottom()})
+			case 7, 8:
+				segments = append(segments, [2]contourPoint{top(), left()})
+			case 5:
+				// top right and bottom left are above the level
+				if center {
+					segments = append(segments, [2]contourPoint{top(), left()}, [2]contourPoint{bottom(), right()})
+				} else {
+					segments = append(segments, [2]contourPoint{top(), right()}, [2]contourPoint{left(), bottom()})
+				}
+			case 10:
+				// top left and bottom right are above the level
+				if center {
+					segments = append(segments, [2]contourPoint{top(), right()}, [2]contourPoint{left(), bottom()})
+				} else {
+					segments = append(segments, [2]contourPoint{top(), left()}, [2]contourPoint{bottom(), right()})
+				}
+			}
+		}
+	}
+	return segments
+}
+
+// joinSegments joins segments that share end points into lines.  Repeated
+// points are removed, and lines with fewer than 2 points are dropped.
+func joinSegments(segments [][2]contourPoint) [][][2]int32 {
+	byPoint := make(map[contourPoint][]int)
+	for i, segment := range segments {
+		byPoint[segment[0]] = append(byPoint[segment[0]], i)
+		byPoint[segment[1]] = append(byPoint[segment[1]], i)
+	}
+	used := make([]bool, len(segments))
+
+	// extend follows unused segments from p, and returns the points reached
+	extend := func(p contourPoint) []contourPoint {
+		var points []contourPoint
+		for {
+			next := -1
+			for _, i := range byPoint[p] {
+				if !used[i] {
+					next = i
+					break
+				}
+			}
+			if next < 0 {
+				return points
+			}
+			used[next] = true
+			if segments[next][0] == p {
+				p = segments[next][1]
+			} else {
+				p = segments[next][0]
+			}
+			points = append(points, p)
+		}
+	}
+
+	var lines [][][2]int32
+	for i, segment := range segments {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		backward := extend(segment[0])
+		forward := extend(segment[1])
+
+		var line [][2]int32
+		add := func(p contourPoint) {
+			if len(line) == 0 || line[len(line)-1] != p {
+				line = append(line, p)
+			}
+		}
+		for j := len(backward) - 1; j >= 0; j-- {
+			add(backward[j])
+		}
+		add(segment[0])
+		add(segment[1])
+		for _, p := range forward {
+			add(p)
+		}
+		if len(line) >= 2 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
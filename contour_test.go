@@ -0,0 +1,104 @@
+package mbtiles
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func Test_GenerateContours(t *testing.T) {
+	// a cone 1000m high at the center of 1/0/0, and flat elsewhere
+	filename := createTerrainTileset(t, map[TileCoord]func(x int, y int) float64{
+		{Z: 1, X: 0, Y: 0}: func(x int, y int) float64 {
+			return math.Max(0, 1000-8*math.Hypot(float64(x)-127.5, float64(y)-127.5))
+		},
+		{Z: 1, X: 1, Y: 0}: constantElevation(100),
+	})
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	dst := filepath.Join(t.TempDir(), "contours.mbtiles")
+	count, err := db.GenerateContours(ctx, dst, 250)
+	if err != nil {
+		t.Fatal("Could not generate contours:", err)
+	}
+	if count != 1 {
+		t.Error("GenerateContours did not write expected number of tiles:", count)
+	}
+
+	out, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if out.GetTileFormat() != PBF {
+		t.Error("Contour tiles are not PBF")
+	}
+	if errs, err := out.ValidateVectorTiles(ctx, 1, 1); err != nil || len(errs) != 0 {
+		t.Error("Contour tiles are not valid:", errs, err)
+	}
+	metadata, err := out.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layers, ok := metadata["vector_layers"].([]interface{}); !ok || len(layers) != 1 {
+		t.Error("Metadata does not describe contour layer:", metadata)
+	}
+
+	var data []byte
+	if err := out.ReadTile(1, 0, 1, &data); err != nil || data == nil {
+		t.Fatal("Could not read contour tile:", err)
+	}
+	data, err = gunzip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := decodeMVT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || layers[0].name != contourLayer {
+		t.Fatal("Unexpected layers:", layers)
+	}
+
+	// 0m is the minimum elevation, so every pixel is at or above it
+	layer := layers[0]
+	expected := []float64{250, 500, 750}
+	if len(layer.features) != len(expected) || len(layer.values) != len(expected) {
+		t.Fatal("Unexpected number of contour features:", len(layer.features))
+	}
+	for i, feature := range layer.features {
+		if feature.geomType != mvtLineString || layer.values[feature.tags[1]] != expected[i] {
+			t.Error("Unexpected contour feature:", feature.geomType, layer.values[feature.tags[1]])
+		}
+		// each contour of the cone is a single closed line
+		if moveTos := countCommands(feature.geometry, mvtMoveTo); moveTos != 1 {
+			t.Error("Contour", expected[i], "has", moveTos, "lines instead of 1")
+		}
+	}
+
+	if _, err := db.GenerateContours(ctx, filepath.Join(t.TempDir(), "invalid.mbtiles"), 0); err == nil {
+		t.Error("Expected error for invalid interval")
+	}
+}
+
+// countCommands returns the number of geometry commands of type command.
+func countCommands(geometry []uint32, command uint32) int {
+	var count int
+	for i := 0; i < len(geometry); {
+		c, n := geometry[i]&7, int(geometry[i]>>3)
+		if c == command {
+			count++
+		}
+		i++
+		if c != mvtClosePath {
+			i += 2 * n
+		}
+	}
+	return count
+}
@@ -0,0 +1,209 @@
+package mbtiles
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxZoomLevel is the highest zoom level supported by TileCoord; tile columns
+// and rows at higher zoom levels would overflow.
+const MaxZoomLevel = 30
+
+// ErrInvalidTileCoord is wrapped by the errors of ParseTilePath and
+// TileCoord.Validate, so that handlers can distinguish requests for invalid
+// tiles from other errors.
+var ErrInvalidTileCoord = errors.New("invalid tile coordinates")
+
+// invalidCoordError is an error for invalid tile coordinates, whose message
+// is formatted as for fmt.Errorf.
+type invalidCoordError struct {
+	msg string
+}
+
+func (e *invalidCoordError) Error() string { return e.msg }
+
+// Is returns true for ErrInvalidTileCoord.
+func (e *invalidCoordError) Is(target error) bool { return target == ErrInvalidTileCoord }
+
+// invalidCoordf returns an error wrapping ErrInvalidTileCoord, with the
+// message formatted as for fmt.Errorf.
+func invalidCoordf(format string, args ...interface{}) error {
+	return &invalidCoordError{msg: fmt.Sprintf(format, args...)}
+}
+
+// TileCoord identifies a tile by zoom level, column, and row.  Unless
+// otherwise noted, coordinates use the XYZ scheme (origin at top left) used by
+// most web maps; use FlipY to convert to and from the TMS scheme (origin at
+// bottom left) used to store tiles in an mbtiles file.
+type TileCoord struct {
+	Z int64
+	X int64
+	Y int64
+}
+
+// ParseTilePath parses a tile path of the form "z/x/y" or "z/x/y.ext" into a
+// TileCoord, and the TileFormat of the extension, if present.
+// Example: "12/654/1583.pbf" => {Z: 12, X: 654, Y: 1583}, PBF
+//
+// An error wrapping ErrInvalidTileCoord is returned if the path is malformed,
+// the coordinates are out of range for the zoom level, or the extension is
+// not a known tile format.
+func ParseTilePath(path string) (TileCoord, TileFormat, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 3 {
+		return TileCoord{}, UNKNOWN, invalidCoordf("tile path %q is not of the form z/x/y", path)
+	}
+
+	format := UNKNOWN
+	if i := strings.LastIndexByte(parts[2], '.'); i >= 0 {
+		ext := parts[2][i+1:]
+		format = TileFormatFromExtension(ext)
+		if format == UNKNOWN {
+			return TileCoord{}, UNKNOWN, invalidCoordf("tile path %q has unknown tile format extension %q", path, ext)
+		}
+		parts[2] = parts[2][:i]
+	}
+
+	var values [3]int64
+	for i, part := range parts {
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return TileCoord{}, UNKNOWN, invalidCoordf("tile path %q has invalid coordinate %q", path, part)
+		}
+		values[i] = value
+	}
+
+	coord := TileCoord{Z: values[0], X: values[1], Y: values[2]}
+	if err := coord.Validate(); err != nil {
+		return TileCoord{}, UNKNOWN, err
+	}
+	return coord, format, nil
+}
+
+// String returns the tile coordinate as "z/x/y".
+func (c TileCoord) String() string {
+	return fmt.Sprintf("%d/%d/%d", c.Z, c.X, c.Y)
+}
+
+// Validate returns an error wrapping ErrInvalidTileCoord if the zoom level is
+// outside 0-MaxZoomLevel or the column or row is outside the range of tiles at
+// that zoom level.
+func (c TileCoord) Validate() error {
+	if c.Z < 0 || c.Z > MaxZoomLevel {
+		return invalidCoordf("tile %v has zoom level outside range 0-%d", c, MaxZoomLevel)
+	}
+	n := int64(1) << c.Z
+	if c.X < 0 || c.X >= n || c.Y < 0 || c.Y >= n {
+		return invalidCoordf("tile %v has column or row outside range 0-%d for zoom level", c, n-1)
+	}
+	return nil
+}
+
+// FlipY converts the row between the XYZ and TMS tiling schemes.
+func (c TileCoord) FlipY() TileCoord {
+	return TileCoord{Z: c.Z, X: c.X, Y: (int64(1) << c.Z) - 1 - c.Y}
+}
+
+// Quadkey returns the Bing Maps quadkey of the tile, assuming the XYZ scheme.
+// Returns an empty string for zoom level 0.
+func (c TileCoord) Quadkey() string {
+	var sb strings.Builder
+	sb.Grow(int(c.Z))
+	for i := c.Z; i > 0; i-- {
+		digit := byte('0')
+		mask := int64(1) << (i - 1)
+		if c.X&mask != 0 {
+			digit++
+		}
+		if c.Y&mask != 0 {
+			digit += 2
+		}
+		sb.WriteByte(digit)
+	}
+	return sb.String()
+}
+
+// Parent returns the tile at the next lower zoom level that contains this
+// tile.  The parent of a tile at zoom level 0 is the tile itself.
+func (c TileCoord) Parent() TileCoord {
+	if c.Z == 0 {
+		return c
+	}
+	return TileCoord{Z: c.Z - 1, X: c.X >> 1, Y: c.Y >> 1}
+}
+
+// Children returns the 4 tiles at the next higher zoom level contained by this
+// tile, in row-major order.  The row order is independent of tiling scheme.
+func (c TileCoord) Children() [4]TileCoord {
+	z, x, y := c.Z+1, c.X<<1, c.Y<<1
+	return [4]TileCoord{
+		{Z: z, X: x, Y: y},
+		{Z: z, X: x + 1, Y: y},
+		{Z: z, X: x, Y: y + 1},
+		{Z: z, X: x + 1, Y: y + 1},
+	}
+}
+
+// maxLatitude is the maximum latitude of the Web Mercator projection.
+const maxLatitude = 85.0511287798066
+
+// TileCoordFromLonLat returns the XYZ scheme tile at zoom level z that contains
+// the point at longitude lon and latitude lat, in degrees.  Points outside the
+// range of the Web Mercator projection are clamped to the nearest tile.
+func TileCoordFromLonLat(lon float64, lat float64, z int64) TileCoord {
+	n := float64(int64(1) << z)
+	lat = math.Max(-maxLatitude, math.Min(maxLatitude, lat))
+	latRad := lat * math.Pi / 180
+
+	x := int64(math.Floor((lon + 180) / 360 * n))
+	y := int64(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n))
+
+	max := int64(n) - 1
+	clamp := func(v int64) int64 {
+		if v < 0 {
+			return 0
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	return TileCoord{Z: z, X: clamp(x), Y: clamp(y)}
+}
+
+// tileBounds returns the bounds of the XYZ scheme tile c as [west, south,
+// east, north] in degrees.
+func tileBounds(c TileCoord) [4]float64 {
+	n := float64(int64(1) << c.Z)
+	lon := func(x int64) float64 { return float64(x)/n*360 - 180 }
+	lat := func(y int64) float64 { return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi }
+	return [4]float64{lon(c.X), lat(c.Y + 1), lon(c.X + 1), lat(c.Y)}
+}
+
+// tileRange returns the XYZ scheme tiles at the top left and bottom right of
+// the range of tiles at zoom level z that intersect bounds: [west, south, east,
+// north] in degrees.
+func tileRange(bounds []float64, z int64) (TileCoord, TileCoord, error) {
+	if err := validateBounds(bounds); err != nil {
+		return TileCoord{}, TileCoord{}, err
+	}
+	topLeft := TileCoordFromLonLat(bounds[0], bounds[3], z)
+	bottomRight := TileCoordFromLonLat(bounds[2], bounds[1], z)
+	return topLeft, bottomRight, nil
+}
+
+// validateBounds returns an error if bounds is not of the form [west, south,
+// east, north] in degrees.  Bounds that cross the antimeridian are not
+// supported.
+func validateBounds(bounds []float64) error {
+	if len(bounds) != 4 {
+		return fmt.Errorf("bounds %v must have 4 values: west, south, east, north", bounds)
+	}
+	if bounds[0] > bounds[2] || bounds[1] > bounds[3] {
+		return fmt.Errorf("bounds %v must have west <= east and south <= north", bounds)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package mbtiles
+
+import (
+	"testing"
+)
+
+func Test_ParseTilePath(t *testing.T) {
+	tests := []struct {
+		path   string
+		coord  TileCoord
+		format TileFormat
+	}{
+		{path: "12/654/1583.pbf", coord: TileCoord{Z: 12, X: 654, Y: 1583}, format: PBF},
+		{path: "/1/0/1.png", coord: TileCoord{Z: 1, X: 0, Y: 1}, format: PNG},
+		{path: "3/2/1.jpeg", coord: TileCoord{Z: 3, X: 2, Y: 1}, format: JPG},
+		{path: "0/0/0", coord: TileCoord{Z: 0, X: 0, Y: 0}, format: UNKNOWN},
+	}
+
+	for _, tc := range tests {
+		coord, format, err := ParseTilePath(tc.path)
+		if err != nil {
+			t.Error("Unexpected error parsing tile path:", tc.path, err)
+			continue
+		}
+		if coord != tc.coord {
+			t.Error("Tile coordinate", coord, "does not match expected value", tc.coord, "for:", tc.path)
+		}
+		if format != tc.format {
+			t.Error("Tile format", format, "does not match expected value", tc.format, "for:", tc.path)
+		}
+		if tc.format == UNKNOWN && coord.String() != tc.path {
+			t.Error("String", coord.String(), "does not match tile path", tc.path)
+		}
+	}
+}
+
+func Test_ParseTilePath_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"1/2",
+		"1/2/3/4",
+		"a/0/0",
+		"0/0/0.gif",
+		"1/2/0",
+		"1/0/-1",
+		"31/0/0",
+	}
+
+	for _, path := range tests {
+		if _, _, err := ParseTilePath(path); err == nil {
+			t.Error("Invalid tile path did not raise error:", path)
+		}
+	}
+}
+
+func Test_TileCoord_FlipY(t *testing.T) {
+	coord := TileCoord{Z: 2, X: 1, Y: 0}
+	flipped := coord.FlipY()
+	if flipped != (TileCoord{Z: 2, X: 1, Y: 3}) {
+		t.Error("FlipY returned unexpected value:", flipped)
+	}
+	if flipped.FlipY() != coord {
+		t.Error("FlipY is not reversible for:", coord)
+	}
+}
+
+func Test_TileCoord_Quadkey(t *testing.T) {
+	tests := []struct {
+		coord   TileCoord
+		quadkey string
+	}{
+		{coord: TileCoord{Z: 0, X: 0, Y: 0}, quadkey: ""},
+		{coord: TileCoord{Z: 1, X: 1, Y: 1}, quadkey: "3"},
+		// example from https://learn.microsoft.com/en-us/bingmaps/articles/bing-maps-tile-system
+		{coord: TileCoord{Z: 3, X: 3, Y: 5}, quadkey: "213"},
+	}
+
+	for _, tc := range tests {
+		if quadkey := tc.coord.Quadkey(); quadkey != tc.quadkey {
+			t.Error("Quadkey", quadkey, "does not match expected value", tc.quadkey, "for:", tc.coord)
+		}
+	}
+}
+
+func Test_TileCoord_ParentChildren(t *testing.T) {
+	root := TileCoord{Z: 0, X: 0, Y: 0}
+	if root.Parent() != root {
+		t.Error("Parent of zoom level 0 tile is not itself, got:", root.Parent())
+	}
+
+	coord := TileCoord{Z: 3, X: 5, Y: 2}
+	parent := coord.Parent()
+	if parent != (TileCoord{Z: 2, X: 2, Y: 1}) {
+		t.Error("Parent returned unexpected value:", parent)
+	}
+
+	found := false
+	for _, child := range parent.Children() {
+		if child.Parent() != parent {
+			t.Error("Parent of child", child, "is not", parent)
+		}
+		if child == coord {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Children of", parent, "do not include", coord)
+	}
+}
+
+func Test_TileCoordFromLonLat(t *testing.T) {
+	tests := []struct {
+		lon   float64
+		lat   float64
+		z     int64
+		coord TileCoord
+	}{
+		{lon: 0, lat: 0, z: 0, coord: TileCoord{Z: 0, X: 0, Y: 0}},
+		{lon: -90, lat: 45, z: 1, coord: TileCoord{Z: 1, X: 0, Y: 0}},
+		{lon: 90, lat: -45, z: 1, coord: TileCoord{Z: 1, X: 1, Y: 1}},
+		// outside projection bounds are clamped
+		{lon: 180, lat: -90, z: 2, coord: TileCoord{Z: 2, X: 3, Y: 3}},
+		{lon: -180, lat: 90, z: 2, coord: TileCoord{Z: 2, X: 0, Y: 0}},
+		// Portland, OR
+		{lon: -122.6765, lat: 45.5231, z: 12, coord: TileCoord{Z: 12, X: 652, Y: 1464}},
+	}
+
+	for _, tc := range tests {
+		if coord := TileCoordFromLonLat(tc.lon, tc.lat, tc.z); coord != tc.coord {
+			t.Error("Tile coordinate", coord, "does not match expected value", tc.coord, "for:", tc.lon, tc.lat, tc.z)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RejectOutsideCoverage makes reads of tiles outside of the coverage of the
+// tileset fail with ErrTileNotFound without querying the mbtiles file, which
+// saves most queries of servers of regional tilesets for global audiences.
+// The coverage is the range of zoom levels of tiles and the area of the
+// bounds metadata item, with a margin of one tile as bounds are often
+// rounded, and is read when the handle is initialized, by Reload, and after
+// tiles are written through the handle, such as by FillFromAncestors or
+// fallback write back.  Zoom levels synthesized by AncestorFallback are
+// covered.
+func RejectOutsideCoverage() OpenOption {
+	return func(o *openOptions) {
+		o.rejectOutside = true
+	}
+}
+
+// tileCoverage is the coverage of a tileset read for RejectOutsideCoverage.
+type tileCoverage struct {
+	empty            bool // true if the tileset has no tiles
+	minZoom, maxZoom int64
+	// ranges holds the range of columns and rows (TMS scheme) for each zoom
+	// level from minZoom, or is nil if the tileset does not have valid bounds.
+	ranges []coverageRange
+}
+
+// coverageRange is a range of columns and rows of tiles, inclusive.
+type coverageRange struct {
+	minX, maxX, minY, maxY int64
+}
+
+// readCoverage reads the coverage of the tileset from its tiles and bounds.
+func readCoverage(ctx context.Context, con querier) (*tileCoverage, error) {
+	coverage := &tileCoverage{}
+	err := con.QueryRowContext(ctx, "select zoom_level from tiles order by zoom_level limit 1").Scan(&coverage.minZoom)
+	if err == nil {
+		err = con.QueryRowContext(ctx, "select zoom_level from tiles order by zoom_level desc limit 1").Scan(&coverage.maxZoom)
+	}
+	if err == sql.ErrNoRows {
+		return &tileCoverage{empty: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var value string
+	err = con.QueryRowContext(ctx, "select value from metadata where name = 'bounds'").Scan(&value)
+	if err == sql.ErrNoRows {
+		return coverage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := ParseFloats(value)
+	if err != nil || validateBounds(bounds) != nil {
+		// invalid bounds do not limit coverage
+		return coverage, nil
+	}
+	for z := coverage.minZoom; z <= coverage.maxZoom; z++ {
+		topLeft, bottomRight, err := tileRange(bounds, z)
+		if err != nil {
+			return nil, err
+		}
+		last := int64(1)<<z - 1
+		// rows in the TMS scheme increase from bottom to top
+		coverage.ranges = append(coverage.ranges, coverageRange{
+			minX: maxInt64(topLeft.X-1, 0),
+			maxX: minInt64(bottomRight.X+1, last),
+			minY: maxInt64(last-bottomRight.Y-1, 0),
+			maxY: minInt64(last-topLeft.Y+1, last),
+		})
+	}
+	return coverage, nil
+}
+
+// contains returns true if the tile at coord (TMS scheme) is within the
+// coverage, or is a descendant up to fallbackLevels zoom levels below the
+// highest zoom level of a tile within it.
+func (c *tileCoverage) contains(coord TileCoord, fallbackLevels int) bool {
+	if c.empty || coord.Z < c.minZoom || coord.Z > c.maxZoom+int64(fallbackLevels) {
+		return false
+	}
+	if levels := coord.Z - c.maxZoom; levels > 0 {
+		coord = TileCoord{Z: c.maxZoom, X: coord.X >> levels, Y: coord.Y >> levels}
+	}
+	if c.ranges == nil {
+		return true
+	}
+	r := c.ranges[coord.Z-c.minZoom]
+	return coord.X >= r.minX && coord.X <= r.maxX && coord.Y >= r.minY && coord.Y <= r.maxY
+}
+
+// covers returns false if the handle was opened with RejectOutsideCoverage
+// and the tile at coord (TMS scheme) is outside of the coverage of the
+// tileset.
+func (db *MBtiles) covers(coord TileCoord) bool {
+	coverage, _ := db.loadState().coverage.Load().(*tileCoverage)
+	return coverage == nil || coverage.contains(coord, db.options.fallbackLevels)
+}
+
+// refreshCoverage reads the coverage again after tiles are written through
+// the handle, so that tiles at new zoom levels or outside the previous bounds
+// are not rejected.  If the coverage cannot be read, no tiles are rejected
+// until it is read again.
+func (db *MBtiles) refreshCoverage() {
+	state := db.loadState()
+	if state == uninitialized || state.closed {
+		return
+	}
+	coverage, err := readCoverage(context.TODO(), db.traced(db.pool))
+	if err != nil {
+		coverage = nil
+	}
+	state.coverage.Store(coverage)
+}
+
+// minInt64 returns the smaller of a and b.
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt64 returns the larger of a and b.
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package mbtiles
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func Test_RejectOutsideCoverage(t *testing.T) {
+	ctx := context.Background()
+	tile := uniformImage(color.NRGBA{R: 255, A: 255})
+	// tiles that contain 5, 5 at zoom levels 1 and 2
+	filename := createRasterTileset(t, map[string]string{"bounds": "4,4,6,6"}, map[TileCoord]image.Image{
+		{Z: 1, X: 1, Y: 1}: tile,
+		{Z: 2, X: 2, Y: 2}: tile,
+	})
+
+	var queries int
+	db, err := Open(filename, RejectOutsideCoverage(), AncestorFallback(1), TraceQueries(func(QueryTrace) { queries++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.GetTile(ctx, 2, 2, 2); err != nil {
+		t.Fatal("Could not read tile within coverage:", err)
+	}
+
+	queries = 0
+	for _, coord := range []TileCoord{
+		{Z: 0, X: 0, Y: 0}, // below the lowest zoom level
+		{Z: 4, X: 8, Y: 8}, // beyond the fallback levels
+		{Z: 2, X: 0, Y: 0}, // outside of the bounds
+	} {
+		if _, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y); err != ErrTileNotFound {
+			t.Error("Expected ErrTileNotFound for:", coord, err)
+		}
+		if _, err := db.OpenTile(ctx, coord.Z, coord.X, coord.Y); err != ErrTileNotFound {
+			t.Error("Expected ErrTileNotFound opening:", coord, err)
+		}
+	}
+	if queries != 0 {
+		t.Error("Expected no queries for tiles outside of coverage, got:", queries)
+	}
+
+	// tiles next to the bounds and from the fallback levels are read
+	if _, err := db.GetTile(ctx, 2, 3, 3); err != nil || queries == 0 {
+		t.Error("Expected tile within the margin to be queried, got:", queries, err)
+	}
+	if _, err := db.GetTile(ctx, 3, 5, 5); err != nil {
+		t.Error("Expected tile from fallback levels, got:", err)
+	}
+}
+
+func Test_RejectOutsideCoverage_write(t *testing.T) {
+	ctx := context.Background()
+	tile := uniformImage(color.NRGBA{R: 255, A: 255})
+	filename := createRasterTileset(t, map[string]string{"bounds": "4,4,6,6"}, map[TileCoord]image.Image{
+		{Z: 1, X: 1, Y: 1}: tile,
+		{Z: 2, X: 2, Y: 2}: tile,
+	})
+
+	db, err := Open(filename, RejectOutsideCoverage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.GetTile(ctx, 3, 4, 4); err != ErrTileNotFound {
+		t.Fatal("Expected ErrTileNotFound above the highest zoom level, got:", err)
+	}
+
+	// tiles written at a new zoom level are within the coverage
+	if filled, err := db.FillFromAncestors(ctx, 3); err != nil || filled == 0 {
+		t.Fatal("Expected tiles to be filled from ancestors, got:", filled, err)
+	}
+	if _, err := db.GetTile(ctx, 3, 4, 4); err != nil {
+		t.Error("Expected tile written at new zoom level to be read, got:", err)
+	}
+}
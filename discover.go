@@ -0,0 +1,128 @@
+package mbtiles
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// TilesetInfo describes an mbtiles file found by DiscoverTilesets.
+type TilesetInfo struct {
+	Path      string     // path to the mbtiles file
+	Name      string     // name from metadata, if present
+	Format    TileFormat // tile format detected from the first tile
+	Bounds    []float64  // bounds from metadata, if present: [west, south, east, north]
+	MinZoom   int        // minimum zoom from metadata or tiles
+	MaxZoom   int        // maximum zoom from metadata or tiles
+	Size      int64      // size of the file on disk in bytes
+	Timestamp time.Time  // modification time of the file
+	Err       error      // error encountered opening or reading the file, if any
+}
+
+// DiscoverTilesets recursively finds all mbtiles files within a given path
+// (see FindMBtiles), and briefly opens each to read the information needed to
+// describe it in a catalog.
+//
+// Files that cannot be opened or read are still returned, with Err set to the
+// error encountered.
+func DiscoverTilesets(path string, opts ...FindOption) ([]TilesetInfo, error) {
+	return DiscoverTilesetsContext(context.Background(), path, 1, opts...)
+}
+
+// DiscoverTilesetsContext is like DiscoverTilesets, but opens up to
+// concurrency files at a time.  Concurrency less than 1 is treated as 1.
+//
+// If ctx is canceled before all files are probed, the context's error is
+// returned.
+func DiscoverTilesetsContext(ctx context.Context, path string, concurrency int, opts ...FindOption) ([]TilesetInfo, error) {
+	filenames, err := FindMBtiles(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(filenames) {
+		concurrency = len(filenames)
+	}
+
+	// results are written by index to preserve the order of filenames
+	tilesets := make([]TilesetInfo, len(filenames))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				tilesets[i] = probeTileset(ctx, filenames[i])
+			}
+		}()
+	}
+
+dispatch:
+	for i := range filenames {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return tilesets, nil
+}
+
+// probeTileset opens the mbtiles file at path and reads its TilesetInfo.  If
+// ctx is done, the file is not opened and Err is set to the context's error.
+func probeTileset(ctx context.Context, path string) TilesetInfo {
+	info := TilesetInfo{Path: path}
+	if err := ctx.Err(); err != nil {
+		info.Err = err
+		return info
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		info.Err = err
+		return info
+	}
+	info.Size = stat.Size()
+	info.Timestamp = stat.ModTime().Round(time.Second)
+
+	db, err := OpenContext(ctx, path)
+	if err != nil {
+		info.Err = err
+		return info
+	}
+	defer db.Close()
+
+	info.Format = db.GetTileFormat()
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		info.Err = err
+		return info
+	}
+	if name, ok := metadata["name"].(string); ok {
+		info.Name = name
+	}
+	if bounds, ok := metadata["bounds"].([]float64); ok {
+		info.Bounds = bounds
+	}
+	if minZoom, ok := metadata["minzoom"].(int); ok {
+		info.MinZoom = minZoom
+	}
+	if maxZoom, ok := metadata["maxzoom"].(int); ok {
+		info.MaxZoom = maxZoom
+	}
+
+	return info
+}
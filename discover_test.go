@@ -0,0 +1,113 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_DiscoverTilesets(t *testing.T) {
+	tests := []struct {
+		path    string
+		name    string
+		format  TileFormat
+		minzoom int
+		maxzoom int
+	}{
+		{path: "testdata/geography-class-jpg.mbtiles", name: "Geography Class", format: JPG, minzoom: 0, maxzoom: 1},
+		{path: "testdata/geography-class-png.mbtiles", name: "Geography Class", format: PNG, minzoom: 0, maxzoom: 1},
+		{path: "testdata/world_cities.mbtiles", format: PBF, minzoom: 0, maxzoom: 6},
+	}
+
+	tilesets, err := DiscoverTilesets("./testdata")
+	if err != nil {
+		t.Fatal("Could not discover tilesets in testdata directory:", err)
+	}
+
+	byPath := make(map[string]TilesetInfo)
+	for _, tileset := range tilesets {
+		byPath[tileset.Path] = tileset
+	}
+
+	for _, tc := range tests {
+		tileset, ok := byPath[tc.path]
+		if !ok {
+			t.Error("Did not discover tileset:", tc.path)
+			continue
+		}
+		if tileset.Err != nil {
+			t.Error("Unexpected error discovering tileset:", tc.path, tileset.Err)
+			continue
+		}
+		if tc.name != "" && tileset.Name != tc.name {
+			t.Error("Name", tileset.Name, "does not match expected value", tc.name, "for:", tc.path)
+		}
+		if tileset.Format != tc.format {
+			t.Error("Tile format", tileset.Format, "does not match expected value", tc.format, "for:", tc.path)
+		}
+		if tileset.MinZoom != tc.minzoom || tileset.MaxZoom != tc.maxzoom {
+			t.Error("Zoom range", tileset.MinZoom, tileset.MaxZoom, "does not match expected values", tc.minzoom, tc.maxzoom, "for:", tc.path)
+		}
+		if len(tileset.Bounds) != 4 {
+			t.Error("Bounds not expected length for:", tc.path, "got:", tileset.Bounds)
+		}
+
+		stat, _ := os.Stat(tc.path)
+		if tileset.Size != stat.Size() {
+			t.Error("Size", tileset.Size, "does not match value from os.Stat for:", tc.path)
+		}
+		if tileset.Timestamp != stat.ModTime().Round(time.Second) {
+			t.Error("Timestamp", tileset.Timestamp, "does not match value from os.Stat for:", tc.path)
+		}
+	}
+
+	invalid, ok := byPath["testdata/invalid.mbtiles"]
+	if !ok {
+		t.Fatal("Did not discover invalid tileset")
+	}
+	if invalid.Err == nil {
+		t.Error("Invalid tileset was discovered without error")
+	}
+}
+
+func Test_DiscoverTilesetsContext(t *testing.T) {
+	expected, err := DiscoverTilesets("./testdata")
+	if err != nil {
+		t.Fatal("Could not discover tilesets in testdata directory:", err)
+	}
+
+	tilesets, err := DiscoverTilesetsContext(context.Background(), "./testdata", 4)
+	if err != nil {
+		t.Fatal("Could not concurrently discover tilesets in testdata directory:", err)
+	}
+	if len(tilesets) != len(expected) {
+		t.Fatal("Concurrent discovery returned", len(tilesets), "tilesets, expected", len(expected))
+	}
+	for i := range expected {
+		if tilesets[i].Path != expected[i].Path || tilesets[i].Format != expected[i].Format || tilesets[i].MaxZoom != expected[i].MaxZoom {
+			t.Error("Concurrent discovery result does not match sequential result for:", expected[i].Path)
+		}
+	}
+}
+
+func Test_DiscoverTilesetsContext_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := DiscoverTilesetsContext(ctx, "./testdata", 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Error("Canceled discovery did not return context.Canceled, got:", err)
+	}
+}
+
+func Test_probeTileset_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	info := probeTileset(ctx, "./testdata/world_cities.mbtiles")
+	if !errors.Is(info.Err, context.Canceled) {
+		t.Error("Probe with canceled context did not return context.Canceled, got:", info.Err)
+	}
+}
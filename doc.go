@@ -0,0 +1,23 @@
+// Package mbtiles reads and writes tilesets stored in mbtiles files, as
+// defined by the MBTiles specification, and serves their tiles.
+//
+// Open returns an MBtiles handle for an mbtiles file, configured by
+// OpenOption values such as CacheTiles or AncestorFallback.  Tiles are
+// addressed by zoom level, column, and row in the TMS scheme, as in the
+// mbtiles file; TileCoord converts coordinates between schemes and parses tile
+// paths.  Handles and the other sources of tiles of this package, such as
+// Overlay and Replicas, implement TileSource, and those that must be closed
+// implement Tileset.
+//
+// # Stability
+//
+// Starting with v1.0.0, the exported API of this package follows semantic
+// versioning: functions, types, options, and sentinel errors, such as
+// ErrTileNotFound, are not removed or changed incompatibly before v2.
+// Functions that are superseded are kept as thin wrappers with a Deprecated
+// notice naming their replacement, so that code can migrate incrementally:
+// for example, ReadTile is superseded by GetTile, which accepts a context and
+// returns ErrTileNotFound for missing tiles.  Errors returned by this package
+// should be compared to sentinel errors with errors.Is, as they may be
+// wrapped with more context.
+package mbtiles
@@ -0,0 +1,171 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// PartialExtension is the extension added to the destination path of
+// Download for the file being downloaded.
+const PartialExtension = ".part"
+
+// downloadAttempts is the number of times each chunk is requested by Download
+// before giving up.
+const downloadAttempts = 3
+
+// Download downloads the mbtiles file described by manifest from url to a new
+// file at dst, which must not already exist, using HTTP range requests for up
+// to concurrency chunks at a time.  If client is nil, http.DefaultClient is
+// used.
+//
+// The file is downloaded to dst with PartialExtension added, and each chunk is
+// verified against its checksum before it is written.  If a download is
+// interrupted, calling Download again resumes it: chunks already downloaded
+// are verified and skipped.  Once all chunks are downloaded, the checksum of
+// the whole file is verified and it is renamed to dst, ready to open.
+func Download(ctx context.Context, client *http.Client, manifest *ChunkManifest, url string, dst string, concurrency int) error {
+	if err := manifest.validate(); err != nil {
+		return fmt.Errorf("invalid chunk manifest: %w", err)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %q", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	partial := dst + PartialExtension
+	f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(manifest.Size); err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	pending := make(chan int)
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				if err := downloadChunk(ctx, client, manifest, url, f, i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range manifest.Chunks {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case pending <- i:
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	checksum, err := fileChecksum(partial)
+	if err != nil {
+		return err
+	}
+	if checksum != manifest.SHA256 {
+		// the chunks are valid, so the manifest does not match itself
+		return ErrChecksumMismatch
+	}
+	return os.Rename(partial, dst)
+}
+
+// downloadChunk downloads chunk i from url into f, unless f already has the
+// chunk, retrying up to downloadAttempts times.
+func downloadChunk(ctx context.Context, client *http.Client, manifest *ChunkManifest, url string, f *os.File, i int) error {
+	offset, length := manifest.ChunkRange(i)
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, offset); err == nil && manifest.VerifyChunk(i, data) {
+		return nil
+	}
+
+	var err error
+	for attempt := 0; attempt < downloadAttempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = fetchRange(ctx, client, url, offset, data); err != nil {
+			continue
+		}
+		if !manifest.VerifyChunk(i, data) {
+			err = fmt.Errorf("chunk %d does not match checksum", i)
+			continue
+		}
+		_, err = f.WriteAt(data, offset)
+		return err
+	}
+	return fmt.Errorf("could not download chunk %d: %w", i, err)
+}
+
+// fetchRange reads len(data) bytes at offset of the resource at url into data
+// using an HTTP range request.
+func fetchRange(ctx context.Context, client *http.Client, url string, offset int64, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(data))-1))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server ignored the range, so skip to the offset
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	_, err = io.ReadFull(resp.Body, data)
+	return err
+}
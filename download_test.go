@@ -0,0 +1,77 @@
+package mbtiles
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Download(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	manifest, err := NewChunkManifest(filename, 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastRange, _ := manifest.ChunkRange(len(manifest.Chunks) - 1)
+
+	var (
+		mu       sync.Mutex
+		requests int
+		fail     = true
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		failLast := fail && strings.HasPrefix(r.Header.Get("Range"), "bytes="+strconv.FormatInt(lastRange, 10)+"-")
+		mu.Unlock()
+		if failLast {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeFile(w, r, filename)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	dst := filepath.Join(t.TempDir(), "download.mbtiles")
+	if err := Download(ctx, server.Client(), manifest, server.URL, dst, 2); err == nil {
+		t.Fatal("Download did not fail when a chunk is unavailable")
+	}
+	if _, err := os.Stat(dst + PartialExtension); err != nil {
+		t.Fatal("Partial download was not kept:", err)
+	}
+
+	// resume, only requesting missing chunks
+	mu.Lock()
+	fail, requests = false, 0
+	mu.Unlock()
+	if err := Download(ctx, server.Client(), manifest, server.URL, dst, 2); err != nil {
+		t.Fatal("Could not resume download:", err)
+	}
+	if requests >= len(manifest.Chunks) {
+		t.Error("Resumed download requested all chunks:", requests)
+	}
+	if _, err := os.Stat(dst + PartialExtension); !os.IsNotExist(err) {
+		t.Error("Partial download was not renamed:", err)
+	}
+
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal("Could not open downloaded file:", err)
+	}
+	defer db.Close()
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || len(data) != 21246 {
+		t.Error("Could not read tile from downloaded file:", len(data), err)
+	}
+
+	if err := Download(ctx, server.Client(), manifest, server.URL, dst, 2); err == nil {
+		t.Error("Download did not fail for existing destination")
+	}
+}
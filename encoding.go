@@ -0,0 +1,70 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// minCompressedJSONSize is the size in bytes below which EncodeJSON does not
+// compress, since compression of small documents saves little.
+const minCompressedJSONSize = 1024
+
+// NegotiateEncoding returns the first of the supported content codings, such
+// as "gzip", that is acceptable according to the Accept-Encoding request
+// header acceptEncoding, or "identity" if none are acceptable.  Codings with a
+// quality value of 0 are not acceptable, and "*" matches any coding not listed
+// in the header.
+func NegotiateEncoding(acceptEncoding string, supported ...string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "identity", 0.0
+	for _, coding := range supported {
+		q, ok := qualities[coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// EncodeJSON encodes v, such as the result of Style or Catalog, as JSON for a
+// response to a request with the Accept-Encoding header acceptEncoding, and
+// returns the body along with the value of its Content-Encoding header: "gzip"
+// if the client accepts it and the JSON is large enough to benefit, or
+// "identity" otherwise.  Responses should also set "Vary: Accept-Encoding".
+func EncodeJSON(v interface{}, acceptEncoding string) ([]byte, string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) < minCompressedJSONSize || NegotiateEncoding(acceptEncoding, "gzip") != "gzip" {
+		return data, "identity", nil
+	}
+
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return compressed, "gzip", nil
+}
@@ -0,0 +1,54 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_NegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		supported      []string
+		expected       string
+	}{
+		{acceptEncoding: "", supported: []string{"gzip"}, expected: "identity"},
+		{acceptEncoding: "gzip, deflate, br", supported: []string{"gzip"}, expected: "gzip"},
+		{acceptEncoding: "GZIP", supported: []string{"gzip"}, expected: "gzip"},
+		{acceptEncoding: "gzip;q=0", supported: []string{"gzip"}, expected: "identity"},
+		{acceptEncoding: "*", supported: []string{"gzip"}, expected: "gzip"},
+		{acceptEncoding: "*;q=0.5, gzip;q=0", supported: []string{"gzip"}, expected: "identity"},
+		{acceptEncoding: "gzip;q=0.5, zstd", supported: []string{"gzip", "zstd"}, expected: "zstd"},
+		{acceptEncoding: "gzip, zstd", supported: []string{"gzip", "zstd"}, expected: "gzip"},
+	}
+
+	for _, tc := range tests {
+		if encoding := NegotiateEncoding(tc.acceptEncoding, tc.supported...); encoding != tc.expected {
+			t.Error("Encoding", encoding, "does not match expected value", tc.expected, "for:", tc.acceptEncoding)
+		}
+	}
+}
+
+func Test_EncodeJSON(t *testing.T) {
+	large := map[string]string{"description": strings.Repeat("tiles ", 1000)}
+	expected, _ := json.Marshal(large)
+
+	data, encoding, err := EncodeJSON(large, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "gzip" || len(data) >= len(expected) {
+		t.Error("Large JSON was not compressed:", encoding, len(data))
+	}
+	decompressed, err := gunzip(data)
+	if err != nil || string(decompressed) != string(expected) {
+		t.Error("Compressed JSON does not match expected value:", err)
+	}
+
+	if data, encoding, err := EncodeJSON(large, ""); err != nil || encoding != "identity" || string(data) != string(expected) {
+		t.Error("JSON was compressed for client that does not accept gzip:", encoding, err)
+	}
+	if _, encoding, err := EncodeJSON(map[string]string{"name": "small"}, "gzip"); err != nil || encoding != "identity" {
+		t.Error("Small JSON was compressed:", encoding, err)
+	}
+}
@@ -0,0 +1,74 @@
+package mbtiles
+
+import (
+	"context"
+	"strings"
+)
+
+// GetTileETag returns a strong ETag for the tile for z, x, y (TMS scheme),
+// including quotes, derived from its reference as described for GetTileRef,
+// without reading or hashing the tile data.  An empty string is returned if the
+// tile does not exist, and ErrNoTileRefs is returned for schemas without tile
+// references, for which handlers must hash the tile data instead.
+//
+// References that are not valid in an ETag are hashed.  For handles opened
+// with SharedTileIndex, the ETag is the MD5 hash of the tile data from the
+// index, for all schemas.
+func (db *MBtiles) GetTileETag(ctx context.Context, z int64, x int64, y int64) (string, error) {
+	if !db.isClosed() && db.options.tileIndex {
+		if err := db.init(ctx); err != nil {
+			return "", err
+		}
+		if etag, ok := db.indexedETag(TileCoord{Z: z, X: x, Y: y}); ok {
+			return etag, nil
+		}
+	}
+	ref, err := db.GetTileRef(ctx, z, x, y)
+	if err != nil || ref == "" {
+		return "", err
+	}
+	if !validETag(ref) {
+		ref = hashTile([]byte(ref))
+	}
+	return `"` + ref + `"`, nil
+}
+
+// ReadTileETag returns a strong ETag for the tile for z, x, y (TMS scheme) as
+// described for GetTileETag.
+//
+// Deprecated: use GetTileETag, which accepts a context.
+func (db *MBtiles) ReadTileETag(z int64, x int64, y int64) (string, error) {
+	return db.GetTileETag(context.Background(), z, x, y)
+}
+
+// ETagMatches returns true if etag matches any of the entity tags in the
+// If-None-Match request header ifNoneMatch, using weak comparison as required
+// for If-None-Match, in which case handlers should respond with 304 Not
+// Modified.
+func ETagMatches(ifNoneMatch string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// validETag returns true if all characters of value are allowed in an opaque
+// entity tag.
+func validETag(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '"' || c <= ' ' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
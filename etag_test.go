@@ -0,0 +1,62 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_ReadTileETag(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ref, err := db.ReadTileRef(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := db.ReadTileETag(0, 0, 0)
+	if err != nil || etag != `"`+ref+`"` {
+		t.Error("ETag does not match tile reference:", etag, ref, err)
+	}
+
+	if etag, err := db.GetTileETag(context.Background(), 0, 0, 0); err != nil || etag != `"`+ref+`"` {
+		t.Error("ETag read with context does not match tile reference:", etag, ref, err)
+	}
+
+	if etag, err := db.ReadTileETag(10, 0, 0); err != nil || etag != "" {
+		t.Error("Expected empty ETag for missing tile, got:", etag, err)
+	}
+
+	// references that are not valid in an ETag are hashed
+	if _, err := db.pool.Exec(`update map set tile_id = 'a "b"' where zoom_level = 1 and tile_column = 0 and tile_row = 0`); err != nil {
+		t.Fatal(err)
+	}
+	if etag, err := db.ReadTileETag(1, 0, 0); err != nil || etag != `"`+hashTile([]byte(`a "b"`))+`"` {
+		t.Error("Invalid reference was not hashed:", etag, err)
+	}
+}
+
+func Test_ETagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		etag        string
+		expected    bool
+	}{
+		{ifNoneMatch: `"abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"xyz", "abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `W/"abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `*`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"xyz"`, etag: `"abc"`, expected: false},
+		{ifNoneMatch: ``, etag: `"abc"`, expected: false},
+		{ifNoneMatch: `*`, etag: ``, expected: false},
+	}
+
+	for _, tc := range tests {
+		if matches := ETagMatches(tc.ifNoneMatch, tc.etag); matches != tc.expected {
+			t.Error("ETagMatches", matches, "does not match expected value", tc.expected, "for:", tc.ifNoneMatch, tc.etag)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ExtractZoom copies all tiles at zoom level zoom into a new mbtiles file at
+// dst, and returns the number of tiles copied.  dst must not already exist.
+//
+// Metadata is copied from this mbtiles file, with minzoom and maxzoom set to
+// zoom.  Tiles are written to a plain tiles table, regardless of the schema of
+// this mbtiles file.  Tiles are copied within SQLite by attaching dst, without
+// reading tile data into Go unless ctx has a transform (see
+// WithTileTransform).  The extract is written to a temporary file that
+// is only renamed to dst once it is complete, and removed if the extract fails.
+func (db *MBtiles) ExtractZoom(ctx context.Context, dst string, zoom int) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot extract tiles from closed mbtiles database")
+	}
+	if zoom < 0 || zoom > MaxZoomLevel {
+		return 0, fmt.Errorf("invalid zoom level %d", zoom)
+	}
+
+	z := strconv.Itoa(zoom)
+	var count int64
+	err := db.extract(ctx, dst, map[string]string{"minzoom": z, "maxzoom": z}, func(q querier) error {
+		result, err := q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) select zoom_level, tile_column, tile_row, tile_data from main.tiles where zoom_level = ?", zoom)
+		if err != nil {
+			return err
+		}
+		if count, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		progressFunc(ctx)(Progress{Phase: "extract", Current: count, Total: count})
+		return db.recordExtractHistory(ctx, q, "extract", map[string]interface{}{"zoom": zoom})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// extract creates a new mbtiles file at dst, which must not already exist,
+// with metadata copied from this mbtiles file except for the items in
+// metadata, which are set to the provided values.  copyTiles is then called to
+// copy tiles into the dst.tiles table, within a transaction of a connection
+// that has dst attached.  The file is published to dst as described for
+// publishFile.
+func (db *MBtiles) extract(ctx context.Context, dst string, metadata map[string]string, copyTiles func(q querier) error) error {
+	return publishFile(dst, func(tmp string) error {
+		return db.extractConn(ctx, tmp, metadata, copyTiles)
+	})
+}
+
+// extractConn performs extract on a single connection, since attached
+// databases are only visible to the connection that attached them.
+func (db *MBtiles) extractConn(ctx context.Context, dst string, metadata map[string]string, copyTiles func(q querier) error) error {
+	conn, err := db.pool.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	q := db.traced(conn)
+	if _, err := q.ExecContext(ctx, "attach database ? as dst", dst); err != nil {
+		return err
+	}
+	// detach with a new context so that the connection is returned to the
+	// pool without the attached database, even if ctx was canceled
+	defer q.ExecContext(context.Background(), "detach database dst")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	tq := db.traced(tx)
+
+	for _, query := range append(schemaStatements("dst."), "insert or replace into dst.metadata (name, value) select name, value from main.metadata") {
+		if _, err := tq.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	for name, value := range metadata {
+		if _, err := tq.ExecContext(ctx, "insert or replace into dst.metadata (name, value) values (?, ?)", name, value); err != nil {
+			return err
+		}
+	}
+
+	if err := copyTiles(tq); err != nil {
+		return err
+	}
+	if err := transformTiles(ctx, tq, "dst.tiles", db.options.blobStore); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createTileset creates a new mbtiles file at dst, which must not already
+// exist, with metadata, and calls writeTiles to write tiles into its tiles
+// table within a transaction.  The file is published to dst as described for
+// publishFile.
+func createTileset(ctx context.Context, dst string, metadata map[string]string, writeTiles func(q querier) error) error {
+	return publishFile(dst, func(tmp string) error {
+		return createTilesetTx(ctx, tmp, metadata, writeTiles)
+	})
+}
+
+// publishFile creates a new file at dst, which must not already exist, as
+// described for replaceFile.
+func publishFile(dst string, write func(tmp string) error) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %q", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return replaceFile(dst, write)
+}
+
+// replaceFile writes the file at dst by calling write to write it to an empty
+// temporary file next to dst, with PartialExtension added to its name.  The
+// temporary file is then synced and renamed to dst, replacing any existing
+// file, so that readers never see a partially written file at dst.  The
+// temporary file is removed if this fails.
+func replaceFile(dst string, write func(tmp string) error) error {
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-*"+PartialExtension)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	if err := publishTemp(tmp, dst, write); err != nil {
+		os.Remove(tmp)
+		os.Remove(tmp + "-journal")
+		return err
+	}
+	return nil
+}
+
+// publishTemp writes, syncs, and renames tmp to dst, as described for
+// replaceFile.
+func publishTemp(tmp string, dst string, write func(tmp string) error) error {
+	if err := write(tmp); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(tmp, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// createTilesetTx performs createTileset in a single transaction.
+func createTilesetTx(ctx context.Context, dst string, metadata map[string]string, writeTiles func(q querier) error) error {
+	pool, err := sql.Open("sqlite", dst)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range schemaStatements("") {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	for name, value := range metadata {
+		if _, err := tx.ExecContext(ctx, "insert into metadata (name, value) values (?, ?)", name, value); err != nil {
+			return err
+		}
+	}
+	if err := writeTiles(tx); err != nil {
+		return err
+	}
+	if err := transformTiles(ctx, tx, "tiles", nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ZoomSize is the number and total size of tiles at a zoom level.
+type ZoomSize struct {
+	Zoom  int
+	Tiles int64
+	Bytes int64 // total length of tile data
+}
+
+// EstimateExtract returns the number and total size of tiles that intersect
+// bounds: [west, south, east, north] in degrees, for each zoom level from
+// minZoom through maxZoom (inclusive).  This can be used to choose the zoom
+// levels of an extract, such as an offline pack, before it is made.  Sizes
+// exclude SQLite overhead, which is typically small relative to tile data.
+func (db *MBtiles) EstimateExtract(ctx context.Context, bounds []float64, minZoom int, maxZoom int) ([]ZoomSize, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot estimate extract from closed mbtiles database")
+	}
+	if err := validateBounds(bounds); err != nil {
+		return nil, err
+	}
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return nil, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+
+	q := db.traced(db.pool)
+	sizes := make([]ZoomSize, 0, maxZoom-minZoom+1)
+	for z := minZoom; z <= maxZoom; z++ {
+		topLeft, bottomRight, err := tileRange(bounds, int64(z))
+		if err != nil {
+			return nil, err
+		}
+		// tile rows are stored in the TMS scheme, so the range is flipped
+		minRow, maxRow := bottomRight.FlipY().Y, topLeft.FlipY().Y
+
+		size := ZoomSize{Zoom: z}
+		err = q.QueryRowContext(ctx,
+			"select count(*), coalesce(sum(length(tile_data)), 0) from tiles where zoom_level = ? and tile_column between ? and ? and tile_row between ? and ?",
+			z, topLeft.X, bottomRight.X, minRow, maxRow).Scan(&size.Tiles, &size.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// ExtractWithinBudget copies tiles that intersect bounds: [west, south, east,
+// north] in degrees into a new mbtiles file at dst, as described for
+// ExtractZoom, including as many zoom levels from minZoom through maxZoom as
+// fit within maxBytes of tile data, and returns the number and size of tiles
+// copied per zoom level.  dst must not already exist.
+//
+// Zoom levels are included in increasing order while all their tiles fit.  If
+// trim is true, tiles of the next zoom level are then included in order of
+// distance from the center of bounds, until the next tile would exceed the
+// budget.  Metadata is copied from this mbtiles file, with bounds, minzoom, and
+// maxzoom set to those of the extract.  An error is returned if no tiles fit.
+func (db *MBtiles) ExtractWithinBudget(ctx context.Context, dst string, bounds []float64, minZoom int, maxZoom int, maxBytes int64, trim bool) ([]ZoomSize, error) {
+	sizes, err := db.EstimateExtract(ctx, bounds, minZoom, maxZoom)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		included []ZoomSize
+		total    int64
+	)
+	for _, size := range sizes {
+		if total+size.Bytes > maxBytes {
+			break
+		}
+		included = append(included, size)
+		total += size.Bytes
+	}
+
+	var trimmed []TileCoord
+	trimmedZoom := -1
+	if trim && len(included) < len(sizes) {
+		size := ZoomSize{Zoom: sizes[len(included)].Zoom}
+		trimmed, size.Bytes, err = db.nearestTiles(ctx, bounds, size.Zoom, maxBytes-total)
+		if err != nil {
+			return nil, err
+		}
+		if len(trimmed) > 0 {
+			size.Tiles = int64(len(trimmed))
+			included = append(included, size)
+			trimmedZoom = size.Zoom
+		}
+	}
+	if len(included) == 0 {
+		return nil, fmt.Errorf("tiles at zoom level %d exceed the budget of %d bytes", minZoom, maxBytes)
+	}
+
+	metadata := map[string]string{
+		"bounds":  fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3]),
+		"minzoom": strconv.Itoa(included[0].Zoom),
+		"maxzoom": strconv.Itoa(included[len(included)-1].Zoom),
+	}
+	progress := progressFunc(ctx)
+	status := Progress{Phase: "extract"}
+	for _, size := range included {
+		status.Total += size.Tiles
+	}
+	err = db.extract(ctx, dst, metadata, func(q querier) error {
+		for _, size := range included {
+			status.Current += size.Tiles
+			status.Bytes += size.Bytes
+			if size.Zoom == trimmedZoom {
+				for _, coord := range trimmed {
+					_, err := q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) select zoom_level, tile_column, tile_row, tile_data from main.tiles where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y)
+					if err != nil {
+						return err
+					}
+				}
+				progress(status)
+				continue
+			}
+
+			topLeft, bottomRight, err := tileRange(bounds, int64(size.Zoom))
+			if err != nil {
+				return err
+			}
+			// tile rows are stored in the TMS scheme, so the range is flipped
+			minRow, maxRow := bottomRight.FlipY().Y, topLeft.FlipY().Y
+			_, err = q.ExecContext(ctx,
+				"insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) select zoom_level, tile_column, tile_row, tile_data from main.tiles where zoom_level = ? and tile_column between ? and ? and tile_row between ? and ?",
+				size.Zoom, topLeft.X, bottomRight.X, minRow, maxRow)
+			if err != nil {
+				return err
+			}
+			progress(status)
+		}
+		return db.recordExtractHistory(ctx, q, "extract", map[string]interface{}{
+			"bounds": bounds, "minzoom": minZoom, "maxzoom": maxZoom, "max_bytes": maxBytes, "trim": trim,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return included, nil
+}
+
+// nearestTiles returns the coordinates (TMS scheme) of tiles at zoom level z
+// that intersect bounds, in order of distance from the center of bounds, that
+// fit within maxBytes, along with their total size.
+func (db *MBtiles) nearestTiles(ctx context.Context, bounds []float64, z int, maxBytes int64) ([]TileCoord, int64, error) {
+	topLeft, bottomRight, err := tileRange(bounds, int64(z))
+	if err != nil {
+		return nil, 0, err
+	}
+	minRow, maxRow := bottomRight.FlipY().Y, topLeft.FlipY().Y
+	center := TileCoordFromLonLat((bounds[0]+bounds[2])/2, (bounds[1]+bounds[3])/2, int64(z)).FlipY()
+
+	rows, err := db.traced(db.pool).QueryContext(ctx,
+		"select tile_column, tile_row, length(tile_data) from tiles where zoom_level = ? and tile_column between ? and ? and tile_row between ? and ? order by max(abs(tile_column - ?), abs(tile_row - ?)), tile_column, tile_row",
+		z, topLeft.X, bottomRight.X, minRow, maxRow, center.X, center.Y)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		coords []TileCoord
+		total  int64
+	)
+	for rows.Next() {
+		coord := TileCoord{Z: int64(z)}
+		var size int64
+		if err := rows.Scan(&coord.X, &coord.Y, &size); err != nil {
+			return nil, 0, err
+		}
+		if total+size > maxBytes {
+			break
+		}
+		coords = append(coords, coord)
+		total += size
+	}
+	return coords, total, rows.Err()
+}
+
+// schemaStatements returns the statements to create the tables of an mbtiles
+// file, with tiles in a plain tiles table, in the schema with the given
+// prefix: "" for the main database, or the name of an attached database
+// followed by a dot.
+func schemaStatements(prefix string) []string {
+	return []string{
+		"create table " + prefix + "metadata (name text, value text)",
+		"create unique index " + prefix + "name on metadata (name)",
+		"create table " + prefix + "tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+		"create unique index " + prefix + "tile_index on tiles (zoom_level, tile_column, tile_row)",
+	}
+}
@@ -0,0 +1,190 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ExtractZoom(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dst := filepath.Join(t.TempDir(), "zoom3.mbtiles")
+	count, err := db.ExtractZoom(context.Background(), dst, 3)
+	if err != nil {
+		t.Fatal("Unexpected error extracting zoom level:", err)
+	}
+	if count != 17 {
+		t.Error("Expected 17 tiles extracted, got:", count)
+	}
+
+	extracted, err := Open(dst)
+	if err != nil {
+		t.Fatal("Could not open extracted file:", err)
+	}
+	defer extracted.Close()
+
+	metadata, err := extracted.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["minzoom"] != 3 || metadata["maxzoom"] != 3 {
+		t.Error("Zoom range does not match expected value, got:", metadata["minzoom"], metadata["maxzoom"])
+	}
+	if metadata["name"] != "Major cities from Natural Earth data" {
+		t.Error("Metadata was not copied, got name:", metadata["name"])
+	}
+
+	var expected, actual []byte
+	if err := db.ReadTile(3, 1, 4, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := extracted.ReadTile(3, 1, 4, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if expected == nil || !bytes.Equal(expected, actual) {
+		t.Error("Extracted tile does not match source tile")
+	}
+	if err := extracted.ReadTile(2, 0, 1, &actual); err != nil || actual != nil {
+		t.Error("Expected no tiles at other zoom levels")
+	}
+
+	// existing destination
+	if _, err := db.ExtractZoom(context.Background(), dst, 2); err == nil {
+		t.Error("Expected error extracting to existing destination")
+	}
+}
+
+func Test_ExtractZoom_deduplicated(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dst := filepath.Join(t.TempDir(), "zoom1.mbtiles")
+	count, err := db.ExtractZoom(context.Background(), dst, 1)
+	if err != nil {
+		t.Fatal("Unexpected error extracting zoom level:", err)
+	}
+	if count != 4 {
+		t.Error("Expected 4 tiles extracted, got:", count)
+	}
+	if _, err := os.Stat(dst); err != nil {
+		t.Error("Expected extracted file to exist:", err)
+	}
+}
+
+func Test_EstimateExtract(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sizes, err := db.EstimateExtract(context.Background(), []float64{-180, -85, 180, 85}, 0, 6)
+	if err != nil {
+		t.Fatal("Unexpected error estimating extract:", err)
+	}
+	expectedTiles := []int64{1, 4, 7, 17, 38, 57, 72}
+	if len(sizes) != len(expectedTiles) {
+		t.Fatal("Expected sizes for 7 zoom levels, got:", sizes)
+	}
+	var total int64
+	for i, size := range sizes {
+		if size.Zoom != i || size.Tiles != expectedTiles[i] {
+			t.Error("Size for zoom level", i, "does not match expected value, got:", size)
+		}
+		total += size.Bytes
+	}
+	if total != 18861 {
+		t.Error("Total size does not match expected value, got:", total)
+	}
+
+	// western hemisphere excludes most tiles
+	sizes, err = db.EstimateExtract(context.Background(), []float64{-180, -85, -1, 85}, 1, 1)
+	if err != nil {
+		t.Fatal("Unexpected error estimating extract:", err)
+	}
+	if len(sizes) != 1 || sizes[0].Tiles != 2 {
+		t.Error("Size for western hemisphere does not match expected value, got:", sizes)
+	}
+
+	if _, err := db.EstimateExtract(context.Background(), []float64{-180, -85, 180, 85}, 3, 2); err == nil {
+		t.Error("Expected error for invalid zoom range")
+	}
+}
+
+func Test_ExtractWithinBudget(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	bounds := []float64{-180, -85, 180, 85}
+	sizes, err := db.EstimateExtract(ctx, bounds, 0, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// budget for zoom levels 0 through 3, and part of zoom level 4
+	var budget int64
+	for _, size := range sizes[:4] {
+		budget += size.Bytes
+	}
+	budget += sizes[4].Bytes / 2
+
+	tests := []struct {
+		trim    bool
+		maxzoom int
+	}{
+		{trim: false, maxzoom: 3},
+		{trim: true, maxzoom: 4},
+	}
+	for _, tc := range tests {
+		dst := filepath.Join(t.TempDir(), "budget.mbtiles")
+		extracted, err := db.ExtractWithinBudget(ctx, dst, bounds, 0, 6, budget, tc.trim)
+		if err != nil {
+			t.Error("Unexpected error extracting within budget:", err)
+			continue
+		}
+		var total int64
+		for _, size := range extracted {
+			total += size.Bytes
+		}
+		if total > budget {
+			t.Error("Extracted", total, "bytes, exceeding budget", budget)
+		}
+		last := extracted[len(extracted)-1]
+		if last.Zoom != tc.maxzoom {
+			t.Error("Max zoom", last.Zoom, "does not match expected value", tc.maxzoom, "for trim:", tc.trim)
+		}
+		if tc.trim && (last.Tiles == 0 || last.Tiles >= sizes[4].Tiles) {
+			t.Error("Expected some tiles of zoom level 4, got:", last)
+		}
+
+		out, err := Open(dst)
+		if err != nil {
+			t.Fatal("Could not open extracted file:", err)
+		}
+		metadata, err := out.ReadMetadata()
+		out.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata["maxzoom"] != tc.maxzoom {
+			t.Error("maxzoom metadata does not match expected value, got:", metadata["maxzoom"])
+		}
+	}
+
+	if _, err := db.ExtractWithinBudget(ctx, filepath.Join(t.TempDir(), "empty.mbtiles"), bounds, 0, 6, 1, false); err == nil {
+		t.Error("Expected error when no tiles fit within budget")
+	}
+}
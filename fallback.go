@@ -0,0 +1,338 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// AncestorFallback makes GetTile synthesize tiles that do not exist in the
+// mbtiles file from the nearest ancestor tile up to levels zoom levels lower,
+// by cropping the ancestor tile to the area of the requested tile and
+// upscaling it using nearest-neighbor resampling.  This enables serving sparse
+// tile pyramids, where higher zoom levels only exist for some areas.
+//
+// Only PNG and JPG tilesets are supported; tiles in other formats are never
+// synthesized.
+func AncestorFallback(levels int) OpenOption {
+	return func(o *openOptions) {
+		o.fallbackLevels = levels
+	}
+}
+
+// FallbackWriteBack makes GetTile write tiles synthesized by AncestorFallback
+// to the mbtiles file, so that each tile is only synthesized once.  The mbtiles
+// file must be writable.
+func FallbackWriteBack() OpenOption {
+	return func(o *openOptions) {
+		o.fallbackWriteBack = true
+	}
+}
+
+// readFallbackTile synthesizes the tile for z, x, y (TMS scheme) from its
+// nearest ancestor.  Returns ErrTileNotFound if there is no ancestor tile
+// within the fallback levels, or the tileset format cannot be synthesized.
+func (db *MBtiles) readFallbackTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	format := db.loadState().format
+	if format != PNG && format != JPG {
+		return nil, ErrTileNotFound
+	}
+
+	for levels := int64(1); levels <= int64(db.options.fallbackLevels) && levels <= z; levels++ {
+		var ancestor []byte
+		err := db.queryTile(ctx, z-levels, x>>levels, y>>levels, &ancestor)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tile, err := synthesizeTile(format, ancestor, levels, x-(x>>levels)<<levels, y-(y>>levels)<<levels)
+		if err != nil {
+			return nil, fmt.Errorf("could not synthesize tile %d/%d/%d from ancestor: %v", z, x, y, err)
+		}
+
+		if db.options.fallbackWriteBack {
+			if err := db.insertTile(ctx, z, x, y, tile); err != nil {
+				return nil, fmt.Errorf("could not write synthesized tile %d/%d/%d: %v", z, x, y, err)
+			}
+		}
+
+		return tile, nil
+	}
+	return nil, ErrTileNotFound
+}
+
+// synthesizeTile crops the area of a descendant tile from ancestor, an
+// encoded image of the tile the given number of zoom levels lower, and
+// upscales it to the size of the ancestor.  dx and dy are the column and row
+// (TMS scheme) offsets of the descendant tile from the first descendant of the
+// ancestor at that zoom level.
+func synthesizeTile(format TileFormat, ancestor []byte, levels int64, dx int64, dy int64) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(ancestor))
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	width, height := int64(b.Dx()), int64(b.Dy())
+	// image rows start at the top, but TMS rows start at the bottom
+	row := (int64(1) << levels) - 1 - dy
+
+	dst := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	for py := int64(0); py < height; py++ {
+		sy := b.Min.Y + int((row*height+py)>>levels)
+		for px := int64(0); px < width; px++ {
+			sx := b.Min.X + int((dx*width+px)>>levels)
+			dst.Set(int(px), int(py), color.RGBAModel.Convert(src.At(sx, sy)))
+		}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case PNG:
+		err = png.Encode(&buf, dst)
+	case JPG:
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 90})
+	default:
+		err = fmt.Errorf("cannot encode tile format %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FillFromAncestors writes tiles synthesized from their nearest ancestor tile
+// (as described for AncestorFallback) for every tile at zoom that does not
+// exist but has an ancestor, and returns the number of tiles written.  This
+// gives clients without fallback logic full coverage at zoom.  All tiles are
+// written in a single transaction.
+//
+// Note that the number of tiles written grows by a factor of 4 for each zoom
+// level between zoom and the ancestor tiles.  Only PNG and JPG tilesets are
+// supported.
+func (db *MBtiles) FillFromAncestors(ctx context.Context, zoom int) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot fill tiles in closed mbtiles database")
+	}
+	format := db.GetTileFormat()
+	if format != PNG && format != JPG {
+		return 0, fmt.Errorf("cannot synthesize tiles in %v format", format)
+	}
+	if zoom < 1 || zoom > MaxZoomLevel {
+		return 0, fmt.Errorf("invalid zoom level %d", zoom)
+	}
+
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	q := db.traced(tx)
+	schema, err := db.writeSchema(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	z := int64(zoom)
+	covered := make(map[TileCoord]bool)
+	existing, err := readTileCoords(ctx, q, z)
+	if err != nil {
+		return 0, err
+	}
+	for _, coord := range existing {
+		covered[coord] = true
+	}
+
+	var filled int64
+	progress := progressFunc(ctx)
+	status := Progress{Phase: "fill"}
+	// closer ancestors take precedence over more distant ones
+	for ancestorZoom := z - 1; ancestorZoom >= 0; ancestorZoom-- {
+		ancestors, err := readTileCoords(ctx, q, ancestorZoom)
+		if err != nil {
+			return 0, err
+		}
+		levels := z - ancestorZoom
+		n := int64(1) << levels
+
+		for _, ancestor := range ancestors {
+			var data []byte
+			for dy := int64(0); dy < n; dy++ {
+				for dx := int64(0); dx < n; dx++ {
+					coord := TileCoord{Z: z, X: ancestor.X<<levels + dx, Y: ancestor.Y<<levels + dy}
+					if covered[coord] {
+						continue
+					}
+					if err := ctx.Err(); err != nil {
+						return 0, err
+					}
+
+					if data == nil {
+						err := q.QueryRowContext(ctx, "select tile_data from tiles where zoom_level = ? and tile_column = ? and tile_row = ?",
+							ancestor.Z, ancestor.X, ancestor.Y).Scan(&data)
+						if err != nil {
+							return 0, err
+						}
+						if err := db.resolveBlob(ctx, &data); err != nil {
+							return 0, err
+						}
+					}
+					tile, err := synthesizeTile(format, data, levels, dx, dy)
+					if err != nil {
+						return 0, fmt.Errorf("could not synthesize tile %v from ancestor: %v", coord, err)
+					}
+					stored, err := db.externalize(ctx, tile)
+					if err != nil {
+						return 0, err
+					}
+					if err := insertTileTx(ctx, q, schema, coord.Z, coord.X, coord.Y, tile, stored); err != nil {
+						return 0, err
+					}
+					covered[coord] = true
+					filled++
+					status.Current = filled
+					status.Bytes += int64(len(tile))
+					progress(status)
+				}
+			}
+		}
+	}
+
+	if filled > 0 {
+		if err := db.bumpVersion(ctx, q); err != nil {
+			return 0, err
+		}
+		if err := db.syncMetadata(ctx, q, schema); err != nil {
+			return 0, err
+		}
+		if err := db.recordHistory(ctx, q, "fill_from_ancestors", map[string]interface{}{"zoom": zoom, "filled": filled}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	db.tilesChanged()
+	return filled, nil
+}
+
+// readTileCoords reads the coordinates (TMS scheme) of all tiles at zoom
+// level z.
+func readTileCoords(ctx context.Context, q querier, z int64) ([]TileCoord, error) {
+	rows, err := q.QueryContext(ctx, "select tile_column, tile_row from tiles where zoom_level = ?", z)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coords []TileCoord
+	for rows.Next() {
+		coord := TileCoord{Z: z}
+		if err := rows.Scan(&coord.X, &coord.Y); err != nil {
+			return nil, err
+		}
+		coords = append(coords, coord)
+	}
+	return coords, rows.Err()
+}
+
+// insertTile inserts a tile for z, x, y (TMS scheme) into the mbtiles file, if
+// the tile does not already exist.
+func (db *MBtiles) insertTile(ctx context.Context, z int64, x int64, y int64, data []byte) error {
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := db.traced(tx)
+	schema, err := db.writeSchema(ctx, q)
+	if err != nil {
+		return err
+	}
+	stored, err := db.externalize(ctx, data)
+	if err != nil {
+		return err
+	}
+	if err := insertTileTx(ctx, q, schema, z, x, y, data, stored); err != nil {
+		return err
+	}
+	if err := db.bumpVersion(ctx, q); err != nil {
+		return err
+	}
+	if err := db.syncMetadata(ctx, q, schema); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.tilesChanged()
+	return nil
+}
+
+// insertTileTx inserts a tile within a transaction, as described for
+// insertTile.  stored is written in place of data, such as a reference to
+// data stored externally, or is data itself.  For deduplicated schemas, the
+// tile image is identified by the MD5 hash of data, which is also stored in
+// the tile_hash column if present.
+func insertTileTx(ctx context.Context, tx querier, schema tileSchema, z int64, x int64, y int64, data []byte, stored []byte) error {
+	tileHash := hashTile(data)
+
+	var (
+		result sql.Result
+		err    error
+	)
+	switch {
+	case schema.deduplicated:
+		if !bytes.Equal(stored, data) {
+			// an image of data stored before it was externalized is replaced
+			// by the reference
+			if _, err = tx.ExecContext(ctx, "update images set tile_data = ? where tile_id = ?", stored, tileHash); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, "insert into images (tile_data, tile_id) select ?, ? where not exists (select 1 from images where tile_id = ?)", stored, tileHash, tileHash)
+		if err != nil {
+			return err
+		}
+		if schema.hashed {
+			result, err = tx.ExecContext(ctx, "insert or ignore into map (zoom_level, tile_column, tile_row, tile_id, tile_hash) values (?, ?, ?, ?, ?)", z, x, y, tileHash, tileHash)
+		} else {
+			result, err = tx.ExecContext(ctx, "insert or ignore into map (zoom_level, tile_column, tile_row, tile_id) values (?, ?, ?, ?)", z, x, y, tileHash)
+		}
+	case schema.hashed:
+		result, err = tx.ExecContext(ctx, "insert or ignore into "+schema.table+" (zoom_level, tile_column, tile_row, tile_data, tile_hash) values (?, ?, ?, ?, ?)", z, x, y, stored, tileHash)
+	default:
+		result, err = tx.ExecContext(ctx, "insert or ignore into "+schema.table+" (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", z, x, y, stored)
+	}
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil || inserted == 0 {
+		return err
+	}
+	return logChange(ctx, tx, schema, ChangeWrite, TileCoord{Z: z, X: x, Y: y}, tileHash)
+}
+
+// writeTileTx writes a tile within a transaction, as described for
+// insertTileTx, replacing any existing tile.  For deduplicated schemas, the
+// caller must delete tile images that are no longer referenced.
+func writeTileTx(ctx context.Context, tx querier, schema tileSchema, z int64, x int64, y int64, data []byte, stored []byte) error {
+	_, err := tx.ExecContext(ctx, "delete from "+schema.table+" where zoom_level = ? and tile_column = ? and tile_row = ?", z, x, y)
+	if err != nil {
+		return err
+	}
+	return insertTileTx(ctx, tx, schema, z, x, y, data, stored)
+}
@@ -0,0 +1,176 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func Test_AncestorFallback(t *testing.T) {
+	tests := []struct {
+		path   string
+		levels int
+		z      int64
+		x      int64
+		y      int64
+		exists bool
+	}{
+		{path: "geography-class-png.mbtiles", levels: 1, z: 2, x: 0, y: 0, exists: true},
+		{path: "geography-class-png.mbtiles", levels: 1, z: 3, x: 0, y: 0, exists: false},
+		{path: "geography-class-png.mbtiles", levels: 2, z: 3, x: 7, y: 7, exists: true},
+		{path: "geography-class-jpg.mbtiles", levels: 1, z: 2, x: 3, y: 1, exists: true},
+		// webp tiles cannot be synthesized
+		{path: "geography-class-webp.mbtiles", levels: 1, z: 2, x: 0, y: 0, exists: false},
+	}
+
+	for _, tc := range tests {
+		db, err := Open("./testdata/"+tc.path, AncestorFallback(tc.levels))
+		if err != nil {
+			t.Fatal("Could not open:", tc.path, err)
+		}
+
+		var data []byte
+		err = db.ReadTile(tc.z, tc.x, tc.y, &data)
+		db.Close()
+		if err != nil {
+			t.Error("Unexpected error reading tile:", tc.path, tc.z, tc.x, tc.y, err)
+			continue
+		}
+		if (data != nil) != tc.exists {
+			t.Error("Tile", tc.z, tc.x, tc.y, "existence does not match expected value", tc.exists, "for:", tc.path)
+			continue
+		}
+		if data == nil {
+			continue
+		}
+
+		format, err := ParseTileFormat(data)
+		if err != nil || format != db.GetTileFormat() {
+			t.Error("Synthesized tile format", format, "does not match tileset format for:", tc.path)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width != 256 || cfg.Height != 256 {
+			t.Error("Synthesized tile is not a 256px image for:", tc.path, err)
+		}
+	}
+}
+
+func Test_AncestorFallback_pixels(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles", AncestorFallback(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var parentData, childData []byte
+	if err := db.ReadTile(1, 0, 0, &parentData); err != nil {
+		t.Fatal(err)
+	}
+	// 2/1/0 (TMS) is the bottom right quarter of 1/0/0
+	if err := db.ReadTile(2, 1, 0, &childData); err != nil {
+		t.Fatal(err)
+	}
+
+	parent, _, err := image.Decode(bytes.NewReader(parentData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, _, err := image.Decode(bytes.NewReader(childData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []image.Point{{0, 0}, {10, 20}, {255, 255}} {
+		expected := color.RGBAModel.Convert(parent.At(128+p.X/2, 128+p.Y/2))
+		actual := color.RGBAModel.Convert(child.At(p.X, p.Y))
+		if expected != actual {
+			t.Error("Synthesized pixel", p, actual, "does not match ancestor pixel", expected)
+		}
+	}
+}
+
+func Test_FallbackWriteBack(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+
+	db, err := Open(filename, AncestorFallback(1), FallbackWriteBack())
+	if err != nil {
+		t.Fatal("Could not open:", filename, err)
+	}
+	var synthesized []byte
+	err = db.ReadTile(2, 1, 2, &synthesized)
+	db.Close()
+	if err != nil || synthesized == nil {
+		t.Fatal("Could not synthesize tile:", err)
+	}
+
+	db, err = Open(filename)
+	if err != nil {
+		t.Fatal("Could not open:", filename, err)
+	}
+	defer db.Close()
+
+	var data []byte
+	if err := db.ReadTile(2, 1, 2, &data); err != nil {
+		t.Fatal("Unexpected error reading tile:", err)
+	}
+	if !bytes.Equal(data, synthesized) {
+		t.Error("Synthesized tile was not written back")
+	}
+}
+
+func Test_FillFromAncestors(t *testing.T) {
+	tests := []struct {
+		path   string
+		zoom   int
+		filled int64
+	}{
+		{path: "geography-class-png.mbtiles", zoom: 1, filled: 0},
+		{path: "geography-class-png.mbtiles", zoom: 2, filled: 16},
+		{path: "geography-class-jpg.mbtiles", zoom: 3, filled: 64},
+	}
+
+	for _, tc := range tests {
+		filename := copyTestdata(t, tc.path)
+		db, err := Open(filename)
+		if err != nil {
+			t.Fatal("Could not open:", filename, err)
+		}
+
+		var status Progress
+		filled, err := db.FillFromAncestors(WithProgress(context.Background(), func(p Progress) { status = p }), tc.zoom)
+		if err != nil {
+			t.Error("Unexpected error filling tiles for:", tc.path, tc.zoom, err)
+			db.Close()
+			continue
+		}
+		if filled != tc.filled {
+			t.Error("Filled", filled, "tiles, expected", tc.filled, "for:", tc.path, tc.zoom)
+		}
+		if status.Current != tc.filled || (tc.filled > 0 && (status.Phase != "fill" || status.Bytes == 0)) {
+			t.Error("Progress does not match expected values, got:", status, "for:", tc.path, tc.zoom)
+		}
+
+		var count int64
+		if err := db.pool.QueryRow("select count(*) from tiles where zoom_level = ?", tc.zoom).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != int64(1)<<(2*tc.zoom) {
+			t.Error("Zoom level", tc.zoom, "does not have full coverage after filling, found", count, "tiles for:", tc.path)
+		}
+		db.Close()
+	}
+}
+
+func Test_FillFromAncestors_unsupported(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.FillFromAncestors(context.Background(), 7); err == nil {
+		t.Error("Filling vector tiles did not raise error")
+	}
+}
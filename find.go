@@ -0,0 +1,219 @@
+package mbtiles
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FindOption configures how FindMBtiles and FindMBtilesFS search for mbtiles
+// files.
+type FindOption func(*findOptions)
+
+type findOptions struct {
+	followSymlinks bool
+	exclude        []string
+	maxDepth       int
+	journalPolicy  JournalPolicy
+}
+
+// FollowSymlinks makes the search descend into symlinked directories.  Symlink
+// cycles are detected and not followed.  Symlinked mbtiles files are found
+// with or without this option.
+func FollowSymlinks() FindOption {
+	return func(o *findOptions) {
+		o.followSymlinks = true
+	}
+}
+
+// Exclude skips any file or directory whose name or slash-separated path
+// relative to the search root matches one of the patterns.  Patterns use the
+// syntax of path.Match.
+func Exclude(patterns ...string) FindOption {
+	return func(o *findOptions) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// MaxDepth limits the number of directory levels below the search root that
+// are searched; 0 searches only the root directory.  A negative depth (the
+// default) is unlimited.
+func MaxDepth(depth int) FindOption {
+	return func(o *findOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// FindMBtiles recursively finds all mbtiles files within a given path.
+func FindMBtiles(root string, opts ...FindOption) ([]string, error) {
+	// fail fast with the same error as filepath.Walk if root does not exist
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		found, err := FindMBtilesFS(os.DirFS(filepath.Dir(root)), filepath.Base(root), opts...)
+		if err != nil || len(found) == 0 {
+			return nil, err
+		}
+		return []string{root}, nil
+	}
+
+	found, err := FindMBtilesFS(os.DirFS(root), ".", opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := make([]string, 0, len(found))
+	for _, p := range found {
+		filenames = append(filenames, filepath.Join(root, filepath.FromSlash(p)))
+	}
+	return filenames, nil
+}
+
+// FindMBtilesFS recursively finds all mbtiles files within root of the
+// provided file system.  Returned paths are slash-separated paths within fsys,
+// as used by the io/fs package.
+func FindMBtilesFS(fsys fs.FS, root string, opts ...FindOption) ([]string, error) {
+	o := findOptions{maxDepth: -1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &finder{fsys: fsys, root: root, opts: o}
+	if !info.IsDir() {
+		if w.isMBtiles(root) {
+			return []string{root}, nil
+		}
+		return nil, nil
+	}
+
+	err = w.walk(root, 0, []fs.FileInfo{info})
+	if err != nil {
+		return nil, err
+	}
+	return w.filenames, nil
+}
+
+// finder holds the state of a single FindMBtilesFS search.
+type finder struct {
+	fsys      fs.FS
+	root      string
+	opts      findOptions
+	filenames []string
+}
+
+// walk searches dir, which is at the given depth below the root.  parents
+// holds the directories from the root down to dir, and is used to detect
+// symlink cycles.
+func (w *finder) walk(dir string, depth int, parents []fs.FileInfo) error {
+	entries, err := fs.ReadDir(w.fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		if w.excluded(p) {
+			continue
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			info, err := fs.Stat(w.fsys, p)
+			if err != nil {
+				// ignore broken symlinks
+				continue
+			}
+			if info.IsDir() {
+				// symlinked files are always listed, but symlinked
+				// directories are only searched with FollowSymlinks
+				if !w.opts.followSymlinks || isCycle(info, parents) {
+					continue
+				}
+				if w.opts.maxDepth < 0 || depth < w.opts.maxDepth {
+					if err := w.walk(p, depth+1, append(parents, info)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+
+		if isDir {
+			if w.opts.maxDepth >= 0 && depth >= w.opts.maxDepth {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := w.walk(p, depth+1, append(parents, info)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if w.isMBtiles(p) {
+			w.filenames = append(w.filenames, p)
+		}
+	}
+	return nil
+}
+
+// isMBtiles returns true if p has a .mbtiles extension and no associated
+// -journal file, unless it is in WAL mode or the journal policy allows it.
+func (w *finder) isMBtiles(p string) bool {
+	if path.Ext(p) != ".mbtiles" {
+		return false
+	}
+	if w.opts.journalPolicy != JournalRefuse {
+		return true
+	}
+	// Ignore any that have an associated -journal file; these are incomplete
+	if _, err := fs.Stat(w.fsys, p+"-journal"); err == nil {
+		if wal, err := fsFileIsWAL(w.fsys, p); err != nil || !wal {
+			return false
+		}
+	}
+	return true
+}
+
+// excluded returns true if the name or the path relative to the search root
+// matches any of the exclude patterns.
+func (w *finder) excluded(p string) bool {
+	if len(w.opts.exclude) == 0 {
+		return false
+	}
+	rel := p
+	if w.root != "." {
+		rel = p[len(w.root)+1:]
+	}
+	name := path.Base(p)
+	for _, pattern := range w.opts.exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isCycle returns true if dir is the same directory as any of its parents.
+// This only detects cycles for file systems backed by the operating system.
+func isCycle(dir fs.FileInfo, parents []fs.FileInfo) bool {
+	for _, parent := range parents {
+		if os.SameFile(dir, parent) {
+			return true
+		}
+	}
+	return false
+}
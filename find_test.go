@@ -0,0 +1,143 @@
+package mbtiles
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_FindMBtiles_excludes_journal(t *testing.T) {
+	filenames, err := FindMBtiles("./testdata")
+	if err != nil {
+		t.Fatal("Could not list mbtiles files in testdata directory:", err)
+	}
+	for _, filename := range filenames {
+		if filename == "testdata/incomplete.mbtiles" {
+			t.Error("Listed mbtiles file with associated -journal file:", filename)
+		}
+	}
+}
+
+func Test_FindMBtiles_file(t *testing.T) {
+	filename := "./testdata/geography-class-png.mbtiles"
+	filenames, err := FindMBtiles(filename)
+	if err != nil {
+		t.Fatal("Could not list single mbtiles file:", err)
+	}
+	if len(filenames) != 1 || filenames[0] != filename {
+		t.Error("Did not list single mbtiles file, got:", filenames)
+	}
+}
+
+func Test_FindMBtilesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.mbtiles":                  {},
+		"b.txt":                      {},
+		"incomplete.mbtiles":         {},
+		"incomplete.mbtiles-journal": {},
+		"sub/c.mbtiles":              {},
+		"sub/deeper/d.mbtiles":       {},
+		"skip/e.mbtiles":             {},
+		"sub/f-draft.mbtiles":        {},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []FindOption
+		expected []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"a.mbtiles", "skip/e.mbtiles", "sub/c.mbtiles", "sub/deeper/d.mbtiles", "sub/f-draft.mbtiles"},
+		},
+		{
+			name:     "max depth 0",
+			opts:     []FindOption{MaxDepth(0)},
+			expected: []string{"a.mbtiles"},
+		},
+		{
+			name:     "max depth 1",
+			opts:     []FindOption{MaxDepth(1)},
+			expected: []string{"a.mbtiles", "skip/e.mbtiles", "sub/c.mbtiles", "sub/f-draft.mbtiles"},
+		},
+		{
+			name:     "exclude directory and name pattern",
+			opts:     []FindOption{Exclude("skip", "*-draft.mbtiles")},
+			expected: []string{"a.mbtiles", "sub/c.mbtiles", "sub/deeper/d.mbtiles"},
+		},
+		{
+			name:     "exclude relative path",
+			opts:     []FindOption{Exclude("sub/deeper")},
+			expected: []string{"a.mbtiles", "skip/e.mbtiles", "sub/c.mbtiles", "sub/f-draft.mbtiles"},
+		},
+	}
+
+	for _, tc := range tests {
+		filenames, err := FindMBtilesFS(fsys, ".", tc.opts...)
+		if err != nil {
+			t.Error("Unexpected error for:", tc.name, err)
+			continue
+		}
+		sort.Strings(filenames)
+		if !equalStrings(filenames, tc.expected) {
+			t.Error("Did not list expected mbtiles files for:", tc.name, "got:", filenames)
+		}
+	}
+}
+
+func Test_FindMBtiles_symlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	root := filepath.Join(dir, "root")
+	for _, d := range []string{target, root} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(target, "linked.mbtiles"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, "link")); err != nil {
+		t.Skip("Symlinks not supported:", err)
+	}
+	// cycle back to the root directory
+	if err := os.Symlink(root, filepath.Join(root, "link", "cycle")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(target, "linked.mbtiles"), filepath.Join(root, "file.mbtiles")); err != nil {
+		t.Fatal(err)
+	}
+
+	filenames, err := FindMBtiles(root)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	expected := []string{filepath.Join(root, "file.mbtiles")}
+	if !equalStrings(filenames, expected) {
+		t.Error("Did not list only symlinked file without FollowSymlinks option, got:", filenames)
+	}
+
+	filenames, err = FindMBtiles(root, FollowSymlinks())
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	sort.Strings(filenames)
+	expected = []string{filepath.Join(root, "file.mbtiles"), filepath.Join(root, "link", "linked.mbtiles")}
+	if !equalStrings(filenames, expected) {
+		t.Error("Did not list expected mbtiles files through symlinks, got:", filenames)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
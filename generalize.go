@@ -0,0 +1,206 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// FeatureFilter drops small features from vector tiles at low zoom levels, to
+// keep their tiles under size limits.  Sizes are in tile units of a 4096
+// extent, so that they do not depend on the extent of each layer.
+type FeatureFilter struct {
+	MaxZoom   int     // highest zoom level at which the filter applies
+	MinArea   float64 // polygons of smaller area are dropped; 0 keeps all polygons
+	MinLength float64 // lines of smaller total length are dropped; 0 keeps all lines
+}
+
+// ExtractGeneralized copies the vector tiles that intersect bounds: [west,
+// south, east, north] in degrees at zoom levels from minZoom through maxZoom
+// (inclusive) into a new mbtiles file at dst, dropping the features that are
+// smaller than filters, and returns the number of tiles written.  bounds may
+// be nil to copy tiles in all areas.  dst must not already exist.
+//
+// At each zoom level, the filter with the lowest MaxZoom at or above the zoom
+// level applies; tiles at higher zoom levels are copied unchanged.  The area
+// of polygons is that of their exterior rings less their holes, and the
+// length of lines is that of all of their parts.  Points are never dropped.
+// Layers without features are removed, and tiles without layers are not
+// written.  Filtered tiles are re-encoded, and gzip compressed if the source
+// tile is.  Metadata is copied from this mbtiles file, with bounds, minzoom,
+// and maxzoom set to those of the extract.  dst is removed if the operation
+// fails.
+func (db *MBtiles) ExtractGeneralized(ctx context.Context, dst string, bounds []float64, minZoom int, maxZoom int, filters []FeatureFilter) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot extract tiles from closed mbtiles database")
+	}
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return 0, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+	if bounds != nil {
+		if err := validateBounds(bounds); err != nil {
+			return 0, err
+		}
+	}
+	for _, filter := range filters {
+		if filter.MinArea < 0 || filter.MinLength < 0 {
+			return 0, fmt.Errorf("invalid feature filter %+v", filter)
+		}
+	}
+	if err := db.init(ctx); err != nil {
+		return 0, err
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return 0, fmt.Errorf("generalization is only supported for vector tilesets, not %v", format)
+	}
+
+	filters = append([]FeatureFilter(nil), filters...)
+	sort.SliceStable(filters, func(i, j int) bool { return filters[i].MaxZoom < filters[j].MaxZoom })
+
+	metadata := map[string]string{
+		"minzoom": strconv.Itoa(minZoom),
+		"maxzoom": strconv.Itoa(maxZoom),
+	}
+	if bounds != nil {
+		metadata["bounds"] = fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+
+	var count int64
+	progress := progressFunc(ctx)
+	status := Progress{Phase: "extract"}
+	err := db.extract(ctx, dst, metadata, func(q querier) error {
+		for z := minZoom; z <= maxZoom; z++ {
+			coords, err := readTileCoords(ctx, db.traced(db.pool), int64(z))
+			if err != nil {
+				return err
+			}
+			if bounds != nil {
+				topLeft, bottomRight, err := tileRange(bounds, int64(z))
+				if err != nil {
+					return err
+				}
+				// tile rows are stored in the TMS scheme, so the range is flipped
+				minRow, maxRow := bottomRight.FlipY().Y, topLeft.FlipY().Y
+				within := coords[:0]
+				for _, coord := range coords {
+					if coord.X >= topLeft.X && coord.X <= bottomRight.X && coord.Y >= minRow && coord.Y <= maxRow {
+						within = append(within, coord)
+					}
+				}
+				coords = within
+			}
+
+			var filter *FeatureFilter
+			for i := range filters {
+				if filters[i].MaxZoom >= z {
+					filter = &filters[i]
+					break
+				}
+			}
+			for _, coord := range coords {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				status.Current++
+				progress(status)
+				if filter == nil {
+					_, err = q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) select zoom_level, tile_column, tile_row, tile_data from main.tiles where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y)
+					if err != nil {
+						return err
+					}
+					count++
+					continue
+				}
+
+				layers, gzipped, err := db.readTileLayers(ctx, coord)
+				if err != nil {
+					return err
+				}
+				layers = filterFeatures(layers, *filter)
+				if len(layers) == 0 {
+					continue
+				}
+				data := encodeMVT(layers)
+				if gzipped {
+					if data, err = gzipBytes(data); err != nil {
+						return err
+					}
+				}
+				_, err = q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data)
+				if err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return db.recordExtractHistory(ctx, q, "generalize", map[string]interface{}{
+			"bounds": bounds, "minzoom": minZoom, "maxzoom": maxZoom, "filters": len(filters),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// filterFeatures returns layers without the features that are smaller than
+// filter, and without the layers that have no features left.
+func filterFeatures(layers []mvtLayer, filter FeatureFilter) []mvtLayer {
+	var filtered []mvtLayer
+	for _, layer := range layers {
+		// scale from the extent of the layer to 4096
+		scale := 4096.0
+		if layer.extent > 0 {
+			scale /= float64(layer.extent)
+		}
+
+		var features []mvtFeature
+		for _, feature := range layer.features {
+			switch feature.geomType {
+			case mvtPolygon:
+				if filter.MinArea > 0 && polygonArea(decodeGeometry(feature))*scale*scale < filter.MinArea {
+					continue
+				}
+			case mvtLineString:
+				if filter.MinLength > 0 && lineLength(decodeGeometry(feature))*scale < filter.MinLength {
+					continue
+				}
+			}
+			features = append(features, feature)
+		}
+		if len(features) > 0 {
+			layer.features = features
+			filtered = append(filtered, layer)
+		}
+	}
+	return filtered
+}
+
+// polygonArea returns the area of the rings of a decoded Polygon feature:
+// holes have the opposite winding order of exterior rings, so their area is
+// subtracted.
+func polygonArea(rings [][][2]int32) float64 {
+	var area float64
+	for _, ring := range rings {
+		for i := range ring {
+			a, b := ring[i], ring[(i+1)%len(ring)]
+			area += float64(a[0])*float64(b[1]) - float64(b[0])*float64(a[1])
+		}
+	}
+	return math.Abs(area) / 2
+}
+
+// lineLength returns the total length of the lines of a decoded LineString
+// feature.
+func lineLength(lines [][][2]int32) float64 {
+	var length float64
+	for _, line := range lines {
+		for i := 1; i < len(line); i++ {
+			length += math.Hypot(float64(line[i][0]-line[i-1][0]), float64(line[i][1]-line[i-1][1]))
+		}
+	}
+	return length
+}
@@ -0,0 +1,82 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ExtractGeneralized(t *testing.T) {
+	ctx := context.Background()
+	square := func(size int32) mvtFeature {
+		return mvtFeature{geomType: mvtPolygon, geometry: encodePolygonGeometry([][][2]int32{{{0, 0}, {size, 0}, {size, size}, {0, size}}})}
+	}
+	line := func(length int32) mvtFeature {
+		return mvtFeature{geomType: mvtLineString, geometry: encodeLineGeometry([][][2]int32{{{0, 0}, {length, 0}}})}
+	}
+	point := mvtFeature{geomType: mvtPoint, geometry: encodePointGeometry([][2]int32{{5, 5}})}
+	layer := func(name string, features ...mvtFeature) mvtLayer {
+		return mvtLayer{version: 2, name: name, extent: 4096, features: features}
+	}
+	filename := createVectorTileset(t, `{"vector_layers":[{"id":"land"},{"id":"roads"}]}`, map[TileCoord][]mvtLayer{
+		{Z: 0}:             {layer("land", square(5), square(100)), layer("roads", line(10), point)},
+		{Z: 1, X: 1, Y: 1}: {layer("land", square(5))},
+		{Z: 2, X: 1, Y: 1}: {layer("land", square(5))},
+	})
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dst := filepath.Join(t.TempDir(), "generalized.mbtiles")
+	filters := []FeatureFilter{{MaxZoom: 1, MinArea: 1000}, {MaxZoom: 0, MinArea: 100, MinLength: 50}}
+	count, err := db.ExtractGeneralized(ctx, dst, nil, 0, 2, filters)
+	if err != nil {
+		t.Fatal("Could not extract generalized tiles:", err)
+	}
+	// the tile at zoom level 1 has no features left
+	if count != 2 {
+		t.Error("Expected 2 tiles, got:", count)
+	}
+
+	generalized, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generalized.Close()
+	data, err := generalized.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := decodeTileMVT(data)
+	if err != nil || len(layers) != 2 || len(layers[0].features) != 1 || len(layers[1].features) != 1 || layers[1].features[0].geomType != mvtPoint {
+		t.Error("Expected large polygon and point, got:", layers, err)
+	}
+	if _, err := generalized.GetTile(ctx, 1, 1, 1); err != ErrTileNotFound {
+		t.Error("Expected tile without features to be dropped, got:", err)
+	}
+	// tiles above the zoom levels of filters are copied unchanged
+	expected, err := db.GetTile(ctx, 2, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := generalized.GetTile(ctx, 2, 1, 1); err != nil || string(data) != string(expected) {
+		t.Error("Expected unfiltered tile to be unchanged, got:", err)
+	}
+
+	if _, err := db.ExtractGeneralized(ctx, filepath.Join(t.TempDir(), "invalid.mbtiles"), nil, 0, 2, []FeatureFilter{{MinArea: -1}}); err == nil {
+		t.Error("Expected error for invalid filter")
+	}
+}
+
+func Test_polygonArea(t *testing.T) {
+	// a square with a square hole of opposite winding order
+	rings := [][][2]int32{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, {{2, 2}, {2, 4}, {4, 4}, {4, 2}}}
+	if area := polygonArea(rings); area != 96 {
+		t.Error("Area does not match expected value, got:", area)
+	}
+	if length := lineLength([][][2]int32{{{0, 0}, {3, 4}}, {{0, 0}, {0, 5}}}); length != 10 {
+		t.Error("Length does not match expected value, got:", length)
+	}
+}
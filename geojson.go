@@ -0,0 +1,730 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// geoJSONExtent is the extent of vector tiles written by TileGeoJSON.
+const geoJSONExtent = 4096
+
+// TileGeoJSONOption is an option for TileGeoJSON.
+type TileGeoJSONOption func(*tileGeoJSONOptions)
+
+type tileGeoJSONOptions struct {
+	layer      string
+	attributes []string // nil keeps all attributes
+	tolerance  float64
+	buffer     float64
+}
+
+// LayerName sets the name of the vector tile layer written by TileGeoJSON.
+// The default is "features".
+func LayerName(name string) TileGeoJSONOption {
+	return func(o *tileGeoJSONOptions) {
+		o.layer = name
+	}
+}
+
+// KeepAttributes makes TileGeoJSON only write the named feature properties as
+// attributes.  By default, all properties are written.
+func KeepAttributes(names ...string) TileGeoJSONOption {
+	return func(o *tileGeoJSONOptions) {
+		o.attributes = append([]string{}, names...)
+	}
+}
+
+// Simplify sets the tolerance of the simplification of lines and polygons by
+// TileGeoJSON, in tile units of a 4096 extent; 0 disables simplification.
+// The default is 1.
+func Simplify(tolerance float64) TileGeoJSONOption {
+	return func(o *tileGeoJSONOptions) {
+		o.tolerance = tolerance
+	}
+}
+
+// TileBuffer sets the size of the buffer around each tile in which geometries
+// are kept by TileGeoJSON, in tile units of a 4096 extent, so that lines and
+// polygons are rendered seamlessly across tile edges.  The default is 64.
+func TileBuffer(units float64) TileGeoJSONOption {
+	return func(o *tileGeoJSONOptions) {
+		o.buffer = units
+	}
+}
+
+// geoJSONObject is a GeoJSON FeatureCollection, Feature or geometry.
+type geoJSONObject struct {
+	Type        string                 `json:"type"`
+	Features    []geoJSONObject        `json:"features"`
+	Geometry    *geoJSONObject         `json:"geometry"`
+	Properties  map[string]interface{} `json:"properties"`
+	ID          interface{}            `json:"id"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+}
+
+// sourceFeature is a GeoJSON feature projected to world coordinates, where
+// the Web Mercator world spans 0 to 1 from west to east and north to south.
+type sourceFeature struct {
+	geomType uint32
+	// parts are the points of a Point feature as a single part of a single
+	// list, each line of a LineString feature as a part of a single list, or
+	// each polygon of a Polygon feature as a part of a list per ring, with the
+	// exterior ring first.  Rings do not repeat their first point.
+	parts      [][][][2]float64
+	id         uint64
+	hasID      bool
+	properties map[string]interface{}
+	bbox       [4]float64 // minX, minY, maxX, maxY in world coordinates
+}
+
+// TileGeoJSON creates a new mbtiles file at dst of vector tiles of the
+// features in features, a GeoJSON FeatureCollection or Feature in longitude
+// and latitude, for zoom levels minZoom through maxZoom (inclusive), and
+// returns the number of tiles written.  dst must not already exist.  Tiles
+// without features are not written.
+//
+// Features are written to a single layer, as Point, LineString or Polygon
+// features for the corresponding GeoJSON geometry types and their Multi
+// variants.  Lines and polygons are simplified for each zoom level and clipped
+// to a buffer around each tile.  Properties are written as attributes, with
+// nested objects and arrays encoded as JSON strings and null values omitted,
+// and integer ids are written as feature ids.  Tiles are gzip compressed.
+// Metadata is set with format pbf, the bounds of the features and json
+// describing the layer.  dst is removed if the operation fails.
+func TileGeoJSON(ctx context.Context, dst string, features io.Reader, minZoom int, maxZoom int, opts ...TileGeoJSONOption) (int64, error) {
+	options := tileGeoJSONOptions{layer: "features", tolerance: 1, buffer: 64}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.layer == "" {
+		return 0, fmt.Errorf("layer name must not be empty")
+	}
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return 0, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+
+	var collection geoJSONObject
+	if err := json.NewDecoder(features).Decode(&collection); err != nil {
+		return 0, fmt.Errorf("cannot parse GeoJSON: %w", err)
+	}
+	sources, bounds, err := readGeoJSONFeatures(collection, options.attributes)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := make(map[string]string)
+	for _, feature := range sources {
+		for key, value := range feature.properties {
+			fieldType := "Number"
+			switch value.(type) {
+			case string:
+				fieldType = "String"
+			case bool:
+				fieldType = "Boolean"
+			}
+			if previous, ok := fields[key]; ok && previous != fieldType {
+				fieldType = "Mixed"
+			}
+			fields[key] = fieldType
+		}
+	}
+	layerJSON, err := json.Marshal(map[string]interface{}{
+		"vector_layers": []interface{}{map[string]interface{}{
+			"id":      options.layer,
+			"fields":  fields,
+			"minzoom": minZoom,
+			"maxzoom": maxZoom,
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	metadata := map[string]string{
+		"name":    options.layer,
+		"format":  "pbf",
+		"minzoom": strconv.Itoa(minZoom),
+		"maxzoom": strconv.Itoa(maxZoom),
+		"json":    string(layerJSON),
+	}
+	if bounds != nil {
+		metadata["bounds"] = fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+
+	var count int64
+	err = createTileset(ctx, dst, metadata, func(q querier) error {
+		for z := int64(minZoom); z <= int64(maxZoom); z++ {
+			tiles, err := sliceFeatures(ctx, sources, z, options)
+			if err != nil {
+				return err
+			}
+			for coord, tile := range tiles {
+				data, err := gzipBytes(encodeMVT([]mvtLayer{*tile}))
+				if err != nil {
+					return err
+				}
+				coord = coord.FlipY()
+				_, err = q.ExecContext(ctx, "insert into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data)
+				if err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// readGeoJSONFeatures projects the features of a GeoJSON FeatureCollection or
+// Feature to world coordinates, keeping only the named properties if
+// attributes is not nil, and returns them with their bounds in degrees, or
+// nil bounds if there are no features with geometries.
+func readGeoJSONFeatures(collection geoJSONObject, attributes []string) ([]sourceFeature, []float64, error) {
+	var features []geoJSONObject
+	switch collection.Type {
+	case "FeatureCollection":
+		features = collection.Features
+	case "Feature":
+		features = []geoJSONObject{collection}
+	default:
+		return nil, nil, fmt.Errorf("unsupported GeoJSON type %q: must be a FeatureCollection or Feature", collection.Type)
+	}
+
+	var (
+		sources []sourceFeature
+		bounds  []float64
+	)
+	for i, feature := range features {
+		if feature.Type != "Feature" {
+			return nil, nil, fmt.Errorf("feature %d: unsupported GeoJSON type %q", i, feature.Type)
+		}
+		if feature.Geometry == nil {
+			continue
+		}
+		source, featureBounds, err := readGeoJSONGeometry(*feature.Geometry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		if source.parts == nil {
+			continue
+		}
+		if bounds == nil {
+			bounds = featureBounds
+		} else {
+			bounds[0], bounds[1] = math.Min(bounds[0], featureBounds[0]), math.Min(bounds[1], featureBounds[1])
+			bounds[2], bounds[3] = math.Max(bounds[2], featureBounds[2]), math.Max(bounds[3], featureBounds[3])
+		}
+
+		if id, ok := feature.ID.(float64); ok && id >= 0 && id == math.Trunc(id) && id < math.MaxUint64 {
+			source.id, source.hasID = uint64(id), true
+		}
+		source.properties = make(map[string]interface{})
+		for key, value := range feature.Properties {
+			if value == nil {
+				continue
+			}
+			source.properties[key] = value
+		}
+		if attributes != nil {
+			kept := make(map[string]interface{})
+			for _, name := range attributes {
+				if value, ok := source.properties[name]; ok {
+					kept[name] = value
+				}
+			}
+			source.properties = kept
+		}
+		for key, value := range source.properties {
+			switch v := value.(type) {
+			case string, bool:
+			case float64:
+				if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+					source.properties[key] = int64(v)
+				}
+			default:
+				// nested objects and arrays
+				encoded, err := json.Marshal(v)
+				if err != nil {
+					return nil, nil, err
+				}
+				source.properties[key] = string(encoded)
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources, bounds, nil
+}
+
+// readGeoJSONGeometry projects a GeoJSON geometry to world coordinates, and
+// returns it with its bounds in degrees.  The parts of the returned feature
+// are nil if the geometry is empty.
+func readGeoJSONGeometry(geometry geoJSONObject) (sourceFeature, []float64, error) {
+	var (
+		source sourceFeature
+		err    error
+		// nested is the list of polygons, each a list of rings
+		nested [][][][]float64
+	)
+	switch geometry.Type {
+	case "Point":
+		var point []float64
+		err = json.Unmarshal(geometry.Coordinates, &point)
+		source.geomType, nested = mvtPoint, [][][][]float64{{{point}}}
+	case "MultiPoint":
+		var points [][]float64
+		err = json.Unmarshal(geometry.Coordinates, &points)
+		source.geomType, nested = mvtPoint, [][][][]float64{{points}}
+	case "LineString":
+		var line [][]float64
+		err = json.Unmarshal(geometry.Coordinates, &line)
+		source.geomType, nested = mvtLineString, [][][][]float64{{line}}
+	case "MultiLineString":
+		var lines [][][]float64
+		err = json.Unmarshal(geometry.Coordinates, &lines)
+		source.geomType = mvtLineString
+		for _, line := range lines {
+			nested = append(nested, [][][]float64{line})
+		}
+	case "Polygon":
+		var rings [][][]float64
+		err = json.Unmarshal(geometry.Coordinates, &rings)
+		source.geomType, nested = mvtPolygon, [][][][]float64{rings}
+	case "MultiPolygon":
+		err = json.Unmarshal(geometry.Coordinates, &nested)
+		source.geomType = mvtPolygon
+	default:
+		return source, nil, fmt.Errorf("unsupported geometry type %q", geometry.Type)
+	}
+	if err != nil {
+		return source, nil, fmt.Errorf("invalid %s coordinates: %w", geometry.Type, err)
+	}
+
+	bounds := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	source.bbox = [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, part := range nested {
+		var projected [][][2]float64
+		for _, list := range part {
+			points := make([][2]float64, 0, len(list))
+			for _, position := range list {
+				if len(position) < 2 {
+					return source, nil, fmt.Errorf("invalid %s coordinates: position has fewer than 2 values", geometry.Type)
+				}
+				lon, lat := position[0], math.Max(-maxLatitude, math.Min(maxLatitude, position[1]))
+				bounds[0], bounds[1] = math.Min(bounds[0], lon), math.Min(bounds[1], lat)
+				bounds[2], bounds[3] = math.Max(bounds[2], lon), math.Max(bounds[3], lat)
+
+				x, y := lonLatToTileFraction(lon, lat, 0)
+				source.bbox[0], source.bbox[1] = math.Min(source.bbox[0], x), math.Min(source.bbox[1], y)
+				source.bbox[2], source.bbox[3] = math.Max(source.bbox[2], x), math.Max(source.bbox[3], y)
+				points = append(points, [2]float64{x, y})
+			}
+			if source.geomType == mvtPolygon && len(points) > 1 && points[0] == points[len(points)-1] {
+				points = points[:len(points)-1]
+			}
+			projected = append(projected, points)
+		}
+		source.parts = append(source.parts, projected)
+	}
+	if math.IsInf(bounds[0], 1) {
+		source.parts = nil
+	}
+	return source, bounds, nil
+}
+
+// sliceFeatures returns the vector tile layers of features at zoom z, by tile
+// coordinates (XYZ scheme).
+func sliceFeatures(ctx context.Context, features []sourceFeature, z int64, options tileGeoJSONOptions) (map[TileCoord]*mvtLayer, error) {
+	n := int64(1) << z
+	scale := float64(n) * geoJSONExtent
+	buffer := options.buffer / geoJSONExtent
+	tolerance := options.tolerance / scale
+
+	tiles := make(map[TileCoord]*mvtLayer)
+	keys := make(map[*mvtLayer]map[string]uint32)
+	values := make(map[*mvtLayer]map[interface{}]uint32)
+	for _, feature := range features {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		parts := feature.parts
+		if tolerance > 0 && feature.geomType != mvtPoint {
+			parts = simplifyParts(parts, feature.geomType, tolerance)
+		}
+
+		minX, maxX := tileIndex(feature.bbox[0]*float64(n)-buffer, n), tileIndex(feature.bbox[2]*float64(n)+buffer, n)
+		minY, maxY := tileIndex(feature.bbox[1]*float64(n)-buffer, n), tileIndex(feature.bbox[3]*float64(n)+buffer, n)
+		for ty := minY; ty <= maxY; ty++ {
+			for tx := minX; tx <= maxX; tx++ {
+				geometry := clipFeature(parts, feature.geomType, scale, tx, ty, options.buffer)
+				if geometry == nil {
+					continue
+				}
+
+				coord := TileCoord{Z: z, X: tx, Y: ty}
+				layer := tiles[coord]
+				if layer == nil {
+					layer = &mvtLayer{version: 2, name: options.layer, extent: geoJSONExtent}
+					tiles[coord] = layer
+					keys[layer] = make(map[string]uint32)
+					values[layer] = make(map[interface{}]uint32)
+				}
+
+				tile := mvtFeature{id: feature.id, hasID: feature.hasID, geomType: feature.geomType, geometry: geometry}
+				names := make([]string, 0, len(feature.properties))
+				for key := range feature.properties {
+					names = append(names, key)
+				}
+				sort.Strings(names)
+				for _, key := range names {
+					k, ok := keys[layer][key]
+					if !ok {
+						k = uint32(len(layer.keys))
+						keys[layer][key] = k
+						layer.keys = append(layer.keys, key)
+					}
+					value := feature.properties[key]
+					v, ok := values[layer][value]
+					if !ok {
+						v = uint32(len(layer.values))
+						values[layer][value] = v
+						layer.values = append(layer.values, value)
+					}
+					tile.tags = append(tile.tags, k, v)
+				}
+				layer.features = append(layer.features, tile)
+			}
+		}
+	}
+	return tiles, nil
+}
+
+// tileIndex returns the index of the tile containing v, a coordinate in tile
+// units at a zoom level with n tiles along each axis, within 0 to n-1.
+func tileIndex(v float64, n int64) int64 {
+	i := int64(math.Floor(v))
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// simplifyParts simplifies the lines or rings of parts using the
+// Douglas-Peucker algorithm with tolerance in world coordinates.  Rings that
+// are simplified to fewer than 3 points are dropped, as are polygons without
+// their exterior ring.
+func simplifyParts(parts [][][][2]float64, geomType uint32, tolerance float64) [][][][2]float64 {
+	var simplified [][][][2]float64
+	for _, part := range parts {
+		var lists [][][2]float64
+		for i, list := range part {
+			if geomType == mvtPolygon {
+				if len(list) < 3 {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+				// simplify the closed ring, so that its first point can be removed
+				ring := simplifyLine(append(list[:len(list):len(list)], list[0]), tolerance)
+				if len(ring) < 4 {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+				lists = append(lists, ring[:len(ring)-1])
+				continue
+			}
+			lists = append(lists, simplifyLine(list, tolerance))
+		}
+		if len(lists) > 0 {
+			simplified = append(simplified, lists)
+		}
+	}
+	return simplified
+}
+
+// simplifyLine simplifies line using the Douglas-Peucker algorithm, keeping
+// points that are further than tolerance from the simplified line.
+func simplifyLine(line [][2]float64, tolerance float64) [][2]float64 {
+	if len(line) < 3 {
+		return line
+	}
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	type span struct{ first, last int }
+	stack := []span{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, distance := -1, tolerance*tolerance
+		for i := s.first + 1; i < s.last; i++ {
+			if d := segmentDistanceSquared(line[i], line[s.first], line[s.last]); d > distance {
+				farthest, distance = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+		}
+	}
+
+	var simplified [][2]float64
+	for i, point := range line {
+		if keep[i] {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// segmentDistanceSquared returns the squared distance from p to the segment
+// from a to b.
+func segmentDistanceSquared(p [2]float64, a [2]float64, b [2]float64) float64 {
+	x, y := a[0], a[1]
+	dx, dy := b[0]-x, b[1]-y
+	if dx != 0 || dy != 0 {
+		t := ((p[0]-x)*dx + (p[1]-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b[0], b[1]
+		} else if t > 0 {
+			x, y = x+dx*t, y+dy*t
+		}
+	}
+	dx, dy = p[0]-x, p[1]-y
+	return dx*dx + dy*dy
+}
+
+// clipFeature transforms parts from world coordinates to the coordinates of
+// tile tx, ty, where the world spans scale units, clips them to buffer units
+// around the tile, and returns the encoded geometry, or nil if nothing remains
+// of the feature within the tile.
+func clipFeature(parts [][][][2]float64, geomType uint32, scale float64, tx int64, ty int64, buffer float64) []uint32 {
+	low, high := -buffer, geoJSONExtent+buffer
+	transform := func(list [][2]float64) [][2]float64 {
+		points := make([][2]float64, len(list))
+		for i, p := range list {
+			points[i] = [2]float64{p[0]*scale - float64(tx)*geoJSONExtent, p[1]*scale - float64(ty)*geoJSONExtent}
+		}
+		return points
+	}
+
+	switch geomType {
+	case mvtPoint:
+		var points [][2]int32
+		for _, p := range transform(parts[0][0]) {
+			if p[0] >= low && p[0] <= high && p[1] >= low && p[1] <= high {
+				points = append(points, quantizePoint(p))
+			}
+		}
+		if len(points) == 0 {
+			return nil
+		}
+		return encodePointGeometry(points)
+
+	case mvtLineString:
+		var lines [][][2]int32
+		for _, part := range parts {
+			for _, line := range clipLine(transform(part[0]), low, high) {
+				if quantized := quantizeLine(line); len(quantized) >= 2 {
+					lines = append(lines, quantized)
+				}
+			}
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		return encodeLineGeometry(lines)
+
+	default:
+		var rings [][][2]int32
+		for _, part := range parts {
+			for i, list := range part {
+				ring := quantizeLine(clipRing(transform(list), low, high))
+				if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+					ring = ring[:len(ring)-1]
+				}
+				area := ringArea(ring)
+				if len(ring) < 3 || area == 0 {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+				// exterior rings have a positive area in tile coordinates,
+				// and interior rings a negative area
+				if (i == 0) != (area > 0) {
+					for a, b := 0, len(ring)-1; a < b; a, b = a+1, b-1 {
+						ring[a], ring[b] = ring[b], ring[a]
+					}
+				}
+				rings = append(rings, ring)
+			}
+		}
+		if len(rings) == 0 {
+			return nil
+		}
+		return encodePolygonGeometry(rings)
+	}
+}
+
+// clipLine clips line to the square from low to high along each axis, and
+// returns the parts of the line within it.
+func clipLine(line [][2]float64, low float64, high float64) [][][2]float64 {
+	var (
+		lines   [][][2]float64
+		current [][2]float64
+	)
+	for i := 0; i+1 < len(line); i++ {
+		a, b, ok := clipSegment(line[i], line[i+1], low, high)
+		if !ok {
+			if len(current) > 0 {
+				lines, current = append(lines, current), nil
+			}
+			continue
+		}
+		if len(current) == 0 {
+			current = append(current, a)
+		} else if current[len(current)-1] != a {
+			lines, current = append(lines, current), [][2]float64{a}
+		}
+		current = append(current, b)
+		if b != line[i+1] {
+			lines, current = append(lines, current), nil
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// clipSegment clips the segment from a to b to the square from low to high
+// along each axis using the Liang-Barsky algorithm, and returns the clipped
+// segment, or false if it is outside the square.
+func clipSegment(a [2]float64, b [2]float64, low float64, high float64) ([2]float64, [2]float64, bool) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t0, t1 := 0.0, 1.0
+	for _, edge := range [][2]float64{{-dx, a[0] - low}, {dx, high - a[0]}, {-dy, a[1] - low}, {dy, high - a[1]}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return a, b, false
+			}
+			if t > t0 {
+				t0 = t
+			}
+		} else {
+			if t < t0 {
+				return a, b, false
+			}
+			if t < t1 {
+				t1 = t
+			}
+		}
+	}
+
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = [2]float64{a[0] + t0*dx, a[1] + t0*dy}
+	}
+	if t1 < 1 {
+		clippedB = [2]float64{a[0] + t1*dx, a[1] + t1*dy}
+	}
+	return clippedA, clippedB, true
+}
+
+// clipRing clips ring to the square from low to high along each axis using
+// the Sutherland-Hodgman algorithm.
+func clipRing(ring [][2]float64, low float64, high float64) [][2]float64 {
+	// each edge is the axis and bound, and whether points must be below it
+	edges := []struct {
+		axis  int
+		bound float64
+		below bool
+	}{{0, low, false}, {0, high, true}, {1, low, false}, {1, high, true}}
+
+	for _, edge := range edges {
+		if len(ring) == 0 {
+			return nil
+		}
+		inside := func(p [2]float64) bool {
+			if edge.below {
+				return p[edge.axis] <= edge.bound
+			}
+			return p[edge.axis] >= edge.bound
+		}
+		intersect := func(a, b [2]float64) [2]float64 {
+			t := (edge.bound - a[edge.axis]) / (b[edge.axis] - a[edge.axis])
+			return [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+		}
+
+		var clipped [][2]float64
+		previous := ring[len(ring)-1]
+		for _, p := range ring {
+			if inside(p) {
+				if !inside(previous) {
+					clipped = append(clipped, intersect(previous, p))
+				}
+				clipped = append(clipped, p)
+			} else if inside(previous) {
+				clipped = append(clipped, intersect(previous, p))
+			}
+			previous = p
+		}
+		ring = clipped
+	}
+	return ring
+}
+
+// quantizePoint rounds p to integer tile coordinates.
+func quantizePoint(p [2]float64) [2]int32 {
+	return [2]int32{int32(math.Round(p[0])), int32(math.Round(p[1]))}
+}
+
+// quantizeLine rounds the points of line to integer tile coordinates, and
+// removes repeated points.
+func quantizeLine(line [][2]float64) [][2]int32 {
+	var quantized [][2]int32
+	for _, p := range line {
+		q := quantizePoint(p)
+		if len(quantized) > 0 && quantized[len(quantized)-1] == q {
+			continue
+		}
+		quantized = append(quantized, q)
+	}
+	return quantized
+}
+
+// ringArea returns twice the signed area of ring, which is positive for
+// clockwise rings in tile coordinates, where y increases downwards.
+func ringArea(ring [][2]int32) int64 {
+	var area int64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		area += int64(p[0])*int64(q[1]) - int64(q[0])*int64(p[1])
+	}
+	return area
+}
@@ -0,0 +1,178 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGeoJSON = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "id": 1, "properties": {"name": "city", "population": 1000, "capital": true, "tags": ["a", "b"]},
+	 "geometry": {"type": "Point", "coordinates": [10, 10]}},
+	{"type": "Feature", "id": 2, "properties": {"name": "road", "width": 2.5, "note": null},
+	 "geometry": {"type": "LineString", "coordinates": [[-20, 5], [0, 5.001], [20, 5]]}},
+	{"type": "Feature", "id": 3, "properties": {"name": "park"},
+	 "geometry": {"type": "Polygon", "coordinates": [
+		[[-10, -10], [-10, 10], [10, 10], [10, -10], [-10, -10]],
+		[[-5, -5], [5, -5], [5, 5], [-5, 5], [-5, -5]]
+	 ]}},
+	{"type": "Feature", "properties": {"name": "nothing"}, "geometry": null}
+]}`
+
+func Test_TileGeoJSON(t *testing.T) {
+	ctx := context.Background()
+	dst := filepath.Join(t.TempDir(), "features.mbtiles")
+	count, err := TileGeoJSON(ctx, dst, strings.NewReader(testGeoJSON), 0, 2, LayerName("places"), KeepAttributes("name", "population", "tags"))
+	if err != nil {
+		t.Fatal("Could not tile GeoJSON:", err)
+	}
+	// 1 tile at zoom 0, and the 4 tiles around the origin at zooms 1 and 2
+	if count != 9 {
+		t.Error("TileGeoJSON did not write expected number of tiles:", count)
+	}
+
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.GetTileFormat() != PBF {
+		t.Error("Tiles are not PBF")
+	}
+	if errs, err := db.ValidateVectorTiles(ctx, 1, 1); err != nil || len(errs) != 0 {
+		t.Error("Tiles are not valid:", errs, err)
+	}
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["name"] != "places" || metadata["minzoom"] != 0 || metadata["maxzoom"] != 2 {
+		t.Error("Metadata is not as expected:", metadata)
+	}
+	layers, ok := metadata["vector_layers"].([]interface{})
+	if !ok || len(layers) != 1 {
+		t.Fatal("Metadata does not describe layer:", metadata)
+	}
+	fields := layers[0].(map[string]interface{})["fields"]
+	expectedFields := map[string]interface{}{"name": "String", "population": "Number", "tags": "String"}
+	if len(fields.(map[string]interface{})) != len(expectedFields) {
+		t.Error("Layer fields are not as expected:", fields)
+	}
+	for key, value := range expectedFields {
+		if fields.(map[string]interface{})[key] != value {
+			t.Error("Layer field is not as expected:", key, fields)
+		}
+	}
+
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || data == nil {
+		t.Fatal("Could not read tile:", err)
+	}
+	data, err = gunzip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeMVT(data)
+	if err != nil || len(decoded) != 1 {
+		t.Fatal("Could not decode tile:", err)
+	}
+	layer := decoded[0]
+	if layer.name != "places" || layer.extent != 4096 || len(layer.features) != 3 {
+		t.Fatal("Tile layer is not as expected:", layer.name, layer.extent, len(layer.features))
+	}
+
+	for i, expectedType := range []uint32{mvtPoint, mvtLineString, mvtPolygon} {
+		feature := layer.features[i]
+		if feature.geomType != expectedType || !feature.hasID || feature.id != uint64(i+1) {
+			t.Error("Feature is not as expected:", feature)
+		}
+	}
+	attributes := make(map[string]interface{})
+	for i := 0; i < len(layer.features[0].tags); i += 2 {
+		attributes[layer.keys[layer.features[0].tags[i]]] = layer.values[layer.features[0].tags[i+1]]
+	}
+	if len(attributes) != 3 || attributes["name"] != "city" || attributes["population"] != int64(1000) || attributes["tags"] != `["a","b"]` {
+		t.Error("Feature attributes are not as expected:", attributes)
+	}
+	// the line is simplified to its endpoints at zoom 0
+	if n := countCommands(layer.features[1].geometry, mvtLineTo); n != 1 {
+		t.Error("Line was not simplified:", layer.features[1].geometry)
+	}
+
+	rings := polygonRings(layer.features[2].geometry)
+	if len(rings) != 2 {
+		t.Fatal("Polygon does not have expected rings:", rings)
+	}
+	if ringArea(rings[0]) <= 0 || ringArea(rings[1]) >= 0 {
+		t.Error("Polygon rings do not have expected winding order:", rings)
+	}
+
+	// the polygon is clipped to each tile at zoom 1
+	if err := db.ReadTile(1, 0, 0, &data); err != nil || data == nil {
+		t.Fatal("Could not read tile:", err)
+	}
+	data, err = gunzip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = decodeMVT(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, feature := range decoded[0].features {
+		if feature.geomType != mvtPolygon {
+			continue
+		}
+		for _, ring := range polygonRings(feature.geometry) {
+			for _, p := range ring {
+				if p[0] < -64 || p[0] > 4096+64 || p[1] < -64 || p[1] > 4096+64 {
+					t.Error("Polygon is not clipped to tile buffer:", ring)
+				}
+			}
+		}
+	}
+
+	if _, err := TileGeoJSON(ctx, dst, strings.NewReader(testGeoJSON), 0, 2); err == nil {
+		t.Error("TileGeoJSON did not fail for existing destination")
+	}
+	invalid := filepath.Join(t.TempDir(), "invalid.mbtiles")
+	if _, err := TileGeoJSON(ctx, invalid, strings.NewReader(`{"type": "Feature", "geometry": {"type": "GeometryCollection"}}`), 0, 2); err == nil {
+		t.Error("TileGeoJSON did not fail for unsupported geometry type")
+	}
+}
+
+func Test_clipRing(t *testing.T) {
+	ring := [][2]float64{{-10, -10}, {10, -10}, {10, 10}, {-10, 10}}
+	clipped := quantizeLine(clipRing(ring, 0, 20))
+	if len(clipped) != 4 || ringArea(clipped) != 200 {
+		t.Error("Ring was not clipped as expected:", clipped)
+	}
+	if clipped := clipRing(ring, 20, 30); len(clipped) != 0 {
+		t.Error("Ring outside bounds was not removed:", clipped)
+	}
+}
+
+// polygonRings decodes the rings of Polygon geometry commands.
+func polygonRings(geometry []uint32) [][][2]int32 {
+	var (
+		rings [][][2]int32
+		x, y  int32
+	)
+	for i := 0; i < len(geometry); {
+		command, count := geometry[i]&7, int(geometry[i]>>3)
+		i++
+		if command == mvtClosePath {
+			continue
+		}
+		if command == mvtMoveTo {
+			rings = append(rings, nil)
+		}
+		for j := 0; j < count; j++ {
+			x += int32(geometry[i]>>1) ^ -int32(geometry[i]&1)
+			y += int32(geometry[i+1]>>1) ^ -int32(geometry[i+1]&1)
+			rings[len(rings)-1] = append(rings[len(rings)-1], [2]int32{x, y})
+			i += 2
+		}
+	}
+	return rings
+}
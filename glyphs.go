@@ -0,0 +1,148 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrGlyphsNotFound is returned by ReadGlyphs if the glyph range is not
+// stored in the mbtiles file.
+var ErrGlyphsNotFound = errors.New("glyphs not found")
+
+// ErrSpriteNotFound is returned by ReadSprite if the sprite file is not
+// stored in the mbtiles file.
+var ErrSpriteNotFound = errors.New("sprite not found")
+
+// Tables of glyphs and sprites stored alongside tiles.  These extend the
+// mbtiles schema, and are ignored by other readers.
+const (
+	glyphsTable  = "create table if not exists glyphs (fontstack text, range text, data blob)"
+	glyphsIndex  = "create unique index if not exists glyphs_index on glyphs (fontstack, range)"
+	spritesTable = "create table if not exists sprites (name text, data blob)"
+	spritesIndex = "create unique index if not exists sprites_index on sprites (name)"
+)
+
+// WriteGlyphs stores the glyph range of fontstack, such as 0-255, in the
+// mbtiles file, replacing any existing glyphs of the range, so that a single
+// file holds everything a vector map needs.  data is the PBF glyph range
+// served at the MapLibre glyphs URL {fontstack}/{range}.pbf.  The glyphs
+// table is created by the first write.
+func (db *MBtiles) WriteGlyphs(ctx context.Context, fontstack string, glyphRange string, data []byte) error {
+	if db.isClosed() {
+		return errors.New("cannot write glyphs to closed mbtiles database")
+	}
+	return db.writeResource(ctx, []string{glyphsTable, glyphsIndex},
+		"insert or replace into glyphs (fontstack, range, data) values (?, ?, ?)", fontstack, glyphRange, data)
+}
+
+// ReadGlyphs returns the glyph range of fontstack stored with WriteGlyphs.
+// ErrGlyphsNotFound is returned if the range is not stored.
+func (db *MBtiles) ReadGlyphs(ctx context.Context, fontstack string, glyphRange string) ([]byte, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read glyphs from closed mbtiles database")
+	}
+	data, err := db.readResource(ctx, "glyphs", "select data from glyphs where fontstack = ? and range = ?", fontstack, glyphRange)
+	if err == sql.ErrNoRows {
+		return nil, ErrGlyphsNotFound
+	}
+	return data, err
+}
+
+// Fontstacks returns the names of the font stacks with glyphs stored in the
+// mbtiles file, in order.
+func (db *MBtiles) Fontstacks(ctx context.Context) ([]string, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read glyphs from closed mbtiles database")
+	}
+	q := db.traced(db.pool)
+	if exists, err := tableExists(ctx, q, "glyphs"); err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, "select distinct fontstack from glyphs order by fontstack")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fontstacks []string
+	for rows.Next() {
+		var fontstack string
+		if err := rows.Scan(&fontstack); err != nil {
+			return nil, err
+		}
+		fontstacks = append(fontstacks, fontstack)
+	}
+	return fontstacks, rows.Err()
+}
+
+// WriteSprite stores the sprite file name, such as sprite.json or
+// sprite@2x.png, in the mbtiles file, replacing any existing file of that
+// name.  The sprites table is created by the first write.
+func (db *MBtiles) WriteSprite(ctx context.Context, name string, data []byte) error {
+	if db.isClosed() {
+		return errors.New("cannot write sprite to closed mbtiles database")
+	}
+	return db.writeResource(ctx, []string{spritesTable, spritesIndex},
+		"insert or replace into sprites (name, data) values (?, ?)", name, data)
+}
+
+// ReadSprite returns the sprite file name stored with WriteSprite.
+// ErrSpriteNotFound is returned if the file is not stored.
+func (db *MBtiles) ReadSprite(ctx context.Context, name string) ([]byte, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read sprite from closed mbtiles database")
+	}
+	data, err := db.readResource(ctx, "sprites", "select data from sprites where name = ?", name)
+	if err == sql.ErrNoRows {
+		return nil, ErrSpriteNotFound
+	}
+	return data, err
+}
+
+// writeResource creates the tables of a resource if needed and stores it in a
+// single transaction.
+func (db *MBtiles) writeResource(ctx context.Context, tables []string, query string, args ...interface{}) error {
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := db.traced(tx)
+	for _, table := range tables {
+		if _, err := q.ExecContext(ctx, table); err != nil {
+			return err
+		}
+	}
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// readResource reads a resource from table, returning sql.ErrNoRows if it or
+// the table does not exist.
+func (db *MBtiles) readResource(ctx context.Context, table string, query string, args ...interface{}) ([]byte, error) {
+	q := db.traced(db.pool)
+	exists, err := tableExists(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	var data []byte
+	if err := q.QueryRowContext(ctx, query, args...).Scan(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// tableExists returns true if the named table exists.
+func tableExists(ctx context.Context, q querier, name string) (bool, error) {
+	var count int
+	err := q.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = ?", name).Scan(&count)
+	return count > 0, err
+}
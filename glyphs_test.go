@@ -0,0 +1,72 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func Test_Glyphs(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ReadGlyphs(ctx, "Noto Sans Regular", "0-255"); err != ErrGlyphsNotFound {
+		t.Error("Expected ErrGlyphsNotFound without glyphs table, got:", err)
+	}
+	if fontstacks, err := db.Fontstacks(ctx); err != nil || len(fontstacks) != 0 {
+		t.Error("Expected no font stacks, got:", fontstacks, err)
+	}
+
+	for _, data := range [][]byte{[]byte("old"), []byte("glyphs")} {
+		if err := db.WriteGlyphs(ctx, "Noto Sans Regular", "0-255", data); err != nil {
+			t.Fatal("Could not write glyphs:", err)
+		}
+	}
+	if err := db.WriteGlyphs(ctx, "Noto Sans Bold", "0-255", []byte("bold")); err != nil {
+		t.Fatal("Could not write glyphs:", err)
+	}
+	data, err := db.ReadGlyphs(ctx, "Noto Sans Regular", "0-255")
+	if err != nil || !bytes.Equal(data, []byte("glyphs")) {
+		t.Error("Glyphs do not match expected value, got:", string(data), err)
+	}
+	if _, err := db.ReadGlyphs(ctx, "Noto Sans Regular", "256-511"); err != ErrGlyphsNotFound {
+		t.Error("Expected ErrGlyphsNotFound for missing range, got:", err)
+	}
+	fontstacks, err := db.Fontstacks(ctx)
+	if err != nil || len(fontstacks) != 2 || fontstacks[0] != "Noto Sans Bold" {
+		t.Error("Font stacks do not match expected value, got:", fontstacks, err)
+	}
+}
+
+func Test_Sprites(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ReadSprite(ctx, "sprite.json"); err != ErrSpriteNotFound {
+		t.Error("Expected ErrSpriteNotFound without sprites table, got:", err)
+	}
+	if err := db.WriteSprite(ctx, "sprite.json", []byte("{}")); err != nil {
+		t.Fatal("Could not write sprite:", err)
+	}
+	data, err := db.ReadSprite(ctx, "sprite.json")
+	if err != nil || !bytes.Equal(data, []byte("{}")) {
+		t.Error("Sprite does not match expected value, got:", string(data), err)
+	}
+	if _, err := db.ReadSprite(ctx, "sprite.png"); err != ErrSpriteNotFound {
+		t.Error("Expected ErrSpriteNotFound for missing sprite, got:", err)
+	}
+
+	// tiles can still be read by other readers
+	var tile []byte
+	if err := db.ReadTile(0, 0, 0, &tile); err != nil {
+		t.Error("Could not read tile:", err)
+	}
+}
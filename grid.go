@@ -0,0 +1,266 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// tileGridKey is the metadata item that identifies or defines the tile
+	// grid of tilesets that do not use the Web Mercator grid.
+	tileGridKey = "tile_matrix_set"
+	// WorldCRS84QuadID is the ID of the WGS84 tile matrix set, as defined by OGC
+	// Two Dimensional Tile Matrix Set.
+	WorldCRS84QuadID = "WorldCRS84Quad"
+	// crs84 is the URI of the WGS84 longitude, latitude coordinate reference
+	// system.
+	crs84 = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+)
+
+// TileGrid describes the tile matrix set of a tileset: the extent of the
+// coordinate reference system that is tiled, and the number of tiles across
+// it at zoom level 0, which doubles along each axis at each zoom level.
+// Tiles are numbered from the top left corner of the extent.
+type TileGrid struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	// URI is the URI of a well-known tile matrix set, if any.
+	URI string `json:"uri,omitempty"`
+	// CRS is the URI of the coordinate reference system.
+	CRS string `json:"crs"`
+	// Extent is [minX, minY, maxX, maxY] in units of the coordinate reference
+	// system, with X as easting or longitude and Y as northing or latitude.
+	Extent       [4]float64 `json:"extent"`
+	MatrixWidth  int64      `json:"matrixWidth"`
+	MatrixHeight int64      `json:"matrixHeight"`
+	TileSize     int        `json:"tileSize"`
+}
+
+// WebMercatorGrid is the Web Mercator tile grid used by mbtiles files by
+// default.
+var WebMercatorGrid = TileGrid{
+	ID:           WebMercatorQuadID,
+	Title:        "Google Maps Compatible for the World",
+	URI:          webMercatorQuadURI,
+	CRS:          webMercatorCRS,
+	Extent:       [4]float64{-mercatorOrigin, -mercatorOrigin, mercatorOrigin, mercatorOrigin},
+	MatrixWidth:  1,
+	MatrixHeight: 1,
+	TileSize:     256,
+}
+
+// WorldCRS84Grid is the WGS84 tile grid, where zoom level 0 has 2 tiles
+// covering the western and eastern hemispheres.
+var WorldCRS84Grid = TileGrid{
+	ID:           WorldCRS84QuadID,
+	Title:        "CRS84 for the World",
+	URI:          "http://www.opengis.net/def/tilematrixset/OGC/1.0/WorldCRS84Quad",
+	CRS:          crs84,
+	Extent:       [4]float64{-180, -90, 180, 90},
+	MatrixWidth:  2,
+	MatrixHeight: 1,
+	TileSize:     256,
+}
+
+// MatrixSize returns the number of tile columns and rows at zoom level z.
+func (g TileGrid) MatrixSize(z int64) (int64, int64) {
+	return g.MatrixWidth << z, g.MatrixHeight << z
+}
+
+// FlipY returns the tile coordinates with the row flipped between the XYZ
+// and TMS schemes, for the number of rows of the grid at the zoom level.
+func (g TileGrid) FlipY(c TileCoord) TileCoord {
+	_, rows := g.MatrixSize(c.Z)
+	return TileCoord{Z: c.Z, X: c.X, Y: rows - 1 - c.Y}
+}
+
+// TileBounds returns the extent of the tile (XYZ scheme) as [minX, minY,
+// maxX, maxY] in units of the coordinate reference system.
+func (g TileGrid) TileBounds(c TileCoord) [4]float64 {
+	cols, rows := g.MatrixSize(c.Z)
+	width := (g.Extent[2] - g.Extent[0]) / float64(cols)
+	height := (g.Extent[3] - g.Extent[1]) / float64(rows)
+	return [4]float64{
+		g.Extent[0] + float64(c.X)*width,
+		g.Extent[3] - float64(c.Y+1)*height,
+		g.Extent[0] + float64(c.X+1)*width,
+		g.Extent[3] - float64(c.Y)*height,
+	}
+}
+
+// TileCoordAt returns the XYZ scheme tile at zoom level z that contains the
+// point at x, y in units of the coordinate reference system.  Points outside
+// the extent are clamped to the nearest tile.
+func (g TileGrid) TileCoordAt(x float64, y float64, z int64) TileCoord {
+	cols, rows := g.MatrixSize(z)
+	clamp := func(v float64, max int64) int64 {
+		i := int64(math.Floor(v))
+		if i < 0 {
+			return 0
+		}
+		if i >= max {
+			return max - 1
+		}
+		return i
+	}
+	return TileCoord{
+		Z: z,
+		X: clamp((x-g.Extent[0])/(g.Extent[2]-g.Extent[0])*float64(cols), cols),
+		Y: clamp((g.Extent[3]-y)/(g.Extent[3]-g.Extent[1])*float64(rows), rows),
+	}
+}
+
+// TileRange returns the XYZ scheme tiles at the top left and bottom right of
+// the range of tiles at zoom level z that intersect bounds: [west, south,
+// east, north] in degrees.  Bounds can only be projected to the Web Mercator
+// and WGS84 coordinate reference systems; for other systems, the range covers
+// all tiles of the zoom level.
+func (g TileGrid) TileRange(bounds []float64, z int64) (TileCoord, TileCoord, error) {
+	if err := validateBounds(bounds); err != nil {
+		return TileCoord{}, TileCoord{}, err
+	}
+	west, north, ok := g.fromLonLat(bounds[0], bounds[3])
+	if !ok {
+		cols, rows := g.MatrixSize(z)
+		return TileCoord{Z: z}, TileCoord{Z: z, X: cols - 1, Y: rows - 1}, nil
+	}
+	east, south, _ := g.fromLonLat(bounds[2], bounds[1])
+	return g.TileCoordAt(west, north, z), g.TileCoordAt(east, south, z), nil
+}
+
+// fromLonLat projects longitude and latitude in degrees to the coordinate
+// reference system of the grid, if it is Web Mercator or WGS84.
+func (g TileGrid) fromLonLat(lon float64, lat float64) (float64, float64, bool) {
+	switch {
+	case g.CRS == webMercatorCRS:
+		x, y := lonLatToMercator(lon, lat)
+		return x, y, true
+	case isGeographicCRS(g.CRS):
+		return lon, lat, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// isGeographicCRS returns true if crs is the URI of a WGS84 coordinate
+// reference system in degrees.
+func isGeographicCRS(crs string) bool {
+	return crs == crs84 || crs == "http://www.opengis.net/def/crs/EPSG/0/4326"
+}
+
+// validate returns an error if the grid definition cannot be used.
+func (g TileGrid) validate() error {
+	if g.ID == "" || g.CRS == "" {
+		return errors.New("tile grid must have an id and crs")
+	}
+	if !(g.Extent[0] < g.Extent[2]) || !(g.Extent[1] < g.Extent[3]) {
+		return fmt.Errorf("tile grid extent %v must have minX < maxX and minY < maxY", g.Extent)
+	}
+	if g.MatrixWidth < 1 || g.MatrixHeight < 1 || g.TileSize < 1 {
+		return errors.New("tile grid matrix size and tile size must be greater than 0")
+	}
+	return nil
+}
+
+// OGCTileMatrixSet returns the OGC API - Tiles description of the tile
+// matrix set of the grid, with tile matrices for zoom levels 0 through
+// maxZoom.
+func (g TileGrid) OGCTileMatrixSet(maxZoom int) map[string]interface{} {
+	// meters per unit of the coordinate reference system
+	metersPerUnit := 1.0
+	if isGeographicCRS(g.CRS) {
+		metersPerUnit = earthRadius * math.Pi / 180
+	}
+
+	var matrices []map[string]interface{}
+	for z := 0; z <= maxZoom; z++ {
+		cols, rows := g.MatrixSize(int64(z))
+		cellSize := (g.Extent[2] - g.Extent[0]) / float64(g.TileSize) / float64(cols)
+		matrices = append(matrices, map[string]interface{}{
+			"id":               fmt.Sprint(z),
+			"scaleDenominator": cellSize * metersPerUnit / ogcPixelSize,
+			"cellSize":         cellSize,
+			"cornerOfOrigin":   "topLeft",
+			"pointOfOrigin":    []float64{g.Extent[0], g.Extent[3]},
+			"tileWidth":        g.TileSize,
+			"tileHeight":       g.TileSize,
+			"matrixWidth":      cols,
+			"matrixHeight":     rows,
+		})
+	}
+
+	tms := map[string]interface{}{
+		"id":           g.ID,
+		"crs":          g.CRS,
+		"orderedAxes":  []string{"X", "Y"},
+		"tileMatrices": matrices,
+	}
+	if isGeographicCRS(g.CRS) {
+		tms["orderedAxes"] = []string{"Lon", "Lat"}
+	}
+	if g.Title != "" {
+		tms["title"] = g.Title
+	}
+	if g.URI != "" {
+		tms["uri"] = g.URI
+	}
+	return tms
+}
+
+// GetTileGrid returns the tile grid of the tileset, from the tile_matrix_set
+// metadata item, which is either the ID of a well-known tile matrix set
+// (WebMercatorQuad or WorldCRS84Quad) or the JSON encoding of a TileGrid.
+// Returns WebMercatorGrid if the item is not present.
+func (db *MBtiles) GetTileGrid() (TileGrid, error) {
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return TileGrid{}, err
+	}
+	value, _ := metadata[tileGridKey].(string)
+	return parseTileGrid(value)
+}
+
+// parseTileGrid parses the value of the tile_matrix_set metadata item.
+func parseTileGrid(value string) (TileGrid, error) {
+	switch value = strings.TrimSpace(value); value {
+	case "", WebMercatorQuadID:
+		return WebMercatorGrid, nil
+	case WorldCRS84QuadID:
+		return WorldCRS84Grid, nil
+	}
+
+	var grid TileGrid
+	if err := json.Unmarshal([]byte(value), &grid); err != nil {
+		return TileGrid{}, fmt.Errorf("cannot read metadata item %s: %v", tileGridKey, err)
+	}
+	if err := grid.validate(); err != nil {
+		return TileGrid{}, fmt.Errorf("cannot read metadata item %s: %v", tileGridKey, err)
+	}
+	return grid, nil
+}
+
+// SetTileGrid stores the tile grid in the tile_matrix_set metadata item, as
+// the ID of WebMercatorQuad or WorldCRS84Quad if grid is one of these, and
+// otherwise as a JSON encoding of grid.  The mbtiles file must be writable.
+func (db *MBtiles) SetTileGrid(ctx context.Context, grid TileGrid) error {
+	if db.isClosed() {
+		return errors.New("cannot write metadata to closed mbtiles database")
+	}
+	if err := grid.validate(); err != nil {
+		return err
+	}
+
+	value := grid.ID
+	if grid != WebMercatorGrid && grid != WorldCRS84Grid {
+		encoded, err := json.Marshal(grid)
+		if err != nil {
+			return err
+		}
+		value = string(encoded)
+	}
+	return db.writeMetadata(ctx, map[string]string{tileGridKey: value})
+}
@@ -0,0 +1,100 @@
+package mbtiles
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func Test_TileGrid(t *testing.T) {
+	grid := WorldCRS84Grid
+	if cols, rows := grid.MatrixSize(2); cols != 8 || rows != 4 {
+		t.Error("Matrix size does not match expected value, got:", cols, rows)
+	}
+
+	tile := TileCoord{Z: 1, X: 3, Y: 0}
+	if bounds := grid.TileBounds(tile); bounds != [4]float64{90, 0, 180, 90} {
+		t.Error("Tile bounds do not match expected value, got:", bounds)
+	}
+	if coord := grid.TileCoordAt(100, 45, 1); coord != tile {
+		t.Error("Tile at point does not match expected value, got:", coord)
+	}
+	if coord := grid.FlipY(tile); coord != (TileCoord{Z: 1, X: 3, Y: 1}) {
+		t.Error("Flipped tile does not match expected value, got:", coord)
+	}
+
+	topLeft, bottomRight, err := grid.TileRange([]float64{-123.12359, -37.818085, 174.763027, 59.352706}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topLeft != (TileCoord{Z: 2, X: 1, Y: 0}) || bottomRight != (TileCoord{Z: 2, X: 7, Y: 2}) {
+		t.Error("Tile range does not match expected value, got:", topLeft, bottomRight)
+	}
+
+	// the Web Mercator grid matches the existing coordinate helpers
+	for _, point := range [][2]float64{{-123.12359, 59.352706}, {174.763027, -37.818085}} {
+		x, y := lonLatToMercator(point[0], point[1])
+		if coord := WebMercatorGrid.TileCoordAt(x, y, 5); coord != TileCoordFromLonLat(point[0], point[1], 5) {
+			t.Error("Web Mercator tile does not match TileCoordFromLonLat, got:", coord)
+		}
+	}
+
+	tms := grid.OGCTileMatrixSet(2)
+	matrices := tms["tileMatrices"].([]map[string]interface{})
+	// values from OGC Two Dimensional Tile Matrix Set, Annex D.2
+	if len(matrices) != 3 || math.Abs(matrices[0]["scaleDenominator"].(float64)-279541132.0143589) > 1e-6 {
+		t.Error("Tile matrices do not match expected values, got:", matrices)
+	}
+}
+
+func Test_SetTileGrid(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	grid, err := db.GetTileGrid()
+	if err != nil || grid != WebMercatorGrid {
+		t.Error("Tile grid is not Web Mercator by default, got:", grid, err)
+	}
+
+	if err := db.SetTileGrid(ctx, WorldCRS84Grid); err != nil {
+		t.Fatal("Could not set tile grid:", err)
+	}
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata[tileGridKey] != WorldCRS84QuadID {
+		t.Error("Well-known tile grid is not stored by ID, got:", metadata[tileGridKey])
+	}
+	tileset, err := db.OGCTileset("http://localhost/tiles/WorldCRS84Quad/{tileMatrix}/{tileRow}/{tileCol}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := tileset["tileMatrixSetLimits"].([]map[string]interface{})
+	if tileset["crs"] != crs84 || limits[2]["maxTileCol"] != int64(7) {
+		t.Error("Tileset does not use tile grid, got:", tileset)
+	}
+
+	custom := TileGrid{
+		ID:           "LambertQuad",
+		CRS:          "http://www.opengis.net/def/crs/EPSG/0/3035",
+		Extent:       [4]float64{2000000, 1000000, 6000000, 5000000},
+		MatrixWidth:  1,
+		MatrixHeight: 1,
+		TileSize:     512,
+	}
+	if err := db.SetTileGrid(ctx, custom); err != nil {
+		t.Fatal("Could not set tile grid:", err)
+	}
+	if grid, err := db.GetTileGrid(); err != nil || grid != custom {
+		t.Error("Custom tile grid was not read back, got:", grid, err)
+	}
+
+	if err := db.SetTileGrid(ctx, TileGrid{ID: "invalid"}); err == nil {
+		t.Error("SetTileGrid did not fail for invalid grid")
+	}
+}
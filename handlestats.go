@@ -0,0 +1,87 @@
+package mbtiles
+
+import "sync/atomic"
+
+// HandleStats is a snapshot of the cumulative counts of reads of an MBtiles
+// handle since it was opened, and of its connections to the mbtiles file, for
+// health endpoints of servers that do not export metrics.
+type HandleStats struct {
+	Reads       uint64 // calls to GetTile, ReadTile, and OpenTile
+	CacheHits   uint64 // reads served from the tile cache of CacheTiles
+	CacheMisses uint64 // reads not found in the tile cache of CacheTiles
+	NotFound    uint64 // reads of tiles that do not exist
+	Errors      uint64 // reads that failed for other reasons
+	BytesServed uint64 // total length of the tiles read
+
+	OpenConnections  int // connections to the mbtiles file, in use or idle
+	InUseConnections int
+	IdleConnections  int
+
+	ReadLimit   int // current limit of concurrent reads of AdaptiveReadLimit, or 0
+	ReadsQueued int // reads waiting for the limit of AdaptiveReadLimit
+}
+
+// handleCounters are the counters of HandleStats, updated atomically.  A nil
+// *handleCounters counts nothing.
+type handleCounters struct {
+	reads       uint64
+	cacheHits   uint64
+	cacheMisses uint64
+	notFound    uint64
+	errors      uint64
+	bytesServed uint64
+}
+
+// cacheHit counts a read served from the tile cache, or not found in it if
+// hit is false.
+func (c *handleCounters) cacheHit(hit bool) {
+	if c == nil {
+		return
+	}
+	if hit {
+		atomic.AddUint64(&c.cacheHits, 1)
+	} else {
+		atomic.AddUint64(&c.cacheMisses, 1)
+	}
+}
+
+// read counts a read of size bytes that returned err.
+func (c *handleCounters) read(size int64, err error) {
+	if c == nil {
+		return
+	}
+	atomic.AddUint64(&c.reads, 1)
+	switch {
+	case err == ErrTileNotFound:
+		atomic.AddUint64(&c.notFound, 1)
+	case err != nil:
+		atomic.AddUint64(&c.errors, 1)
+	default:
+		atomic.AddUint64(&c.bytesServed, uint64(size))
+	}
+}
+
+// Stats returns a snapshot of the counts of reads of the handle since it was
+// opened, and of its open connections.
+func (db *MBtiles) Stats() HandleStats {
+	var stats HandleStats
+	if db == nil {
+		return stats
+	}
+	if c := db.counters; c != nil {
+		stats.Reads = atomic.LoadUint64(&c.reads)
+		stats.CacheHits = atomic.LoadUint64(&c.cacheHits)
+		stats.CacheMisses = atomic.LoadUint64(&c.cacheMisses)
+		stats.NotFound = atomic.LoadUint64(&c.notFound)
+		stats.Errors = atomic.LoadUint64(&c.errors)
+		stats.BytesServed = atomic.LoadUint64(&c.bytesServed)
+	}
+	if db.pool != nil {
+		pool := db.pool.Stats()
+		stats.OpenConnections = pool.OpenConnections
+		stats.InUseConnections = pool.InUse
+		stats.IdleConnections = pool.Idle
+	}
+	stats.ReadLimit, stats.ReadsQueued = db.limiter.current()
+	return stats
+}
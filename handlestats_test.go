@@ -0,0 +1,44 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Stats(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles", CacheTiles(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := db.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Fatal("Expected ErrTileNotFound, got:", err)
+	}
+	tile, err := db.OpenTile(ctx, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	expected := HandleStats{Reads: 4, CacheHits: 1, CacheMisses: 2, NotFound: 1, BytesServed: uint64(2*len(data)) + uint64(tile.Size())}
+	if stats.Reads != expected.Reads || stats.CacheHits != expected.CacheHits || stats.CacheMisses != expected.CacheMisses ||
+		stats.NotFound != expected.NotFound || stats.Errors != 0 || stats.BytesServed != expected.BytesServed {
+		t.Error("Stats do not match expected value, got:", stats)
+	}
+	if stats.OpenConnections == 0 || stats.OpenConnections != stats.InUseConnections+stats.IdleConnections {
+		t.Error("Connection counts do not match expected value, got:", stats)
+	}
+
+	db.Close()
+	if stats := db.Stats(); stats.OpenConnections != 0 {
+		t.Error("Expected no open connections after close, got:", stats.OpenConnections)
+	}
+}
@@ -0,0 +1,154 @@
+package mbtiles
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReadTileHash returns the MD5 hash of the tile data for z, x, y (TMS scheme)
+// as lowercase hex, or an empty string if the tile does not exist.
+//
+// If the mbtiles file stores per-tile hashes in a tile_hash column (such as
+// the tiles_with_hash table produced by some pipelines), the stored hash is
+// returned without reading the tile data; otherwise the hash is computed from
+// the tile data.
+func (db *MBtiles) ReadTileHash(ctx context.Context, z int64, x int64, y int64) (string, error) {
+	if db.isClosed() {
+		return "", errors.New("cannot read tile hash from closed mbtiles database")
+	}
+
+	q := db.traced(db.pool)
+	schema, err := readTileSchema(ctx, q)
+	if err != nil {
+		return "", err
+	}
+
+	var hash string
+	if schema.hashed {
+		err = q.QueryRowContext(ctx, "select tile_hash from "+schema.table+" where zoom_level = ? and tile_column = ? and tile_row = ?", z, x, y).Scan(&hash)
+	} else {
+		var data []byte
+		err = q.QueryRowContext(ctx, "select tile_data from tiles where zoom_level = ? and tile_column = ? and tile_row = ?", z, x, y).Scan(&data)
+		if err == nil {
+			err = db.resolveBlob(ctx, &data)
+		}
+		hash = hashTile(data)
+	}
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(hash), nil
+}
+
+// ReadTileHashes returns the MD5 hashes of all tiles at zoom level zoom, as
+// described for ReadTileHash, keyed by tile coordinates (TMS scheme).  These
+// can be compared between mbtiles files to find tiles that differ without
+// comparing tile data.
+func (db *MBtiles) ReadTileHashes(ctx context.Context, zoom int) (map[TileCoord]string, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read tile hashes from closed mbtiles database")
+	}
+	if zoom < 0 || zoom > MaxZoomLevel {
+		return nil, fmt.Errorf("invalid zoom level %d", zoom)
+	}
+
+	q := db.traced(db.pool)
+	schema, err := readTileSchema(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	column, table := "tile_data", "tiles"
+	if schema.hashed {
+		column, table = "tile_hash", schema.table
+	}
+	rows, err := q.QueryContext(ctx, "select tile_column, tile_row, "+column+" from "+table+" where zoom_level = ?", zoom)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[TileCoord]string)
+	for rows.Next() {
+		coord := TileCoord{Z: int64(zoom)}
+		var value []byte
+		if err := rows.Scan(&coord.X, &coord.Y, &value); err != nil {
+			return nil, err
+		}
+		if schema.hashed {
+			hashes[coord] = strings.ToLower(string(value))
+			continue
+		}
+		if err := db.resolveBlob(ctx, &value); err != nil {
+			return nil, err
+		}
+		hashes[coord] = hashTile(value)
+	}
+	return hashes, rows.Err()
+}
+
+// ErrNoTileRefs is returned by ReadTileRef if the mbtiles file does not
+// reference tile data by ID or hash.
+var ErrNoTileRefs = errors.New("mbtiles file does not reference tile data by ID or hash")
+
+// GetTileRef returns the reference to the tile data for z, x, y (TMS scheme)
+// without reading the tile data, or an empty string if the tile does not
+// exist.  Tiles with the same reference have the same data, so that tools that
+// synchronize deduplicated tilesets can transfer each unique tile once.
+//
+// For deduplicated schemas, the reference is the tile_id of the map table; for
+// tiles stored with per-tile hashes, it is the tile_hash.  ErrNoTileRefs is
+// returned for other schemas.
+func (db *MBtiles) GetTileRef(ctx context.Context, z int64, x int64, y int64) (string, error) {
+	if db.isClosed() {
+		return "", errors.New("cannot read tile reference from closed mbtiles database")
+	}
+
+	q := db.traced(db.pool)
+	schema, err := readTileSchema(ctx, q)
+	if err != nil {
+		return "", err
+	}
+
+	var column string
+	switch {
+	case schema.deduplicated:
+		column = "tile_id"
+	case schema.hashed:
+		column = "tile_hash"
+	default:
+		return "", ErrNoTileRefs
+	}
+
+	var ref sql.NullString
+	err = q.QueryRowContext(ctx, "select "+column+" from "+schema.table+" where zoom_level = ? and tile_column = ? and tile_row = ?", z, x, y).Scan(&ref)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ref.String, nil
+}
+
+// ReadTileRef returns the reference to the tile data for z, x, y (TMS scheme)
+// as described for GetTileRef.
+//
+// Deprecated: use GetTileRef, which accepts a context.
+func (db *MBtiles) ReadTileRef(z int64, x int64, y int64) (string, error) {
+	return db.GetTileRef(context.Background(), z, x, y)
+}
+
+// hashTile returns the MD5 hash of data as lowercase hex.
+func hashTile(data []byte) string {
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
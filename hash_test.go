@@ -0,0 +1,123 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_ReadTileHash(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := db.ReadTileHash(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Unexpected error reading tile hash:", err)
+	}
+	if hash != hashTile(data) {
+		t.Error("Hash does not match hash of tile data, got:", hash)
+	}
+
+	hash, err = db.ReadTileHash(ctx, 10, 0, 0)
+	if err != nil || hash != "" {
+		t.Error("Expected empty hash for missing tile, got:", hash, err)
+	}
+
+	hashes, err := db.ReadTileHashes(ctx, 1)
+	if err != nil {
+		t.Fatal("Unexpected error reading tile hashes:", err)
+	}
+	if len(hashes) != 4 {
+		t.Error("Expected 4 hashes at zoom level 1, got:", hashes)
+	}
+}
+
+func Test_ReadTileHash_column(t *testing.T) {
+	filename := copyTestdata(t, "world_cities.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// convert to a tiles_with_hash table, with stored hashes that differ from
+	// the hashes of the tile data
+	for _, query := range []string{
+		"alter table tiles rename to tiles_with_hash",
+		"alter table tiles_with_hash add column tile_hash text",
+		"update tiles_with_hash set tile_hash = 'HASH-' || zoom_level || '-' || tile_column || '-' || tile_row",
+		"create view tiles as select zoom_level, tile_column, tile_row, tile_data from tiles_with_hash",
+	} {
+		if _, err := db.pool.Exec(query); err != nil {
+			t.Fatal(query, err)
+		}
+	}
+
+	ctx := context.Background()
+	hash, err := db.ReadTileHash(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Unexpected error reading tile hash:", err)
+	}
+	if hash != "hash-0-0-0" {
+		t.Error("Expected stored hash, got:", hash)
+	}
+
+	hashes, err := db.ReadTileHashes(ctx, 1)
+	if err != nil {
+		t.Fatal("Unexpected error reading tile hashes:", err)
+	}
+	if hashes[TileCoord{Z: 1, X: 1, Y: 1}] != "hash-1-1-1" {
+		t.Error("Expected stored hashes, got:", hashes)
+	}
+
+	// hashes are populated for new tiles
+	data := []byte("tile")
+	if err := db.insertTile(ctx, 7, 0, 0, data); err != nil {
+		t.Fatal("Unexpected error inserting tile:", err)
+	}
+	hash, err = db.ReadTileHash(ctx, 7, 0, 0)
+	if err != nil || hash != hashTile(data) {
+		t.Error("Hash of inserted tile does not match expected value, got:", hash, err)
+	}
+}
+
+func Test_ReadTileRef(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ref, err := db.ReadTileRef(0, 0, 0)
+	if err != nil {
+		t.Fatal("Unexpected error reading tile reference:", err)
+	}
+	var expected string
+	if err := db.pool.QueryRow("select tile_id from map where zoom_level = 0 and tile_column = 0 and tile_row = 0").Scan(&expected); err != nil {
+		t.Fatal(err)
+	}
+	if ref == "" || ref != expected {
+		t.Error("Tile reference does not match expected value, got:", ref)
+	}
+
+	ref, err = db.ReadTileRef(10, 0, 0)
+	if err != nil || ref != "" {
+		t.Error("Expected empty reference for missing tile, got:", ref, err)
+	}
+
+	flat, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flat.Close()
+	if _, err := flat.ReadTileRef(0, 0, 0); err != ErrNoTileRefs {
+		t.Error("Expected ErrNoTileRefs for tiles table, got:", err)
+	}
+}
@@ -0,0 +1,157 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// ErrUnavailable is returned, wrapped with the error that made the tileset
+// unavailable, by reads of tiles from handles opened with RecoverUnavailable
+// while the mbtiles file cannot be read.
+var ErrUnavailable = errors.New("mbtiles file is unavailable")
+
+// SQLite primary result codes of errors that make a tileset unavailable.
+const (
+	sqliteIOErr     = 10
+	sqliteCorrupt   = 11
+	sqliteCantOpen  = 14
+	sqliteNotADB    = 26
+	sqliteCodesMask = 0xff
+)
+
+// RecoverUnavailable marks the handle unhealthy when reads of tiles fail with
+// I/O errors, such as when a disk is removed or a network file system
+// fails, instead of failing each read in turn.  While unhealthy, reads of
+// tiles fail immediately with an error wrapping ErrUnavailable, for which
+// handlers should respond with 503 Service Unavailable and the Retry-After
+// header from RetryAfterHeader.  Once retryInterval has passed, the next read
+// attempts to reopen the file as Reload does, and the handle is healthy
+// again if that succeeds.
+func RecoverUnavailable(retryInterval time.Duration) OpenOption {
+	return func(o *openOptions) {
+		o.recoverInterval = retryInterval
+	}
+}
+
+// healthState tracks whether a handle opened with RecoverUnavailable can
+// read its mbtiles file.
+type healthState struct {
+	mu        sync.Mutex
+	unhealthy bool
+	cause     error     // error that made the handle unhealthy
+	retryAt   time.Time // time of the next attempt to reopen the file
+}
+
+// Health returns true if the handle can read its mbtiles file.  Otherwise, it
+// also returns the time until the next attempt to reopen the file, for the
+// Retry-After header of responses.  Handles not opened with
+// RecoverUnavailable are always healthy.
+func (db *MBtiles) Health() (bool, time.Duration) {
+	health := db.health
+	if health == nil {
+		return true, 0
+	}
+	health.mu.Lock()
+	defer health.mu.Unlock()
+	if !health.unhealthy {
+		return true, 0
+	}
+	retryAfter := time.Until(health.retryAt)
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	return false, retryAfter
+}
+
+// RetryAfterHeader formats retryAfter as the value of a Retry-After header,
+// in whole seconds rounded up, and at least 1 second.
+func RetryAfterHeader(retryAfter time.Duration) string {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
+
+// checkHealth returns an error wrapping ErrUnavailable if the handle is
+// unhealthy, after attempting to reopen the file if it is time to retry.
+func (db *MBtiles) checkHealth(ctx context.Context) error {
+	health := db.health
+	if health == nil {
+		return nil
+	}
+	health.mu.Lock()
+	if !health.unhealthy {
+		health.mu.Unlock()
+		return nil
+	}
+	if time.Now().Before(health.retryAt) {
+		err := health.cause
+		health.mu.Unlock()
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	// other reads fail immediately while the file is reopened
+	health.retryAt = time.Now().Add(db.options.recoverInterval)
+	health.mu.Unlock()
+
+	err := db.reopen(ctx)
+
+	health.mu.Lock()
+	defer health.mu.Unlock()
+	if err != nil {
+		health.cause = err
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	health.unhealthy, health.cause = false, nil
+	return nil
+}
+
+// reopen closes the idle connections of the pool, so that new connections
+// reopen the file, and reloads the handle.
+func (db *MBtiles) reopen(ctx context.Context) error {
+	db.pool.SetMaxIdleConns(0)
+	db.pool.SetMaxIdleConns(db.maxIdleConns())
+	return db.Reload(ctx)
+}
+
+// defaultMaxIdleConns is the default number of idle connections kept by a
+// database/sql pool.
+const defaultMaxIdleConns = 2
+
+// markUnhealthy marks the handle unhealthy if err is an I/O error and the
+// handle was opened with RecoverUnavailable, and returns err, wrapped with
+// ErrUnavailable in that case.
+func (db *MBtiles) markUnhealthy(err error) error {
+	health := db.health
+	if health == nil || !isUnavailableError(err) {
+		return err
+	}
+	health.mu.Lock()
+	defer health.mu.Unlock()
+	if !health.unhealthy {
+		health.unhealthy, health.cause = true, err
+		health.retryAt = time.Now().Add(db.options.recoverInterval)
+	}
+	return fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+// isUnavailableError returns true if err is an SQLite error caused by the
+// file being unreadable, rather than by the query.
+func isUnavailableError(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & sqliteCodesMask {
+	case sqliteIOErr, sqliteCorrupt, sqliteCantOpen, sqliteNotADB:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_RecoverUnavailable(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "world_cities.mbtiles")
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(filename, RecoverUnavailable(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if healthy, _ := db.Health(); !healthy {
+		t.Error("Expected healthy handle")
+	}
+
+	// the file becomes unreadable
+	if err := os.WriteFile(filename, bytes.Repeat([]byte("x"), len(original)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetTile(ctx, 1, 0, 0); !errors.Is(err, ErrUnavailable) {
+		t.Fatal("Expected ErrUnavailable for unreadable file, got:", err)
+	}
+	healthy, retryAfter := db.Health()
+	if healthy || retryAfter <= 0 || retryAfter > 50*time.Millisecond {
+		t.Error("Expected unhealthy handle with retry delay, got:", healthy, retryAfter)
+	}
+	if header := RetryAfterHeader(retryAfter); header != "1" {
+		t.Error("Retry-After header does not match expected value, got:", header)
+	}
+
+	// reads fail immediately until the retry interval has passed, even if the
+	// file is readable again
+	if err := os.WriteFile(filename, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetTile(ctx, 0, 0, 0); !errors.Is(err, ErrUnavailable) {
+		t.Error("Expected ErrUnavailable before retry, got:", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := db.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Error("Expected tile after file is reopened, got:", err)
+	}
+	if healthy, _ := db.Health(); !healthy {
+		t.Error("Expected healthy handle after recovery")
+	}
+
+	// missing tiles do not make the handle unhealthy
+	if _, err := db.GetTile(ctx, 20, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound, got:", err)
+	}
+}
@@ -0,0 +1,130 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+)
+
+// Hillshade creates a new mbtiles file at dst of hillshade PNG tiles computed
+// from the Terrain-RGB tiles of this mbtiles file, lit from azimuth degrees
+// clockwise from north and altitude degrees above the horizon (for example,
+// 315 and 45), and returns the number of tiles written.  dst must not already
+// exist.
+//
+// Slopes are computed using Horn's method, with pixel sizes corrected for the
+// scale of the Web Mercator projection at each row.  Pixels on the edges of a
+// tile are shaded using the nearest pixels within the tile, so faint seams may
+// be visible between tiles.  Metadata is copied from this mbtiles file, with
+// format set to png.  Only PNG tilesets are supported, as described for
+// ElevationProfile.  dst is removed if the operation fails.
+func (db *MBtiles) Hillshade(ctx context.Context, dst string, azimuth float64, altitude float64) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot create hillshade from closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PNG {
+		return 0, fmt.Errorf("hillshade is only supported for PNG tilesets, not %v", format)
+	}
+	if altitude < 0 || altitude > 90 {
+		return 0, fmt.Errorf("invalid altitude %f: must be 0-90 degrees", altitude)
+	}
+
+	var count int64
+	err := db.extract(ctx, dst, map[string]string{"format": "png"}, func(q querier) error {
+		err := forEachTile(ctx, q, func(coord TileCoord, data []byte) error {
+			src, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return &TileError{Coord: coord, Err: err}
+			}
+
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, hillshade(src, coord, azimuth, altitude)); err != nil {
+				return err
+			}
+			_, err = q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, buf.Bytes())
+			if err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return db.recordExtractHistory(ctx, q, "hillshade", map[string]interface{}{"azimuth": azimuth, "altitude": altitude})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// forEachTile calls fn for each tile of the main database of q, in order of
+// zoom level, column, and row.  Tile coordinates are read first, so that fn
+// may execute other statements using q.  The tiles table of attached
+// databases is not read, since unqualified table names resolve to main first.
+func forEachTile(ctx context.Context, q querier, fn func(coord TileCoord, data []byte) error) error {
+	coords, err := readAllTileCoords(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	for _, coord := range coords {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var data []byte
+		err := q.QueryRowContext(ctx, "select tile_data from main.tiles where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y).Scan(&data)
+		if err != nil {
+			return err
+		}
+		if err := fn(coord, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hillshade computes the hillshade of src, a decoded Terrain-RGB tile at coord
+// (TMS scheme), as described for Hillshade.
+func hillshade(src image.Image, coord TileCoord, azimuth float64, altitude float64) *image.Gray {
+	elevations, width, height := terrainElevations(src)
+	at := func(x int, y int) float64 {
+		return elevations[clampPixel(y, height)*width+clampPixel(x, width)]
+	}
+
+	zenith := (90 - altitude) * math.Pi / 180
+	azimuthRad := math.Mod(360-azimuth+90, 360) * math.Pi / 180
+
+	n := float64(int64(1) << coord.Z)
+	// size of a pixel in projected meters, which is scaled by the cosine of
+	// the latitude to give the size on the ground
+	pixelSize := 2 * mercatorOrigin / n / float64(width)
+	xyz := coord.FlipY()
+
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// latitude of the center of the row
+		my := math.Pi * (1 - 2*(float64(xyz.Y)+(float64(y)+0.5)/float64(height))/n)
+		size := pixelSize * math.Cos(math.Atan(math.Sinh(my)))
+
+		for x := 0; x < width; x++ {
+			a, b, c := at(x-1, y-1), at(x, y-1), at(x+1, y-1)
+			d, f := at(x-1, y), at(x+1, y)
+			g, h, i := at(x-1, y+1), at(x, y+1), at(x+1, y+1)
+
+			dzdx := ((c + 2*f + i) - (a + 2*d + g)) / (8 * size)
+			dzdy := ((g + 2*h + i) - (a + 2*b + c)) / (8 * size)
+			slope := math.Atan(math.Hypot(dzdx, dzdy))
+			aspect := math.Atan2(dzdy, -dzdx)
+
+			shade := math.Cos(zenith)*math.Cos(slope) + math.Sin(zenith)*math.Sin(slope)*math.Cos(azimuthRad-aspect)
+			dst.Pix[y*dst.Stride+x] = uint8(math.Round(255 * math.Max(0, shade)))
+		}
+	}
+	return dst
+}
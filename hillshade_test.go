@@ -0,0 +1,82 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Hillshade(t *testing.T) {
+	// flat at 0/0/0, and rising to the east at 1/1/0
+	filename := createTerrainTileset(t, map[TileCoord]func(x int, y int) float64{
+		{Z: 0, X: 0, Y: 0}: constantElevation(100),
+		{Z: 1, X: 1, Y: 0}: func(x int, y int) float64 { return float64(x) * 1000 },
+	})
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		azimuth float64
+		slope   func(shade uint8, flat uint8) bool
+	}{
+		// lit from the west, the slope faces the light
+		{azimuth: 270, slope: func(shade uint8, flat uint8) bool { return shade > flat }},
+		// lit from the east, the slope faces away
+		{azimuth: 90, slope: func(shade uint8, flat uint8) bool { return shade < flat }},
+	}
+
+	for _, tc := range tests {
+		dst := filepath.Join(t.TempDir(), "hillshade.mbtiles")
+		count, err := db.Hillshade(ctx, dst, tc.azimuth, 45)
+		if err != nil {
+			t.Fatal("Could not create hillshade:", err)
+		}
+		if count != 2 {
+			t.Error("Hillshade did not write expected number of tiles:", count)
+		}
+
+		out, err := Open(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.GetTileFormat() != PNG {
+			t.Error("Hillshade tiles are not PNG")
+		}
+
+		shades := make([]uint8, 2)
+		for i, coord := range []TileCoord{{Z: 0, X: 0, Y: 0}, {Z: 1, X: 1, Y: 1}} {
+			var data []byte
+			if err := out.ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || data == nil {
+				t.Fatal("Could not read hillshade tile:", coord, err)
+			}
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gray, ok := img.(*image.Gray)
+			if !ok {
+				t.Fatal("Hillshade tile is not grayscale")
+			}
+			shades[i] = gray.GrayAt(128, 128).Y
+		}
+		out.Close()
+
+		// a flat surface lit from 45 degrees is shaded cos(45)
+		if shades[0] != 180 {
+			t.Error("Flat shade", shades[0], "does not match expected value 180")
+		}
+		if !tc.slope(shades[1], shades[0]) {
+			t.Error("Unexpected slope shade", shades[1], "for azimuth", tc.azimuth)
+		}
+	}
+
+	if _, err := db.Hillshade(ctx, filepath.Join(t.TempDir(), "invalid.mbtiles"), 315, 100); err == nil {
+		t.Error("Expected error for invalid altitude")
+	}
+}
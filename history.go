@@ -0,0 +1,124 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// historyTable records operations performed with RecordHistory.
+const historyTable = "history (id integer primary key autoincrement, timestamp integer, operation text, parameters text)"
+
+// RecordHistory records operations performed by this library using the
+// MBtiles handle in a history table of the mbtiles file, with their
+// timestamps and parameters, for reproducibility of published tilesets.  The
+// table is created by the first recorded operation.
+//
+// Operations that change tiles in place are recorded within the same
+// transaction as the change.  Operations that create a new mbtiles file from
+// this one, such as ExtractZoom, Mosaic, or Hillshade, copy the history to the
+// new file and record the operation there.
+func RecordHistory() OpenOption {
+	return func(o *openOptions) {
+		o.history = true
+	}
+}
+
+// HistoryEntry is an operation recorded in the history table.
+type HistoryEntry struct {
+	ID         int64
+	Timestamp  time.Time
+	Operation  string // for example extract, mosaic, or prune
+	Parameters map[string]interface{}
+}
+
+// History returns the operations recorded in the history table of the
+// mbtiles file, oldest first, or no entries if the mbtiles file has no
+// history table.
+func (db *MBtiles) History(ctx context.Context) ([]HistoryEntry, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read history from closed mbtiles database")
+	}
+	q := db.traced(db.pool)
+
+	var count int
+	if err := q.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'history'").Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	rows, err := q.QueryContext(ctx, "select id, timestamp, operation, parameters from history order by id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var (
+			entry      HistoryEntry
+			timestamp  int64
+			parameters string
+		)
+		if err := rows.Scan(&entry.ID, &timestamp, &entry.Operation, &parameters); err != nil {
+			return nil, err
+		}
+		entry.Timestamp = time.Unix(timestamp, 0)
+		if parameters != "" {
+			if err := json.Unmarshal([]byte(parameters), &entry.Parameters); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// recordHistory records an operation that changes tiles in place within its
+// transaction, if enabled with RecordHistory.
+func (db *MBtiles) recordHistory(ctx context.Context, tx querier, operation string, parameters map[string]interface{}) error {
+	if !db.options.history {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "create table if not exists "+historyTable); err != nil {
+		return err
+	}
+	return insertHistory(ctx, tx, "", operation, parameters)
+}
+
+// recordExtractHistory copies the history of this mbtiles file to the
+// attached dst database of an extract, and records the operation that created
+// it, if enabled with RecordHistory.
+func (db *MBtiles) recordExtractHistory(ctx context.Context, tx querier, operation string, parameters map[string]interface{}) error {
+	if !db.options.history || operation == "" {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "create table dst."+historyTable); err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, "select count(*) from main.sqlite_master where type = 'table' and name = 'history'").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		if _, err := tx.ExecContext(ctx, "insert into dst.history select * from main.history order by id"); err != nil {
+			return err
+		}
+	}
+	return insertHistory(ctx, tx, "dst.", operation, parameters)
+}
+
+// insertHistory inserts a history entry into the history table of the
+// database with the given prefix.
+func insertHistory(ctx context.Context, tx querier, prefix string, operation string, parameters map[string]interface{}) error {
+	encoded, err := json.Marshal(parameters)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "insert into "+prefix+"history (timestamp, operation, parameters) values (?, ?, ?)", time.Now().Unix(), operation, string(encoded))
+	return err
+}
@@ -0,0 +1,59 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func Test_History(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"), RecordHistory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	entries, err := db.History(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Fatal("Expected no history entries, got:", entries, err)
+	}
+
+	deleted, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 6, 6)
+	if err != nil || deleted == 0 {
+		t.Fatal("Could not delete tiles:", err)
+	}
+	entries, err = db.History(ctx)
+	if err != nil || len(entries) != 1 {
+		t.Fatal("Expected 1 history entry, got:", entries, err)
+	}
+	if entries[0].Operation != "delete_tiles" || entries[0].Parameters["deleted"] != float64(deleted) || entries[0].Timestamp.IsZero() {
+		t.Error("History entry does not match expected values, got:", entries[0])
+	}
+
+	// extracts copy the history and record the extract
+	dst := filepath.Join(t.TempDir(), "extract.mbtiles")
+	if _, err := db.ExtractZoom(ctx, dst, 1); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	entries, err = out.History(ctx)
+	if err != nil || len(entries) != 2 {
+		t.Fatal("Expected 2 history entries, got:", entries, err)
+	}
+	if entries[0].Operation != "delete_tiles" || entries[1].Operation != "extract" || entries[1].Parameters["zoom"] != float64(1) {
+		t.Error("History entries do not match expected values, got:", entries)
+	}
+
+	// operations are not recorded without RecordHistory
+	if _, err := out.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = out.History(ctx); err != nil || len(entries) != 2 {
+		t.Error("Operation was recorded without RecordHistory, got:", entries, err)
+	}
+}
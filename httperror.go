@@ -0,0 +1,41 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorRenderer writes the response to a tile request r that failed with err,
+// with the given 4xx or 5xx HTTP status.  Tile handlers can accept an
+// ErrorRenderer to let users choose how errors are presented to clients, for
+// example as JSON problem details or as an error image, instead of sending
+// the text of Go errors, which may describe internal details such as file
+// paths.
+type ErrorRenderer func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// PlainTextError is an ErrorRenderer that responds with the text of the
+// status, such as "Not Found", without the text of err.
+func PlainTextError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+// ProblemDetailsError is an ErrorRenderer that responds with JSON problem
+// details as defined by RFC 7807, with the path of the request as the
+// instance.  The text of err is included as the detail of client errors
+// (4xx), such as malformed tile paths, but not of server errors (5xx).
+func ProblemDetailsError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	problem := map[string]interface{}{
+		"type":     "about:blank",
+		"title":    http.StatusText(status),
+		"status":   status,
+		"instance": r.URL.Path,
+	}
+	if err != nil && status < http.StatusInternalServerError {
+		problem["detail"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
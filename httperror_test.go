@@ -0,0 +1,40 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_ProblemDetailsError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/tiles/world/1/2/3.png", nil)
+
+	w := httptest.NewRecorder()
+	ProblemDetailsError(w, r, http.StatusBadRequest, errors.New("tile 1/2/3 has column or row outside range 0-1 for zoom level"))
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Error("Unexpected response, got:", w.Code, w.Header())
+	}
+	var problem map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem["title"] != "Bad Request" || problem["status"] != 400.0 || problem["instance"] != "/tiles/world/1/2/3.png" || problem["detail"] == nil {
+		t.Error("Problem details do not match expected values, got:", problem)
+	}
+
+	// server errors do not leak details
+	w = httptest.NewRecorder()
+	ProblemDetailsError(w, r, http.StatusInternalServerError, errors.New("open /data/secret/world.mbtiles: permission denied"))
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Error("Server error details were included in response:", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	PlainTextError(w, r, http.StatusInternalServerError, errors.New("open /data/secret/world.mbtiles: permission denied"))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "secret") {
+		t.Error("Unexpected plain text error response, got:", w.Code, w.Body.String())
+	}
+}
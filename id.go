@@ -0,0 +1,51 @@
+package mbtiles
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// IDFromPath derives a URL-safe tileset ID from the path of an mbtiles file
+// relative to base.  Path separators are replaced with hyphens, the extension
+// is stripped, and any other characters that are not URL-safe are replaced with
+// underscores.
+// Example: IDFromPath("/data", "/data/world/cities.mbtiles") => "world-cities"
+//
+// If path is not within base, only the filename of path is used.
+func IDFromPath(base string, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(path)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	rel = filepath.ToSlash(rel)
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '/':
+			return '-'
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-', r == '_', r == '.', r == '~':
+			return r
+		default:
+			return '_'
+		}
+	}, rel)
+}
+
+// IDsFromPaths derives tileset IDs for each of paths relative to base using
+// IDFromPath, and returns a map of ID to path.  An error is returned if more
+// than one path produces the same ID.
+func IDsFromPaths(base string, paths []string) (map[string]string, error) {
+	ids := make(map[string]string, len(paths))
+	for _, path := range paths {
+		id := IDFromPath(base, path)
+		if existing, ok := ids[id]; ok {
+			return nil, fmt.Errorf("tileset ID %q for %q collides with %q", id, path, existing)
+		}
+		ids[id] = path
+	}
+	return ids, nil
+}
@@ -0,0 +1,45 @@
+package mbtiles
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_IDFromPath(t *testing.T) {
+	tests := []struct {
+		base string
+		path string
+		id   string
+	}{
+		{base: "testdata", path: "testdata/world_cities.mbtiles", id: "world_cities"},
+		{base: "./testdata", path: "testdata/world_cities.mbtiles", id: "world_cities"},
+		{base: "/data", path: "/data/world/cities.mbtiles", id: "world-cities"},
+		{base: "/data", path: "/data/a/b/c.v2.mbtiles", id: "a-b-c.v2"},
+		{base: "/data", path: "/data/my tiles?.mbtiles", id: "my_tiles_"},
+		// outside of base, only the filename is used
+		{base: "/data", path: "/other/cities.mbtiles", id: "cities"},
+	}
+
+	for _, tc := range tests {
+		id := IDFromPath(filepath.FromSlash(tc.base), filepath.FromSlash(tc.path))
+		if id != tc.id {
+			t.Error("ID", id, "does not match expected value", tc.id, "for:", tc.path)
+		}
+	}
+}
+
+func Test_IDsFromPaths(t *testing.T) {
+	ids, err := IDsFromPaths("/data", []string{"/data/a.mbtiles", "/data/b/c.mbtiles"})
+	if err != nil {
+		t.Fatal("Unexpected error deriving IDs:", err)
+	}
+	if ids["a"] != "/data/a.mbtiles" || ids["b-c"] != "/data/b/c.mbtiles" {
+		t.Error("IDs do not match expected values, got:", ids)
+	}
+
+	_, err = IDsFromPaths("/data", []string{"/data/b/c.mbtiles", "/data/b-c.mbtiles"})
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Error("Colliding IDs did not raise expected error, got:", err)
+	}
+}
@@ -0,0 +1,126 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrUploadTooLarge is returned by Ingest if the mbtiles file exceeds the
+// maximum size.
+var ErrUploadTooLarge = errors.New("mbtiles file exceeds maximum upload size")
+
+// InvalidTilesError is returned by Ingest if tiles of the mbtiles file are not
+// valid.
+type InvalidTilesError struct {
+	Tiles []TileError // invalid tiles, in tile order
+}
+
+func (e *InvalidTilesError) Error() string {
+	return fmt.Sprintf("mbtiles file has %d invalid tiles, first: %v", len(e.Tiles), e.Tiles[0])
+}
+
+// Ingest reads an mbtiles file from r, such as the body of an upload, into a
+// temporary file next to dst, validates it, and only then renames it to dst,
+// replacing any existing file atomically.  Handles already open on a replaced
+// file continue to read the previous file until they are closed.
+//
+// The file must open successfully with Open, and its tiles are validated with
+// ValidateRasterTiles or ValidateVectorTiles using concurrency and sample;
+// tiles in other formats are not decoded.  An InvalidTilesError is returned if
+// any tiles are invalid.  If maxBytes is greater than 0, ErrUploadTooLarge is
+// returned once more than maxBytes are read.  The temporary file is removed if
+// ingestion fails.
+func Ingest(ctx context.Context, r io.Reader, dst string, maxBytes int64, concurrency int, sample int) error {
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-*"+PartialExtension)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := ingestFile(ctx, r, f, maxBytes, concurrency, sample); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ingestFile copies r into f and validates it, as described for Ingest.
+func ingestFile(ctx context.Context, r io.Reader, f *os.File, maxBytes int64, concurrency int, sample int) error {
+	src := r
+	if maxBytes > 0 {
+		// read one byte more than allowed to detect files that are too large
+		src = io.LimitReader(r, maxBytes+1)
+	}
+	n, err := io.Copy(f, &progressReader{r: contextReader{ctx: ctx, r: src}, progress: progressFunc(ctx)})
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 && n > maxBytes {
+		return ErrUploadTooLarge
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	db, err := Open(f.Name())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var invalid []TileError
+	switch db.GetTileFormat() {
+	case PNG, JPG:
+		invalid, err = db.ValidateRasterTiles(ctx, concurrency, sample)
+	case PBF:
+		invalid, err = db.ValidateVectorTiles(ctx, concurrency, sample)
+	}
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		return &InvalidTilesError{Tiles: invalid}
+	}
+	return nil
+}
+
+// progressReader is an io.Reader that reports the number of bytes read as the
+// progress of Ingest.
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	progress func(Progress)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(Progress{Phase: "ingest", Current: r.read})
+	}
+	return n, err
+}
+
+// contextReader is an io.Reader that stops reading once ctx is canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,67 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Ingest(t *testing.T) {
+	ctx := context.Background()
+	data, err := os.ReadFile("testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "world.mbtiles")
+
+	if err := Ingest(ctx, bytes.NewReader(data), dst, 0, 2, 1); err != nil {
+		t.Fatal("Could not ingest valid file:", err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal("Could not open ingested file:", err)
+	}
+	db.Close()
+
+	if err := Ingest(ctx, bytes.NewReader(data), dst, int64(len(data)-1), 2, 1); !errors.Is(err, ErrUploadTooLarge) {
+		t.Error("Ingest did not reject file that is too large, got:", err)
+	}
+	if err := Ingest(ctx, bytes.NewReader([]byte("not an mbtiles file")), dst, 0, 2, 1); err == nil {
+		t.Error("Ingest did not reject invalid file")
+	}
+
+	// truncate the tile at zoom 0 in a copy of the file
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	var tile []byte
+	corrupt, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := corrupt.ReadTile(0, 0, 0, &tile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := corrupt.pool.Exec("update images set tile_data = ? where tile_data = ?", tile[:100], tile); err != nil {
+		t.Fatal(err)
+	}
+	corrupt.Close()
+	if data, err = os.ReadFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	var invalid *InvalidTilesError
+	if err := Ingest(ctx, bytes.NewReader(data), dst, 0, 2, 1); !errors.As(err, &invalid) || len(invalid.Tiles) != 1 {
+		t.Error("Ingest did not reject file with invalid tiles, got:", err)
+	}
+
+	// the valid file is kept, and temporary files are removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "world.mbtiles" {
+		t.Error("Temporary files were not removed, got:", entries)
+	}
+}
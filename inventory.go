@@ -0,0 +1,82 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// inventoryHeader is the header row of tile inventories.
+var inventoryHeader = []string{"z", "x", "y", "size", "hash", "format"}
+
+// WriteInventoryCSV writes the inventory of all tiles as CSV to w, with a
+// header row followed by a row of the zoom level, column, row (TMS scheme),
+// size in bytes, MD5 hash as lowercase hex, and detected format of each tile,
+// in order of coordinates, and returns the number of tiles written, for
+// analysis of large tilesets in data warehouses.  Rows are streamed as tiles
+// are read, so that the inventory of planet-scale tilesets is not held in
+// memory.  Gzip compressed tiles are reported as pbf, and tiles of
+// unrecognized formats with an empty format unless the tileset is pbf.
+// Parquet is not supported, to avoid dependencies; CSV inventories can be
+// converted with the import tools of data warehouses.
+func (db *MBtiles) WriteInventoryCSV(ctx context.Context, w io.Writer) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot read tiles from closed mbtiles database")
+	}
+	if err := db.init(ctx); err != nil {
+		return 0, err
+	}
+	tilesetFormat := db.GetTileFormat()
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(inventoryHeader); err != nil {
+		return 0, err
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx, "select zoom_level, tile_column, tile_row, tile_data from tiles order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	record := make([]string, len(inventoryHeader))
+	for rows.Next() {
+		var (
+			coord TileCoord
+			data  []byte
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &data); err != nil {
+			return count, err
+		}
+		if err := db.resolveBlob(ctx, &data); err != nil {
+			return count, &TileError{Coord: coord, Err: err}
+		}
+		format, err := ParseTileFormat(data)
+		switch {
+		case format == GZIP:
+			format = PBF
+		case err != nil && tilesetFormat == PBF:
+			// uncompressed vector tiles have no signature
+			format = PBF
+		}
+
+		record[0] = strconv.FormatInt(coord.Z, 10)
+		record[1] = strconv.FormatInt(coord.X, 10)
+		record[2] = strconv.FormatInt(coord.Y, 10)
+		record[3] = strconv.Itoa(len(data))
+		record[4] = hashTile(data)
+		record[5] = format.String()
+		if err := writer.Write(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	writer.Flush()
+	return count, writer.Error()
+}
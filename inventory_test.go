@@ -0,0 +1,46 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strconv"
+	"testing"
+)
+
+func Test_WriteInventoryCSV(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := db.WriteInventoryCSV(ctx, &buf)
+	if err != nil {
+		t.Fatal("Could not write inventory:", err)
+	}
+	if count != 5 {
+		t.Error("Expected 5 tiles in inventory, got:", count)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal("Inventory is not valid CSV:", err)
+	}
+	if len(records) != 6 || len(records[0]) != 6 || records[0][0] != "z" || records[0][5] != "format" {
+		t.Fatal("Inventory rows do not match expected value, got:", records)
+	}
+
+	data, err := db.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"0", "0", "0", strconv.Itoa(len(data)), hashTile(data), "png"}
+	for i, value := range expected {
+		if records[1][i] != value {
+			t.Error("Inventory row does not match expected value, got:", records[1])
+			break
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// JournalPolicy determines how mbtiles files with an associated -journal file
+// are handled.  A -journal file may be left by a tileset that is still being
+// created, or by a process that crashed while writing to it.  Files in WAL mode
+// do not use -journal files, so these are always ignored for them.
+type JournalPolicy uint8
+
+// JournalPolicy values
+const (
+	// JournalRefuse refuses to open the file, and skips it when searching for
+	// mbtiles files.  This is the default.
+	JournalRefuse JournalPolicy = iota
+	// JournalReadOnly logs a warning and opens the file read-only, without
+	// modifying the -journal file.  Reads fail if SQLite needs to roll back the
+	// journal first.
+	JournalReadOnly
+	// JournalRecover opens the file read-write, so that SQLite rolls back the
+	// journal (if it is a hot journal left by a crashed writer) on first read.
+	JournalRecover
+)
+
+// UseJournalPolicy sets how Open handles a file with an associated -journal
+// file.  The default is JournalRefuse.
+func UseJournalPolicy(policy JournalPolicy) OpenOption {
+	return func(o *openOptions) {
+		o.journalPolicy = policy
+	}
+}
+
+// FindJournalPolicy sets how FindMBtiles and FindMBtilesFS handle files with
+// an associated -journal file.  These are skipped for the default of
+// JournalRefuse, and included otherwise.
+func FindJournalPolicy(policy JournalPolicy) FindOption {
+	return func(o *findOptions) {
+		o.journalPolicy = policy
+	}
+}
+
+// Recover recovers an mbtiles file that has a hot journal left by a writer
+// that crashed, and opens it read-only.
+//
+// The file is first opened read-write and locked for writing, which fails if
+// another process is still writing to it; SQLite rolls back the hot journal,
+// if any, before the lock is acquired.  The file is then opened read-only
+// using the provided options.
+func Recover(path string, opts ...OpenOption) (*MBtiles, error) {
+	if err := rollbackJournal(path); err != nil {
+		return nil, err
+	}
+	return Open(path, append(opts, UseJournalPolicy(JournalRecover), openReadOnly())...)
+}
+
+// openReadOnly opens the mbtiles file read-only.
+func openReadOnly() OpenOption {
+	return func(o *openOptions) {
+		o.readOnly = true
+	}
+}
+
+// rollbackJournal opens path read-write and acquires a write lock, so that
+// SQLite rolls back a hot journal.
+func rollbackJournal(path string) error {
+	// fail fast if path does not exist, rather than creating it
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("path does not exist: %q", path)
+		}
+		return err
+	}
+
+	pool, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "begin immediate"); err != nil {
+		return fmt.Errorf("cannot recover mbtiles file %q, which may be in use by another process: %w", path, err)
+	}
+	_, err = conn.ExecContext(ctx, "rollback")
+	return err
+}
@@ -0,0 +1,137 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_UseJournalPolicy(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	// a stale (not hot) journal left behind by a writer
+	if err := os.WriteFile(filename+"-journal", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(filename); err == nil {
+		t.Error("Expected error opening file with -journal file by default")
+	}
+
+	var buf bytes.Buffer
+	db, err := Open(filename, UseJournalPolicy(JournalReadOnly), UseLogger(log.New(&buf, "", 0)))
+	if err != nil {
+		t.Fatal("Could not open file read-only:", err)
+	}
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || data == nil {
+		t.Error("Could not read tile from file opened read-only:", err)
+	}
+	if !strings.Contains(buf.String(), "read-only") {
+		t.Error("Expected warning to be logged, got:", buf.String())
+	}
+	if _, err := db.pool.Exec("delete from metadata"); err == nil {
+		t.Error("Expected error writing to file opened read-only")
+	}
+	db.Close()
+
+	db, err = Open(filename, UseJournalPolicy(JournalRecover))
+	if err != nil {
+		t.Fatal("Could not open file for recovery:", err)
+	}
+	defer db.Close()
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || data == nil {
+		t.Error("Could not read tile from recovered file:", err)
+	}
+}
+
+func Test_FindJournalPolicy(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	if err := os.WriteFile(filename+"-journal", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Dir(filename)
+
+	found, err := FindMBtiles(root)
+	if err != nil || len(found) != 0 {
+		t.Error("Expected file with -journal file to be skipped, got:", found, err)
+	}
+	found, err = FindMBtiles(root, FindJournalPolicy(JournalReadOnly))
+	if err != nil || !equalStrings(found, []string{filename}) {
+		t.Error("Expected file with -journal file to be found, got:", found, err)
+	}
+}
+
+func Test_Recover(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	var expected []byte
+	src, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.ReadTile(1, 0, 0, &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a writer that crashed: copy the file and its journal while a
+	// write transaction has modified the file on disk
+	ctx := context.Background()
+	conn, err := src.pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"pragma cache_size = 1", // spill modified pages to the file before commit
+		"begin",
+		"update images set tile_data = zeroblob(length(tile_data))",
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(query, err)
+		}
+	}
+	crashed := filepath.Join(t.TempDir(), "crashed.mbtiles")
+	for _, suffix := range []string{"", "-journal"} {
+		data, err := os.ReadFile(filename + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(crashed+suffix, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.ExecContext(ctx, "rollback")
+	conn.Close()
+	src.Close()
+
+	if _, err := Open(crashed); err == nil {
+		t.Error("Expected error opening file with hot journal")
+	}
+
+	db, err := Recover(crashed)
+	if err != nil {
+		t.Fatal("Unexpected error recovering file:", err)
+	}
+	defer db.Close()
+
+	if _, err := os.Stat(crashed + "-journal"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected hot journal to be rolled back")
+	}
+	var data []byte
+	if err := db.ReadTile(1, 0, 0, &data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Error("Recovered tile does not match original tile")
+	}
+	if _, err := db.pool.Exec("delete from metadata"); err == nil {
+		t.Error("Expected recovered file to be opened read-only")
+	}
+
+	if _, err := Recover(filepath.Join(t.TempDir(), "missing.mbtiles")); err == nil {
+		t.Error("Expected error recovering missing file")
+	}
+}
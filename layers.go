@@ -0,0 +1,532 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// layerPlaceholder is replaced by the name of each layer in the destination
+// pattern of SplitLayers.
+const layerPlaceholder = "{layer}"
+
+// SplitLayers writes each layer of the vector tiles of the mbtiles file to a
+// separate new mbtiles file, so that heavy layers, such as buildings, can be
+// hosted and cached separately from light ones, such as boundaries.  Returns
+// the number of tiles written for each layer.
+//
+// dstPattern is the path of the new files, in which {layer} is replaced by
+// the name of each layer, with characters other than ASCII letters, digits,
+// '.', '-', and '_' replaced by '_'; none of the files may already exist.
+// Each tile that has features in a layer is written to the file of the layer
+// with only that layer, re-encoded, and gzip compressed if the source tile
+// is.  Metadata is copied from the mbtiles file, except that minzoom and
+// maxzoom are those of the tiles of the layer, and the layers of json are
+// limited to the layer.  All new files are removed if the operation fails.
+func (db *MBtiles) SplitLayers(ctx context.Context, dstPattern string) (map[string]int64, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot split layers of closed mbtiles database")
+	}
+	if !strings.Contains(dstPattern, layerPlaceholder) {
+		return nil, fmt.Errorf("destination pattern %q must contain %s", dstPattern, layerPlaceholder)
+	}
+	if err := db.init(ctx); err != nil {
+		return nil, err
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return nil, fmt.Errorf("split layers is only supported for vector tilesets, not %v", format)
+	}
+
+	coords, err := readAllTileCoords(ctx, db.traced(db.pool))
+	if err != nil {
+		return nil, err
+	}
+	// coordinates of the tiles with features in each layer
+	layerCoords := make(map[string][]TileCoord)
+	for _, coord := range coords {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		layers, _, err := db.readTileLayers(ctx, coord)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range layers {
+			if len(layer.features) > 0 {
+				layerCoords[layer.name] = append(layerCoords[layer.name], coord)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(layerCoords))
+	for name := range layerCoords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paths := make(map[string]string, len(names))
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		path := strings.Replace(dstPattern, layerPlaceholder, layerFileName(name), -1)
+		if other, ok := seen[path]; ok {
+			return nil, fmt.Errorf("layers %q and %q have the same destination %q", other, name, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("destination already exists: %q", path)
+		}
+		seen[path] = name
+		paths[name] = path
+	}
+
+	var layerJSON string
+	err = db.traced(db.pool).QueryRowContext(ctx, "select value from metadata where name = 'json'").Scan(&layerJSON)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(names))
+	for _, name := range names {
+		count, err := db.writeLayer(ctx, paths[name], name, layerCoords[name], layerJSON)
+		if err != nil {
+			for _, written := range names {
+				if _, ok := counts[written]; ok {
+					os.Remove(paths[written])
+				}
+			}
+			return nil, fmt.Errorf("cannot write layer %q: %w", name, err)
+		}
+		counts[name] = count
+	}
+	return counts, nil
+}
+
+// writeLayer creates a new mbtiles file at dst with the layer name of the
+// tiles at coords (TMS scheme), and the json metadata item layerJSON limited
+// to the layer.  Returns the number of tiles written.
+func (db *MBtiles) writeLayer(ctx context.Context, dst string, name string, coords []TileCoord, layerJSON string) (int64, error) {
+	minZoom, maxZoom := coords[0].Z, coords[0].Z
+	for _, coord := range coords {
+		minZoom, maxZoom = minInt64(minZoom, coord.Z), maxInt64(maxZoom, coord.Z)
+	}
+	encoded, err := filterLayerJSON(layerJSON, name, int(minZoom), int(maxZoom))
+	if err != nil {
+		return 0, err
+	}
+	metadata := map[string]string{
+		"minzoom": strconv.FormatInt(minZoom, 10),
+		"maxzoom": strconv.FormatInt(maxZoom, 10),
+		"json":    encoded,
+	}
+
+	var count int64
+	err = db.extract(ctx, dst, metadata, func(q querier) error {
+		for _, coord := range coords {
+			layers, gzipped, err := db.readTileLayers(ctx, coord)
+			if err != nil {
+				return err
+			}
+			var data []byte
+			for _, layer := range layers {
+				if layer.name == name {
+					data = encodeMVT([]mvtLayer{layer})
+					break
+				}
+			}
+			if gzipped {
+				if data, err = gzipBytes(data); err != nil {
+					return err
+				}
+			}
+			_, err = q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+		return db.recordExtractHistory(ctx, q, "split_layers", map[string]interface{}{"layer": name})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// readTileLayers reads and decodes the layers of the vector tile at coord
+// (TMS scheme), and returns true if the tile is gzip compressed.
+func (db *MBtiles) readTileLayers(ctx context.Context, coord TileCoord) ([]mvtLayer, bool, error) {
+	var data []byte
+	if err := db.queryTile(ctx, coord.Z, coord.X, coord.Y, &data); err != nil {
+		return nil, false, err
+	}
+	gzipped := len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+	layers, err := decodeTileMVT(data)
+	if err != nil {
+		return nil, false, &TileError{Coord: coord, Err: err}
+	}
+	return layers, gzipped, nil
+}
+
+// layerFileName returns name with characters other than ASCII letters,
+// digits, '.', '-', and '_' replaced by '_', for use in file names.
+func layerFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// filterLayerJSON returns the json metadata item layerJSON with only the
+// entry of the layer name in vector_layers, and in the layers of tilestats
+// if present.  An entry for the layer with no fields is added if there is
+// none; its minzoom and maxzoom are set to minZoom and maxZoom.
+func filterLayerJSON(layerJSON string, name string, minZoom int, maxZoom int) (string, error) {
+	content := make(map[string]interface{})
+	if layerJSON != "" {
+		if err := decodeMetadataJSON(layerJSON, &content); err != nil {
+			return "", err
+		}
+	}
+
+	var entry map[string]interface{}
+	vectorLayers, _ := content["vector_layers"].([]interface{})
+	for _, layer := range vectorLayers {
+		if layer, ok := layer.(map[string]interface{}); ok && layer["id"] == name {
+			entry = layer
+			break
+		}
+	}
+	if entry == nil {
+		entry = map[string]interface{}{"id": name, "fields": map[string]interface{}{}}
+	}
+	entry["minzoom"], entry["maxzoom"] = minZoom, maxZoom
+	content["vector_layers"] = []interface{}{entry}
+
+	if tilestats, ok := content["tilestats"].(map[string]interface{}); ok {
+		layers := []interface{}{}
+		statsLayers, _ := tilestats["layers"].([]interface{})
+		for _, layer := range statsLayers {
+			if layer, ok := layer.(map[string]interface{}); ok && layer["layer"] == name {
+				layers = append(layers, layer)
+			}
+		}
+		tilestats["layers"] = layers
+		tilestats["layerCount"] = len(layers)
+	}
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// LayerConflict is how JoinLayers handles layers of the same name in several
+// sources.
+type LayerConflict uint8
+
+// LayerConflict values
+const (
+	LayerConflictError  LayerConflict = iota // return an error
+	LayerConflictRename                      // rename the layers of later sources, such as roads_2
+	LayerConflictMerge                       // merge the features of the layers into one layer
+)
+
+// JoinLayers creates a new mbtiles file at dst with vector tiles that contain
+// the layers of the tiles of all of the vector tilesets sources, such as those
+// written by SplitLayers, and returns the number of tiles written.  dst must
+// not already exist.  Tiles are joined using up to concurrency goroutines;
+// concurrency less than 1 is treated as 1.
+//
+// The layers of each tile are decoded and encoded again in a single tile, in
+// the order of sources, and gzip compressed if any source tile is.  Layers of
+// the same name in several sources are handled as set by conflict: with
+// LayerConflictRename, the layer of the source at index i is renamed with the
+// suffix _i+1, in all tiles; with LayerConflictMerge, the features of later
+// sources are appended to the layer of the first source, which must have the
+// same extent.  Metadata is combined as described for Mosaic, with format set
+// to pbf, and json listing the vector_layers of all sources; tilestats are
+// not copied.  dst is removed if the operation fails.
+func JoinLayers(ctx context.Context, dst string, conflict LayerConflict, concurrency int, sources ...*MBtiles) (int64, error) {
+	if len(sources) == 0 {
+		return 0, errors.New("join layers requires at least one source")
+	}
+	if conflict > LayerConflictMerge {
+		return 0, fmt.Errorf("invalid layer conflict %d", conflict)
+	}
+	for _, db := range sources {
+		if db.isClosed() {
+			return 0, errors.New("cannot join layers of closed mbtiles database")
+		}
+		if err := db.init(ctx); err != nil {
+			return 0, err
+		}
+		if format := db.GetTileFormat(); format != PBF {
+			return 0, fmt.Errorf("join layers is only supported for vector tilesets, not %v", format)
+		}
+	}
+
+	// the vector_layers of the json metadata of each source
+	sourceLayers := make([][]map[string]interface{}, len(sources))
+	for i, db := range sources {
+		var layerJSON string
+		err := db.traced(db.pool).QueryRowContext(ctx, "select value from metadata where name = 'json'").Scan(&layerJSON)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		if sourceLayers[i], err = readVectorLayers(layerJSON); err != nil {
+			return 0, err
+		}
+	}
+	renames := make([]map[string]string, len(sources))
+	if conflict != LayerConflictMerge {
+		var err error
+		if renames, err = joinedLayerNames(ctx, sources, sourceLayers, conflict); err != nil {
+			return 0, err
+		}
+	}
+
+	metadata, err := unionMetadata(sources)
+	if err != nil {
+		return 0, err
+	}
+	metadata["format"] = "pbf"
+	if metadata["json"], err = joinLayerJSON(sourceLayers, renames); err != nil {
+		return 0, err
+	}
+	filenames := make([]string, len(sources))
+	for i, db := range sources {
+		filenames[i] = db.GetFilename()
+	}
+	parameters := map[string]interface{}{"sources": filenames, "conflict": int(conflict)}
+	return compositeTiles(ctx, dst, sources, metadata, "join_layers", parameters, concurrency, func(coord TileCoord) ([]byte, error) {
+		return joinTile(ctx, sources, renames, coord)
+	})
+}
+
+// joinedLayerNames returns the names of the layers of each of sources in the
+// joined tileset, for the layers found in their tiles or listed in their
+// vector_layers, for LayerConflictError and LayerConflictRename.
+func joinedLayerNames(ctx context.Context, sources []*MBtiles, sourceLayers [][]map[string]interface{}, conflict LayerConflict) ([]map[string]string, error) {
+	used := make(map[string]bool)
+	renames := make([]map[string]string, len(sources))
+	for i, db := range sources {
+		names := make(map[string]bool)
+		for _, layer := range sourceLayers[i] {
+			if id, ok := layer["id"].(string); ok {
+				names[id] = true
+			}
+		}
+		coords, err := readAllTileCoords(ctx, db.traced(db.pool))
+		if err != nil {
+			return nil, err
+		}
+		for _, coord := range coords {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			layers, _, err := db.readTileLayers(ctx, coord)
+			if err != nil {
+				return nil, err
+			}
+			for _, layer := range layers {
+				names[layer.name] = true
+			}
+		}
+
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		renames[i] = make(map[string]string, len(sorted))
+		for _, name := range sorted {
+			renamed := name
+			if used[name] {
+				if conflict == LayerConflictError {
+					return nil, fmt.Errorf("layer %q of %s is also in an earlier source", name, db.GetFilename())
+				}
+				for n := i + 1; used[renamed]; n++ {
+					renamed = name + "_" + strconv.Itoa(n)
+				}
+			}
+			used[renamed] = true
+			renames[i][name] = renamed
+		}
+	}
+	return renames, nil
+}
+
+// joinTile reads the tiles of sources at coord (TMS scheme) and returns a
+// tile with all of their layers, renamed by renames for each source, or
+// merged if renames is nil.
+func joinTile(ctx context.Context, sources []*MBtiles, renames []map[string]string, coord TileCoord) ([]byte, error) {
+	var joined []mvtLayer
+	indexes := make(map[string]int)
+	compress := false
+	for i, db := range sources {
+		layers, gzipped, err := db.readTileLayers(ctx, coord)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		compress = compress || gzipped
+		for _, layer := range layers {
+			if name, ok := renames[i][layer.name]; ok {
+				layer.name = name
+			}
+			index, ok := indexes[layer.name]
+			if !ok {
+				indexes[layer.name] = len(joined)
+				joined = append(joined, layer)
+				continue
+			}
+			if err := mergeMVTLayer(&joined[index], layer); err != nil {
+				return nil, &TileError{Coord: coord, Err: err}
+			}
+		}
+	}
+
+	data := encodeMVT(joined)
+	if compress {
+		return gzipBytes(data)
+	}
+	return data, nil
+}
+
+// mergeMVTLayer appends the features of src to dst, which must have the same
+// extent, adding the keys and values of their tags to those of dst.
+func mergeMVTLayer(dst *mvtLayer, src mvtLayer) error {
+	if dst.extent != src.extent {
+		return fmt.Errorf("cannot merge layer %q with extents %d and %d", dst.name, dst.extent, src.extent)
+	}
+	if src.version > dst.version {
+		dst.version = src.version
+	}
+
+	keys := make(map[string]uint32, len(dst.keys))
+	for i, key := range dst.keys {
+		keys[key] = uint32(i)
+	}
+	values := make(map[interface{}]uint32, len(dst.values))
+	for i, value := range dst.values {
+		values[value] = uint32(i)
+	}
+	// copy the slices of dst, which may be shared with the decoded tile
+	dst.keys = append([]string(nil), dst.keys...)
+	dst.values = append([]interface{}(nil), dst.values...)
+	dst.features = append([]mvtFeature(nil), dst.features...)
+	for _, feature := range src.features {
+		if len(feature.tags)%2 != 0 {
+			return fmt.Errorf("feature of layer %q has an odd number of tags", src.name)
+		}
+		tags := make([]uint32, len(feature.tags))
+		for j := 0; j < len(feature.tags); j += 2 {
+			k, v := feature.tags[j], feature.tags[j+1]
+			if int(k) >= len(src.keys) || int(v) >= len(src.values) {
+				return fmt.Errorf("feature of layer %q has invalid tags", src.name)
+			}
+			key, ok := keys[src.keys[k]]
+			if !ok {
+				key = uint32(len(dst.keys))
+				keys[src.keys[k]] = key
+				dst.keys = append(dst.keys, src.keys[k])
+			}
+			value, ok := values[src.values[v]]
+			if !ok {
+				value = uint32(len(dst.values))
+				values[src.values[v]] = value
+				dst.values = append(dst.values, src.values[v])
+			}
+			tags[j], tags[j+1] = key, value
+		}
+		feature.tags = tags
+		dst.features = append(dst.features, feature)
+	}
+	return nil
+}
+
+// readVectorLayers returns the entries of vector_layers of the json metadata
+// item layerJSON, if any.
+func readVectorLayers(layerJSON string) ([]map[string]interface{}, error) {
+	if layerJSON == "" {
+		return nil, nil
+	}
+	var content struct {
+		VectorLayers []map[string]interface{} `json:"vector_layers"`
+	}
+	if err := decodeMetadataJSON(layerJSON, &content); err != nil {
+		return nil, err
+	}
+	return content.VectorLayers, nil
+}
+
+// joinLayerJSON returns the json metadata item of tilesets joined by
+// JoinLayers, listing the vector_layers of each source, renamed by renames,
+// and merged if they have the same id: fields are combined, and minzoom and
+// maxzoom cover those of all entries.
+func joinLayerJSON(sourceLayers [][]map[string]interface{}, renames []map[string]string) (string, error) {
+	var joined []map[string]interface{}
+	indexes := make(map[string]int)
+	for i, layers := range sourceLayers {
+		for _, layer := range layers {
+			id, _ := layer["id"].(string)
+			if name, ok := renames[i][id]; ok {
+				id = name
+			}
+			entry := make(map[string]interface{}, len(layer))
+			for key, value := range layer {
+				entry[key] = value
+			}
+			entry["id"] = id
+
+			index, ok := indexes[id]
+			if !ok {
+				indexes[id] = len(joined)
+				joined = append(joined, entry)
+				continue
+			}
+			existing := joined[index]
+			fields, _ := existing["fields"].(map[string]interface{})
+			if fields == nil {
+				fields = make(map[string]interface{})
+				existing["fields"] = fields
+			}
+			if more, ok := entry["fields"].(map[string]interface{}); ok {
+				for field, fieldType := range more {
+					if previous, ok := fields[field]; ok && previous != fieldType {
+						fieldType = "Mixed"
+					}
+					fields[field] = fieldType
+				}
+			}
+			if z, ok := entry["minzoom"].(float64); ok {
+				if previous, ok := existing["minzoom"].(float64); !ok || z < previous {
+					existing["minzoom"] = z
+				}
+			}
+			if z, ok := entry["maxzoom"].(float64); ok {
+				if previous, ok := existing["maxzoom"].(float64); !ok || z > previous {
+					existing["maxzoom"] = z
+				}
+			}
+		}
+	}
+
+	if joined == nil {
+		joined = []map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(map[string]interface{}{"vector_layers": joined})
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
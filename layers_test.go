@@ -0,0 +1,219 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// createVectorTileset creates a gzipped vector tileset in a temporary
+// directory with the json metadata item layerJSON and tiles, and returns its
+// path.
+func createVectorTileset(t *testing.T, layerJSON string, tiles map[TileCoord][]mvtLayer) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "vector.mbtiles")
+	pool, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for _, query := range schemaStatements("") {
+		if _, err := pool.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, value := range map[string]string{"name": "vector", "format": "pbf", "json": layerJSON} {
+		if _, err := pool.Exec("insert into metadata (name, value) values (?, ?)", name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for coord, layers := range tiles {
+		data, err := gzipBytes(encodeMVT(layers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec("insert into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filename
+}
+
+// pointLayer returns a layer named name with a point feature with the
+// attribute kind.
+func pointLayer(name string, kind string) mvtLayer {
+	return mvtLayer{
+		version: 2,
+		name:    name,
+		extent:  4096,
+		keys:    []string{"kind"},
+		values:  []interface{}{kind},
+		features: []mvtFeature{{
+			tags:     []uint32{0, 0},
+			geomType: mvtPoint,
+			geometry: encodePointGeometry([][2]int32{{10, 10}}),
+		}},
+	}
+}
+
+func Test_SplitLayers(t *testing.T) {
+	ctx := context.Background()
+	layerJSON := `{"vector_layers":[{"id":"roads","fields":{"kind":"String"}},{"id":"water bodies","fields":{"kind":"String"}}]}`
+	filename := createVectorTileset(t, layerJSON, map[TileCoord][]mvtLayer{
+		{Z: 0}:             {pointLayer("roads", "primary"), pointLayer("water bodies", "lake")},
+		{Z: 1, X: 1, Y: 1}: {pointLayer("roads", "secondary")},
+		{Z: 1}:             {pointLayer("roads", "primary"), {version: 2, name: "water bodies", extent: 4096}},
+	})
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	if _, err := db.SplitLayers(ctx, filepath.Join(dir, "layer.mbtiles")); err == nil {
+		t.Error("Expected error for pattern without {layer}")
+	}
+	counts, err := db.SplitLayers(ctx, filepath.Join(dir, "{layer}.mbtiles"))
+	if err != nil {
+		t.Fatal("Could not split layers:", err)
+	}
+	if len(counts) != 2 || counts["roads"] != 3 || counts["water bodies"] != 1 {
+		t.Error("Tile counts do not match expected value, got:", counts)
+	}
+
+	water, err := Open(filepath.Join(dir, "water_bodies.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer water.Close()
+	data, err := water.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := decodeTileMVT(data)
+	if err != nil || len(layers) != 1 || layers[0].name != "water bodies" || len(layers[0].features) != 1 {
+		t.Error("Expected tile with water bodies layer, got:", layers, err)
+	}
+	if _, err := water.GetTile(ctx, 1, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected no tile without features of the layer, got:", err)
+	}
+	metadata, err := water.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vectorLayers, ok := metadata["vector_layers"].([]interface{}); !ok || len(vectorLayers) != 1 || vectorLayers[0].(map[string]interface{})["id"] != "water bodies" {
+		t.Error("Expected json metadata with only the layer, got:", metadata["vector_layers"])
+	}
+	if metadata["maxzoom"] != 0 || metadata["name"] != "vector" {
+		t.Error("Metadata does not match expected value, got:", metadata)
+	}
+
+	// existing destinations are not overwritten
+	if _, err := db.SplitLayers(ctx, filepath.Join(dir, "{layer}.mbtiles")); err == nil {
+		t.Error("Expected error for existing destination")
+	}
+}
+
+func Test_JoinLayers(t *testing.T) {
+	ctx := context.Background()
+	open := func(layerJSON string, tiles map[TileCoord][]mvtLayer) *MBtiles {
+		db, err := Open(createVectorTileset(t, layerJSON, tiles))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(db.Close)
+		return db
+	}
+	roads := open(`{"vector_layers":[{"id":"roads","fields":{"kind":"String"},"minzoom":0,"maxzoom":1}]}`, map[TileCoord][]mvtLayer{
+		{Z: 0}:             {pointLayer("roads", "primary")},
+		{Z: 1, X: 1, Y: 1}: {pointLayer("roads", "secondary")},
+	})
+	water := open(`{"vector_layers":[{"id":"water","fields":{"kind":"String"}}]}`, map[TileCoord][]mvtLayer{
+		{Z: 0}: {pointLayer("water", "lake")},
+	})
+	moreRoads := open(`{"vector_layers":[{"id":"roads","fields":{"lanes":"Number"}}]}`, map[TileCoord][]mvtLayer{
+		{Z: 0}: {pointLayer("roads", "track")},
+	})
+
+	dir := t.TempDir()
+	count, err := JoinLayers(ctx, filepath.Join(dir, "joined.mbtiles"), LayerConflictError, 2, roads, water)
+	if err != nil {
+		t.Fatal("Could not join layers:", err)
+	}
+	if count != 2 {
+		t.Error("Expected 2 joined tiles, got:", count)
+	}
+	joined, err := Open(filepath.Join(dir, "joined.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer joined.Close()
+	data, err := joined.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := decodeTileMVT(data)
+	if err != nil || len(layers) != 2 || layers[0].name != "roads" || layers[1].name != "water" {
+		t.Error("Expected tile with roads and water layers, got:", layers, err)
+	}
+	metadata, err := joined.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vectorLayers, ok := metadata["vector_layers"].([]interface{}); !ok || len(vectorLayers) != 2 {
+		t.Error("Expected json metadata with both layers, got:", metadata["vector_layers"])
+	}
+
+	if _, err := JoinLayers(ctx, filepath.Join(dir, "conflict.mbtiles"), LayerConflictError, 1, roads, moreRoads); err == nil {
+		t.Error("Expected error for layers of the same name")
+	}
+
+	if _, err := JoinLayers(ctx, filepath.Join(dir, "renamed.mbtiles"), LayerConflictRename, 1, roads, moreRoads); err != nil {
+		t.Fatal("Could not join renamed layers:", err)
+	}
+	renamed, err := Open(filepath.Join(dir, "renamed.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renamed.Close()
+	data, err = renamed.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layers, err := decodeTileMVT(data); err != nil || len(layers) != 2 || layers[1].name != "roads_2" {
+		t.Error("Expected renamed layer, got:", layers, err)
+	}
+
+	if _, err := JoinLayers(ctx, filepath.Join(dir, "merged.mbtiles"), LayerConflictMerge, 1, roads, moreRoads); err != nil {
+		t.Fatal("Could not join merged layers:", err)
+	}
+	merged, err := Open(filepath.Join(dir, "merged.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer merged.Close()
+	data, err = merged.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err = decodeTileMVT(data)
+	if err != nil || len(layers) != 1 || len(layers[0].features) != 2 {
+		t.Fatal("Expected merged layer with 2 features, got:", layers, err)
+	}
+	// the features keep their own values
+	tags := layers[0].features[1].tags
+	if layers[0].keys[tags[0]] != "kind" || layers[0].values[tags[1]] != "track" {
+		t.Error("Merged feature tags do not match expected value, got:", layers[0].keys, layers[0].values, tags)
+	}
+	metadata, err = merged.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vectorLayers, ok := metadata["vector_layers"].([]interface{}); !ok || len(vectorLayers) != 1 || len(vectorLayers[0].(map[string]interface{})["fields"].(map[string]interface{})) != 2 {
+		t.Error("Expected json metadata with merged fields, got:", metadata["vector_layers"])
+	}
+}
@@ -0,0 +1,151 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrLocked is returned by operations that write to an mbtiles file if the
+// writer lock of the file is held by another handle, possibly in another
+// process.
+var ErrLocked = errors.New("mbtiles file is locked by another writer")
+
+// LockExtension is the extension added to the path of an mbtiles file for the
+// file of its writer lock, which exists while the lock is held.
+const LockExtension = "-lock"
+
+// LockWriter makes Open acquire the writer lock of the mbtiles file, and hold
+// it until Close, so that no other handle, including handles of other
+// processes, can write to the file in the meantime.  Open returns ErrLocked
+// if another handle holds the lock.
+//
+// Without this option, each write of a handle holds the lock only for its
+// transaction, and returns ErrLocked if another handle holds it.  The lock is
+// an advisory lock (flock) of a file next to the mbtiles file, with
+// LockExtension added to its path; on platforms without flock, writes are not
+// locked beyond the locking of SQLite.
+func LockWriter() OpenOption {
+	return func(o *openOptions) {
+		o.lockWriter = true
+	}
+}
+
+// writerLock is the writer lock of a handle, which is held while any of its
+// writes are in progress, or until Close if opened with LockWriter.
+type writerLock struct {
+	mu      sync.Mutex
+	file    *os.File // nil if the lock is not held
+	holders int
+}
+
+// lockWriter acquires the writer lock of the mbtiles file for the handle, or
+// returns ErrLocked if another handle holds it.  Writes of the same handle
+// share the lock.
+func (db *MBtiles) lockWriter() error {
+	l := &db.writer
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holders == 0 {
+		f, err := openLockFile(db.filename + LockExtension)
+		if err != nil {
+			return err
+		}
+		l.file = f
+	}
+	l.holders++
+	return nil
+}
+
+// openLockFile creates the lock file at name if needed, and returns it once
+// it is locked.  The lock file is removed by its holder when released, so it
+// is opened again if it was removed before it was locked.
+func openLockFile(name string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(name); err == nil && os.SameFile(info, current) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// closeLockFile removes and closes a lock file returned by openLockFile,
+// which releases the lock.
+func closeLockFile(f *os.File) {
+	os.Remove(f.Name())
+	f.Close()
+}
+
+// unlockWriter releases the writer lock acquired by lockWriter once it is no
+// longer held by any writes of the handle.
+func (db *MBtiles) unlockWriter() {
+	l := &db.writer
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holders == 0 {
+		return
+	}
+	if l.holders--; l.holders == 0 {
+		closeLockFile(l.file)
+		l.file = nil
+	}
+}
+
+// release releases the writer lock on Close, even if writes of the handle are
+// still in progress.
+func (l *writerLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		closeLockFile(l.file)
+		l.file = nil
+	}
+	l.holders = 0
+}
+
+// writeTx is a write transaction of a handle, which holds the writer lock of
+// the mbtiles file until it is committed or rolled back.
+type writeTx struct {
+	*sql.Tx
+	unlock sync.Once
+	db     *MBtiles
+}
+
+// beginWrite begins a write transaction of the handle, once it holds the
+// writer lock of the mbtiles file.
+func (db *MBtiles) beginWrite(ctx context.Context) (*writeTx, error) {
+	if err := db.lockWriter(); err != nil {
+		return nil, err
+	}
+	tx, err := db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		db.unlockWriter()
+		return nil, err
+	}
+	return &writeTx{Tx: tx, db: db}, nil
+}
+
+func (tx *writeTx) Commit() error {
+	defer tx.unlock.Do(tx.db.unlockWriter)
+	return tx.Tx.Commit()
+}
+
+func (tx *writeTx) Rollback() error {
+	defer tx.unlock.Do(tx.db.unlockWriter)
+	return tx.Tx.Rollback()
+}
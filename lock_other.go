@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package mbtiles
+
+import "os"
+
+// lockFile does nothing on platforms without flock; writes are only locked by
+// SQLite.
+func lockFile(f *os.File) error {
+	return nil
+}
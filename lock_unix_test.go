@@ -0,0 +1,60 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func Test_LockWriter(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	writer, err := Open(filename, LockWriter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if _, err := os.Stat(filename + LockExtension); err != nil {
+		t.Error("Expected lock file while lock is held, got:", err)
+	}
+
+	if _, err := Open(filename, LockWriter()); !errors.Is(err, ErrLocked) {
+		t.Error("Expected ErrLocked opening second writer, got:", err)
+	}
+	other, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Error("Expected reads to ignore the writer lock, got:", err)
+	}
+	if _, err := other.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 1, 1); !errors.Is(err, ErrLocked) {
+		t.Error("Expected ErrLocked writing while locked, got:", err)
+	}
+	if report, err := other.DeleteTilesInBoundsDryRun(ctx, []float64{-180, -85, 180, 85}, 1, 1); err != nil || report.Tiles != 4 {
+		t.Error("Expected dry run to ignore the writer lock, got:", report, err)
+	}
+	if err := other.UpdateMetadata(ctx, func(m *Metadata) error { m.Name = "renamed"; return nil }); !errors.Is(err, ErrLocked) {
+		t.Error("Expected ErrLocked updating metadata while locked, got:", err)
+	}
+
+	// writes of the handle holding the lock succeed
+	if deleted, err := writer.DeleteTilesInBounds(ctx, []float64{-180, -85, -1, 85}, 1, 1); err != nil || deleted != 2 {
+		t.Error("Expected writer to delete tiles, got:", deleted, err)
+	}
+
+	writer.Close()
+	if _, err := os.Stat(filename + LockExtension); !os.IsNotExist(err) {
+		t.Error("Expected lock file to be removed on Close, got:", err)
+	}
+	if deleted, err := other.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 1, 1); err != nil || deleted != 2 {
+		t.Error("Expected write after lock is released, got:", deleted, err)
+	}
+	if _, err := os.Stat(filename + LockExtension); !os.IsNotExist(err) {
+		t.Error("Expected lock file to be removed after write, got:", err)
+	}
+}
@@ -0,0 +1,47 @@
+package mbtiles
+
+import (
+	"log"
+	"time"
+)
+
+// Logger is used to log messages from an MBtiles handle; it is satisfied by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// UseLogger sets the logger used for messages from the MBtiles handle.  The
+// default is log.Default().
+func UseLogger(logger Logger) OpenOption {
+	return func(o *openOptions) {
+		o.logger = logger
+	}
+}
+
+// LogSlowQueries logs any tile or metadata read that takes longer than
+// threshold, including the tile coordinates and size for tile reads, to help
+// diagnose pathological tiles and disk issues.
+func LogSlowQueries(threshold time.Duration) OpenOption {
+	return func(o *openOptions) {
+		o.slowQueryThreshold = threshold
+	}
+}
+
+// logger returns the logger for the MBtiles handle.
+func (db *MBtiles) logger() Logger {
+	if db.options.logger != nil {
+		return db.options.logger
+	}
+	return log.Default()
+}
+
+// logSlowQuery logs a message if more than the slow query threshold has
+// elapsed since start.  format and v describe the query, as for Printf.
+func (db *MBtiles) logSlowQuery(start time.Time, format string, v ...interface{}) {
+	elapsed := time.Since(start)
+	if elapsed <= db.options.slowQueryThreshold {
+		return
+	}
+	db.logger().Printf("mbtiles: slow query on %s took %v: "+format, append([]interface{}{db.filename, elapsed}, v...)...)
+}
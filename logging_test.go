@@ -0,0 +1,48 @@
+package mbtiles
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LogSlowQueries(t *testing.T) {
+	tests := []struct {
+		threshold time.Duration
+		logged    bool
+	}{
+		{threshold: time.Nanosecond, logged: true},
+		{threshold: time.Hour, logged: false},
+	}
+
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		db, err := Open("./testdata/geography-class-png.mbtiles", LogSlowQueries(tc.threshold), UseLogger(log.New(&buf, "", 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var data []byte
+		if err := db.ReadTile(0, 0, 0, &data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ReadMetadata(); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		output := buf.String()
+		if tc.logged {
+			if !strings.Contains(output, "tile 0/0/0 (21246 bytes)") {
+				t.Error("Slow tile query was not logged, got:", output)
+			}
+			if !strings.Contains(output, "metadata") {
+				t.Error("Slow metadata query was not logged, got:", output)
+			}
+		} else if output != "" {
+			t.Error("Query under threshold was logged:", output)
+		}
+	}
+}
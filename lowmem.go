@@ -0,0 +1,59 @@
+package mbtiles
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Settings of LowMemory
+const (
+	lowMemoryPageCacheKiB = 512      // SQLite page cache of each connection
+	lowMemoryMaxConns     = 2        // open connections of the pool
+	lowMemoryIdleConns    = 1        // idle connections kept by the pool
+	lowMemoryChunkSize    = 64 << 10 // bytes read by each query of a TileReader
+	lowMemoryMaxTileSize  = 16 << 20 // default MaxTileSize
+)
+
+// LowMemory tunes the handle for devices with little memory, such as
+// Raspberry Pi-class servers, at the cost of throughput.  The pool keeps at
+// most 2 connections open and 1 idle, and each connection has a 512 KiB SQLite
+// page cache, no memory-mapped I/O, and temporary tables in files.  Tiles are
+// not cached in memory, as with CacheTiles(0); TileReader, and so ServeTile,
+// read tiles in chunks of 64 KiB; and tiles larger than 16 MiB are not read,
+// as with MaxTileSize(16 << 20).  CacheTiles and MaxTileSize options after
+// LowMemory override its settings.
+func LowMemory() OpenOption {
+	return func(o *openOptions) {
+		o.lowMemory = true
+		o.cacheSize = 0
+		o.maxTileSize = lowMemoryMaxTileSize
+	}
+}
+
+// lowMemoryParams adds the SQLite pragmas of LowMemory to the parameters of
+// a file: URI, which may be nil.
+func lowMemoryParams(params url.Values) url.Values {
+	if params == nil {
+		params = make(url.Values)
+	}
+	params.Add("_pragma", "cache_size(-"+strconv.Itoa(lowMemoryPageCacheKiB)+")")
+	params.Add("_pragma", "mmap_size(0)")
+	params.Add("_pragma", "temp_store(file)")
+	return params
+}
+
+// chunkSize returns the number of bytes read by each query of a TileReader.
+func (db *MBtiles) chunkSize() int {
+	if db.options.lowMemory {
+		return lowMemoryChunkSize
+	}
+	return defaultTileChunkSize
+}
+
+// maxIdleConns returns the number of idle connections kept by the pool.
+func (db *MBtiles) maxIdleConns() int {
+	if db.options.lowMemory {
+		return lowMemoryIdleConns
+	}
+	return defaultMaxIdleConns
+}
@@ -0,0 +1,53 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_LowMemory(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles", LowMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var cacheSize, mmapSize int64
+	if err := db.pool.QueryRowContext(ctx, "pragma cache_size").Scan(&cacheSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.pool.QueryRowContext(ctx, "pragma mmap_size").Scan(&mmapSize); err != nil {
+		t.Fatal(err)
+	}
+	if cacheSize != -lowMemoryPageCacheKiB || mmapSize != 0 {
+		t.Error("Pragmas do not match expected values, got:", cacheSize, mmapSize)
+	}
+	if stats := db.pool.Stats(); stats.MaxOpenConnections != lowMemoryMaxConns {
+		t.Error("Expected pool to be limited, got:", stats.MaxOpenConnections)
+	}
+	if db.cache != nil || db.options.maxTileSize != lowMemoryMaxTileSize {
+		t.Error("Expected tile cache to be disabled and tile size to be limited")
+	}
+
+	if _, err := db.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Error("Could not read tile:", err)
+	}
+	tile, err := db.OpenTile(ctx, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := tile.Read(make([]byte, 2*lowMemoryChunkSize)); err != nil || n > lowMemoryChunkSize {
+		t.Error("Expected reads of at most one chunk, got:", n, err)
+	}
+
+	// later options override LowMemory
+	cached, err := Open("./testdata/geography-class-png.mbtiles", LowMemory(), CacheTiles(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cached.Close()
+	if cached.cache == nil {
+		t.Error("Expected tile cache to be enabled")
+	}
+}
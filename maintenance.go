@@ -0,0 +1,456 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoTileTimestamps is returned by operations that require per-tile
+// timestamps if the mbtiles file does not have them.
+var ErrNoTileTimestamps = errors.New("mbtiles file does not have per-tile timestamps")
+
+// DryRunReport describes the tiles that a destructive operation would delete,
+// as reported by its dry-run variant without changing the mbtiles file.
+type DryRunReport struct {
+	Tiles int64 // number of tiles that would be deleted
+	// Bytes is the total size of the tile data that would be deleted.  For
+	// deduplicated schemas, tile images that are shared by several tiles are
+	// counted once per tile, so the space freed may be smaller.
+	Bytes int64
+}
+
+// DeleteTilesInBounds deletes all tiles that intersect bounds: [west, south,
+// east, north] in degrees, for zoom levels minZoom through maxZoom (inclusive),
+// and returns the number of tiles deleted.  All tiles are deleted in a single
+// transaction.
+//
+// For deduplicated schemas (where tiles is a view of map and images tables),
+// the tiles are deleted from the map table, and tile images that are no longer
+// referenced are then deleted.
+func (db *MBtiles) DeleteTilesInBounds(ctx context.Context, bounds []float64, minZoom int, maxZoom int) (int64, error) {
+	report, err := db.deleteTilesInBounds(ctx, bounds, minZoom, maxZoom, false)
+	return report.Tiles, err
+}
+
+// DeleteTilesInBoundsDryRun reports the tiles that DeleteTilesInBounds would
+// delete, without changing the mbtiles file.
+func (db *MBtiles) DeleteTilesInBoundsDryRun(ctx context.Context, bounds []float64, minZoom int, maxZoom int) (DryRunReport, error) {
+	return db.deleteTilesInBounds(ctx, bounds, minZoom, maxZoom, true)
+}
+
+// deleteTilesInBounds deletes tiles as described for DeleteTilesInBounds, or
+// only reports them if dryRun is true.  Only the number of tiles is reported
+// if they are deleted.
+func (db *MBtiles) deleteTilesInBounds(ctx context.Context, bounds []float64, minZoom int, maxZoom int, dryRun bool) (DryRunReport, error) {
+	if db.isClosed() {
+		return DryRunReport{}, errors.New("cannot delete tiles from closed mbtiles database")
+	}
+	if err := validateBounds(bounds); err != nil {
+		return DryRunReport{}, err
+	}
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return DryRunReport{}, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+
+	tx, schema, err := db.beginMaintenance(ctx, dryRun)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	defer tx.Rollback()
+	q := db.traced(tx)
+	table, deduplicated := schema.table, schema.deduplicated
+
+	var report DryRunReport
+	for z := int64(minZoom); z <= int64(maxZoom); z++ {
+		topLeft, bottomRight, err := tileRange(bounds, z)
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		// tile rows are stored in the TMS scheme, so the range is flipped
+		minRow, maxRow := bottomRight.FlipY().Y, topLeft.FlipY().Y
+
+		where := "zoom_level = ? and tile_column between ? and ? and tile_row between ? and ?"
+		if dryRun {
+			measured, err := measureTiles(ctx, q, table, where, z, topLeft.X, bottomRight.X, minRow, maxRow)
+			if err != nil {
+				return DryRunReport{}, err
+			}
+			report.Tiles += measured.Tiles
+			report.Bytes += measured.Bytes
+			continue
+		}
+		if err := logDeletes(ctx, q, schema, where, z, topLeft.X, bottomRight.X, minRow, maxRow); err != nil {
+			return DryRunReport{}, err
+		}
+		result, err := q.ExecContext(ctx, "delete from "+table+" where "+where, z, topLeft.X, bottomRight.X, minRow, maxRow)
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		report.Tiles += count
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	if report.Tiles > 0 {
+		if deduplicated {
+			if err := deleteUnreferencedImages(ctx, q); err != nil {
+				return DryRunReport{}, err
+			}
+		}
+		if err := db.bumpVersion(ctx, q); err != nil {
+			return DryRunReport{}, err
+		}
+		if err := db.syncMetadata(ctx, q, schema); err != nil {
+			return DryRunReport{}, err
+		}
+		parameters := map[string]interface{}{"bounds": bounds, "minzoom": minZoom, "maxzoom": maxZoom, "deleted": report.Tiles}
+		if err := db.recordHistory(ctx, q, "delete_tiles", parameters); err != nil {
+			return DryRunReport{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DryRunReport{}, err
+	}
+	db.tilesChanged()
+	return report, nil
+}
+
+// PruneOlderThan deletes all tiles last modified before cutoff, and returns
+// the number of tiles deleted.
+//
+// This requires per-tile timestamps in a last_modified column of the tiles
+// table (or map table for deduplicated schemas), stored either as Unix time in
+// seconds or as text in a format understood by SQLite's datetime() function.
+// ErrNoTileTimestamps is returned if the column is not present.
+func (db *MBtiles) PruneOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	report, err := db.pruneOlderThan(ctx, cutoff, false)
+	return report.Tiles, err
+}
+
+// PruneOlderThanDryRun reports the tiles that PruneOlderThan would delete,
+// without changing the mbtiles file.
+func (db *MBtiles) PruneOlderThanDryRun(ctx context.Context, cutoff time.Time) (DryRunReport, error) {
+	return db.pruneOlderThan(ctx, cutoff, true)
+}
+
+// pruneOlderThan deletes tiles as described for PruneOlderThan, or only
+// reports them if dryRun is true.  Only the number of tiles is reported if they
+// are deleted.
+func (db *MBtiles) pruneOlderThan(ctx context.Context, cutoff time.Time, dryRun bool) (DryRunReport, error) {
+	if db.isClosed() {
+		return DryRunReport{}, errors.New("cannot prune tiles from closed mbtiles database")
+	}
+
+	tx, schema, err := db.beginMaintenance(ctx, dryRun)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	defer tx.Rollback()
+	q := db.traced(tx)
+	table, deduplicated := schema.table, schema.deduplicated
+	hasTimestamps, err := hasColumn(ctx, q, table, "last_modified")
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	if !hasTimestamps {
+		return DryRunReport{}, ErrNoTileTimestamps
+	}
+
+	where := "(typeof(last_modified) in ('integer', 'real') and last_modified < ?) or (typeof(last_modified) = 'text' and datetime(last_modified) < datetime(?))"
+	args := []interface{}{cutoff.Unix(), cutoff.UTC().Format("2006-01-02 15:04:05")}
+	if dryRun {
+		return measureTiles(ctx, q, table, where, args...)
+	}
+	if err := logDeletes(ctx, q, schema, where, args...); err != nil {
+		return DryRunReport{}, err
+	}
+	result, err := q.ExecContext(ctx, "delete from "+table+" where "+where, args...)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	if deleted > 0 {
+		if deduplicated {
+			if err := deleteUnreferencedImages(ctx, q); err != nil {
+				return DryRunReport{}, err
+			}
+		}
+		if err := db.bumpVersion(ctx, q); err != nil {
+			return DryRunReport{}, err
+		}
+		if err := db.syncMetadata(ctx, q, schema); err != nil {
+			return DryRunReport{}, err
+		}
+		parameters := map[string]interface{}{"cutoff": cutoff.UTC().Format(time.RFC3339), "deleted": deleted}
+		if err := db.recordHistory(ctx, q, "prune", parameters); err != nil {
+			return DryRunReport{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DryRunReport{}, err
+	}
+	db.tilesChanged()
+	return DryRunReport{Tiles: deleted}, nil
+}
+
+// EvictionPolicy determines which tiles are deleted first by EnforceMaxSize.
+type EvictionPolicy uint8
+
+// EvictionPolicy values
+const (
+	EvictHighestZoom EvictionPolicy = iota // delete tiles at the highest zoom levels first
+	EvictOldest                            // delete least recently written tiles first; requires per-tile timestamps
+)
+
+// EnforceMaxSize deletes tiles until the total size of tile data is at most
+// maxBytes, in the order determined by policy, and returns the number of tiles
+// deleted.  All tiles are deleted in a single transaction.
+//
+// Size is measured as the total length of tile data in the tiles table; for
+// deduplicated schemas, tile images that are shared by several tiles are counted
+// once per tile.  Space freed within the file is reused by subsequent writes, but
+// the file itself does not shrink unless it is vacuumed.
+//
+// EvictOldest requires per-tile timestamps as described for PruneOlderThan;
+// ErrNoTileTimestamps is returned if they are not present.
+func (db *MBtiles) EnforceMaxSize(ctx context.Context, maxBytes int64, policy EvictionPolicy) (int64, error) {
+	report, err := db.enforceMaxSize(ctx, maxBytes, policy, false)
+	return report.Tiles, err
+}
+
+// EnforceMaxSizeDryRun reports the tiles that EnforceMaxSize would delete,
+// without changing the mbtiles file.
+func (db *MBtiles) EnforceMaxSizeDryRun(ctx context.Context, maxBytes int64, policy EvictionPolicy) (DryRunReport, error) {
+	return db.enforceMaxSize(ctx, maxBytes, policy, true)
+}
+
+// enforceMaxSize deletes tiles as described for EnforceMaxSize, or only
+// reports them if dryRun is true.
+func (db *MBtiles) enforceMaxSize(ctx context.Context, maxBytes int64, policy EvictionPolicy, dryRun bool) (DryRunReport, error) {
+	if db.isClosed() {
+		return DryRunReport{}, errors.New("cannot evict tiles from closed mbtiles database")
+	}
+
+	tx, schema, err := db.beginMaintenance(ctx, dryRun)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	defer tx.Rollback()
+	q := db.traced(tx)
+	table, deduplicated := schema.table, schema.deduplicated
+
+	var order string
+	switch policy {
+	case EvictHighestZoom:
+		order = "t.zoom_level desc, t.tile_column, t.tile_row"
+	case EvictOldest:
+		hasTimestamps, err := hasColumn(ctx, q, table, "last_modified")
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		if !hasTimestamps {
+			return DryRunReport{}, ErrNoTileTimestamps
+		}
+		order = "c.last_modified, t.zoom_level desc"
+	default:
+		return DryRunReport{}, fmt.Errorf("unknown eviction policy: %d", policy)
+	}
+
+	var total int64
+	err = q.QueryRowContext(ctx, "select coalesce(sum(length(tile_data)), 0) from tiles").Scan(&total)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	if total <= maxBytes {
+		return DryRunReport{}, nil
+	}
+
+	// join the tiles table to the table of tile coordinates, which is the same
+	// table for non-deduplicated schemas, to order by its last_modified column
+	rows, err := q.QueryContext(ctx, "select t.zoom_level, t.tile_column, t.tile_row, length(t.tile_data) from tiles t join "+table+
+		" c on c.zoom_level = t.zoom_level and c.tile_column = t.tile_column and c.tile_row = t.tile_row order by "+order)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	var (
+		evict []TileCoord
+		freed int64
+	)
+	for rows.Next() && total > maxBytes {
+		var (
+			coord TileCoord
+			size  int64
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &size); err != nil {
+			rows.Close()
+			return DryRunReport{}, err
+		}
+		evict = append(evict, coord)
+		total -= size
+		freed += size
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return DryRunReport{}, err
+	}
+	if err := rows.Close(); err != nil {
+		return DryRunReport{}, err
+	}
+	report := DryRunReport{Tiles: int64(len(evict)), Bytes: freed}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, coord := range evict {
+		if err := logChange(ctx, q, schema, ChangeDelete, coord, ""); err != nil {
+			return DryRunReport{}, err
+		}
+		if _, err := q.ExecContext(ctx, "delete from "+table+" where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y); err != nil {
+			return DryRunReport{}, err
+		}
+	}
+
+	if len(evict) > 0 {
+		if deduplicated {
+			if err := deleteUnreferencedImages(ctx, q); err != nil {
+				return DryRunReport{}, err
+			}
+		}
+		if err := db.bumpVersion(ctx, q); err != nil {
+			return DryRunReport{}, err
+		}
+		if err := db.syncMetadata(ctx, q, schema); err != nil {
+			return DryRunReport{}, err
+		}
+		parameters := map[string]interface{}{"max_bytes": maxBytes, "policy": policy, "deleted": len(evict)}
+		if err := db.recordHistory(ctx, q, "enforce_max_size", parameters); err != nil {
+			return DryRunReport{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return DryRunReport{}, err
+	}
+	db.tilesChanged()
+	return report, nil
+}
+
+// maintenanceTx is the transaction of a maintenance operation, as begun by
+// beginMaintenance.
+type maintenanceTx interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// beginMaintenance begins a write transaction that holds the writer lock, and
+// returns it with the schema to write tiles with.  Dry runs, which do not
+// change the mbtiles file, use a read-only transaction instead, so that they
+// are not blocked by other writers and work on read-only file systems.
+func (db *MBtiles) beginMaintenance(ctx context.Context, dryRun bool) (maintenanceTx, tileSchema, error) {
+	if dryRun {
+		tx, err := db.pool.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, tileSchema{}, err
+		}
+		schema, err := readTileSchema(ctx, db.traced(tx))
+		if err != nil {
+			tx.Rollback()
+			return nil, tileSchema{}, err
+		}
+		return tx, schema, nil
+	}
+
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return nil, tileSchema{}, err
+	}
+	schema, err := db.writeSchema(ctx, db.traced(tx))
+	if err != nil {
+		tx.Rollback()
+		return nil, tileSchema{}, err
+	}
+	return tx, schema, nil
+}
+
+// tileSchema describes how tiles are stored in an mbtiles file.
+type tileSchema struct {
+	// table holds tile coordinates: tiles, map if the database uses the
+	// deduplicated schema, where tiles is a view that joins a map table of tile
+	// coordinates to an images table of tile data, or tiles_with_hash if tiles
+	// is a view of a table of tiles with hashes.
+	table        string
+	deduplicated bool
+	// hashed is true if table has a tile_hash column with the MD5 hash of the
+	// tile data.
+	hashed bool
+	// logged is true if changes are recorded in the changelog table.
+	logged bool
+}
+
+// readTileSchema determines how tiles are stored in the mbtiles file.
+func readTileSchema(ctx context.Context, tx querier) (tileSchema, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, "select count(*) from sqlite_master where (type = 'view' and name = 'tiles') or (type = 'table' and name in ('map', 'images'))").Scan(&count)
+	if err != nil {
+		return tileSchema{}, err
+	}
+
+	schema := tileSchema{table: "tiles"}
+	if count == 3 {
+		schema = tileSchema{table: "map", deduplicated: true}
+	} else if count == 1 {
+		err := tx.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'tiles_with_hash'").Scan(&count)
+		if err != nil {
+			return tileSchema{}, err
+		}
+		if count == 1 {
+			schema.table = "tiles_with_hash"
+		}
+	}
+
+	schema.hashed, err = hasColumn(ctx, tx, schema.table, "tile_hash")
+	if err != nil {
+		return tileSchema{}, err
+	}
+	return schema, nil
+}
+
+// hasColumn returns true if table has the named column.
+func hasColumn(ctx context.Context, tx querier, table string, column string) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, "select count(*) from pragma_table_info(?) where name = ?", table, column).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// measureTiles returns the number and total size of the tiles in table that
+// match where, for a dry run of deleting them.
+func measureTiles(ctx context.Context, tx querier, table string, where string, args ...interface{}) (DryRunReport, error) {
+	var report DryRunReport
+	err := tx.QueryRowContext(ctx, "select count(*), coalesce(sum(length(tile_data)), 0) from tiles where (zoom_level, tile_column, tile_row) in (select zoom_level, tile_column, tile_row from "+table+" where "+where+")", args...).Scan(&report.Tiles, &report.Bytes)
+	return report, err
+}
+
+// deleteUnreferencedImages deletes tile images in a deduplicated schema that
+// are no longer referenced by the map table.
+func deleteUnreferencedImages(ctx context.Context, tx querier) error {
+	_, err := tx.ExecContext(ctx, "delete from images where tile_id not in (select tile_id from map where tile_id is not null)")
+	return err
+}
@@ -0,0 +1,293 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_DeleteTilesInBounds(t *testing.T) {
+	tests := []struct {
+		path    string
+		bounds  []float64
+		minzoom int
+		maxzoom int
+		deleted int64
+	}{
+		// western hemisphere at zoom 1 is tile column 0
+		{path: "geography-class-png.mbtiles", bounds: []float64{-180, -85, -1, 85}, minzoom: 1, maxzoom: 1, deleted: 2},
+		// north west quadrant at zoom 1 is tile 1/0/0 (XYZ)
+		{path: "geography-class-png.mbtiles", bounds: []float64{-170, 1, -1, 80}, minzoom: 0, maxzoom: 1, deleted: 2},
+		{path: "world_cities.mbtiles", bounds: []float64{-180, -85, 180, 85}, minzoom: 5, maxzoom: 6, deleted: 129},
+		{path: "world_cities.mbtiles", bounds: []float64{-180, -85, 180, 85}, minzoom: 10, maxzoom: 12, deleted: 0},
+	}
+
+	for _, tc := range tests {
+		filename := copyTestdata(t, tc.path)
+		db, err := Open(filename)
+		if err != nil {
+			t.Fatal("Could not open:", filename, err)
+		}
+
+		deleted, err := db.DeleteTilesInBounds(context.Background(), tc.bounds, tc.minzoom, tc.maxzoom)
+		db.Close()
+		if err != nil {
+			t.Error("Unexpected error deleting tiles for:", tc.path, tc.bounds, err)
+			continue
+		}
+		if deleted != tc.deleted {
+			t.Error("Deleted", deleted, "tiles, expected", tc.deleted, "for:", tc.path, tc.bounds)
+		}
+	}
+}
+
+func Test_DeleteTilesInBounds_tiles(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal("Could not open:", filename, err)
+	}
+	defer db.Close()
+
+	// delete the north west tile at zoom 1, which is row 1 in the TMS scheme
+	_, err = db.DeleteTilesInBounds(context.Background(), []float64{-170, 1, -1, 80}, 1, 1)
+	if err != nil {
+		t.Fatal("Unexpected error deleting tiles:", err)
+	}
+
+	tests := []struct {
+		z      int64
+		x      int64
+		y      int64
+		exists bool
+	}{
+		{z: 1, x: 0, y: 1, exists: false},
+		{z: 1, x: 0, y: 0, exists: true},
+		{z: 1, x: 1, y: 1, exists: true},
+		{z: 0, x: 0, y: 0, exists: true},
+	}
+
+	for _, tc := range tests {
+		var data []byte
+		if err := db.ReadTile(tc.z, tc.x, tc.y, &data); err != nil {
+			t.Error("Unexpected error reading tile:", tc.z, tc.x, tc.y, err)
+			continue
+		}
+		if (data != nil) != tc.exists {
+			t.Error("Tile", tc.z, tc.x, tc.y, "existence does not match expected value", tc.exists)
+		}
+	}
+
+	var images int
+	if err := db.pool.QueryRow("select count(*) from images").Scan(&images); err != nil {
+		t.Fatal(err)
+	}
+	var referenced int
+	if err := db.pool.QueryRow("select count(distinct tile_id) from map").Scan(&referenced); err != nil {
+		t.Fatal(err)
+	}
+	if images != referenced {
+		t.Error("Unreferenced images were not deleted, found", images, "images for", referenced, "tiles")
+	}
+}
+
+func Test_DeleteTilesInBounds_invalid(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		bounds  []float64
+		minzoom int
+		maxzoom int
+	}{
+		{bounds: []float64{0, 0, 1}, minzoom: 0, maxzoom: 1},
+		{bounds: []float64{10, 0, 0, 10}, minzoom: 0, maxzoom: 1},
+		{bounds: []float64{0, 0, 10, 10}, minzoom: 2, maxzoom: 1},
+	}
+
+	for _, tc := range tests {
+		if _, err := db.DeleteTilesInBounds(context.Background(), tc.bounds, tc.minzoom, tc.maxzoom); err == nil {
+			t.Error("Invalid parameters did not raise error:", tc.bounds, tc.minzoom, tc.maxzoom)
+		}
+	}
+}
+
+func Test_PruneOlderThan(t *testing.T) {
+	cutoff := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		path    string
+		update  string
+		deleted int64
+	}{
+		// Unix timestamps: zoom levels 0-2 are before the cutoff (12 tiles)
+		{
+			path:    "world_cities.mbtiles",
+			update:  "alter table tiles add column last_modified integer; update tiles set last_modified = case when zoom_level <= 2 then 1500000000 else 1700000000 end",
+			deleted: 12,
+		},
+		// text timestamps in deduplicated schema: zoom level 1 is before the cutoff
+		{
+			path:    "geography-class-png.mbtiles",
+			update:  "alter table map add column last_modified text; update map set last_modified = case when zoom_level = 1 then '2019-12-31 23:59:59' else '2020-01-01 00:00:00' end",
+			deleted: 4,
+		},
+	}
+
+	for _, tc := range tests {
+		filename := copyTestdata(t, tc.path)
+		db, err := Open(filename)
+		if err != nil {
+			t.Fatal("Could not open:", filename, err)
+		}
+		if _, err := db.pool.Exec(tc.update); err != nil {
+			t.Fatal(err)
+		}
+
+		deleted, err := db.PruneOlderThan(context.Background(), cutoff)
+		db.Close()
+		if err != nil {
+			t.Error("Unexpected error pruning tiles for:", tc.path, err)
+			continue
+		}
+		if deleted != tc.deleted {
+			t.Error("Pruned", deleted, "tiles, expected", tc.deleted, "for:", tc.path)
+		}
+	}
+}
+
+func Test_PruneOlderThan_no_timestamps(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.PruneOlderThan(context.Background(), time.Now())
+	if err != ErrNoTileTimestamps {
+		t.Error("Pruning without per-tile timestamps did not return ErrNoTileTimestamps, got:", err)
+	}
+}
+
+func Test_EnforceMaxSize(t *testing.T) {
+	tests := []struct {
+		path     string
+		update   string
+		maxBytes int64
+		policy   EvictionPolicy
+		deleted  int64
+		remains  []TileCoord
+	}{
+		// total size of tiles is 88472 bytes; zoom level 1 tiles are deleted first
+		{path: "geography-class-png.mbtiles", maxBytes: 100000, policy: EvictHighestZoom, deleted: 0},
+		{path: "geography-class-png.mbtiles", maxBytes: 21246, policy: EvictHighestZoom, deleted: 4, remains: []TileCoord{{Z: 0, X: 0, Y: 0}}},
+		{path: "geography-class-png.mbtiles", maxBytes: 0, policy: EvictHighestZoom, deleted: 5},
+		// zoom level 6 tiles (4917 bytes total) are the oldest
+		{
+			path:     "world_cities.mbtiles",
+			update:   "alter table tiles add column last_modified integer; update tiles set last_modified = case when zoom_level = 6 then 1 else 2 end",
+			maxBytes: 18861 - 4917,
+			policy:   EvictOldest,
+			deleted:  72,
+			remains:  []TileCoord{{Z: 5, X: 5, Y: 19}, {Z: 0, X: 0, Y: 0}},
+		},
+	}
+
+	for _, tc := range tests {
+		filename := copyTestdata(t, tc.path)
+		db, err := Open(filename)
+		if err != nil {
+			t.Fatal("Could not open:", filename, err)
+		}
+		if tc.update != "" {
+			if _, err := db.pool.Exec(tc.update); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		deleted, err := db.EnforceMaxSize(context.Background(), tc.maxBytes, tc.policy)
+		if err != nil {
+			t.Error("Unexpected error enforcing max size for:", tc.path, err)
+			db.Close()
+			continue
+		}
+		if deleted != tc.deleted {
+			t.Error("Evicted", deleted, "tiles, expected", tc.deleted, "for:", tc.path, tc.maxBytes)
+		}
+		for _, coord := range tc.remains {
+			var data []byte
+			if err := db.ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || data == nil {
+				t.Error("Tile", coord, "was unexpectedly evicted for:", tc.path, tc.maxBytes)
+			}
+		}
+		db.Close()
+	}
+}
+
+func Test_EnforceMaxSize_no_timestamps(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.EnforceMaxSize(context.Background(), 0, EvictOldest)
+	if err != ErrNoTileTimestamps {
+		t.Error("Evicting oldest tiles without per-tile timestamps did not return ErrNoTileTimestamps, got:", err)
+	}
+}
+
+func Test_DryRun(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal("Could not open:", filename, err)
+	}
+	defer db.Close()
+	if _, err := db.pool.Exec("alter table map add column last_modified text; update map set last_modified = case when zoom_level = 1 then '2019-12-31 23:59:59' else '2020-01-01 00:00:00' end"); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int64 {
+		var count int64
+		if err := db.pool.QueryRow("select count(*) from tiles").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	report, err := db.DeleteTilesInBoundsDryRun(ctx, []float64{-180, -85, -1, 85}, 1, 1)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if report.Tiles != 2 || report.Bytes <= 0 {
+		t.Error("Delete report does not match expected values, got:", report)
+	}
+
+	report, err = db.PruneOlderThanDryRun(ctx, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if report.Tiles != 4 || report.Bytes != 88472-21246 {
+		t.Error("Prune report does not match expected values, got:", report)
+	}
+
+	// total size of tiles is 88472 bytes; zoom level 1 tiles are deleted first
+	report, err = db.EnforceMaxSizeDryRun(ctx, 21246, EvictHighestZoom)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if report.Tiles != 4 || report.Bytes != 88472-21246 {
+		t.Error("Eviction report does not match expected values, got:", report)
+	}
+
+	if got := count(); got != 5 {
+		t.Error("Expected dry runs to keep all tiles, got:", got)
+	}
+	if deleted, err := db.EnforceMaxSize(ctx, 21246, EvictHighestZoom); err != nil || deleted != report.Tiles {
+		t.Error("Evicted tiles do not match dry run, got:", deleted, err)
+	}
+}
@@ -0,0 +1,139 @@
+package mbtiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ManifestExtension is the extension added to the path of an mbtiles file
+	// to get the path of its chunk manifest.
+	ManifestExtension = ".manifest.json"
+	// DefaultChunkSize is the chunk size used by WriteChunkManifest if the
+	// chunk size is not greater than 0.
+	DefaultChunkSize = 16 << 20
+)
+
+// ChunkManifest describes an mbtiles file as a list of fixed size chunks
+// with their SHA-256 checksums, so that large files can be downloaded in
+// parallel, resumed and verified chunk by chunk, as done by Download.  All
+// chunks have ChunkSize bytes except the last, which can be shorter.
+type ChunkManifest struct {
+	Name      string   `json:"name"` // base name of the file
+	Size      int64    `json:"size"`
+	ChunkSize int64    `json:"chunk_size"`
+	SHA256    string   `json:"sha256"` // checksum of the whole file, in hexadecimal
+	Chunks    []string `json:"chunks"` // checksum of each chunk, in hexadecimal
+}
+
+// NewChunkManifest computes the chunk manifest of the file at path, reading it
+// once.  The main database file only is included; tilesets in WAL mode must be
+// checkpointed first so that all changes are included.
+func NewChunkManifest(path string, chunkSize int64) (*ChunkManifest, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := &ChunkManifest{Name: filepath.Base(path), ChunkSize: chunkSize}
+	file := sha256.New()
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			chunk := sha256.Sum256(buf[:n])
+			manifest.Chunks = append(manifest.Chunks, hex.EncodeToString(chunk[:]))
+			file.Write(buf[:n])
+			manifest.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	manifest.SHA256 = hex.EncodeToString(file.Sum(nil))
+	return manifest, nil
+}
+
+// WriteChunkManifest computes the chunk manifest of the mbtiles file at path,
+// as described for NewChunkManifest, and writes it as JSON to a file at path
+// with ManifestExtension added.  If chunkSize is not greater than 0,
+// DefaultChunkSize is used.
+func WriteChunkManifest(path string, chunkSize int64) (*ChunkManifest, error) {
+	manifest, err := NewChunkManifest(path, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+ManifestExtension, data, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadChunkManifest reads and validates a chunk manifest written by
+// WriteChunkManifest.
+func ReadChunkManifest(r io.Reader) (*ChunkManifest, error) {
+	var manifest ChunkManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("cannot parse chunk manifest: %w", err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("invalid chunk manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// validate returns an error if the number of chunks does not match the size
+// of the file, or if checksums are not valid.
+func (m *ChunkManifest) validate() error {
+	if m.Size < 0 || m.ChunkSize <= 0 {
+		return errors.New("size must not be negative and chunk size must be greater than 0")
+	}
+	if expected := (m.Size + m.ChunkSize - 1) / m.ChunkSize; int64(len(m.Chunks)) != expected {
+		return fmt.Errorf("expected %d chunks for %d bytes, got %d", expected, m.Size, len(m.Chunks))
+	}
+	for _, checksum := range append([]string{m.SHA256}, m.Chunks...) {
+		if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+			return fmt.Errorf("invalid SHA-256 checksum %q", checksum)
+		}
+	}
+	return nil
+}
+
+// ChunkRange returns the offset and length in bytes of chunk i.
+func (m *ChunkManifest) ChunkRange(i int) (int64, int64) {
+	offset := int64(i) * m.ChunkSize
+	length := m.ChunkSize
+	if offset+length > m.Size {
+		length = m.Size - offset
+	}
+	return offset, length
+}
+
+// VerifyChunk returns true if data matches the checksum of chunk i.
+func (m *ChunkManifest) VerifyChunk(i int, data []byte) bool {
+	if i < 0 || i >= len(m.Chunks) {
+		return false
+	}
+	if _, length := m.ChunkRange(i); int64(len(data)) != length {
+		return false
+	}
+	checksum := sha256.Sum256(data)
+	return hex.EncodeToString(checksum[:]) == m.Chunks[i]
+}
@@ -0,0 +1,61 @@
+package mbtiles
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_WriteChunkManifest(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	stat, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunkSize := int64(64 << 10)
+	manifest, err := WriteChunkManifest(filename, chunkSize)
+	if err != nil {
+		t.Fatal("Could not write chunk manifest:", err)
+	}
+	if manifest.Name != "geography-class-png.mbtiles" || manifest.Size != stat.Size() {
+		t.Error("Chunk manifest does not describe file, got:", manifest.Name, manifest.Size)
+	}
+	if expected := int((stat.Size() + chunkSize - 1) / chunkSize); len(manifest.Chunks) != expected {
+		t.Error("Expected", expected, "chunks, got:", len(manifest.Chunks))
+	}
+	checksum, err := fileChecksum(filename)
+	if err != nil || manifest.SHA256 != checksum {
+		t.Error("Chunk manifest checksum does not match file checksum:", manifest.SHA256, err)
+	}
+
+	f, err := os.Open(filename + ManifestExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	read, err := ReadChunkManifest(f)
+	if err != nil {
+		t.Fatal("Could not read chunk manifest:", err)
+	}
+	if read.SHA256 != manifest.SHA256 || len(read.Chunks) != len(manifest.Chunks) {
+		t.Error("Chunk manifest was not read back, got:", read)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := len(manifest.Chunks) - 1
+	offset, length := manifest.ChunkRange(last)
+	if offset+length != stat.Size() || !manifest.VerifyChunk(last, data[offset:offset+length]) {
+		t.Error("Last chunk does not verify:", offset, length)
+	}
+	if manifest.VerifyChunk(0, data[1:chunkSize+1]) {
+		t.Error("Chunk with wrong data verified")
+	}
+
+	read.Chunks = read.Chunks[1:]
+	if err := read.validate(); err == nil {
+		t.Error("Chunk manifest with missing chunks is valid")
+	}
+}
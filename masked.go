@@ -0,0 +1,120 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+)
+
+// MaskedTiles serves the tiles of a pair of raster tilesets, a base tileset of
+// imagery, usually JPG to keep it small, and a PNG mask tileset of the same
+// tiles whose pixels give the opacity of the base, as single PNG tiles with
+// transparency, such as for imagery with irregular coverage.  The opacity of
+// each pixel is the luminance of the mask multiplied by its alpha, so that
+// both grayscale masks and masks with transparency are supported.  Masked
+// tiles are kept in an LRU cache, so that each tile is only composited once.
+type MaskedTiles struct {
+	base  *MBtiles
+	mask  *MBtiles
+	cache *tileCache
+}
+
+// NewMaskedTiles creates a source of the tiles of base with the opacity of
+// the tiles of mask, with a cache of up to cacheSize masked tiles.  base must
+// be a PNG or JPG tileset, and mask a PNG tileset aligned with it, with tiles
+// of the same size.
+func NewMaskedTiles(base *MBtiles, mask *MBtiles, cacheSize int) (*MaskedTiles, error) {
+	for _, db := range []*MBtiles{base, mask} {
+		if db.isClosed() {
+			return nil, errors.New("cannot mask tiles of closed mbtiles database")
+		}
+		if err := db.init(context.TODO()); err != nil {
+			return nil, err
+		}
+	}
+	if format := base.GetTileFormat(); format != PNG && format != JPG {
+		return nil, fmt.Errorf("masked tiles are only supported for PNG and JPG base tilesets, not %v", format)
+	}
+	if format := mask.GetTileFormat(); format != PNG {
+		return nil, fmt.Errorf("mask tileset must be PNG, not %v", format)
+	}
+	return &MaskedTiles{base: base, mask: mask, cache: newTileCache(cacheSize)}, nil
+}
+
+// GetTile returns the masked PNG tile for z, x, y (TMS scheme), from the
+// cache if available.  ErrTileNotFound is returned if the base tile does not
+// exist.  Base tiles without a mask tile are opaque.
+func (m *MaskedTiles) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if data, ok := m.cache.get(coord); ok {
+		return data, nil
+	}
+	generation := m.cache.current()
+	data, err := m.maskTile(ctx, coord)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.add(coord, data, generation)
+	return data, nil
+}
+
+// GetTileFormat returns the format of masked tiles, which is always PNG.
+func (m *MaskedTiles) GetTileFormat() TileFormat {
+	return PNG
+}
+
+// Export creates a new PNG mbtiles file at dst with the masked tiles, and
+// returns the number of tiles written.  dst must not already exist.  Tiles
+// are masked using up to concurrency goroutines; concurrency less than 1 is
+// treated as 1.  Metadata is copied from the base tileset.  dst is removed if
+// the export fails.
+func (m *MaskedTiles) Export(ctx context.Context, dst string, concurrency int) (int64, error) {
+	parameters := map[string]interface{}{"mask": m.mask.GetFilename()}
+	return compositeTiles(ctx, dst, []*MBtiles{m.base}, map[string]string{"format": "png"}, "mask", parameters, concurrency, func(coord TileCoord) ([]byte, error) {
+		return m.maskTile(ctx, coord)
+	})
+}
+
+// Purge removes all masked tiles from the cache, so that changes to the tiles
+// of the base or mask tilesets are served.
+func (m *MaskedTiles) Purge() {
+	m.cache.purge()
+}
+
+// maskTile reads the base and mask tiles at coord (TMS scheme), and returns
+// the encoded PNG of the base with the opacity of the mask.  Returns
+// ErrTileNotFound if the base tile does not exist.
+func (m *MaskedTiles) maskTile(ctx context.Context, coord TileCoord) ([]byte, error) {
+	img, err := readTileNRGBA(ctx, m.base, coord)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, ErrTileNotFound
+	}
+	mask, err := readTileNRGBA(ctx, m.mask, coord)
+	if err != nil {
+		return nil, err
+	}
+	if mask != nil {
+		if mask.Rect != img.Rect {
+			return nil, &TileError{Coord: coord, Err: fmt.Errorf("mask tile size %v does not match base tile size %v", mask.Rect.Size(), img.Rect.Size())}
+		}
+		applyMask(img, mask)
+	}
+	return encodePNG(img)
+}
+
+// applyMask multiplies the alpha of each pixel of img by the luminance of the
+// pixel of mask, multiplied by its alpha.  img and mask must be of the same
+// size.
+func applyMask(img *image.NRGBA, mask *image.NRGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b, a := uint32(mask.Pix[i]), uint32(mask.Pix[i+1]), uint32(mask.Pix[i+2]), uint32(mask.Pix[i+3])
+		// luminance as computed by color.GrayModel
+		y := (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+		opacity := y * a / 255
+		img.Pix[i+3] = uint8((uint32(img.Pix[i+3])*opacity + 127) / 255)
+	}
+}
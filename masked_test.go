@@ -0,0 +1,83 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"testing"
+)
+
+func Test_MaskedTiles(t *testing.T) {
+	ctx := context.Background()
+	base, err := Open("./testdata/geography-class-jpg.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	// the left half of the tile at zoom level 0 is opaque
+	maskImage := uniformImage(color.NRGBA{A: 255})
+	draw.Draw(maskImage, image.Rect(0, 0, 128, 256), image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: 255}), image.Point{}, draw.Src)
+	mask, err := Open(createRasterTileset(t, nil, map[TileCoord]image.Image{{Z: 0}: maskImage}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mask.Close()
+
+	if _, err := NewMaskedTiles(mask, base, 0); err == nil {
+		t.Error("Expected error for JPG mask")
+	}
+	masked, err := NewMaskedTiles(base, mask, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := masked.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Could not read masked tile:", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal("Masked tile is not a PNG:", err)
+	}
+	if c := color.NRGBAModel.Convert(img.At(20, 20)).(color.NRGBA); c.A != 255 {
+		t.Error("Expected opaque pixel within mask, got:", c)
+	}
+	if c := color.NRGBAModel.Convert(img.At(200, 20)).(color.NRGBA); c.A != 0 {
+		t.Error("Expected transparent pixel outside mask, got:", c)
+	}
+
+	// tiles without a mask are opaque
+	if data, err := masked.GetTile(ctx, 1, 0, 0); err != nil {
+		t.Error("Could not read tile without mask:", err)
+	} else if format, _ := ParseTileFormat(data); format != PNG {
+		t.Error("Expected PNG tile without mask, got:", format)
+	}
+	if _, err := masked.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "masked.mbtiles")
+	count, err := masked.Export(ctx, dst, 2)
+	if err != nil {
+		t.Fatal("Could not export masked tiles:", err)
+	}
+	if count != 5 {
+		t.Error("Expected 5 exported tiles, got:", count)
+	}
+	exported, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exported.Close()
+	if exported.GetTileFormat() != PNG {
+		t.Error("Expected exported tileset to be PNG, got:", exported.GetTileFormat())
+	}
+	if tile, err := exported.GetTile(ctx, 0, 0, 0); err != nil || !bytes.Equal(tile, data) {
+		t.Error("Expected exported tile to be masked, got:", err)
+	}
+}
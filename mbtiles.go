@@ -3,146 +3,646 @@ package mbtiles
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// ErrNoTiles is returned by operations that require tiles if the mbtiles file
+// does not have any.
+var ErrNoTiles = errors.New("mbtiles file has no tiles")
+
+// ErrTileNotFound is returned by GetTile if the tile does not exist.
+var ErrTileNotFound = errors.New("tile not found")
+
+// tileQuery is the SQL of the prepared statement used to read tiles.
+const tileQuery = "select tile_data from tiles where zoom_level = ? and tile_column = ? and tile_row = ?"
+
 // MBtiles provides a basic handle for an mbtiles file.
 type MBtiles struct {
-	filename  string
-	pool      *sql.DB
-	tileStmt  *sql.Stmt
-	format    TileFormat
+	filename string
+	pool     *sql.DB
+	wal      bool
+	options  openOptions
+
+	initOnce sync.Once // validates the file and sets state
+	initErr  error
+	state    atomic.Value // *handleState; replaced by Reload
+	cache    *tileCache   // nil if tiles are not cached
+	misses   *missCache   // nil if missing tiles are not cached
+	health   *healthState // nil unless opened with RecoverUnavailable
+	counters *handleCounters
+	limiter  *readLimiter // nil unless opened with AdaptiveReadLimit
+	writer   writerLock
+
+	mu        sync.RWMutex // protects timestamp, zoom range, metadata, and stats
 	timestamp time.Time
-	tilesize  uint32
+	metadata  map[string]interface{} // cached by ReadMetadata
+	stats     *TilesetStats          // cached by TilesetStats
+	hasZooms  bool                   // true if minZoom and maxZoom have been read from tiles
+	minZoom   int
+	maxZoom   int
 }
 
-// FindMBtiles recursively finds all mbtiles files within a given path.
-func FindMBtiles(path string) ([]string, error) {
-	var filenames []string
-	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		// Ignore any that have an associated -journal file; these are incomplete
-		if _, err := os.Stat(p + "-journal"); err == nil {
-			return nil
-		}
-		if ext := filepath.Ext(p); ext == ".mbtiles" {
-			filenames = append(filenames, p)
+// handleState is the state of the handle detected from the mbtiles file, which
+// is replaced atomically by Reload so that it is never seen partially updated.
+type handleState struct {
+	format   TileFormat
+	tilesize uint32
+	tileStmt *sql.Stmt
+	coverage atomic.Value // of *tileCoverage, nil unless opened with RejectOutsideCoverage
+	index    *sharedIndex // nil unless opened with SharedTileIndex
+
+	closed  bool         // true for the state stored by Close
+	mu      sync.RWMutex // read locked while tileStmt is in use
+	retired bool         // true once replaced or closed, and tileStmt is closed
+}
 
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+// uninitialized is the state of handles that have not been initialized.  It
+// is shared by all handles, so it is never retired.
+var uninitialized = &handleState{}
+
+// errClosed is returned by reads of handles that have been closed.
+var errClosed = errors.New("cannot read tile from closed mbtiles database")
+
+// retire closes the statements of the state once they are no longer in use.
+func (s *handleState) retire() {
+	if s == uninitialized {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.retired {
+		return
+	}
+	s.retired = true
+	if s.tileStmt != nil {
+		s.tileStmt.Close()
 	}
-	return filenames, err
+	s.index.close()
+}
+
+// OpenOption configures how Open opens an mbtiles file.
+type OpenOption func(*openOptions)
+
+type openOptions struct {
+	fallbackLevels     int
+	fallbackWriteBack  bool
+	logger             Logger
+	slowQueryThreshold time.Duration
+	traceHook          func(QueryTrace)
+	lazy               bool
+	journalPolicy      JournalPolicy
+	readOnly           bool
+	cacheSize          int
+	replicationLog     bool
+	versionBump        VersionPart
+	history            bool
+	syncMetadata       bool
+	recoverInterval    time.Duration
+	servedZooms        *ZoomLimit
+	maxTileSize        int64
+	lowMemory          bool
+	blobStore          BlobStore
+	blobThreshold      int64
+	rejectOutside      bool
+	missCacheSize      int
+	missCacheTTL       time.Duration
+	tileIndex          bool
+	tileIndexPath      string
+	writeCoordinates   bool
+	writeXYZ           bool
+	coordinateMode     CoordinateMode
+	overlayBase        bool
+	readLimit          int
+	readLimitLatency   time.Duration
+	lockWriter         bool
 }
 
 // Open opens an MBtiles file for reading, and validates that it has the correct
 // structure.
-func Open(path string) (*MBtiles, error) {
-	// try to open file; fail fast if it doesn't exist
-	stat, err := os.Stat(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, fmt.Errorf("path does not exist: %q", path)
+//
+// path may be a file: URI with SQLite query parameters, such as
+// "file:/data/world.mbtiles?immutable=1&cache=shared", to pass options to the
+// SQLite driver.  The supported parameters are mode (ro or rw), cache (shared
+// or private), immutable (0 or 1), and nolock (0 or 1); an error is returned
+// for other parameters or values.  GetFilename returns the path of the file.
+func Open(path string, opts ...OpenOption) (*MBtiles, error) {
+	return OpenContext(context.Background(), path, opts...)
+}
+
+// OpenContext opens an MBtiles file as described for Open, and returns the
+// error of ctx if it is done before the file is opened and validated, so that
+// opening files on slow or unresponsive file systems, such as network mounts,
+// can be bounded with a timeout.  File system calls that do not return stay
+// blocked in the background.
+func OpenContext(ctx context.Context, path string, opts ...OpenOption) (*MBtiles, error) {
+	var options openOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.writeCoordinates && !options.overlayBase {
+		return nil, errors.New("WriteCoordinates only applies to the base of an Overlay")
+	}
+
+	var params url.Values
+	if strings.HasPrefix(path, "file:") {
+		var err error
+		if path, params, err = parseFileURI(path); err != nil {
+			return nil, err
 		}
+	}
+
+	file, err := probeFile(ctx, path)
+	if err != nil {
 		return nil, err
 	}
 
-	// there must not be a corresponding *-journal file (tileset is still being created)
-	if _, err := os.Stat(path + "-journal"); err == nil {
-		return nil, fmt.Errorf("refusing to open mbtiles file with associated -journal file (incomplete tileset)")
+	// by default, there must not be a corresponding *-journal file (tileset is
+	// still being created)
+	readOnly := options.readOnly || params.Get("mode") == "ro"
+	if file.journal {
+		switch options.journalPolicy {
+		case JournalReadOnly:
+			readOnly = true
+		case JournalRecover:
+			// SQLite rolls back a hot journal on first read
+		default:
+			return nil, fmt.Errorf("refusing to open mbtiles file with associated -journal file (incomplete tileset)")
+		}
+	}
+	if readOnly {
+		if params == nil {
+			params = make(url.Values)
+		}
+		params.Set("mode", "ro")
+	}
+	if options.lowMemory {
+		params = lowMemoryParams(params)
+	}
+	dsn := path
+	if params != nil {
+		dsn = fileDSN(path, params)
 	}
 
-	pool, err := sql.Open("sqlite", path)
+	pool, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
+	if options.lowMemory {
+		pool.SetMaxOpenConns(lowMemoryMaxConns)
+		pool.SetMaxIdleConns(lowMemoryIdleConns)
+	}
 
 	db := &MBtiles{
 		filename:  path,
 		pool:      pool,
-		timestamp: stat.ModTime().Round(time.Second),
+		options:   options,
+		wal:       file.wal,
+		cache:     newTileCache(options.cacheSize),
+		misses:    newMissCache(options.missCacheSize, options.missCacheTTL),
+		counters:  &handleCounters{},
+		limiter:   newReadLimiter(options.readLimit, options.readLimitLatency),
+		timestamp: file.stat.ModTime().Round(time.Second),
+	}
+	if options.recoverInterval > 0 {
+		db.health = &healthState{}
+	}
+
+	if file.journal && options.journalPolicy == JournalReadOnly {
+		db.logger().Printf("mbtiles: opening %s read-only because it has an associated -journal file (tileset may be incomplete)", path)
+	}
+
+	if options.lockWriter && !readOnly {
+		if err := db.lockWriter(); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	if !options.lazy {
+		if err := db.init(ctx); err != nil {
+			db.writer.release()
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// fileProbe is the information about an mbtiles file read by probeFile.
+type fileProbe struct {
+	stat    os.FileInfo
+	wal     bool
+	journal bool // true if there is a corresponding *-journal file
+}
+
+// probeFile reads the information about the mbtiles file at path needed to
+// open it, in a goroutine so that OpenContext stops waiting for unresponsive
+// file systems once ctx is done.
+func probeFile(ctx context.Context, path string) (fileProbe, error) {
+	if err := ctx.Err(); err != nil {
+		return fileProbe{}, err
+	}
+
+	type result struct {
+		probe fileProbe
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		probe, err := readFileProbe(path)
+		done <- result{probe, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fileProbe{}, ctx.Err()
+	case r := <-done:
+		return r.probe, r.err
+	}
+}
+
+// readFileProbe reads the information about the mbtiles file at path needed
+// to open it.
+func readFileProbe(path string) (fileProbe, error) {
+	// try to open file; fail fast if it doesn't exist
+	stat, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fileProbe{}, fmt.Errorf("path does not exist: %q", path)
+		}
+		return fileProbe{}, err
+	}
+
+	wal, err := fileIsWAL(path)
+	if err != nil {
+		return fileProbe{}, err
+	}
+
+	// files in WAL mode do not use a -journal file
+	journal := false
+	if !wal {
+		if _, err := os.Stat(path + "-journal"); err == nil {
+			journal = true
+		}
+	}
+	return fileProbe{stat: stat, wal: wal, journal: journal}, nil
+}
+
+// LazyOpen defers validation of the mbtiles file, detection of its tile format
+// and size, and preparation of statements from Open to first use, so that Open
+// only checks that the file exists.  This allows many mbtiles files to be
+// registered quickly; errors for invalid files are instead returned by
+// GetTile and ReadMetadata, and GetTileFormat returns UNKNOWN.
+func LazyOpen() OpenOption {
+	return func(o *openOptions) {
+		o.lazy = true
+	}
+}
+
+// init validates the mbtiles file, detects its tile format and size, and
+// prepares statements, on first call.  All calls return the same error.
+func (db *MBtiles) init(ctx context.Context) error {
+	db.initOnce.Do(func() {
+		db.initErr = db.doInit(ctx)
+	})
+	return db.initErr
+}
+
+func (db *MBtiles) doInit(ctx context.Context) error {
+	state, err := db.detectState(ctx)
+	if err != nil {
+		return err
 	}
+	if !db.state.CompareAndSwap(nil, state) {
+		// closed while the state was detected
+		state.retire()
+		return errClosed
+	}
+	return nil
+}
 
-	con, err := db.getConnection(context.TODO())
+// detectState validates the mbtiles file, detects its tile format and size,
+// and prepares statements.
+func (db *MBtiles) detectState(ctx context.Context) (*handleState, error) {
+	con, err := db.getConnection(ctx)
 	defer db.closeConnection(con)
 	if err != nil {
 		return nil, err
 	}
 
-	err = validateRequiredTables(con)
+	err = validateRequiredTables(ctx, con)
 	if err != nil {
 		return nil, err
 	}
 
-	format, tilesize, err := getTileFormatAndSize(con)
+	format, tilesize, err := getTileFormatAndSize(ctx, con, db.options.blobStore)
 	if err != nil {
 		return nil, err
 	}
 
-	db.format = format
-	db.tilesize = tilesize
+	var coverage *tileCoverage
+	if db.options.rejectOutside {
+		if coverage, err = readCoverage(ctx, con); err != nil {
+			return nil, err
+		}
+	}
+
+	var index *sharedIndex
+	if db.options.tileIndex {
+		if index, err = db.openTileIndex(ctx); err != nil {
+			return nil, err
+		}
+	}
 
-	db.tileStmt, err = con.Prepare("select tile_data from tiles where zoom_level = ? and tile_column = ? and tile_row = ?")
+	tileStmt, err := db.pool.PrepareContext(ctx, tileQuery)
 	if err != nil {
+		index.close()
 		return nil, err
 	}
+	state := &handleState{format: format, tilesize: tilesize, tileStmt: tileStmt, index: index}
+	state.coverage.Store(coverage)
+	return state, nil
+}
+
+// loadState returns the current state of the handle, which must not be used
+// for statements; see acquireState.
+func (db *MBtiles) loadState() *handleState {
+	if state, ok := db.state.Load().(*handleState); ok {
+		return state
+	}
+	return uninitialized
+}
 
-	return db, nil
+// acquireState returns the current state of the handle read locked, so that
+// its statements are not closed by Reload until the lock is released, or
+// errClosed if the handle has been closed.
+func (db *MBtiles) acquireState() (*handleState, error) {
+	for {
+		state := db.loadState()
+		if state.closed {
+			return nil, errClosed
+		}
+		state.mu.RLock()
+		if !state.retired {
+			return state, nil
+		}
+		// replaced since it was loaded, by Reload or by the state of Close
+		state.mu.RUnlock()
+	}
 }
 
-// Close closes a MBtiles file
+// isClosed returns true if db is nil or has been closed.
+func (db *MBtiles) isClosed() bool {
+	return db == nil || db.pool == nil || db.loadState().closed
+}
+
+// Reload detects the tile format and size of the mbtiles file again, prepares
+// new statements, and clears cached metadata, zoom range, and tiles, so that
+// the handle reflects changes written to the file by another process, such as
+// tiles of a different format.  If the file is no longer valid, an error is
+// returned and the handle is unchanged.
+//
+// Reload is safe to call concurrently with reads such as GetTile and
+// ReadMetadata: reads in progress complete using the previous state, and
+// reads that start after Reload returns use the new state.  Changes must be
+// written to the file in place; a file that is replaced by renaming another
+// file over it must be opened again with Open.
+func (db *MBtiles) Reload(ctx context.Context) error {
+	if db.isClosed() {
+		return errors.New("cannot reload closed mbtiles database")
+	}
+	if err := db.init(ctx); err != nil {
+		return err
+	}
+
+	previous := db.loadState()
+	if previous.closed {
+		return errors.New("cannot reload closed mbtiles database")
+	}
+	state, err := db.detectState(ctx)
+	if err != nil {
+		return err
+	}
+	if !db.state.CompareAndSwap(previous, state) {
+		// closed, or reloaded concurrently
+		state.retire()
+		if db.loadState().closed {
+			return errors.New("cannot reload closed mbtiles database")
+		}
+		return nil
+	}
+	previous.retire()
+
+	db.mu.Lock()
+	db.metadata = nil
+	db.stats = nil
+	db.hasZooms = false
+	db.mu.Unlock()
+	db.cache.purge()
+	db.misses.purge()
+
+	_, err = db.RefreshTimestamp()
+	return err
+}
+
+// Close closes a MBtiles file.  Reads after Close return an error.
 func (db *MBtiles) Close() {
-	if db.tileStmt != nil {
-		db.tileStmt.Close()
+	// the detected format and size remain available after Close
+	previous := db.loadState()
+	closed := &handleState{format: previous.format, tilesize: previous.tilesize, closed: true}
+	if previous, ok := db.state.Swap(closed).(*handleState); ok {
+		previous.retire()
 	}
 	if db.pool != nil {
 		db.pool.Close()
 	}
+	db.writer.release()
+}
+
+// GetTile returns the tile for z, x, y (TMS scheme), or ErrTileNotFound if
+// the tile does not exist in the database and cannot be synthesized from an
+// ancestor tile (see AncestorFallback).
+func (db *MBtiles) GetTile(ctx context.Context, z int64, x int64, y int64) (data []byte, err error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read tile from closed mbtiles database")
+	}
+	defer func() { db.counters.read(int64(len(data)), err) }()
+	if err := db.init(ctx); err != nil {
+		return nil, err
+	}
+
+	if db.options.slowQueryThreshold > 0 {
+		defer func(start time.Time) {
+			db.logSlowQuery(start, "tile %d/%d/%d (%d bytes)", z, x, y, len(data))
+		}(time.Now())
+	}
+
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if !db.covers(coord) {
+		return nil, ErrTileNotFound
+	}
+	tile, ok := db.cache.get(coord)
+	if db.cache != nil {
+		db.counters.cacheHit(ok)
+	}
+	if ok {
+		return tile, nil
+	}
+	cached := db.cache.current()
+	missing, generation := db.misses.contains(coord)
+	if missing {
+		return nil, ErrTileNotFound
+	}
+	if err := db.checkHealth(ctx); err != nil {
+		return nil, err
+	}
+
+	if db.indexedMissing(coord) {
+		err = sql.ErrNoRows
+	} else {
+		err = db.limiter.do(ctx, func() error {
+			if limit := db.options.maxTileSize; limit > 0 {
+				return db.queryLimitedTile(ctx, z, x, y, &data, limit)
+			}
+			return db.queryTile(ctx, z, x, y, &data)
+		})
+	}
+	if err == sql.ErrNoRows {
+		if db.options.fallbackLevels > 0 {
+			data, err = db.readFallbackTile(ctx, z, x, y)
+		} else {
+			err = ErrTileNotFound
+		}
+		if err == ErrTileNotFound {
+			db.misses.add(coord, generation)
+		}
+		return data, err
+	}
+	if err != nil {
+		return nil, db.markUnhealthy(err)
+	}
+	db.cache.add(coord, data, cached)
+	return data, nil
 }
 
 // ReadTile reads a tile for z, x, y into the provided *[]byte.
-// data will be nil if the tile does not exist in the database
+// data will be nil if the tile does not exist in the database, unless it can
+// be synthesized from an ancestor tile (see AncestorFallback).
+//
+// Deprecated: use GetTile, which accepts a context and returns
+// ErrTileNotFound for missing tiles.
 func (db *MBtiles) ReadTile(z int64, x int64, y int64, data *[]byte) error {
-	if db == nil || db.tileStmt == nil {
-		return errors.New("cannot read tile from closed mbtiles database")
+	tile, err := db.GetTile(context.Background(), z, x, y)
+	if err == ErrTileNotFound {
+		*data = nil // If this tile does not exist in the database, return empty bytes
+		return nil
 	}
+	*data = tile
+	return err
+}
 
-	err := db.tileStmt.QueryRow(z, x, y).Scan(data)
+// queryTile reads a tile for z, x, y into data using the prepared tile
+// statement, reading the tile from the BlobStore of ExternalBlobs if it is
+// stored there.  Returns sql.ErrNoRows if the tile does not exist.
+func (db *MBtiles) queryTile(ctx context.Context, z int64, x int64, y int64, data *[]byte) error {
+	state, err := db.acquireState()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			*data = nil // If this tile does not exist in the database, return empty bytes
-			return nil
+		return err
+	}
+	defer state.mu.RUnlock()
+
+	if db.options.traceHook == nil {
+		if err := state.tileStmt.QueryRowContext(ctx, z, x, y).Scan(data); err != nil {
+			return err
 		}
+		return db.resolveBlob(ctx, data)
+	}
+
+	start := time.Now()
+	err = state.tileStmt.QueryRowContext(ctx, z, x, y).Scan(data)
+	var traceErr error
+	if err != sql.ErrNoRows {
+		traceErr = err
+	}
+	db.trace(start, tileQuery, []interface{}{z, x, y}, traceErr)
+	if err != nil {
 		return err
 	}
-	return nil
+	return db.resolveBlob(ctx, data)
 }
 
 // ReadMetadata reads the metadata table into a map, casting their values into
-// the appropriate type
+// the appropriate type.  The keys of the json metadata item, which may be gzip
+// compressed, are added to the map.
+//
+// The metadata is cached on the handle until InvalidateMetadata is called, or
+// RefreshTimestamp detects that the file was modified.  Each call returns a new
+// map, but values such as bounds are shared between calls and must not be
+// modified.
 func (db *MBtiles) ReadMetadata() (map[string]interface{}, error) {
-	if db == nil || db.pool == nil {
+	if db.isClosed() {
 		return nil, errors.New("cannot read tile from closed mbtiles database")
 	}
+	if err := db.init(context.TODO()); err != nil {
+		return nil, err
+	}
 
-	con, err := db.getConnection(context.TODO())
-	defer db.closeConnection(con)
+	db.mu.RLock()
+	metadata := db.metadata
+	db.mu.RUnlock()
+
+	if metadata == nil {
+		var err error
+		metadata, err = db.readMetadata()
+		if err != nil {
+			return nil, err
+		}
+		db.mu.Lock()
+		db.metadata = metadata
+		db.mu.Unlock()
+	}
+
+	result := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// InvalidateMetadata clears the metadata cached by ReadMetadata, so that it is
+// read again from the metadata table on next use.
+func (db *MBtiles) InvalidateMetadata() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.metadata = nil
+}
+
+// readMetadata reads and parses the metadata table.
+func (db *MBtiles) readMetadata() (map[string]interface{}, error) {
+	if db.options.slowQueryThreshold > 0 {
+		defer db.logSlowQuery(time.Now(), "metadata")
+	}
+
+	// read in a single transaction, so that all queries see the same snapshot
+	// of tilesets in WAL mode that are being updated by another process
+	ctx := context.TODO()
+	tx, err := db.pool.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+	con := db.traced(tx)
 
 	var (
 		key   string
@@ -150,7 +650,7 @@ func (db *MBtiles) ReadMetadata() (map[string]interface{}, error) {
 	)
 	metadata := make(map[string]interface{})
 
-	rows, err := con.Query("select * from metadata where value is not ''")
+	rows, err := con.QueryContext(ctx, "select * from metadata where value is not ''")
 	if err != nil {
 		return nil, err
 	}
@@ -158,25 +658,8 @@ func (db *MBtiles) ReadMetadata() (map[string]interface{}, error) {
 
 	for rows.Next() {
 		rows.Scan(&key, &value)
-
-		switch key {
-		case "maxzoom", "minzoom":
-			metadata[key], err = strconv.Atoi(value)
-			if err != nil {
-				return nil, fmt.Errorf("cannot read metadata item %s: %v", key, err)
-			}
-		case "bounds", "center":
-			metadata[key], err = parseFloats(value)
-			if err != nil {
-				return nil, fmt.Errorf("cannot read metadata item %s: %v", key, err)
-			}
-		case "json":
-			err = json.Unmarshal([]byte(value), &metadata)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse JSON metadata item: %v", err)
-			}
-		default:
-			metadata[key] = value
+		if err := parseMetadataItem(metadata, key, value); err != nil {
+			return nil, err
 		}
 	}
 
@@ -184,8 +667,7 @@ func (db *MBtiles) ReadMetadata() (map[string]interface{}, error) {
 	_, hasMinZoom := metadata["minzoom"]
 	_, hasMaxZoom := metadata["maxzoom"]
 	if !(hasMinZoom && hasMaxZoom) {
-		var minZoom, maxZoom int
-		err := con.QueryRow("select min(zoom_level), max(zoom_level) from tiles").Scan(&minZoom, &maxZoom)
+		minZoom, maxZoom, err := db.readZoomRange(ctx, con)
 		if err != nil {
 			return metadata, nil
 		}
@@ -195,39 +677,170 @@ func (db *MBtiles) ReadMetadata() (map[string]interface{}, error) {
 	return metadata, nil
 }
 
+// parseMetadataItem parses the value of the metadata item key into metadata.
+// The items of the json metadata item are added to metadata.
+func parseMetadataItem(metadata map[string]interface{}, key string, value string) (err error) {
+	switch key {
+	case "maxzoom", "minzoom":
+		metadata[key], err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("cannot read metadata item %s: %v", key, err)
+		}
+	case "bounds", "center", "extent":
+		metadata[key], err = ParseFloats(value)
+		if err != nil {
+			return fmt.Errorf("cannot read metadata item %s: %v", key, err)
+		}
+	case "json":
+		return parseMetadataJSON(metadata, value)
+	default:
+		metadata[key] = value
+	}
+	return nil
+}
+
+// GetMinZoom returns the lowest zoom level of tiles in the mbtiles file, which
+// may differ from the minzoom metadata item.  It is read from the tiles table
+// on first use and cached on the handle.  Returns ErrNoTiles if the mbtiles
+// file has no tiles.
+func (db *MBtiles) GetMinZoom() (int, error) {
+	minZoom, _, err := db.zoomRange()
+	return minZoom, err
+}
+
+// GetMaxZoom returns the highest zoom level of tiles in the mbtiles file, as
+// described for GetMinZoom.
+func (db *MBtiles) GetMaxZoom() (int, error) {
+	_, maxZoom, err := db.zoomRange()
+	return maxZoom, err
+}
+
+// zoomRange returns the cached zoom range of tiles, reading it if needed.
+func (db *MBtiles) zoomRange() (int, int, error) {
+	if db.isClosed() {
+		return 0, 0, errors.New("cannot read zoom levels from closed mbtiles database")
+	}
+	ctx := context.TODO()
+	con, err := db.getConnection(ctx)
+	defer db.closeConnection(con)
+	if err != nil {
+		return 0, 0, err
+	}
+	return db.readZoomRange(ctx, con)
+}
+
+// readZoomRange returns the zoom range of tiles in the mbtiles file, and caches
+// it on the handle.  The lowest and highest zoom levels are queried separately
+// so that SQLite can use the tiles index instead of scanning the whole table.
+func (db *MBtiles) readZoomRange(ctx context.Context, con querier) (int, int, error) {
+	db.mu.RLock()
+	if db.hasZooms {
+		defer db.mu.RUnlock()
+		return db.minZoom, db.maxZoom, nil
+	}
+	db.mu.RUnlock()
+
+	var minZoom, maxZoom int
+	err := con.QueryRowContext(ctx, "select zoom_level from tiles order by zoom_level limit 1").Scan(&minZoom)
+	if err == nil {
+		err = con.QueryRowContext(ctx, "select zoom_level from tiles order by zoom_level desc limit 1").Scan(&maxZoom)
+	}
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrNoTiles
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.hasZooms, db.minZoom, db.maxZoom = true, minZoom, maxZoom
+	return minZoom, maxZoom, nil
+}
+
+// tilesChanged clears the cached zoom range and tiles after tiles are added
+// or deleted, and the cached metadata if its version was bumped.
+func (db *MBtiles) tilesChanged() {
+	db.mu.Lock()
+	db.hasZooms = false
+	db.stats = nil
+	if db.options.versionBump != 0 || db.options.syncMetadata {
+		db.metadata = nil
+	}
+	db.mu.Unlock()
+
+	db.cache.purge()
+	db.misses.purge()
+	db.loadState().index.markStale()
+	if db.options.rejectOutside {
+		db.refreshCoverage()
+	}
+}
+
 func (db *MBtiles) GetFilename() string {
 	return db.filename
 }
 
 // GetTileFormat returns the TileFormat of the mbtiles file.
 func (db *MBtiles) GetTileFormat() TileFormat {
-	return db.format
+	db.init(context.TODO())
+	return db.loadState().format
 }
 
 // GetTileSize returns the tile size in pixels of the mbtiles file, if detected.
 // Returns 0 if tile size is not detected.
 func (db *MBtiles) GetTileSize() uint32 {
-	return db.tilesize
+	db.init(context.TODO())
+	return db.loadState().tilesize
 }
 
-// Timestamp returns the time stamp of the mbtiles file.
+// GetTimestamp returns the time stamp of the mbtiles file, as of Open or the
+// most recent call to RefreshTimestamp.
 func (db *MBtiles) GetTimestamp() time.Time {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 	return db.timestamp
 }
 
+// RefreshTimestamp reads the modification time of the mbtiles file again, so
+// that GetTimestamp reflects a file that was replaced or modified in place
+// after it was opened, and returns the updated time stamp.  If the time stamp
+// changed, cached metadata and zoom range are cleared.
+func (db *MBtiles) RefreshTimestamp() (time.Time, error) {
+	stat, err := os.Stat(db.filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	timestamp := stat.ModTime().Round(time.Second)
+	if !timestamp.Equal(db.timestamp) {
+		// the file was modified, so cached values may be out of date
+		db.metadata = nil
+		db.stats = nil
+		db.hasZooms = false
+		db.cache.purge()
+		db.misses.purge()
+		db.loadState().index.markStale()
+	}
+	db.timestamp = timestamp
+	return db.timestamp, nil
+}
+
 // getConnection gets a sqlite.Conn from an open connection pool.
 // closeConnection(con) must be called to release the connection.
-func (db *MBtiles) getConnection(ctx context.Context) (*sql.DB, error) {
+func (db *MBtiles) getConnection(ctx context.Context) (querier, error) {
 	/*con := db.pool.Get(ctx)
 	if con == nil {
 		return nil, errors.New("connection could not be opened")
 	}
 	return con, nil*/
-	return db.pool, nil
+	return db.traced(db.pool), nil
 }
 
 // closeConnection closes an open sqlite.Conn and returns it to the pool.
-func (db *MBtiles) closeConnection(con *sql.DB) {
+func (db *MBtiles) closeConnection(con querier) {
 	/*if con != nil {
 		db.pool.Put(con)
 	}*/
@@ -235,9 +848,9 @@ func (db *MBtiles) closeConnection(con *sql.DB) {
 
 // validateRequiredTables checks that both 'tiles' and 'metadata' tables are
 // present in the database
-func validateRequiredTables(con *sql.DB) error {
+func validateRequiredTables(ctx context.Context, con querier) error {
 	var tableCount int
-	err := con.QueryRow("SELECT count(*) FROM sqlite_master WHERE name in ('tiles', 'metadata')").Scan(&tableCount)
+	err := con.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE name in ('tiles', 'metadata')").Scan(&tableCount)
 	if err != nil {
 		return err
 	}
@@ -251,15 +864,15 @@ func validateRequiredTables(con *sql.DB) error {
 
 // getTileFormat reads the first 8 bytes of the first tile in the database.
 // See TileFormat for list of supported tile formats.
-func getTileFormat(con *sql.DB) (TileFormat, error) {
+func getTileFormat(ctx context.Context, con querier) (TileFormat, error) {
 	magicWord := make([]byte, 8)
-	err := con.QueryRow("select tile_data from tiles limit 1").Scan(&magicWord)
+	err := con.QueryRowContext(ctx, "select tile_data from tiles limit 1").Scan(&magicWord)
 
 	if err != nil {
 		return UNKNOWN, err
 	}
 
-	format, err := detectTileFormat(magicWord)
+	format, err := ParseTileFormat(magicWord)
 	if err != nil {
 		return UNKNOWN, err
 	}
@@ -273,19 +886,23 @@ func getTileFormat(con *sql.DB) (TileFormat, error) {
 }
 
 // getTileFormatAndSize reads the first tile in the database to detect the tile
-// format and if PNG also the size.
+// format and if PNG also the size.  The tile is read from store if it is an
+// external blob; store may be nil.
 // See TileFormat for list of supported tile formats.
-func getTileFormatAndSize(con *sql.DB) (TileFormat, uint32, error) {
+func getTileFormatAndSize(ctx context.Context, con querier, store BlobStore) (TileFormat, uint32, error) {
 	var tilesize uint32 = 0 // not detected for all formats
 
 	var tileData []byte
-	err := con.QueryRow("select tile_data from tiles limit 1").Scan(&tileData)
+	err := con.QueryRowContext(ctx, "select tile_data from tiles limit 1").Scan(&tileData)
 
 	if err != nil {
 		return UNKNOWN, tilesize, err
 	}
+	if err := resolveBlob(ctx, store, &tileData); err != nil {
+		return UNKNOWN, tilesize, err
+	}
 
-	format, err := detectTileFormat(tileData)
+	format, err := ParseTileFormat(tileData)
 	if err != nil {
 		return UNKNOWN, tilesize, err
 	}
@@ -295,7 +912,7 @@ func getTileFormatAndSize(con *sql.DB) (TileFormat, uint32, error) {
 		format = PBF
 	}
 
-	tilesize, err = detectTileSize(format, tileData)
+	tilesize, err = ParseTileSize(format, tileData)
 	if err != nil {
 		return format, tilesize, err
 	}
@@ -303,10 +920,11 @@ func getTileFormatAndSize(con *sql.DB) (TileFormat, uint32, error) {
 	return format, tilesize, nil
 }
 
-// parseFloats converts a commma-delimited string of floats to a slice of
-// float64 and returns it and the first error that was encountered.
+// ParseFloats converts a comma-delimited string of floats, such as the bounds
+// metadata item, to a slice of float64, and returns it and the first error
+// that was encountered.
 // Example: "1.5,2.1" => [1.5, 2.1]
-func parseFloats(str string) ([]float64, error) {
+func ParseFloats(str string) ([]float64, error) {
 	split := strings.Split(str, ",")
 	var out []float64
 	for _, v := range split {
@@ -1,8 +1,13 @@
 package mbtiles
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -98,12 +103,122 @@ func Test_OpenMBtiles_invalid(t *testing.T) {
 	}
 }
 
+func Test_OpenContext(t *testing.T) {
+	db, err := OpenContext(context.Background(), "./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if db, err := OpenContext(ctx, "./testdata/geography-class-png.mbtiles"); err != context.Canceled || db != nil {
+		t.Error("Expected context.Canceled opening with canceled context, got:", err)
+	}
+}
+
+func Test_OpenMBtiles_lazy(t *testing.T) {
+	var queries int32
+	db, err := Open("./testdata/geography-class-png.mbtiles", LazyOpen(), TraceQueries(func(QueryTrace) { atomic.AddInt32(&queries, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if queries != 0 {
+		t.Error("Expected no queries on lazy open, got:", queries)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var data []byte
+			if err := db.ReadTile(0, 0, 0, &data); err != nil || len(data) != 21246 {
+				t.Error("Could not read tile after lazy open:", len(data), err)
+			}
+		}()
+	}
+	wg.Wait()
+	if db.GetTileFormat() != PNG || db.GetTileSize() != 256 {
+		t.Error("Tile format and size do not match expected values, got:", db.GetTileFormat(), db.GetTileSize())
+	}
+
+	// errors are deferred to first use
+	invalid, err := Open("./testdata/invalid.mbtiles", LazyOpen())
+	if err != nil {
+		t.Fatal("Unexpected error on lazy open of invalid file:", err)
+	}
+	defer invalid.Close()
+	var data []byte
+	if err := invalid.ReadTile(0, 0, 0, &data); err == nil {
+		t.Error("Expected error reading tile from invalid file")
+	}
+	if _, err := invalid.ReadMetadata(); err == nil {
+		t.Error("Expected error reading metadata from invalid file")
+	}
+	if invalid.GetTileFormat() != UNKNOWN {
+		t.Error("Expected UNKNOWN tile format for invalid file, got:", invalid.GetTileFormat())
+	}
+}
+
 func Test_CloseMBtiles(t *testing.T) {
 	// an MBtiles handle should not panic on close
 	fakeDB := &MBtiles{}
 	fakeDB.Close()
 }
 
+func Test_ReadAfterClose(t *testing.T) {
+	ctx := context.Background()
+	lazy, err := Open("./testdata/geography-class-png.mbtiles", LazyOpen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy.Close()
+
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	db.Close()
+
+	for _, closed := range []*MBtiles{db, lazy} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if _, err := closed.GetTile(ctx, 0, 0, 0); err == nil || !strings.Contains(err.Error(), "closed mbtiles database") {
+				t.Error("Expected error reading tile after close, got:", err)
+			}
+			var data []byte
+			if err := closed.ReadTile(0, 0, 0, &data); err == nil {
+				t.Error("Expected error reading tile after close")
+			}
+			if _, err := closed.OpenTile(ctx, 0, 0, 0); err == nil {
+				t.Error("Expected error opening tile after close")
+			}
+			if err := closed.Reload(ctx); err == nil {
+				t.Error("Expected error reloading after close")
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Reads after close did not return")
+		}
+	}
+
+	// closing a lazy handle does not affect other uninitialized handles
+	other, err := Open("./testdata/geography-class-png.mbtiles", LazyOpen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Error("Could not read tile of other lazy handle:", err)
+	}
+}
+
 func Test_ReadMetadata(t *testing.T) {
 	tests := []struct {
 		path    string
@@ -189,6 +304,52 @@ func Test_ReadMetadata_contents(t *testing.T) {
 	}
 }
 
+func Test_ReadMetadata_cached(t *testing.T) {
+	filename := copyTestdata(t, "world_cities.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := metadata["name"]
+	metadata["name"] = "modified by caller"
+
+	if _, err := db.pool.Exec("update metadata set value = 'updated' where name = 'name'"); err != nil {
+		t.Fatal(err)
+	}
+	metadata, _ = db.ReadMetadata()
+	if metadata["name"] != name {
+		t.Error("Expected cached metadata, got name:", metadata["name"])
+	}
+
+	db.InvalidateMetadata()
+	metadata, _ = db.ReadMetadata()
+	if metadata["name"] != "updated" {
+		t.Error("Expected metadata to be read again after invalidation, got name:", metadata["name"])
+	}
+
+	// metadata is invalidated if the file is modified
+	if _, err := db.pool.Exec("update metadata set value = 'updated again' where name = 'name'"); err != nil {
+		t.Fatal(err)
+	}
+	modified := db.GetTimestamp().Add(time.Hour)
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RefreshTimestamp(); err != nil {
+		t.Fatal(err)
+	}
+	metadata, _ = db.ReadMetadata()
+	if metadata["name"] != "updated again" {
+		t.Error("Expected metadata to be read again after modification, got name:", metadata["name"])
+	}
+}
+
 func Test_ReadTile(t *testing.T) {
 	tests := []struct {
 		z     int64
@@ -218,6 +379,114 @@ func Test_ReadTile(t *testing.T) {
 	}
 }
 
+func Test_GetTile(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	data, err := db.GetTile(ctx, 0, 0, 0)
+	if err != nil || len(data) != 21246 {
+		t.Error("GetTile returned different number of bytes than expected, got:", len(data), err)
+	}
+	if data, err := db.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound || data != nil {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", len(data), err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := db.GetTile(canceled, 1, 0, 0); err == nil {
+		t.Error("GetTile did not fail for canceled context")
+	}
+}
+
+func Test_Reload(t *testing.T) {
+	db, err := Open(copyTestdata(t, "geography-class-png.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	jpg, err := Open("./testdata/geography-class-jpg.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jpg.Close()
+	jpgTile, err := jpg.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	// change the file in place, as another process would
+	if _, err := db.pool.Exec("update images set tile_data = ?", jpgTile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.pool.Exec("update metadata set value = 'reloaded' where name = 'name'"); err != nil {
+		t.Fatal(err)
+	}
+
+	// reads are not interrupted by concurrent reloads
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := db.GetTile(ctx, 1, 0, 0); err != nil {
+					t.Error("Unexpected error reading tile during reload:", err)
+					return
+				}
+				if _, err := db.ReadMetadata(); err != nil {
+					t.Error("Unexpected error reading metadata during reload:", err)
+					return
+				}
+				db.GetTileFormat()
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := db.Reload(ctx); err != nil {
+			t.Error("Could not reload:", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if db.GetTileFormat() != JPG {
+		t.Error("Reload did not detect new tile format, got:", db.GetTileFormat())
+	}
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["name"] != "reloaded" {
+		t.Error("Reload did not clear cached metadata, got:", metadata["name"])
+	}
+
+	// the handle is unchanged if the file is no longer valid
+	if _, err := db.pool.Exec("delete from map"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(ctx); err == nil {
+		t.Error("Reload did not fail for file without tiles")
+	}
+	if db.GetTileFormat() != JPG {
+		t.Error("Failed reload changed tile format, got:", db.GetTileFormat())
+	}
+}
+
 func Test_GetFilename(t *testing.T) {
 	filename := "./testdata/geography-class-png.mbtiles"
 	db, _ := Open(filename)
@@ -240,3 +509,108 @@ func Test_GetTimestamp(t *testing.T) {
 		t.Error("Timestamp does not match value from os.Stat, got:", db.GetTimestamp())
 	}
 }
+
+func Test_RefreshTimestamp(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal("Could not open:", filename, err)
+	}
+	defer db.Close()
+
+	modified := db.GetTimestamp().Add(time.Hour)
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, err := db.RefreshTimestamp()
+	if err != nil {
+		t.Fatal("Unexpected error refreshing timestamp:", err)
+	}
+	if !timestamp.Equal(modified) || !db.GetTimestamp().Equal(modified) {
+		t.Error("Refreshed timestamp does not match modification time, got:", db.GetTimestamp())
+	}
+}
+
+func Test_GetZoomRange(t *testing.T) {
+	filename := copyTestdata(t, "world_cities.mbtiles")
+	var queries int
+	db, err := Open(filename, TraceQueries(func(QueryTrace) { queries++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	minZoom, err := db.GetMinZoom()
+	if err != nil || minZoom != 0 {
+		t.Error("Min zoom does not match expected value, got:", minZoom, err)
+	}
+	maxZoom, err := db.GetMaxZoom()
+	if err != nil || maxZoom != 6 {
+		t.Error("Max zoom does not match expected value, got:", maxZoom, err)
+	}
+
+	// zoom range is cached
+	queries = 0
+	db.GetMinZoom()
+	db.GetMaxZoom()
+	if queries != 0 {
+		t.Error("Expected cached zoom range, got queries:", queries)
+	}
+
+	// zoom range is read again after tiles are deleted
+	ctx := context.Background()
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 5, 6); err != nil {
+		t.Fatal(err)
+	}
+	if maxZoom, err := db.GetMaxZoom(); err != nil || maxZoom != 4 {
+		t.Error("Max zoom does not match expected value after delete, got:", maxZoom, err)
+	}
+
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 0, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetMinZoom(); err != ErrNoTiles {
+		t.Error("Expected ErrNoTiles for empty mbtiles file, got:", err)
+	}
+}
+
+// copyTestdata copies the named file in testdata to a temporary directory that
+// is removed when the test completes, and returns the path of the copy.
+func copyTestdata(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func Test_ParseFloats(t *testing.T) {
+	values, err := ParseFloats("1.5, 2.1,-3")
+	if err != nil || !reflect.DeepEqual(values, []float64{1.5, 2.1, -3}) {
+		t.Error("Values do not match expected value, got:", values, err)
+	}
+	for _, str := range []string{"", "1,,2", "1;2"} {
+		if _, err := ParseFloats(str); err == nil {
+			t.Error("Expected error for malformed floats:", str)
+		}
+	}
+}
+
+func Fuzz_ParseFloats(f *testing.F) {
+	f.Add("-180,-85.0511,180,85.0511")
+	f.Add("1e400,NaN")
+	f.Fuzz(func(t *testing.T, str string) {
+		values, err := ParseFloats(str)
+		if err == nil && len(values) != strings.Count(str, ",")+1 {
+			t.Error("Expected a value for each comma-separated item, got:", values)
+		}
+	})
+}
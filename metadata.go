@@ -0,0 +1,517 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Metadata is a typed view of the metadata items of an mbtiles file, as read
+// by ReadMetadata.  Items that are not present are left as zero values.
+type Metadata struct {
+	Name        string
+	Description string
+	Attribution string
+	Version     string
+	Type        string    // overlay or baselayer
+	Format      string    // format metadata item, which may differ from the detected tile format
+	Bounds      []float64 // [west, south, east, north] in degrees
+	Center      []float64 // [longitude, latitude, zoom]
+	MinZoom     int
+	MaxZoom     int
+	// VectorLayers is the vector_layers item of the json metadata item of
+	// vector tilesets.
+	VectorLayers []interface{}
+
+	// CRS, CRSWKT and Extent are extensions emitted by some producers of
+	// tilesets that do not use Web Mercator.
+	CRS    string    // coordinate reference system, for example EPSG:3857
+	CRSWKT string    // coordinate reference system in Well-Known Text
+	Extent []float64 // [minX, minY, maxX, maxY] in units of the CRS
+
+	// Generator and GeneratorOptions describe the program that created the
+	// tileset, for example tippecanoe or planetiler, and its arguments.
+	Generator        string
+	GeneratorOptions string
+	// Planetiler holds the planetiler:* items written by planetiler, by their
+	// full names.
+	Planetiler map[string]string
+	// TippecanoeDecisions is the parsed tippecanoe_decisions item written by
+	// tippecanoe, describing how it simplified or dropped features.
+	TippecanoeDecisions map[string]interface{}
+}
+
+// GetMetadata reads the metadata of the mbtiles file into a Metadata struct.
+//
+// Generator metadata is preserved by operations that copy metadata to a new
+// mbtiles file, such as ExtractZoom and Mosaic.
+func (db *MBtiles) GetMetadata() (Metadata, error) {
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return Metadata{}, err
+	}
+	return newMetadata(metadata)
+}
+
+// newMetadata creates a Metadata struct from metadata items parsed as by
+// ReadMetadata.
+func newMetadata(metadata map[string]interface{}) (Metadata, error) {
+	var m Metadata
+	text := map[string]*string{
+		"name":        &m.Name,
+		"description": &m.Description,
+		"attribution": &m.Attribution,
+		"version":     &m.Version,
+		"type":        &m.Type,
+		"format":      &m.Format,
+		"crs":         &m.CRS,
+		"crs_wkt":     &m.CRSWKT,
+
+		"generator":         &m.Generator,
+		"generator_options": &m.GeneratorOptions,
+	}
+	for key, value := range text {
+		*value, _ = metadata[key].(string)
+	}
+	m.Bounds, _ = metadata["bounds"].([]float64)
+	m.Center, _ = metadata["center"].([]float64)
+	m.Extent, _ = metadata["extent"].([]float64)
+	m.MinZoom, _ = metadata["minzoom"].(int)
+	m.MaxZoom, _ = metadata["maxzoom"].(int)
+	m.VectorLayers, _ = metadata["vector_layers"].([]interface{})
+
+	for key, value := range metadata {
+		if text, ok := value.(string); ok && strings.HasPrefix(key, "planetiler:") {
+			if m.Planetiler == nil {
+				m.Planetiler = make(map[string]string)
+			}
+			m.Planetiler[key] = text
+		}
+	}
+	if decisions, ok := metadata["tippecanoe_decisions"].(string); ok {
+		if err := json.Unmarshal([]byte(decisions), &m.TippecanoeDecisions); err != nil {
+			return Metadata{}, fmt.Errorf("cannot read metadata item tippecanoe_decisions: %v", err)
+		}
+	}
+	return m, nil
+}
+
+// ParseMetadata parses the items of a metadata table, such as one read from
+// an uploaded mbtiles file before it is opened, into a Metadata struct as
+// GetMetadata does.  The keys of the json item are parsed first, so that other
+// items take precedence over keys of the same name, and empty items are
+// ignored.  An error is returned for malformed items; ParseMetadata does not
+// panic for any input.
+func ParseMetadata(items map[string]string) (Metadata, error) {
+	metadata := make(map[string]interface{})
+	if value := items["json"]; value != "" {
+		if err := parseMetadataItem(metadata, "json", value); err != nil {
+			return Metadata{}, err
+		}
+	}
+	for key, value := range items {
+		if key == "json" || value == "" {
+			continue
+		}
+		if err := parseMetadataItem(metadata, key, value); err != nil {
+			return Metadata{}, err
+		}
+	}
+	return newMetadata(metadata)
+}
+
+// isGeneratorKey returns true if key is a metadata item describing the
+// program that created the tileset.
+func isGeneratorKey(key string) bool {
+	return key == "generator" || key == "generator_options" || key == "tippecanoe_decisions" || strings.HasPrefix(key, "planetiler:")
+}
+
+// IsWebMercator returns true if the metadata does not declare a coordinate
+// reference system, or declares the Web Mercator system.
+func (m Metadata) IsWebMercator() bool {
+	switch strings.ToUpper(strings.TrimSpace(m.CRS)) {
+	case "", "EPSG:3857", "EPSG:900913", "EPSG:3785", "URN:OGC:DEF:CRS:EPSG::3857", strings.ToUpper(webMercatorCRS):
+		return true
+	}
+	return false
+}
+
+// SetProjection writes the crs, crs_wkt and extent metadata items, removing
+// items that are empty.  extent is [minX, minY, maxX, maxY] in units of crs.
+// The mbtiles file must be writable.
+func (db *MBtiles) SetProjection(ctx context.Context, crs string, crsWKT string, extent []float64) error {
+	if db.isClosed() {
+		return errors.New("cannot write metadata to closed mbtiles database")
+	}
+	if extent != nil && (len(extent) != 4 || extent[0] > extent[2] || extent[1] > extent[3]) {
+		return fmt.Errorf("extent %v must have 4 values: minX, minY, maxX, maxY", extent)
+	}
+
+	items := map[string]string{"crs": crs, "crs_wkt": crsWKT, "extent": ""}
+	if extent != nil {
+		items["extent"] = fmt.Sprintf("%f,%f,%f,%f", extent[0], extent[1], extent[2], extent[3])
+	}
+	return db.writeMetadata(ctx, items)
+}
+
+// SyncMetadata enables updating the minzoom, maxzoom, and bounds metadata
+// items when operations using the MBtiles handle write or delete tiles, as
+// stale values are a common cause of tiles not being displayed.
+//
+// The items are updated in the same transaction as the change to match the
+// zoom levels of the tiles and the extent of the tiles at all zoom levels.
+// Each side of the bounds is only moved if it lies outside of the outermost
+// tiles of all zoom levels, or if tiles of a zoom level lie entirely beyond
+// it, so precise bounds are kept while they match the tiles, and tiles
+// written at a single zoom level, such as by the write-back of
+// AncestorFallback, never shrink them.  Syncing requires a query of the
+// extent of the tiles at each zoom level for each change.
+func SyncMetadata() OpenOption {
+	return func(o *openOptions) {
+		o.syncMetadata = true
+	}
+}
+
+// syncMetadata updates the minzoom, maxzoom, and bounds metadata items to
+// match the tiles within a transaction that changes tiles, if enabled with
+// SyncMetadata.  Items are left as they are if no tiles remain.
+func (db *MBtiles) syncMetadata(ctx context.Context, tx querier, schema tileSchema) error {
+	if !db.options.syncMetadata {
+		return nil
+	}
+
+	// the outermost tiles of each zoom level, as [west, south, east, north]
+	// bounds of the tiles at the west, south, east, and north edges
+	var edges [][4][4]float64
+	var minZoom, maxZoom int64
+	rows, err := tx.QueryContext(ctx, "select zoom_level, min(tile_column), max(tile_column), min(tile_row), max(tile_row) from "+schema.table+" group by zoom_level")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var z, minCol, maxCol, minRow, maxRow int64
+		if err := rows.Scan(&z, &minCol, &maxCol, &minRow, &maxRow); err != nil {
+			rows.Close()
+			return err
+		}
+		if len(edges) == 0 || z < minZoom {
+			minZoom = z
+		}
+		if len(edges) == 0 || z > maxZoom {
+			maxZoom = z
+		}
+		// tile rows are stored in the TMS scheme, so the highest row is at the
+		// top
+		topLeft := tileBounds(TileCoord{Z: z, X: minCol, Y: maxRow}.FlipY())
+		bottomRight := tileBounds(TileCoord{Z: z, X: maxCol, Y: minRow}.FlipY())
+		edges = append(edges, [4][4]float64{topLeft, bottomRight, bottomRight, topLeft})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+
+	current := make(map[string]string)
+	rows, err = tx.QueryContext(ctx, "select name, value from metadata where name in ('minzoom', 'maxzoom', 'bounds')")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		current[name] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	items := make(map[string]string)
+	if value := strconv.FormatInt(minZoom, 10); current["minzoom"] != value {
+		items["minzoom"] = value
+	}
+	if value := strconv.FormatInt(maxZoom, 10); current["maxzoom"] != value {
+		items["maxzoom"] = value
+	}
+
+	// the extent of the tiles of all zoom levels
+	outer := []float64{edges[0][0][0], edges[0][1][1], edges[0][2][2], edges[0][3][3]}
+	for _, edge := range edges[1:] {
+		outer[0] = math.Min(outer[0], edge[0][0])
+		outer[1] = math.Min(outer[1], edge[1][1])
+		outer[2] = math.Max(outer[2], edge[2][2])
+		outer[3] = math.Max(outer[3], edge[3][3])
+	}
+	bounds, err := ParseFloats(current["bounds"])
+	if err != nil || len(bounds) != 4 {
+		bounds = outer
+	} else {
+		synced := []float64{bounds[0], bounds[1], bounds[2], bounds[3]}
+		// a side moves to the extent of the tiles if it lies beyond it, or if
+		// the outermost tiles of a zoom level are entirely beyond the side
+		beyond := [4]bool{bounds[0] < outer[0], bounds[1] < outer[1], bounds[2] > outer[2], bounds[3] > outer[3]}
+		for _, edge := range edges {
+			beyond[0] = beyond[0] || edge[0][2] <= bounds[0]
+			beyond[1] = beyond[1] || edge[1][3] <= bounds[1]
+			beyond[2] = beyond[2] || edge[2][0] >= bounds[2]
+			beyond[3] = beyond[3] || edge[3][1] >= bounds[3]
+		}
+		changed := false
+		for i := range synced {
+			if beyond[i] {
+				synced[i] = outer[i]
+				changed = true
+			}
+		}
+		bounds = nil
+		if changed {
+			bounds = synced
+		}
+	}
+	if bounds != nil {
+		items["bounds"] = fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+	return writeMetadataItems(ctx, tx, items)
+}
+
+// writeMetadata sets metadata items, replacing any existing values, in a
+// single transaction, and clears the metadata cached by ReadMetadata.  Items
+// with empty values are removed.
+func (db *MBtiles) writeMetadata(ctx context.Context, items map[string]string) error {
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writeMetadataItems(ctx, db.traced(tx), items); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.InvalidateMetadata()
+	return nil
+}
+
+// writeMetadataItems sets metadata items within a transaction, as described
+// for writeMetadata.
+func writeMetadataItems(ctx context.Context, tx querier, items map[string]string) error {
+	for name, value := range items {
+		// not all mbtiles files have a unique index on name, which is required
+		// for insert or replace
+		if _, err := tx.ExecContext(ctx, "delete from metadata where name = ?", name); err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "insert into metadata (name, value) values (?, ?)", name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateMetadata reads the metadata of the mbtiles file, calls update to
+// change it, and writes the changed items back in a single transaction, so
+// that tools updating metadata concurrently do not overwrite each other's
+// changes: the transaction holds the write lock from the start, so no other
+// writer can change the metadata between the read and the write.  No items
+// are written if update returns an error, which is returned.
+//
+// Only items whose values are changed by update are written; items that are
+// not represented in Metadata are left as they are.  Changes to VectorLayers
+// are written to the json metadata item, preserving its other contents.
+// Unlike GetMetadata, MinZoom and MaxZoom are 0 if the minzoom and maxzoom
+// items are not present.
+func (db *MBtiles) UpdateMetadata(ctx context.Context, update func(m *Metadata) error) error {
+	if db.isClosed() {
+		return errors.New("cannot write metadata to closed mbtiles database")
+	}
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := db.traced(tx)
+	// a write acquires the write lock before metadata is read
+	if _, err := q.ExecContext(ctx, "delete from metadata where 0"); err != nil {
+		return err
+	}
+	rows, err := q.QueryContext(ctx, "select name, value from metadata where value is not ''")
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]interface{})
+	var jsonItem string
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == "json" {
+			jsonItem = value
+		}
+		if err := parseMetadataItem(parsed, name, value); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	m, err := newMetadata(parsed)
+	if err != nil {
+		return err
+	}
+	// update may change slices and maps of m in place, so the items are
+	// encoded before it is called
+	before, err := m.items()
+	if err != nil {
+		return err
+	}
+	beforeLayers, err := json.Marshal(m.VectorLayers)
+	if err != nil {
+		return err
+	}
+	if err := update(&m); err != nil {
+		return err
+	}
+
+	items, err := changedMetadataItems(before, beforeLayers, m, jsonItem)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := writeMetadataItems(ctx, q, items); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.InvalidateMetadata()
+	return nil
+}
+
+// changedMetadataItems returns the metadata items of after that differ from
+// the items and encoded vector layers of before, with empty values for items
+// that were removed.  jsonItem is the current value of the json metadata
+// item, which is updated if VectorLayers changed, and stays gzip compressed if
+// it is.
+func changedMetadataItems(beforeItems map[string]string, beforeLayers []byte, after Metadata, jsonItem string) (map[string]string, error) {
+	afterItems, err := after.items()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]string)
+	for key, value := range afterItems {
+		if beforeItems[key] != value {
+			changed[key] = value
+		}
+	}
+	for key := range beforeItems {
+		if _, ok := afterItems[key]; !ok {
+			changed[key] = ""
+		}
+	}
+
+	afterLayers, err := json.Marshal(after.VectorLayers)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(beforeLayers, afterLayers) {
+		content := make(map[string]interface{})
+		if jsonItem != "" {
+			if err := decodeMetadataJSON(jsonItem, &content); err != nil {
+				return nil, err
+			}
+		}
+		if after.VectorLayers == nil {
+			delete(content, "vector_layers")
+		} else {
+			content["vector_layers"] = after.VectorLayers
+		}
+		changed["json"] = ""
+		if len(content) > 0 {
+			encoded, err := json.Marshal(content)
+			if err != nil {
+				return nil, err
+			}
+			if isGzippedJSON(jsonItem) {
+				// keep the item compressed as the generator stored it
+				if encoded, err = gzipBytes(encoded); err != nil {
+					return nil, err
+				}
+			}
+			changed["json"] = string(encoded)
+		}
+	}
+	return changed, nil
+}
+
+// items returns the metadata items represented by m, other than the json
+// item, by name.  Empty items are omitted.
+func (m Metadata) items() (map[string]string, error) {
+	items := map[string]string{
+		"name":              m.Name,
+		"description":       m.Description,
+		"attribution":       m.Attribution,
+		"version":           m.Version,
+		"type":              m.Type,
+		"format":            m.Format,
+		"crs":               m.CRS,
+		"crs_wkt":           m.CRSWKT,
+		"generator":         m.Generator,
+		"generator_options": m.GeneratorOptions,
+		"bounds":            formatFloats(m.Bounds),
+		"center":            formatFloats(m.Center),
+		"extent":            formatFloats(m.Extent),
+		"minzoom":           strconv.Itoa(m.MinZoom),
+		"maxzoom":           strconv.Itoa(m.MaxZoom),
+	}
+	for key, value := range m.Planetiler {
+		items[key] = value
+	}
+	if m.TippecanoeDecisions != nil {
+		decisions, err := json.Marshal(m.TippecanoeDecisions)
+		if err != nil {
+			return nil, err
+		}
+		items["tippecanoe_decisions"] = string(decisions)
+	}
+	for key, value := range items {
+		if value == "" {
+			delete(items, key)
+		}
+	}
+	return items, nil
+}
+
+// formatFloats formats values as a comma-separated list, as parsed by
+// ParseFloats.
+func formatFloats(values []float64) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strings.Join(formatted, ",")
+}
@@ -0,0 +1,336 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func Test_GetMetadata(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	metadata, err := db.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Name != "Major cities from Natural Earth data" || metadata.Format != "pbf" || metadata.MinZoom != 0 || metadata.MaxZoom != 6 {
+		t.Error("Metadata does not match expected values, got:", metadata)
+	}
+	if len(metadata.Bounds) != 4 || len(metadata.VectorLayers) != 1 {
+		t.Error("Metadata bounds or vector layers do not match expected values, got:", metadata)
+	}
+	if !metadata.IsWebMercator() {
+		t.Error("Tileset without crs is not Web Mercator")
+	}
+
+	extent := []float64{2000000, 1000000, 6000000, 5000000}
+	if err := db.SetProjection(ctx, "EPSG:3035", `PROJCS["ETRS89-extended / LAEA Europe"]`, extent); err != nil {
+		t.Fatal("Could not set projection:", err)
+	}
+	if metadata, err = db.GetMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.CRS != "EPSG:3035" || metadata.CRSWKT != `PROJCS["ETRS89-extended / LAEA Europe"]` || len(metadata.Extent) != 4 || metadata.Extent[2] != 6000000 {
+		t.Error("Projection metadata does not match expected values, got:", metadata)
+	}
+	if metadata.IsWebMercator() {
+		t.Error("Tileset with EPSG:3035 crs is Web Mercator")
+	}
+
+	if err := db.SetProjection(ctx, "EPSG:3857", "", nil); err != nil {
+		t.Fatal("Could not set projection:", err)
+	}
+	if metadata, err = db.GetMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if !metadata.IsWebMercator() || metadata.CRSWKT != "" || metadata.Extent != nil {
+		t.Error("Projection metadata was not replaced, got:", metadata)
+	}
+
+	if err := db.SetProjection(ctx, "EPSG:3857", "", []float64{1, 2}); err == nil {
+		t.Error("SetProjection did not fail for invalid extent")
+	}
+}
+
+func Test_GetMetadata_generator(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	err = db.writeMetadata(ctx, map[string]string{
+		"generator":            "tippecanoe v2.26.1",
+		"generator_options":    "tippecanoe -o world_cities.mbtiles -z6",
+		"planetiler:version":   "0.7.0",
+		"tippecanoe_decisions": `{"basezoom":6,"droprate":2.5}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generator metadata is preserved by extracts
+	dst := filepath.Join(t.TempDir(), "extract.mbtiles")
+	if _, err := db.ExtractZoom(ctx, dst, 1); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	metadata, err := out.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Generator != "tippecanoe v2.26.1" || metadata.GeneratorOptions != "tippecanoe -o world_cities.mbtiles -z6" {
+		t.Error("Generator metadata does not match expected values, got:", metadata)
+	}
+	if len(metadata.Planetiler) != 1 || metadata.Planetiler["planetiler:version"] != "0.7.0" {
+		t.Error("Planetiler metadata does not match expected values, got:", metadata.Planetiler)
+	}
+	if metadata.TippecanoeDecisions["droprate"] != 2.5 {
+		t.Error("Tippecanoe decisions do not match expected values, got:", metadata.TippecanoeDecisions)
+	}
+}
+
+func Test_UpdateMetadata(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.UpdateMetadata(ctx, func(m *Metadata) error {
+		m.Name = "Cities"
+		m.Attribution = "Natural Earth"
+		m.Type = ""
+		m.Center[2] = 4
+		m.VectorLayers = m.VectorLayers[:0]
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Could not update metadata:", err)
+	}
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["name"] != "Cities" || metadata["attribution"] != "Natural Earth" {
+		t.Error("Updated metadata does not match expected values, got:", metadata)
+	}
+	if _, ok := metadata["type"]; ok {
+		t.Error("Expected type to be removed, got:", metadata["type"])
+	}
+	if center := metadata["center"].([]float64); center[2] != 4 {
+		t.Error("Center does not match expected value, got:", center)
+	}
+	if layers := metadata["vector_layers"].([]interface{}); len(layers) != 0 {
+		t.Error("Expected vector layers to be removed, got:", layers)
+	}
+	if _, ok := metadata["tilestats"]; !ok {
+		t.Error("Expected other items of the json metadata item to be preserved")
+	}
+
+	// unchanged items are not rewritten
+	var bounds string
+	if err := db.pool.QueryRow("select value from metadata where name = 'bounds'").Scan(&bounds); err != nil {
+		t.Fatal(err)
+	}
+	if bounds != "-123.123590,-37.818085,174.763027,59.352706" {
+		t.Error("Expected bounds to be unchanged, got:", bounds)
+	}
+
+	// nothing is written if update fails
+	failed := errors.New("failed")
+	err = db.UpdateMetadata(ctx, func(m *Metadata) error {
+		m.Name = "Other"
+		return failed
+	})
+	if err != failed {
+		t.Error("Expected error from update, got:", err)
+	}
+	if name, _ := db.GetMetadata(); name.Name != "Cities" {
+		t.Error("Expected metadata to be unchanged, got:", name.Name)
+	}
+}
+
+func Test_syncMetadata(t *testing.T) {
+	ctx := context.Background()
+	items := func(db *MBtiles) map[string]string {
+		rows, err := db.pool.Query("select name, value from metadata where name in ('minzoom', 'maxzoom', 'bounds')")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		items := make(map[string]string)
+		for rows.Next() {
+			var name, value string
+			rows.Scan(&name, &value)
+			items[name] = value
+		}
+		return items
+	}
+
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"), SyncMetadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	before := items(db)
+
+	// deleting the highest zoom level keeps bounds within the remaining tiles
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 6, 6); err != nil {
+		t.Fatal(err)
+	}
+	after := items(db)
+	if after["maxzoom"] != "5" || after["minzoom"] != "0" {
+		t.Error("Zoom levels do not match expected values, got:", after)
+	}
+	if after["bounds"] != before["bounds"] {
+		t.Error("Expected bounds to be unchanged, got:", after["bounds"])
+	}
+	if m, err := db.GetMetadata(); err != nil || m.MaxZoom != 5 {
+		t.Error("Expected cached metadata to be cleared, got:", m.MaxZoom, err)
+	}
+
+	// deleting the western hemisphere moves the west side of bounds
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, -1, 85}, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := db.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Bounds[0] < 0 || metadata.Bounds[2] != 174.763027 {
+		t.Error("Bounds do not match expected values, got:", metadata.Bounds)
+	}
+
+	// writing a single tile above the highest zoom level, as for the
+	// write-back of AncestorFallback, does not shrink bounds
+	before = items(db)
+	if err := db.insertTile(ctx, 6, 49, 33, []byte("tile")); err != nil {
+		t.Fatal(err)
+	}
+	after = items(db)
+	if after["maxzoom"] != "6" {
+		t.Error("Expected maxzoom to be updated, got:", after)
+	}
+	if after["bounds"] != before["bounds"] {
+		t.Error("Expected bounds to be unchanged, got:", after["bounds"])
+	}
+
+	unsynced, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsynced.Close()
+	if _, err := unsynced.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 6, 6); err != nil {
+		t.Fatal(err)
+	}
+	if got := items(unsynced); got["maxzoom"] != "6" {
+		t.Error("Expected metadata to be unchanged without SyncMetadata, got:", got)
+	}
+}
+
+func Test_ParseMetadata(t *testing.T) {
+	metadata, err := ParseMetadata(map[string]string{
+		"name":    "test",
+		"minzoom": "1",
+		"bounds":  "-10,-20,10,20",
+		"json":    `{"vector_layers": [{"id": "cities"}], "name": "ignored"}`,
+		"center":  "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Name != "test" || metadata.MinZoom != 1 || len(metadata.Bounds) != 4 || len(metadata.VectorLayers) != 1 || metadata.Center != nil {
+		t.Error("Metadata does not match expected value, got:", metadata)
+	}
+
+	for _, items := range []map[string]string{
+		{"minzoom": "one"},
+		{"bounds": "-10,x"},
+		{"json": "[1, 2]"},
+	} {
+		if _, err := ParseMetadata(items); err == nil {
+			t.Error("Expected error for malformed metadata:", items)
+		}
+	}
+}
+
+func Fuzz_ParseMetadata(f *testing.F) {
+	f.Add("bounds", "-10,-20,10,20", `{"vector_layers": [{"id": "cities"}]}`)
+	f.Add("minzoom", "3", `{"minzoom": "x", "bounds": {}}`)
+	f.Add("tippecanoe_decisions", `{"a": 1}`, "null")
+	f.Fuzz(func(t *testing.T, key string, value string, jsonItem string) {
+		ParseMetadata(map[string]string{key: value, "json": jsonItem})
+	})
+}
+
+func Test_ReadMetadata_gzippedJSON(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var jsonItem string
+	if err := db.pool.QueryRow("select value from metadata where name = 'json'").Scan(&jsonItem); err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := gzipBytes([]byte(jsonItem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.pool.Exec("update metadata set value = ? where name = 'json'", string(compressed)); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal("Could not read gzipped json metadata:", err)
+	}
+	if layers, ok := metadata["vector_layers"].([]interface{}); !ok || len(layers) != 1 {
+		t.Error("Expected vector layers from gzipped json metadata, got:", metadata["vector_layers"])
+	}
+	if parsed, err := ParseMetadata(map[string]string{"json": string(compressed)}); err != nil || len(parsed.VectorLayers) != 1 {
+		t.Error("Expected vector layers from gzipped json metadata, got:", parsed.VectorLayers, err)
+	}
+
+	// updated items stay compressed
+	err = db.UpdateMetadata(ctx, func(m *Metadata) error {
+		m.VectorLayers = m.VectorLayers[:0]
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Could not update metadata:", err)
+	}
+	if err := db.pool.QueryRow("select value from metadata where name = 'json'").Scan(&jsonItem); err != nil {
+		t.Fatal(err)
+	}
+	if !isGzippedJSON(jsonItem) {
+		t.Error("Expected json metadata item to stay compressed")
+	}
+	metadata, err = db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layers := metadata["vector_layers"].([]interface{}); len(layers) != 0 {
+		t.Error("Expected vector layers to be removed, got:", layers)
+	}
+	if _, ok := metadata["tilestats"]; !ok {
+		t.Error("Expected other items of the json metadata item to be preserved")
+	}
+}
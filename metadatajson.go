@@ -0,0 +1,96 @@
+package mbtiles
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// isGzippedJSON returns true if the json metadata item value is gzip
+// compressed, as stored by some generators, detected by its gzip header.
+func isGzippedJSON(value string) bool {
+	return len(value) >= 2 && value[0] == 0x1f && value[1] == 0x8b
+}
+
+// metadataJSONReader returns a reader of the content of the json metadata
+// item value, decompressing it if it is gzip compressed.
+func metadataJSONReader(value string) (io.Reader, error) {
+	r := strings.NewReader(value)
+	if !isGzippedJSON(value) {
+		return r, nil
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress JSON metadata item: %v", err)
+	}
+	return zr, nil
+}
+
+// decodeMetadataJSON decodes the json metadata item value into v, as
+// json.Unmarshal does, decompressing it if it is gzip compressed.
+func decodeMetadataJSON(value string, v interface{}) error {
+	r, err := metadataJSONReader(value)
+	if err != nil {
+		return err
+	}
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("unable to parse JSON metadata item: %v", err)
+	}
+	return nil
+}
+
+// parseMetadataJSON adds the keys of the json metadata item value to
+// metadata.  The value is decoded one key at a time from a stream, so that
+// large items, such as those with tilestats of many layers, are not buffered
+// in full beside the decoded values.
+func parseMetadataJSON(metadata map[string]interface{}, value string) error {
+	r, err := metadataJSONReader(value)
+	if err != nil {
+		return err
+	}
+	if err := decodeJSONObject(json.NewDecoder(r), metadata); err != nil {
+		return fmt.Errorf("unable to parse JSON metadata item: %v", err)
+	}
+	return nil
+}
+
+// decodeJSONObject decodes a JSON object from dec into metadata, one key at a
+// time.  null is ignored.
+func decodeJSONObject(dec *json.Decoder, metadata map[string]interface{}) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		// null, as accepted by json.Unmarshal
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errors.New("value is not a JSON object")
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("invalid key %v", token)
+		}
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		metadata[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after JSON object")
+	}
+	return nil
+}
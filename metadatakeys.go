@@ -0,0 +1,175 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// MetadataKey is the name of a metadata item of an mbtiles file.
+type MetadataKey string
+
+// Metadata items defined by the MBTiles 1.3 specification.
+const (
+	KeyName        MetadataKey = "name"        // required
+	KeyFormat      MetadataKey = "format"      // required: pbf, jpg, png, or webp
+	KeyBounds      MetadataKey = "bounds"      // west, south, east, north in degrees
+	KeyCenter      MetadataKey = "center"      // longitude, latitude, zoom
+	KeyMinZoom     MetadataKey = "minzoom"     // lowest zoom level
+	KeyMaxZoom     MetadataKey = "maxzoom"     // highest zoom level
+	KeyAttribution MetadataKey = "attribution" // HTML attribution of the data
+	KeyDescription MetadataKey = "description"
+	KeyType        MetadataKey = "type"    // overlay or baselayer
+	KeyVersion     MetadataKey = "version" // revision of the tileset
+	// KeyJSON is the json item, which lists the vector_layers of vector
+	// tilesets; it is required for pbf tilesets.
+	KeyJSON MetadataKey = "json"
+)
+
+// metadataKeys are the metadata items defined by the MBTiles 1.3
+// specification, in the order of the specification.
+var metadataKeys = []MetadataKey{KeyName, KeyFormat, KeyBounds, KeyCenter, KeyMinZoom, KeyMaxZoom, KeyAttribution, KeyDescription, KeyType, KeyVersion, KeyJSON}
+
+// MetadataKeys returns the metadata items defined by the MBTiles 1.3
+// specification, in the order of the specification.
+func MetadataKeys() []MetadataKey {
+	return append([]MetadataKey(nil), metadataKeys...)
+}
+
+// IsStandard returns true if k is defined by the MBTiles 1.3 specification.
+// Other items, such as generator, are allowed by the specification.
+func (k MetadataKey) IsStandard() bool {
+	for _, key := range metadataKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Required returns true if the MBTiles 1.3 specification requires the item
+// for tilesets of format: name and format for all tilesets, and json for
+// vector tilesets.
+func (k MetadataKey) Required(format TileFormat) bool {
+	switch k {
+	case KeyName, KeyFormat:
+		return true
+	case KeyJSON:
+		return format == PBF
+	}
+	return false
+}
+
+// RequiredMetadataKeys returns the metadata items required for tilesets of
+// format by the MBTiles 1.3 specification.
+func RequiredMetadataKeys(format TileFormat) []MetadataKey {
+	var keys []MetadataKey
+	for _, key := range metadataKeys {
+		if key.Required(format) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// MetadataError is an error found validating a metadata item.
+type MetadataError struct {
+	Key MetadataKey
+	Err error
+}
+
+func (e MetadataError) Error() string {
+	return fmt.Sprintf("metadata item %s: %v", e.Key, e.Err)
+}
+
+func (e MetadataError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateMetadataItems checks metadata items, such as those to be written
+// to a new tileset of format, against the MBTiles 1.3 specification: required
+// items must be present, and standard items must have valid values.  Returns
+// an entry for each invalid or missing item, ordered by key.  Items not
+// defined by the specification are not checked.
+func ValidateMetadataItems(items map[string]string, format TileFormat) []MetadataError {
+	var problems []MetadataError
+	for _, key := range metadataKeys {
+		value, ok := items[string(key)]
+		if !ok || value == "" {
+			if key.Required(format) {
+				problems = append(problems, MetadataError{Key: key, Err: errors.New("missing required item")})
+			}
+			continue
+		}
+		if err := validateMetadataValue(key, value, format); err != nil {
+			problems = append(problems, MetadataError{Key: key, Err: err})
+		}
+	}
+
+	minZoom, minErr := strconv.Atoi(items[string(KeyMinZoom)])
+	maxZoom, maxErr := strconv.Atoi(items[string(KeyMaxZoom)])
+	if minErr == nil && maxErr == nil && minZoom > maxZoom {
+		problems = append(problems, MetadataError{Key: KeyMinZoom, Err: fmt.Errorf("minzoom %d is greater than maxzoom %d", minZoom, maxZoom)})
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Key < problems[j].Key })
+	return problems
+}
+
+// validateMetadataValue returns an error if value is not a valid value of
+// the standard item key for tilesets of format.
+func validateMetadataValue(key MetadataKey, value string, format TileFormat) error {
+	switch key {
+	case KeyFormat:
+		parsed := TileFormatFromExtension(value)
+		if parsed == UNKNOWN || value != parsed.String() {
+			return fmt.Errorf("unknown format %q, must be pbf, jpg, png, or webp", value)
+		}
+		if format != UNKNOWN && parsed != format {
+			return fmt.Errorf("format %q does not match tile format %v", value, format)
+		}
+	case KeyBounds:
+		bounds, err := ParseFloats(value)
+		if err != nil {
+			return err
+		}
+		if err := validateBounds(bounds); err != nil {
+			return err
+		}
+		if bounds[0] < -180 || bounds[2] > 180 || bounds[1] < -90 || bounds[3] > 90 {
+			return fmt.Errorf("bounds %v are outside of the range of longitudes and latitudes", bounds)
+		}
+	case KeyCenter:
+		center, err := ParseFloats(value)
+		if err != nil {
+			return err
+		}
+		if len(center) != 3 {
+			return fmt.Errorf("center %v must have 3 values: longitude, latitude, zoom", center)
+		}
+	case KeyMinZoom, KeyMaxZoom:
+		zoom, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if zoom < 0 || zoom > MaxZoomLevel {
+			return fmt.Errorf("zoom level %d must be between 0 and %d", zoom, MaxZoomLevel)
+		}
+	case KeyType:
+		if value != "overlay" && value != "baselayer" {
+			return fmt.Errorf("unknown type %q, must be overlay or baselayer", value)
+		}
+	case KeyJSON:
+		var content struct {
+			VectorLayers []json.RawMessage `json:"vector_layers"`
+		}
+		if err := json.Unmarshal([]byte(value), &content); err != nil {
+			return fmt.Errorf("cannot parse JSON: %v", err)
+		}
+		if format == PBF && len(content.VectorLayers) == 0 {
+			return errors.New("vector tilesets must list vector_layers")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package mbtiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_RequiredMetadataKeys(t *testing.T) {
+	if keys := RequiredMetadataKeys(PNG); !reflect.DeepEqual(keys, []MetadataKey{KeyName, KeyFormat}) {
+		t.Error("Required keys for raster tilesets do not match expected value, got:", keys)
+	}
+	if keys := RequiredMetadataKeys(PBF); !reflect.DeepEqual(keys, []MetadataKey{KeyName, KeyFormat, KeyJSON}) {
+		t.Error("Required keys for vector tilesets do not match expected value, got:", keys)
+	}
+	if !KeyBounds.IsStandard() || MetadataKey("generator").IsStandard() {
+		t.Error("Expected only keys of the specification to be standard")
+	}
+}
+
+func Test_ValidateMetadataItems(t *testing.T) {
+	valid := map[string]string{
+		"name":    "test",
+		"format":  "png",
+		"bounds":  "-180,-85,180,85",
+		"center":  "0,0,2",
+		"minzoom": "0",
+		"maxzoom": "4",
+		"type":    "baselayer",
+		"version": "1.0.0",
+	}
+	if problems := ValidateMetadataItems(valid, PNG); len(problems) != 0 {
+		t.Error("Expected no problems, got:", problems)
+	}
+
+	invalid := map[string]string{
+		"format":  "jpeg",
+		"bounds":  "-200,0,10,10",
+		"minzoom": "5",
+		"maxzoom": "4",
+		"type":    "base",
+		"json":    `{"vector_layers": []}`,
+	}
+	var keys []MetadataKey
+	for _, problem := range ValidateMetadataItems(invalid, PBF) {
+		keys = append(keys, problem.Key)
+	}
+	expected := []MetadataKey{KeyBounds, KeyFormat, KeyJSON, KeyMinZoom, KeyName, KeyType}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Error("Invalid keys do not match expected value, got:", keys)
+	}
+
+	if problems := ValidateMetadataItems(map[string]string{"name": "test", "format": "png"}, JPG); len(problems) != 1 || problems[0].Key != KeyFormat {
+		t.Error("Expected format not matching tiles, got:", problems)
+	}
+}
@@ -0,0 +1,83 @@
+package mbtiles
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MissingTilePolicy is how tile handlers respond to requests for tiles that do
+// not exist.  Clients differ in how they handle missing tiles: MapLibre and
+// Leaflet accept 404 Not Found, while some mobile SDKs log errors or retry
+// unless they receive an empty or blank tile.
+type MissingTilePolicy uint8
+
+// MissingTilePolicy values
+const (
+	MissingNotFound  MissingTilePolicy = iota // respond with 404 Not Found
+	MissingNoContent                          // respond with 204 No Content
+	MissingBlankTile                          // respond with 200 OK and a blank tile
+)
+
+// defaultBlankTileSize is the size in pixels of blank raster tiles when the
+// tile size of the tileset is not detected.
+const defaultBlankTileSize = 256
+
+// MissingTileResponse returns the HTTP status and body of the response to a
+// request for a tile that does not exist in a tileset of the given format and
+// tile size, according to policy.  The body is empty except for
+// MissingBlankTile.
+//
+// Blank PNG tiles are transparent and blank JPG tiles are white.  Blank PBF
+// tiles are vector tiles without layers, gzip-compressed like the tiles of PBF
+// tilesets, so their responses set Content-Encoding as for other tiles.
+// Blank WEBP tiles cannot be encoded, so tilesets of other formats respond
+// with 204 No Content instead.  If tileSize is 0, blank raster tiles are 256
+// pixels.
+func MissingTileResponse(policy MissingTilePolicy, format TileFormat, tileSize uint32) (int, []byte, error) {
+	switch policy {
+	case MissingNoContent:
+		return http.StatusNoContent, nil, nil
+	case MissingBlankTile:
+	default:
+		return http.StatusNotFound, nil, nil
+	}
+
+	if tileSize == 0 {
+		tileSize = defaultBlankTileSize
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, int(tileSize), int(tileSize)))
+
+	var buf bytes.Buffer
+	switch format {
+	case PBF:
+		data, err := gzipBytes(nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, data, nil
+	case PNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return 0, nil, err
+		}
+	case JPG:
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return 0, nil, err
+		}
+	default:
+		return http.StatusNoContent, nil, nil
+	}
+	return http.StatusOK, buf.Bytes(), nil
+}
+
+// MissingTileResponse returns the HTTP status and body of the response to a
+// request for a tile that does not exist in this tileset, as described for
+// MissingTileResponse.
+func (db *MBtiles) MissingTileResponse(policy MissingTilePolicy) (int, []byte, error) {
+	return MissingTileResponse(policy, db.GetTileFormat(), db.GetTileSize())
+}
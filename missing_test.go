@@ -0,0 +1,56 @@
+package mbtiles
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+)
+
+func Test_MissingTileResponse(t *testing.T) {
+	tests := []struct {
+		policy MissingTilePolicy
+		format TileFormat
+		status int
+		body   bool
+	}{
+		{MissingNotFound, PNG, http.StatusNotFound, false},
+		{MissingNoContent, PNG, http.StatusNoContent, false},
+		{MissingBlankTile, PNG, http.StatusOK, true},
+		{MissingBlankTile, JPG, http.StatusOK, true},
+		{MissingBlankTile, PBF, http.StatusOK, true},
+		{MissingBlankTile, WEBP, http.StatusNoContent, false},
+	}
+	for _, tc := range tests {
+		status, body, err := MissingTileResponse(tc.policy, tc.format, 512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != tc.status || (len(body) > 0) != tc.body {
+			t.Error("Unexpected response for", tc.policy, tc.format, "got:", status, len(body))
+		}
+	}
+
+	_, body, err := MissingTileResponse(MissingBlankTile, PNG, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 512 {
+		t.Error("Blank tile does not match tile size, got:", img.Bounds())
+	}
+	if _, _, _, a := img.At(10, 10).RGBA(); a != 0 {
+		t.Error("Blank PNG tile is not transparent")
+	}
+
+	_, body, err = MissingTileResponse(MissingBlankTile, PBF, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := gunzip(body); err != nil || len(data) != 0 {
+		t.Error("Blank PBF tile is not an empty gzipped tile:", data, err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package mbtiles
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of f, on platforms where files are not
+// mapped into memory.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unmapFile releases data returned by mapFile.
+func unmapFile(data []byte) {}
@@ -0,0 +1,227 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Mosaic creates a new mbtiles file at dst of PNG tiles that merge the
+// overlapping raster tilesets sources, such as several surveys of the same
+// area, into one tileset, and returns the number of tiles written.  dst must
+// not already exist.  Tiles are merged using up to concurrency goroutines;
+// concurrency less than 1 is treated as 1.
+//
+// sources are in order of priority, such as most recent first: each pixel is
+// taken from the first source that has an opaque pixel there, and partially
+// transparent pixels are blended over those of later sources as described
+// for AlphaBlend.  If feather is greater than 0, the alpha of each source is
+// reduced over feather pixels from its transparent areas, such as the edges of
+// a survey, so that sources blend without visible seams.  Distances to
+// transparent areas are measured within each tile.
+//
+// The tilesets must be aligned, with tiles of the same size.  Metadata is
+// copied from the first source, with format set to png, bounds, minzoom, and
+// maxzoom set to cover all sources, and the distinct attributions of the
+// sources joined by " | ".  Generator metadata (see GetMetadata) is also
+// copied from later sources if the first source does not have it.  Only PNG
+// and JPG tilesets are supported.  dst is removed if the operation fails.
+func Mosaic(ctx context.Context, dst string, feather int, concurrency int, sources ...*MBtiles) (int64, error) {
+	if len(sources) == 0 {
+		return 0, errors.New("mosaic requires at least one source")
+	}
+	for _, db := range sources {
+		if db.isClosed() {
+			return 0, errors.New("cannot create mosaic from closed mbtiles database")
+		}
+		if format := db.GetTileFormat(); format != PNG && format != JPG {
+			return 0, fmt.Errorf("mosaic is only supported for PNG and JPG tilesets, not %v", format)
+		}
+	}
+
+	metadata, err := unionMetadata(sources)
+	if err != nil {
+		return 0, err
+	}
+	filenames := make([]string, len(sources))
+	for i, db := range sources {
+		filenames[i] = db.GetFilename()
+	}
+	parameters := map[string]interface{}{"sources": filenames, "feather": feather}
+	return compositeTiles(ctx, dst, sources, metadata, "mosaic", parameters, concurrency, func(coord TileCoord) ([]byte, error) {
+		return mosaicTile(ctx, sources, coord, feather)
+	})
+}
+
+// attributionSeparator separates the attributions of sources merged by
+// unionMetadata.
+const attributionSeparator = " | "
+
+// unionMetadata returns the metadata items of a PNG tileset that combines
+// sources that differ from those of the first source: bounds, minzoom, and
+// maxzoom cover all sources, and attribution lists the distinct attributions
+// of the sources in order.
+func unionMetadata(sources []*MBtiles) (map[string]string, error) {
+	metadata := map[string]string{"format": "png"}
+	var (
+		first            map[string]interface{}
+		bounds           []float64
+		minZoom, maxZoom = math.MaxInt32, -1
+		attributions     []string
+		seen             = make(map[string]bool)
+	)
+	for i, db := range sources {
+		m, err := db.ReadMetadata()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			first = m
+		}
+		// keep generator metadata of later sources that the first does not have
+		for key, value := range m {
+			text, ok := value.(string)
+			if _, exists := first[key]; ok && !exists && isGeneratorKey(key) {
+				if _, exists := metadata[key]; !exists {
+					metadata[key] = text
+				}
+			}
+		}
+		if attribution, ok := m["attribution"].(string); ok {
+			for _, part := range strings.Split(attribution, attributionSeparator) {
+				if part = strings.TrimSpace(part); part != "" && !seen[part] {
+					seen[part] = true
+					attributions = append(attributions, part)
+				}
+			}
+		}
+
+		b, ok := m["bounds"].([]float64)
+		if ok && validateBounds(b) == nil && (i == 0 || bounds != nil) {
+			if bounds == nil {
+				bounds = append([]float64(nil), b...)
+			}
+			bounds[0], bounds[1] = math.Min(bounds[0], b[0]), math.Min(bounds[1], b[1])
+			bounds[2], bounds[3] = math.Max(bounds[2], b[2]), math.Max(bounds[3], b[3])
+		} else {
+			// the union is unknown unless all sources have bounds
+			bounds = nil
+		}
+
+		z, err := db.GetMinZoom()
+		if err != nil {
+			return nil, err
+		}
+		if z < minZoom {
+			minZoom = z
+		}
+		if z, err = db.GetMaxZoom(); err != nil {
+			return nil, err
+		}
+		if z > maxZoom {
+			maxZoom = z
+		}
+	}
+
+	if bounds != nil {
+		metadata["bounds"] = fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+	if len(attributions) > 0 {
+		metadata["attribution"] = strings.Join(attributions, attributionSeparator)
+	}
+	metadata["minzoom"] = strconv.Itoa(minZoom)
+	metadata["maxzoom"] = strconv.Itoa(maxZoom)
+	return metadata, nil
+}
+
+// mosaicTile merges the tiles of sources at coord (TMS scheme) as described
+// for Mosaic, and returns the encoded PNG.
+func mosaicTile(ctx context.Context, sources []*MBtiles, coord TileCoord, feather int) ([]byte, error) {
+	var dst *image.NRGBA
+	for i := len(sources) - 1; i >= 0; i-- {
+		src, err := readTileNRGBA(ctx, sources[i], coord)
+		if err != nil {
+			return nil, err
+		}
+		if src == nil {
+			continue
+		}
+		if dst == nil {
+			dst = image.NewNRGBA(src.Rect)
+		}
+		if src.Rect != dst.Rect {
+			return nil, &TileError{Coord: coord, Err: fmt.Errorf("tile sizes %v and %v do not match", src.Rect.Size(), dst.Rect.Size())}
+		}
+		if feather > 0 {
+			featherAlpha(src, feather)
+		}
+
+		for p := 0; p < len(dst.Pix); p += 4 {
+			c := AlphaBlend(
+				color.NRGBA{R: dst.Pix[p], G: dst.Pix[p+1], B: dst.Pix[p+2], A: dst.Pix[p+3]},
+				color.NRGBA{R: src.Pix[p], G: src.Pix[p+1], B: src.Pix[p+2], A: src.Pix[p+3]},
+			)
+			dst.Pix[p], dst.Pix[p+1], dst.Pix[p+2], dst.Pix[p+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return encodePNG(dst)
+}
+
+// featherAlpha scales the alpha of each pixel of img by its distance in pixels
+// to the nearest transparent pixel divided by feather, up to 1.  Distances are
+// the chessboard distance, computed using two passes over the pixels.
+func featherAlpha(img *image.NRGBA, feather int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	// distances are limited to feather, beyond which alpha is unchanged
+	distances := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if img.Pix[y*img.Stride+x*4+3] != 0 {
+				distances[y*width+x] = feather
+			}
+		}
+	}
+
+	at := func(x int, y int) int {
+		if x < 0 || y < 0 || x >= width || y >= height {
+			return feather
+		}
+		return distances[y*width+x]
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			d := distances[y*width+x]
+			for _, n := range []int{at(x-1, y), at(x-1, y-1), at(x, y-1), at(x+1, y-1)} {
+				if n+1 < d {
+					d = n + 1
+				}
+			}
+			distances[y*width+x] = d
+		}
+	}
+	for y := height - 1; y >= 0; y-- {
+		for x := width - 1; x >= 0; x-- {
+			d := distances[y*width+x]
+			for _, n := range []int{at(x+1, y), at(x+1, y+1), at(x, y+1), at(x-1, y+1)} {
+				if n+1 < d {
+					d = n + 1
+				}
+			}
+			distances[y*width+x] = d
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if d := distances[y*width+x]; d < feather {
+				i := y*img.Stride + x*4 + 3
+				img.Pix[i] = uint8(int(img.Pix[i]) * d / feather)
+			}
+		}
+	}
+}
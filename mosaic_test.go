@@ -0,0 +1,93 @@
+package mbtiles
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Mosaic(t *testing.T) {
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+
+	// the first source covers the left half of 1/0/0
+	half := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(half, image.Rect(0, 0, 128, 256), image.NewUniform(red), image.Point{}, draw.Src)
+	first, err := Open(createRasterTileset(t,
+		map[string]string{"bounds": "-180,0,-90,85", "attribution": "© Survey A | © Survey B"},
+		map[TileCoord]image.Image{{Z: 1, X: 0, Y: 0}: half},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := Open(createRasterTileset(t,
+		map[string]string{"bounds": "-180,-85,180,0", "attribution": "© Survey B | © Survey C"},
+		map[TileCoord]image.Image{
+			{Z: 1, X: 0, Y: 0}: uniformImage(blue),
+			{Z: 2, X: 3, Y: 3}: uniformImage(blue),
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	tests := []struct {
+		feather int
+		// expected colors at pixels of 1/0/0
+		pixels map[int]color.NRGBA
+	}{
+		{feather: 0, pixels: map[int]color.NRGBA{50: red, 127: red, 128: blue}},
+		// 127 is 1 pixel from the transparent half: 1/8 red
+		{feather: 8, pixels: map[int]color.NRGBA{50: red, 127: {R: 31, B: 224, A: 255}, 128: blue}},
+	}
+
+	for _, tc := range tests {
+		dst := filepath.Join(t.TempDir(), "mosaic.mbtiles")
+		count, err := Mosaic(context.Background(), dst, tc.feather, 2, first, second)
+		if err != nil {
+			t.Fatal("Could not create mosaic:", err)
+		}
+		if count != 2 {
+			t.Error("Mosaic did not write expected number of tiles:", count)
+		}
+
+		out, err := Open(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metadata, err := out.ReadMetadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds, _ := metadata["bounds"].([]float64)
+		if len(bounds) != 4 || bounds[0] != -180 || bounds[1] != -85 || bounds[2] != 180 || bounds[3] != 85 {
+			t.Error("Mosaic bounds do not cover all sources:", bounds)
+		}
+		if metadata["minzoom"] != 1 || metadata["maxzoom"] != 2 {
+			t.Error("Mosaic zoom range does not cover all sources:", metadata["minzoom"], metadata["maxzoom"])
+		}
+		if metadata["attribution"] != "© Survey A | © Survey B | © Survey C" {
+			t.Error("Mosaic attribution does not merge sources:", metadata["attribution"])
+		}
+
+		img, err := readTileNRGBA(context.Background(), out, TileCoord{Z: 1, X: 0, Y: 0})
+		out.Close()
+		if err != nil || img == nil {
+			t.Fatal("Could not read mosaic tile:", err)
+		}
+		for x, expected := range tc.pixels {
+			if c := img.NRGBAAt(x, 100); c != expected {
+				t.Error("Mosaic pixel", x, c, "does not match expected value", expected, "with feather", tc.feather)
+			}
+		}
+	}
+
+	if _, err := Mosaic(context.Background(), filepath.Join(t.TempDir(), "empty.mbtiles"), 0, 1); err == nil {
+		t.Error("Expected error for mosaic without sources")
+	}
+}
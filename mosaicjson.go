@@ -0,0 +1,231 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// mosaicJSONVersion is the version of the MosaicJSON specification of
+// documents created by NewMosaicJSON.
+const mosaicJSONVersion = "0.0.3"
+
+// MosaicJSON is a MosaicJSON document, which describes a set of adjacent
+// tilesets, such as imagery delivered as one mbtiles file per scene, as one
+// logical tileset.  Tiles maps the quadkeys of tiles at QuadkeyZoom to the
+// paths of the tilesets that intersect them, in order of priority.
+type MosaicJSON struct {
+	MosaicJSON  string              `json:"mosaicjson"`
+	Name        string              `json:"name,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Version     string              `json:"version,omitempty"`
+	Attribution string              `json:"attribution,omitempty"`
+	MinZoom     int                 `json:"minzoom"`
+	MaxZoom     int                 `json:"maxzoom"`
+	QuadkeyZoom int                 `json:"quadkey_zoom"`
+	Bounds      []float64           `json:"bounds"`
+	Center      []float64           `json:"center,omitempty"`
+	AssetPrefix string              `json:"asset_prefix,omitempty"`
+	Tiles       map[string][]string `json:"tiles"`
+}
+
+// NewMosaicJSON creates a MosaicJSON document describing tilesets, such as
+// found by DiscoverTilesets, indexed by the quadkeys of tiles at quadkeyZoom.
+// The tilesets must have bounds and the same tile format; the bounds and zoom
+// levels of the document cover all tilesets.  Tilesets are listed for each
+// quadkey in the order of tilesets.  Higher quadkeyZoom values make reads
+// more precise for small tilesets, at the cost of a larger document; it is
+// usually the minimum zoom level of the tilesets.
+func NewMosaicJSON(tilesets []TilesetInfo, quadkeyZoom int) (*MosaicJSON, error) {
+	if len(tilesets) == 0 {
+		return nil, errors.New("mosaic requires at least one tileset")
+	}
+	if quadkeyZoom < 0 || quadkeyZoom > MaxZoomLevel {
+		return nil, fmt.Errorf("invalid quadkey zoom %d", quadkeyZoom)
+	}
+
+	mosaic := &MosaicJSON{
+		MosaicJSON:  mosaicJSONVersion,
+		Version:     "1.0.0",
+		MinZoom:     math.MaxInt32,
+		MaxZoom:     -1,
+		QuadkeyZoom: quadkeyZoom,
+		Tiles:       make(map[string][]string),
+	}
+	for _, tileset := range tilesets {
+		if tileset.Err != nil {
+			return nil, fmt.Errorf("cannot add %q to mosaic: %w", tileset.Path, tileset.Err)
+		}
+		if tileset.Format != tilesets[0].Format {
+			return nil, fmt.Errorf("tile format %v of %q does not match %v", tileset.Format, tileset.Path, tilesets[0].Format)
+		}
+		if err := validateBounds(tileset.Bounds); err != nil {
+			return nil, fmt.Errorf("invalid bounds of %q: %w", tileset.Path, err)
+		}
+
+		b := tileset.Bounds
+		if mosaic.Bounds == nil {
+			mosaic.Bounds = append([]float64(nil), b...)
+		}
+		mosaic.Bounds[0], mosaic.Bounds[1] = math.Min(mosaic.Bounds[0], b[0]), math.Min(mosaic.Bounds[1], b[1])
+		mosaic.Bounds[2], mosaic.Bounds[3] = math.Max(mosaic.Bounds[2], b[2]), math.Max(mosaic.Bounds[3], b[3])
+		if tileset.MinZoom < mosaic.MinZoom {
+			mosaic.MinZoom = tileset.MinZoom
+		}
+		if tileset.MaxZoom > mosaic.MaxZoom {
+			mosaic.MaxZoom = tileset.MaxZoom
+		}
+
+		topLeft, bottomRight, err := tileRange(b, int64(quadkeyZoom))
+		if err != nil {
+			return nil, err
+		}
+		for x := topLeft.X; x <= bottomRight.X; x++ {
+			for y := topLeft.Y; y <= bottomRight.Y; y++ {
+				quadkey := TileCoord{Z: int64(quadkeyZoom), X: x, Y: y}.Quadkey()
+				mosaic.Tiles[quadkey] = append(mosaic.Tiles[quadkey], tileset.Path)
+			}
+		}
+	}
+	mosaic.Center = []float64{
+		(mosaic.Bounds[0] + mosaic.Bounds[2]) / 2,
+		(mosaic.Bounds[1] + mosaic.Bounds[3]) / 2,
+		float64(mosaic.MinZoom),
+	}
+	return mosaic, nil
+}
+
+// ReadMosaicJSON decodes a MosaicJSON document from r.
+func ReadMosaicJSON(r io.Reader) (*MosaicJSON, error) {
+	var mosaic MosaicJSON
+	if err := json.NewDecoder(r).Decode(&mosaic); err != nil {
+		return nil, fmt.Errorf("cannot parse MosaicJSON: %w", err)
+	}
+	if mosaic.QuadkeyZoom < 0 || mosaic.QuadkeyZoom > MaxZoomLevel {
+		return nil, fmt.Errorf("invalid quadkey zoom %d", mosaic.QuadkeyZoom)
+	}
+	return &mosaic, nil
+}
+
+// MosaicTiles serves the tiles of the tilesets of a MosaicJSON document as
+// one tileset.
+type MosaicTiles struct {
+	mosaic   *MosaicJSON
+	quadkeys []string // sorted quadkeys of mosaic.Tiles
+	tilesets map[string]*MBtiles
+	format   TileFormat
+}
+
+// OpenMosaic opens the tilesets of mosaic using the provided options, to read
+// their tiles.  Paths of tilesets are prefixed with the asset_prefix of the
+// document, if any.
+func OpenMosaic(mosaic *MosaicJSON, opts ...OpenOption) (*MosaicTiles, error) {
+	m := &MosaicTiles{mosaic: mosaic, tilesets: make(map[string]*MBtiles)}
+	for quadkey, paths := range mosaic.Tiles {
+		m.quadkeys = append(m.quadkeys, quadkey)
+		for _, path := range paths {
+			if _, ok := m.tilesets[path]; ok {
+				continue
+			}
+			db, err := Open(mosaic.AssetPrefix+path, opts...)
+			if err != nil {
+				m.Close()
+				return nil, err
+			}
+			m.tilesets[path] = db
+			if err := db.init(context.TODO()); err != nil {
+				m.Close()
+				return nil, err
+			}
+			if m.format == UNKNOWN {
+				m.format = db.GetTileFormat()
+			} else if db.GetTileFormat() != m.format {
+				m.Close()
+				return nil, fmt.Errorf("tile format %v of %q does not match %v", db.GetTileFormat(), path, m.format)
+			}
+		}
+	}
+	sort.Strings(m.quadkeys)
+	return m, nil
+}
+
+// GetTile reads the tile for z, x, y (TMS scheme) from the tilesets of the
+// mosaic that intersect it, in order of priority.  If several PNG tilesets
+// have the tile, such as at zoom levels below the quadkey zoom where tiles
+// span several scenes, their tiles are merged as described for Mosaic;
+// otherwise the tile of the first tileset that has it is returned.
+// ErrTileNotFound is returned if no tileset has the tile.
+func (m *MosaicTiles) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	if z < int64(m.mosaic.MinZoom) || z > int64(m.mosaic.MaxZoom) {
+		return nil, ErrTileNotFound
+	}
+
+	coord := TileCoord{Z: z, X: x, Y: y}
+	var (
+		found []*MBtiles
+		first []byte
+	)
+	for _, path := range m.assets(coord.FlipY()) {
+		data, err := m.tilesets[path].GetTile(ctx, z, x, y)
+		if err == ErrTileNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = data
+		}
+		found = append(found, m.tilesets[path])
+		if m.format != PNG {
+			break
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return nil, ErrTileNotFound
+	case len(found) == 1:
+		return first, nil
+	}
+	return mosaicTile(ctx, found, coord, 0)
+}
+
+// assets returns the paths of the tilesets that intersect coord (XYZ scheme),
+// without duplicates.  Below the quadkey zoom, the tilesets of the quadkeys
+// within coord are listed in the order of the quadkeys.
+func (m *MosaicTiles) assets(coord TileCoord) []string {
+	quadkey := coord.Quadkey()
+	if len(quadkey) >= m.mosaic.QuadkeyZoom {
+		return m.mosaic.Tiles[quadkey[:m.mosaic.QuadkeyZoom]]
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	i := sort.SearchStrings(m.quadkeys, quadkey)
+	for ; i < len(m.quadkeys) && strings.HasPrefix(m.quadkeys[i], quadkey); i++ {
+		for _, path := range m.mosaic.Tiles[m.quadkeys[i]] {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// GetTileFormat returns the tile format of the tilesets of the mosaic.
+func (m *MosaicTiles) GetTileFormat() TileFormat {
+	return m.format
+}
+
+// Close closes the tilesets of the mosaic.
+func (m *MosaicTiles) Close() {
+	for _, db := range m.tilesets {
+		db.Close()
+	}
+}
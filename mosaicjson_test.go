@@ -0,0 +1,90 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func Test_MosaicJSON(t *testing.T) {
+	ctx := context.Background()
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	// halfImage returns a 256px image with c in the left or right half
+	halfImage := func(c color.NRGBA, left bool) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+		half := image.Rect(128, 0, 256, 256)
+		if left {
+			half = image.Rect(0, 0, 128, 256)
+		}
+		draw.Draw(img, half, image.NewUniform(c), image.Point{}, draw.Src)
+		return img
+	}
+
+	west := createRasterTileset(t, nil, map[TileCoord]image.Image{
+		{Z: 0, X: 0, Y: 0}: halfImage(red, true),
+		{Z: 1, X: 0, Y: 0}: uniformImage(red),
+		{Z: 1, X: 0, Y: 1}: uniformImage(red),
+	})
+	east := createRasterTileset(t, nil, map[TileCoord]image.Image{
+		{Z: 0, X: 0, Y: 0}: halfImage(blue, false),
+		{Z: 1, X: 1, Y: 0}: uniformImage(blue),
+		{Z: 1, X: 1, Y: 1}: uniformImage(blue),
+	})
+	mosaic, err := NewMosaicJSON([]TilesetInfo{
+		{Path: west, Format: PNG, Bounds: []float64{-180, -85, -1, 85}, MinZoom: 0, MaxZoom: 1},
+		{Path: east, Format: PNG, Bounds: []float64{1, -85, 180, 85}, MinZoom: 0, MaxZoom: 1},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mosaic.Tiles) != 4 || len(mosaic.Tiles["0"]) != 1 || mosaic.Tiles["1"][0] != east {
+		t.Error("Mosaic tiles do not match expected value, got:", mosaic.Tiles)
+	}
+
+	// documents can be encoded and read back
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(mosaic); err != nil {
+		t.Fatal(err)
+	}
+	if mosaic, err = ReadMosaicJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tiles, err := OpenMosaic(mosaic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tiles.Close()
+
+	expected, err := encodePNG(uniformImage(blue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := tiles.GetTile(ctx, 1, 1, 1); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Tile does not match tile of east tileset:", err)
+	}
+
+	// the tile at zoom 0 merges both tilesets
+	data, err := tiles.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(10, 10).RGBA(); r>>8 != 255 {
+		t.Error("Expected red pixel on the left, got:", img.At(10, 10))
+	}
+	if _, _, b, _ := img.At(250, 10).RGBA(); b>>8 != 255 {
+		t.Error("Expected blue pixel on the right, got:", img.At(250, 10))
+	}
+
+	if _, err := tiles.GetTile(ctx, 2, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound beyond max zoom, got:", err)
+	}
+}
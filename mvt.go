@@ -0,0 +1,482 @@
+package mbtiles
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Geometry types of vector tile features, as defined by the Mapbox Vector Tile
+// specification.
+const (
+	mvtUnknown    = 0
+	mvtPoint      = 1
+	mvtLineString = 2
+	mvtPolygon    = 3
+)
+
+// Geometry commands of vector tile features.
+const (
+	mvtMoveTo    = 1
+	mvtLineTo    = 2
+	mvtClosePath = 7
+)
+
+// mvtLayer is a decoded layer of a Mapbox Vector Tile.
+type mvtLayer struct {
+	version  uint32
+	name     string
+	extent   uint32
+	keys     []string
+	values   []interface{}
+	features []mvtFeature
+}
+
+// mvtFeature is a decoded feature of a vector tile layer.  tags and geometry
+// are encoded as described by the specification.
+type mvtFeature struct {
+	id       uint64
+	hasID    bool
+	tags     []uint32
+	geomType uint32
+	geometry []uint32
+}
+
+// errTruncated is returned when decoding protocol buffers that end early.
+var errTruncated = errors.New("truncated protocol buffer")
+
+// pbReader reads fields of a protocol buffer message.
+type pbReader struct {
+	data []byte
+}
+
+// next reads the next field, returning its number and wire type, and its value:
+// the varint or fixed value for scalar types, or the bytes for length-delimited
+// fields.
+func (r *pbReader) next() (field uint64, wireType uint64, value uint64, data []byte, err error) {
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	field, wireType = key>>3, key&7
+	switch wireType {
+	case 0:
+		value, err = r.varint()
+	case 1:
+		if len(r.data) < 8 {
+			return 0, 0, 0, nil, errTruncated
+		}
+		value, r.data = binary.LittleEndian.Uint64(r.data), r.data[8:]
+	case 2:
+		var length uint64
+		length, err = r.varint()
+		if err == nil {
+			if length > uint64(len(r.data)) {
+				return 0, 0, 0, nil, errTruncated
+			}
+			data, r.data = r.data[:length], r.data[length:]
+		}
+	case 5:
+		if len(r.data) < 4 {
+			return 0, 0, 0, nil, errTruncated
+		}
+		value, r.data = uint64(binary.LittleEndian.Uint32(r.data)), r.data[4:]
+	default:
+		return 0, 0, 0, nil, fmt.Errorf("unsupported protocol buffer wire type %d", wireType)
+	}
+	return field, wireType, value, data, err
+}
+
+func (r *pbReader) varint() (uint64, error) {
+	value, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.data = r.data[n:]
+	return value, nil
+}
+
+// packedUint32s decodes a packed repeated uint32 field.
+func packedUint32s(data []byte) ([]uint32, error) {
+	r := pbReader{data: data}
+	var values []uint32
+	for len(r.data) > 0 {
+		value, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, uint32(value))
+	}
+	return values, nil
+}
+
+// decodeMVT decodes the layers of an uncompressed Mapbox Vector Tile.
+func decodeMVT(data []byte) ([]mvtLayer, error) {
+	var layers []mvtLayer
+	r := pbReader{data: data}
+	for len(r.data) > 0 {
+		field, wireType, _, value, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if field != 3 || wireType != 2 {
+			continue // unknown fields are ignored
+		}
+		layer, err := decodeMVTLayer(value)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+func decodeMVTLayer(data []byte) (mvtLayer, error) {
+	layer := mvtLayer{version: 1, extent: 4096}
+	r := pbReader{data: data}
+	for len(r.data) > 0 {
+		field, _, value, bytes, err := r.next()
+		if err != nil {
+			return layer, err
+		}
+		switch field {
+		case 15:
+			layer.version = uint32(value)
+		case 1:
+			layer.name = string(bytes)
+		case 2:
+			feature, err := decodeMVTFeature(bytes)
+			if err != nil {
+				return layer, err
+			}
+			layer.features = append(layer.features, feature)
+		case 3:
+			layer.keys = append(layer.keys, string(bytes))
+		case 4:
+			v, err := decodeMVTValue(bytes)
+			if err != nil {
+				return layer, err
+			}
+			layer.values = append(layer.values, v)
+		case 5:
+			layer.extent = uint32(value)
+		}
+	}
+	return layer, nil
+}
+
+func decodeMVTFeature(data []byte) (mvtFeature, error) {
+	var feature mvtFeature
+	r := pbReader{data: data}
+	for len(r.data) > 0 {
+		field, _, value, bytes, err := r.next()
+		if err != nil {
+			return feature, err
+		}
+		switch field {
+		case 1:
+			feature.id, feature.hasID = value, true
+		case 2:
+			if feature.tags, err = packedUint32s(bytes); err != nil {
+				return feature, err
+			}
+		case 3:
+			feature.geomType = uint32(value)
+		case 4:
+			if feature.geometry, err = packedUint32s(bytes); err != nil {
+				return feature, err
+			}
+		}
+	}
+	return feature, nil
+}
+
+func decodeMVTValue(data []byte) (interface{}, error) {
+	var v interface{}
+	r := pbReader{data: data}
+	for len(r.data) > 0 {
+		field, _, value, bytes, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case 1:
+			v = string(bytes)
+		case 2:
+			v = float64(math.Float32frombits(uint32(value)))
+		case 3:
+			v = math.Float64frombits(value)
+		case 4:
+			v = int64(value)
+		case 5:
+			v = value
+		case 6:
+			v = int64(value>>1) ^ -int64(value&1)
+		case 7:
+			v = value != 0
+		}
+	}
+	return v, nil
+}
+
+// validateGeometry checks that the geometry commands of feature are valid for
+// its geometry type.
+func validateGeometry(feature mvtFeature) error {
+	var (
+		moveTos, points int
+		ringPoints      int
+	)
+	geometry := feature.geometry
+	for i := 0; i < len(geometry); {
+		command, count := geometry[i]&7, int(geometry[i]>>3)
+		i++
+		switch command {
+		case mvtMoveTo, mvtLineTo:
+			if i+2*count > len(geometry) {
+				return errors.New("geometry command has too few parameters")
+			}
+			i += 2 * count
+			if command == mvtMoveTo {
+				if feature.geomType != mvtPoint && count != 1 {
+					return errors.New("MoveTo command must have a count of 1 for lines and polygons")
+				}
+				if feature.geomType == mvtLineString && moveTos > 0 && ringPoints < 2 {
+					return errors.New("line has fewer than 2 points")
+				}
+				moveTos++
+				ringPoints = count
+			} else {
+				if moveTos == 0 {
+					return errors.New("LineTo command before MoveTo")
+				}
+				if feature.geomType == mvtPoint {
+					return errors.New("point geometry has LineTo command")
+				}
+				ringPoints += count
+			}
+			points += count
+		case mvtClosePath:
+			if feature.geomType != mvtPolygon {
+				return errors.New("ClosePath command in non-polygon geometry")
+			}
+			if count != 1 {
+				return errors.New("ClosePath command must have a count of 1")
+			}
+			if ringPoints < 3 {
+				return errors.New("polygon ring has fewer than 3 points")
+			}
+			ringPoints = 0
+		default:
+			return fmt.Errorf("unknown geometry command %d", command)
+		}
+	}
+
+	switch feature.geomType {
+	case mvtPoint:
+		if points == 0 {
+			return errors.New("point geometry has no points")
+		}
+	case mvtLineString:
+		if moveTos == 0 || ringPoints < 2 {
+			return errors.New("line has fewer than 2 points")
+		}
+	case mvtPolygon:
+		if moveTos == 0 || ringPoints != 0 {
+			return errors.New("polygon ring is not closed")
+		}
+	default:
+		return fmt.Errorf("unknown geometry type %d", feature.geomType)
+	}
+	return nil
+}
+
+// encodeMVT encodes layers as an uncompressed Mapbox Vector Tile.  Values of
+// layers must be string, float64, int64, uint64, or bool.
+func encodeMVT(layers []mvtLayer) []byte {
+	var data []byte
+	for _, layer := range layers {
+		data = appendBytesField(data, 3, encodeMVTLayer(layer))
+	}
+	return data
+}
+
+func encodeMVTLayer(layer mvtLayer) []byte {
+	data := appendVarintField(nil, 15, uint64(layer.version))
+	data = appendBytesField(data, 1, []byte(layer.name))
+	for _, feature := range layer.features {
+		data = appendBytesField(data, 2, encodeMVTFeature(feature))
+	}
+	for _, key := range layer.keys {
+		data = appendBytesField(data, 3, []byte(key))
+	}
+	for _, value := range layer.values {
+		data = appendBytesField(data, 4, encodeMVTValue(value))
+	}
+	return appendVarintField(data, 5, uint64(layer.extent))
+}
+
+func encodeMVTFeature(feature mvtFeature) []byte {
+	var data []byte
+	if feature.hasID {
+		data = appendVarintField(data, 1, feature.id)
+	}
+	if len(feature.tags) > 0 {
+		data = appendBytesField(data, 2, packUint32s(feature.tags))
+	}
+	data = appendVarintField(data, 3, uint64(feature.geomType))
+	return appendBytesField(data, 4, packUint32s(feature.geometry))
+}
+
+func encodeMVTValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return appendBytesField(nil, 1, []byte(v))
+	case float64:
+		data := appendUvarint(nil, 3<<3|1)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		return append(data, buf[:]...)
+	case int64:
+		return appendVarintField(nil, 6, uint64(v<<1)^uint64(v>>63))
+	case uint64:
+		return appendVarintField(nil, 5, v)
+	case bool:
+		if v {
+			return appendVarintField(nil, 7, 1)
+		}
+		return appendVarintField(nil, 7, 0)
+	default:
+		panic(fmt.Sprintf("unsupported vector tile value type %T", value))
+	}
+}
+
+// encodePointGeometry encodes points in tile coordinates as the geometry
+// commands of a Point feature.
+func encodePointGeometry(points [][2]int32) []uint32 {
+	geometry := []uint32{mvtMoveTo | uint32(len(points))<<3}
+	var x, y int32
+	for _, point := range points {
+		geometry = append(geometry, zigzag32(point[0]-x), zigzag32(point[1]-y))
+		x, y = point[0], point[1]
+	}
+	return geometry
+}
+
+// encodeLineGeometry encodes lines, each a list of [x, y] points in tile
+// coordinates with at least 2 points, as the geometry commands of a
+// LineString feature.
+func encodeLineGeometry(lines [][][2]int32) []uint32 {
+	var (
+		geometry []uint32
+		x, y     int32
+	)
+	for _, line := range lines {
+		geometry = append(geometry, mvtMoveTo|1<<3, zigzag32(line[0][0]-x), zigzag32(line[0][1]-y))
+		x, y = line[0][0], line[0][1]
+		geometry = append(geometry, mvtLineTo|uint32(len(line)-1)<<3)
+		for _, point := range line[1:] {
+			geometry = append(geometry, zigzag32(point[0]-x), zigzag32(point[1]-y))
+			x, y = point[0], point[1]
+		}
+	}
+	return geometry
+}
+
+// encodePolygonGeometry encodes rings, each a list of at least 3 [x, y] points
+// in tile coordinates without repeating the first point, as the geometry
+// commands of a Polygon feature.  Exterior rings must be followed by their
+// interior rings, and have the winding order required by the specification.
+func encodePolygonGeometry(rings [][][2]int32) []uint32 {
+	var (
+		geometry []uint32
+		x, y     int32
+	)
+	for _, ring := range rings {
+		geometry = append(geometry, mvtMoveTo|1<<3, zigzag32(ring[0][0]-x), zigzag32(ring[0][1]-y))
+		x, y = ring[0][0], ring[0][1]
+		geometry = append(geometry, mvtLineTo|uint32(len(ring)-1)<<3)
+		for _, point := range ring[1:] {
+			geometry = append(geometry, zigzag32(point[0]-x), zigzag32(point[1]-y))
+			x, y = point[0], point[1]
+		}
+		geometry = append(geometry, mvtClosePath|1<<3)
+	}
+	return geometry
+}
+
+// zigzag32 encodes a geometry command parameter.
+func zigzag32(v int32) uint32 {
+	return uint32(v<<1) ^ uint32(v>>31)
+}
+
+// unzigzag32 decodes a geometry command parameter.
+func unzigzag32(v uint32) int32 {
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+// decodeGeometry decodes the geometry commands of feature into parts, each a
+// list of [x, y] points in tile coordinates: a part per point of Point
+// features, per line of LineString features, or per ring of Polygon features,
+// without repeating the first point.  Decoding stops at the first malformed
+// command; see validateGeometry.
+func decodeGeometry(feature mvtFeature) [][][2]int32 {
+	var (
+		parts    [][][2]int32
+		x, y     int32
+		geometry = feature.geometry
+	)
+	for i := 0; i < len(geometry); {
+		command, count := geometry[i]&7, int(geometry[i]>>3)
+		i++
+		switch command {
+		case mvtMoveTo, mvtLineTo:
+			if i+2*count > len(geometry) || (command == mvtLineTo && len(parts) == 0) {
+				return parts
+			}
+			for j := 0; j < count; j++ {
+				x += unzigzag32(geometry[i])
+				y += unzigzag32(geometry[i+1])
+				i += 2
+				if command == mvtMoveTo {
+					parts = append(parts, nil)
+				}
+				parts[len(parts)-1] = append(parts[len(parts)-1], [2]int32{x, y})
+			}
+		case mvtClosePath:
+			// rings are closed implicitly
+		default:
+			return parts
+		}
+	}
+	return parts
+}
+
+// packUint32s encodes values as a packed repeated uint32 field.
+func packUint32s(values []uint32) []byte {
+	var data []byte
+	for _, value := range values {
+		data = appendUvarint(data, uint64(value))
+	}
+	return data
+}
+
+// appendVarintField appends a varint field to a protocol buffer message.
+func appendVarintField(data []byte, field uint64, value uint64) []byte {
+	data = appendUvarint(data, field<<3)
+	return appendUvarint(data, value)
+}
+
+// appendBytesField appends a length-delimited field to a protocol buffer
+// message.
+func appendBytesField(data []byte, field uint64, value []byte) []byte {
+	data = appendUvarint(data, field<<3|2)
+	data = appendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+// appendUvarint appends value to data as a varint.
+func appendUvarint(data []byte, value uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(data, buf[:binary.PutUvarint(buf[:], value)]...)
+}
@@ -0,0 +1,138 @@
+package mbtiles
+
+import (
+	"testing"
+)
+
+func Test_decodeMVT(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil {
+		t.Fatal(err)
+	}
+	data, err = gunzip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := decodeMVT(data)
+	if err != nil {
+		t.Fatal("Unexpected error decoding tile:", err)
+	}
+	if len(layers) != 1 {
+		t.Fatal("Expected 1 layer, got:", len(layers))
+	}
+	layer := layers[0]
+	if layer.name != "cities" || layer.version != 2 || layer.extent != 4096 {
+		t.Error("Layer does not match expected values, got:", layer.name, layer.version, layer.extent)
+	}
+	if len(layer.features) == 0 || len(layer.keys) != 1 || layer.keys[0] != "name" {
+		t.Error("Layer features and keys do not match expected values, got:", len(layer.features), layer.keys)
+	}
+	for _, feature := range layer.features {
+		if feature.geomType != mvtPoint {
+			t.Error("Expected point feature, got:", feature.geomType)
+		}
+		if err := validateGeometry(feature); err != nil {
+			t.Error("Unexpected invalid geometry:", err)
+		}
+	}
+
+	if _, err := decodeMVT(data[:len(data)/2]); err == nil {
+		t.Error("Expected error decoding truncated tile")
+	}
+}
+
+// command encodes a vector tile geometry command.
+func command(id uint32, count uint32) uint32 {
+	return id&7 | count<<3
+}
+
+func Test_validateGeometry(t *testing.T) {
+	tests := []struct {
+		geomType uint32
+		geometry []uint32
+		valid    bool
+	}{
+		{geomType: mvtPoint, geometry: []uint32{command(mvtMoveTo, 1), 2, 2}, valid: true},
+		{geomType: mvtPoint, geometry: []uint32{command(mvtMoveTo, 2), 2, 2, 4, 4}, valid: true},
+		{geomType: mvtPoint, geometry: []uint32{command(mvtMoveTo, 2), 2, 2}, valid: false},
+		{geomType: mvtPoint, geometry: nil, valid: false},
+		{geomType: mvtLineString, geometry: []uint32{command(mvtMoveTo, 1), 2, 2, command(mvtLineTo, 1), 4, 4}, valid: true},
+		{geomType: mvtLineString, geometry: []uint32{command(mvtMoveTo, 1), 2, 2}, valid: false},
+		{geomType: mvtLineString, geometry: []uint32{command(mvtLineTo, 1), 2, 2}, valid: false},
+		{geomType: mvtPolygon, geometry: []uint32{command(mvtMoveTo, 1), 2, 2, command(mvtLineTo, 2), 4, 0, 0, 4, command(mvtClosePath, 1)}, valid: true},
+		{geomType: mvtPolygon, geometry: []uint32{command(mvtMoveTo, 1), 2, 2, command(mvtLineTo, 2), 4, 0, 0, 4}, valid: false},
+		{geomType: mvtPolygon, geometry: []uint32{command(mvtMoveTo, 1), 2, 2, command(mvtLineTo, 1), 4, 0, command(mvtClosePath, 1)}, valid: false},
+		{geomType: mvtUnknown, geometry: []uint32{command(mvtMoveTo, 1), 2, 2}, valid: false},
+		{geomType: mvtPoint, geometry: []uint32{command(3, 1), 2, 2}, valid: false},
+	}
+
+	for _, tc := range tests {
+		err := validateGeometry(mvtFeature{geomType: tc.geomType, geometry: tc.geometry})
+		if (err == nil) != tc.valid {
+			t.Error("Validity of geometry", tc.geometry, "of type", tc.geomType, "does not match expected value", tc.valid, "got error:", err)
+		}
+	}
+}
+
+func Test_encodeMVT(t *testing.T) {
+	lines := [][][2]int32{{{10, 20}, {30, 20}, {30, 5}}, {{0, 0}, {4096, 4096}}}
+	layer := mvtLayer{
+		version: 2,
+		name:    "roads",
+		extent:  4096,
+		keys:    []string{"name", "lanes", "length", "count", "oneway"},
+		values:  []interface{}{"Main St", int64(-2), 12.5, uint64(3), true},
+		features: []mvtFeature{{
+			id:       7,
+			hasID:    true,
+			tags:     []uint32{0, 0, 1, 1, 2, 2, 3, 3, 4, 4},
+			geomType: mvtLineString,
+			geometry: encodeLineGeometry(lines),
+		}},
+	}
+
+	data := encodeMVT([]mvtLayer{layer})
+	if err := validateMVT(data); err != nil {
+		t.Fatal("Encoded vector tile is not valid:", err)
+	}
+	layers, err := decodeMVT(data)
+	if err != nil || len(layers) != 1 {
+		t.Fatal("Could not decode encoded vector tile:", err)
+	}
+
+	decoded := layers[0]
+	if decoded.version != 2 || decoded.name != "roads" || decoded.extent != 4096 || !equalStrings(decoded.keys, layer.keys) {
+		t.Error("Decoded layer does not match encoded layer:", decoded)
+	}
+	for i, value := range layer.values {
+		if decoded.values[i] != value {
+			t.Error("Decoded value", decoded.values[i], "does not match encoded value", value)
+		}
+	}
+	feature := decoded.features[0]
+	if !feature.hasID || feature.id != 7 || feature.geomType != mvtLineString {
+		t.Error("Decoded feature does not match encoded feature:", feature)
+	}
+	// MoveTo(10, 20) LineTo(+20, 0)(0, -15) MoveTo(-30, -5) LineTo(+4096, +4096)
+	expected := []uint32{9, 20, 40, 18, 40, 0, 0, 29, 9, 59, 9, 10, 8192, 8192}
+	if len(feature.geometry) != len(expected) {
+		t.Fatal("Decoded geometry does not match expected value:", feature.geometry)
+	}
+	for i := range expected {
+		if feature.geometry[i] != expected[i] {
+			t.Error("Decoded geometry does not match expected value:", feature.geometry)
+			break
+		}
+	}
+	parts := decodeGeometry(feature)
+	if len(parts) != len(lines) || len(parts[0]) != 3 || parts[0][2] != lines[0][2] || parts[1][1] != lines[1][1] {
+		t.Error("Decoded parts do not match encoded lines:", parts)
+	}
+}
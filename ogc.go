@@ -0,0 +1,118 @@
+package mbtiles
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// WebMercatorQuadID is the ID of the Web Mercator tile matrix set used by
+	// mbtiles files, as defined by OGC Two Dimensional Tile Matrix Set.
+	WebMercatorQuadID = "WebMercatorQuad"
+	// webMercatorQuadURI is the URI of the WebMercatorQuad tile matrix set.
+	webMercatorQuadURI = "http://www.opengis.net/def/tilematrixset/OGC/1.0/WebMercatorQuad"
+	// webMercatorCRS is the URI of the Web Mercator coordinate reference system.
+	webMercatorCRS = "http://www.opengis.net/def/crs/EPSG/0/3857"
+	// webMercatorQuadMaxZoom is the highest zoom level defined by the
+	// WebMercatorQuad tile matrix set.
+	webMercatorQuadMaxZoom = 24
+	// ogcPixelSize is the standardized rendering pixel size in meters used to
+	// compute scale denominators.
+	ogcPixelSize = 0.00028
+)
+
+// WebMercatorQuad returns the OGC API - Tiles description of the
+// WebMercatorQuad tile matrix set used by mbtiles files, as served at
+// /tileMatrixSets/WebMercatorQuad.
+func WebMercatorQuad() map[string]interface{} {
+	return WebMercatorGrid.OGCTileMatrixSet(webMercatorQuadMaxZoom)
+}
+
+// OGCTileset creates the OGC API - Tiles tileset metadata for the tileset,
+// using tilesURL as the URL template of its tiles, for example
+// "https://example.com/collections/world/tiles/WebMercatorQuad/{tileMatrix}/{tileRow}/{tileCol}".
+//
+// Tile row and column limits for each zoom level are derived from the bounds
+// in metadata, and use the XYZ scheme (origin at top left).  The coordinate
+// reference system and tile matrix set are those of the tile grid returned by
+// GetTileGrid.
+func (db *MBtiles) OGCTileset(tilesURL string) (map[string]interface{}, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot create tileset metadata for closed mbtiles database")
+	}
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := metadata["name"].(string)
+	description, _ := metadata["description"].(string)
+	attribution, _ := metadata["attribution"].(string)
+	minZoom, _ := metadata["minzoom"].(int)
+	maxZoom, _ := metadata["maxzoom"].(int)
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return nil, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+
+	grid, err := db.GetTileGrid()
+	if err != nil {
+		return nil, err
+	}
+
+	bounds, ok := metadata["bounds"].([]float64)
+	if !ok || validateBounds(bounds) != nil {
+		bounds = []float64{-180, -maxLatitude, 180, maxLatitude}
+		if isGeographicCRS(grid.CRS) {
+			bounds = []float64{-180, -90, 180, 90}
+		}
+	}
+
+	var limits []map[string]interface{}
+	for z := minZoom; z <= maxZoom; z++ {
+		topLeft, bottomRight, err := grid.TileRange(bounds, int64(z))
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, map[string]interface{}{
+			"tileMatrix": strconv.Itoa(z),
+			"minTileRow": topLeft.Y,
+			"maxTileRow": bottomRight.Y,
+			"minTileCol": topLeft.X,
+			"maxTileCol": bottomRight.X,
+		})
+	}
+
+	format := db.GetTileFormat()
+	dataType := "map"
+	if format == PBF {
+		dataType = "vector"
+	}
+
+	tileset := map[string]interface{}{
+		"title":               name,
+		"description":         description,
+		"dataType":            dataType,
+		"crs":                 grid.CRS,
+		"tileMatrixSetLimits": limits,
+		"boundingBox": map[string]interface{}{
+			"lowerLeft":  []float64{bounds[0], bounds[1]},
+			"upperRight": []float64{bounds[2], bounds[3]},
+			"crs":        "http://www.opengis.net/def/crs/OGC/1.3/CRS84",
+		},
+		"links": []map[string]interface{}{{
+			"rel":       "item",
+			"href":      tilesURL,
+			"type":      format.MimeType(),
+			"templated": true,
+		}},
+	}
+	if grid.URI != "" {
+		tileset["tileMatrixSetURI"] = grid.URI
+	}
+	if attribution != "" {
+		tileset["attribution"] = attribution
+	}
+	return tileset, nil
+}
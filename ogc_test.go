@@ -0,0 +1,75 @@
+package mbtiles
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_WebMercatorQuad(t *testing.T) {
+	tms := WebMercatorQuad()
+	if tms["id"] != WebMercatorQuadID {
+		t.Error("Tile matrix set ID does not match expected value, got:", tms["id"])
+	}
+
+	matrices := tms["tileMatrices"].([]map[string]interface{})
+	if len(matrices) != 25 {
+		t.Fatal("Expected 25 tile matrices, got:", len(matrices))
+	}
+	// values from OGC Two Dimensional Tile Matrix Set, Annex D.1
+	if math.Abs(matrices[0]["scaleDenominator"].(float64)-559082264.0287178) > 1e-6 {
+		t.Error("Zoom level 0 scale denominator does not match expected value, got:", matrices[0]["scaleDenominator"])
+	}
+	if matrices[3]["id"] != "3" || matrices[3]["matrixWidth"] != int64(8) {
+		t.Error("Zoom level 3 tile matrix does not match expected values, got:", matrices[3])
+	}
+}
+
+func Test_OGCTileset(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tileset, err := db.OGCTileset("http://localhost/tiles/WebMercatorQuad/{tileMatrix}/{tileRow}/{tileCol}")
+	if err != nil {
+		t.Fatal("Unexpected error creating tileset metadata:", err)
+	}
+
+	if tileset["dataType"] != "vector" {
+		t.Error("dataType does not match expected value, got:", tileset["dataType"])
+	}
+
+	limits := tileset["tileMatrixSetLimits"].([]map[string]interface{})
+	if len(limits) != 7 {
+		t.Fatal("Expected tile matrix set limits per zoom level, got:", limits)
+	}
+	// bounds: -123.123590,-37.818085,174.763027,59.352706
+	expected := map[string]interface{}{"tileMatrix": "2", "minTileRow": int64(1), "maxTileRow": int64(2), "minTileCol": int64(0), "maxTileCol": int64(3)}
+	for key, value := range expected {
+		if limits[2][key] != value {
+			t.Error("Zoom level 2 limit", key, limits[2][key], "does not match expected value", value)
+		}
+	}
+
+	links := tileset["links"].([]map[string]interface{})
+	if len(links) != 1 || links[0]["type"] != "application/x-protobuf" {
+		t.Error("links do not match expected values, got:", links)
+	}
+}
+
+func Test_OGCTileset_invalid_zoom_range(t *testing.T) {
+	db, err := Open(copyTestdata(t, "geography-class-jpg.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.pool.Exec("update metadata set value = '-1' where name = 'minzoom'"); err != nil {
+		t.Fatal(err)
+	}
+	db.InvalidateMetadata()
+
+	if _, err := db.OGCTileset("https://example.com/{tileMatrix}/{tileRow}/{tileCol}"); err == nil {
+		t.Error("Creating OGC tileset metadata with negative minimum zoom did not raise error")
+	}
+}
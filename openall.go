@@ -0,0 +1,79 @@
+package mbtiles
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// OpenAll opens and validates the mbtiles files at paths using up to
+// concurrency goroutines, as described for OpenContext, to warm up services
+// that serve many tilesets at startup.  Concurrency less than 1 is treated as
+// 1.  Duplicate paths are opened once.
+//
+// The files that were opened are returned keyed by path, along with an error
+// for each file that could not be opened, in the order of paths, so that
+// callers can serve the valid tilesets and report the others.  If ctx is done
+// before all files are opened, an error is returned for each remaining file.
+// Callers must close the returned handles.
+func OpenAll(ctx context.Context, paths []string, concurrency int, opts ...OpenOption) (map[string]*MBtiles, []error) {
+	unique := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(unique) {
+		concurrency = len(unique)
+	}
+
+	// results are written by index to preserve the order of paths
+	handles := make([]*MBtiles, len(unique))
+	errs := make([]error, len(unique))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				handles[i], errs[i] = OpenContext(ctx, unique[i], opts...)
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for i := range unique {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case indexes <- i:
+			dispatched++
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	opened := make(map[string]*MBtiles, len(unique))
+	var failed []error
+	for i, path := range unique {
+		err := errs[i]
+		if i >= dispatched {
+			err = ctx.Err()
+		}
+		if err != nil {
+			failed = append(failed, fmt.Errorf("could not open %q: %w", path, err))
+			continue
+		}
+		opened[path] = handles[i]
+	}
+	return opened, failed
+}
@@ -0,0 +1,38 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_OpenAll(t *testing.T) {
+	paths := []string{
+		"./testdata/geography-class-png.mbtiles",
+		"./testdata/invalid.mbtiles",
+		"./testdata/world_cities.mbtiles",
+		"./testdata/does-not-exist.mbtiles",
+		"./testdata/world_cities.mbtiles",
+	}
+
+	opened, errs := OpenAll(context.Background(), paths, 2)
+	defer func() {
+		for _, db := range opened {
+			db.Close()
+		}
+	}()
+	if len(opened) != 2 || opened[paths[0]] == nil || opened[paths[2]] == nil {
+		t.Error("OpenAll did not open valid files, got:", opened)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "invalid.mbtiles") || !strings.Contains(errs[1].Error(), "does-not-exist.mbtiles") {
+		t.Error("OpenAll did not return errors for invalid files in order, got:", errs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opened, errs = OpenAll(ctx, paths[:1], 1)
+	if len(opened) != 0 || len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Error("Expected context.Canceled opening with canceled context, got:", opened, errs)
+	}
+}
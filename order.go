@@ -0,0 +1,156 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TileOrder is the order in which Export writes the tiles of each zoom level.
+// Tiles are stored in SQLite in the order they are written, so ordering tiles
+// along a space-filling curve keeps nearby tiles in nearby pages of the file,
+// which improves the locality of OS page caches and of readers that fetch
+// ranges of the file, such as over HTTP.
+type TileOrder uint8
+
+// TileOrder values
+const (
+	OrderRowMajor TileOrder = iota // by column, then row
+	OrderZOrder                    // along the Z-order (Morton) curve
+	OrderHilbert                   // along the Hilbert curve, as in PMTiles
+)
+
+// ZOrderIndex returns the position of the XYZ scheme tile c along the Z-order
+// curve of the tiles at its zoom level, by interleaving the bits of its
+// column and row.
+func (c TileCoord) ZOrderIndex() uint64 {
+	var index uint64
+	for bit := int64(0); bit < c.Z; bit++ {
+		index |= uint64((c.X>>bit)&1) << (2 * bit)
+		index |= uint64((c.Y>>bit)&1) << (2*bit + 1)
+	}
+	return index
+}
+
+// HilbertIndex returns the position of the XYZ scheme tile c along the
+// Hilbert curve of the tiles at its zoom level, which starts at the top left
+// tile and ends at the top right tile, as used to order tiles in PMTiles.
+func (c TileCoord) HilbertIndex() uint64 {
+	n := int64(1) << c.Z
+	x, y := c.X, c.Y
+	var index uint64
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry int64
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		index += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+		// rotate the quadrant so that the curve within it is in standard order
+		if ry == 0 {
+			if rx == 1 {
+				x, y = n-1-x, n-1-y
+			}
+			x, y = y, x
+		}
+	}
+	return index
+}
+
+// Export copies all tiles into a new mbtiles file at dst, writing the tiles of
+// each zoom level in order, from the lowest zoom level to the highest, and
+// returns the number of tiles copied.  dst must not already exist.  The tiles
+// of each zoom level are sorted in memory before they are copied.
+//
+// Metadata is copied from this mbtiles file.  Tiles are written to a plain
+// tiles table, regardless of the schema of this mbtiles file.  dst is removed
+// if the export fails.
+func (db *MBtiles) Export(ctx context.Context, dst string, order TileOrder) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot export tiles from closed mbtiles database")
+	}
+	var index func(coord TileCoord) uint64
+	switch order {
+	case OrderRowMajor:
+	case OrderZOrder:
+		index = TileCoord.ZOrderIndex
+	case OrderHilbert:
+		index = TileCoord.HilbertIndex
+	default:
+		return 0, fmt.Errorf("invalid tile order %d", order)
+	}
+
+	var count int64
+	err := db.extract(ctx, dst, nil, func(q querier) error {
+		zooms, err := readZoomLevels(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, z := range zooms {
+			coords, err := readZoomTileCoords(ctx, q, z)
+			if err != nil {
+				return err
+			}
+			if index != nil {
+				sort.Slice(coords, func(i, j int) bool {
+					return index(coords[i].FlipY()) < index(coords[j].FlipY())
+				})
+			}
+			for _, coord := range coords {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				_, err := q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) select zoom_level, tile_column, tile_row, tile_data from main.tiles where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y)
+				if err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return db.recordExtractHistory(ctx, q, "export", map[string]interface{}{"order": int(order)})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// readZoomLevels reads the zoom levels of tiles, in increasing order.
+func readZoomLevels(ctx context.Context, q querier) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, "select distinct zoom_level from main.tiles order by zoom_level")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var zooms []int64
+	for rows.Next() {
+		var z int64
+		if err := rows.Scan(&z); err != nil {
+			return nil, err
+		}
+		zooms = append(zooms, z)
+	}
+	return zooms, rows.Err()
+}
+
+// readZoomTileCoords reads the coordinates (TMS scheme) of the tiles at zoom
+// level z, in order of column and row.
+func readZoomTileCoords(ctx context.Context, q querier, z int64) ([]TileCoord, error) {
+	rows, err := q.QueryContext(ctx, "select tile_column, tile_row from main.tiles where zoom_level = ? order by tile_column, tile_row", z)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var coords []TileCoord
+	for rows.Next() {
+		coord := TileCoord{Z: z}
+		if err := rows.Scan(&coord.X, &coord.Y); err != nil {
+			return nil, err
+		}
+		coords = append(coords, coord)
+	}
+	return coords, rows.Err()
+}
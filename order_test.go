@@ -0,0 +1,85 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_TileCoord_curveIndex(t *testing.T) {
+	// tiles of zoom level 1 in order along each curve
+	hilbert := []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 1, X: 0, Y: 1}, {Z: 1, X: 1, Y: 1}, {Z: 1, X: 1, Y: 0}}
+	zOrder := []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 1, X: 1, Y: 0}, {Z: 1, X: 0, Y: 1}, {Z: 1, X: 1, Y: 1}}
+	for i := range hilbert {
+		if index := hilbert[i].HilbertIndex(); index != uint64(i) {
+			t.Error("Hilbert index of", hilbert[i], index, "does not match expected value", i)
+		}
+		if index := zOrder[i].ZOrderIndex(); index != uint64(i) {
+			t.Error("Z-order index of", zOrder[i], index, "does not match expected value", i)
+		}
+	}
+
+	// consecutive tiles along the Hilbert curve are adjacent
+	seen := make(map[uint64]TileCoord)
+	for x := int64(0); x < 8; x++ {
+		for y := int64(0); y < 8; y++ {
+			coord := TileCoord{Z: 3, X: x, Y: y}
+			seen[coord.HilbertIndex()] = coord
+		}
+	}
+	for i := uint64(1); i < 64; i++ {
+		a, b := seen[i-1], seen[i]
+		if dx, dy := a.X-b.X, a.Y-b.Y; dx*dx+dy*dy != 1 {
+			t.Error("Tiles", a, b, "at consecutive Hilbert indexes are not adjacent")
+		}
+	}
+	if len(seen) != 64 {
+		t.Error("Expected distinct Hilbert indexes, got:", len(seen))
+	}
+}
+
+func Test_Export(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dst := filepath.Join(t.TempDir(), "hilbert.mbtiles")
+	count, err := db.Export(ctx, dst, OrderHilbert)
+	if err != nil {
+		t.Fatal("Could not export tiles:", err)
+	}
+	if count != 5 {
+		t.Error("Expected 5 exported tiles, got:", count)
+	}
+
+	exported, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exported.Close()
+	rows, err := exported.pool.QueryContext(ctx, "select zoom_level, tile_column, tile_row from tiles order by rowid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var order []TileCoord
+	for rows.Next() {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, coord.FlipY())
+	}
+	expected := []TileCoord{{Z: 0, X: 0, Y: 0}, {Z: 1, X: 0, Y: 0}, {Z: 1, X: 0, Y: 1}, {Z: 1, X: 1, Y: 1}, {Z: 1, X: 1, Y: 0}}
+	if !reflect.DeepEqual(order, expected) {
+		t.Error("Order of tiles does not match expected value, got:", order)
+	}
+
+	if _, err := db.Export(ctx, dst, OrderZOrder); err == nil {
+		t.Error("Expected error for existing destination")
+	}
+}
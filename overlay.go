@@ -0,0 +1,393 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+)
+
+// tombstonesTable records tiles deleted in the patch of an Overlay.
+const tombstonesTable = "create table if not exists tombstones (zoom_level integer, tile_column integer, tile_row integer)"
+
+// tombstonesIndex ensures each tile is recorded as deleted at most once.
+const tombstonesIndex = "create unique index if not exists tombstone_index on tombstones (zoom_level, tile_column, tile_row)"
+
+// Overlay layers a small writable patch mbtiles file over a base mbtiles file,
+// such as corrections on top of a vendor basemap.  Tiles are read from the
+// patch if present, and from the base otherwise.  Tiles are written to the
+// patch, leaving the base unchanged until Flatten is called.
+//
+// Deleted tiles are recorded in a tombstones table of the patch, so that they
+// are hidden from reads and removed from the base by Flatten.
+//
+// Since patches are typically small, most reads miss the patch.  The most
+// recently read tiles that are neither in the patch nor deleted there are
+// remembered, so that they are read from the base without querying the patch
+// again.  The patch must therefore only be changed using the Overlay.
+type Overlay struct {
+	base          *MBtiles
+	patch         *MBtiles
+	tombstoneStmt *sql.Stmt
+	patchMisses   *missCache
+}
+
+// tombstoneQuery counts the tombstones of a tile in the patch.
+const tombstoneQuery = "select count(*) from tombstones where zoom_level = ? and tile_column = ? and tile_row = ?"
+
+// overlayMissCacheSize is the number of tiles missing from the patch that are
+// remembered by an Overlay.
+const overlayMissCacheSize = 16384
+
+// OpenOverlay opens the base mbtiles file at basePath using the provided
+// options, and the patch mbtiles file at patchPath, which is created if it
+// does not exist.  Tiles in the patch must have the same format as the base.
+func OpenOverlay(basePath string, patchPath string, opts ...OpenOption) (*Overlay, error) {
+	base, err := Open(basePath, append(opts, openOverlayBase())...)
+	if err != nil {
+		return nil, err
+	}
+	if err := base.init(context.TODO()); err != nil {
+		base.Close()
+		return nil, err
+	}
+
+	patch, err := openPatch(patchPath, base)
+	if err != nil {
+		base.Close()
+		return nil, err
+	}
+	tombstoneStmt, err := patch.pool.PrepareContext(context.TODO(), tombstoneQuery)
+	if err != nil {
+		patch.Close()
+		base.Close()
+		return nil, err
+	}
+	return &Overlay{base: base, patch: patch, tombstoneStmt: tombstoneStmt, patchMisses: newMissCache(overlayMissCacheSize, 0)}, nil
+}
+
+// openOverlayBase opens the mbtiles file as the base of an Overlay, which
+// accepts WriteCoordinates.
+func openOverlayBase() OpenOption {
+	return func(o *openOptions) {
+		o.overlayBase = true
+	}
+}
+
+// openPatch opens the patch mbtiles file at path, creating it if needed.
+// Unlike Open, the patch may be empty; its tile format is that of base.
+func openPatch(path string, base *MBtiles) (*MBtiles, error) {
+	baseState := base.loadState()
+	// patches created before tombstones were supported lack the table
+	patch, _, err := openTileStore(path, base.options.traceHook, baseState.format, baseState.tilesize, tombstonesTable, tombstonesIndex)
+	return patch, err
+}
+
+// openTileStore opens the mbtiles file at path for writing tiles of the given
+// format and size, creating it if needed, and creates tables with the extra
+// queries if they do not exist.  Unlike Open, the file may be empty.  Its
+// metadata is not synced to its tiles, as stores usually hold only some of the
+// tiles of another tileset, whose metadata they keep.  Returns true if the
+// file was created.
+func openTileStore(path string, traceHook func(QueryTrace), format TileFormat, tilesize uint32, queries ...string) (*MBtiles, bool, error) {
+	_, err := os.Stat(path)
+	create := errors.Is(err, os.ErrNotExist)
+	if err != nil && !create {
+		return nil, false, err
+	}
+
+	pool, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, false, err
+	}
+	db := &MBtiles{
+		filename:  path,
+		pool:      pool,
+		options:   openOptions{traceHook: traceHook},
+		timestamp: time.Now().Round(time.Second),
+	}
+
+	ctx := context.TODO()
+	db.initOnce.Do(func() {
+		q := db.traced(pool)
+		if create {
+			queries = append(schemaStatements(""), queries...)
+		}
+		for _, query := range queries {
+			if _, db.initErr = q.ExecContext(ctx, query); db.initErr != nil {
+				return
+			}
+		}
+		if db.initErr = validateRequiredTables(ctx, q); db.initErr != nil {
+			return
+		}
+		var tileStmt *sql.Stmt
+		if tileStmt, db.initErr = pool.PrepareContext(ctx, tileQuery); db.initErr != nil {
+			return
+		}
+		db.state.Store(&handleState{format: format, tilesize: tilesize, tileStmt: tileStmt})
+	})
+	if db.initErr != nil {
+		pool.Close()
+		return nil, false, db.initErr
+	}
+	return db, create, nil
+}
+
+// Close closes the base and patch mbtiles files.
+func (o *Overlay) Close() {
+	o.tombstoneStmt.Close()
+	o.patch.Close()
+	o.base.Close()
+}
+
+// GetTileFormat returns the TileFormat of the base mbtiles file, which is also
+// that of the patch.
+func (o *Overlay) GetTileFormat() TileFormat {
+	return o.base.GetTileFormat()
+}
+
+// Base returns the base mbtiles file.
+func (o *Overlay) Base() *MBtiles {
+	return o.base
+}
+
+// GetTile returns the tile for z, x, y (TMS scheme) from the patch if present,
+// or from the base otherwise, as described for MBtiles.GetTile.
+// ErrTileNotFound is returned if the tile was deleted in the patch.
+func (o *Overlay) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	missing, generation := o.patchMisses.contains(coord)
+	if !missing {
+		data, err := o.patch.GetTile(ctx, z, x, y)
+		if err != ErrTileNotFound {
+			return data, err
+		}
+
+		var deleted int
+		if err := o.patch.tracedStmt(o.tombstoneStmt, tombstoneQuery).QueryRowContext(ctx, z, x, y).Scan(&deleted); err != nil {
+			return nil, err
+		}
+		if deleted > 0 {
+			return nil, ErrTileNotFound
+		}
+		o.patchMisses.add(coord, generation)
+	}
+	return o.base.GetTile(ctx, z, x, y)
+}
+
+// ReadTile reads a tile for z, x, y (TMS scheme) into data from the patch if
+// present, or from the base otherwise, as described for MBtiles.ReadTile.
+// data is set to nil if the tile was deleted in the patch.
+//
+// Deprecated: use GetTile, which accepts a context and returns
+// ErrTileNotFound for missing tiles.
+func (o *Overlay) ReadTile(z int64, x int64, y int64, data *[]byte) error {
+	tile, err := o.GetTile(context.Background(), z, x, y)
+	if err == ErrTileNotFound {
+		*data = nil
+		return nil
+	}
+	*data = tile
+	return err
+}
+
+// WriteTile writes a tile for z, x, y (TMS scheme, unless set otherwise with
+// WriteCoordinates) to the patch, replacing any tile previously written or
+// deleted there.
+func (o *Overlay) WriteTile(ctx context.Context, z int64, x int64, y int64, data []byte) error {
+	return o.updateTile(ctx, z, x, y, func(q querier, schema tileSchema, coord TileCoord) error {
+		if _, err := q.ExecContext(ctx, "delete from tombstones where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y); err != nil {
+			return err
+		}
+		return writeTileTx(ctx, q, schema, coord.Z, coord.X, coord.Y, data, data)
+	})
+}
+
+// DeleteTile deletes the tile for z, x, y (TMS scheme, unless set otherwise
+// with WriteCoordinates) from the overlay, by removing any tile written to the
+// patch and recording a tombstone for it, so that the tile of the base is no
+// longer read and is deleted by Flatten.
+func (o *Overlay) DeleteTile(ctx context.Context, z int64, x int64, y int64) error {
+	return o.updateTile(ctx, z, x, y, func(q querier, schema tileSchema, coord TileCoord) error {
+		if _, err := q.ExecContext(ctx, "delete from "+schema.table+" where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y); err != nil {
+			return err
+		}
+		if schema.deduplicated {
+			if err := deleteUnreferencedImages(ctx, q); err != nil {
+				return err
+			}
+		}
+		_, err := q.ExecContext(ctx, "insert or ignore into tombstones (zoom_level, tile_column, tile_row) values (?, ?, ?)", coord.Z, coord.X, coord.Y)
+		return err
+	})
+}
+
+// Tombstones returns the coordinates (TMS scheme) of tiles deleted in the
+// patch, ordered by zoom level, column, and row, so that deletions can be
+// propagated to other copies of the base.
+func (o *Overlay) Tombstones(ctx context.Context) ([]TileCoord, error) {
+	return readTombstones(ctx, o.patch.traced(o.patch.pool))
+}
+
+// updateTile validates and normalizes the tile coordinates as set with
+// WriteCoordinates, and calls update with them (TMS scheme) within a
+// transaction of the patch.
+func (o *Overlay) updateTile(ctx context.Context, z int64, x int64, y int64, update func(q querier, schema tileSchema, coord TileCoord) error) error {
+	coord, err := o.base.options.normalizeWriteCoord(TileCoord{Z: z, X: x, Y: y})
+	if err != nil {
+		return err
+	}
+
+	patch := o.patch
+	tx, err := patch.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := patch.traced(tx)
+	schema, err := patch.writeSchema(ctx, q)
+	if err != nil {
+		return err
+	}
+	if err := update(q, schema, coord); err != nil {
+		return err
+	}
+	// forget the tile both before and after the commit, so that reads that
+	// miss the tile before the commit do not cache it
+	o.patchMisses.remove(coord)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	o.patchMisses.remove(coord)
+	patch.tilesChanged()
+	return nil
+}
+
+// Flatten writes all tiles in the patch to the base, replacing existing
+// tiles, and deletes tiles recorded as deleted in the patch from the base, in
+// a single transaction, then removes them from the patch.  Returns the number
+// of tiles written or deleted.
+func (o *Overlay) Flatten(ctx context.Context) (int64, error) {
+	base, patch := o.base, o.patch
+
+	tx, err := base.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := base.traced(tx)
+
+	schema, err := base.writeSchema(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := patch.traced(patch.pool).QueryContext(ctx, "select zoom_level, tile_column, tile_row, tile_data from tiles")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var written int64
+	for rows.Next() {
+		var (
+			coord TileCoord
+			data  []byte
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &data); err != nil {
+			return 0, err
+		}
+		if err := writeTileTx(ctx, q, schema, coord.Z, coord.X, coord.Y, data, data); err != nil {
+			return 0, err
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	tombstones, err := o.Tombstones(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, coord := range tombstones {
+		if err := logDeletes(ctx, q, schema, "zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y); err != nil {
+			return 0, err
+		}
+		if _, err := q.ExecContext(ctx, "delete from "+schema.table+" where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y); err != nil {
+			return 0, err
+		}
+		written++
+	}
+
+	if written > 0 {
+		if schema.deduplicated {
+			if err := deleteUnreferencedImages(ctx, q); err != nil {
+				return 0, err
+			}
+		}
+		if err := base.bumpVersion(ctx, q); err != nil {
+			return 0, err
+		}
+		if err := base.syncMetadata(ctx, q, schema); err != nil {
+			return 0, err
+		}
+		parameters := map[string]interface{}{"patch": patch.GetFilename(), "written": written}
+		if err := base.recordHistory(ctx, q, "flatten", parameters); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	base.tilesChanged()
+
+	// tiles are removed from the patch after they are committed to the base;
+	// if this fails, flattening again writes the same tiles
+	if err := clearPatch(ctx, patch); err != nil {
+		return written, err
+	}
+	patch.tilesChanged()
+	return written, nil
+}
+
+// clearPatch removes all tiles and tombstones from patch.
+func clearPatch(ctx context.Context, patch *MBtiles) error {
+	tx, err := patch.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := patch.traced(tx)
+	for _, query := range []string{"delete from tiles", "delete from tombstones"} {
+		if _, err := q.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// readTombstones returns the coordinates (TMS scheme) of tiles recorded in the
+// tombstones table.
+func readTombstones(ctx context.Context, q querier) ([]TileCoord, error) {
+	rows, err := q.QueryContext(ctx, "select zoom_level, tile_column, tile_row from tombstones order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coords []TileCoord
+	for rows.Next() {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			return nil, err
+		}
+		coords = append(coords, coord)
+	}
+	return coords, rows.Err()
+}
@@ -0,0 +1,236 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Overlay(t *testing.T) {
+	basePath := copyTestdata(t, "geography-class-png.mbtiles")
+	patchPath := filepath.Join(t.TempDir(), "patch.mbtiles")
+	ctx := context.Background()
+
+	overlay, err := OpenOverlay(basePath, patchPath)
+	if err != nil {
+		t.Fatal("Could not open overlay:", err)
+	}
+	defer overlay.Close()
+
+	// use an existing tile as the patched tile, to keep a valid PNG
+	var replacement []byte
+	if err := overlay.ReadTile(0, 0, 0, &replacement); err != nil || len(replacement) != 21246 {
+		t.Fatal("Could not read base tile through overlay:", err)
+	}
+
+	for _, coord := range []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 2, X: 0, Y: 0}} {
+		if err := overlay.WriteTile(ctx, coord.Z, coord.X, coord.Y, replacement); err != nil {
+			t.Fatal("Could not write tile:", coord, err)
+		}
+	}
+
+	var data []byte
+	if err := overlay.ReadTile(1, 0, 0, &data); err != nil || !bytes.Equal(data, replacement) {
+		t.Error("Overlay did not read patched tile:", err)
+	}
+	if err := overlay.ReadTile(1, 1, 1, &data); err != nil || len(data) != 20156 {
+		t.Error("Overlay did not read base tile:", len(data), err)
+	}
+	if err := overlay.Base().ReadTile(1, 0, 0, &data); err != nil || len(data) != 13843 {
+		t.Error("Base was modified before Flatten:", len(data), err)
+	}
+
+	written, err := overlay.Flatten(ctx)
+	if err != nil {
+		t.Fatal("Could not flatten overlay:", err)
+	}
+	if written != 2 {
+		t.Error("Flatten did not write expected number of tiles:", written)
+	}
+	for _, coord := range []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 2, X: 0, Y: 0}} {
+		if err := overlay.Base().ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || !bytes.Equal(data, replacement) {
+			t.Error("Base does not have flattened tile:", coord, err)
+		}
+	}
+	if err := overlay.patch.ReadTile(1, 0, 0, &data); err != nil || data != nil {
+		t.Error("Patch still has tile after Flatten:", err)
+	}
+
+	// an existing patch is reopened
+	reopened, err := OpenOverlay(basePath, patchPath)
+	if err != nil {
+		t.Fatal("Could not reopen overlay:", err)
+	}
+	reopened.Close()
+}
+
+func Test_Overlay_DeleteTile(t *testing.T) {
+	basePath := copyTestdata(t, "geography-class-png.mbtiles")
+	patchPath := filepath.Join(t.TempDir(), "patch.mbtiles")
+	ctx := context.Background()
+
+	overlay, err := OpenOverlay(basePath, patchPath)
+	if err != nil {
+		t.Fatal("Could not open overlay:", err)
+	}
+	defer overlay.Close()
+
+	var replacement []byte
+	if err := overlay.ReadTile(0, 0, 0, &replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	// delete a base tile, and a tile written to the patch
+	if err := overlay.DeleteTile(ctx, 1, 1, 1); err != nil {
+		t.Fatal("Could not delete tile:", err)
+	}
+	if err := overlay.WriteTile(ctx, 1, 0, 1, replacement); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.DeleteTile(ctx, 1, 0, 1); err != nil {
+		t.Fatal("Could not delete tile:", err)
+	}
+	// writing a deleted tile restores it
+	if err := overlay.DeleteTile(ctx, 1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.WriteTile(ctx, 1, 1, 0, replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	tombstones, err := overlay.Tombstones(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TileCoord{{Z: 1, X: 0, Y: 1}, {Z: 1, X: 1, Y: 1}}
+	if len(tombstones) != len(expected) || tombstones[0] != expected[0] || tombstones[1] != expected[1] {
+		t.Error("Tombstones do not match expected value:", tombstones)
+	}
+
+	var data []byte
+	for _, coord := range expected {
+		if err := overlay.ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || data != nil {
+			t.Error("Overlay read deleted tile:", coord, err)
+		}
+		if _, err := overlay.GetTile(ctx, coord.Z, coord.X, coord.Y); err != ErrTileNotFound {
+			t.Error("Expected ErrTileNotFound for deleted tile:", coord, err)
+		}
+		if err := overlay.Base().ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || data == nil {
+			t.Error("Base tile was deleted before Flatten:", coord, err)
+		}
+	}
+	if err := overlay.ReadTile(1, 1, 0, &data); err != nil || !bytes.Equal(data, replacement) {
+		t.Error("Overlay did not read restored tile:", err)
+	}
+
+	written, err := overlay.Flatten(ctx)
+	if err != nil {
+		t.Fatal("Could not flatten overlay:", err)
+	}
+	if written != 3 {
+		t.Error("Flatten did not write or delete expected number of tiles:", written)
+	}
+	for _, coord := range expected {
+		if err := overlay.Base().ReadTile(coord.Z, coord.X, coord.Y, &data); err != nil || data != nil {
+			t.Error("Flatten did not delete base tile:", coord, err)
+		}
+	}
+	if tombstones, err := overlay.Tombstones(ctx); err != nil || len(tombstones) != 0 {
+		t.Error("Patch still has tombstones after Flatten:", tombstones, err)
+	}
+}
+
+func Test_Overlay_patchMisses(t *testing.T) {
+	basePath := copyTestdata(t, "geography-class-png.mbtiles")
+	patchPath := filepath.Join(t.TempDir(), "patch.mbtiles")
+	ctx := context.Background()
+
+	var tileQueries, tombstoneQueries int
+	overlay, err := OpenOverlay(basePath, patchPath, TraceQueries(func(trace QueryTrace) {
+		switch trace.SQL {
+		case tileQuery:
+			tileQueries++
+		case tombstoneQuery:
+			tombstoneQueries++
+		}
+	}))
+	if err != nil {
+		t.Fatal("Could not open overlay:", err)
+	}
+	defer overlay.Close()
+
+	// the patch is only queried on the first read of a tile missing from it
+	for i := 0; i < 3; i++ {
+		if _, err := overlay.GetTile(ctx, 1, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tileQueries != 4 {
+		t.Error("Expected 4 tile queries for 3 reads of base tile, got:", tileQueries)
+	}
+	if tombstoneQueries != 1 {
+		t.Error("Expected 1 tombstone query for 3 reads of base tile, got:", tombstoneQueries)
+	}
+
+	// writing the tile to the patch is not hidden
+	replacement, err := overlay.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.WriteTile(ctx, 1, 1, 1, replacement); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := overlay.GetTile(ctx, 1, 1, 1); err != nil || !bytes.Equal(data, replacement) {
+		t.Error("Overlay did not read patched tile after it was missing:", err)
+	}
+}
+
+func Test_Overlay_WriteCoordinates(t *testing.T) {
+	basePath := copyTestdata(t, "geography-class-png.mbtiles")
+	ctx := context.Background()
+
+	strict, err := OpenOverlay(basePath, filepath.Join(t.TempDir(), "strict.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strict.Close()
+	replacement, err := strict.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, coord := range []TileCoord{{Z: 1, X: -1, Y: 0}, {Z: 1, X: 2, Y: 0}, {Z: 1, X: 0, Y: 2}, {Z: -1}} {
+		if err := strict.WriteTile(ctx, coord.Z, coord.X, coord.Y, replacement); !errors.Is(err, ErrInvalidTileCoord) {
+			t.Error("Expected ErrInvalidTileCoord for invalid coordinates:", coord, err)
+		}
+	}
+
+	lenient, err := OpenOverlay(basePath, filepath.Join(t.TempDir(), "lenient.mbtiles"), WriteCoordinates(XYZScheme, LenientCoordinates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lenient.Close()
+	// column -1 wraps to 1, and XYZ row 0 is TMS row 1
+	if err := lenient.WriteTile(ctx, 1, -1, 0, replacement); err != nil {
+		t.Fatal("Could not write tile with lenient coordinates:", err)
+	}
+	if data, err := lenient.GetTile(ctx, 1, 1, 1); err != nil || !bytes.Equal(data, replacement) {
+		t.Error("Expected normalized tile to be written, got:", err)
+	}
+	if err := lenient.WriteTile(ctx, 1, 0, 2, replacement); !errors.Is(err, ErrInvalidTileCoord) {
+		t.Error("Expected ErrInvalidTileCoord for row outside range, got:", err)
+	}
+	if err := lenient.DeleteTile(ctx, 1, 3, 0); err != nil {
+		t.Fatal("Could not delete tile with lenient coordinates:", err)
+	}
+	if _, err := lenient.GetTile(ctx, 1, 1, 1); err != ErrTileNotFound {
+		t.Error("Expected normalized tile to be deleted, got:", err)
+	}
+
+	// the option has no effect outside an Overlay
+	if db, err := Open(basePath, WriteCoordinates(XYZScheme, LenientCoordinates)); err == nil {
+		db.Close()
+		t.Error("Expected error opening mbtiles file with WriteCoordinates")
+	}
+}
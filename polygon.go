@@ -0,0 +1,266 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ExtractPolygon copies tiles that intersect polygon, a GeoJSON Polygon or
+// MultiPolygon geometry, or a Feature with one, in longitude and latitude,
+// into a new mbtiles file at dst, as described for ExtractZoom, for zoom
+// levels minZoom through maxZoom (inclusive), and returns the number of tiles
+// copied.  dst must not already exist.  This can be used for offline packs
+// shaped like a country rather than its bounding box.
+//
+// If clip is true, features of vector tiles that are outside polygon are
+// removed: points are tested individually, and lines and polygons by their
+// bounds within the tile.  Features that cross the boundary of polygon are
+// kept whole rather than cut at the boundary.  Clipping is applied before the
+// transform of ctx, if any (see WithTileTransform), and returns an error for
+// tilesets that are not vector tiles.
+//
+// Metadata is copied from this mbtiles file, with bounds set to those of
+// polygon, and minzoom and maxzoom set to minZoom and maxZoom.
+func (db *MBtiles) ExtractPolygon(ctx context.Context, dst string, polygon []byte, minZoom int, maxZoom int, clip bool) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot extract tiles from closed mbtiles database")
+	}
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return 0, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+	shape, bounds, err := readGeoJSONPolygon(polygon)
+	if err != nil {
+		return 0, err
+	}
+	if clip {
+		if err := db.init(ctx); err != nil {
+			return 0, err
+		}
+		if format := db.GetTileFormat(); format != PBF {
+			return 0, fmt.Errorf("clipping is only supported for vector tilesets, not %v", format)
+		}
+		transform := tileTransform(ctx)
+		ctx = WithTileTransform(ctx, func(coord TileCoord, data []byte) ([]byte, error) {
+			data, err := clipTile(shape, coord, data)
+			if err != nil || transform == nil {
+				return data, err
+			}
+			return transform(coord, data)
+		})
+	}
+
+	metadata := map[string]string{
+		"bounds":  fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3]),
+		"minzoom": strconv.Itoa(minZoom),
+		"maxzoom": strconv.Itoa(maxZoom),
+	}
+	progress := progressFunc(ctx)
+	var count int64
+	err = db.extract(ctx, dst, metadata, func(q querier) error {
+		for z := minZoom; z <= maxZoom; z++ {
+			coords, err := polygonTiles(ctx, q, shape, int64(z))
+			if err != nil {
+				return err
+			}
+			for _, coord := range coords {
+				_, err := q.ExecContext(ctx, "insert into dst.tiles (zoom_level, tile_column, tile_row, tile_data) select zoom_level, tile_column, tile_row, tile_data from main.tiles where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y)
+				if err != nil {
+					return err
+				}
+			}
+			count += int64(len(coords))
+			progress(Progress{Phase: "extract", Current: count})
+		}
+		return db.recordExtractHistory(ctx, q, "extract", map[string]interface{}{
+			"bounds": bounds, "minzoom": minZoom, "maxzoom": maxZoom, "clip": clip,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// readGeoJSONPolygon returns the polygons of a GeoJSON Polygon or
+// MultiPolygon geometry, or a Feature with one, in world coordinates, along
+// with their bounds in degrees.
+func readGeoJSONPolygon(data []byte) (sourceFeature, []float64, error) {
+	var geometry geoJSONObject
+	if err := json.Unmarshal(data, &geometry); err != nil {
+		return sourceFeature{}, nil, fmt.Errorf("invalid GeoJSON polygon: %w", err)
+	}
+	if geometry.Type == "Feature" {
+		if geometry.Geometry == nil {
+			return sourceFeature{}, nil, errors.New("GeoJSON polygon feature has no geometry")
+		}
+		geometry = *geometry.Geometry
+	}
+	if geometry.Type != "Polygon" && geometry.Type != "MultiPolygon" {
+		return sourceFeature{}, nil, fmt.Errorf("unsupported GeoJSON type %q: must be a Polygon or MultiPolygon", geometry.Type)
+	}
+	shape, bounds, err := readGeoJSONGeometry(geometry)
+	if err != nil {
+		return sourceFeature{}, nil, err
+	}
+	if shape.parts == nil {
+		return sourceFeature{}, nil, errors.New("GeoJSON polygon is empty")
+	}
+	return shape, bounds, nil
+}
+
+// polygonTiles returns the coordinates (TMS scheme) of tiles at zoom level z
+// that intersect shape.  Only tiles within the bounds of shape are tested.
+func polygonTiles(ctx context.Context, q querier, shape sourceFeature, z int64) ([]TileCoord, error) {
+	n := float64(int64(1) << z)
+	limit := func(v float64) int64 { return int64(math.Max(0, math.Min(n-1, math.Floor(v*n)))) }
+	minX, maxX := limit(shape.bbox[0]), limit(shape.bbox[2])
+	// tile rows are stored in the TMS scheme, so the range is flipped
+	minRow, maxRow := int64(n)-1-limit(shape.bbox[3]), int64(n)-1-limit(shape.bbox[1])
+
+	rows, err := q.QueryContext(ctx,
+		"select tile_column, tile_row from main.tiles where zoom_level = ? and tile_column between ? and ? and tile_row between ? and ? order by tile_column, tile_row",
+		z, minX, maxX, minRow, maxRow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coords []TileCoord
+	for rows.Next() {
+		coord := TileCoord{Z: z}
+		if err := rows.Scan(&coord.X, &coord.Y); err != nil {
+			return nil, err
+		}
+		y := int64(n) - 1 - coord.Y
+		if polygonIntersectsRect(shape.parts, [4]float64{float64(coord.X) / n, float64(y) / n, float64(coord.X+1) / n, float64(y+1) / n}) {
+			coords = append(coords, coord)
+		}
+	}
+	return coords, rows.Err()
+}
+
+// clipTile removes the features of vector tile data at coord (TMS scheme)
+// that are outside shape, as described for ExtractPolygon.  Layers without
+// features are removed.
+func clipTile(shape sourceFeature, coord TileCoord, data []byte) ([]byte, error) {
+	gzipped := len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+	layers, err := decodeTileMVT(data)
+	if err != nil {
+		return nil, err
+	}
+	xyz := coord.FlipY()
+	n := float64(int64(1) << xyz.Z)
+
+	var clipped []mvtLayer
+	for _, layer := range layers {
+		scale := float64(layer.extent) * n
+		world := func(point [2]int32) [2]float64 {
+			return [2]float64{(float64(xyz.X)*float64(layer.extent) + float64(point[0])) / scale, (float64(xyz.Y)*float64(layer.extent) + float64(point[1])) / scale}
+		}
+		features := layer.features[:0:0]
+		for _, feature := range layer.features {
+			parts := decodeGeometry(feature)
+			keep := false
+			if feature.geomType == mvtPoint {
+				for _, part := range parts {
+					for _, point := range part {
+						if pointInPolygons(shape.parts, world(point)) {
+							keep = true
+						}
+					}
+				}
+			} else {
+				bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+				for _, part := range parts {
+					for _, point := range part {
+						p := world(point)
+						bbox[0], bbox[1] = math.Min(bbox[0], p[0]), math.Min(bbox[1], p[1])
+						bbox[2], bbox[3] = math.Max(bbox[2], p[0]), math.Max(bbox[3], p[1])
+					}
+				}
+				keep = !math.IsInf(bbox[0], 1) && polygonIntersectsRect(shape.parts, bbox)
+			}
+			if keep {
+				features = append(features, feature)
+			}
+		}
+		if len(features) > 0 {
+			layer.features = features
+			clipped = append(clipped, layer)
+		}
+	}
+
+	data = encodeMVT(clipped)
+	if gzipped {
+		return gzipBytes(data)
+	}
+	return data, nil
+}
+
+// polygonIntersectsRect returns true if any of polygons, each a list of rings
+// with the exterior ring first, intersects rect: [minX, minY, maxX, maxY].
+func polygonIntersectsRect(polygons [][][][2]float64, rect [4]float64) bool {
+	if pointInPolygons(polygons, [2]float64{rect[0], rect[1]}) {
+		return true
+	}
+	for _, rings := range polygons {
+		for _, ring := range rings {
+			for i := range ring {
+				if segmentIntersectsRect(ring[i], ring[(i+1)%len(ring)], rect) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pointInPolygons returns true if point is within any of polygons, using the
+// even-odd rule across the rings of each polygon so that holes are excluded.
+func pointInPolygons(polygons [][][][2]float64, point [2]float64) bool {
+	for _, rings := range polygons {
+		inside := false
+		for _, ring := range rings {
+			for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+				a, b := ring[i], ring[j]
+				if (a[1] > point[1]) != (b[1] > point[1]) && point[0] < (b[0]-a[0])*(point[1]-a[1])/(b[1]-a[1])+a[0] {
+					inside = !inside
+				}
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentIntersectsRect returns true if the segment from a to b intersects
+// rect: [minX, minY, maxX, maxY], by clipping the segment to rect.
+func segmentIntersectsRect(a [2]float64, b [2]float64, rect [4]float64) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	for _, edge := range [][2]float64{{-dx, a[0] - rect[0]}, {dx, rect[2] - a[0]}, {-dy, a[1] - rect[1]}, {dy, rect[3] - a[1]}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// triangle around Vancouver that excludes the other cities of the test data
+const vancouverPolygon = `{"type": "Polygon", "coordinates": [[[-124, 48.5], [-122, 48.5], [-124, 50.5], [-124, 48.5]]]}`
+
+func Test_ExtractPolygon(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, clip := range []bool{false, true} {
+		dst := filepath.Join(t.TempDir(), "polygon.mbtiles")
+		count, err := db.ExtractPolygon(ctx, dst, []byte(vancouverPolygon), 0, 6, clip)
+		if err != nil {
+			t.Fatal("Unexpected error extracting polygon:", err)
+		}
+		// a single tile per zoom level contains the polygon
+		if count != 7 {
+			t.Error("Extracted", count, "tiles, expected 7 for clip:", clip)
+		}
+
+		out, err := Open(dst)
+		if err != nil {
+			t.Fatal("Could not open extracted file:", err)
+		}
+		names := make(map[string]bool)
+		features := 0
+		for z := int64(0); z <= 6; z++ {
+			coord := TileCoordFromLonLat(-123.12, 49.28, z).FlipY()
+			layers, _, err := out.readTileLayers(ctx, coord)
+			if err != nil {
+				t.Error("Could not read extracted tile", coord, err)
+				continue
+			}
+			for _, layer := range layers {
+				features += len(layer.features)
+				for _, feature := range layer.features {
+					for j := 0; j+1 < len(feature.tags); j += 2 {
+						if layer.keys[feature.tags[j]] == "name" {
+							names[layer.values[feature.tags[j+1]].(string)] = true
+						}
+					}
+				}
+			}
+		}
+		out.Close()
+
+		if !names["Vancouver"] {
+			t.Error("Expected Vancouver in extract for clip:", clip)
+		}
+		// only Vancouver is within the polygon, once per zoom level
+		if clip && (len(names) != 1 || features != 7) {
+			t.Error("Expected only Vancouver in clipped extract, got:", features, "features of", names)
+		}
+		if !clip && len(names) <= 1 {
+			t.Error("Expected other cities in tiles of unclipped extract, got:", names)
+		}
+	}
+}
+
+func Test_ExtractPolygon_invalid(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		polygon string
+		minZoom int
+		maxZoom int
+	}{
+		{polygon: vancouverPolygon, minZoom: -1, maxZoom: 6},
+		{polygon: vancouverPolygon, minZoom: 4, maxZoom: 2},
+		{polygon: `{"type": "LineString", "coordinates": [[0, 0], [1, 1]]}`, minZoom: 0, maxZoom: 6},
+		{polygon: `{"type": "Polygon", "coordinates": []}`, minZoom: 0, maxZoom: 6},
+		{polygon: `not json`, minZoom: 0, maxZoom: 6},
+	}
+	for _, tc := range tests {
+		dst := filepath.Join(t.TempDir(), "polygon.mbtiles")
+		if _, err := db.ExtractPolygon(ctx, dst, []byte(tc.polygon), tc.minZoom, tc.maxZoom, false); err == nil {
+			t.Error("Expected error extracting polygon", tc.polygon, tc.minZoom, tc.maxZoom)
+		}
+	}
+
+	png, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer png.Close()
+	dst := filepath.Join(t.TempDir(), "polygon.mbtiles")
+	if _, err := png.ExtractPolygon(ctx, dst, []byte(vancouverPolygon), 0, 1, true); err == nil {
+		t.Error("Expected error clipping image tiles")
+	}
+}
@@ -0,0 +1,36 @@
+package mbtiles
+
+import "context"
+
+// Progress reports the progress of a long running operation, such as an
+// extract, to the callback set with WithProgress.
+type Progress struct {
+	Phase   string // name of the operation or its current step, such as "extract" or "validate"
+	Current int64  // number of tiles processed so far, or bytes read for "ingest"
+	Total   int64  // total number of tiles to process, or 0 if not known
+	Bytes   int64  // total size of tile data processed so far, or 0 if not known
+}
+
+// progressKey is the context key of the progress callback.
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that makes the operations it is passed
+// to call progress as they proceed: ExtractZoom, ExtractWithinBudget, and
+// ExtractGeneralized after each zoom level or tile is copied,
+// ValidateRasterTiles and ValidateVectorTiles after each tile is checked,
+// Ingest as the upload is read and then as it is validated, FillFromAncestors
+// after each tile is written, and SeedFromTileJSON after each tile is fetched.
+// progress is called from a single goroutine at a time.  Operations are
+// canceled with ctx as usual.
+func WithProgress(ctx context.Context, progress func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// progressFunc returns the progress callback of ctx, or a callback that does
+// nothing if ctx does not have one.
+func progressFunc(ctx context.Context) func(Progress) {
+	if progress, ok := ctx.Value(progressKey{}).(func(Progress)); ok && progress != nil {
+		return progress
+	}
+	return func(Progress) {}
+}
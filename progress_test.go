@@ -0,0 +1,65 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WithProgress(t *testing.T) {
+	var updates []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { updates = append(updates, p) })
+	last := func() Progress {
+		if len(updates) == 0 {
+			return Progress{}
+		}
+		return updates[len(updates)-1]
+	}
+
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ValidateRasterTiles(ctx, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if p := last(); p.Phase != "validate" || p.Current != 5 || p.Total != 5 || p.Bytes != 88472 || len(updates) != 5 {
+		t.Error("Validate progress does not match expected values, got:", p, len(updates))
+	}
+
+	updates = nil
+	if _, err := db.ExtractZoom(ctx, filepath.Join(t.TempDir(), "z1.mbtiles"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if p := last(); p.Phase != "extract" || p.Current != 4 || p.Total != 4 {
+		t.Error("Extract progress does not match expected values, got:", p)
+	}
+
+	updates = nil
+	data, err := os.ReadFile("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Ingest(ctx, bytes.NewReader(data), filepath.Join(t.TempDir(), "world.mbtiles"), 0, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	var read int64
+	for _, p := range updates {
+		if p.Phase == "ingest" {
+			read = p.Current
+		}
+	}
+	if read != int64(len(data)) || last().Phase != "validate" {
+		t.Error("Ingest progress does not match expected values, got:", read, last())
+	}
+
+	// without a callback, operations do not report progress
+	updates = nil
+	if _, err := db.ValidateRasterTiles(context.Background(), 1, 1); err != nil || len(updates) != 0 {
+		t.Error("Expected no progress without callback, got:", updates, err)
+	}
+}
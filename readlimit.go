@@ -0,0 +1,141 @@
+package mbtiles
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// readLimitDecrease is the factor by which the limit of AdaptiveReadLimit is
+// reduced after a slow read.
+const readLimitDecrease = 0.75
+
+// AdaptiveReadLimit bounds the number of concurrent tile reads from the
+// mbtiles file by GetTile, ReadTile, and OpenTile, so that a handle under
+// overload, such as from hundreds of goroutines reading tiles at once, queues
+// reads and keeps their latency stable instead of slowing all of them down.
+// The limit starts at maxConcurrent, and is adjusted to the latency of reads
+// from SQLite: it is reduced by a quarter, at most once per targetLatency,
+// after a read slower than targetLatency, and grows by about one after each
+// limit of faster reads, up to maxConcurrent (additive increase,
+// multiplicative decrease).  It is never below 1.  Queued reads wait until
+// their context is done.  Reads served from caches are not limited.
+func AdaptiveReadLimit(maxConcurrent int, targetLatency time.Duration) OpenOption {
+	return func(o *openOptions) {
+		o.readLimit = maxConcurrent
+		o.readLimitLatency = targetLatency
+	}
+}
+
+// readLimiter is the limiter of concurrent reads of AdaptiveReadLimit.  A nil
+// *readLimiter does not limit reads.
+type readLimiter struct {
+	max    float64
+	target time.Duration
+
+	mu           sync.Mutex
+	limit        float64
+	inFlight     int
+	waiting      []chan struct{} // closed in order as reads complete
+	lastDecrease time.Time
+}
+
+// newReadLimiter returns a limiter of up to maxConcurrent reads, or nil if
+// maxConcurrent is less than 1.
+func newReadLimiter(maxConcurrent int, target time.Duration) *readLimiter {
+	if maxConcurrent < 1 {
+		return nil
+	}
+	return &readLimiter{max: float64(maxConcurrent), target: target, limit: float64(maxConcurrent)}
+}
+
+// do calls read once fewer reads than the limit are in progress, and adjusts
+// the limit to its latency.  Returns the error of ctx if it is done before
+// read is called.
+func (l *readLimiter) do(ctx context.Context, read func() error) error {
+	if l == nil {
+		return read()
+	}
+	if err := l.acquire(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := read()
+	l.release(time.Since(start))
+	return err
+}
+
+// acquire waits until a read may start.
+func (l *readLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && len(l.waiting) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	l.waiting = append(l.waiting, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, c := range l.waiting {
+		if c == ready {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// started just as ctx was done, so the slot is handed on
+	l.inFlight--
+	l.startWaiting()
+	return ctx.Err()
+}
+
+// release ends a read that took latency, and starts queued reads within the
+// adjusted limit.
+func (l *readLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if latency > l.target {
+		if now := time.Now(); now.Sub(l.lastDecrease) >= l.target {
+			l.lastDecrease = now
+			l.limit *= readLimitDecrease
+			if l.limit < 1 {
+				l.limit = 1
+			}
+		}
+	} else if l.limit < l.max {
+		l.limit += 1 / l.limit
+		if l.limit > l.max {
+			l.limit = l.max
+		}
+	}
+	l.startWaiting()
+}
+
+// startWaiting starts queued reads, in order, while fewer reads than the
+// limit are in progress.  l.mu must be held.
+func (l *readLimiter) startWaiting() {
+	for len(l.waiting) > 0 && l.inFlight < int(l.limit) {
+		close(l.waiting[0])
+		l.waiting = l.waiting[1:]
+		l.inFlight++
+	}
+}
+
+// current returns the current limit, and the number of queued reads.
+func (l *readLimiter) current() (int, int) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit), len(l.waiting)
+}
@@ -0,0 +1,95 @@
+package mbtiles
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_readLimiter(t *testing.T) {
+	ctx := context.Background()
+	if newReadLimiter(0, time.Second) != nil {
+		t.Error("Expected no limiter without a limit")
+	}
+
+	l := newReadLimiter(4, time.Millisecond)
+	var inFlight, peak int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.do(ctx, func() error {
+				n := atomic.AddInt64(&inFlight, 1)
+				for {
+					p := atomic.LoadInt64(&peak)
+					if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(100 * time.Microsecond)
+				atomic.AddInt64(&inFlight, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if peak > 4 {
+		t.Error("Expected at most 4 concurrent reads, got:", peak)
+	}
+
+	// slow reads reduce the limit, down to 1
+	for i := 0; i < 10; i++ {
+		l.do(ctx, func() error {
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		})
+	}
+	if limit, _ := l.current(); limit != 1 {
+		t.Error("Expected limit to be reduced to 1 after slow reads, got:", limit)
+	}
+	// fast reads raise it again
+	for i := 0; i < 20; i++ {
+		l.do(ctx, func() error { return nil })
+	}
+	if limit, _ := l.current(); limit != 4 {
+		t.Error("Expected limit to grow back to 4 after fast reads, got:", limit)
+	}
+
+	// queued reads stop waiting once their context is done
+	l = newReadLimiter(1, time.Second)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go l.do(ctx, func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	called := false
+	if err := l.do(timeout, func() error { called = true; return nil }); err != context.DeadlineExceeded || called {
+		t.Error("Expected queued read to time out, got:", err, called)
+	}
+	if _, queued := l.current(); queued != 0 {
+		t.Error("Expected no queued reads after timeout, got:", queued)
+	}
+	close(release)
+}
+
+func Test_AdaptiveReadLimit(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles", AdaptiveReadLimit(4, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.GetTile(context.Background(), 0, 0, 0); err != nil {
+		t.Fatal("Could not read tile with read limit:", err)
+	}
+	if stats := db.Stats(); stats.ReadLimit != 4 || stats.ReadsQueued != 0 {
+		t.Error("Read limit stats do not match expected value, got:", stats)
+	}
+}
@@ -0,0 +1,299 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"sort"
+)
+
+// RedactionMode is how the pixels of raster tiles within redaction regions
+// are hidden.
+type RedactionMode uint8
+
+// RedactionMode values
+const (
+	RedactBlank RedactionMode = iota // transparent for PNG tiles, white for JPG tiles
+	RedactBlur                       // blurred with a box blur
+)
+
+// defaultBlurRadius is the radius in pixels of the blur of RedactBlur if
+// Redaction.BlurRadius is 0.
+const defaultBlurRadius = 8
+
+// Redaction configures the regions of raster tiles that are hidden, for
+// deployments of imagery that show sensitive sites.
+type Redaction struct {
+	// Regions are polygons as rings of [longitude, latitude] points in
+	// degrees.  The first ring of each polygon is its exterior, and other
+	// rings are holes, with the opposite winding order as required by
+	// GeoJSON.
+	Regions [][][][2]float64
+	Mode    RedactionMode
+	// BlurRadius is the radius in pixels of the blur of RedactBlur; 8 if 0.
+	// Pixels are blurred with the other pixels of the same tile, so edges
+	// between tiles may be visible.
+	BlurRadius int
+}
+
+// RedactedTiles serves the raster tiles of an mbtiles file with the pixels
+// within redaction regions hidden.  Redacted tiles are kept in an LRU cache;
+// tiles that do not intersect any region are served unchanged.
+type RedactedTiles struct {
+	db        *MBtiles
+	redaction Redaction
+	extents   [][4]float64 // [west, south, east, north] of each region
+	cache     *tileCache
+}
+
+// NewRedactedTiles creates a source of the tiles of db with the regions of
+// redaction hidden, with a cache of up to cacheSize redacted tiles.  Only PNG
+// and JPG tilesets are supported; tiles are encoded in the format of db.
+func NewRedactedTiles(db *MBtiles, redaction Redaction, cacheSize int) (*RedactedTiles, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot redact tiles of closed mbtiles database")
+	}
+	if redaction.Mode != RedactBlank && redaction.Mode != RedactBlur {
+		return nil, fmt.Errorf("invalid redaction mode %d", redaction.Mode)
+	}
+	if redaction.BlurRadius < 0 {
+		return nil, fmt.Errorf("invalid blur radius %d", redaction.BlurRadius)
+	}
+	if err := db.init(context.TODO()); err != nil {
+		return nil, err
+	}
+	if format := db.GetTileFormat(); format != PNG && format != JPG {
+		return nil, fmt.Errorf("redaction is only supported for PNG and JPG tilesets, not %v", format)
+	}
+
+	r := &RedactedTiles{db: db, redaction: redaction, cache: newTileCache(cacheSize)}
+	for _, region := range redaction.Regions {
+		if len(region) == 0 || len(region[0]) < 3 {
+			return nil, errors.New("redaction region must have an exterior ring of at least 3 points")
+		}
+		extent := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+		for _, p := range region[0] {
+			extent[0], extent[1] = math.Min(extent[0], p[0]), math.Min(extent[1], p[1])
+			extent[2], extent[3] = math.Max(extent[2], p[0]), math.Max(extent[3], p[1])
+		}
+		r.extents = append(r.extents, extent)
+	}
+	return r, nil
+}
+
+// GetTile returns the redacted tile for z, x, y (TMS scheme), from the cache
+// if available.  ErrTileNotFound is returned if the tile does not exist.
+func (r *RedactedTiles) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if data, ok := r.cache.get(coord); ok {
+		return data, nil
+	}
+	generation := r.cache.current()
+	data, redacted, err := r.redact(ctx, coord)
+	if err == sql.ErrNoRows {
+		return nil, ErrTileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if redacted {
+		r.cache.add(coord, data, generation)
+	}
+	return data, nil
+}
+
+// Export creates a new mbtiles file at dst with the redacted tiles, so that
+// the hidden pixels are not distributed, and returns the number of tiles
+// written.  dst must not already exist.  Tiles are redacted using up to
+// concurrency goroutines; concurrency less than 1 is treated as 1.  Metadata
+// is copied from the mbtiles file.  dst is removed if the export fails.
+func (r *RedactedTiles) Export(ctx context.Context, dst string, concurrency int) (int64, error) {
+	parameters := map[string]interface{}{"regions": len(r.redaction.Regions)}
+	return compositeTiles(ctx, dst, []*MBtiles{r.db}, nil, "redact", parameters, concurrency, func(coord TileCoord) ([]byte, error) {
+		data, _, err := r.redact(ctx, coord)
+		return data, err
+	})
+}
+
+// redact reads the tile at coord (TMS scheme) and hides its pixels within the
+// redaction regions.  Returns true if the tile intersects any region, or
+// sql.ErrNoRows if the tile does not exist.
+func (r *RedactedTiles) redact(ctx context.Context, coord TileCoord) ([]byte, bool, error) {
+	var data []byte
+	if err := r.db.queryTile(ctx, coord.Z, coord.X, coord.Y, &data); err != nil {
+		return nil, false, err
+	}
+	xyz := coord.FlipY()
+	bounds := tileBounds(xyz)
+	var regions [][][][2]float64
+	for i, extent := range r.extents {
+		if extent[0] < bounds[2] && extent[2] > bounds[0] && extent[1] < bounds[3] && extent[3] > bounds[1] {
+			regions = append(regions, r.redaction.Regions[i])
+		}
+	}
+	if len(regions) == 0 {
+		return data, false, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, &TileError{Coord: coord, Err: err}
+	}
+	b := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Rect, src, b.Min, draw.Src)
+
+	// project the regions to pixels of the tile
+	n := float64(int64(1) << xyz.Z)
+	size := float64(b.Dx())
+	mask := image.NewAlpha(img.Rect)
+	for _, region := range regions {
+		rings := make([][][2]float64, len(region))
+		for i, ring := range region {
+			rings[i] = make([][2]float64, len(ring))
+			for j, p := range ring {
+				lat := math.Max(-maxLatitude, math.Min(maxLatitude, p[1])) * math.Pi / 180
+				mx := (p[0] + 180) / 360 * n
+				my := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * n
+				rings[i][j] = [2]float64{(mx - float64(xyz.X)) * size, (my - float64(xyz.Y)) * size}
+			}
+		}
+		fillPolygon(mask, rings)
+	}
+
+	format := r.db.GetTileFormat()
+	var hidden *image.NRGBA
+	switch {
+	case r.redaction.Mode == RedactBlur:
+		radius := r.redaction.BlurRadius
+		if radius == 0 {
+			radius = defaultBlurRadius
+		}
+		hidden = boxBlur(img, radius)
+	case format == JPG:
+		hidden = image.NewNRGBA(img.Rect)
+		draw.Draw(hidden, hidden.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	default:
+		hidden = image.NewNRGBA(img.Rect)
+	}
+	for i, a := range mask.Pix {
+		if a != 0 {
+			copy(img.Pix[4*i:4*i+4], hidden.Pix[4*i:4*i+4])
+		}
+	}
+
+	if format == JPG {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, false, err
+		}
+		return buf.Bytes(), true, nil
+	}
+	data, err = encodePNG(img)
+	return data, true, err
+}
+
+// boxBlur returns a copy of img blurred with a box blur of radius pixels,
+// applied horizontally and then vertically.  Pixels beyond the edges of img
+// are treated as copies of the nearest edge pixel.
+func boxBlur(img *image.NRGBA, radius int) *image.NRGBA {
+	window := uint32(2*radius + 1)
+	blurPass := func(src *image.NRGBA, vertical bool) *image.NRGBA {
+		dst := image.NewNRGBA(src.Rect)
+		lines, length := src.Rect.Dy(), src.Rect.Dx()
+		if vertical {
+			lines, length = length, lines
+		}
+		for line := 0; line < lines; line++ {
+			// offset of the pixel at position i along the line
+			offset := func(i int) int {
+				i = maxInt(0, minInt(length-1, i))
+				if vertical {
+					return src.PixOffset(line, i)
+				}
+				return src.PixOffset(i, line)
+			}
+			var sum [4]uint32
+			for i := -radius; i <= radius; i++ {
+				o := offset(i)
+				for c := 0; c < 4; c++ {
+					sum[c] += uint32(src.Pix[o+c])
+				}
+			}
+			for i := 0; i < length; i++ {
+				o, out, in := offset(i), offset(i-radius), offset(i+radius+1)
+				for c := 0; c < 4; c++ {
+					dst.Pix[o+c] = uint8((sum[c] + window/2) / window)
+					sum[c] = sum[c] + uint32(src.Pix[in+c]) - uint32(src.Pix[out+c])
+				}
+			}
+		}
+		return dst
+	}
+	return blurPass(blurPass(img, false), true)
+}
+
+// fillPolygon sets the pixels of mask whose centers are inside rings, using
+// the nonzero winding rule so that interior rings, which have the opposite
+// winding order to exterior rings, are holes.
+func fillPolygon(mask *image.Alpha, rings [][][2]float64) {
+	type crossing struct {
+		x       float64
+		winding int
+	}
+	b := mask.Bounds()
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		cy := float64(py) + 0.5
+		var crossings []crossing
+		for _, ring := range rings {
+			for i := range ring {
+				a, c := ring[i], ring[(i+1)%len(ring)]
+				if (a[1] <= cy) == (c[1] <= cy) {
+					continue
+				}
+				winding := 1
+				if c[1] < a[1] {
+					winding = -1
+				}
+				x := a[0] + (cy-a[1])*(c[0]-a[0])/(c[1]-a[1])
+				crossings = append(crossings, crossing{x: x, winding: winding})
+			}
+		}
+		sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+		winding := 0
+		for i := 0; i+1 < len(crossings); i++ {
+			winding += crossings[i].winding
+			if winding == 0 {
+				continue
+			}
+			// pixels whose centers are between the crossings
+			start := int(math.Ceil(crossings[i].x - 0.5))
+			end := int(math.Ceil(crossings[i+1].x - 0.5))
+			for px := maxInt(start, b.Min.X); px < end && px < b.Max.X; px++ {
+				mask.SetAlpha(px, py, color.Alpha{A: 0xff})
+			}
+		}
+	}
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,95 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"testing"
+)
+
+func Test_RedactedTiles(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	region := [][][2]float64{{{10, -30}, {30, -30}, {30, -10}, {10, -10}, {10, -30}}}
+	redacted, err := NewRedactedTiles(db, Redaction{Regions: [][][][2]float64{region}}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := redacted.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Could not read redacted tile:", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(142, 142).RGBA(); a != 0 {
+		t.Error("Expected pixel within region to be blank, got alpha:", a)
+	}
+	original, err := readTileNRGBA(ctx, db, TileCoord{Z: 0, X: 0, Y: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.NRGBAModel.Convert(img.At(20, 20)); c != original.At(20, 20) {
+		t.Error("Expected pixel outside region to be unchanged, got:", c)
+	}
+
+	// tiles that do not intersect a region are not changed
+	expected, err := db.GetTile(ctx, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := redacted.GetTile(ctx, 1, 0, 1); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Expected tile outside region to be unchanged, got:", err)
+	}
+	if _, err := redacted.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "redacted.mbtiles")
+	count, err := redacted.Export(ctx, dst, 2)
+	if err != nil {
+		t.Fatal("Could not export redacted tiles:", err)
+	}
+	if count != 5 {
+		t.Error("Expected 5 exported tiles, got:", count)
+	}
+	exported, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exported.Close()
+	if tile, err := exported.GetTile(ctx, 0, 0, 0); err != nil || !bytes.Equal(tile, data) {
+		t.Error("Expected exported tile to be redacted, got:", err)
+	}
+
+	blurred, err := NewRedactedTiles(db, Redaction{Regions: [][][][2]float64{region}, Mode: RedactBlur}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blurred.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Error("Could not read blurred tile:", err)
+	}
+}
+
+func Test_boxBlur(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	draw.Draw(img, img.Rect, image.NewUniform(color.NRGBA{A: 255}), image.Point{}, draw.Src)
+	img.SetNRGBA(2, 0, color.NRGBA{R: 255, A: 255})
+
+	blurred := boxBlur(img, 1)
+	for x, expected := range []uint8{0, 85, 85, 85, 0} {
+		if c := blurred.NRGBAAt(x, 0); c.R != expected || c.A != 255 {
+			t.Error("Blurred pixel does not match expected value, got:", x, c)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+//go:build render
+
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+// RenderStyle is a simple style used by RenderVectorTile to draw vector tiles
+// as raster images, for clients that cannot render vector tiles.
+type RenderStyle struct {
+	Background color.Color   // nil for a transparent background
+	Layers     []RenderLayer // drawn in order
+}
+
+// RenderLayer styles the features of a layer of vector tiles.  Polygons are
+// filled with Fill and outlined with Line, lines are drawn with Line, and
+// points are drawn as circles with Circle; features are not drawn where the
+// color is nil.
+type RenderLayer struct {
+	SourceLayer  string // name of the vector tile layer; empty for all layers
+	Fill         color.Color
+	Line         color.Color
+	LineWidth    float64 // in pixels; 1 if 0
+	Circle       color.Color
+	CircleRadius float64 // in pixels; 3 if 0
+}
+
+// RenderVectorTile draws the optionally gzipped vector tile data as a PNG
+// image of size by size pixels using style.  Labels are not drawn, and shapes
+// are not anti-aliased.
+//
+// This renderer is only included in builds with the render build tag.
+func RenderVectorTile(data []byte, style RenderStyle, size int) ([]byte, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid image size %d", size)
+	}
+	layers, err := decodeTileMVT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	if style.Background != nil {
+		draw.Draw(img, img.Bounds(), image.NewUniform(style.Background), image.Point{}, draw.Src)
+	}
+	for _, styleLayer := range style.Layers {
+		for _, layer := range layers {
+			if styleLayer.SourceLayer == "" || styleLayer.SourceLayer == layer.name {
+				renderLayer(img, layer, styleLayer)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderTile reads the vector tile for z, x, y (TMS scheme) and draws it as
+// described for RenderVectorTile.  ErrTileNotFound is returned if the tile
+// does not exist.
+//
+// This renderer is only included in builds with the render build tag.
+func (db *MBtiles) RenderTile(ctx context.Context, z int64, x int64, y int64, style RenderStyle, size int) ([]byte, error) {
+	if format := db.GetTileFormat(); format != PBF {
+		return nil, errors.New("only vector tiles can be rendered")
+	}
+	data, err := db.GetTile(ctx, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	return RenderVectorTile(data, style, size)
+}
+
+// RasterTiles renders the vector tiles of an mbtiles file as PNG images on
+// demand, for tile handlers that serve raster tiles of vector tilesets to
+// clients that cannot render vector tiles.  Rendered tiles are kept in an LRU
+// cache, and optionally written back to a companion raster mbtiles file so
+// that they are only rendered once.
+//
+// RasterTiles is only included in builds with the render build tag.
+type RasterTiles struct {
+	db        *MBtiles
+	style     RenderStyle
+	size      int
+	cache     *tileCache
+	companion *MBtiles
+}
+
+// NewRasterTiles creates a renderer of the vector tiles of db using style, as
+// images of size by size pixels, with a cache of up to cacheSize rendered
+// tiles.  If companionPath is not empty, rendered tiles are also written to
+// the raster mbtiles file at companionPath, which is created with the
+// metadata of db if it does not exist, and tiles are read from it before they
+// are rendered.  The companion file is not updated when tiles of db change.
+func NewRasterTiles(db *MBtiles, style RenderStyle, size int, cacheSize int, companionPath string) (*RasterTiles, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot render tiles of closed mbtiles database")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid image size %d", size)
+	}
+	if err := db.init(context.TODO()); err != nil {
+		return nil, err
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return nil, errors.New("only vector tiles can be rendered")
+	}
+
+	r := &RasterTiles{db: db, style: style, size: size, cache: newTileCache(cacheSize)}
+	if companionPath == "" {
+		return r, nil
+	}
+	companion, created, err := openTileStore(companionPath, db.options.traceHook, PNG, uint32(size))
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := writeCompanionMetadata(db, companion); err != nil {
+			companion.Close()
+			return nil, err
+		}
+	}
+	r.companion = companion
+	return r, nil
+}
+
+// writeCompanionMetadata writes the metadata of the raster companion of db.
+func writeCompanionMetadata(db *MBtiles, companion *MBtiles) error {
+	metadata, err := db.GetMetadata()
+	if err != nil {
+		return err
+	}
+	items := map[string]string{
+		"name":        metadata.Name,
+		"description": metadata.Description,
+		"attribution": metadata.Attribution,
+		"type":        metadata.Type,
+		"format":      "png",
+		"minzoom":     strconv.Itoa(metadata.MinZoom),
+		"maxzoom":     strconv.Itoa(metadata.MaxZoom),
+	}
+	if bounds := metadata.Bounds; len(bounds) == 4 {
+		items["bounds"] = fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+	return companion.writeMetadata(context.TODO(), items)
+}
+
+// Tile returns the rendered PNG image of the vector tile for z, x, y (TMS
+// scheme), from the cache or companion file if available.  ErrTileNotFound is
+// returned if the vector tile does not exist.
+func (r *RasterTiles) Tile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if data, ok := r.cache.get(coord); ok {
+		return data, nil
+	}
+	generation := r.cache.current()
+	if r.companion != nil {
+		data, err := r.companion.GetTile(ctx, z, x, y)
+		if err == nil {
+			r.cache.add(coord, data, generation)
+			return data, nil
+		}
+		if !errors.Is(err, ErrTileNotFound) {
+			return nil, err
+		}
+	}
+
+	data, err := r.db.RenderTile(ctx, z, x, y, r.style, r.size)
+	if err != nil {
+		return nil, err
+	}
+	if r.companion != nil {
+		if err := r.companion.insertTile(ctx, z, x, y, data); err != nil {
+			return nil, err
+		}
+	}
+	r.cache.add(coord, data, generation)
+	return data, nil
+}
+
+// Companion returns the companion raster mbtiles file, or nil if rendered
+// tiles are not written back.
+func (r *RasterTiles) Companion() *MBtiles {
+	return r.companion
+}
+
+// Close closes the companion raster mbtiles file, if any.  The vector mbtiles
+// file is not closed.
+func (r *RasterTiles) Close() {
+	if r.companion != nil {
+		r.companion.Close()
+	}
+}
+
+// renderLayer draws the features of layer onto img using style.
+func renderLayer(img *image.NRGBA, layer mvtLayer, style RenderLayer) {
+	scale := float64(img.Bounds().Dx()) / float64(layer.extent)
+	lineWidth := style.LineWidth
+	if lineWidth <= 0 {
+		lineWidth = 1
+	}
+	radius := style.CircleRadius
+	if radius <= 0 {
+		radius = 3
+	}
+
+	for _, feature := range layer.features {
+		var parts [][][2]float64
+		for _, part := range decodeGeometry(feature) {
+			points := make([][2]float64, len(part))
+			for i, p := range part {
+				points[i] = [2]float64{float64(p[0]) * scale, float64(p[1]) * scale}
+			}
+			parts = append(parts, points)
+		}
+
+		switch feature.geomType {
+		case mvtPolygon:
+			if style.Fill != nil {
+				mask := image.NewAlpha(img.Bounds())
+				fillPolygon(mask, parts)
+				drawMask(img, mask, style.Fill)
+			}
+			if style.Line != nil {
+				mask := image.NewAlpha(img.Bounds())
+				for _, ring := range parts {
+					// close the ring
+					strokeLine(mask, append(ring, ring[0]), lineWidth)
+				}
+				drawMask(img, mask, style.Line)
+			}
+		case mvtLineString:
+			if style.Line != nil {
+				mask := image.NewAlpha(img.Bounds())
+				for _, line := range parts {
+					strokeLine(mask, line, lineWidth)
+				}
+				drawMask(img, mask, style.Line)
+			}
+		case mvtPoint:
+			if style.Circle != nil {
+				mask := image.NewAlpha(img.Bounds())
+				for _, point := range parts {
+					fillCircle(mask, point[0], radius)
+				}
+				drawMask(img, mask, style.Circle)
+			}
+		}
+	}
+}
+
+// drawMask draws c over img where mask is set.
+func drawMask(img *image.NRGBA, mask *image.Alpha, c color.Color) {
+	draw.DrawMask(img, img.Bounds(), image.NewUniform(c), image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// strokeLine sets the pixels of mask whose centers are within width / 2 of the
+// line through points.
+func strokeLine(mask *image.Alpha, points [][2]float64, width float64) {
+	half := width / 2
+	b := mask.Bounds()
+	for i := 0; i+1 < len(points); i++ {
+		a, c := points[i], points[i+1]
+		minX := maxInt(int(math.Floor(math.Min(a[0], c[0])-half)), b.Min.X)
+		maxX := minInt(int(math.Ceil(math.Max(a[0], c[0])+half)), b.Max.X-1)
+		minY := maxInt(int(math.Floor(math.Min(a[1], c[1])-half)), b.Min.Y)
+		maxY := minInt(int(math.Ceil(math.Max(a[1], c[1])+half)), b.Max.Y-1)
+		for py := minY; py <= maxY; py++ {
+			for px := minX; px <= maxX; px++ {
+				if segmentDistance(float64(px)+0.5, float64(py)+0.5, a, c) <= half {
+					mask.SetAlpha(px, py, color.Alpha{A: 0xff})
+				}
+			}
+		}
+	}
+}
+
+// fillCircle sets the pixels of mask whose centers are within radius of
+// center.
+func fillCircle(mask *image.Alpha, center [2]float64, radius float64) {
+	b := mask.Bounds()
+	minX := maxInt(int(math.Floor(center[0]-radius)), b.Min.X)
+	maxX := minInt(int(math.Ceil(center[0]+radius)), b.Max.X-1)
+	minY := maxInt(int(math.Floor(center[1]-radius)), b.Min.Y)
+	maxY := minInt(int(math.Ceil(center[1]+radius)), b.Max.Y-1)
+	for py := minY; py <= maxY; py++ {
+		for px := minX; px <= maxX; px++ {
+			if math.Hypot(float64(px)+0.5-center[0], float64(py)+0.5-center[1]) <= radius {
+				mask.SetAlpha(px, py, color.Alpha{A: 0xff})
+			}
+		}
+	}
+}
+
+// segmentDistance returns the distance from x, y to the segment from a to c.
+func segmentDistance(x float64, y float64, a [2]float64, c [2]float64) float64 {
+	dx, dy := c[0]-a[0], c[1]-a[1]
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, ((x-a[0])*dx+(y-a[1])*dy)/length))
+	}
+	return math.Hypot(x-(a[0]+t*dx), y-(a[1]+t*dy))
+}
@@ -0,0 +1,138 @@
+//go:build render
+
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_RenderTile(t *testing.T) {
+	ctx := context.Background()
+	dst := filepath.Join(t.TempDir(), "features.mbtiles")
+	if _, err := TileGeoJSON(ctx, dst, strings.NewReader(testGeoJSON), 0, 0, LayerName("places")); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	style := RenderStyle{
+		Background: color.White,
+		Layers: []RenderLayer{
+			{SourceLayer: "places", Fill: red},
+			{Circle: blue, CircleRadius: 2},
+		},
+	}
+	data, err := db.RenderTile(ctx, 0, 0, 0, style, 256)
+	if err != nil {
+		t.Fatal("Could not render tile:", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 256 {
+		t.Error("Rendered image does not match size, got:", img.Bounds())
+	}
+
+	x, y := func(lon float64) int { return int((lon + 180) / 360 * 256) }, func(lat float64) int {
+		_, fy := lonLatToTileFraction(0, lat, 0)
+		return int(fy * 256)
+	}
+	tests := []struct {
+		lon, lat float64
+		color    color.NRGBA
+	}{
+		{lon: -7.5, lat: -7.5, color: red},                                   // in the park
+		{lon: 0, lat: 0, color: color.NRGBA{R: 255, G: 255, B: 255, A: 255}}, // in the hole of the park
+		{lon: 10, lat: 10, color: blue},                                      // the city
+		{lon: 90, lat: 45, color: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+	for _, tc := range tests {
+		c := color.NRGBAModel.Convert(img.At(x(tc.lon), y(tc.lat))).(color.NRGBA)
+		if c != tc.color {
+			t.Error("Rendered color at", tc.lon, tc.lat, "does not match expected value", tc.color, "got:", c)
+		}
+	}
+
+	if _, err := db.RenderTile(ctx, 1, 1, 1, style, 256); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+}
+
+func Test_RasterTiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "features.mbtiles")
+	if _, err := TileGeoJSON(ctx, dst, strings.NewReader(testGeoJSON), 0, 1, LayerName("places")); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	style := RenderStyle{Layers: []RenderLayer{{Fill: color.Black, Circle: color.Black}}}
+	companionPath := filepath.Join(dir, "raster.mbtiles")
+	r, err := NewRasterTiles(db, style, 128, 4, companionPath)
+	if err != nil {
+		t.Fatal("Could not create raster tiles:", err)
+	}
+	data, err := r.Tile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Could not render tile:", err)
+	}
+	if _, err := r.Tile(ctx, 3, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+	r.Close()
+
+	companion, err := Open(companionPath)
+	if err != nil {
+		t.Fatal("Could not open companion:", err)
+	}
+	defer companion.Close()
+	if format := companion.GetTileFormat(); format != PNG {
+		t.Error("Companion format does not match expected value, got:", format)
+	}
+	if size := companion.GetTileSize(); size != 128 {
+		t.Error("Companion tile size does not match expected value, got:", size)
+	}
+	stored, err := companion.GetTile(ctx, 0, 0, 0)
+	if err != nil || !bytes.Equal(stored, data) {
+		t.Error("Rendered tile was not written to companion:", err)
+	}
+	metadata, err := companion.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Format != "png" || metadata.MaxZoom != 1 {
+		t.Error("Companion metadata does not match expected value, got:", metadata)
+	}
+
+	// tiles in the companion are not rendered again
+	r, err = NewRasterTiles(db, RenderStyle{Background: color.White}, 128, 0, companionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	again, err := r.Tile(ctx, 0, 0, 0)
+	if err != nil || !bytes.Equal(again, data) {
+		t.Error("Expected tile from companion, got:", err)
+	}
+
+	if _, err := NewRasterTiles(companion, style, 128, 0, ""); err == nil {
+		t.Error("Expected error for raster tileset")
+	}
+}
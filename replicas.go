@@ -0,0 +1,100 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Replicas balances reads of a tileset across physical copies of the same
+// mbtiles file, such as one copy per drive, to increase the number of reads
+// per second of very hot tilesets.  Tiles are read from each copy in turn; if
+// a copy fails with an error other than ErrTileNotFound, the tile is read from
+// the other copies before the error is returned.
+//
+// The copies must not be changed while they are replicas, as they would no
+// longer match.
+type Replicas struct {
+	replicas []*MBtiles
+	next     uint64 // index of the replica for the next read, modulo len(replicas)
+}
+
+// OpenReplicas opens the copies of an mbtiles file at paths using the
+// provided options.  The copies must have the same size, tile format, and
+// tile size.
+func OpenReplicas(paths []string, opts ...OpenOption) (*Replicas, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one replica is required")
+	}
+
+	r := &Replicas{}
+	var size int64
+	for i, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		db, err := Open(path, opts...)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		if err := db.init(context.TODO()); err != nil {
+			db.Close()
+			r.Close()
+			return nil, err
+		}
+		r.replicas = append(r.replicas, db)
+
+		if i == 0 {
+			size = stat.Size()
+			continue
+		}
+		first := r.replicas[0]
+		if stat.Size() != size || db.GetTileFormat() != first.GetTileFormat() || db.GetTileSize() != first.GetTileSize() {
+			r.Close()
+			return nil, fmt.Errorf("replica %q does not match %q", path, first.GetFilename())
+		}
+	}
+	return r, nil
+}
+
+// GetTile reads the tile for z, x, y (TMS scheme) from the next replica, as
+// described for MBtiles.GetTile.
+func (r *Replicas) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	start := atomic.AddUint64(&r.next, 1) - 1
+	var firstErr error
+	for i := range r.replicas {
+		db := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		data, err := db.GetTile(ctx, z, x, y)
+		if err == nil || err == ErrTileNotFound || ctx.Err() != nil {
+			return data, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// Primary returns the first replica, for reading metadata and other
+// properties that are the same for all replicas.
+func (r *Replicas) Primary() *MBtiles {
+	return r.replicas[0]
+}
+
+// Replicas returns the handles of all replicas, in the order of the paths
+// they were opened from.
+func (r *Replicas) Replicas() []*MBtiles {
+	return r.replicas
+}
+
+// Close closes all replicas.
+func (r *Replicas) Close() {
+	for _, db := range r.replicas {
+		db.Close()
+	}
+}
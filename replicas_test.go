@@ -0,0 +1,62 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OpenReplicas(t *testing.T) {
+	ctx := context.Background()
+	first := copyTestdata(t, "world_cities.mbtiles")
+	second := filepath.Join(t.TempDir(), "world_cities.mbtiles")
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenReplicas([]string{first, second})
+	if err != nil {
+		t.Fatal("Could not open replicas:", err)
+	}
+	defer r.Close()
+	var queries [2]int
+	for i, db := range r.Replicas() {
+		i := i
+		db.options.traceHook = func(trace QueryTrace) {
+			if trace.SQL == tileQuery {
+				queries[i]++
+			}
+		}
+	}
+
+	expected, err := r.Primary().GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries[0] = 0
+	for i := 0; i < 4; i++ {
+		tile, err := r.GetTile(ctx, 0, 0, 0)
+		if err != nil || !bytes.Equal(tile, expected) {
+			t.Fatal("Tile does not match expected value:", err)
+		}
+	}
+	if queries[0] != 2 || queries[1] != 2 {
+		t.Error("Expected reads to be balanced across replicas, got:", queries)
+	}
+	if _, err := r.GetTile(ctx, 20, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound, got:", err)
+	}
+
+	if _, err := OpenReplicas([]string{first, "./testdata/geography-class-png.mbtiles"}); err == nil {
+		t.Error("Expected error for replicas that do not match")
+	}
+	if _, err := OpenReplicas(nil); err == nil {
+		t.Error("Expected error without replicas")
+	}
+}
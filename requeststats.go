@@ -0,0 +1,233 @@
+package mbtiles
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// TileCount is the number of requests recorded for a tile.
+type TileCount struct {
+	Coord TileCoord
+	Count uint64
+	// Error is the maximum amount by which Count may overestimate the true
+	// number of requests for the tile.
+	Error uint64
+}
+
+// RequestStatsSnapshot is a point in time copy of RequestStats.
+type RequestStatsSnapshot struct {
+	Total    uint64                // total number of requests
+	ByZoom   map[int64]uint64      // number of requests by zoom level
+	ByResult map[TileResult]uint64 // number of requests recorded with RecordResult by result
+	Hot      []TileCount           // most requested tiles, in descending order of count
+}
+
+// RequestStats counts tile requests by zoom level, and tracks the most
+// requested tiles.  It is safe for concurrent use, and is intended to be
+// updated by tile handlers for each tile served.
+//
+// The most requested tiles are tracked using the Space-Saving algorithm in
+// bounded memory, so their counts are approximate: tiles that were requested
+// more than total/capacity times are guaranteed to be tracked.
+type RequestStats struct {
+	mu       sync.Mutex
+	capacity int
+	total    uint64
+	byZoom   map[int64]uint64
+	byResult map[TileResult]uint64
+	counts   map[TileCoord]*heapCount
+	lowest   countHeap // of the tracked tiles, lowest count first
+}
+
+// heapCount is a tracked tile and its position in a countHeap.
+type heapCount struct {
+	TileCount
+	index int
+}
+
+// countHeap is a min-heap of tracked tiles by count, implementing
+// heap.Interface, so that the tile to replace is found in O(log capacity).
+type countHeap []*heapCount
+
+func (h countHeap) Len() int           { return len(h) }
+func (h countHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h countHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *countHeap) Push(x interface{}) {
+	count := x.(*heapCount)
+	count.index = len(*h)
+	*h = append(*h, count)
+}
+
+func (h *countHeap) Pop() interface{} {
+	old := *h
+	count := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return count
+}
+
+// NewRequestStats creates a RequestStats that tracks up to capacity of the
+// most requested tiles.
+func NewRequestStats(capacity int) *RequestStats {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RequestStats{
+		capacity: capacity,
+		byZoom:   make(map[int64]uint64),
+		byResult: make(map[TileResult]uint64),
+		counts:   make(map[TileCoord]*heapCount, capacity),
+		lowest:   make(countHeap, 0, capacity),
+	}
+}
+
+// Record records a request for the tile at coord.  Requests with invalid
+// coordinates are counted in the total, but not by zoom level or tile.
+func (s *RequestStats) Record(coord TileCoord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(coord)
+}
+
+// RecordResult records a request for the tile at coord, with its result, such
+// as from ClassifyTileResult.  Requests with invalid coordinates, or with
+// result ResultInvalidCoords, are counted in the total and by result, but not
+// by zoom level or tile.
+func (s *RequestStats) RecordResult(coord TileCoord, result TileResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byResult[result]++
+	if result == ResultInvalidCoords {
+		s.total++
+		return
+	}
+	s.record(coord)
+}
+
+// record records a request for the tile at coord.  s.mu must be held.
+func (s *RequestStats) record(coord TileCoord) {
+	s.total++
+	if coord.Validate() != nil {
+		// tiles are only tracked if they can be mapped, such as by
+		// HeatmapGeoJSON
+		return
+	}
+	s.byZoom[coord.Z]++
+
+	if count, ok := s.counts[coord]; ok {
+		count.Count++
+		heap.Fix(&s.lowest, count.index)
+		return
+	}
+	if len(s.counts) < s.capacity {
+		count := &heapCount{TileCount: TileCount{Coord: coord, Count: 1}}
+		s.counts[coord] = count
+		heap.Push(&s.lowest, count)
+		return
+	}
+
+	// replace the tile with the lowest count, inheriting its count as error
+	count := s.lowest[0]
+	delete(s.counts, count.Coord)
+	count.TileCount = TileCount{Coord: coord, Count: count.Count + 1, Error: count.Count}
+	s.counts[coord] = count
+	heap.Fix(&s.lowest, 0)
+}
+
+// Snapshot returns a copy of the current statistics, including up to n of the
+// most requested tiles.
+func (s *RequestStats) Snapshot(n int) RequestStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := RequestStatsSnapshot{
+		Total:    s.total,
+		ByZoom:   make(map[int64]uint64, len(s.byZoom)),
+		ByResult: make(map[TileResult]uint64, len(s.byResult)),
+		Hot:      make([]TileCount, 0, len(s.counts)),
+	}
+	for z, count := range s.byZoom {
+		snapshot.ByZoom[z] = count
+	}
+	for result, count := range s.byResult {
+		snapshot.ByResult[result] = count
+	}
+	for _, count := range s.counts {
+		snapshot.Hot = append(snapshot.Hot, count.TileCount)
+	}
+
+	sort.Slice(snapshot.Hot, func(i, j int) bool {
+		a, b := snapshot.Hot[i], snapshot.Hot[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		// break ties in tile order so that snapshots are deterministic
+		if a.Coord.Z != b.Coord.Z {
+			return a.Coord.Z < b.Coord.Z
+		}
+		if a.Coord.X != b.Coord.X {
+			return a.Coord.X < b.Coord.X
+		}
+		return a.Coord.Y < b.Coord.Y
+	})
+	if n >= 0 && len(snapshot.Hot) > n {
+		snapshot.Hot = snapshot.Hot[:n]
+	}
+	return snapshot
+}
+
+// Reset clears all statistics.
+func (s *RequestStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total = 0
+	s.byZoom = make(map[int64]uint64)
+	s.byResult = make(map[TileResult]uint64)
+	s.counts = make(map[TileCoord]*heapCount, s.capacity)
+	s.lowest = make(countHeap, 0, s.capacity)
+}
+
+// HeatmapGeoJSON returns a GeoJSON FeatureCollection with a Polygon feature
+// for the bounds of each of the most requested tiles in the snapshot, so that
+// access counts can be visualized on a map.  Each feature has properties z, x,
+// y (XYZ scheme), count, and error, as described for TileCount, and share,
+// the fraction of all requests for the tile.  The JSON encoding can be tiled
+// into a vector mbtiles file using TileGeoJSON.
+func (s RequestStatsSnapshot) HeatmapGeoJSON() map[string]interface{} {
+	features := make([]interface{}, 0, len(s.Hot))
+	for _, count := range s.Hot {
+		b := tileBounds(count.Coord)
+		var share float64
+		if s.Total > 0 {
+			share = float64(count.Count) / float64(s.Total)
+		}
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type":        "Polygon",
+				"coordinates": [][][]float64{{{b[0], b[1]}, {b[2], b[1]}, {b[2], b[3]}, {b[0], b[3]}, {b[0], b[1]}}},
+			},
+			"properties": map[string]interface{}{
+				"z":     count.Coord.Z,
+				"x":     count.Coord.X,
+				"y":     count.Coord.Y,
+				"count": count.Count,
+				"error": count.Error,
+				"share": share,
+			},
+		})
+	}
+	return map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}
+}
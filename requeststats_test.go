@@ -0,0 +1,153 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func Test_RequestStats(t *testing.T) {
+	stats := NewRequestStats(3)
+
+	hot := TileCoord{Z: 2, X: 1, Y: 1}
+	warm := TileCoord{Z: 1, X: 0, Y: 0}
+	for i := 0; i < 10; i++ {
+		stats.Record(hot)
+	}
+	for i := 0; i < 5; i++ {
+		stats.Record(warm)
+	}
+	// more distinct cold tiles than capacity
+	for x := int64(0); x < 4; x++ {
+		stats.Record(TileCoord{Z: 3, X: x, Y: 0})
+	}
+
+	snapshot := stats.Snapshot(2)
+	if snapshot.Total != 19 {
+		t.Error("Total does not match expected value, got:", snapshot.Total)
+	}
+	expectedByZoom := map[int64]uint64{1: 5, 2: 10, 3: 4}
+	for z, count := range expectedByZoom {
+		if snapshot.ByZoom[z] != count {
+			t.Error("Count for zoom level", z, snapshot.ByZoom[z], "does not match expected value", count)
+		}
+	}
+	if len(snapshot.Hot) != 2 {
+		t.Fatal("Expected 2 hot tiles, got:", snapshot.Hot)
+	}
+	if snapshot.Hot[0].Coord != hot || snapshot.Hot[0].Count != 10 {
+		t.Error("Hottest tile does not match expected value, got:", snapshot.Hot[0])
+	}
+	if snapshot.Hot[1].Coord != warm || snapshot.Hot[1].Count != 5 {
+		t.Error("Second hottest tile does not match expected value, got:", snapshot.Hot[1])
+	}
+
+	// each cold tile replaced the previous one, which had the lowest count
+	snapshot = stats.Snapshot(-1)
+	last := snapshot.Hot[len(snapshot.Hot)-1]
+	if len(snapshot.Hot) != 3 || last.Coord != (TileCoord{Z: 3, X: 3}) || last.Count != 4 || last.Error != 3 {
+		t.Error("Replacement tile does not match expected value, got:", snapshot.Hot)
+	}
+
+	// invalid coordinates are only counted in the total, so that the heatmap
+	// can be created
+	stats.Reset()
+	stats.Record(TileCoord{Z: -1})
+	stats.Record(TileCoord{Z: 64})
+	snapshot = stats.Snapshot(-1)
+	if snapshot.Total != 2 || len(snapshot.ByZoom) != 0 || len(snapshot.Hot) != 0 {
+		t.Error("Expected invalid coordinates to only be counted in total, got:", snapshot)
+	}
+	if features := snapshot.HeatmapGeoJSON()["features"].([]interface{}); len(features) != 0 {
+		t.Error("Expected no heatmap features for invalid coordinates, got:", features)
+	}
+
+	stats.Reset()
+	snapshot = stats.Snapshot(-1)
+	if snapshot.Total != 0 || len(snapshot.ByZoom) != 0 || len(snapshot.Hot) != 0 {
+		t.Error("Statistics were not reset, got:", snapshot)
+	}
+}
+
+func Test_RequestStats_RecordResult(t *testing.T) {
+	stats := NewRequestStats(10)
+	coord := TileCoord{Z: 1, X: 0, Y: 0}
+	stats.RecordResult(coord, ResultHit)
+	stats.RecordResult(coord, ResultHit)
+	stats.RecordResult(coord, ResultMiss)
+	stats.RecordResult(TileCoord{Z: 1, X: 5, Y: 0}, ResultInvalidCoords)
+
+	snapshot := stats.Snapshot(-1)
+	if snapshot.Total != 4 || snapshot.ByZoom[1] != 3 || len(snapshot.Hot) != 1 {
+		t.Error("Snapshot does not match expected value, got:", snapshot)
+	}
+	if snapshot.ByResult[ResultHit] != 2 || snapshot.ByResult[ResultMiss] != 1 || snapshot.ByResult[ResultInvalidCoords] != 1 {
+		t.Error("Counts by result do not match expected values, got:", snapshot.ByResult)
+	}
+
+	data, err := json.Marshal(snapshot.ByResult)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"hit":2,"invalid_coords":1,"miss":1}`; string(data) != expected {
+		t.Error("JSON encoding", string(data), "does not match expected value", expected)
+	}
+}
+
+func Test_RequestStats_concurrent(t *testing.T) {
+	stats := NewRequestStats(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stats.Record(TileCoord{Z: int64(i % 3), X: 0, Y: 0})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if snapshot := stats.Snapshot(-1); snapshot.Total != 800 {
+		t.Error("Total does not match expected value, got:", snapshot.Total)
+	}
+}
+
+func Test_RequestStatsSnapshot_HeatmapGeoJSON(t *testing.T) {
+	stats := NewRequestStats(10)
+	for i := 0; i < 3; i++ {
+		stats.Record(TileCoord{Z: 1, X: 1, Y: 0})
+	}
+	stats.Record(TileCoord{Z: 0, X: 0, Y: 0})
+
+	heatmap := stats.Snapshot(-1).HeatmapGeoJSON()
+	features := heatmap["features"].([]interface{})
+	if heatmap["type"] != "FeatureCollection" || len(features) != 2 {
+		t.Fatal("Heatmap does not match expected values, got:", heatmap)
+	}
+
+	feature := features[0].(map[string]interface{})
+	properties := feature["properties"].(map[string]interface{})
+	if properties["count"] != uint64(3) || properties["share"] != 0.75 || properties["x"] != int64(1) {
+		t.Error("Feature properties do not match expected values, got:", properties)
+	}
+	ring := feature["geometry"].(map[string]interface{})["coordinates"].([][][]float64)[0]
+	if ring[0][0] != 0 || ring[0][1] != 0 || ring[2][0] != 180 || math.Abs(ring[2][1]-maxLatitude) > 1e-9 {
+		t.Error("Feature geometry does not cover tile, got:", ring)
+	}
+
+	// the heatmap can be tiled into a vector tileset
+	data, err := json.Marshal(heatmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := TileGeoJSON(context.Background(), filepath.Join(t.TempDir(), "heatmap.mbtiles"), bytes.NewReader(data), 0, 1)
+	if err != nil || count == 0 {
+		t.Error("Could not tile heatmap:", count, err)
+	}
+}
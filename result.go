@@ -0,0 +1,57 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TileResult is the outcome of a tile request, for metrics and access logs
+// that classify requests without parsing the text of errors.
+type TileResult uint8
+
+// TileResult values
+const (
+	ResultNone          TileResult = iota // not a tile request, or not classified
+	ResultHit                             // the tile was served
+	ResultMiss                            // the tile does not exist or is not served
+	ResultInvalidCoords                   // the tile path or coordinates are invalid
+	ResultBackendError                    // reading the tile failed
+	ResultCanceled                        // the request was canceled or timed out
+)
+
+var tileResultNames = [...]string{"", "hit", "miss", "invalid_coords", "backend_error", "canceled"}
+
+// String returns the name of the result, such as "hit" or "backend_error".
+func (r TileResult) String() string {
+	if int(r) < len(tileResultNames) {
+		return tileResultNames[r]
+	}
+	return fmt.Sprintf("TileResult(%d)", uint8(r))
+}
+
+// MarshalText encodes the result as its name, so that results can be keys of
+// JSON objects.
+func (r TileResult) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ClassifyTileResult returns the result of a tile request that failed with
+// err, or ResultHit if err is nil.  Errors wrapping ErrTileNotFound or
+// ErrZoomNotServed are misses, errors wrapping ErrInvalidTileCoord are
+// invalid coordinates, and errors wrapping context.Canceled or
+// context.DeadlineExceeded are canceled requests; other errors, including
+// ErrUnavailable, are backend errors.
+func ClassifyTileResult(err error) TileResult {
+	switch {
+	case err == nil:
+		return ResultHit
+	case errors.Is(err, ErrTileNotFound), errors.Is(err, ErrZoomNotServed):
+		return ResultMiss
+	case errors.Is(err, ErrInvalidTileCoord):
+		return ResultInvalidCoords
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ResultCanceled
+	}
+	return ResultBackendError
+}
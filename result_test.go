@@ -0,0 +1,31 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_ClassifyTileResult(t *testing.T) {
+	_, _, invalid := ParseTilePath("1/2/0")
+	tests := []struct {
+		err      error
+		expected TileResult
+	}{
+		{nil, ResultHit},
+		{ErrTileNotFound, ResultMiss},
+		{ErrZoomNotServed, ResultMiss},
+		{invalid, ResultInvalidCoords},
+		{TileCoord{Z: 31}.Validate(), ResultInvalidCoords},
+		{fmt.Errorf("reading tile: %w", context.Canceled), ResultCanceled},
+		{context.DeadlineExceeded, ResultCanceled},
+		{fmt.Errorf("%w: disk removed", ErrUnavailable), ResultBackendError},
+		{errors.New("other"), ResultBackendError},
+	}
+	for _, tc := range tests {
+		if result := ClassifyTileResult(tc.err); result != tc.expected {
+			t.Error("Result for", tc.err, "does not match expected value", tc.expected, "got:", result)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// MaintenanceSchedule configures the background maintenance tasks run by
+// RunMaintenance.  Tasks are only run if enabled, so each tileset can enable
+// the tasks that apply to it.
+type MaintenanceSchedule struct {
+	Interval time.Duration // time between runs; must be positive
+	// Jitter is the maximum random delay added to each interval, so that
+	// tilesets scheduled together are not maintained at the same time.
+	Jitter time.Duration
+
+	RefreshMetadata bool // refresh the timestamp and clear cached metadata
+	RecomputeStats  bool // recompute the stats cached by TilesetStats
+	// PruneOlderThan deletes tiles last modified longer ago than this, if
+	// positive, as described for the PruneOlderThan method.
+	PruneOlderThan time.Duration
+	QuickCheck     bool // run SQLite's quick integrity check
+}
+
+// RunMaintenance runs the tasks enabled in schedule after every interval
+// until ctx is canceled, and returns the error of ctx.  Failed tasks do not
+// stop maintenance: their errors are passed to report, if not nil, wrapped
+// with the name of the task, and the remaining tasks of the run continue.
+// Tasks are run in the order refresh, prune, check, stats, so that stats
+// reflect pruned tiles.
+//
+// RunMaintenance blocks, so it is usually called in its own goroutine.
+func (db *MBtiles) RunMaintenance(ctx context.Context, schedule MaintenanceSchedule, report func(error)) error {
+	if db.isClosed() {
+		return errors.New("cannot maintain closed mbtiles database")
+	}
+	if schedule.Interval <= 0 {
+		return errors.New("maintenance interval must be positive")
+	}
+	if report == nil {
+		report = func(error) {}
+	}
+
+	for {
+		delay := schedule.Interval
+		if schedule.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(schedule.Jitter)))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		db.runMaintenance(ctx, schedule, report)
+	}
+}
+
+// runMaintenance runs the tasks enabled in schedule once.
+func (db *MBtiles) runMaintenance(ctx context.Context, schedule MaintenanceSchedule, report func(error)) {
+	if schedule.RefreshMetadata {
+		if _, err := db.RefreshTimestamp(); err != nil {
+			report(fmt.Errorf("refresh: %w", err))
+		}
+		db.InvalidateMetadata()
+	}
+	if schedule.PruneOlderThan > 0 {
+		if _, err := db.PruneOlderThan(ctx, time.Now().Add(-schedule.PruneOlderThan)); err != nil {
+			report(fmt.Errorf("prune: %w", err))
+		}
+	}
+	if schedule.QuickCheck {
+		if err := db.quickCheck(ctx); err != nil {
+			report(fmt.Errorf("quick check: %w", err))
+		}
+	}
+	if schedule.RecomputeStats {
+		db.mu.Lock()
+		db.stats = nil
+		db.mu.Unlock()
+		if _, err := db.TilesetStats(ctx); err != nil {
+			report(fmt.Errorf("stats: %w", err))
+		}
+	}
+}
+
+// quickCheck runs SQLite's quick integrity check, returning an error with the
+// first problem found.
+func (db *MBtiles) quickCheck(ctx context.Context) error {
+	var result string
+	err := db.traced(db.pool).QueryRowContext(ctx, "pragma quick_check(1)").Scan(&result)
+	if err == sql.ErrNoRows || (err == nil && result != "ok") {
+		return fmt.Errorf("mbtiles file failed integrity check: %s", result)
+	}
+	return err
+}
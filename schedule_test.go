@@ -0,0 +1,64 @@
+package mbtiles
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_RunMaintenance(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+	report := func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	}
+
+	schedule := MaintenanceSchedule{
+		Interval:        10 * time.Millisecond,
+		Jitter:          5 * time.Millisecond,
+		RefreshMetadata: true,
+		RecomputeStats:  true,
+		PruneOlderThan:  time.Hour,
+		QuickCheck:      true,
+	}
+	db.runMaintenance(context.Background(), schedule, report)
+	db.mu.RLock()
+	stats := db.stats
+	db.mu.RUnlock()
+	if stats == nil {
+		t.Error("Expected stats to be recomputed")
+	}
+	// world_cities has no tile timestamps, so only pruning fails
+	if len(reported) != 1 || reported[0].Error() != "prune: "+ErrNoTileTimestamps.Error() {
+		t.Error("Expected only pruning to fail, got:", reported)
+	}
+
+	// tasks are run until the context is canceled
+	reported = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	schedule = MaintenanceSchedule{Interval: 10 * time.Millisecond, PruneOlderThan: time.Hour}
+	if err := db.RunMaintenance(ctx, schedule, report); err != context.DeadlineExceeded {
+		t.Error("Expected context error, got:", err)
+	}
+	mu.Lock()
+	if len(reported) < 2 {
+		t.Error("Expected tasks to run repeatedly, got:", reported)
+	}
+	mu.Unlock()
+
+	if err := db.RunMaintenance(context.Background(), MaintenanceSchedule{}, nil); err == nil {
+		t.Error("Expected error for invalid interval")
+	}
+}
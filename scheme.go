@@ -0,0 +1,83 @@
+package mbtiles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tileJSONVersion is the version of the TileJSON specification of documents
+// created by TileJSON.
+const tileJSONVersion = "3.0.0"
+
+// TileScheme is the row numbering of tile URLs: XYZ (origin at top left) as
+// used by most web maps, or TMS (origin at bottom left) as used to store tiles
+// in an mbtiles file.
+type TileScheme uint8
+
+// TileScheme values
+const (
+	XYZScheme TileScheme = iota
+	TMSScheme
+)
+
+// String returns "xyz" or "tms", as used by the scheme item of TileJSON.
+func (s TileScheme) String() string {
+	if s == TMSScheme {
+		return "tms"
+	}
+	return "xyz"
+}
+
+// ParseTileScheme parses "xyz" or "tms", ignoring case, such as the value of a
+// scheme query parameter.  An empty string is parsed as XYZScheme.
+func ParseTileScheme(s string) (TileScheme, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "xyz":
+		return XYZScheme, nil
+	case "tms":
+		return TMSScheme, nil
+	}
+	return XYZScheme, fmt.Errorf("unknown tile scheme %q, must be xyz or tms", s)
+}
+
+// TMSCoord returns the tile coordinates of the request in the TMS scheme, as
+// used by GetTile, converting them from the scheme of the request.
+func (r TileRequest) TMSCoord() TileCoord {
+	if r.Scheme == TMSScheme {
+		return r.Coord
+	}
+	return r.Coord.FlipY()
+}
+
+// TileJSON creates a TileJSON document describing the tileset, from its
+// metadata, with tilesURL as the URL template of its tiles, for example
+// "https://example.com/tiles/{z}/{x}/{y}.png".  The scheme item advertises the
+// row numbering of tilesURL, so that the same tileset can be served to
+// clients under both XYZ and TMS URLs, each with its own TileJSON.  The
+// returned document can be encoded to JSON and served as tilejson.json.
+func (db *MBtiles) TileJSON(tilesURL string, scheme TileScheme) (map[string]interface{}, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot create TileJSON for closed mbtiles database")
+	}
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	tileJSON := map[string]interface{}{
+		"tilejson": tileJSONVersion,
+		"tiles":    []string{tilesURL},
+		"scheme":   scheme.String(),
+	}
+	for _, key := range []string{"name", "description", "attribution", "version", "bounds", "center", "minzoom", "maxzoom", "vector_layers"} {
+		if value, ok := metadata[key]; ok {
+			tileJSON[key] = value
+		}
+	}
+	if format := db.GetTileFormat(); format != UNKNOWN {
+		tileJSON["format"] = format.String()
+	}
+	return tileJSON, nil
+}
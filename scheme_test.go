@@ -0,0 +1,44 @@
+package mbtiles
+
+import (
+	"testing"
+)
+
+func Test_ParseTileScheme(t *testing.T) {
+	tests := map[string]TileScheme{"": XYZScheme, "xyz": XYZScheme, "TMS": TMSScheme}
+	for value, expected := range tests {
+		if scheme, err := ParseTileScheme(value); err != nil || scheme != expected {
+			t.Error("Tile scheme", value, "was not parsed as", expected, "got:", scheme, err)
+		}
+	}
+	if _, err := ParseTileScheme("wmts"); err == nil {
+		t.Error("Unknown tile scheme did not raise error")
+	}
+
+	xyz := TileRequest{Coord: TileCoord{Z: 2, X: 1, Y: 0}}
+	tms := TileRequest{Coord: TileCoord{Z: 2, X: 1, Y: 3}, Scheme: TMSScheme}
+	if xyz.TMSCoord() != tms.Coord || tms.TMSCoord() != tms.Coord {
+		t.Error("TMS coordinates do not match, got:", xyz.TMSCoord(), tms.TMSCoord())
+	}
+}
+
+func Test_TileJSON(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, scheme := range []TileScheme{XYZScheme, TMSScheme} {
+		tileJSON, err := db.TileJSON("https://example.com/"+scheme.String()+"/{z}/{x}/{y}.pbf", scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tileJSON["scheme"] != scheme.String() || tileJSON["format"] != "pbf" || tileJSON["maxzoom"] != 6 {
+			t.Error("TileJSON does not match expected values, got:", tileJSON)
+		}
+		if _, ok := tileJSON["vector_layers"]; !ok {
+			t.Error("TileJSON is missing vector_layers")
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoSearchIndex is returned by Search if the mbtiles file does not have a
+// search index built by BuildSearchIndex.
+var ErrNoSearchIndex = errors.New("mbtiles file does not have a search index")
+
+// ErrNoFeatureIndex is returned by TilesForFeature if the mbtiles file does
+// not have a feature index built by BuildFeatureIndex.
+var ErrNoFeatureIndex = errors.New("mbtiles file does not have a feature index")
+
+// searchTable is the FTS5 table of the search index built by
+// BuildSearchIndex.
+const searchTable = "search_index using fts5(text, layer unindexed, attributes unindexed, zoom_level unindexed, tile_column unindexed, tile_row unindexed)"
+
+// featureTilesTable and featureTilesIndex record the tiles that contain each
+// feature, as built by BuildFeatureIndex.
+const (
+	featureTilesTable = "create table feature_tiles (layer text, feature_id integer, zoom_level integer, tile_column integer, tile_row integer)"
+	featureTilesIndex = "create index feature_tiles_index on feature_tiles (layer, feature_id)"
+)
+
+// SearchResult is a feature found by Search.
+type SearchResult struct {
+	Layer      string
+	Attributes map[string]interface{} // indexed attributes of the feature
+	Coord      TileCoord              // tile that contains the feature (TMS scheme)
+}
+
+// BuildSearchIndex extracts the given attributes, such as place names, from
+// the features of the vector tiles at zoom into a full-text search table
+// inside the mbtiles file, replacing any existing index, and returns the
+// number of features indexed.  This allows features to be found with Search
+// without a separate geocoding database, for example in offline map packs.
+// The mbtiles file must be writable.
+//
+// Features that have none of the attributes are not indexed.  Features that
+// are present in more than one tile, such as features that cross tile
+// boundaries, are indexed once, for the first tile in which they are found:
+// features are identified by their layer and ID, or by their layer and
+// indexed attributes if they do not have an ID.  Zoom is typically the
+// maximum zoom level, which has the most features.
+func (db *MBtiles) BuildSearchIndex(ctx context.Context, attributes []string, zoom int) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot build search index in closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return 0, fmt.Errorf("cannot build search index for tile format %v", format)
+	}
+	if len(attributes) == 0 {
+		return 0, errors.New("at least one attribute must be indexed")
+	}
+
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := db.traced(tx)
+
+	if _, err := q.ExecContext(ctx, "drop table if exists search_index"); err != nil {
+		return 0, err
+	}
+	if _, err := q.ExecContext(ctx, "create virtual table "+searchTable); err != nil {
+		return 0, err
+	}
+	insertQuery := "insert into search_index (text, layer, attributes, zoom_level, tile_column, tile_row) values (?, ?, ?, ?, ?, ?)"
+	prepared, err := tx.PrepareContext(ctx, insertQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer prepared.Close()
+	insert := db.tracedStmt(prepared, insertQuery)
+
+	rows, err := q.QueryContext(ctx, "select tile_column, tile_row, tile_data from tiles where zoom_level = ? order by tile_column, tile_row", zoom)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	seen := make(map[string]bool)
+	for rows.Next() {
+		coord := TileCoord{Z: int64(zoom)}
+		var data []byte
+		if err := rows.Scan(&coord.X, &coord.Y, &data); err != nil {
+			return 0, err
+		}
+		features, err := searchFeatures(data, attributes)
+		if err != nil {
+			return 0, &TileError{Coord: coord, Err: err}
+		}
+
+		for _, feature := range features {
+			if seen[feature.key] {
+				continue
+			}
+			seen[feature.key] = true
+			if _, err := insert.ExecContext(ctx, feature.text, feature.layer, feature.attributes, coord.Z, coord.X, coord.Y); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	params := map[string]interface{}{"attributes": attributes, "zoom": zoom}
+	if err := db.recordHistory(ctx, q, "build_search_index", params); err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+// Search returns the features indexed by BuildSearchIndex whose attributes
+// match query, best matches first.  query uses the SQLite FTS5 query syntax,
+// for example "new york" for features with both words, or "ber*" for words
+// starting with ber.  ErrNoSearchIndex is returned if the index has not been
+// built.
+func (db *MBtiles) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot search closed mbtiles database")
+	}
+	q := db.traced(db.pool)
+
+	var count int
+	if err := q.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'search_index'").Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrNoSearchIndex
+	}
+
+	rows, err := q.QueryContext(ctx, "select layer, attributes, zoom_level, tile_column, tile_row from search_index where search_index match ? order by rank", query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search for %q: %w", query, err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var (
+			result     SearchResult
+			attributes string
+		)
+		if err := rows.Scan(&result.Layer, &attributes, &result.Coord.Z, &result.Coord.X, &result.Coord.Y); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(attributes), &result.Attributes); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// searchFeature is a feature of a vector tile to be indexed by
+// BuildSearchIndex.
+type searchFeature struct {
+	key        string // identifies the feature across tiles
+	layer      string
+	text       string // indexed attribute values, separated by spaces
+	attributes string // indexed attributes, encoded as JSON
+}
+
+// searchFeatures decodes the features of the optionally gzipped vector tile
+// data that have any of attributes.
+func searchFeatures(data []byte, attributes []string) ([]searchFeature, error) {
+	layers, err := decodeTileMVT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		indexed[attribute] = true
+	}
+
+	var features []searchFeature
+	for _, layer := range layers {
+		for _, feature := range layer.features {
+			values := make(map[string]interface{})
+			var text []string
+			for i := 0; i+1 < len(feature.tags); i += 2 {
+				k, v := feature.tags[i], feature.tags[i+1]
+				if int(k) >= len(layer.keys) || int(v) >= len(layer.values) || !indexed[layer.keys[k]] {
+					continue
+				}
+				values[layer.keys[k]] = layer.values[v]
+				text = append(text, fmt.Sprint(layer.values[v]))
+			}
+			if len(values) == 0 {
+				continue
+			}
+
+			encoded, err := json.Marshal(values)
+			if err != nil {
+				return nil, err
+			}
+			key := layer.name + "\x00" + string(encoded)
+			if feature.hasID {
+				key = fmt.Sprintf("%s\x00%d", layer.name, feature.id)
+			}
+			features = append(features, searchFeature{
+				key:        key,
+				layer:      layer.name,
+				text:       strings.Join(text, " "),
+				attributes: string(encoded),
+			})
+		}
+	}
+	return features, nil
+}
+
+// BuildFeatureIndex records the tiles at all zoom levels that contain each
+// vector tile feature with an ID in a table of the mbtiles file, replacing any
+// existing index, and returns the number of features found in tiles.  This
+// allows TilesForFeature to find the tiles affected when a feature is edited.
+// Features without IDs are not indexed.  The mbtiles file must be writable.
+func (db *MBtiles) BuildFeatureIndex(ctx context.Context) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot build feature index in closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return 0, fmt.Errorf("cannot build feature index for tile format %v", format)
+	}
+
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := db.traced(tx)
+
+	for _, query := range []string{"drop table if exists feature_tiles", featureTilesTable} {
+		if _, err := q.ExecContext(ctx, query); err != nil {
+			return 0, err
+		}
+	}
+	insertQuery := "insert into feature_tiles (layer, feature_id, zoom_level, tile_column, tile_row) values (?, ?, ?, ?, ?)"
+	prepared, err := tx.PrepareContext(ctx, insertQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer prepared.Close()
+	insert := db.tracedStmt(prepared, insertQuery)
+
+	rows, err := q.QueryContext(ctx, "select zoom_level, tile_column, tile_row, tile_data from tiles")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var (
+			coord TileCoord
+			data  []byte
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &data); err != nil {
+			return 0, err
+		}
+		layers, err := decodeTileMVT(data)
+		if err != nil {
+			return 0, &TileError{Coord: coord, Err: err}
+		}
+		for _, layer := range layers {
+			for _, feature := range layer.features {
+				if !feature.hasID {
+					continue
+				}
+				// IDs are stored as signed integers by SQLite
+				if _, err := insert.ExecContext(ctx, layer.name, int64(feature.id), coord.Z, coord.X, coord.Y); err != nil {
+					return 0, err
+				}
+				count++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, err := q.ExecContext(ctx, featureTilesIndex); err != nil {
+		return 0, err
+	}
+	if err := db.recordHistory(ctx, q, "build_feature_index", nil); err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+// TilesForFeature returns the coordinates (TMS scheme) of the tiles that
+// contain the feature with id in layer, ordered by zoom level, column, and
+// row, from the index built by BuildFeatureIndex, so that exactly the tiles
+// affected by an edit of the feature can be regenerated or purged from caches.
+// ErrNoFeatureIndex is returned if the index has not been built.
+func (db *MBtiles) TilesForFeature(ctx context.Context, layer string, id uint64) ([]TileCoord, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read feature index from closed mbtiles database")
+	}
+	q := db.traced(db.pool)
+
+	var count int
+	if err := q.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'feature_tiles'").Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrNoFeatureIndex
+	}
+
+	rows, err := q.QueryContext(ctx, "select distinct zoom_level, tile_column, tile_row from feature_tiles where layer = ? and feature_id = ? order by zoom_level, tile_column, tile_row", layer, int64(id))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coords []TileCoord
+	for rows.Next() {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			return nil, err
+		}
+		coords = append(coords, coord)
+	}
+	return coords, rows.Err()
+}
+
+// decodeTileMVT decodes the layers of the optionally gzipped vector tile data.
+func decodeTileMVT(data []byte) ([]mvtLayer, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		var err error
+		if data, err = gunzip(data); err != nil {
+			return nil, fmt.Errorf("cannot decompress tile: %w", err)
+		}
+	}
+	layers, err := decodeMVT(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse tile: %w", err)
+	}
+	return layers, nil
+}
@@ -0,0 +1,99 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Search(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.Search(ctx, "berlin"); err != ErrNoSearchIndex {
+		t.Error("Expected ErrNoSearchIndex before building index, got:", err)
+	}
+
+	count, err := db.BuildSearchIndex(ctx, []string{"name"}, 6)
+	if err != nil {
+		t.Fatal("Could not build search index:", err)
+	}
+	if count != 68 {
+		t.Error("Expected 68 features to be indexed, got:", count)
+	}
+
+	results, err := db.Search(ctx, "berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Layer != "cities" || results[0].Attributes["name"] != "Berlin" {
+		t.Fatal("Search results do not match expected values, got:", results)
+	}
+	// Berlin is at 13.4E 52.5N, near the edge of the tile that contains it,
+	// so it is also in the buffer of the tile below
+	expected := TileCoordFromLonLat(13.4, 52.5, 6).FlipY()
+	if coord := results[0].Coord; coord.Z != expected.Z || coord.X != expected.X || coord.Y < expected.Y-1 || coord.Y > expected.Y {
+		t.Error("Search result tile", coord, "does not match expected value", expected)
+	}
+
+	if results, err := db.Search(ctx, "new*"); err != nil || len(results) < 2 {
+		t.Error("Expected prefix search to match several cities, got:", results, err)
+	}
+
+	// rebuilding replaces the index
+	if count, err := db.BuildSearchIndex(ctx, []string{"name"}, 6); err != nil || count != 68 {
+		t.Error("Could not rebuild search index:", count, err)
+	}
+
+	if _, err := db.Search(ctx, `"unbalanced`); err == nil {
+		t.Error("Invalid query did not raise error")
+	}
+}
+
+func Test_TilesForFeature(t *testing.T) {
+	ctx := context.Background()
+	dst := filepath.Join(t.TempDir(), "features.mbtiles")
+	if _, err := TileGeoJSON(ctx, dst, strings.NewReader(testGeoJSON), 0, 2, LayerName("places")); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.TilesForFeature(ctx, "places", 1); err != ErrNoFeatureIndex {
+		t.Error("Expected ErrNoFeatureIndex before building index, got:", err)
+	}
+	if _, err := db.BuildFeatureIndex(ctx); err != nil {
+		t.Fatal("Could not build feature index:", err)
+	}
+
+	// the point at 10E 10N
+	coords, err := db.TilesForFeature(ctx, "places", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TileCoord{{Z: 0, X: 0, Y: 0}, {Z: 1, X: 1, Y: 1}, {Z: 2, X: 2, Y: 2}}
+	if len(coords) != len(expected) {
+		t.Fatal("Tiles for feature do not match expected value, got:", coords)
+	}
+	for i := range expected {
+		if coords[i] != expected[i] {
+			t.Error("Tiles for feature do not match expected value, got:", coords)
+		}
+	}
+
+	// the road crosses the prime meridian, so it is in 2 tiles at zooms 1 and 2
+	if coords, err := db.TilesForFeature(ctx, "places", 2); err != nil || len(coords) != 5 {
+		t.Error("Expected 5 tiles for road, got:", coords, err)
+	}
+	if coords, err := db.TilesForFeature(ctx, "places", 99); err != nil || len(coords) != 0 {
+		t.Error("Expected no tiles for unknown feature, got:", coords, err)
+	}
+}
@@ -0,0 +1,462 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SeedOptions configures SeedFromTileJSON.
+type SeedOptions struct {
+	Client *http.Client // http.DefaultClient if nil
+	// Zooms limits the zoom levels seeded to those also advertised by the
+	// TileJSON.  TileJSON documents without maxzoom default to zoom level 22,
+	// so Zooms is usually set to keep the tileset to a practical size.
+	Zooms *ZoomLimit
+	// Bounds limits the area seeded to its intersection with the bounds of
+	// the TileJSON: [west, south, east, north] in degrees.
+	Bounds      []float64
+	Concurrency int // number of tiles requested at a time; 1 if less than 1
+}
+
+// remoteTileJSON holds the items of a TileJSON document used for seeding.
+type remoteTileJSON struct {
+	Tiles        []string        `json:"tiles"`
+	Scheme       string          `json:"scheme"`
+	MinZoom      *int            `json:"minzoom"`
+	MaxZoom      *int            `json:"maxzoom"`
+	Bounds       []float64       `json:"bounds"`
+	Center       []float64       `json:"center"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Attribution  string          `json:"attribution"`
+	Version      string          `json:"version"`
+	Format       string          `json:"format"`
+	VectorLayers json.RawMessage `json:"vector_layers"`
+}
+
+// seedResult is a tile fetched by a worker of SeedFromTileJSON.
+type seedResult struct {
+	coord TileCoord
+	data  []byte // nil if the server does not have the tile
+	err   error
+}
+
+// SeedFromTileJSON creates a new mbtiles file at dst, which must not already
+// exist, with the tiles of the remote tileset described by the TileJSON at
+// url, such as to mirror a public tileset for offline use, and returns the
+// number of tiles written.  The bounds, zoom levels, and scheme of the tile
+// URLs are read from the TileJSON, optionally limited by opts, and its name,
+// description, attribution, version, center, and vector layers are written to
+// the metadata.
+//
+// The tile format is read from the format item of the TileJSON, or from the
+// extension of the tile URLs, or else detected from the first tile.  Tiles
+// that the server responds to with 404 Not Found or 204 No Content are
+// skipped.  Requests for tiles are spread over the tile URLs of the TileJSON.
+//
+// Tiles are written to dst with PartialExtension added, which is renamed to
+// dst once all tiles are seeded.  The tiles of each column of a zoom level are
+// committed together with a checkpoint of the column, so if seeding fails or
+// is interrupted, calling SeedFromTileJSON again with the same url and opts
+// resumes it: columns already seeded are skipped.
+func SeedFromTileJSON(ctx context.Context, url string, dst string, opts SeedOptions) (int64, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	tileJSON, err := fetchTileJSON(ctx, client, url)
+	if err != nil {
+		return 0, err
+	}
+	if len(tileJSON.Tiles) == 0 {
+		return 0, errors.New("TileJSON does not have tile URLs")
+	}
+	scheme, err := ParseTileScheme(tileJSON.Scheme)
+	if err != nil {
+		return 0, err
+	}
+
+	minZoom, maxZoom := 0, 22
+	if tileJSON.MinZoom != nil {
+		minZoom = *tileJSON.MinZoom
+	}
+	if tileJSON.MaxZoom != nil {
+		maxZoom = *tileJSON.MaxZoom
+	}
+	if opts.Zooms != nil {
+		if opts.Zooms.MinZoom > minZoom {
+			minZoom = opts.Zooms.MinZoom
+		}
+		if opts.Zooms.MaxZoom < maxZoom {
+			maxZoom = opts.Zooms.MaxZoom
+		}
+	}
+	if minZoom < 0 || maxZoom > MaxZoomLevel || minZoom > maxZoom {
+		return 0, fmt.Errorf("invalid zoom range %d-%d", minZoom, maxZoom)
+	}
+
+	bounds := []float64{-180, -maxLatitude, 180, maxLatitude}
+	if tileJSON.Bounds != nil {
+		if err := validateBounds(tileJSON.Bounds); err != nil {
+			return 0, fmt.Errorf("invalid TileJSON bounds: %w", err)
+		}
+		bounds = intersectBounds(bounds, tileJSON.Bounds)
+	}
+	if opts.Bounds != nil {
+		if err := validateBounds(opts.Bounds); err != nil {
+			return 0, err
+		}
+		bounds = intersectBounds(bounds, opts.Bounds)
+	}
+	if bounds[0] > bounds[2] || bounds[1] > bounds[3] {
+		return 0, errors.New("bounds do not intersect the bounds of the TileJSON")
+	}
+
+	format := TileFormatFromExtension(tileJSON.Format)
+	if format == UNKNOWN {
+		template := strings.SplitN(tileJSON.Tiles[0], "?", 2)[0]
+		format = TileFormatFromExtension(path.Ext(template))
+	}
+
+	metadata := map[string]string{
+		"minzoom": strconv.Itoa(minZoom),
+		"maxzoom": strconv.Itoa(maxZoom),
+		"bounds":  fmt.Sprintf("%f,%f,%f,%f", bounds[0], bounds[1], bounds[2], bounds[3]),
+	}
+	for name, value := range map[string]string{
+		"name":        tileJSON.Name,
+		"description": tileJSON.Description,
+		"attribution": tileJSON.Attribution,
+		"version":     tileJSON.Version,
+	} {
+		if value != "" {
+			metadata[name] = value
+		}
+	}
+	if len(tileJSON.Center) == 3 {
+		metadata["center"] = fmt.Sprintf("%f,%f,%d", tileJSON.Center[0], tileJSON.Center[1], int(tileJSON.Center[2]))
+	}
+	if len(tileJSON.VectorLayers) > 0 && string(tileJSON.VectorLayers) != "null" {
+		layerJSON, err := json.Marshal(map[string]json.RawMessage{"vector_layers": tileJSON.VectorLayers})
+		if err != nil {
+			return 0, err
+		}
+		metadata["json"] = string(layerJSON)
+	}
+	if format != UNKNOWN {
+		metadata["format"] = format.String()
+	}
+
+	sources := make([]*HTTPTileSource, len(tileJSON.Tiles))
+	for i, template := range tileJSON.Tiles {
+		sources[i] = &HTTPTileSource{URL: template, Client: client, Scheme: scheme}
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		return 0, fmt.Errorf("destination already exists: %q", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	partial := dst + PartialExtension
+	count, err := seedTiles(ctx, partial, metadata, format, sources, bounds, minZoom, maxZoom, opts.Concurrency)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// seedBatch is a batch of tiles seeded by SeedFromTileJSON: the tiles of a
+// column within the seeded bounds at a zoom level.
+type seedBatch struct {
+	Z int64
+	X int64
+}
+
+// seedTiles seeds the tiles of sources within bounds into the mbtiles file at
+// partial, as described for SeedFromTileJSON, and returns the number of tiles
+// in it.  The tiles of each batch are committed along with a record of the
+// batch in the seed_batches table, which is dropped once all batches are
+// seeded.  Batches already recorded are skipped.
+func seedTiles(ctx context.Context, partial string, metadata map[string]string, format TileFormat, sources []*HTTPTileSource, bounds []float64, minZoom int, maxZoom int, concurrency int) (int64, error) {
+	// range of tiles within bounds at each zoom level, computed before any
+	// tiles are fetched so that the seed fails if one cannot be
+	ranges := make(map[int64][2]TileCoord)
+	for z := int64(minZoom); z <= int64(maxZoom); z++ {
+		topLeft, bottomRight, err := tileRange(bounds, z)
+		if err != nil {
+			return 0, err
+		}
+		ranges[z] = [2]TileCoord{topLeft, bottomRight}
+	}
+
+	pool, err := sql.Open("sqlite", partial)
+	if err != nil {
+		return 0, err
+	}
+	defer pool.Close()
+
+	seeded, err := openSeedPartial(ctx, pool, metadata, &format)
+	if err != nil {
+		return 0, err
+	}
+
+	// number of rows of each batch at each zoom level
+	rows := make(map[int64]int64)
+	progress := progressFunc(ctx)
+	status := Progress{Phase: "seed"}
+	for z, r := range ranges {
+		topLeft, bottomRight := r[0], r[1]
+		rows[z] = bottomRight.Y - topLeft.Y + 1
+		status.Total += (bottomRight.X - topLeft.X + 1) * rows[z]
+	}
+	for batch := range seeded {
+		status.Current += rows[batch.Z]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pending := make(chan TileCoord)
+	results := make(chan seedResult)
+	defer func() {
+		// stop the workers, and wait for them to finish
+		cancel()
+		for range results {
+		}
+	}()
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		source := sources[w%len(sources)]
+		go func() {
+			defer wg.Done()
+			for coord := range pending {
+				data, err := source.GetTile(ctx, coord.Z, coord.X, coord.Y)
+				if err == ErrTileNotFound {
+					data, err = nil, nil
+				}
+				select {
+				case results <- seedResult{coord: coord, data: data, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(pending)
+		for z := int64(minZoom); z <= int64(maxZoom); z++ {
+			topLeft, bottomRight := ranges[z][0], ranges[z][1]
+			for x := topLeft.X; x <= bottomRight.X; x++ {
+				if seeded[seedBatch{Z: z, X: x}] {
+					continue
+				}
+				for y := topLeft.Y; y <= bottomRight.Y; y++ {
+					select {
+					case pending <- (TileCoord{Z: z, X: x, Y: y}).FlipY():
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// tx is replaced as batches are committed
+	defer func() { tx.Rollback() }()
+
+	// number of tiles not yet fetched of each batch that is in progress
+	remaining := make(map[seedBatch]int64)
+	for result := range results {
+		if result.err != nil {
+			return 0, result.err
+		}
+		status.Current++
+		status.Bytes += int64(len(result.data))
+		progress(status)
+		if result.data != nil {
+			data, err := seedTileData(ctx, tx, &format, result.data)
+			if err != nil {
+				return 0, err
+			}
+			// tiles of a batch that was not recorded may have been committed
+			// along with other batches before seeding was interrupted
+			_, err = tx.ExecContext(ctx, "insert or replace into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", result.coord.Z, result.coord.X, result.coord.Y, data)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		batch := seedBatch{Z: result.coord.Z, X: result.coord.X}
+		if _, ok := remaining[batch]; !ok {
+			remaining[batch] = rows[batch.Z]
+		}
+		if remaining[batch]--; remaining[batch] > 0 {
+			continue
+		}
+		delete(remaining, batch)
+		if _, err := tx.ExecContext(ctx, "insert into seed_batches (zoom_level, tile_column) values (?, ?)", batch.Z, batch.X); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+		if tx, err = pool.BeginTx(ctx, nil); err != nil {
+			return 0, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "drop table seed_batches"); err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := tx.QueryRowContext(ctx, "select count(*) from tiles").Scan(&count); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, pool.Close()
+}
+
+// openSeedPartial creates the mbtiles file of pool for SeedFromTileJSON, or
+// resumes it if it has a seed_batches table, and writes metadata to it.  If
+// *format is UNKNOWN, it is read from the metadata of a resumed file.  Returns
+// the batches already seeded.
+func openSeedPartial(ctx context.Context, pool *sql.DB, metadata map[string]string, format *TileFormat) (map[seedBatch]bool, error) {
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, "select count(*) from sqlite_master where type = 'table' and name = 'seed_batches'").Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		for _, query := range append(schemaStatements(""), "create table seed_batches (zoom_level integer not null, tile_column integer not null, primary key (zoom_level, tile_column))") {
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for name, value := range metadata {
+		if _, err := tx.ExecContext(ctx, "insert or replace into metadata (name, value) values (?, ?)", name, value); err != nil {
+			return nil, err
+		}
+	}
+	if *format == UNKNOWN {
+		var value string
+		err := tx.QueryRowContext(ctx, "select value from metadata where name = 'format'").Scan(&value)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		*format = TileFormatFromExtension(value)
+	}
+
+	rows, err := tx.QueryContext(ctx, "select zoom_level, tile_column from seed_batches")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	seeded := make(map[seedBatch]bool)
+	for rows.Next() {
+		var batch seedBatch
+		if err := rows.Scan(&batch.Z, &batch.X); err != nil {
+			return nil, err
+		}
+		seeded[batch] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return seeded, tx.Commit()
+}
+
+// seedTileData returns the data of a tile fetched by SeedFromTileJSON as it
+// is stored in the mbtiles file.  If *format is UNKNOWN, it is detected from
+// data and written to the metadata.  Vector tiles are gzip-compressed if the
+// server sent them uncompressed, or the HTTP client decompressed them.
+func seedTileData(ctx context.Context, q querier, format *TileFormat, data []byte) ([]byte, error) {
+	if *format == UNKNOWN {
+		detected, err := ParseTileFormat(data)
+		if err != nil {
+			return nil, err
+		}
+		if detected == GZIP {
+			detected = PBF
+		}
+		if _, err := q.ExecContext(ctx, "insert into metadata (name, value) values ('format', ?)", detected.String()); err != nil {
+			return nil, err
+		}
+		*format = detected
+	}
+	if *format == PBF {
+		if detected, _ := ParseTileFormat(data); detected != GZIP {
+			return gzipBytes(data)
+		}
+	}
+	return data, nil
+}
+
+// fetchTileJSON requests and decodes the TileJSON document at url.
+func fetchTileJSON(ctx context.Context, client *http.Client, url string) (*remoteTileJSON, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status for TileJSON: %s", resp.Status)
+	}
+
+	var tileJSON remoteTileJSON
+	if err := json.NewDecoder(resp.Body).Decode(&tileJSON); err != nil {
+		return nil, fmt.Errorf("cannot parse TileJSON: %w", err)
+	}
+	return &tileJSON, nil
+}
+
+// intersectBounds returns the intersection of bounds a and b, which is empty,
+// with west > east or south > north, if they do not intersect.
+func intersectBounds(a []float64, b []float64) []float64 {
+	return []float64{
+		math.Max(a[0], b[0]),
+		math.Max(a[1], b[1]),
+		math.Min(a[2], b[2]),
+		math.Min(a[3], b[3]),
+	}
+}
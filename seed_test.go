@@ -0,0 +1,190 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func Test_SeedFromTileJSON(t *testing.T) {
+	ctx := context.Background()
+	remote, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tilejson.json" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tilejson": "3.0.0",
+				"name":     "Geography Class",
+				"scheme":   "tms",
+				"tiles":    []string{server.URL + "/tiles/{z}/{x}/{y}"},
+				"minzoom":  0,
+				"maxzoom":  8,
+			})
+			return
+		}
+		var z, x, y int64
+		fmt.Sscanf(r.URL.Path, "/tiles/%d/%d/%d", &z, &x, &y)
+		data, err := remote.GetTile(r.Context(), z, x, y)
+		if err == ErrTileNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	dst := filepath.Join(t.TempDir(), "mirror.mbtiles")
+	var status Progress
+	count, err := SeedFromTileJSON(WithProgress(ctx, func(p Progress) { status = p }), server.URL+"/tilejson.json", dst, SeedOptions{
+		Zooms:       &ZoomLimit{MinZoom: 0, MaxZoom: 2},
+		Concurrency: 4,
+	})
+	if err != nil {
+		t.Fatal("Could not seed tileset:", err)
+	}
+	// zoom level 2 is not in the remote tileset
+	if count != 5 {
+		t.Error("Expected 5 tiles, got:", count)
+	}
+	if status.Phase != "seed" || status.Current != 21 || status.Total != 21 || status.Bytes == 0 {
+		t.Error("Progress does not match expected values, got:", status)
+	}
+
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.GetTileFormat() != PNG {
+		t.Error("Expected PNG tiles, got:", db.GetTileFormat())
+	}
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["name"] != "Geography Class" || metadata["maxzoom"] != 2 || metadata["format"] != "png" {
+		t.Error("Metadata does not match expected values, got:", metadata)
+	}
+
+	expected, err := remote.GetTile(ctx, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := db.GetTile(ctx, 1, 0, 1); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Tile does not match remote tile:", err)
+	}
+
+	if _, err := SeedFromTileJSON(ctx, server.URL+"/tilejson.json", dst, SeedOptions{}); err == nil {
+		t.Error("Expected error for existing destination")
+	}
+}
+
+func Test_SeedFromTileJSON_resume(t *testing.T) {
+	ctx := context.Background()
+	remote, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	// the number of tile requests of each run, as late requests of a failed
+	// run may still be served
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests = make(map[string]int)
+	)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tilejson.json" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tilejson": "3.0.0",
+				"scheme":   "tms",
+				"tiles":    []string{server.URL + "/tiles/{z}/{x}/{y}?run=" + r.URL.Query().Get("run")},
+				"maxzoom":  2,
+			})
+			return
+		}
+		run := r.URL.Query().Get("run")
+		mu.Lock()
+		requests[run]++
+		mu.Unlock()
+		var z, x, y int64
+		fmt.Sscanf(r.URL.Path, "/tiles/%d/%d/%d", &z, &x, &y)
+		if run == "1" && z == 1 && x == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		data, err := remote.GetTile(r.Context(), z, x, y)
+		if err == ErrTileNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	// tiles are requested in order with a concurrency of 1, so the columns
+	// of zoom level 0 and column 0 of zoom level 1 are seeded before the
+	// failure
+	dst := filepath.Join(t.TempDir(), "mirror.mbtiles")
+	if _, err := SeedFromTileJSON(ctx, server.URL+"/tilejson.json?run=1", dst, SeedOptions{}); err == nil {
+		t.Fatal("Expected error for failed tile request")
+	}
+	if _, err := os.Stat(dst + PartialExtension); err != nil {
+		t.Fatal("Expected partial file to be kept:", err)
+	}
+
+	count, err := SeedFromTileJSON(ctx, server.URL+"/tilejson.json?run=2", dst, SeedOptions{})
+	if err != nil {
+		t.Fatal("Could not resume seeding:", err)
+	}
+	// zoom level 2 is not in the remote tileset
+	mu.Lock()
+	resumed := requests["2"]
+	mu.Unlock()
+	if count != 5 || resumed != 2+16 {
+		t.Error("Expected 5 tiles from 18 requests, got:", count, resumed)
+	}
+	if _, err := os.Stat(dst + PartialExtension); !os.IsNotExist(err) {
+		t.Error("Expected partial file to be renamed, got:", err)
+	}
+
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.GetTileFormat() != PNG {
+		t.Error("Expected PNG tiles, got:", db.GetTileFormat())
+	}
+	var tables int
+	if err := db.pool.QueryRow("select count(*) from sqlite_master where name = 'seed_batches'").Scan(&tables); err != nil || tables != 0 {
+		t.Error("Expected seed_batches table to be dropped, got:", tables, err)
+	}
+}
+
+func Test_seedTiles_invalid_bounds(t *testing.T) {
+	partial := filepath.Join(t.TempDir(), "seeded.mbtiles"+PartialExtension)
+	source := &HTTPTileSource{URL: "http://localhost/{z}/{x}/{y}.png"}
+
+	count, err := seedTiles(context.Background(), partial, nil, PNG, []*HTTPTileSource{source}, []float64{10, 0, 0, 10}, 0, 1, 1)
+	if err == nil {
+		t.Error("Expected error for invalid bounds, got count:", count)
+	}
+	if _, err := os.Stat(partial); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected no partial file to be created, got:", err)
+	}
+}
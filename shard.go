@@ -0,0 +1,114 @@
+package mbtiles
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// defaultVirtualNodes is the number of points of each shard on a ShardRing if
+// the number is not set.
+const defaultVirtualNodes = 100
+
+// ShardRing maps tiles to the backend shards of a tileset that is split
+// across machines, using consistent hashing, so that routers send requests
+// for each tile to the same shards, and adding or removing a shard only
+// moves the tiles of about 1/n of the ring.
+//
+// Each shard is placed at virtualNodes points on the ring, at the hashes of
+// "shard#i" for i from 0, and each tile at the hash of its XYZ scheme
+// coordinates as "z/x/y".  Keys are hashed with 64-bit FNV-1a followed by the
+// 64-bit finalizer of MurmurHash3 to spread similar keys, so routers in other
+// languages can compute the same mapping.  A tile belongs to the shards of the first points
+// at or after its hash.
+type ShardRing struct {
+	points []ringPoint // in order of hash
+	shards int
+}
+
+// ringPoint is a point of a shard on a ShardRing.
+type ringPoint struct {
+	hash  uint64
+	shard string
+}
+
+// NewShardRing creates a ring of the named shards, each placed at
+// virtualNodes points; 100 if virtualNodes is 0.  More points spread tiles
+// more evenly between shards.
+func NewShardRing(shards []string, virtualNodes int) (*ShardRing, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("shard ring requires at least one shard")
+	}
+	if virtualNodes < 0 {
+		return nil, fmt.Errorf("invalid number of virtual nodes %d", virtualNodes)
+	}
+	if virtualNodes == 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+
+	r := &ShardRing{shards: len(shards)}
+	seen := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		if seen[shard] {
+			return nil, fmt.Errorf("duplicate shard %q", shard)
+		}
+		seen[shard] = true
+		for i := 0; i < virtualNodes; i++ {
+			r.points = append(r.points, ringPoint{hash: ringHash(shard + "#" + strconv.Itoa(i)), shard: shard})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		a, b := r.points[i], r.points[j]
+		if a.hash != b.hash {
+			return a.hash < b.hash
+		}
+		return a.shard < b.shard
+	})
+	return r, nil
+}
+
+// Shard returns the shard of the XYZ scheme tile at coord.
+func (r *ShardRing) Shard(coord TileCoord) string {
+	return r.Shards(coord, 1)[0]
+}
+
+// Shards returns replicas distinct shards for the XYZ scheme tile at coord,
+// starting with the shard returned by Shard, for tilesets that store each
+// tile on several shards.  Requests can be balanced between these shards, or
+// sent to the next one when a shard fails.  All shards are returned if
+// replicas is greater than the number of shards.
+func (r *ShardRing) Shards(coord TileCoord, replicas int) []string {
+	if replicas > r.shards {
+		replicas = r.shards
+	}
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	hash := ringHash(coord.String())
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= hash })
+	shards := make([]string, 0, replicas)
+	for i := 0; len(shards) < replicas; i++ {
+		shard := r.points[(start+i)%len(r.points)].shard
+		if !containsString(shards, shard) {
+			shards = append(shards, shard)
+		}
+	}
+	return shards
+}
+
+// ringHash returns the 64-bit FNV-1a hash of key, mixed with the finalizer
+// of MurmurHash3.
+func ringHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	v := h.Sum64()
+	v ^= v >> 33
+	v *= 0xff51afd7ed558ccd
+	v ^= v >> 33
+	v *= 0xc4ceb9fe1a85ec53
+	v ^= v >> 33
+	return v
+}
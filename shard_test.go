@@ -0,0 +1,53 @@
+package mbtiles
+
+import "testing"
+
+func Test_ShardRing(t *testing.T) {
+	ring, err := NewShardRing([]string{"a", "b", "c"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smaller, err := NewShardRing([]string{"a", "b"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	var moved int
+	var total int
+	for x := int64(0); x < 64; x++ {
+		for y := int64(0); y < 64; y++ {
+			coord := TileCoord{Z: 6, X: x, Y: y}
+			shard := ring.Shard(coord)
+			counts[shard]++
+			total++
+			// only tiles of the removed shard move
+			if other := smaller.Shard(coord); other != shard {
+				moved++
+				if shard != "c" {
+					t.Fatal("Tile", coord, "moved from remaining shard", shard, "to", other)
+				}
+			}
+
+			shards := ring.Shards(coord, 2)
+			if len(shards) != 2 || shards[0] != shard || shards[1] == shard {
+				t.Fatal("Replica shards do not match expected value, got:", shards)
+			}
+		}
+	}
+	for shard, count := range counts {
+		if count < total/4 || count > total/2 {
+			t.Error("Tiles are not spread evenly, got:", shard, count)
+		}
+	}
+	if moved != counts["c"] {
+		t.Error("Expected only tiles of removed shard to move, got:", moved)
+	}
+
+	if shards := ring.Shards(TileCoord{}, 5); len(shards) != 3 {
+		t.Error("Expected all shards, got:", shards)
+	}
+	if _, err := NewShardRing([]string{"a", "a"}, 10); err == nil {
+		t.Error("Expected error for duplicate shards")
+	}
+}
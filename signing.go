@@ -0,0 +1,81 @@
+package mbtiles
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Query parameters added to signed tile URLs.
+const (
+	SignatureExpiresParam = "expires"
+	SignatureParam        = "signature"
+)
+
+var (
+	// ErrSignatureMissing is returned by VerifyTileURL if the URL is not signed.
+	ErrSignatureMissing = errors.New("tile URL is not signed")
+	// ErrSignatureInvalid is returned by VerifyTileURL if the signature does
+	// not match the URL.
+	ErrSignatureInvalid = errors.New("tile URL signature is invalid")
+	// ErrSignatureExpired is returned by VerifyTileURL if the signature has
+	// expired.
+	ErrSignatureExpired = errors.New("tile URL signature has expired")
+)
+
+// SignTileURL signs a tile URL with an HMAC-SHA256 of its path and expiry
+// time, using key.  The expiry time and signature are added to the URL as
+// query parameters.  Other query parameters and the host are not signed.
+func SignTileURL(key []byte, rawURL string, expires time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	expiresValue := strconv.FormatInt(expires.Unix(), 10)
+	query := u.Query()
+	query.Set(SignatureExpiresParam, expiresValue)
+	query.Set(SignatureParam, signPath(key, u.EscapedPath(), expiresValue))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// VerifyTileURL verifies that a tile URL was signed by SignTileURL using key,
+// and that the signature has not expired by now.
+func VerifyTileURL(key []byte, u *url.URL, now time.Time) error {
+	query := u.Query()
+	expiresValue := query.Get(SignatureExpiresParam)
+	signature := query.Get(SignatureParam)
+	if expiresValue == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+
+	expected := signPath(key, u.EscapedPath(), expiresValue)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrSignatureInvalid
+	}
+
+	expires, err := strconv.ParseInt(expiresValue, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if now.Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// signPath returns the URL-safe base64 encoded HMAC-SHA256 of path and
+// expires.
+func signPath(key []byte, path string, expires string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
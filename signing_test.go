@@ -0,0 +1,56 @@
+package mbtiles
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_SignTileURL(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1600000000, 0)
+
+	signed, err := SignTileURL(key, "https://example.com/tiles/world/1/0/0.png?style=dark", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal("Unexpected error signing URL:", err)
+	}
+
+	u, _ := url.Parse(signed)
+	if u.Query().Get("style") != "dark" {
+		t.Error("Signed URL did not preserve existing query parameters:", signed)
+	}
+	if err := VerifyTileURL(key, u, now); err != nil {
+		t.Error("Signed URL did not verify:", signed, err)
+	}
+
+	// signature for a different tile
+	otherPath, _ := url.Parse(signed)
+	otherPath.Path = "/tiles/world/1/1/0.png"
+
+	// extended expiry time
+	extended, _ := url.Parse(signed)
+	query := extended.Query()
+	query.Set(SignatureExpiresParam, "1700000000")
+	extended.RawQuery = query.Encode()
+
+	tests := []struct {
+		name string
+		key  []byte
+		url  string
+		now  time.Time
+		err  error
+	}{
+		{name: "expired", key: key, url: signed, now: now.Add(2 * time.Hour), err: ErrSignatureExpired},
+		{name: "wrong key", key: []byte("other"), url: signed, now: now, err: ErrSignatureInvalid},
+		{name: "unsigned", key: key, url: "https://example.com/tiles/world/1/0/0.png", now: now, err: ErrSignatureMissing},
+		{name: "different path", key: key, url: otherPath.String(), now: now, err: ErrSignatureInvalid},
+		{name: "changed expiry", key: key, url: extended.String(), now: now, err: ErrSignatureInvalid},
+	}
+
+	for _, tc := range tests {
+		u, _ := url.Parse(tc.url)
+		if err := VerifyTileURL(tc.key, u, tc.now); err != tc.err {
+			t.Error("VerifyTileURL did not return expected error for:", tc.name, "got:", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ErrTileTooLarge is returned, wrapped with the size of the tile, by reads of
+// tiles larger than the limit set with MaxTileSize.
+var ErrTileTooLarge = errors.New("tile exceeds maximum size")
+
+// MaxTileSize limits the size of tiles read by GetTile and ReadTile to limit
+// bytes, so that pathological tiles, such as 100 MB tiles from a faulty
+// export, do not exhaust the memory of constrained servers.  Larger tiles are
+// not read into memory, and their reads fail with an error wrapping
+// ErrTileTooLarge.  OpenTile and ServeTile read tiles in chunks, so they are
+// not limited.
+func MaxTileSize(limit int64) OpenOption {
+	return func(o *openOptions) {
+		o.maxTileSize = limit
+	}
+}
+
+// limitedTileQuery reads a tile only if it is within a size limit, and the
+// size of the tile.
+const limitedTileQuery = "select case when length(tile_data) <= ? then tile_data end, length(tile_data) from tiles where zoom_level = ? and tile_column = ? and tile_row = ?"
+
+// queryLimitedTile reads a tile for z, x, y into data as queryTile does, or
+// returns an error wrapping ErrTileTooLarge if it is larger than limit bytes.
+func (db *MBtiles) queryLimitedTile(ctx context.Context, z int64, x int64, y int64, data *[]byte, limit int64) error {
+	var (
+		tile []byte
+		size sql.NullInt64
+	)
+	if err := db.traced(db.pool).QueryRowContext(ctx, limitedTileQuery, limit, z, x, y).Scan(&tile, &size); err != nil {
+		return err
+	}
+	if size.Int64 > limit {
+		return fmt.Errorf("%w: tile %d/%d/%d is %d bytes", ErrTileTooLarge, z, x, y, size.Int64)
+	}
+	if err := db.resolveBlob(ctx, &tile); err != nil {
+		return err
+	}
+	if int64(len(tile)) > limit {
+		return fmt.Errorf("%w: tile %d/%d/%d is %d bytes", ErrTileTooLarge, z, x, y, len(tile))
+	}
+	*data = tile
+	return nil
+}
+
+// TileSize is the size of the data of a tile.
+type TileSize struct {
+	Coord TileCoord // tile coordinates (TMS scheme)
+	Bytes int64
+}
+
+// OversizedTiles returns the tiles with data larger than limit bytes, largest
+// first.  Large tiles are a common cause of slow map loads, particularly for
+// vector tiles; 500 KB is a typical limit.
+func (db *MBtiles) OversizedTiles(ctx context.Context, limit int) ([]TileSize, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read tile sizes from closed mbtiles database")
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx,
+		"select zoom_level, tile_column, tile_row, length(tile_data) from tiles where length(tile_data) > ? order by length(tile_data) desc, zoom_level, tile_column, tile_row",
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tiles []TileSize
+	for rows.Next() {
+		var tile TileSize
+		if err := rows.Scan(&tile.Coord.Z, &tile.Coord.X, &tile.Coord.Y, &tile.Bytes); err != nil {
+			return nil, err
+		}
+		tiles = append(tiles, tile)
+	}
+	return tiles, rows.Err()
+}
+
+// TileSizeHistogram counts tiles by size at each zoom level.  It can be
+// marshaled as JSON, or written as CSV using WriteCSV.
+type TileSizeHistogram struct {
+	// Buckets are the upper bounds (inclusive) of tile sizes in bytes for each
+	// bucket, in increasing order.  A final bucket counts tiles larger than the
+	// last bound.
+	Buckets []int64         `json:"buckets"`
+	Zooms   []ZoomHistogram `json:"zooms"`
+}
+
+// ZoomHistogram counts tiles by size at a zoom level.
+type ZoomHistogram struct {
+	Zoom   int     `json:"zoom"`
+	Counts []int64 `json:"counts"` // one more count than TileSizeHistogram.Buckets
+}
+
+// SizeHistogram counts tiles by size at each zoom level, using buckets as the
+// upper bounds (inclusive) of tile sizes in bytes, which must be in increasing
+// order.
+func (db *MBtiles) SizeHistogram(ctx context.Context, buckets []int64) (*TileSizeHistogram, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read tile sizes from closed mbtiles database")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return nil, errors.New("histogram buckets must be in increasing order")
+		}
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx, "select zoom_level, length(tile_data) from tiles order by zoom_level")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histogram := &TileSizeHistogram{Buckets: buckets, Zooms: []ZoomHistogram{}}
+	for rows.Next() {
+		var (
+			z    int
+			size int64
+		)
+		if err := rows.Scan(&z, &size); err != nil {
+			return nil, err
+		}
+		zooms := histogram.Zooms
+		if len(zooms) == 0 || zooms[len(zooms)-1].Zoom != z {
+			histogram.Zooms = append(zooms, ZoomHistogram{Zoom: z, Counts: make([]int64, len(buckets)+1)})
+		}
+		bucket := sort.Search(len(buckets), func(i int) bool { return size <= buckets[i] })
+		histogram.Zooms[len(histogram.Zooms)-1].Counts[bucket]++
+	}
+	return histogram, rows.Err()
+}
+
+// WriteCSV writes the histogram as CSV, with a header row of bucket labels
+// followed by a row of counts for each zoom level.
+func (h *TileSizeHistogram) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, 0, len(h.Buckets)+2)
+	header = append(header, "zoom")
+	for _, bound := range h.Buckets {
+		header = append(header, "<="+strconv.FormatInt(bound, 10))
+	}
+	if len(h.Buckets) > 0 {
+		header = append(header, ">"+strconv.FormatInt(h.Buckets[len(h.Buckets)-1], 10))
+	} else {
+		header = append(header, "all")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, zoom := range h.Zooms {
+		record := make([]string, 0, len(zoom.Counts)+1)
+		record = append(record, strconv.Itoa(zoom.Zoom))
+		for _, count := range zoom.Counts {
+			record = append(record, strconv.FormatInt(count, 10))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
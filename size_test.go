@@ -0,0 +1,129 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_OversizedTiles(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tiles, err := db.OversizedTiles(context.Background(), 20156)
+	if err != nil {
+		t.Fatal("Unexpected error finding oversized tiles:", err)
+	}
+	expected := []TileSize{
+		{Coord: TileCoord{Z: 0, X: 0, Y: 0}, Bytes: 21246},
+		{Coord: TileCoord{Z: 1, X: 0, Y: 1}, Bytes: 21130},
+	}
+	if len(tiles) != len(expected) {
+		t.Fatal("Oversized tiles do not match expected values, got:", tiles)
+	}
+	for i, tile := range tiles {
+		if tile != expected[i] {
+			t.Error("Oversized tile", tile, "does not match expected value", expected[i])
+		}
+	}
+
+	tiles, err = db.OversizedTiles(context.Background(), 1<<20)
+	if err != nil || len(tiles) != 0 {
+		t.Error("Expected no tiles larger than 1 MB, got:", tiles, err)
+	}
+}
+
+func Test_SizeHistogram(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	histogram, err := db.SizeHistogram(context.Background(), []int64{15000, 21130})
+	if err != nil {
+		t.Fatal("Unexpected error creating histogram:", err)
+	}
+	expected := []ZoomHistogram{
+		{Zoom: 0, Counts: []int64{0, 0, 1}},
+		{Zoom: 1, Counts: []int64{2, 2, 0}},
+	}
+	if !reflect.DeepEqual(histogram.Zooms, expected) {
+		t.Error("Histogram does not match expected value, got:", histogram.Zooms)
+	}
+
+	var buf bytes.Buffer
+	if err := histogram.WriteCSV(&buf); err != nil {
+		t.Fatal("Unexpected error writing CSV:", err)
+	}
+	expectedCSV := "zoom,<=15000,<=21130,>21130\n0,0,0,1\n1,2,2,0\n"
+	if buf.String() != expectedCSV {
+		t.Error("CSV does not match expected value, got:", buf.String())
+	}
+
+	encoded, err := json.Marshal(histogram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedJSON := `{"buckets":[15000,21130],"zooms":[{"zoom":0,"counts":[0,0,1]},{"zoom":1,"counts":[2,2,0]}]}`
+	if string(encoded) != expectedJSON {
+		t.Error("JSON does not match expected value, got:", string(encoded))
+	}
+
+	if _, err := db.SizeHistogram(context.Background(), []int64{100, 10}); err == nil {
+		t.Error("Expected error for buckets out of order")
+	}
+}
+
+func Test_MaxTileSize(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expected, err := db.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited, err := Open("./testdata/geography-class-png.mbtiles", MaxTileSize(int64(len(expected))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer limited.Close()
+	if data, err := limited.GetTile(ctx, 0, 0, 0); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Expected tile within limit to be read, got:", err)
+	}
+	if _, err := limited.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+
+	small, err := Open("./testdata/geography-class-png.mbtiles", MaxTileSize(int64(len(expected)-1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer small.Close()
+	if _, err := small.GetTile(ctx, 0, 0, 0); !errors.Is(err, ErrTileTooLarge) {
+		t.Error("Expected ErrTileTooLarge for tile above limit, got:", err)
+	}
+
+	// tiles above the limit are not prefetched into the cache
+	cached, err := Open("./testdata/geography-class-png.mbtiles", MaxTileSize(int64(len(expected)-1)), CacheTiles(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cached.Close()
+	if count, err := cached.Prefetch(ctx, TileCoord{}, 0, 0); err != nil || count != 0 {
+		t.Error("Expected tile above limit not to be prefetched, got:", count, err)
+	}
+	if _, err := cached.GetTile(ctx, 0, 0, 0); !errors.Is(err, ErrTileTooLarge) {
+		t.Error("Expected ErrTileTooLarge for prefetched tile above limit, got:", err)
+	}
+}
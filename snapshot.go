@@ -0,0 +1,49 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+)
+
+// Snapshot copies the mbtiles file to a new file at dst, which must not
+// already exist.  The copy is consistent as of the start of the snapshot, even
+// if the mbtiles file is being written by another process, and tiles can
+// continue to be read while it is made.
+//
+// The copy is made using SQLite's VACUUM INTO statement, as the database
+// driver does not expose SQLite's online backup API; it is also compacted,
+// so it may be smaller than the original file.  The snapshot is written to a
+// temporary file that is only renamed to dst once it is complete, and removed
+// if the snapshot fails.  Tile transforms are not supported (see
+// WithTileTransform).
+func (db *MBtiles) Snapshot(ctx context.Context, dst string) error {
+	if db.isClosed() {
+		return errors.New("cannot snapshot closed mbtiles database")
+	}
+	if tileTransform(ctx) != nil {
+		return errTransformNotSupported
+	}
+	return publishFile(dst, func(tmp string) error {
+		_, err := db.traced(db.pool).ExecContext(ctx, "vacuum into ?", tmp)
+		return err
+	})
+}
+
+// Publish copies the mbtiles file to dst as described for Snapshot, replacing
+// any existing file at dst atomically, so that readers opening dst see either
+// the previous file or the complete copy.  Handles already open on a replaced
+// file continue to read the previous file until they are closed.  This
+// publishes a tileset that is written in place, such as with UpdateMetadata or
+// an Overlay, to the path it is served from.
+func (db *MBtiles) Publish(ctx context.Context, dst string) error {
+	if db.isClosed() {
+		return errors.New("cannot publish closed mbtiles database")
+	}
+	if tileTransform(ctx) != nil {
+		return errTransformNotSupported
+	}
+	return replaceFile(dst, func(tmp string) error {
+		_, err := db.traced(db.pool).ExecContext(ctx, "vacuum into ?", tmp)
+		return err
+	})
+}
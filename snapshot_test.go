@@ -0,0 +1,88 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Snapshot(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dst := filepath.Join(t.TempDir(), "snapshot.mbtiles")
+	if err := db.Snapshot(context.Background(), dst); err != nil {
+		t.Fatal("Unexpected error creating snapshot:", err)
+	}
+
+	snapshot, err := Open(dst)
+	if err != nil {
+		t.Fatal("Could not open snapshot:", err)
+	}
+	defer snapshot.Close()
+
+	var expected, actual []byte
+	if err := db.ReadTile(1, 1, 1, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshot.ReadTile(1, 1, 1, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if expected == nil || !bytes.Equal(expected, actual) {
+		t.Error("Snapshot tile does not match original tile")
+	}
+
+	if err := db.Snapshot(context.Background(), dst); err == nil {
+		t.Error("Expected error creating snapshot at existing destination")
+	}
+}
+
+func Test_Publish(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dst := copyTestdata(t, "world_cities.mbtiles")
+	previous, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer previous.Close()
+
+	if err := db.Publish(ctx, dst); err != nil {
+		t.Fatal("Unexpected error publishing:", err)
+	}
+	published, err := Open(dst)
+	if err != nil {
+		t.Fatal("Could not open published file:", err)
+	}
+	defer published.Close()
+	if published.GetTileFormat() != PNG {
+		t.Error("Expected published file to replace destination, got:", published.GetTileFormat())
+	}
+	if _, err := previous.GetTile(ctx, 6, 10, 40); err != nil && err != ErrTileNotFound {
+		t.Error("Expected open handle to read previous file, got:", err)
+	}
+	if previous.GetTileFormat() != PBF {
+		t.Error("Expected open handle to keep previous format, got:", previous.GetTileFormat())
+	}
+
+	// failed extracts leave no files behind
+	dir := t.TempDir()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := db.ExtractZoom(canceled, filepath.Join(dir, "z1.mbtiles"), 1); err == nil {
+		t.Error("Expected error extracting with canceled context")
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Error("Expected no files after failed extract, got:", entries, err)
+	}
+}
@@ -0,0 +1,84 @@
+package mbtiles
+
+import (
+	"strings"
+)
+
+const (
+	// stacVersion is the version of the STAC specification of collections
+	// created by STACCollections.
+	stacVersion = "1.0.0"
+	// stacWebMapLinks is the schema of the STAC web-map-links extension, which
+	// defines links to XYZ tiles and TileJSON.
+	stacWebMapLinks = "https://stac-extensions.github.io/web-map-links/v1.1.0/schema.json"
+)
+
+// STACCollections creates a SpatioTemporal Asset Catalog (STAC) Collection for
+// each of the tilesets found by DiscoverTilesets, for indexing tilesets with
+// STAC tooling.  The returned collections can be encoded to JSON, and are
+// ordered by ID as described for Catalog.  They are typically served as the
+// children of a root STAC Catalog.
+//
+// Each collection has a spatial extent from the bounds of the tileset (or the
+// whole world if not available), an open temporal extent, and links to the
+// tiles and TileJSON of the tileset as defined by the web-map-links extension.
+// tilesURL is the URL template of the tiles, for example
+// "https://example.com/services/{id}/tiles/{z}/{x}/{y}.{format}", in which
+// "{id}" is replaced by the tileset ID and "{format}" by the tile format, and
+// tileJSONURL is the URL template of the TileJSON, in which "{id}" is
+// replaced; it is omitted if empty.
+func STACCollections(base string, tilesets []TilesetInfo, tilesURL string, tileJSONURL string) ([]map[string]interface{}, error) {
+	ids, byID, err := catalogIDs(base, tilesets)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		tileset := byID[id]
+
+		bounds := tileset.Bounds
+		if validateBounds(bounds) != nil {
+			bounds = []float64{-180, -maxLatitude, 180, maxLatitude}
+		}
+		title := tileset.Name
+		if title == "" {
+			title = id
+		}
+
+		links := []map[string]interface{}{{
+			"rel":   "xyz",
+			"href":  strings.NewReplacer("{id}", id, "{format}", tileset.Format.String()).Replace(tilesURL),
+			"type":  tileset.Format.MimeType(),
+			"title": title,
+		}}
+		if tileJSONURL != "" {
+			links = append(links, map[string]interface{}{
+				"rel":   "tilejson",
+				"href":  strings.ReplaceAll(tileJSONURL, "{id}", id),
+				"type":  "application/json",
+				"title": title,
+			})
+		}
+
+		collections = append(collections, map[string]interface{}{
+			"type":            "Collection",
+			"stac_version":    stacVersion,
+			"stac_extensions": []string{stacWebMapLinks},
+			"id":              id,
+			"title":           title,
+			"description":     title,
+			"license":         "other",
+			"extent": map[string]interface{}{
+				"spatial":  map[string]interface{}{"bbox": [][]float64{bounds}},
+				"temporal": map[string]interface{}{"interval": [][]interface{}{{nil, nil}}},
+			},
+			"summaries": map[string]interface{}{
+				"minzoom": tileset.MinZoom,
+				"maxzoom": tileset.MaxZoom,
+			},
+			"links": links,
+		})
+	}
+	return collections, nil
+}
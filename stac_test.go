@@ -0,0 +1,51 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_STACCollections(t *testing.T) {
+	tilesets, err := DiscoverTilesets("./testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err := STACCollections("testdata", tilesets, "https://example.com/services/{id}/tiles/{z}/{x}/{y}.{format}", "https://example.com/services/{id}")
+	if err != nil {
+		t.Fatal("Could not create STAC collections:", err)
+	}
+
+	var collection map[string]interface{}
+	for _, c := range collections {
+		if c["id"] == "world_cities" {
+			collection = c
+		}
+	}
+	if collection == nil {
+		t.Fatal("STAC collections do not include world_cities")
+	}
+
+	if collection["type"] != "Collection" || collection["stac_version"] != stacVersion || collection["title"] != "Major cities from Natural Earth data" {
+		t.Error("Unexpected STAC collection:", collection)
+	}
+	bbox := collection["extent"].(map[string]interface{})["spatial"].(map[string]interface{})["bbox"].([][]float64)
+	if len(bbox) != 1 || len(bbox[0]) != 4 {
+		t.Error("Unexpected spatial extent:", bbox)
+	}
+
+	links := collection["links"].([]map[string]interface{})
+	if len(links) != 2 {
+		t.Fatal("Unexpected links:", links)
+	}
+	if links[0]["rel"] != "xyz" || links[0]["href"] != "https://example.com/services/world_cities/tiles/{z}/{x}/{y}.pbf" || links[0]["type"] != PBF.MimeType() {
+		t.Error("Unexpected xyz link:", links[0])
+	}
+	if links[1]["rel"] != "tilejson" || links[1]["href"] != "https://example.com/services/world_cities" {
+		t.Error("Unexpected tilejson link:", links[1])
+	}
+
+	if _, err := json.Marshal(collections); err != nil {
+		t.Error("Could not encode STAC collections to JSON:", err)
+	}
+}
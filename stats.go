@@ -0,0 +1,195 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// statsValidationTiles is the approximate number of tiles validated by
+// TilesetStats.
+const statsValidationTiles = 100
+
+// ValidationStatus is the result of the quick validation of tiles by
+// TilesetStats.
+type ValidationStatus string
+
+// ValidationStatus values
+const (
+	ValidationValid     ValidationStatus = "valid"     // sampled tiles are valid
+	ValidationInvalid   ValidationStatus = "invalid"   // some sampled tiles are invalid
+	ValidationUnchecked ValidationStatus = "unchecked" // tiles of this format cannot be validated
+)
+
+// TilesetStats summarizes the tiles of an mbtiles file, for tileset
+// statistics endpoints.  It can be marshaled as JSON.
+type TilesetStats struct {
+	Format       string           `json:"format"`
+	LastModified time.Time        `json:"last_modified"`
+	Tiles        int64            `json:"tiles"`
+	Bytes        int64            `json:"bytes"` // total length of tile data
+	Zooms        []ZoomStats      `json:"zooms"`
+	Validation   ValidationStatus `json:"validation"`
+	// InvalidTiles is the number of invalid tiles found by validation.
+	InvalidTiles int `json:"invalid_tiles"`
+}
+
+// ZoomStats summarizes the tiles at a zoom level.
+type ZoomStats struct {
+	Zoom     int     `json:"zoom"`
+	Tiles    int64   `json:"tiles"`
+	Bytes    int64   `json:"bytes"`
+	MinBytes int64   `json:"min_bytes"`
+	MaxBytes int64   `json:"max_bytes"`
+	AvgBytes float64 `json:"avg_bytes"`
+}
+
+// TilesetStats returns the number and sizes of tiles at each zoom level, the
+// tile format, the last modified time of the mbtiles file, and the result of a
+// quick validation of about 100 evenly sampled tiles with
+// ValidateRasterTiles or ValidateVectorTiles.
+//
+// Computing statistics reads every tile, so the result is cached on the handle
+// until tiles are changed using the handle, RefreshTimestamp detects that the
+// file was modified, or the handle is reloaded.  The returned stats must not
+// be modified.
+func (db *MBtiles) TilesetStats(ctx context.Context) (*TilesetStats, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read stats from closed mbtiles database")
+	}
+	if err := db.init(ctx); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	stats := db.stats
+	db.mu.RUnlock()
+	if stats != nil {
+		return stats, nil
+	}
+
+	stats, err := db.readTilesetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	db.stats = stats
+	db.mu.Unlock()
+	return stats, nil
+}
+
+// readTilesetStats computes the stats returned by TilesetStats.
+func (db *MBtiles) readTilesetStats(ctx context.Context) (*TilesetStats, error) {
+	stats := &TilesetStats{
+		Format:       db.GetTileFormat().String(),
+		LastModified: db.GetTimestamp(),
+		Zooms:        []ZoomStats{},
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx, "select zoom_level, count(*), sum(length(tile_data)), min(length(tile_data)), max(length(tile_data)) from tiles group by zoom_level order by zoom_level")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var zoom ZoomStats
+		if err := rows.Scan(&zoom.Zoom, &zoom.Tiles, &zoom.Bytes, &zoom.MinBytes, &zoom.MaxBytes); err != nil {
+			return nil, err
+		}
+		zoom.AvgBytes = float64(zoom.Bytes) / float64(zoom.Tiles)
+		stats.Tiles += zoom.Tiles
+		stats.Bytes += zoom.Bytes
+		stats.Zooms = append(stats.Zooms, zoom)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	sample := int(stats.Tiles / statsValidationTiles)
+	var invalid []TileError
+	switch db.GetTileFormat() {
+	case PNG, JPG:
+		invalid, err = db.ValidateRasterTiles(ctx, 1, sample)
+	case PBF:
+		invalid, err = db.ValidateVectorTiles(ctx, 1, sample)
+	default:
+		stats.Validation = ValidationUnchecked
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stats.Validation = ValidationValid
+	if len(invalid) > 0 {
+		stats.Validation = ValidationInvalid
+		stats.InvalidTiles = len(invalid)
+	}
+	return stats, nil
+}
+
+// TileGroup summarizes the tiles at a zoom level that share an ancestor tile
+// at a coarser zoom level, as computed by AggregateTilesByParent.
+type TileGroup struct {
+	ZoomStats
+	Parent TileCoord `json:"parent"` // ancestor tile (TMS scheme)
+}
+
+// AggregateTilesByZoom calls fn with the number and sizes of tiles at each
+// zoom level, in order of zoom level, as TilesetStats reports them, without
+// validating or caching them, so that capacity dashboards do not query the
+// schema of the mbtiles file directly.  Iteration stops at the first error
+// returned by fn, which is returned.
+func (db *MBtiles) AggregateTilesByZoom(ctx context.Context, fn func(ZoomStats) error) error {
+	if db.isClosed() {
+		return errors.New("cannot read stats from closed mbtiles database")
+	}
+	return db.aggregateTiles(ctx, "select zoom_level, 0, 0, count(*), sum(length(tile_data)), min(length(tile_data)), max(length(tile_data)) from tiles group by zoom_level order by zoom_level", nil, func(group TileGroup) error {
+		return fn(group.ZoomStats)
+	})
+}
+
+// AggregateTilesByParent calls fn with the number and sizes of tiles at each
+// zoom level at or above parentZoom that share each ancestor tile at
+// parentZoom, in order of zoom level and ancestor tile, to find the areas
+// that account for the size of a tileset.  Only ancestors with tiles are
+// reported.  Iteration stops at the first error returned by fn, which is
+// returned.
+func (db *MBtiles) AggregateTilesByParent(ctx context.Context, parentZoom int, fn func(TileGroup) error) error {
+	if db.isClosed() {
+		return errors.New("cannot read stats from closed mbtiles database")
+	}
+	if parentZoom < 0 || parentZoom > MaxZoomLevel {
+		return fmt.Errorf("invalid zoom level %d", parentZoom)
+	}
+	// the ancestor at parentZoom of a tile is the same in the XYZ and TMS
+	// schemes, as rows are flipped at both zoom levels
+	query := "select zoom_level, tile_column >> (zoom_level - ?1), tile_row >> (zoom_level - ?1), count(*), sum(length(tile_data)), min(length(tile_data)), max(length(tile_data)) from tiles where zoom_level >= ?1 group by 1, 2, 3 order by 1, 2, 3"
+	return db.aggregateTiles(ctx, query, []interface{}{parentZoom}, func(group TileGroup) error {
+		group.Parent.Z = int64(parentZoom)
+		return fn(group)
+	})
+}
+
+// aggregateTiles calls fn with each group of tiles returned by query, which
+// selects the zoom level, ancestor column and row, and the number, total,
+// minimum, and maximum size of the tiles of each group.
+func (db *MBtiles) aggregateTiles(ctx context.Context, query string, args []interface{}, fn func(TileGroup) error) error {
+	rows, err := db.traced(db.pool).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var group TileGroup
+		if err := rows.Scan(&group.Zoom, &group.Parent.X, &group.Parent.Y, &group.Tiles, &group.Bytes, &group.MinBytes, &group.MaxBytes); err != nil {
+			return err
+		}
+		group.AvgBytes = float64(group.Bytes) / float64(group.Tiles)
+		if err := fn(group); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
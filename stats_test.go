@@ -0,0 +1,126 @@
+package mbtiles
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func Test_TilesetStats(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count, bytes int64
+	if err := db.pool.QueryRow("select count(*), sum(length(tile_data)) from tiles").Scan(&count, &bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.TilesetStats(ctx)
+	if err != nil {
+		t.Fatal("Could not read stats:", err)
+	}
+	if stats.Format != "pbf" || stats.Tiles != count || stats.Bytes != bytes {
+		t.Error("Stats do not match expected values, got:", stats)
+	}
+	if len(stats.Zooms) != 7 || stats.Zooms[0].Zoom != 0 || stats.Zooms[0].Tiles != 1 {
+		t.Error("Zoom stats do not match expected values, got:", stats.Zooms)
+	}
+	for _, zoom := range stats.Zooms {
+		if zoom.MinBytes > zoom.MaxBytes || zoom.AvgBytes < float64(zoom.MinBytes) || zoom.AvgBytes > float64(zoom.MaxBytes) {
+			t.Error("Size stats are not consistent, got:", zoom)
+		}
+	}
+	if stats.Validation != ValidationValid || stats.InvalidTiles != 0 {
+		t.Error("Expected valid tiles, got:", stats.Validation, stats.InvalidTiles)
+	}
+	if !stats.LastModified.Equal(db.GetTimestamp()) {
+		t.Error("Last modified does not match timestamp, got:", stats.LastModified)
+	}
+
+	if cached, err := db.TilesetStats(ctx); err != nil || cached != stats {
+		t.Error("Expected cached stats, got:", cached, err)
+	}
+
+	encoded, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(encoded, &decoded)
+	for _, key := range []string{"format", "last_modified", "tiles", "bytes", "zooms", "validation"} {
+		if _, ok := decoded[key]; !ok {
+			t.Error("JSON stats do not have key:", key)
+		}
+	}
+
+	// changing tiles clears the cached stats
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 6, 6); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = db.TilesetStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Zooms) != 6 {
+		t.Error("Expected stats to be recomputed after deleting tiles, got:", stats.Zooms)
+	}
+}
+
+func Test_AggregateTiles(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stats, err := db.TilesetStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var zooms []ZoomStats
+	if err := db.AggregateTilesByZoom(ctx, func(zoom ZoomStats) error {
+		zooms = append(zooms, zoom)
+		return nil
+	}); err != nil {
+		t.Fatal("Could not aggregate tiles by zoom:", err)
+	}
+	if len(zooms) != len(stats.Zooms) {
+		t.Fatal("Zoom aggregates do not match stats, got:", zooms)
+	}
+	for i := range zooms {
+		if zooms[i] != stats.Zooms[i] {
+			t.Error("Zoom aggregate does not match stats, got:", zooms[i])
+		}
+	}
+
+	var groups []TileGroup
+	if err := db.AggregateTilesByParent(ctx, 0, func(group TileGroup) error {
+		groups = append(groups, group)
+		return nil
+	}); err != nil {
+		t.Fatal("Could not aggregate tiles by parent:", err)
+	}
+	if len(groups) != 2 || groups[1].Zoom != 1 || groups[1].Tiles != 4 || groups[1].Parent != (TileCoord{}) || groups[1].Bytes != stats.Zooms[1].Bytes {
+		t.Error("Parent aggregates do not match expected value, got:", groups)
+	}
+
+	groups = nil
+	if err := db.AggregateTilesByParent(ctx, 1, func(group TileGroup) error {
+		groups = append(groups, group)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 4 || groups[3].Parent != (TileCoord{Z: 1, X: 1, Y: 1}) || groups[3].Tiles != 1 {
+		t.Error("Parent aggregates do not match expected value, got:", groups)
+	}
+
+	if err := db.AggregateTilesByParent(ctx, -1, func(TileGroup) error { return nil }); err == nil {
+		t.Error("Expected error for invalid parent zoom level")
+	}
+}
@@ -0,0 +1,257 @@
+package mbtiles
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// tileStreamMagic starts tile streams, with the version of the format.
+const tileStreamMagic = "MBTSTRM\x01"
+
+// maxStreamRecordSize is the largest metadata or tile data read from a tile
+// stream, so that corrupt streams do not exhaust memory.
+const maxStreamRecordSize = 256 << 20
+
+// ErrInvalidTileStream is returned, wrapped with details, by TileStreamReader
+// for data that is not a valid tile stream.
+var ErrInvalidTileStream = errors.New("invalid tile stream")
+
+// TileStreamWriter writes tiles to a tile stream, to pipe tiles between
+// processes, such as from an export to a tool that reads tiles from stdin,
+// without temporary files.  Writes are buffered; Flush must be called after
+// the last tile.
+//
+// A stream starts with the magic "MBTSTRM" and a version byte of 1, followed
+// by the big endian uint32 length of a JSON object of metadata items and the
+// object, and a record for each tile: its zoom level as a byte, its column
+// and row (TMS scheme) and the length of its data as big endian uint32s, and
+// its data.  The stream ends after the last complete record.
+type TileStreamWriter struct {
+	w      *bufio.Writer
+	record [13]byte
+}
+
+// NewTileStreamWriter starts a tile stream on w with metadata items, which
+// may be nil.
+func NewTileStreamWriter(w io.Writer, metadata map[string]string) (*TileStreamWriter, error) {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	sw := &TileStreamWriter{w: bufio.NewWriter(w)}
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(encoded)))
+	for _, b := range [][]byte{[]byte(tileStreamMagic), length[:], encoded} {
+		if _, err := sw.w.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	return sw, nil
+}
+
+// WriteTile writes the tile at coord (TMS scheme) with data to the stream.
+func (sw *TileStreamWriter) WriteTile(coord TileCoord, data []byte) error {
+	if err := coord.Validate(); err != nil {
+		return err
+	}
+	if len(data) > maxStreamRecordSize {
+		return &TileError{Coord: coord, Err: fmt.Errorf("tile of %d bytes is too large for tile stream", len(data))}
+	}
+	sw.record[0] = byte(coord.Z)
+	binary.BigEndian.PutUint32(sw.record[1:], uint32(coord.X))
+	binary.BigEndian.PutUint32(sw.record[5:], uint32(coord.Y))
+	binary.BigEndian.PutUint32(sw.record[9:], uint32(len(data)))
+	if _, err := sw.w.Write(sw.record[:]); err != nil {
+		return err
+	}
+	_, err := sw.w.Write(data)
+	return err
+}
+
+// Flush writes buffered tiles to the underlying writer.
+func (sw *TileStreamWriter) Flush() error {
+	return sw.w.Flush()
+}
+
+// TileStreamReader reads tiles from a tile stream.
+type TileStreamReader struct {
+	r        *bufio.Reader
+	metadata map[string]string
+	record   [13]byte
+}
+
+// NewTileStreamReader reads the start of a tile stream from r, and returns
+// an error wrapping ErrInvalidTileStream if it is not a tile stream.
+func NewTileStreamReader(r io.Reader) (*TileStreamReader, error) {
+	sr := &TileStreamReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(tileStreamMagic)+4)
+	if _, err := io.ReadFull(sr.r, header); err != nil {
+		return nil, fmt.Errorf("%w: cannot read header: %v", ErrInvalidTileStream, err)
+	}
+	if string(header[:len(tileStreamMagic)]) != tileStreamMagic {
+		return nil, fmt.Errorf("%w: unknown header", ErrInvalidTileStream)
+	}
+	length := binary.BigEndian.Uint32(header[len(tileStreamMagic):])
+	if length > maxStreamRecordSize {
+		return nil, fmt.Errorf("%w: metadata of %d bytes is too large", ErrInvalidTileStream, length)
+	}
+	encoded := make([]byte, length)
+	if _, err := io.ReadFull(sr.r, encoded); err != nil {
+		return nil, fmt.Errorf("%w: cannot read metadata: %v", ErrInvalidTileStream, err)
+	}
+	if err := json.Unmarshal(encoded, &sr.metadata); err != nil {
+		return nil, fmt.Errorf("%w: cannot parse metadata: %v", ErrInvalidTileStream, err)
+	}
+	if sr.metadata == nil {
+		sr.metadata = map[string]string{}
+	}
+	return sr, nil
+}
+
+// Metadata returns the metadata items of the stream, which must not be
+// modified.
+func (sr *TileStreamReader) Metadata() map[string]string {
+	return sr.metadata
+}
+
+// Next reads the next tile of the stream, and returns its coordinates (TMS
+// scheme) and data.  io.EOF is returned after the last tile, and an error
+// wrapping ErrInvalidTileStream for truncated or invalid records.
+func (sr *TileStreamReader) Next() (TileCoord, []byte, error) {
+	if _, err := io.ReadFull(sr.r, sr.record[:]); err != nil {
+		if err == io.EOF {
+			return TileCoord{}, nil, io.EOF
+		}
+		return TileCoord{}, nil, fmt.Errorf("%w: cannot read tile record: %v", ErrInvalidTileStream, err)
+	}
+	coord := TileCoord{
+		Z: int64(sr.record[0]),
+		X: int64(binary.BigEndian.Uint32(sr.record[1:])),
+		Y: int64(binary.BigEndian.Uint32(sr.record[5:])),
+	}
+	if err := coord.Validate(); err != nil {
+		return TileCoord{}, nil, fmt.Errorf("%w: %v", ErrInvalidTileStream, err)
+	}
+	length := binary.BigEndian.Uint32(sr.record[9:])
+	if length > maxStreamRecordSize {
+		return TileCoord{}, nil, fmt.Errorf("%w: tile %v of %d bytes is too large", ErrInvalidTileStream, coord, length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(sr.r, data); err != nil {
+		return TileCoord{}, nil, fmt.Errorf("%w: cannot read tile %v: %v", ErrInvalidTileStream, coord, err)
+	}
+	return coord, data, nil
+}
+
+// WriteTileStream writes the metadata items and all tiles of the mbtiles file
+// to w as a tile stream, in order of coordinates, and returns the number of
+// tiles written.  Tiles are streamed as they are read, so that large tilesets
+// can be piped to other processes.
+func (db *MBtiles) WriteTileStream(ctx context.Context, w io.Writer) (int64, error) {
+	if db.isClosed() {
+		return 0, errors.New("cannot read tiles from closed mbtiles database")
+	}
+	q := db.traced(db.pool)
+
+	metadata := make(map[string]string)
+	rows, err := q.QueryContext(ctx, "select name, value from metadata where value is not ''")
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		metadata[name] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sw, err := NewTileStreamWriter(w, metadata)
+	if err != nil {
+		return 0, err
+	}
+	rows, err = q.QueryContext(ctx, "select zoom_level, tile_column, tile_row, tile_data from tiles order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	transform := tileTransform(ctx)
+	var count int64
+	for rows.Next() {
+		var (
+			coord TileCoord
+			data  []byte
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &data); err != nil {
+			return count, err
+		}
+		if coord.Validate() != nil {
+			// tiles with invalid coordinates cannot be requested
+			continue
+		}
+		if err := db.resolveBlob(ctx, &data); err != nil {
+			return count, &TileError{Coord: coord, Err: err}
+		}
+		if transform != nil {
+			if data, err = transform(coord, data); err != nil {
+				return count, &TileError{Coord: coord, Err: err}
+			}
+		}
+		if err := sw.WriteTile(coord, data); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, sw.Flush()
+}
+
+// ImportTileStream creates a new mbtiles file at dst with the metadata items
+// and tiles of the tile stream read from r, and returns the number of tiles
+// read.  dst must not already exist, and is removed if the import fails.
+// Tiles that appear more than once replace the earlier tiles.
+func ImportTileStream(ctx context.Context, r io.Reader, dst string) (int64, error) {
+	sr, err := NewTileStreamReader(r)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = createTileset(ctx, dst, sr.Metadata(), func(q querier) error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			coord, data, err := sr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := q.ExecContext(ctx, "insert or replace into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data); err != nil {
+				return err
+			}
+			count++
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
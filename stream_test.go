@@ -0,0 +1,85 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func Test_TileStream(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := db.WriteTileStream(ctx, &buf)
+	if err != nil {
+		t.Fatal("Could not write tile stream:", err)
+	}
+	if count != 5 {
+		t.Error("Expected 5 tiles in stream, got:", count)
+	}
+	encoded := buf.Bytes()
+
+	sr, err := NewTileStreamReader(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal("Could not read tile stream:", err)
+	}
+	if sr.Metadata()["name"] != "Geography Class" {
+		t.Error("Stream metadata does not match expected value, got:", sr.Metadata())
+	}
+	coord, data, err := sr.Next()
+	if err != nil || coord != (TileCoord{}) {
+		t.Fatal("Could not read first tile of stream:", coord, err)
+	}
+	if expected, err := db.GetTile(ctx, 0, 0, 0); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Stream tile does not match expected value:", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "imported.mbtiles")
+	if count, err := ImportTileStream(ctx, bytes.NewReader(encoded), dst); err != nil || count != 5 {
+		t.Fatal("Could not import tile stream:", count, err)
+	}
+	imported, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+	if imported.GetTileFormat() != PNG {
+		t.Error("Expected imported tileset to be PNG, got:", imported.GetTileFormat())
+	}
+	if _, err := imported.GetTile(ctx, 1, 1, 1); err != nil {
+		t.Error("Could not read imported tile:", err)
+	}
+
+	// truncated streams are invalid, but ending after a record is not
+	sr, err = NewTileStreamReader(bytes.NewReader(encoded[:len(encoded)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, _, err = sr.Next()
+	}
+	if !errors.Is(err, ErrInvalidTileStream) {
+		t.Error("Expected ErrInvalidTileStream for truncated stream, got:", err)
+	}
+	sw, err := NewTileStreamWriter(io.Discard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.WriteTile(TileCoord{Z: 1, X: 2}, nil); !errors.Is(err, ErrInvalidTileCoord) {
+		t.Error("Expected ErrInvalidTileCoord for invalid tile, got:", err)
+	}
+	if _, err := NewTileStreamReader(bytes.NewReader([]byte("not a stream"))); !errors.Is(err, ErrInvalidTileStream) {
+		t.Error("Expected ErrInvalidTileStream for invalid header, got:", err)
+	}
+	if _, err := ImportTileStream(ctx, bytes.NewReader(encoded[:len(encoded)-1]), filepath.Join(t.TempDir(), "truncated.mbtiles")); err == nil {
+		t.Error("Expected error for truncated stream")
+	}
+}
@@ -0,0 +1,143 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// styleSourceID is the ID of the tileset source in styles created by Style.
+const styleSourceID = "mbtiles"
+
+// styleColors are used in turn for the layers of vector tilesets in styles
+// created by Style.
+var styleColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// Style creates a minimal MapLibre / Mapbox GL style that displays the tileset,
+// using tileJSONURL as the URL of the TileJSON of the tileset source.  The
+// returned style can be encoded to JSON and served as style.json.
+//
+// Raster tilesets are displayed as a single raster layer.  For vector
+// tilesets, each layer listed in the vector_layers of the JSON metadata is
+// displayed using a default color, as circles, lines, or fills according to
+// its geometry type in tilestats metadata, or as all three if its geometry
+// type is not known.
+func (db *MBtiles) Style(tileJSONURL string) (map[string]interface{}, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot create style for closed mbtiles database")
+	}
+	if err := db.init(context.TODO()); err != nil {
+		return nil, err
+	}
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := metadata["name"].(string)
+	style := map[string]interface{}{
+		"version": 8,
+		"name":    name,
+	}
+	if center, ok := metadata["center"].([]float64); ok && len(center) >= 2 {
+		style["center"] = center[:2]
+		if len(center) >= 3 {
+			style["zoom"] = center[2]
+		}
+	}
+
+	source := map[string]interface{}{"url": tileJSONURL}
+	var layers []map[string]interface{}
+
+	state := db.loadState()
+	switch state.format {
+	case PBF:
+		source["type"] = "vector"
+		layers = vectorStyleLayers(metadata)
+	case PNG, JPG, WEBP:
+		source["type"] = "raster"
+		if state.tilesize > 0 {
+			source["tileSize"] = state.tilesize
+		}
+		layers = []map[string]interface{}{{
+			"id":     styleSourceID,
+			"type":   "raster",
+			"source": styleSourceID,
+		}}
+	default:
+		return nil, fmt.Errorf("cannot create style for tile format %v", state.format)
+	}
+
+	style["sources"] = map[string]interface{}{styleSourceID: source}
+	style["layers"] = layers
+	return style, nil
+}
+
+// vectorStyleLayers creates style layers for each of the vector layers in
+// metadata.
+func vectorStyleLayers(metadata map[string]interface{}) []map[string]interface{} {
+	geometries := make(map[string]string)
+	if tilestats, ok := metadata["tilestats"].(map[string]interface{}); ok {
+		statsLayers, _ := tilestats["layers"].([]interface{})
+		for _, item := range statsLayers {
+			layer, _ := item.(map[string]interface{})
+			id, _ := layer["layer"].(string)
+			geometry, _ := layer["geometry"].(string)
+			geometries[id] = geometry
+		}
+	}
+
+	var layers []map[string]interface{}
+	vectorLayers, _ := metadata["vector_layers"].([]interface{})
+	for i, item := range vectorLayers {
+		vectorLayer, _ := item.(map[string]interface{})
+		id, ok := vectorLayer["id"].(string)
+		if !ok {
+			continue
+		}
+		color := styleColors[i%len(styleColors)]
+
+		var types []string
+		switch geometries[id] {
+		case "Point":
+			types = []string{"circle"}
+		case "LineString":
+			types = []string{"line"}
+		case "Polygon":
+			types = []string{"fill"}
+		default:
+			types = []string{"fill", "line", "circle"}
+		}
+
+		for _, layerType := range types {
+			layer := map[string]interface{}{
+				"id":           id + "-" + layerType,
+				"type":         layerType,
+				"source":       styleSourceID,
+				"source-layer": id,
+			}
+			switch layerType {
+			case "circle":
+				layer["paint"] = map[string]interface{}{"circle-color": color, "circle-radius": 3}
+			case "line":
+				layer["paint"] = map[string]interface{}{"line-color": color}
+			case "fill":
+				layer["paint"] = map[string]interface{}{"fill-color": color, "fill-opacity": 0.4}
+			}
+			if len(types) > 1 {
+				// only draw each type of geometry with the matching layer type
+				geometry := map[string]string{"circle": "Point", "line": "LineString", "fill": "Polygon"}[layerType]
+				layer["filter"] = []interface{}{"==", "$type", geometry}
+			}
+			if minZoom, ok := vectorLayer["minzoom"].(float64); ok {
+				layer["minzoom"] = minZoom
+			}
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
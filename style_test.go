@@ -0,0 +1,87 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_Style(t *testing.T) {
+	tests := []struct {
+		path       string
+		sourceType string
+		layers     []string
+	}{
+		{path: "geography-class-png.mbtiles", sourceType: "raster", layers: []string{"mbtiles"}},
+		{path: "world_cities.mbtiles", sourceType: "vector", layers: []string{"cities-circle"}},
+	}
+
+	for _, tc := range tests {
+		db, err := Open("./testdata/" + tc.path)
+		if err != nil {
+			t.Fatal("Could not open:", tc.path, err)
+		}
+		style, err := db.Style("http://localhost/services/test")
+		db.Close()
+		if err != nil {
+			t.Error("Unexpected error creating style for:", tc.path, err)
+			continue
+		}
+
+		// round trip through JSON to check the style as a client would see it
+		encoded, err := json.Marshal(style)
+		if err != nil {
+			t.Error("Could not encode style to JSON for:", tc.path, err)
+			continue
+		}
+		var decoded struct {
+			Version int `json:"version"`
+			Sources map[string]struct {
+				Type string `json:"type"`
+				URL  string `json:"url"`
+			} `json:"sources"`
+			Layers []struct {
+				ID     string `json:"id"`
+				Source string `json:"source"`
+			} `json:"layers"`
+		}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Error("Could not decode style JSON for:", tc.path, err)
+			continue
+		}
+
+		if decoded.Version != 8 {
+			t.Error("Style version is not 8 for:", tc.path)
+		}
+		source, ok := decoded.Sources["mbtiles"]
+		if !ok || source.Type != tc.sourceType || source.URL != "http://localhost/services/test" {
+			t.Error("Style source does not match expected values for:", tc.path, "got:", decoded.Sources)
+		}
+		if len(decoded.Layers) != len(tc.layers) {
+			t.Error("Style has", len(decoded.Layers), "layers, expected", len(tc.layers), "for:", tc.path)
+			continue
+		}
+		for i, id := range tc.layers {
+			if decoded.Layers[i].ID != id || decoded.Layers[i].Source != "mbtiles" {
+				t.Error("Style layer", decoded.Layers[i], "does not match expected ID", id, "for:", tc.path)
+			}
+		}
+	}
+}
+
+func Test_vectorStyleLayers_unknown_geometry(t *testing.T) {
+	metadata := map[string]interface{}{
+		"vector_layers": []interface{}{
+			map[string]interface{}{"id": "roads"},
+		},
+	}
+
+	layers := vectorStyleLayers(metadata)
+	if len(layers) != 3 {
+		t.Fatal("Expected a layer per geometry type for unknown geometry, got:", layers)
+	}
+	for _, layer := range layers {
+		if layer["source-layer"] != "roads" || layer["filter"] == nil {
+			t.Error("Layer does not match expected values:", layer)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package mbtiles
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TileRequest is a tile request parsed from a URL path by a PathTemplate.
+type TileRequest struct {
+	ID     string     // tileset ID; empty if the template has no {id}
+	Coord  TileCoord  // tile coordinates, in the scheme used by the URL
+	Format TileFormat // format of the {ext} extension; UNKNOWN if the template has no {ext}
+	Scheme TileScheme // scheme of the {scheme} placeholder; XYZScheme if the template has no {scheme}
+}
+
+// PathTemplate parses tile request URL paths according to a template such as
+// "/tiles/{id}/{z}/{x}/{y}.{ext}".
+//
+// Supported placeholders are:
+//   - {id}  tileset ID (optional); matches any characters other than "/"
+//   - {z}   zoom level (required)
+//   - {x}   tile column (required)
+//   - {y}   tile row (required)
+//   - {ext} tile format extension (optional), see TileFormatFromExtension
+//   - {scheme} tile scheme (optional), "xyz" or "tms"
+//
+// To serve a tileset under both XYZ and TMS URLs, use a template with
+// {scheme}, such as "/tiles/{id}/{scheme}/{z}/{x}/{y}.{ext}", or a template
+// per scheme, and read tiles at TileRequest.TMSCoord.
+type PathTemplate struct {
+	template string
+	pattern  *regexp.Regexp
+	groups   []string // placeholder names in order of their regexp groups
+}
+
+var templatePlaceholders = map[string]string{
+	"id":  `([^/]+?)`,
+	"z":   `([0-9]+)`,
+	"x":   `([0-9]+)`,
+	"y":   `([0-9]+)`,
+	"ext": `([A-Za-z0-9]+)`,
+
+	"scheme": `(xyz|tms)`,
+}
+
+// ParsePathTemplate parses a URL path template.  An error is returned if the
+// template contains an unknown or repeated placeholder, or is missing one of
+// {z}, {x}, or {y}.
+func ParsePathTemplate(template string) (*PathTemplate, error) {
+	var (
+		sb     strings.Builder
+		groups []string
+		seen   = make(map[string]bool)
+	)
+
+	sb.WriteString("^")
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			sb.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("path template %q has unterminated placeholder", template)
+		}
+		end += start
+
+		name := rest[start+1 : end]
+		expr, ok := templatePlaceholders[name]
+		if !ok {
+			return nil, fmt.Errorf("path template %q has unknown placeholder {%s}", template, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("path template %q has repeated placeholder {%s}", template, name)
+		}
+		seen[name] = true
+		groups = append(groups, name)
+
+		sb.WriteString(regexp.QuoteMeta(rest[:start]))
+		sb.WriteString(expr)
+		rest = rest[end+1:]
+	}
+	sb.WriteString("$")
+
+	for _, name := range []string{"z", "x", "y"} {
+		if !seen[name] {
+			return nil, fmt.Errorf("path template %q is missing required placeholder {%s}", template, name)
+		}
+	}
+
+	pattern, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not compile path template %q: %v", template, err)
+	}
+
+	return &PathTemplate{template: template, pattern: pattern, groups: groups}, nil
+}
+
+// String returns the template.
+func (t *PathTemplate) String() string {
+	return t.template
+}
+
+// Match parses path according to the template.  An error is returned if the
+// path does not match the template, the tile coordinates are out of range for
+// the zoom level, or the extension is not a known tile format.
+func (t *PathTemplate) Match(path string) (TileRequest, error) {
+	var req TileRequest
+
+	submatches := t.pattern.FindStringSubmatch(path)
+	if submatches == nil {
+		return req, fmt.Errorf("path %q does not match template %q", path, t.template)
+	}
+
+	for i, name := range t.groups {
+		value := submatches[i+1]
+		switch name {
+		case "id":
+			req.ID = value
+		case "scheme":
+			req.Scheme, _ = ParseTileScheme(value)
+		case "ext":
+			req.Format = TileFormatFromExtension(value)
+			if req.Format == UNKNOWN {
+				return req, fmt.Errorf("path %q has unknown tile format extension %q", path, value)
+			}
+		default:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return req, fmt.Errorf("path %q has invalid coordinate %q", path, value)
+			}
+			switch name {
+			case "z":
+				req.Coord.Z = n
+			case "x":
+				req.Coord.X = n
+			case "y":
+				req.Coord.Y = n
+			}
+		}
+	}
+
+	if err := req.Coord.Validate(); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// Expand returns the URL path for req according to the template.
+func (t *PathTemplate) Expand(req TileRequest) string {
+	return strings.NewReplacer(
+		"{id}", req.ID,
+		"{z}", strconv.FormatInt(req.Coord.Z, 10),
+		"{x}", strconv.FormatInt(req.Coord.X, 10),
+		"{y}", strconv.FormatInt(req.Coord.Y, 10),
+		"{ext}", req.Format.String(),
+		"{scheme}", req.Scheme.String(),
+	).Replace(t.template)
+}
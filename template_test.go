@@ -0,0 +1,89 @@
+package mbtiles
+
+import (
+	"testing"
+)
+
+func Test_ParsePathTemplate_invalid(t *testing.T) {
+	tests := []string{
+		"/tiles/{z}/{x}",
+		"/tiles/{z}/{x}/{y}/{z}",
+		"/tiles/{z}/{x}/{y}.{format}",
+		"/tiles/{z}/{x}/{y",
+	}
+
+	for _, template := range tests {
+		if _, err := ParsePathTemplate(template); err == nil {
+			t.Error("Invalid path template did not raise error:", template)
+		}
+	}
+}
+
+func Test_PathTemplate_Match(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		req      TileRequest
+	}{
+		{
+			template: "/tiles/{id}/{z}/{x}/{y}.{ext}",
+			path:     "/tiles/world-cities/12/654/1583.pbf",
+			req:      TileRequest{ID: "world-cities", Coord: TileCoord{Z: 12, X: 654, Y: 1583}, Format: PBF},
+		},
+		{
+			template: "/{z}/{x}/{y}",
+			path:     "/1/1/0",
+			req:      TileRequest{Coord: TileCoord{Z: 1, X: 1, Y: 0}},
+		},
+		{
+			template: "/services/{id}.mbtiles/tiles/{z}/{x}/{y}.{ext}",
+			path:     "/services/geography.class.mbtiles/tiles/0/0/0.png",
+			req:      TileRequest{ID: "geography.class", Coord: TileCoord{Z: 0, X: 0, Y: 0}, Format: PNG},
+		},
+		{
+			template: "/tiles/{id}/{scheme}/{z}/{x}/{y}.{ext}",
+			path:     "/tiles/world-cities/tms/1/0/1.pbf",
+			req:      TileRequest{ID: "world-cities", Coord: TileCoord{Z: 1, X: 0, Y: 1}, Format: PBF, Scheme: TMSScheme},
+		},
+	}
+
+	for _, tc := range tests {
+		template, err := ParsePathTemplate(tc.template)
+		if err != nil {
+			t.Error("Unexpected error parsing path template:", tc.template, err)
+			continue
+		}
+		req, err := template.Match(tc.path)
+		if err != nil {
+			t.Error("Unexpected error matching path:", tc.path, err)
+			continue
+		}
+		if req != tc.req {
+			t.Error("Tile request", req, "does not match expected value", tc.req, "for:", tc.path)
+		}
+		if path := template.Expand(req); path != tc.path {
+			t.Error("Expanded path", path, "does not match expected value", tc.path)
+		}
+	}
+}
+
+func Test_PathTemplate_Match_invalid(t *testing.T) {
+	template, err := ParsePathTemplate("/tiles/{id}/{z}/{x}/{y}.{ext}")
+	if err != nil {
+		t.Fatal("Unexpected error parsing path template:", err)
+	}
+
+	tests := []string{
+		"/tiles/world/1/0/0",
+		"/tiles/world/1/0/0.gif",
+		"/tiles/world/1/2/0.png",
+		"/other/world/1/0/0.png",
+		"/tiles/world/a/0/0.png",
+	}
+
+	for _, path := range tests {
+		if _, err := template.Match(path); err == nil {
+			t.Error("Invalid path did not raise error:", path)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// DecodeTerrainRGB returns the elevation in meters encoded by the red, green,
+// and blue components of a pixel of a Mapbox Terrain-RGB tile.
+func DecodeTerrainRGB(r uint8, g uint8, b uint8) float64 {
+	return -10000 + float64(int(r)<<16|int(g)<<8|int(b))*0.1
+}
+
+// terrainElevation returns the elevation in meters of the pixel at x, y of a
+// decoded Terrain-RGB tile.
+func terrainElevation(img image.Image, x int, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return DecodeTerrainRGB(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
+
+// terrainElevations returns the elevations in meters of all pixels of a
+// decoded Terrain-RGB tile, in row order, along with its width and height.
+func terrainElevations(img image.Image) ([]float64, int, int) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	elevations := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			elevations[y*width+x] = terrainElevation(img, bounds.Min.X+x, bounds.Min.Y+y)
+		}
+	}
+	return elevations, width, height
+}
+
+// ProfilePoint is a point of an elevation profile.
+type ProfilePoint struct {
+	Distance  float64 // distance along the line from its start, in meters
+	Lon       float64
+	Lat       float64
+	Elevation float64 // elevation in meters; NaN if there is no tile at the point
+}
+
+// ElevationProfile returns the elevation at samples points evenly spaced along
+// lineString, a list of [longitude, latitude] positions in degrees, including
+// its start and end, read from the Terrain-RGB tiles of this mbtiles file at
+// its maximum zoom level.  Only the tiles that contain a sample are read, and
+// each is read and decoded once.
+//
+// Distances are measured on a sphere with the radius of the Web Mercator
+// projection.  Elevations are those of the nearest pixel to each sample.  Only
+// PNG tilesets are supported, since Terrain-RGB requires lossless tiles.
+func (db *MBtiles) ElevationProfile(ctx context.Context, lineString [][]float64, samples int) ([]ProfilePoint, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read elevation profile from closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PNG {
+		return nil, fmt.Errorf("elevation profiles are only supported for PNG tilesets, not %v", format)
+	}
+	if len(lineString) < 2 {
+		return nil, errors.New("line string must have at least 2 positions")
+	}
+	for _, position := range lineString {
+		if len(position) < 2 {
+			return nil, fmt.Errorf("invalid position %v: must have longitude and latitude", position)
+		}
+	}
+	if samples < 2 {
+		return nil, fmt.Errorf("invalid number of samples %d: must be at least 2", samples)
+	}
+
+	zoom, err := db.GetMaxZoom()
+	if err != nil {
+		return nil, err
+	}
+
+	// cumulative distance to the start of each segment
+	distances := make([]float64, len(lineString))
+	for i := 1; i < len(lineString); i++ {
+		distances[i] = distances[i-1] + haversine(lineString[i-1], lineString[i])
+	}
+	total := distances[len(distances)-1]
+
+	tiles := make(map[TileCoord]image.Image)
+	profile := make([]ProfilePoint, 0, samples)
+	segment := 0
+	for i := 0; i < samples; i++ {
+		distance := total * float64(i) / float64(samples-1)
+		for segment < len(lineString)-2 && distances[segment+1] < distance {
+			segment++
+		}
+
+		start, end := lineString[segment], lineString[segment+1]
+		t := 0.0
+		if length := distances[segment+1] - distances[segment]; length > 0 {
+			t = (distance - distances[segment]) / length
+		}
+		point := ProfilePoint{
+			Distance: distance,
+			Lon:      start[0] + (end[0]-start[0])*t,
+			Lat:      start[1] + (end[1]-start[1])*t,
+		}
+
+		point.Elevation, err = db.elevationAt(ctx, tiles, point.Lon, point.Lat, int64(zoom))
+		if err != nil {
+			return nil, err
+		}
+		profile = append(profile, point)
+	}
+	return profile, nil
+}
+
+// elevationAt returns the elevation at lon, lat from the Terrain-RGB tile at
+// zoom level z, or NaN if there is no tile.  Decoded tiles are kept in tiles;
+// missing tiles are kept as nil.
+func (db *MBtiles) elevationAt(ctx context.Context, tiles map[TileCoord]image.Image, lon float64, lat float64, z int64) (float64, error) {
+	fx, fy := lonLatToTileFraction(lon, lat, z)
+	coord := TileCoordFromLonLat(lon, lat, z)
+
+	img, ok := tiles[coord]
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		tms := coord.FlipY()
+		data, err := db.GetTile(ctx, tms.Z, tms.X, tms.Y)
+		if err != nil && err != ErrTileNotFound {
+			return 0, err
+		}
+		if err == nil {
+			img, _, err = image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return 0, &TileError{Coord: tms, Err: err}
+			}
+		}
+		tiles[coord] = img
+	}
+	if img == nil {
+		return math.NaN(), nil
+	}
+
+	b := img.Bounds()
+	px := b.Min.X + clampPixel(int((fx-float64(coord.X))*float64(b.Dx())), b.Dx())
+	py := b.Min.Y + clampPixel(int((fy-float64(coord.Y))*float64(b.Dy())), b.Dy())
+	return terrainElevation(img, px, py), nil
+}
+
+// clampPixel clamps v to the range 0 to size-1.
+func clampPixel(v int, size int) int {
+	if v < 0 {
+		return 0
+	}
+	if v >= size {
+		return size - 1
+	}
+	return v
+}
+
+// lonLatToTileFraction returns the fractional XYZ scheme tile column and row at
+// zoom level z of the point at longitude lon and latitude lat, in degrees.
+func lonLatToTileFraction(lon float64, lat float64, z int64) (float64, float64) {
+	n := float64(int64(1) << z)
+	lat = math.Max(-maxLatitude, math.Min(maxLatitude, lat))
+	latRad := lat * math.Pi / 180
+	x := (lon + 180) / 360 * n
+	y := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+	return x, y
+}
+
+// haversine returns the great circle distance in meters between two
+// [longitude, latitude] positions in degrees.
+func haversine(a []float64, b []float64) float64 {
+	lat1, lat2 := a[1]*math.Pi/180, b[1]*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b[0] - a[0]) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(1, h)))
+}
@@ -0,0 +1,125 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// createTerrainTileset creates an mbtiles file of 256px Terrain-RGB tiles, with
+// the elevation of each pixel of each tile in elevations, by XYZ scheme tile
+// coordinates.
+func createTerrainTileset(t *testing.T, elevations map[TileCoord]func(x int, y int) float64) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "terrain.mbtiles")
+	pool, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for _, query := range schemaStatements("") {
+		if _, err := pool.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for coord, elevation := range elevations {
+		img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+		for y := 0; y < 256; y++ {
+			for x := 0; x < 256; x++ {
+				v := int(math.Round((elevation(x, y) + 10000) * 10))
+				img.Set(x, y, color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		tms := coord.FlipY()
+		if _, err := pool.Exec("insert into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", tms.Z, tms.X, tms.Y, buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filename
+}
+
+// constantElevation returns an elevation function for createTerrainTileset
+// with the same elevation for every pixel.
+func constantElevation(elevation float64) func(x int, y int) float64 {
+	return func(x int, y int) float64 {
+		return elevation
+	}
+}
+
+func Test_DecodeTerrainRGB(t *testing.T) {
+	if elevation := DecodeTerrainRGB(1, 134, 160); elevation != 0 {
+		t.Error("Elevation", elevation, "does not match expected value 0")
+	}
+	if elevation := DecodeTerrainRGB(0, 0, 0); elevation != -10000 {
+		t.Error("Elevation", elevation, "does not match expected value -10000")
+	}
+}
+
+func Test_ElevationProfile(t *testing.T) {
+	filename := createTerrainTileset(t, map[TileCoord]func(x int, y int) float64{
+		{Z: 1, X: 0, Y: 0}: constantElevation(100),
+		{Z: 1, X: 1, Y: 0}: constantElevation(200),
+		{Z: 1, X: 0, Y: 1}: constantElevation(300),
+	})
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	profile, err := db.ElevationProfile(ctx, [][]float64{{-90, 45}, {90, 45}}, 2)
+	if err != nil {
+		t.Fatal("Could not read elevation profile:", err)
+	}
+	if len(profile) != 2 || profile[0].Elevation != 100 || profile[1].Elevation != 200 {
+		t.Fatal("Unexpected elevation profile:", profile)
+	}
+	if profile[0].Distance != 0 || math.Abs(profile[1].Distance-haversine([]float64{-90, 45}, []float64{90, 45})) > 1e-6 {
+		t.Error("Unexpected distances:", profile)
+	}
+
+	// there is no tile at the end of the line
+	profile, err = db.ElevationProfile(ctx, [][]float64{{-90, -45}, {90, -45}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile[0].Elevation != 300 || !math.IsNaN(profile[1].Elevation) {
+		t.Error("Unexpected elevation profile with missing tile:", profile)
+	}
+
+	line := [][]float64{{-90, 45}, {-45, 45}, {-45, -45}}
+	profile, err = db.ElevationProfile(ctx, line, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := haversine(line[0], line[1]) + haversine(line[1], line[2])
+	last := profile[len(profile)-1]
+	if len(profile) != 11 || math.Abs(last.Distance-total) > 1e-6 || math.Abs(last.Lon+45) > 1e-9 || math.Abs(last.Lat+45) > 1e-9 {
+		t.Error("Profile does not end at end of line:", last)
+	}
+	for i := 1; i < len(profile); i++ {
+		if profile[i].Distance <= profile[i-1].Distance {
+			t.Error("Profile distances are not increasing:", profile)
+		}
+	}
+
+	if _, err := db.ElevationProfile(ctx, line[:1], 2); err == nil {
+		t.Error("Expected error for line with 1 position")
+	}
+	if _, err := db.ElevationProfile(ctx, line, 1); err == nil {
+		t.Error("Expected error for 1 sample")
+	}
+}
@@ -0,0 +1,438 @@
+// Package testtiles generates small mbtiles files for tests, so that projects
+// using mbtiles files can write integration tests without committing binary
+// fixtures.  Files are deterministic: the same Options always produce the same
+// tiles, and the tile at each coordinate can be computed with TileData to
+// compare with tiles read from the file.
+package testtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	mbtiles "github.com/brendan-ward/mbtiles-go"
+	_ "modernc.org/sqlite"
+)
+
+// LayerName is the name of the layer of vector tiles.
+const LayerName = "tiles"
+
+// Defect is a set of problems deliberately introduced into a generated file,
+// to test how readers and validators handle broken files.  Defects that
+// affect a tile each affect a different tile at the maximum zoom level, as
+// returned by DefectiveTile, if it has at least 3 tiles.
+type Defect uint
+
+// Defect values
+const (
+	// MissingMetadata omits the required name and format metadata items.
+	MissingMetadata Defect = 1 << iota
+	// EmptyTile stores an empty blob as the data of a tile.
+	EmptyTile
+	// MixedFormats stores a tile in another format: JPG for PNG and PBF
+	// files, and PNG for JPG files.
+	MixedFormats
+	// OutOfRangeRow adds a tile at the maximum zoom level in the row after
+	// the last row of the zoom level, which no TMS tile has.
+	OutOfRangeRow
+	// TruncatedTile stores the first half of the data of a tile.
+	TruncatedTile
+	// HotJournal leaves a hot rollback journal next to the file, as left by
+	// a writer that crashed during a transaction, which SQLite rolls back
+	// when the file is next opened for writing.  mbtiles.Open refuses to open
+	// files with journals as incomplete tilesets.
+	HotJournal
+)
+
+// String returns the names of the defects of d, separated by "|".
+func (d Defect) String() string {
+	var names []string
+	for i, name := range []string{"MissingMetadata", "EmptyTile", "MixedFormats", "OutOfRangeRow", "TruncatedTile", "HotJournal"} {
+		if d&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Options configures a generated mbtiles file.
+type Options struct {
+	MinZoom int
+	MaxZoom int // MinZoom if less than MinZoom
+	// Bounds limits tiles to those that intersect [west, south, east, north]
+	// in degrees; the whole world if nil.
+	Bounds   []float64
+	Format   mbtiles.TileFormat // PNG if UNKNOWN; PNG, JPG, and PBF are supported
+	TileSize int                // size of raster tiles in pixels; 256 if 0
+	// Deduplicated uses the schema with a map table of tile coordinates and
+	// an images table of tile data, with tiles as a view of both.
+	Deduplicated bool
+	Metadata     map[string]string // metadata items added to or replacing the generated items
+	Defects      Defect
+}
+
+// New creates an mbtiles file configured by opts in a temporary directory
+// removed when the test completes, and returns its path.  The test fails if
+// the file cannot be created.
+func New(tb testing.TB, opts Options) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "test.mbtiles")
+	if err := Create(path, opts); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// Create creates an mbtiles file configured by opts at path, which must not
+// already exist.  path is removed if this fails.
+func Create(path string, opts Options) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("destination already exists: %q", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	opts = withDefaults(opts)
+	if opts.MinZoom < 0 || opts.MaxZoom > mbtiles.MaxZoomLevel {
+		return fmt.Errorf("invalid zoom range %d-%d", opts.MinZoom, opts.MaxZoom)
+	}
+	if len(opts.Bounds) != 4 || opts.Bounds[0] > opts.Bounds[2] || opts.Bounds[1] > opts.Bounds[3] {
+		return fmt.Errorf("invalid bounds %v", opts.Bounds)
+	}
+
+	err := create(path, opts)
+	if err == nil && opts.Defects&HotJournal != 0 {
+		err = leaveHotJournal(path)
+	}
+	if err != nil {
+		os.Remove(path)
+		os.Remove(path + "-journal")
+		return err
+	}
+	return nil
+}
+
+// withDefaults returns opts with defaults for unset options.
+func withDefaults(opts Options) Options {
+	if opts.MaxZoom < opts.MinZoom {
+		opts.MaxZoom = opts.MinZoom
+	}
+	if opts.Bounds == nil {
+		opts.Bounds = []float64{-180, -maxLatitude, 180, maxLatitude}
+	}
+	if opts.Format == mbtiles.UNKNOWN {
+		opts.Format = mbtiles.PNG
+	}
+	if opts.TileSize == 0 {
+		opts.TileSize = 256
+	}
+	return opts
+}
+
+// create writes the file of Create in a single transaction.
+func create(path string, opts Options) error {
+	pool, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"create table metadata (name text, value text)",
+		"create unique index name on metadata (name)",
+	}
+	if opts.Deduplicated {
+		statements = append(statements,
+			"create table map (zoom_level integer, tile_column integer, tile_row integer, tile_id text)",
+			"create unique index map_index on map (zoom_level, tile_column, tile_row)",
+			"create table images (tile_data blob, tile_id text)",
+			"create unique index images_id on images (tile_id)",
+			"create view tiles as select map.zoom_level as zoom_level, map.tile_column as tile_column, map.tile_row as tile_row, images.tile_data as tile_data from map join images on images.tile_id = map.tile_id",
+		)
+	} else {
+		statements = append(statements,
+			"create table tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+			"create unique index tile_index on tiles (zoom_level, tile_column, tile_row)",
+		)
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range metadata(opts) {
+		if _, err := tx.Exec("insert into metadata (name, value) values (?, ?)", name, value); err != nil {
+			return err
+		}
+	}
+
+	for _, coord := range Coords(opts) {
+		data, err := TileData(opts, coord)
+		if err != nil {
+			return err
+		}
+		if data, err = defectiveData(opts, coord, data); err != nil {
+			return err
+		}
+		if err := insertTile(tx, opts, coord, data); err != nil {
+			return err
+		}
+	}
+	if opts.Defects&OutOfRangeRow != 0 {
+		coord := DefectiveTile(opts, OutOfRangeRow)
+		data, err := TileData(opts, coord)
+		if err != nil {
+			return err
+		}
+		if err := insertTile(tx, opts, coord, data); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// defectiveData returns the data of the tile at coord with the tile defects
+// of opts that affect it.
+func defectiveData(opts Options, coord mbtiles.TileCoord, data []byte) ([]byte, error) {
+	switch {
+	case opts.Defects&EmptyTile != 0 && coord == DefectiveTile(opts, EmptyTile):
+		return []byte{}, nil
+	case opts.Defects&TruncatedTile != 0 && coord == DefectiveTile(opts, TruncatedTile):
+		return data[:len(data)/2], nil
+	case opts.Defects&MixedFormats != 0 && coord == DefectiveTile(opts, MixedFormats):
+		other := opts
+		other.Format = mbtiles.JPG
+		if opts.Format == mbtiles.JPG {
+			other.Format = mbtiles.PNG
+		}
+		return TileData(other, coord)
+	}
+	return data, nil
+}
+
+// leaveHotJournal leaves a hot rollback journal next to the file at path, by
+// copying the journal of a transaction that is rolled back.  The journal
+// holds the original pages of the file, so rolling it back does not change
+// the file.
+func leaveHotJournal(path string) error {
+	// a page cache of 1 page spills changes to the file, which creates the
+	// journal before the transaction ends
+	pool, err := sql.Open("sqlite", path+"?_pragma=journal_mode(delete)&_pragma=cache_size(1)")
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("create table hot (data blob)"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("insert into hot (data) values (zeroblob(1048576))"); err != nil {
+		return err
+	}
+	journal, err := os.ReadFile(path + "-journal")
+	if err != nil {
+		return err
+	}
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	if err := pool.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path+"-journal", journal, 0644)
+}
+
+// insertTile inserts data as the tile at coord (TMS scheme).
+func insertTile(tx *sql.Tx, opts Options, coord mbtiles.TileCoord, data []byte) error {
+	if !opts.Deduplicated {
+		_, err := tx.Exec("insert into tiles (zoom_level, tile_column, tile_row, tile_data) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, data)
+		return err
+	}
+	id := coord.String()
+	if _, err := tx.Exec("insert into images (tile_data, tile_id) values (?, ?)", data, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("insert into map (zoom_level, tile_column, tile_row, tile_id) values (?, ?, ?, ?)", coord.Z, coord.X, coord.Y, id)
+	return err
+}
+
+// metadata returns the metadata items of the file of opts.
+func metadata(opts Options) map[string]string {
+	b := opts.Bounds
+	items := map[string]string{
+		"name":    "testtiles",
+		"format":  opts.Format.String(),
+		"minzoom": strconv.Itoa(opts.MinZoom),
+		"maxzoom": strconv.Itoa(opts.MaxZoom),
+		"bounds":  fmt.Sprintf("%g,%g,%g,%g", b[0], b[1], b[2], b[3]),
+		"center":  fmt.Sprintf("%g,%g,%d", (b[0]+b[2])/2, (b[1]+b[3])/2, opts.MinZoom),
+		"type":    "baselayer",
+	}
+	if opts.Format == mbtiles.PBF {
+		items["json"] = fmt.Sprintf(`{"vector_layers":[{"id":%q,"fields":{"coord":"String"},"minzoom":%d,"maxzoom":%d}]}`, LayerName, opts.MinZoom, opts.MaxZoom)
+	}
+	for name, value := range opts.Metadata {
+		items[name] = value
+	}
+	if opts.Defects&MissingMetadata != 0 {
+		delete(items, "name")
+		delete(items, "format")
+	}
+	return items
+}
+
+// Coords returns the coordinates (TMS scheme) of the tiles of the file of
+// opts, ordered by zoom level, column, and row.
+func Coords(opts Options) []mbtiles.TileCoord {
+	opts = withDefaults(opts)
+	var coords []mbtiles.TileCoord
+	for z := int64(opts.MinZoom); z <= int64(opts.MaxZoom); z++ {
+		topLeft := mbtiles.TileCoordFromLonLat(opts.Bounds[0], opts.Bounds[3], z)
+		bottomRight := mbtiles.TileCoordFromLonLat(opts.Bounds[2], opts.Bounds[1], z)
+		for x := topLeft.X; x <= bottomRight.X; x++ {
+			// rows in the TMS scheme increase from bottom to top
+			for y := bottomRight.Y; y >= topLeft.Y; y-- {
+				coords = append(coords, mbtiles.TileCoord{Z: z, X: x, Y: y}.FlipY())
+			}
+		}
+	}
+	return coords
+}
+
+// DefectiveTile returns the coordinates (TMS scheme) of the tile affected by
+// defect, one of EmptyTile, MixedFormats, OutOfRangeRow, or TruncatedTile, in
+// files generated with opts.  The tile of OutOfRangeRow is not returned by
+// Coords.
+func DefectiveTile(opts Options, defect Defect) mbtiles.TileCoord {
+	opts = withDefaults(opts)
+	coords := Coords(Options{MinZoom: opts.MaxZoom, MaxZoom: opts.MaxZoom, Bounds: opts.Bounds})
+	switch defect {
+	case MixedFormats:
+		return coords[len(coords)-1]
+	case OutOfRangeRow:
+		return mbtiles.TileCoord{Z: int64(opts.MaxZoom), X: coords[0].X, Y: int64(1) << opts.MaxZoom}
+	case TruncatedTile:
+		return coords[len(coords)/2]
+	}
+	return coords[0]
+}
+
+// TileData returns the data of the tile at coord (TMS scheme) in files
+// generated with opts, without defects.  Raster tiles have a uniform color
+// that differs between tiles; vector tiles have one point feature in
+// LayerName, at the center of the tile, with the coordinates of the tile as
+// its coord property.
+func TileData(opts Options, coord mbtiles.TileCoord) ([]byte, error) {
+	opts = withDefaults(opts)
+	switch opts.Format {
+	case mbtiles.PNG, mbtiles.JPG:
+		img := image.NewNRGBA(image.Rect(0, 0, opts.TileSize, opts.TileSize))
+		c := tileColor(coord)
+		for p := 0; p < len(img.Pix); p += 4 {
+			img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3] = c.R, c.G, c.B, c.A
+		}
+		var buf bytes.Buffer
+		var err error
+		if opts.Format == mbtiles.JPG {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+		} else {
+			err = png.Encode(&buf, img)
+		}
+		return buf.Bytes(), err
+	case mbtiles.PBF:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(pointTile(coord)); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported tile format %v", opts.Format)
+}
+
+// tileColor returns the color of the raster tile at coord, which differs for
+// each tile of the zoom levels of typical test files.
+func tileColor(coord mbtiles.TileCoord) color.NRGBA {
+	hash := uint32(coord.Z)*73856093 ^ uint32(coord.X)*19349663 ^ uint32(coord.Y)*83492791
+	return color.NRGBA{R: uint8(hash), G: uint8(hash >> 8), B: uint8(hash >> 16), A: 255}
+}
+
+// pointTile encodes an uncompressed Mapbox Vector Tile with one point feature
+// at the center of the tile in LayerName.
+func pointTile(coord mbtiles.TileCoord) []byte {
+	const extent = 4096
+	// geometry: MoveTo 1 point at the center, zigzag-encoded
+	center := uint64(extent/2) << 1
+	var geometry []byte
+	geometry = appendVarint(geometry, 1<<3|1)
+	geometry = appendVarint(geometry, center)
+	geometry = appendVarint(geometry, center)
+
+	var feature []byte
+	feature = appendBytes(feature, 2, []byte{0, 0}) // tags: key 0, value 0
+	feature = appendVarintField(feature, 3, 1)      // type: point
+	feature = appendBytes(feature, 4, geometry)
+
+	value := appendBytes(nil, 1, []byte(coord.String())) // string value
+
+	var layer []byte
+	layer = appendVarintField(layer, 15, 2) // version
+	layer = appendBytes(layer, 1, []byte(LayerName))
+	layer = appendBytes(layer, 2, feature)
+	layer = appendBytes(layer, 3, []byte("coord"))
+	layer = appendBytes(layer, 4, value)
+	layer = appendVarintField(layer, 5, extent)
+
+	return appendBytes(nil, 3, layer)
+}
+
+// appendVarint appends v to b as a protocol buffers varint.
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendVarintField appends a varint field to a protocol buffers message.
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendVarint(b, uint64(field)<<3)
+	return appendVarint(b, v)
+}
+
+// appendBytes appends a length-delimited field to a protocol buffers message.
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|2)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// maxLatitude is the maximum latitude of the Web Mercator projection.
+var maxLatitude = math.Atan(math.Sinh(math.Pi)) * 180 / math.Pi
@@ -0,0 +1,121 @@
+package testtiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mbtiles "github.com/brendan-ward/mbtiles-go"
+)
+
+func Test_New(t *testing.T) {
+	ctx := context.Background()
+	for _, opts := range []Options{
+		{MaxZoom: 2},
+		{MinZoom: 1, MaxZoom: 3, Format: mbtiles.JPG, TileSize: 512, Bounds: []float64{0, 0, 10, 10}},
+		{MaxZoom: 2, Format: mbtiles.PBF, Deduplicated: true},
+	} {
+		db, err := mbtiles.Open(New(t, opts))
+		if err != nil {
+			t.Fatal("Could not open generated file:", err)
+		}
+		if db.GetTileFormat() != withDefaults(opts).Format {
+			t.Error("Tile format does not match expected value, got:", db.GetTileFormat())
+		}
+		coords := Coords(opts)
+		if len(coords) == 0 {
+			t.Fatal("Expected tiles for:", opts)
+		}
+		for _, coord := range coords {
+			expected, err := TileData(opts, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y)
+			if err != nil || !bytes.Equal(data, expected) {
+				t.Error("Tile does not match expected value:", coord, err)
+			}
+		}
+		if z, err := db.GetMaxZoom(); err != nil || z != withDefaults(opts).MaxZoom {
+			t.Error("Max zoom does not match expected value, got:", z, err)
+		}
+		db.Close()
+	}
+
+	// files are deterministic
+	a, _ := TileData(Options{}, mbtiles.TileCoord{Z: 1, X: 0, Y: 1})
+	b, _ := TileData(Options{}, mbtiles.TileCoord{Z: 1, X: 0, Y: 1})
+	c, _ := TileData(Options{}, mbtiles.TileCoord{Z: 1, X: 1, Y: 1})
+	if !bytes.Equal(a, b) || bytes.Equal(a, c) {
+		t.Error("Expected the same tiles for the same coordinates and different tiles otherwise")
+	}
+}
+
+func Test_Defects(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{MaxZoom: 2, Defects: MissingMetadata | EmptyTile | MixedFormats | OutOfRangeRow | TruncatedTile}
+	db, err := mbtiles.Open(New(t, opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := metadata["name"]; ok {
+		t.Error("Expected name to be missing, got:", metadata)
+	}
+
+	coord := DefectiveTile(opts, EmptyTile)
+	if data, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil || len(data) != 0 {
+		t.Error("Expected empty tile, got:", len(data), err)
+	}
+	coord = DefectiveTile(opts, MixedFormats)
+	if data, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil || mbtiles.TileFormatFromExtension(formatOf(data)) != mbtiles.JPG {
+		t.Error("Expected JPG tile, got:", err)
+	}
+	coord = DefectiveTile(opts, TruncatedTile)
+	expected, err := TileData(opts, coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil || len(data) != len(expected)/2 {
+		t.Error("Expected truncated tile, got:", len(data), err)
+	}
+	coord = DefectiveTile(opts, OutOfRangeRow)
+	if _, err := db.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil || coord.Validate() == nil {
+		t.Error("Expected tile with invalid coordinates, got:", coord, err)
+	}
+	if opts.Defects.String() != "MissingMetadata|EmptyTile|MixedFormats|OutOfRangeRow|TruncatedTile" {
+		t.Error("Defect names do not match expected value, got:", opts.Defects.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "test.mbtiles")
+	if err := Create(path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(path, opts); err == nil {
+		t.Error("Expected error for existing destination")
+	}
+}
+
+func Test_HotJournal(t *testing.T) {
+	path := New(t, Options{MaxZoom: 1, Defects: HotJournal})
+	if stat, err := os.Stat(path + "-journal"); err != nil || stat.Size() == 0 {
+		t.Fatal("Expected hot journal, got:", err)
+	}
+	if db, err := mbtiles.Open(path); err == nil {
+		db.Close()
+		t.Error("Expected error opening file with hot journal")
+	}
+}
+
+// formatOf returns the name of the format of data.
+func formatOf(data []byte) string {
+	format, _ := mbtiles.ParseTileFormat(data)
+	return format.String()
+}
@@ -0,0 +1,425 @@
+package mbtiles
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TileSource is a source of tiles addressed in the TMS scheme, such as an
+// MBtiles handle, an Overlay, Replicas, or an HTTPTileSource.  GetTile must
+// return ErrTileNotFound if the tile does not exist.
+type TileSource interface {
+	GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error)
+}
+
+// HTTPTileSource reads tiles from a remote tile server.
+type HTTPTileSource struct {
+	// URL is the template of tile URLs, with {z}, {x}, and {y} placeholders
+	// for tile coordinates in Scheme, such as
+	// "https://tiles.example.com/world/{z}/{x}/{y}.pbf".
+	URL    string
+	Client *http.Client // http.DefaultClient if nil
+	// Scheme is the row numbering of {y} in URL; XYZScheme if not set.
+	Scheme TileScheme
+}
+
+// GetTile requests the tile for z, x, y (TMS scheme).  ErrTileNotFound is
+// returned if the server responds with 404 Not Found or 204 No Content.
+func (s *HTTPTileSource) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if s.Scheme != TMSScheme {
+		coord = coord.FlipY()
+	}
+	url := strings.NewReplacer(
+		"{z}", strconv.FormatInt(coord.Z, 10),
+		"{x}", strconv.FormatInt(coord.X, 10),
+		"{y}", strconv.FormatInt(coord.Y, 10),
+	).Replace(s.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusNoContent:
+		return nil, ErrTileNotFound
+	default:
+		return nil, fmt.Errorf("unexpected status for tile %v: %s", coord, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Tiered serves tiles from a local mbtiles file used as a hot cache, and
+// reads tiles missing from it from a cold remote source, such as a tile
+// server or an mbtiles file on network storage.  Tiles read from the remote
+// source are optionally written to the local file, so that each tile is
+// fetched once, as at a CDN edge.
+//
+// A TierPolicy set with SetPolicy keeps some zoom levels local and limits the
+// size of the other tiles in the local file.
+type Tiered struct {
+	local     *MBtiles
+	remote    TileSource
+	writeBack bool
+
+	mu     sync.Mutex
+	policy *TierPolicy // nil if no policy is set
+	usage  *list.List  // of *cacheEntry for unpinned local tiles, most recently used first, without data
+	tiles  map[TileCoord]*list.Element
+	sizes  map[TileCoord]int64
+	bytes  int64 // total size of unpinned local tiles
+}
+
+// OpenTiered opens the local mbtiles file at localPath for tiles of the given
+// format, creating it if it does not exist, and serves tiles missing from it
+// from remote.  If writeBack is true, tiles read from remote are written to
+// the local file.  Tiles deleted from the remote source remain in the local
+// file until they are deleted there.
+func OpenTiered(localPath string, format TileFormat, remote TileSource, writeBack bool) (*Tiered, error) {
+	if remote == nil {
+		return nil, errors.New("tiered tileset requires a remote source")
+	}
+	local, _, err := openTileStore(localPath, nil, format, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Tiered{local: local, remote: remote, writeBack: writeBack}, nil
+}
+
+// GetTile reads the tile for z, x, y (TMS scheme) from the local file, or from
+// the remote source if it is not in the local file.  ErrTileNotFound is
+// returned if neither has the tile.  If writing a tile to the local file
+// fails, the error is logged and the tile is returned.
+func (t *Tiered) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	data, err := t.local.GetTile(ctx, z, x, y)
+	if err != ErrTileNotFound {
+		if err == nil {
+			t.touch(coord)
+		}
+		return data, err
+	}
+	data, err = t.remote.GetTile(ctx, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	if t.writeBack {
+		if err := t.store(ctx, coord, data); err != nil {
+			t.local.logger().Printf("mbtiles: could not write tile %d/%d/%d to %s: %v", z, x, y, t.local.filename, err)
+		}
+	}
+	return data, nil
+}
+
+// TierPolicy determines which tiles a Tiered tileset keeps in its local file.
+type TierPolicy struct {
+	// PinnedMinZoom and PinnedMaxZoom are the range of zoom levels that are
+	// always kept in the local file, and are fetched by Populate.  No zoom
+	// levels are pinned if PinnedMaxZoom is less than PinnedMinZoom.
+	PinnedMinZoom int
+	PinnedMaxZoom int
+	// PinnedBounds limits the pinned tiles to those that intersect bounds:
+	// [west, south, east, north] in degrees.  All tiles at the pinned zoom
+	// levels are pinned if nil.
+	PinnedBounds []float64
+	// MaxBytes is the maximum total size of the other tiles in the local file.
+	// The least recently used tiles are deleted from the local file to stay
+	// within the budget.  The size is not limited if MaxBytes is 0.
+	MaxBytes int64
+}
+
+// pinned returns true if the policy pins the tile at coord (TMS scheme).
+func (p *TierPolicy) pinned(coord TileCoord) bool {
+	if coord.Z < int64(p.PinnedMinZoom) || coord.Z > int64(p.PinnedMaxZoom) {
+		return false
+	}
+	if p.PinnedBounds == nil {
+		return true
+	}
+	topLeft, bottomRight, err := tileRange(p.PinnedBounds, coord.Z)
+	if err != nil {
+		return false
+	}
+	xyz := coord.FlipY()
+	return xyz.X >= topLeft.X && xyz.X <= bottomRight.X && xyz.Y >= topLeft.Y && xyz.Y <= bottomRight.Y
+}
+
+// SetPolicy sets the policy of the tiered tileset.  The tiles already in the
+// local file that are not pinned are tracked as least recently used in zoom
+// level order, highest first, and deleted as needed to stay within the byte
+// budget of the policy.
+func (t *Tiered) SetPolicy(ctx context.Context, policy TierPolicy) error {
+	tracked, err := t.trackPolicy(ctx, policy)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.policy = tracked.policy
+	t.usage, t.tiles, t.sizes, t.bytes = tracked.usage, tracked.tiles, tracked.sizes, tracked.bytes
+	evict := t.overBudget()
+	t.mu.Unlock()
+	return t.deleteLocal(ctx, evict)
+}
+
+// SetPolicyDryRun reports the tiles that SetPolicy would delete from the local
+// file to stay within the byte budget of policy, without changing the policy
+// or the local file.
+func (t *Tiered) SetPolicyDryRun(ctx context.Context, policy TierPolicy) (DryRunReport, error) {
+	tracked, err := t.trackPolicy(ctx, policy)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+	total := tracked.bytes
+	evict := tracked.overBudget()
+	return DryRunReport{Tiles: int64(len(evict)), Bytes: total - tracked.bytes}, nil
+}
+
+// trackPolicy returns a Tiered that only holds policy and the tracking of the
+// unpinned tiles in the local file under it, as described for SetPolicy.
+func (t *Tiered) trackPolicy(ctx context.Context, policy TierPolicy) (*Tiered, error) {
+	if policy.PinnedBounds != nil {
+		if err := validateBounds(policy.PinnedBounds); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := t.local.traced(t.local.pool).QueryContext(ctx, "select zoom_level, tile_column, tile_row, length(tile_data) from tiles order by zoom_level desc")
+	if err != nil {
+		return nil, err
+	}
+	tracked := &Tiered{
+		policy: &policy,
+		usage:  list.New(),
+		tiles:  make(map[TileCoord]*list.Element),
+		sizes:  make(map[TileCoord]int64),
+	}
+	for rows.Next() {
+		var (
+			coord TileCoord
+			size  int64
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if policy.pinned(coord) {
+			continue
+		}
+		tracked.tiles[coord] = tracked.usage.PushFront(&cacheEntry{coord: coord})
+		tracked.sizes[coord] = size
+		tracked.bytes += size
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tracked, nil
+}
+
+// touch marks the unpinned local tile at coord as most recently used.
+func (t *Tiered) touch(coord TileCoord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.tiles[coord]; ok {
+		t.usage.MoveToFront(elem)
+	}
+}
+
+// store writes a tile read from the remote source to the local file, and
+// deletes the least recently used unpinned tiles if the budget is exceeded.
+func (t *Tiered) store(ctx context.Context, coord TileCoord, data []byte) error {
+	if err := t.local.insertTile(ctx, coord.Z, coord.X, coord.Y, data); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	if t.policy == nil || t.policy.pinned(coord) {
+		t.mu.Unlock()
+		return nil
+	}
+	if elem, ok := t.tiles[coord]; ok {
+		t.usage.MoveToFront(elem)
+		t.bytes -= t.sizes[coord]
+	} else {
+		t.tiles[coord] = t.usage.PushFront(&cacheEntry{coord: coord})
+	}
+	t.sizes[coord] = int64(len(data))
+	t.bytes += int64(len(data))
+	evict := t.overBudget()
+	t.mu.Unlock()
+	return t.deleteLocal(ctx, evict)
+}
+
+// overBudget removes the least recently used unpinned tiles from tracking
+// until their total size is within the budget, and returns them so that they
+// are deleted from the local file.  t.mu must be held.
+func (t *Tiered) overBudget() []TileCoord {
+	if t.policy.MaxBytes <= 0 {
+		return nil
+	}
+	var evict []TileCoord
+	for t.bytes > t.policy.MaxBytes && t.usage.Len() > 0 {
+		oldest := t.usage.Back()
+		coord := oldest.Value.(*cacheEntry).coord
+		t.usage.Remove(oldest)
+		delete(t.tiles, coord)
+		t.bytes -= t.sizes[coord]
+		delete(t.sizes, coord)
+		evict = append(evict, coord)
+	}
+	return evict
+}
+
+// deleteLocal deletes tiles from the local file in a single transaction.
+func (t *Tiered) deleteLocal(ctx context.Context, coords []TileCoord) error {
+	if len(coords) == 0 {
+		return nil
+	}
+	db := t.local
+	tx, err := db.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := db.traced(tx)
+
+	schema, err := db.writeSchema(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, coord := range coords {
+		if err := logChange(ctx, q, schema, ChangeDelete, coord, ""); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, "delete from "+schema.table+" where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y); err != nil {
+			return err
+		}
+	}
+	if schema.deduplicated {
+		if err := deleteUnreferencedImages(ctx, q); err != nil {
+			return err
+		}
+	}
+	if err := db.bumpVersion(ctx, q); err != nil {
+		return err
+	}
+	if err := db.syncMetadata(ctx, q, schema); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.tilesChanged()
+	return nil
+}
+
+// PopulateProgress reports the progress of Populate.
+type PopulateProgress struct {
+	Total   int64 // number of pinned tiles
+	Done    int64 // number of pinned tiles checked so far
+	Fetched int64 // number of tiles fetched from the remote source
+	Missing int64 // number of pinned tiles that the remote source does not have
+	Bytes   int64 // total size of fetched tiles
+}
+
+// Populate fetches the pinned tiles of the policy that are missing from the
+// local file from the remote source, and writes them to the local file, so
+// that they are served locally from the first request.  progress, if not nil,
+// is called after each tile is checked.  Populate blocks until all pinned
+// tiles are checked or ctx is done, so it is usually called in its own
+// goroutine; calling it again fetches tiles that are still missing.
+func (t *Tiered) Populate(ctx context.Context, progress func(PopulateProgress)) (PopulateProgress, error) {
+	t.mu.Lock()
+	policy := t.policy
+	t.mu.Unlock()
+	if policy == nil {
+		return PopulateProgress{}, errors.New("tiered tileset does not have a policy")
+	}
+
+	bounds := policy.PinnedBounds
+	if bounds == nil {
+		bounds = []float64{-180, -maxLatitude, 180, maxLatitude}
+	}
+	var status PopulateProgress
+	type zoomRange struct{ topLeft, bottomRight TileCoord }
+	var ranges []zoomRange
+	for z := int64(policy.PinnedMinZoom); z <= int64(policy.PinnedMaxZoom); z++ {
+		topLeft, bottomRight, err := tileRange(bounds, z)
+		if err != nil {
+			return status, err
+		}
+		ranges = append(ranges, zoomRange{topLeft, bottomRight})
+		status.Total += (bottomRight.X - topLeft.X + 1) * (bottomRight.Y - topLeft.Y + 1)
+	}
+
+	for _, r := range ranges {
+		for x := r.topLeft.X; x <= r.bottomRight.X; x++ {
+			for y := r.topLeft.Y; y <= r.bottomRight.Y; y++ {
+				if err := ctx.Err(); err != nil {
+					return status, err
+				}
+				coord := TileCoord{Z: r.topLeft.Z, X: x, Y: y}.FlipY()
+				if err := t.populateTile(ctx, coord, &status); err != nil {
+					return status, err
+				}
+				status.Done++
+				if progress != nil {
+					progress(status)
+				}
+			}
+		}
+	}
+	return status, nil
+}
+
+// populateTile fetches the tile at coord (TMS scheme) from the remote source
+// if it is missing from the local file.
+func (t *Tiered) populateTile(ctx context.Context, coord TileCoord, status *PopulateProgress) error {
+	var exists int
+	err := t.local.traced(t.local.pool).QueryRowContext(ctx, "select count(*) from tiles where zoom_level = ? and tile_column = ? and tile_row = ?", coord.Z, coord.X, coord.Y).Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+	data, err := t.remote.GetTile(ctx, coord.Z, coord.X, coord.Y)
+	if err == ErrTileNotFound {
+		status.Missing++
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := t.local.insertTile(ctx, coord.Z, coord.X, coord.Y, data); err != nil {
+		return err
+	}
+	status.Fetched++
+	status.Bytes += int64(len(data))
+	return nil
+}
+
+// Local returns the local mbtiles file.
+func (t *Tiered) Local() *MBtiles {
+	return t.local
+}
+
+// Close closes the local mbtiles file.  The remote source is not closed.
+func (t *Tiered) Close() {
+	t.local.Close()
+}
@@ -0,0 +1,152 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Tiered(t *testing.T) {
+	ctx := context.Background()
+	remote, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		var z, x, y int64
+		fmt.Sscanf(r.URL.Path, "/%d/%d/%d.png", &z, &x, &y)
+		coord := TileCoord{Z: z, X: x, Y: y}.FlipY()
+		data, err := remote.GetTile(r.Context(), coord.Z, coord.X, coord.Y)
+		if err == ErrTileNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	localPath := filepath.Join(t.TempDir(), "local.mbtiles")
+	tiered, err := OpenTiered(localPath, PNG, &HTTPTileSource{URL: server.URL + "/{z}/{x}/{y}.png"}, true)
+	if err != nil {
+		t.Fatal("Could not open tiered tileset:", err)
+	}
+	defer tiered.Close()
+
+	expected, err := remote.GetTile(ctx, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		data, err := tiered.GetTile(ctx, 1, 0, 1)
+		if err != nil || !bytes.Equal(data, expected) {
+			t.Fatal("Tile does not match expected value:", err)
+		}
+	}
+	// TMS row 1 at zoom 1 is XYZ row 0, and the second read is served locally
+	if len(requests) != 1 || requests[0] != "/1/0/0.png" {
+		t.Error("Expected a single remote request in the XYZ scheme, got:", requests)
+	}
+	if data, err := tiered.Local().GetTile(ctx, 1, 0, 1); err != nil || !bytes.Equal(data, expected) {
+		t.Error("Expected tile to be written to the local file, got:", err)
+	}
+
+	if _, err := tiered.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+
+	// other tile sources can be used as the remote source
+	direct, err := OpenTiered(filepath.Join(t.TempDir(), "direct.mbtiles"), PNG, remote, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer direct.Close()
+	if _, err := direct.GetTile(ctx, 0, 0, 0); err != nil {
+		t.Error("Could not read tile from mbtiles remote source:", err)
+	}
+	if _, err := direct.Local().GetTile(ctx, 0, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected tile not to be written without write-back, got:", err)
+	}
+}
+
+func Test_Tiered_policy(t *testing.T) {
+	ctx := context.Background()
+	remote, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	tiered, err := OpenTiered(filepath.Join(t.TempDir(), "local.mbtiles"), PNG, remote, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tiered.Close()
+
+	if _, err := tiered.Populate(ctx, nil); err == nil {
+		t.Error("Expected error without policy")
+	}
+
+	a, err := remote.GetTile(ctx, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := remote.GetTile(ctx, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := TierPolicy{PinnedMinZoom: 0, PinnedMaxZoom: 0, MaxBytes: int64(len(a) + len(c))}
+	if err := tiered.SetPolicy(ctx, policy); err != nil {
+		t.Fatal("Could not set policy:", err)
+	}
+
+	var updates int
+	status, err := tiered.Populate(ctx, func(PopulateProgress) { updates++ })
+	if err != nil {
+		t.Fatal("Could not populate:", err)
+	}
+	if status.Total != 1 || status.Done != 1 || status.Fetched != 1 || updates != 1 {
+		t.Error("Progress does not match expected values, got:", status, updates)
+	}
+	if status, _ := tiered.Populate(ctx, nil); status.Fetched != 0 {
+		t.Error("Expected no tiles to be fetched again, got:", status)
+	}
+
+	// unpinned tiles are kept within the budget, evicting the least recently
+	// used first
+	for _, coord := range []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 1, X: 1, Y: 0}, {Z: 1, X: 0, Y: 0}, {Z: 1, X: 0, Y: 1}} {
+		if _, err := tiered.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local := tiered.Local()
+	if _, err := local.GetTile(ctx, 1, 1, 0); err != ErrTileNotFound {
+		t.Error("Expected least recently used tile to be evicted, got:", err)
+	}
+	for _, coord := range []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 1, X: 0, Y: 1}, {Z: 0, X: 0, Y: 0}} {
+		if _, err := local.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil {
+			t.Error("Expected tile to be kept:", coord, err)
+		}
+	}
+
+	// a dry run reports the tiles a smaller budget would evict, but keeps them
+	policy.MaxBytes--
+	report, err := tiered.SetPolicyDryRun(ctx, policy)
+	if err != nil {
+		t.Fatal("Could not report policy:", err)
+	}
+	if report.Tiles != 1 || (report.Bytes != int64(len(a)) && report.Bytes != int64(len(c))) {
+		t.Error("Eviction report does not match expected values, got:", report)
+	}
+	for _, coord := range []TileCoord{{Z: 1, X: 0, Y: 0}, {Z: 1, X: 0, Y: 1}} {
+		if _, err := local.GetTile(ctx, coord.Z, coord.X, coord.Y); err != nil {
+			t.Error("Expected tile to be kept by dry run:", coord, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"image/jpeg"
+	"strings"
 )
 
 // TileFormat defines the tile format of tiles an mbtiles file.  Supported image
@@ -64,6 +65,23 @@ func (t TileFormat) MimeType() string {
 	}
 }
 
+// TileFormatFromExtension returns the TileFormat for a file extension, with or
+// without a leading ".".  Returns UNKNOWN if the extension is not recognized.
+func TileFormatFromExtension(ext string) TileFormat {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "png":
+		return PNG
+	case "jpg", "jpeg":
+		return JPG
+	case "pbf", "mvt":
+		return PBF
+	case "webp":
+		return WEBP
+	default:
+		return UNKNOWN
+	}
+}
+
 var formatPrefixes = map[TileFormat][]byte{
 	GZIP: []byte("\x1f\x8b"), // this masks PBF format too
 	ZLIB: []byte("\x78\x9c"),
@@ -75,10 +93,12 @@ var formatPrefixes = map[TileFormat][]byte{
 	WEBP: []byte("\x52\x49\x46\x46"),
 }
 
-// detectFileFormat inspects the first few bytes of byte array to determine tile
-// format PBF tile format does not have a distinct signature, it will be
-// returned as GZIP, and it is up to caller to determine that it is a PBF format.
-func detectTileFormat(data []byte) (TileFormat, error) {
+// ParseTileFormat inspects the first few bytes of the data of a tile to
+// determine its format.  PBF tiles do not have a distinct signature, so
+// gzip-compressed data is returned as GZIP, and it is up to the caller to
+// determine that it is a PBF tile.  ParseTileFormat does not panic for any
+// input, so it can be used on tiles of untrusted files such as uploads.
+func ParseTileFormat(data []byte) (TileFormat, error) {
 	for format, pattern := range formatPrefixes {
 		if bytes.HasPrefix(data, pattern) {
 			return format, nil
@@ -88,10 +108,12 @@ func detectTileFormat(data []byte) (TileFormat, error) {
 	return UNKNOWN, errors.New("could not detect tile format")
 }
 
-// detectTileSize reads tile dimensions from image tiles, and otherwise assumes
+// ParseTileSize reads tile dimensions from image tiles, and otherwise assumes
 // 512px size for PBF tiles.  Tiles are assumed to be square.
-// Data must contain at least the first 20 bytes of the beginning of a tile.
-func detectTileSize(format TileFormat, data []byte) (uint32, error) {
+// Data must contain at least the first 30 bytes of the beginning of a tile.
+// An error is returned if data is too short or malformed, and 0 if the size
+// cannot be detected for format; ParseTileSize does not panic for any input.
+func ParseTileSize(format TileFormat, data []byte) (uint32, error) {
 	switch format {
 	// PBF files are always 512px
 	// GZIP masks PBF, which is only expected type for tiles in GZIP format
@@ -114,11 +136,14 @@ func detectTileSize(format TileFormat, data []byte) (uint32, error) {
 		return uint32(cfg.Width), nil
 	case WEBP:
 		// Webp is a more complex structure with different bit-level encodings
+		if len(data) < 16 {
+			return 0, errors.New("insufficient length to detect webp image size")
+		}
 		encType := data[12:16]
 		switch {
 		case bytes.HasPrefix(encType, []byte("VP8 ")): // Lossy
 			// width appears to be at index 26-27
-			if len(data) < 27 {
+			if len(data) < 28 {
 				return 0, errors.New("insufficient length to detect webp image size")
 			}
 
@@ -133,12 +158,13 @@ func detectTileSize(format TileFormat, data []byte) (uint32, error) {
 			return uint32(binary.LittleEndian.Uint16(data[21:23])&0x1ff) + 1, nil
 
 		case bytes.HasPrefix(encType, []byte("VP8X")): // Alpha
-			// width is in 24 bits out of bytes 24-26
+			// width is in 24 bits out of bytes 24-26; only the lower 16 bits
+			// are read since tiles are never that large
 			if len(data) < 26 {
 				return 0, errors.New("insufficient length to detect webp image size")
 			}
 
-			return uint32(binary.LittleEndian.Uint16(data[24:27])) + 1, nil
+			return uint32(binary.LittleEndian.Uint16(data[24:26])) + 1, nil
 		}
 	}
 
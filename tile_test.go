@@ -46,7 +46,7 @@ func Test_DetectTileFormat(t *testing.T) {
 			t.Error("Error decoding hex image data", err)
 		}
 
-		format, err := detectTileFormat(data)
+		format, err := ParseTileFormat(data)
 		if err != nil {
 			t.Error("Error detecting tile format:", err)
 		}
@@ -99,7 +99,7 @@ func Test_DetectTilesize(t *testing.T) {
 			t.Error("Error decoding hex image data", err)
 		}
 
-		tilesize, err := detectTileSize(tc.format, data)
+		tilesize, err := ParseTileSize(tc.format, data)
 		if err != nil {
 			t.Error("Error detecting tile size: ", err)
 		}
@@ -108,3 +108,31 @@ func Test_DetectTilesize(t *testing.T) {
 		}
 	}
 }
+
+func Fuzz_ParseTileSize(f *testing.F) {
+	for _, seed := range []string{
+		"89504e470d0a1a0a0000000d4948445200000100",
+		"52494646e22800005745425056503820d628000092b3009d012a4001",
+		"52494646a43f0100574542505650384c983f01002f8f014b",
+		"52494646ce46000057454250565038580a000000100000008f01",
+		"52494646",
+		"ffd8ff",
+		"1f8b",
+	} {
+		data, err := hex.DecodeString(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		format, err := ParseTileFormat(data)
+		if err != nil {
+			if format != UNKNOWN {
+				t.Error("Expected UNKNOWN format with error, got:", format)
+			}
+			return
+		}
+		ParseTileSize(format, data)
+	})
+}
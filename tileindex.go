@@ -0,0 +1,282 @@
+package mbtiles
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+)
+
+// TileIndexExtension is the extension added to the path of an mbtiles file to
+// get the default path of its tile index file.
+const TileIndexExtension = ".tileindex"
+
+// Layout of tile index files: a header of the magic, the size and
+// modification time in nanoseconds of the indexed mbtiles file, and the
+// number of entries, followed by entries of the zoom level, column, row
+// (TMS scheme), and size of each tile and the MD5 hash of its data, in order
+// of coordinates.  Integers are little endian.
+const (
+	tileIndexMagic      = "MBTIDX\x00\x01"
+	tileIndexHeaderSize = 32
+	tileIndexEntrySize  = 32
+)
+
+// SharedTileIndex makes the handle map the tile index file at path into
+// memory, or at the path of the mbtiles file with TileIndexExtension added if
+// path is empty, for deployments that run several server processes for the
+// same tileset: the index is built by the first process that opens the
+// tileset, and shared by all of them through the page cache, without
+// duplicated scans of the mbtiles file at startup.
+//
+// Tiles that are not in the index are found missing without querying the
+// mbtiles file, and GetTileETag returns ETags of the MD5 hash of the tile data
+// from the index, for all schemas.  The index is built again if it does not
+// match the size and modification time of the mbtiles file, and is no longer
+// used once tiles are written using this handle, or RefreshTimestamp detects
+// that the file was modified, until Reload.  Index files can also be built
+// ahead of time with WriteTileIndex.
+func SharedTileIndex(path string) OpenOption {
+	return func(o *openOptions) {
+		o.tileIndex = true
+		o.tileIndexPath = path
+	}
+}
+
+// sharedIndex is a tile index file mapped into memory.
+type sharedIndex struct {
+	data    []byte // the mapped file
+	entries []byte // the entries within data
+	stale   uint32 // set to 1 atomically when tiles may have changed
+}
+
+// WriteTileIndex builds the tile index file of the mbtiles file at path, for
+// SharedTileIndex, by reading all tiles.  The file is written to a temporary
+// file that is renamed over path, so that processes that map the previous
+// index are not affected.
+func (db *MBtiles) WriteTileIndex(ctx context.Context, path string) error {
+	if db.isClosed() {
+		return errors.New("cannot index closed mbtiles database")
+	}
+	stat, err := os.Stat(db.filename)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	count, err := db.writeTileIndexEntries(ctx, tmp)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, tileIndexHeaderSize)
+	copy(header, tileIndexMagic)
+	binary.LittleEndian.PutUint64(header[8:], uint64(stat.Size()))
+	binary.LittleEndian.PutUint64(header[16:], uint64(stat.ModTime().UnixNano()))
+	binary.LittleEndian.PutUint64(header[24:], count)
+	if _, err := tmp.WriteAt(header, 0); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeTileIndexEntries writes the entries of all tiles to f after the space
+// for the header, and returns their number.
+func (db *MBtiles) writeTileIndexEntries(ctx context.Context, f *os.File) (uint64, error) {
+	if _, err := f.Seek(tileIndexHeaderSize, 0); err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+
+	rows, err := db.traced(db.pool).QueryContext(ctx, "select zoom_level, tile_column, tile_row, tile_data from tiles order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count uint64
+	entry := make([]byte, tileIndexEntrySize)
+	for rows.Next() {
+		var (
+			coord TileCoord
+			data  sql.RawBytes
+		)
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y, &data); err != nil {
+			return 0, err
+		}
+		if coord.Validate() != nil {
+			// tiles with invalid coordinates cannot be requested
+			continue
+		}
+		binary.LittleEndian.PutUint32(entry[0:], uint32(coord.Z))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(coord.X))
+		binary.LittleEndian.PutUint32(entry[8:], uint32(coord.Y))
+		binary.LittleEndian.PutUint32(entry[12:], uint32(len(data)))
+		hash := md5.Sum(data)
+		copy(entry[16:], hash[:])
+		if _, err := w.Write(entry); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return count, w.Flush()
+}
+
+// openTileIndex maps the tile index file of the handle, building it first if
+// it does not exist or does not match the mbtiles file.
+func (db *MBtiles) openTileIndex(ctx context.Context) (*sharedIndex, error) {
+	path := db.options.tileIndexPath
+	if path == "" {
+		path = db.filename + TileIndexExtension
+	}
+	stat, err := os.Stat(db.filename)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := mapTileIndex(path, stat)
+	if err == nil {
+		return index, nil
+	}
+	if err := db.WriteTileIndex(ctx, path); err != nil {
+		return nil, fmt.Errorf("cannot build tile index: %w", err)
+	}
+	return mapTileIndex(path, stat)
+}
+
+// errStaleTileIndex is returned by mapTileIndex for index files that do not
+// match the mbtiles file.
+var errStaleTileIndex = errors.New("tile index does not match mbtiles file")
+
+// mapTileIndex maps the tile index file at path, and checks that it matches
+// the mbtiles file described by stat.
+func mapTileIndex(path string, stat os.FileInfo) (*sharedIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < tileIndexHeaderSize {
+		return nil, errStaleTileIndex
+	}
+	data, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	count := binary.LittleEndian.Uint64(data[24:])
+	if !bytes.Equal(data[:8], []byte(tileIndexMagic)) ||
+		binary.LittleEndian.Uint64(data[8:]) != uint64(stat.Size()) ||
+		binary.LittleEndian.Uint64(data[16:]) != uint64(stat.ModTime().UnixNano()) ||
+		uint64(len(data)-tileIndexHeaderSize) != count*tileIndexEntrySize {
+		unmapFile(data)
+		return nil, errStaleTileIndex
+	}
+	return &sharedIndex{data: data, entries: data[tileIndexHeaderSize:]}, nil
+}
+
+// lookup returns the entry of the tile at coord (TMS scheme), or nil if it is
+// not in the index.
+func (x *sharedIndex) lookup(coord TileCoord) []byte {
+	if coord.Validate() != nil {
+		return nil
+	}
+	key := [3]uint32{uint32(coord.Z), uint32(coord.X), uint32(coord.Y)}
+	n := len(x.entries) / tileIndexEntrySize
+	i := sort.Search(n, func(i int) bool {
+		entry := x.entries[i*tileIndexEntrySize:]
+		for j, k := range key {
+			if v := binary.LittleEndian.Uint32(entry[4*j:]); v != k {
+				return v > k
+			}
+		}
+		return true
+	})
+	if i == n {
+		return nil
+	}
+	entry := x.entries[i*tileIndexEntrySize : (i+1)*tileIndexEntrySize]
+	for j, k := range key {
+		if binary.LittleEndian.Uint32(entry[4*j:]) != k {
+			return nil
+		}
+	}
+	return entry
+}
+
+// markStale stops use of the index after tiles may have changed.  A nil
+// *sharedIndex is ignored.
+func (x *sharedIndex) markStale() {
+	if x != nil {
+		atomic.StoreUint32(&x.stale, 1)
+	}
+}
+
+// close unmaps the index.  A nil *sharedIndex is ignored.
+func (x *sharedIndex) close() {
+	if x != nil {
+		unmapFile(x.data)
+	}
+}
+
+// indexedTile returns the entry of the tile at coord (TMS scheme) in the
+// index of state, or nil, and true if the index is in use.
+func indexedTile(state *handleState, coord TileCoord) ([]byte, bool) {
+	x := state.index
+	if x == nil || atomic.LoadUint32(&x.stale) != 0 {
+		return nil, false
+	}
+	return x.lookup(coord), true
+}
+
+// indexedMissing returns true if the index of SharedTileIndex is in use and
+// does not have the tile at coord (TMS scheme).
+func (db *MBtiles) indexedMissing(coord TileCoord) bool {
+	state, err := db.acquireState()
+	if err != nil {
+		return false
+	}
+	defer state.mu.RUnlock()
+	entry, ok := indexedTile(state, coord)
+	return ok && entry == nil
+}
+
+// indexedETag returns the ETag of the tile at coord (TMS scheme) from the
+// index of SharedTileIndex, or an empty string if the tile does not exist,
+// and true if the index is in use.
+func (db *MBtiles) indexedETag(coord TileCoord) (string, bool) {
+	state, err := db.acquireState()
+	if err != nil {
+		return "", false
+	}
+	defer state.mu.RUnlock()
+	entry, ok := indexedTile(state, coord)
+	if !ok || entry == nil {
+		return "", ok
+	}
+	return `"` + hex.EncodeToString(entry[16:32]) + `"`, true
+}
@@ -0,0 +1,76 @@
+package mbtiles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_SharedTileIndex(t *testing.T) {
+	ctx := context.Background()
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	indexPath := filename + TileIndexExtension
+
+	var queries int64
+	db, err := Open(filename, SharedTileIndex(""), TraceQueries(func(QueryTrace) { atomic.AddInt64(&queries, 1) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatal("Expected tile index to be built on open:", err)
+	}
+	if info.Size() != tileIndexHeaderSize+5*tileIndexEntrySize {
+		t.Error("Tile index size does not match expected value, got:", info.Size())
+	}
+
+	atomic.StoreInt64(&queries, 0)
+	if _, err := db.GetTile(ctx, 2, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+	if _, err := db.OpenTile(ctx, 3, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+	if n := atomic.LoadInt64(&queries); n != 0 {
+		t.Error("Expected missing tiles to be found without queries, got:", n)
+	}
+
+	data, err := db.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := db.GetTileETag(ctx, 0, 0, 0)
+	if err != nil || etag != `"`+hashTile(data)+`"` {
+		t.Error("Expected ETag of the tile hash, got:", etag, err)
+	}
+	if etag, err := db.GetTileETag(ctx, 2, 1, 1); err != nil || etag != "" {
+		t.Error("Expected empty ETag for missing tile, got:", etag, err)
+	}
+
+	// other handles map the existing index
+	other, err := Open(filename, SharedTileIndex(indexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if stat, err := os.Stat(indexPath); err != nil || !stat.ModTime().Equal(info.ModTime()) {
+		t.Error("Expected existing tile index to be used, got:", err)
+	}
+
+	// stale indexes are built again
+	path := filepath.Join(t.TempDir(), "stale.tileindex")
+	if err := os.WriteFile(path, []byte("not a tile index"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := Open(filename, SharedTileIndex(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stale.Close()
+	if _, err := stale.GetTile(ctx, 1, 1, 1); err != nil {
+		t.Error("Could not read tile with rebuilt index:", err)
+	}
+}
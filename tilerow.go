@@ -0,0 +1,53 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReadTileRow reads the tiles in columns xMin through xMax (inclusive) of row y
+// (TMS scheme) at zoom level z using a single query, and returns them in column
+// order: the tile for column x is at index x-xMin, and is nil if the tile does
+// not exist.  Tiles stored in the BlobStore of ExternalBlobs are read from it.
+// Ancestor fallback does not apply.
+func (db *MBtiles) ReadTileRow(ctx context.Context, z int64, y int64, xMin int64, xMax int64) ([][]byte, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot read tiles from closed mbtiles database")
+	}
+	if z < 0 || z > MaxZoomLevel {
+		return nil, fmt.Errorf("invalid zoom level %d", z)
+	}
+	if xMin < 0 || xMax >= int64(1)<<z || xMin > xMax {
+		return nil, fmt.Errorf("invalid tile column range %d-%d for zoom level %d", xMin, xMax, z)
+	}
+
+	if db.options.slowQueryThreshold > 0 {
+		defer db.logSlowQuery(time.Now(), "tile row %d/%d-%d/%d", z, xMin, xMax, y)
+	}
+
+	rows, err := db.traced(db.pool).QueryContext(ctx,
+		"select tile_column, tile_data from tiles where zoom_level = ? and tile_row = ? and tile_column between ? and ?",
+		z, y, xMin, xMax)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiles := make([][]byte, xMax-xMin+1)
+	for rows.Next() {
+		var (
+			x    int64
+			data []byte
+		)
+		if err := rows.Scan(&x, &data); err != nil {
+			return nil, err
+		}
+		if err := db.resolveBlob(ctx, &data); err != nil {
+			return nil, err
+		}
+		tiles[x-xMin] = data
+	}
+	return tiles, rows.Err()
+}
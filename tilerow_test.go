@@ -0,0 +1,50 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func Test_ReadTileRow(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// zoom level 3 has tiles in columns 1 through 7 of row 4
+	tiles, err := db.ReadTileRow(context.Background(), 3, 4, 0, 7)
+	if err != nil {
+		t.Fatal("Unexpected error reading tile row:", err)
+	}
+	if len(tiles) != 8 {
+		t.Fatal("Expected 8 tiles, got:", len(tiles))
+	}
+	if tiles[0] != nil || tiles[1] == nil {
+		t.Error("Expected missing tile at column 0 only")
+	}
+	for x, tile := range tiles {
+		var expected []byte
+		if err := db.ReadTile(3, int64(x), 4, &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tile, expected) || (tile == nil) != (expected == nil) {
+			t.Error("Tile at column", x, "does not match tile read individually")
+		}
+	}
+
+	invalid := []struct {
+		z, xMin, xMax int64
+	}{
+		{z: 3, xMin: 4, xMax: 3},
+		{z: 3, xMin: -1, xMax: 3},
+		{z: 3, xMin: 0, xMax: 8},
+		{z: 31, xMin: 0, xMax: 1},
+	}
+	for _, tc := range invalid {
+		if _, err := db.ReadTileRow(context.Background(), tc.z, 0, tc.xMin, tc.xMax); err == nil {
+			t.Error("Expected error for invalid range:", tc)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package mbtiles
+
+// Tileset is a source of tiles of a single format that holds resources until
+// it is closed, such as an MBtiles handle, an Overlay, or MosaicTiles.  It is
+// the interface to accept in code that serves tiles from any of them; use
+// TileSource for sources that do not need to be closed.
+type Tileset interface {
+	TileSource
+	// GetTileFormat returns the format of the tiles, or UNKNOWN if it is not
+	// known yet.
+	GetTileFormat() TileFormat
+	Close()
+}
+
+var (
+	_ Tileset = (*MBtiles)(nil)
+	_ Tileset = (*Overlay)(nil)
+	_ Tileset = (*MosaicTiles)(nil)
+)
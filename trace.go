@@ -0,0 +1,111 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// QueryTrace describes a SQL statement executed by an MBtiles handle.
+type QueryTrace struct {
+	SQL      string        // SQL of the statement
+	Args     []interface{} // arguments of the statement
+	Duration time.Duration // time taken to execute the statement; for queries, this excludes reading the results
+	Err      error         // error returned executing the statement, if any
+}
+
+// TraceQueries calls hook after every SQL statement executed by the MBtiles
+// handle, to allow integration with application performance monitoring
+// tools.  hook must be safe for concurrent use, and should return quickly.
+func TraceQueries(hook func(QueryTrace)) OpenOption {
+	return func(o *openOptions) {
+		o.traceHook = hook
+	}
+}
+
+// querier is implemented by *sql.DB, *sql.Tx, and *sql.Conn.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// statement is implemented by *sql.Stmt.
+type statement interface {
+	ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row
+}
+
+// tracedStmt wraps stmt, prepared from query, to call the trace hook of the
+// MBtiles handle, if any, after every execution.
+func (db *MBtiles) tracedStmt(stmt *sql.Stmt, query string) statement {
+	if db.options.traceHook == nil {
+		return stmt
+	}
+	return &tracedStatement{stmt: stmt, query: query, hook: db.options.traceHook}
+}
+
+// traced wraps q to call the trace hook of the MBtiles handle, if any, after
+// every statement.
+func (db *MBtiles) traced(q querier) querier {
+	if db.options.traceHook == nil {
+		return q
+	}
+	return &tracedQuerier{q: q, hook: db.options.traceHook}
+}
+
+// trace calls the trace hook of the MBtiles handle, if any, for a statement
+// that started at start.
+func (db *MBtiles) trace(start time.Time, query string, args []interface{}, err error) {
+	if db.options.traceHook != nil {
+		db.options.traceHook(QueryTrace{SQL: query, Args: args, Duration: time.Since(start), Err: err})
+	}
+}
+
+// tracedQuerier calls hook after executing each statement.
+type tracedQuerier struct {
+	q    querier
+	hook func(QueryTrace)
+}
+
+func (t *tracedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.q.ExecContext(ctx, query, args...)
+	t.hook(QueryTrace{SQL: query, Args: args, Duration: time.Since(start), Err: err})
+	return result, err
+}
+
+func (t *tracedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.q.QueryContext(ctx, query, args...)
+	t.hook(QueryTrace{SQL: query, Args: args, Duration: time.Since(start), Err: err})
+	return rows, err
+}
+
+func (t *tracedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := t.q.QueryRowContext(ctx, query, args...)
+	t.hook(QueryTrace{SQL: query, Args: args, Duration: time.Since(start), Err: row.Err()})
+	return row
+}
+
+// tracedStatement calls hook after each execution of a prepared statement.
+type tracedStatement struct {
+	stmt  *sql.Stmt
+	query string
+	hook  func(QueryTrace)
+}
+
+func (t *tracedStatement) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.stmt.ExecContext(ctx, args...)
+	t.hook(QueryTrace{SQL: t.query, Args: args, Duration: time.Since(start), Err: err})
+	return result, err
+}
+
+func (t *tracedStatement) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := t.stmt.QueryRowContext(ctx, args...)
+	t.hook(QueryTrace{SQL: t.query, Args: args, Duration: time.Since(start), Err: row.Err()})
+	return row
+}
@@ -0,0 +1,58 @@
+package mbtiles
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_TraceQueries(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		traces []QueryTrace
+	)
+	hook := func(trace QueryTrace) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces = append(traces, trace)
+	}
+
+	db, err := Open("./testdata/geography-class-png.mbtiles", TraceQueries(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	openTraces := len(traces)
+	if openTraces == 0 {
+		t.Error("Expected queries executed by Open to be traced")
+	}
+
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ReadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(traces) < openTraces+2 {
+		t.Fatal("Expected tile and metadata queries to be traced, got:", traces)
+	}
+	tile := traces[openTraces]
+	if tile.SQL != tileQuery || !reflect.DeepEqual(tile.Args, []interface{}{int64(0), int64(0), int64(0)}) || tile.Err != nil {
+		t.Error("Tile query trace does not match expected value, got:", tile)
+	}
+	metadata := traces[openTraces+1]
+	if metadata.SQL != "select * from metadata where value is not ''" || len(metadata.Args) != 0 || metadata.Err != nil {
+		t.Error("Metadata query trace does not match expected value, got:", metadata)
+	}
+	for _, trace := range traces {
+		if trace.Duration <= 0 {
+			t.Error("Expected positive duration for trace, got:", trace)
+		}
+	}
+}
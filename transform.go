@@ -0,0 +1,93 @@
+package mbtiles
+
+import (
+	"context"
+	"errors"
+)
+
+// TileTransform returns the data to write in place of data for the tile at
+// coord (TMS scheme), as set with WithTileTransform.  Transforms can be used
+// for watermarking, re-encoding, or redaction of tiles as they are copied.
+type TileTransform func(coord TileCoord, data []byte) ([]byte, error)
+
+// transformKey is the context key of the tile transform.
+type transformKey struct{}
+
+// WithTileTransform returns a copy of ctx that makes the copy and export
+// operations it is passed to call transform for each tile they write, and
+// write the data it returns instead: ExtractZoom, ExtractWithinBudget,
+// ExtractGeneralized, Export, SplitLayers, JoinLayers, Mosaic, Composite, and
+// the other operations that create a new mbtiles file from an existing one,
+// as well as TileGeoJSON, ImportTileStream, and WriteTileStream.  Tiles
+// stored in the BlobStore of ExternalBlobs are read from it before they are
+// transformed.  transform is called from a single goroutine at a time, after
+// the tiles are copied and before the new file is published, so operations
+// that copy tiles within SQLite read each tile into Go when a transform is
+// set.  An error returned by transform fails the operation.
+//
+// Snapshot, Publish, and WriteBundle copy the mbtiles file without reading
+// its tiles, and return an error if ctx has a transform.
+func WithTileTransform(ctx context.Context, transform TileTransform) context.Context {
+	return context.WithValue(ctx, transformKey{}, transform)
+}
+
+// tileTransform returns the tile transform of ctx, or nil if it does not have
+// one.
+func tileTransform(ctx context.Context) TileTransform {
+	transform, _ := ctx.Value(transformKey{}).(TileTransform)
+	return transform
+}
+
+// errTransformNotSupported is returned by operations that copy the mbtiles
+// file without reading its tiles if their context has a tile transform.
+var errTransformNotSupported = errors.New("tile transforms are not supported when copying the whole mbtiles file")
+
+// transformTiles replaces the data of each tile in table, such as dst.tiles
+// for an attached database, with the data returned by the tile transform of
+// ctx, if any.  Tiles that reference blobs in store are read from it first;
+// store may be nil.
+func transformTiles(ctx context.Context, q querier, table string, store BlobStore) error {
+	transform := tileTransform(ctx)
+	if transform == nil {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx, "select zoom_level, tile_column, tile_row from "+table+" order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return err
+	}
+	var coords []TileCoord
+	for rows.Next() {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			rows.Close()
+			return err
+		}
+		coords = append(coords, coord)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	where := " where zoom_level = ? and tile_column = ? and tile_row = ?"
+	for _, coord := range coords {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var data []byte
+		if err := q.QueryRowContext(ctx, "select tile_data from "+table+where, coord.Z, coord.X, coord.Y).Scan(&data); err != nil {
+			return err
+		}
+		if err := resolveBlob(ctx, store, &data); err != nil {
+			return &TileError{Coord: coord, Err: err}
+		}
+		transformed, err := transform(coord, data)
+		if err != nil {
+			return &TileError{Coord: coord, Err: err}
+		}
+		if _, err := q.ExecContext(ctx, "update "+table+" set tile_data = ?"+where, transformed, coord.Z, coord.X, coord.Y); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WithTileTransform(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var transformed []TileCoord
+	ctx := WithTileTransform(context.Background(), func(coord TileCoord, data []byte) ([]byte, error) {
+		transformed = append(transformed, coord)
+		return []byte(fmt.Sprintf("%v:%d", coord, len(data))), nil
+	})
+
+	// tiles copied within SQLite are transformed
+	dst := filepath.Join(t.TempDir(), "z1.mbtiles")
+	if count, err := db.ExtractZoom(ctx, dst, 1); err != nil || count != 4 {
+		t.Fatal("Could not extract tiles with transform:", count, err)
+	}
+	if len(transformed) != 4 {
+		t.Error("Expected 4 tiles to be transformed, got:", transformed)
+	}
+	original, err := db.GetTile(context.Background(), 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the extract cannot be opened with Open, as its tiles are not images
+	extract, err := sql.Open("sqlite", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer extract.Close()
+	var data []byte
+	if err := extract.QueryRow(tileQuery, 1, 0, 0).Scan(&data); err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("1/0/0:%d", len(original)); string(data) != expected {
+		t.Error("Transformed tile does not match expected value, got:", string(data))
+	}
+
+	// exported tiles are transformed
+	var stream bytes.Buffer
+	if _, err := db.WriteTileStream(ctx, &stream); err != nil {
+		t.Fatal(err)
+	}
+	sr, err := NewTileStreamReader(&stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coord, data, err := sr.Next(); err != nil || string(data) != fmt.Sprintf("%v:21246", coord) {
+		t.Error("Streamed tile was not transformed, got:", string(data), err)
+	}
+
+	// errors fail the copy
+	failing := WithTileTransform(context.Background(), func(TileCoord, []byte) ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	if _, err := db.ExtractZoom(failing, filepath.Join(t.TempDir(), "failed.mbtiles"), 1); err == nil {
+		t.Error("Expected error from failing transform")
+	}
+
+	// whole file copies cannot transform tiles
+	if err := db.Snapshot(ctx, filepath.Join(t.TempDir(), "snapshot.mbtiles")); err == nil {
+		t.Error("Expected error for snapshot with transform")
+	}
+}
@@ -0,0 +1,74 @@
+package mbtiles
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// uriParameters are the SQLite URI query parameters accepted by Open, with
+// their allowed values.
+var uriParameters = map[string][]string{
+	"mode":      {"ro", "rw"},
+	"cache":     {"shared", "private"},
+	"immutable": {"0", "1"},
+	"nolock":    {"0", "1"},
+}
+
+// parseFileURI parses a file: URI such as
+// "file:/data/world.mbtiles?immutable=1" into the path of the file and its
+// query parameters.  An error is returned if the URI has a host other than
+// localhost, or a parameter that is not one of uriParameters or has a value
+// that is not allowed.
+func parseFileURI(uri string) (string, url.Values, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid file URI %q: %v", uri, err)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", nil, fmt.Errorf("file URI %q must not have a host", uri)
+	}
+
+	path := u.Path
+	if u.Opaque != "" {
+		// relative paths, such as file:data/world.mbtiles
+		if path, err = url.PathUnescape(u.Opaque); err != nil {
+			return "", nil, fmt.Errorf("invalid file URI %q: %v", uri, err)
+		}
+	}
+	if path == "" {
+		return "", nil, fmt.Errorf("file URI %q does not have a path", uri)
+	}
+
+	params := u.Query()
+	for name, values := range params {
+		allowed, ok := uriParameters[name]
+		if !ok {
+			return "", nil, fmt.Errorf("file URI %q has unsupported parameter %q", uri, name)
+		}
+		if len(values) != 1 || !containsString(allowed, values[0]) {
+			return "", nil, fmt.Errorf("file URI %q parameter %q must be one of %s", uri, name, strings.Join(allowed, ", "))
+		}
+	}
+	return path, params, nil
+}
+
+// fileDSN returns the data source name to open path with the SQLite URI query
+// parameters params.
+func fileDSN(path string, params url.Values) string {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath()
+	if len(params) > 0 {
+		dsn += "?" + params.Encode()
+	}
+	return dsn
+}
+
+// containsString returns true if values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
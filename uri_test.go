@@ -0,0 +1,62 @@
+package mbtiles
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_OpenURI(t *testing.T) {
+	path, err := filepath.Abs("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []string{
+		"file:" + filepath.ToSlash(path) + "?immutable=1&mode=ro&cache=shared",
+		"file://localhost" + filepath.ToSlash(path),
+		"file:testdata/geography-class-png.mbtiles?nolock=1",
+	}
+	for _, uri := range tests {
+		db, err := Open(uri)
+		if err != nil {
+			t.Error("Could not open URI:", uri, err)
+			continue
+		}
+		var data []byte
+		if err := db.ReadTile(0, 0, 0, &data); err != nil || len(data) != 21246 {
+			t.Error("Could not read tile from URI:", uri, len(data), err)
+		}
+		if filepath.Base(db.GetFilename()) != "geography-class-png.mbtiles" {
+			t.Error("Filename is not the path of the URI, got:", db.GetFilename())
+		}
+		db.Close()
+	}
+}
+
+func Test_OpenURI_mode(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open("file:" + filepath.ToSlash(filename) + "?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.pool.Exec("delete from metadata"); err == nil {
+		t.Error("File opened with mode=ro is writable")
+	}
+}
+
+func Test_OpenURI_invalid(t *testing.T) {
+	tests := []string{
+		"file:testdata/geography-class-png.mbtiles?mode=memory",
+		"file:testdata/geography-class-png.mbtiles?vfs=unix-none",
+		"file:testdata/geography-class-png.mbtiles?immutable=yes",
+		"file://example.com/data/geography-class-png.mbtiles",
+		"file:?mode=ro",
+	}
+	for _, uri := range tests {
+		if db, err := Open(uri); err == nil {
+			db.Close()
+			t.Error("Invalid URI did not raise error:", uri)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"sync"
+)
+
+// TileError is an error found validating a tile.
+type TileError struct {
+	Coord TileCoord // tile coordinates (TMS scheme)
+	Err   error
+}
+
+func (e TileError) Error() string {
+	return fmt.Sprintf("tile %v: %v", e.Coord, e.Err)
+}
+
+func (e TileError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateRasterTiles decodes raster tiles to check that they are not
+// truncated or corrupt, which can happen if the process that created the
+// mbtiles file was interrupted, and that their size matches the detected tile
+// size.  Returns an entry for each invalid tile, in tile order.
+//
+// Up to concurrency tiles are decoded at a time.  If sample is greater than 1,
+// only every sample-th tile (in tile order) is validated, for a quicker check
+// of large tilesets.  Only PNG and JPG tilesets are supported.
+func (db *MBtiles) ValidateRasterTiles(ctx context.Context, concurrency int, sample int) ([]TileError, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot validate tiles in closed mbtiles database")
+	}
+
+	var decode func([]byte) (image.Config, error)
+	switch format := db.GetTileFormat(); format {
+	case PNG:
+		decode = decodePNG
+	case JPG:
+		decode = decodeJPG
+	default:
+		return nil, fmt.Errorf("cannot validate raster tiles in %v format", format)
+	}
+	tilesize := int(db.GetTileSize())
+
+	return db.validateTiles(ctx, concurrency, sample, func(data []byte) error {
+		config, err := decode(data)
+		if err != nil {
+			return err
+		}
+		if tilesize > 0 && (config.Width != tilesize || config.Height != tilesize) {
+			return fmt.Errorf("tile is %dx%d pixels, expected %dx%d", config.Width, config.Height, tilesize, tilesize)
+		}
+		return nil
+	})
+}
+
+// decodePNG fully decodes a PNG image, and returns its configuration.
+func decodePNG(data []byte) (image.Config, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return imageConfig(img), nil
+}
+
+// decodeJPG fully decodes a JPG image, and returns its configuration.
+func decodeJPG(data []byte) (image.Config, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return imageConfig(img), nil
+}
+
+func imageConfig(img image.Image) image.Config {
+	bounds := img.Bounds()
+	return image.Config{ColorModel: img.ColorModel(), Width: bounds.Dx(), Height: bounds.Dy()}
+}
+
+// validateTiles calls check for every sample-th tile, using up to concurrency
+// goroutines, and returns the errors found in tile order.
+func (db *MBtiles) validateTiles(ctx context.Context, concurrency int, sample int, check func([]byte) error) ([]TileError, error) {
+	if err := db.init(ctx); err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if sample < 1 {
+		sample = 1
+	}
+
+	// read coordinates first, so that only the sampled tiles are read
+	rows, err := db.traced(db.pool).QueryContext(ctx, "select zoom_level, tile_column, tile_row from tiles order by zoom_level, tile_column, tile_row")
+	if err != nil {
+		return nil, err
+	}
+	var coords []TileCoord
+	for i := 0; rows.Next(); i++ {
+		var coord TileCoord
+		if err := rows.Scan(&coord.Z, &coord.X, &coord.Y); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if i%sample == 0 {
+			coords = append(coords, coord)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		invalid  []TileError
+		firstErr error
+		wg       sync.WaitGroup
+		progress = progressFunc(ctx)
+		status   = Progress{Phase: "validate", Total: int64(len(coords))}
+	)
+	pending := make(chan TileCoord)
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for coord := range pending {
+				var data []byte
+				err := db.queryTile(ctx, coord.Z, coord.X, coord.Y, &data)
+				if err == nil {
+					err := check(data)
+					mu.Lock()
+					if err != nil {
+						invalid = append(invalid, TileError{Coord: coord, Err: err})
+					}
+					status.Current++
+					status.Bytes += int64(len(data))
+					progress(status)
+					mu.Unlock()
+					continue
+				}
+				if err != sql.ErrNoRows {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, coord := range coords {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case pending <- coord:
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(invalid, func(i, j int) bool {
+		a, b := invalid[i].Coord, invalid[j].Coord
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+	return invalid, nil
+}
+
+// ValidateVectorTiles decompresses and parses vector tiles to check that they
+// are valid Mapbox Vector Tiles, which could otherwise crash client renderers.
+// Each layer must have a name, a version of 1 or 2, and a positive extent, and
+// each feature must have valid tags and geometry.  Returns an entry for each
+// invalid tile, in tile order, describing the first problem found and the
+// number of invalid features.
+//
+// concurrency and sample are as described for ValidateRasterTiles.  Only PBF
+// tilesets are supported.
+func (db *MBtiles) ValidateVectorTiles(ctx context.Context, concurrency int, sample int) ([]TileError, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot validate tiles in closed mbtiles database")
+	}
+	if format := db.GetTileFormat(); format != PBF {
+		return nil, fmt.Errorf("cannot validate vector tiles in %v format", format)
+	}
+	return db.validateTiles(ctx, concurrency, sample, validateMVT)
+}
+
+// validateMVT checks that data is a valid, optionally gzipped, vector tile.
+func validateMVT(data []byte) error {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		var err error
+		if data, err = gunzip(data); err != nil {
+			return fmt.Errorf("cannot decompress tile: %w", err)
+		}
+	}
+	layers, err := decodeMVT(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse tile: %w", err)
+	}
+
+	for _, layer := range layers {
+		if layer.name == "" {
+			return errors.New("layer has no name")
+		}
+		if layer.version != 1 && layer.version != 2 {
+			return fmt.Errorf("layer %q has unsupported version %d", layer.name, layer.version)
+		}
+		if layer.extent == 0 {
+			return fmt.Errorf("layer %q has an extent of 0", layer.name)
+		}
+
+		var (
+			invalid  int
+			firstErr error
+		)
+		for _, feature := range layer.features {
+			err := validateTags(layer, feature)
+			if err == nil {
+				err = validateGeometry(feature)
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				invalid++
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("layer %q has %d of %d features with invalid tags or geometry: %v", layer.name, invalid, len(layer.features), firstErr)
+		}
+	}
+	return nil
+}
+
+// validateTags checks that the tags of feature are pairs of valid key and value
+// indexes of layer.
+func validateTags(layer mvtLayer, feature mvtFeature) error {
+	if len(feature.tags)%2 != 0 {
+		return errors.New("feature has an odd number of tags")
+	}
+	for i := 0; i < len(feature.tags); i += 2 {
+		if int(feature.tags[i]) >= len(layer.keys) || int(feature.tags[i+1]) >= len(layer.values) {
+			return errors.New("feature has a tag that is not in the layer keys or values")
+		}
+	}
+	return nil
+}
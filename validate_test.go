@@ -0,0 +1,98 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_ValidateRasterTiles(t *testing.T) {
+	for _, path := range []string{"geography-class-png.mbtiles", "geography-class-jpg.mbtiles"} {
+		db, err := Open("./testdata/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		invalid, err := db.ValidateRasterTiles(context.Background(), 2, 1)
+		db.Close()
+		if err != nil {
+			t.Error("Unexpected error validating tiles for:", path, err)
+			continue
+		}
+		if len(invalid) != 0 {
+			t.Error("Expected no invalid tiles for:", path, "got:", invalid)
+		}
+	}
+}
+
+func Test_ValidateRasterTiles_truncated(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.pool.Exec("update images set tile_data = substr(tile_data, 1, 100) where tile_id = (select tile_id from map where zoom_level = 1 and tile_column = 1 and tile_row = 0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid, err := db.ValidateRasterTiles(context.Background(), 4, 1)
+	if err != nil {
+		t.Fatal("Unexpected error validating tiles:", err)
+	}
+	if len(invalid) != 1 || invalid[0].Coord != (TileCoord{Z: 1, X: 1, Y: 0}) {
+		t.Error("Expected truncated tile to be invalid, got:", invalid)
+	}
+
+	// sampling every other tile skips the truncated tile, which is the 4th of 5
+	invalid, err = db.ValidateRasterTiles(context.Background(), 1, 2)
+	if err != nil || len(invalid) != 0 {
+		t.Error("Expected sampled tiles to be valid, got:", invalid, err)
+	}
+
+	pbf, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pbf.Close()
+	if _, err := pbf.ValidateRasterTiles(context.Background(), 1, 1); err == nil {
+		t.Error("Expected error validating raster tiles in PBF tileset")
+	}
+}
+
+func Test_ValidateVectorTiles(t *testing.T) {
+	filename := copyTestdata(t, "world_cities.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	invalid, err := db.ValidateVectorTiles(context.Background(), 2, 1)
+	if err != nil {
+		t.Fatal("Unexpected error validating tiles:", err)
+	}
+	if len(invalid) != 0 {
+		t.Error("Expected no invalid tiles, got:", invalid)
+	}
+
+	if _, err := db.pool.Exec("update tiles set tile_data = substr(tile_data, 1, 20) where zoom_level = 2 and tile_column = 1 and tile_row = 2"); err != nil {
+		t.Fatal(err)
+	}
+	invalid, err = db.ValidateVectorTiles(context.Background(), 2, 1)
+	if err != nil {
+		t.Fatal("Unexpected error validating tiles:", err)
+	}
+	if len(invalid) != 1 || invalid[0].Coord != (TileCoord{Z: 2, X: 1, Y: 2}) {
+		t.Error("Expected truncated tile to be invalid, got:", invalid)
+	}
+
+	png, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer png.Close()
+	if _, err := png.ValidateVectorTiles(context.Background(), 1, 1); err == nil {
+		t.Error("Expected error validating vector tiles in PNG tileset")
+	}
+}
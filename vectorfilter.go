@@ -0,0 +1,110 @@
+package mbtiles
+
+import "fmt"
+
+// VectorFilter removes layers, attributes, and features from vector tiles as
+// they are copied, as a lightweight alternative to re-tiling: use its
+// Transform with WithTileTransform, such as for ExtractZoom or JoinLayers.
+type VectorFilter struct {
+	DropLayers     []string // names of layers to remove
+	DropAttributes []string // names of attributes to remove from the features of all layers
+	// KeepFeature, if not nil, is called with the name of the layer and the
+	// attributes of each feature, including those in DropAttributes, and
+	// the feature is removed unless it returns true.
+	KeepFeature func(layer string, attributes map[string]interface{}) bool
+}
+
+// Transform returns a TileTransform that applies the filter to vector tiles.
+// Filtered tiles are re-encoded, and gzip compressed if the source tile is.
+// Layers without features are removed; tiles without layers are written
+// without them, as the transformed tile replaces the source tile.  An error is
+// returned for tiles that are not vector tiles.
+func (f VectorFilter) Transform() TileTransform {
+	dropLayers := make(map[string]bool, len(f.DropLayers))
+	for _, name := range f.DropLayers {
+		dropLayers[name] = true
+	}
+	dropAttributes := make(map[string]bool, len(f.DropAttributes))
+	for _, name := range f.DropAttributes {
+		dropAttributes[name] = true
+	}
+
+	return func(coord TileCoord, data []byte) ([]byte, error) {
+		gzipped := len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+		layers, err := decodeTileMVT(data)
+		if err != nil {
+			return nil, err
+		}
+		var filtered []mvtLayer
+		for _, layer := range layers {
+			if dropLayers[layer.name] {
+				continue
+			}
+			layer, err := filterLayer(layer, dropAttributes, f.KeepFeature)
+			if err != nil {
+				return nil, err
+			}
+			if len(layer.features) > 0 {
+				filtered = append(filtered, layer)
+			}
+		}
+
+		data = encodeMVT(filtered)
+		if gzipped {
+			return gzipBytes(data)
+		}
+		return data, nil
+	}
+}
+
+// filterLayer returns layer with only the features for which keep returns
+// true, or all features if keep is nil, without the attributes in drop.  The
+// keys and values of the layer are limited to those of the remaining tags.
+func filterLayer(layer mvtLayer, drop map[string]bool, keep func(string, map[string]interface{}) bool) (mvtLayer, error) {
+	filtered := mvtLayer{version: layer.version, name: layer.name, extent: layer.extent}
+	keys := make(map[string]uint32)
+	values := make(map[interface{}]uint32)
+	for _, feature := range layer.features {
+		if len(feature.tags)%2 != 0 {
+			return mvtLayer{}, fmt.Errorf("feature of layer %q has an odd number of tags", layer.name)
+		}
+		for j := 0; j < len(feature.tags); j += 2 {
+			if int(feature.tags[j]) >= len(layer.keys) || int(feature.tags[j+1]) >= len(layer.values) {
+				return mvtLayer{}, fmt.Errorf("feature of layer %q has invalid tags", layer.name)
+			}
+		}
+		if keep != nil {
+			attributes := make(map[string]interface{}, len(feature.tags)/2)
+			for j := 0; j < len(feature.tags); j += 2 {
+				attributes[layer.keys[feature.tags[j]]] = layer.values[feature.tags[j+1]]
+			}
+			if !keep(layer.name, attributes) {
+				continue
+			}
+		}
+
+		tags := make([]uint32, 0, len(feature.tags))
+		for j := 0; j < len(feature.tags); j += 2 {
+			name, value := layer.keys[feature.tags[j]], layer.values[feature.tags[j+1]]
+			if drop[name] {
+				continue
+			}
+			key, ok := keys[name]
+			if !ok {
+				key = uint32(len(filtered.keys))
+				keys[name] = key
+				filtered.keys = append(filtered.keys, name)
+			}
+			index, ok := values[value]
+			if !ok {
+				index = uint32(len(filtered.values))
+				values[value] = index
+				filtered.values = append(filtered.values, value)
+			}
+			tags = append(tags, key, index)
+		}
+		feature.tags = tags
+		filtered.features = append(filtered.features, feature)
+	}
+	return filtered, nil
+}
@@ -0,0 +1,82 @@
+package mbtiles
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func Test_VectorFilter(t *testing.T) {
+	tile := encodeMVT([]mvtLayer{
+		{version: 2, name: "roads", extent: 4096, keys: []string{"class", "name"}, values: []interface{}{"primary", "Main St", "minor"}, features: []mvtFeature{
+			{tags: []uint32{0, 0, 1, 1}, geomType: mvtPoint, geometry: encodePointGeometry([][2]int32{{1, 1}})},
+			{tags: []uint32{0, 2}, geomType: mvtPoint, geometry: encodePointGeometry([][2]int32{{2, 2}})},
+		}},
+		{version: 2, name: "buildings", extent: 4096, features: []mvtFeature{
+			{geomType: mvtPoint, geometry: encodePointGeometry([][2]int32{{3, 3}})},
+		}},
+	})
+
+	filter := VectorFilter{
+		DropLayers:     []string{"buildings"},
+		DropAttributes: []string{"name"},
+		KeepFeature: func(layer string, attributes map[string]interface{}) bool {
+			return attributes["class"] == "primary" && attributes["name"] == "Main St"
+		},
+	}
+	data, err := filter.Transform()(TileCoord{}, tile)
+	if err != nil {
+		t.Fatal("Unexpected error filtering tile:", err)
+	}
+	layers, err := decodeMVT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || layers[0].name != "roads" || len(layers[0].features) != 1 {
+		t.Fatal("Filtered layers do not match expected values, got:", layers)
+	}
+	roads := layers[0]
+	if len(roads.keys) != 1 || roads.keys[0] != "class" || len(roads.values) != 1 || roads.values[0] != "primary" {
+		t.Error("Filtered keys and values do not match expected values, got:", roads.keys, roads.values)
+	}
+	if tags := roads.features[0].tags; len(tags) != 2 || tags[0] != 0 || tags[1] != 0 {
+		t.Error("Filtered tags do not match expected values, got:", tags)
+	}
+
+	if _, err := filter.Transform()(TileCoord{}, []byte("not a tile")); err == nil {
+		t.Error("Expected error filtering tile that is not a vector tile")
+	}
+}
+
+func Test_VectorFilter_extract(t *testing.T) {
+	db, err := Open("./testdata/world_cities.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	filter := VectorFilter{KeepFeature: func(layer string, attributes map[string]interface{}) bool {
+		return attributes["name"] == "Vancouver"
+	}}
+	ctx := WithTileTransform(context.Background(), filter.Transform())
+	dst := filepath.Join(t.TempDir(), "vancouver.mbtiles")
+	if _, err := db.ExtractZoom(ctx, dst, 0); err != nil {
+		t.Fatal("Could not extract filtered tiles:", err)
+	}
+
+	extract, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer extract.Close()
+	layers, gzipped, err := extract.readTileLayers(context.Background(), TileCoord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gzipped {
+		t.Error("Expected filtered tile to be gzip compressed like the source tile")
+	}
+	if len(layers) != 1 || len(layers[0].features) != 1 {
+		t.Error("Expected a single feature in filtered tile, got:", layers)
+	}
+}
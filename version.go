@@ -0,0 +1,89 @@
+package mbtiles
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionPart is the part of the semantic version in the version metadata
+// item that is incremented by BumpVersion.
+type VersionPart uint8
+
+// VersionPart values
+const (
+	BumpPatch VersionPart = iota + 1 // increment the patch version: 1.2.3 to 1.2.4
+	BumpMinor                        // increment the minor version: 1.2.3 to 1.3.0
+	BumpMajor                        // increment the major version: 1.2.3 to 2.0.0
+)
+
+// BumpVersion makes operations that write or delete tiles using the MBtiles
+// handle increment part of the version metadata item, in the same transaction
+// as the change, so that downstream caches can be keyed on the version.
+//
+// Versions with fewer than 3 parts are padded with zeros, such as 1.1 to
+// 1.1.0.  If the version is missing or is not a semantic version, it is set
+// to 1.0.0.
+func BumpVersion(part VersionPart) OpenOption {
+	return func(o *openOptions) {
+		o.versionBump = part
+	}
+}
+
+// GetVersion returns the version metadata item of the tileset, or an empty
+// string if it is not present.
+func (db *MBtiles) GetVersion() (string, error) {
+	metadata, err := db.ReadMetadata()
+	if err != nil {
+		return "", err
+	}
+	version, _ := metadata["version"].(string)
+	return version, nil
+}
+
+// bumpVersion increments the version metadata item within a transaction that
+// changes tiles, if enabled with BumpVersion.
+func (db *MBtiles) bumpVersion(ctx context.Context, tx querier) error {
+	if db.options.versionBump == 0 {
+		return nil
+	}
+
+	var version string
+	err := tx.QueryRowContext(ctx, "select value from metadata where name = 'version'").Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "delete from metadata where name = 'version'"); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "insert into metadata (name, value) values ('version', ?)", bumpSemver(version, db.options.versionBump))
+	return err
+}
+
+// bumpSemver increments part of version, as described for BumpVersion.
+func bumpSemver(version string, part VersionPart) string {
+	split := strings.Split(strings.TrimSpace(version), ".")
+	if len(split) > 3 {
+		return "1.0.0"
+	}
+	var parts [3]int
+	for i, value := range split {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return "1.0.0"
+		}
+		parts[i] = n
+	}
+
+	switch part {
+	case BumpMajor:
+		parts = [3]int{parts[0] + 1, 0, 0}
+	case BumpMinor:
+		parts = [3]int{parts[0], parts[1] + 1, 0}
+	default:
+		parts[2]++
+	}
+	return fmt.Sprintf("%d.%d.%d", parts[0], parts[1], parts[2])
+}
@@ -0,0 +1,61 @@
+package mbtiles
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_bumpSemver(t *testing.T) {
+	tests := []struct {
+		version  string
+		part     VersionPart
+		expected string
+	}{
+		{"1.2.3", BumpPatch, "1.2.4"},
+		{"1.2.3", BumpMinor, "1.3.0"},
+		{"1.2.3", BumpMajor, "2.0.0"},
+		{"1.1", BumpPatch, "1.1.1"},
+		{"2", BumpMinor, "2.1.0"},
+		{"", BumpPatch, "1.0.0"},
+		{"v1-beta", BumpPatch, "1.0.0"},
+	}
+	for _, tc := range tests {
+		if got := bumpSemver(tc.version, tc.part); got != tc.expected {
+			t.Error("bumpSemver", tc.version, "got:", got, "expected:", tc.expected)
+		}
+	}
+}
+
+func Test_BumpVersion(t *testing.T) {
+	db, err := Open(copyTestdata(t, "world_cities.mbtiles"), BumpVersion(BumpMinor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	before, err := db.GetVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no tiles are deleted, so the version is unchanged
+	if _, err := db.DeleteTilesInBounds(ctx, []float64{0, 0, 0.001, 0.001}, 6, 6); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.GetVersion(); err != nil || version != before {
+		t.Error("Version changed without tile changes, got:", version, err)
+	}
+
+	deleted, err := db.DeleteTilesInBounds(ctx, []float64{-180, -85, 180, 85}, 6, 6)
+	if err != nil || deleted == 0 {
+		t.Fatal("Could not delete tiles:", err)
+	}
+	version, err := db.GetVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != bumpSemver(before, BumpMinor) {
+		t.Error("Version was not bumped, got:", version, "from:", before)
+	}
+}
@@ -0,0 +1,58 @@
+package mbtiles
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// sqliteHeader is the magic string at the start of every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// isWAL returns true if the SQLite database read from r is in WAL mode, as
+// recorded in its header by the file format read and write versions.
+func isWAL(r io.Reader) (bool, error) {
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// too short to be a SQLite database; let SQLite report the error
+			return false, nil
+		}
+		return false, err
+	}
+	if !bytes.Equal(header[:len(sqliteHeader)], sqliteHeader) {
+		return false, nil
+	}
+	return header[18] == 2 && header[19] == 2, nil
+}
+
+// fileIsWAL returns true if the SQLite database at path is in WAL mode.
+func fileIsWAL(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return isWAL(f)
+}
+
+// fsFileIsWAL returns true if the SQLite database at name in fsys is in WAL
+// mode.
+func fsFileIsWAL(fsys fs.FS, name string) (bool, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return isWAL(f)
+}
+
+// IsWAL returns true if the mbtiles file was in WAL mode when it was opened.
+//
+// Tilesets in WAL mode may be updated by another process while they are open.
+// Each tile read sees a consistent snapshot of the file, as do the queries of
+// a single ReadMetadata call, which are run in one read transaction.
+func (db *MBtiles) IsWAL() bool {
+	return db.wal
+}
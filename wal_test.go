@@ -0,0 +1,48 @@
+package mbtiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OpenMBtiles_WAL(t *testing.T) {
+	filename := copyTestdata(t, "geography-class-png.mbtiles")
+	db, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.IsWAL() {
+		t.Error("Expected file not to be in WAL mode")
+	}
+	if _, err := db.pool.Exec("pragma journal_mode = wal"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// a -journal file is ignored for files in WAL mode
+	if err := os.WriteFile(filename+"-journal", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(filename)
+	if err != nil {
+		t.Fatal("Could not open file in WAL mode:", err)
+	}
+	defer db.Close()
+	if !db.IsWAL() {
+		t.Error("Expected file to be in WAL mode")
+	}
+	var data []byte
+	if err := db.ReadTile(0, 0, 0, &data); err != nil || data == nil {
+		t.Error("Could not read tile from file in WAL mode:", err)
+	}
+
+	found, err := FindMBtiles(filepath.Dir(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(found, []string{filename}) {
+		t.Error("Expected file in WAL mode to be found, got:", found)
+	}
+}
@@ -0,0 +1,141 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+// Watermark is a logo drawn over served raster tiles, such as for trial or
+// demo deployments of licensed imagery.
+type Watermark struct {
+	Logo    image.Image
+	Opacity float64 // opacity of the logo from 0 to 1; 1 if 0
+	// Spacing is the distance in pixels between repeated logos, horizontally
+	// and vertically.  Logos are placed across tile edges, so that the
+	// pattern is continuous over the whole map.  If Spacing is 0, the logo is
+	// drawn once at the center of each tile.
+	Spacing int
+}
+
+// WatermarkedTiles serves the raster tiles of an mbtiles file with a
+// Watermark drawn over them.  Watermarked tiles are kept in an LRU cache, so
+// that each tile is only decoded and encoded again once.
+type WatermarkedTiles struct {
+	db    *MBtiles
+	mark  Watermark
+	alpha *image.Uniform
+	cache *tileCache
+}
+
+// NewWatermarkedTiles creates a source of the tiles of db with mark drawn
+// over them, with a cache of up to cacheSize watermarked tiles.  Only PNG
+// and JPG tilesets are supported; tiles are encoded in the format of db.
+func NewWatermarkedTiles(db *MBtiles, mark Watermark, cacheSize int) (*WatermarkedTiles, error) {
+	if db.isClosed() {
+		return nil, errors.New("cannot watermark tiles of closed mbtiles database")
+	}
+	if mark.Logo == nil {
+		return nil, errors.New("watermark requires a logo")
+	}
+	if mark.Opacity < 0 || mark.Opacity > 1 {
+		return nil, fmt.Errorf("invalid watermark opacity %v", mark.Opacity)
+	}
+	if mark.Spacing < 0 {
+		return nil, fmt.Errorf("invalid watermark spacing %d", mark.Spacing)
+	}
+	if err := db.init(context.TODO()); err != nil {
+		return nil, err
+	}
+	if format := db.GetTileFormat(); format != PNG && format != JPG {
+		return nil, fmt.Errorf("watermark is only supported for PNG and JPG tilesets, not %v", format)
+	}
+
+	opacity := mark.Opacity
+	if opacity == 0 {
+		opacity = 1
+	}
+	return &WatermarkedTiles{
+		db:    db,
+		mark:  mark,
+		alpha: image.NewUniform(color.Alpha{A: uint8(opacity*255 + 0.5)}),
+		cache: newTileCache(cacheSize),
+	}, nil
+}
+
+// GetTile returns the watermarked tile for z, x, y (TMS scheme), from the
+// cache if available.  ErrTileNotFound is returned if the tile does not
+// exist.
+func (w *WatermarkedTiles) GetTile(ctx context.Context, z int64, x int64, y int64) ([]byte, error) {
+	coord := TileCoord{Z: z, X: x, Y: y}
+	if data, ok := w.cache.get(coord); ok {
+		return data, nil
+	}
+
+	generation := w.cache.current()
+	img, err := readTileNRGBA(ctx, w.db, coord)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, ErrTileNotFound
+	}
+	w.draw(img, coord.FlipY())
+
+	var data []byte
+	if w.db.GetTileFormat() == JPG {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	} else if data, err = encodePNG(img); err != nil {
+		return nil, err
+	}
+	w.cache.add(coord, data, generation)
+	return data, nil
+}
+
+// draw draws the logo over img, the tile at coord (XYZ scheme).
+func (w *WatermarkedTiles) draw(img *image.NRGBA, coord TileCoord) {
+	logo := w.mark.Logo.Bounds()
+	size := img.Rect.Size()
+	if w.mark.Spacing == 0 {
+		at := image.Pt((size.X-logo.Dx())/2, (size.Y-logo.Dy())/2)
+		draw.DrawMask(img, logo.Sub(logo.Min).Add(at), w.mark.Logo, logo.Min, w.alpha, image.Point{}, draw.Over)
+		return
+	}
+
+	// pixel coordinates of the tile within the map at its zoom level
+	left, top := coord.X*int64(size.X), coord.Y*int64(size.Y)
+	spacing := int64(w.mark.Spacing)
+	// first logos that end after the left and top edges of the tile
+	firstX := (floorDiv(left-int64(logo.Dx()), spacing) + 1) * spacing
+	firstY := (floorDiv(top-int64(logo.Dy()), spacing) + 1) * spacing
+	for py := firstY; py < top+int64(size.Y); py += spacing {
+		for px := firstX; px < left+int64(size.X); px += spacing {
+			at := image.Pt(int(px-left), int(py-top))
+			draw.DrawMask(img, logo.Sub(logo.Min).Add(at), w.mark.Logo, logo.Min, w.alpha, image.Point{}, draw.Over)
+		}
+	}
+}
+
+// Purge removes all watermarked tiles from the cache, so that changes to the
+// tiles of the mbtiles file are served.
+func (w *WatermarkedTiles) Purge() {
+	w.cache.purge()
+}
+
+// floorDiv returns a divided by b, rounded down, for positive b.
+func floorDiv(a int64, b int64) int64 {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return q
+}
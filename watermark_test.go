@@ -0,0 +1,75 @@
+package mbtiles
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func Test_WatermarkedTiles(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	red := color.NRGBA{R: 255, A: 255}
+	logo := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(logo, logo.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+
+	decode := func(data []byte) image.Image {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	centered, err := NewWatermarkedTiles(db, Watermark{Logo: logo}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := centered.GetTile(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal("Could not read watermarked tile:", err)
+	}
+	if c := color.NRGBAModel.Convert(decode(data).At(128, 128)); c != red {
+		t.Error("Expected logo at center of tile, got:", c)
+	}
+	if cached, _ := centered.GetTile(ctx, 0, 0, 0); !bytes.Equal(cached, data) {
+		t.Error("Expected cached tile to match")
+	}
+	if _, err := centered.GetTile(ctx, 10, 0, 0); err != ErrTileNotFound {
+		t.Error("Expected ErrTileNotFound for missing tile, got:", err)
+	}
+
+	// the pattern of repeated logos continues across tile edges
+	repeated, err := NewWatermarkedTiles(db, Watermark{Logo: logo, Spacing: 100}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := db.GetTile(ctx, 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = repeated.GetTile(ctx, 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decode(data)
+	if c := color.NRGBAModel.Convert(img.At(44, 0)); c != red {
+		t.Error("Expected logo at 300 pixels from the left of the map, got:", c)
+	}
+	if img.At(60, 60) != decode(original).At(60, 60) {
+		t.Error("Expected pixels between logos to be unchanged")
+	}
+
+	if _, err := NewWatermarkedTiles(db, Watermark{Logo: logo, Opacity: 2}, 0); err == nil {
+		t.Error("Expected error for invalid opacity")
+	}
+}
@@ -0,0 +1,55 @@
+package mbtiles
+
+// CoordinateMode is how tile coordinates outside the range of tiles at their
+// zoom level are handled by writes, as set with WriteCoordinates.
+type CoordinateMode uint8
+
+// CoordinateMode values
+const (
+	// StrictCoordinates rejects coordinates outside the range of tiles at
+	// their zoom level with an error wrapping ErrInvalidTileCoord.
+	StrictCoordinates CoordinateMode = iota
+	// LenientCoordinates wraps columns outside the range of tiles at their
+	// zoom level around the antimeridian, as written by some generators for
+	// tiles that cross it.  Zoom levels and rows outside their range are
+	// still rejected, as they cannot be normalized to a tile.
+	LenientCoordinates
+)
+
+// WriteCoordinates sets the tiling scheme of the coordinates of tiles
+// written to or deleted from an Overlay, which are converted to the TMS
+// scheme of mbtiles files, and whether coordinates outside the range of tiles
+// at their zoom level are rejected or normalized, so that tiles that can
+// never be read are not written.  By default, coordinates are in the TMS
+// scheme and are rejected if they are outside their range.
+//
+// Open and other functions that do not open an Overlay return an error if
+// this option is set, as their handles do not write tiles at coordinates
+// chosen by the caller.
+func WriteCoordinates(scheme TileScheme, mode CoordinateMode) OpenOption {
+	return func(o *openOptions) {
+		o.writeCoordinates = true
+		o.writeXYZ = scheme == XYZScheme
+		o.coordinateMode = mode
+	}
+}
+
+// normalizeWriteCoord converts coord from the scheme set with WriteCoordinates
+// to the TMS scheme, normalizing it in LenientCoordinates mode, or returns an
+// error wrapping ErrInvalidTileCoord if it is not valid.
+func (o openOptions) normalizeWriteCoord(coord TileCoord) (TileCoord, error) {
+	if o.coordinateMode == LenientCoordinates && coord.Z >= 0 && coord.Z <= MaxZoomLevel {
+		n := int64(1) << coord.Z
+		coord.X %= n
+		if coord.X < 0 {
+			coord.X += n
+		}
+	}
+	if err := coord.Validate(); err != nil {
+		return TileCoord{}, err
+	}
+	if o.writeXYZ {
+		coord = coord.FlipY()
+	}
+	return coord, nil
+}
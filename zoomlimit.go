@@ -0,0 +1,40 @@
+package mbtiles
+
+import "errors"
+
+// ErrZoomNotServed is returned by ServeTile for tiles at zoom levels outside
+// the ZoomLimit set with ServedZooms.  Handlers usually respond with 404 Not
+// Found, or 403 Forbidden if higher zoom levels are available to other users.
+var ErrZoomNotServed = errors.New("zoom level is not served")
+
+// ZoomLimit is a range of zoom levels that are served, independent of the
+// zoom levels stored in the mbtiles file.
+type ZoomLimit struct {
+	MinZoom int
+	MaxZoom int
+}
+
+// Allows returns true if tiles at zoom level z are served.
+func (l ZoomLimit) Allows(z int64) bool {
+	return z >= int64(l.MinZoom) && z <= int64(l.MaxZoom)
+}
+
+// ServedZooms limits the zoom levels served by ServeTile to limit, so that
+// operators can restrict access to expensive high zoom levels while keeping
+// them in the file.  Other reads, such as GetTile, are not limited.  Handlers
+// that serve some users, such as anonymous users, fewer zoom levels than
+// others can instead check a ZoomLimit for each request.
+func ServedZooms(limit ZoomLimit) OpenOption {
+	return func(o *openOptions) {
+		o.servedZooms = &limit
+	}
+}
+
+// ServedZooms returns the zoom levels served by ServeTile, and false if they
+// are not limited.
+func (db *MBtiles) ServedZooms() (ZoomLimit, bool) {
+	if db.options.servedZooms == nil {
+		return ZoomLimit{}, false
+	}
+	return *db.options.servedZooms, true
+}
@@ -0,0 +1,43 @@
+package mbtiles
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ServedZooms(t *testing.T) {
+	db, err := Open("./testdata/geography-class-png.mbtiles", ServedZooms(ZoomLimit{MinZoom: 0, MaxZoom: 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if limit, ok := db.ServedZooms(); !ok || limit.MaxZoom != 0 {
+		t.Error("ServedZooms does not match expected value, got:", limit, ok)
+	}
+
+	w := httptest.NewRecorder()
+	if err := db.ServeTile(w, httptest.NewRequest(http.MethodGet, "/0/0/0.png", nil), 0, 0, 0); err != nil || w.Code != http.StatusOK {
+		t.Error("Expected tile within limit to be served, got:", w.Code, err)
+	}
+	w = httptest.NewRecorder()
+	if err := db.ServeTile(w, httptest.NewRequest(http.MethodGet, "/1/0/0.png", nil), 1, 0, 0); err != ErrZoomNotServed || w.Body.Len() != 0 {
+		t.Error("Expected ErrZoomNotServed without response, got:", err)
+	}
+
+	// the data remains readable
+	if _, err := db.GetTile(context.Background(), 1, 0, 0); err != nil {
+		t.Error("Expected tile outside limit to be readable, got:", err)
+	}
+
+	unlimited, err := Open("./testdata/geography-class-png.mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlimited.Close()
+	if _, ok := unlimited.ServedZooms(); ok {
+		t.Error("Expected zoom levels not to be limited")
+	}
+}